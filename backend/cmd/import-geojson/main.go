@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/services"
+)
+
+// 校园地图导入导出工具：片区多边形与投递点坐标的 GeoJSON
+func main() {
+	var (
+		importFile = flag.String("import", "", "GeoJSON file to import")
+		exportFile = flag.String("export", "", "Write campus map to this GeoJSON file (- for stdout)")
+		school     = flag.String("school", "", "Only export this school code")
+		dryRun     = flag.Bool("dry-run", false, "Validate the import without writing")
+		publicOnly = flag.Bool("public", false, "Export public points only")
+	)
+	flag.Parse()
+
+	if (*importFile == "") == (*exportFile == "") {
+		fmt.Println("OpenPenPal Campus GeoJSON Tool")
+		fmt.Println("Usage:")
+		fmt.Println("  -import <file>  Import area polygons and point coordinates")
+		fmt.Println("  -dry-run        Validate the import without writing")
+		fmt.Println("  -export <file>  Export the campus map (- for stdout)")
+		fmt.Println("  -school <code>  Only export one school")
+		fmt.Println("  -public         Export public points only")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  go run cmd/import-geojson/main.go -import pku.geojson -dry-run")
+		fmt.Println("  go run cmd/import-geojson/main.go -export pku.geojson -school PK")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := config.SetupDatabase(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	opcodeService := services.NewOPCodeService(db)
+
+	if *exportFile != "" {
+		collection, err := opcodeService.ExportGeoJSON(*school, *publicOnly)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		data, err := json.MarshalIndent(collection, "", "  ")
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		if *exportFile == "-" {
+			fmt.Println(string(data))
+			return
+		}
+		if err := os.WriteFile(*exportFile, data, 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", *exportFile, err)
+		}
+		fmt.Printf("✅ Exported %d features to %s\n", len(collection.Features), *exportFile)
+		return
+	}
+
+	data, err := os.ReadFile(*importFile)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *importFile, err)
+	}
+	result, err := opcodeService.ImportGeoJSON(data, *dryRun)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, issue := range result.Issues {
+		fmt.Printf("⚠️  feature #%d %s: %s\n", issue.Feature, issue.Ref, issue.Reason)
+	}
+	mode := "Imported"
+	if result.DryRun {
+		mode = "Dry run"
+	}
+	fmt.Printf("✅ %s: %d areas created, %d areas updated, %d points updated, %d skipped\n",
+		mode, result.AreasCreated, result.AreasUpdated, result.PointsUpdated, len(result.Issues))
+}
@@ -39,6 +39,30 @@ type Config struct {
 
 	// QR Code
 	QRCodeStorePath string
+	QRSigningKeys   string // 二维码签名密钥，格式 kid:base64种子,kid:base64种子
+	QRSigningKeyID  string // 当前签发使用的密钥ID，为空时使用第一个
+	QRTokenTTLDays  int    // 二维码令牌有效天数
+	QRTokenRequired bool   // 扫码时是否必须携带签名令牌
+
+	// 两步验证
+	TwoFactorEncryptionKeys  string // TOTP密钥加密密钥，格式 kid:base64密钥,kid:base64密钥（AES-256，32字节）
+	TwoFactorEncryptionKeyID string // 当前加密使用的密钥ID，为空时使用第一个
+
+	// 信封标签打印
+	LabelStorePath    string // 标签 PDF 存放目录，不对外静态暴露
+	LabelArtworkHosts string // 允许下载信封设计图的外部域名（逗号分隔），为空时只读取本地 uploads
+
+	// 条码生命周期
+	BarcodeUnactivatedTTLDays int // 生成后未绑定信封的条码有效天数
+	BarcodeBoundTTLDays       int // 绑定后一直未被收取的条码有效天数
+	BarcodeExpiryWarnHours    int // 过期前多少小时提醒寄信人
+
+	// 手写信件 OCR
+	OCRServiceURL    string // ocr-service 地址，为空时不识别
+	OCRServiceAPIKey string // 调用 ocr-service 识别接口的服务密钥，与其 OCR_SERVICE_API_KEY 一致
+
+	// OP Code 校验位
+	OPCodeLegacyUntil string // 6位旧格式过渡期截止日期（YYYY-MM-DD），为空表示不限期
 
 	// AI
 	OpenAIAPIKey      string
@@ -99,6 +123,25 @@ func Load() (*Config, error) {
 
 		// QR Code
 		QRCodeStorePath: getEnv("QR_CODE_STORE_PATH", "./uploads/qrcodes"),
+		QRSigningKeys:   getEnv("QR_SIGNING_KEYS", ""),
+		QRSigningKeyID:  getEnv("QR_SIGNING_KEY_ID", ""),
+		QRTokenTTLDays:  getEnvAsInt("QR_TOKEN_TTL_DAYS", 365),
+		QRTokenRequired: getEnv("QR_TOKEN_REQUIRED", "false") == "true",
+
+		TwoFactorEncryptionKeys:  getEnv("TWO_FACTOR_ENCRYPTION_KEYS", ""),
+		TwoFactorEncryptionKeyID: getEnv("TWO_FACTOR_ENCRYPTION_KEY_ID", ""),
+
+		LabelStorePath:    getEnv("LABEL_STORE_PATH", "./data/labels"),
+		LabelArtworkHosts: getEnv("LABEL_ARTWORK_HOSTS", ""),
+
+		BarcodeUnactivatedTTLDays: getEnvAsInt("BARCODE_UNACTIVATED_TTL_DAYS", 30),
+		BarcodeBoundTTLDays:       getEnvAsInt("BARCODE_BOUND_TTL_DAYS", 14),
+		BarcodeExpiryWarnHours:    getEnvAsInt("BARCODE_EXPIRY_WARN_HOURS", 72),
+
+		OCRServiceURL:    getEnv("OCR_SERVICE_URL", ""),
+		OCRServiceAPIKey: getEnv("OCR_SERVICE_API_KEY", ""),
+
+		OPCodeLegacyUntil: getEnv("OPCODE_LEGACY_UNTIL", ""),
 
 		// AI
 		OpenAIAPIKey:      getEnv("OPENAI_API_KEY", ""),
@@ -186,6 +186,64 @@ func getAllModels() []interface{} {
 		&models.OPCodeArea{},
 		&models.OPCodeApplication{},
 		&models.OPCodePermission{},
+
+		// 数据主体请求（个人数据导出/账户删除）
+		&models.DataSubjectRequest{},
+		&models.DataRequestAuditLog{},
+
+		// 登录会话与刷新令牌
+		&models.UserSession{},
+		&models.RefreshToken{},
+
+		// 两步验证
+		&models.UserTwoFactor{},
+		&models.TwoFactorRecoveryCode{},
+		&models.TrustedDevice{},
+		&models.LoginChallenge{},
+
+		// 校园统一身份认证（OIDC/CAS）
+		&models.IdentityProvider{},
+		&models.UserIdentity{},
+		&models.SSOLoginState{},
+
+		// 在校身份认证
+		&models.SchoolVerification{},
+
+		// 签收凭证
+		&models.DeliveryConfirmation{},
+		&models.DeliveryReceipt{},
+
+		// 信件状态同步
+		&models.LetterOutboxEvent{},
+		&models.LetterSyncInbox{},
+		&models.LetterSyncState{},
+		&models.LetterSyncDivergence{},
+
+		// 信封标签打印
+		&models.EnvelopePrintBatch{},
+		&models.EnvelopePrintItem{},
+
+		// OP Code转寄
+		&models.OPCodeForward{},
+		&models.LetterReroute{},
+
+		// OP Code批量导入
+		&models.OPCodeImport{},
+		&models.OPCodeImportItem{},
+
+		// 公共信箱收取
+		&models.MailboxSchedule{},
+		&models.MailboxCollectionRun{},
+		&models.MailboxCollectionItem{},
+
+		// 手写信件识别
+		&models.OCRPreference{},
+		&models.LetterOCRJob{},
+		&models.LetterOCRRegion{},
+
+		// 信封设计评选
+		&models.EnvelopeContest{},
+		&models.EnvelopeContestVote{},
 	}
 }
 
@@ -51,6 +51,28 @@ func SetupTestDB() (*gorm.DB, error) {
 		&models.AdminActivity{},
 		&models.ModerationRecord{},
 		&models.ModerationRule{},
+		&models.LetterOutboxEvent{},
+		&models.LetterSyncInbox{},
+		&models.LetterSyncState{},
+		&models.LetterSyncDivergence{},
+		&models.EnvelopePrintBatch{},
+		&models.EnvelopePrintItem{},
+		&models.OPCode{},
+		&models.OPCodeSchool{},
+		&models.OPCodeArea{},
+		&models.OPCodeApplication{},
+		&models.OPCodeForward{},
+		&models.LetterReroute{},
+		&models.OPCodeImport{},
+		&models.OPCodeImportItem{},
+		&models.MailboxSchedule{},
+		&models.MailboxCollectionRun{},
+		&models.MailboxCollectionItem{},
+		&models.OCRPreference{},
+		&models.LetterOCRJob{},
+		&models.LetterOCRRegion{},
+		&models.EnvelopeContest{},
+		&models.EnvelopeContestVote{},
 	)
 	if err != nil {
 		return nil, err
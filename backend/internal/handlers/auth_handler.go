@@ -17,9 +17,12 @@ import (
 
 // AuthHandler 认证处理器 - 专门处理认证相关请求
 type AuthHandler struct {
-	userService *services.UserService
-	config      *config.Config
-	csrfHandler *middleware.CSRFHandler
+	userService      *services.UserService
+	sessionService   *services.SessionService
+	twoFactorService *services.TwoFactorService
+	ssoService       *services.SSOService
+	config           *config.Config
+	csrfHandler      *middleware.CSRFHandler
 }
 
 // NewAuthHandler 创建认证处理器
@@ -31,6 +34,21 @@ func NewAuthHandler(userService *services.UserService, config *config.Config) *A
 	}
 }
 
+// SetSessionService 设置会话服务
+func (h *AuthHandler) SetSessionService(sessionService *services.SessionService) {
+	h.sessionService = sessionService
+}
+
+// SetTwoFactorService 设置两步验证服务
+func (h *AuthHandler) SetTwoFactorService(twoFactorService *services.TwoFactorService) {
+	h.twoFactorService = twoFactorService
+}
+
+// SetSSOService 设置校园单点登录服务
+func (h *AuthHandler) SetSSOService(ssoService *services.SSOService) {
+	h.ssoService = ssoService
+}
+
 // GetCSRFToken 获取CSRF令牌
 func (h *AuthHandler) GetCSRFToken(c *gin.Context) {
 	h.csrfHandler.GetCSRFToken(c)
@@ -105,6 +123,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// 记录登录设备信息
+	req.UserAgent = c.GetHeader("User-Agent")
+	req.IPAddress = c.ClientIP()
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader("X-Device-ID")
+	}
+
 	// 调用用户服务登录
 	loginResponse, err := h.userService.Login(&req)
 	if err != nil {
@@ -120,6 +145,174 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	h.respondLogin(c, loginResponse)
+}
+
+// respondLogin 需要完成两步验证时返回挑战，否则签发令牌
+func (h *AuthHandler) respondLogin(c *gin.Context, loginResponse *models.LoginResponse) {
+	if loginResponse.TwoFactorRequired || loginResponse.TwoFactorSetupRequired {
+		utils.SuccessResponse(c, http.StatusOK, "请完成两步验证", gin.H{
+			"two_factor_required":       loginResponse.TwoFactorRequired,
+			"two_factor_setup_required": loginResponse.TwoFactorSetupRequired,
+			"challenge_id":              loginResponse.ChallengeID,
+			"expires_at":                loginResponse.ExpiresAt,
+		})
+		return
+	}
+
+	h.respondLoginSuccess(c, loginResponse)
+}
+
+// VerifyTwoFactor 完成登录第二步：提交验证码或恢复码
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req models.LoginChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求格式不正确", err)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		utils.BadRequestResponse(c, "请输入验证码或恢复码", fmt.Errorf("code is required"))
+		return
+	}
+
+	loginResponse, err := h.userService.CompleteLoginChallenge(&req)
+	if err != nil {
+		switch err {
+		case services.ErrLoginChallengeInvalid:
+			utils.UnauthorizedResponse(c, "登录验证已过期，请重新登录")
+		case services.ErrTwoFactorInvalidCode:
+			utils.UnauthorizedResponse(c, "验证码错误")
+		default:
+			utils.UnauthorizedResponse(c, "两步验证失败")
+		}
+		return
+	}
+
+	h.respondLoginSuccess(c, loginResponse)
+}
+
+// TwoFactorSetup 强制两步验证的角色首次登录时获取绑定二维码
+func (h *AuthHandler) TwoFactorSetup(c *gin.Context) {
+	if h.twoFactorService == nil {
+		utils.InternalServerErrorResponse(c, "两步验证不可用", fmt.Errorf("two-factor service not configured"))
+		return
+	}
+
+	var req models.LoginChallengeSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求格式不正确", err)
+		return
+	}
+
+	enrollment, err := h.twoFactorService.ChallengeEnrollment(req.ChallengeID)
+	if err != nil {
+		utils.UnauthorizedResponse(c, "登录验证已过期，请重新登录")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "请使用验证器App扫描二维码", enrollment)
+}
+
+// SSOProviders 获取可用的校园统一身份认证方式
+func (h *AuthHandler) SSOProviders(c *gin.Context) {
+	if h.ssoService == nil {
+		utils.SuccessResponse(c, http.StatusOK, "Success", gin.H{"providers": []gin.H{}})
+		return
+	}
+
+	providers, err := h.ssoService.ListProviders(true)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取登录方式失败", err)
+		return
+	}
+
+	items := make([]gin.H, 0, len(providers))
+	for _, provider := range providers {
+		items = append(items, gin.H{
+			"slug": provider.Slug,
+			"name": provider.Name,
+			"type": provider.Type,
+		})
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Success", gin.H{"providers": items})
+}
+
+// SSOAuthorize 获取跳转到身份提供方的登录地址
+func (h *AuthHandler) SSOAuthorize(c *gin.Context) {
+	if h.ssoService == nil {
+		utils.NotFoundResponse(c, "单点登录未启用")
+		return
+	}
+
+	authorize, err := h.ssoService.BeginLogin(c.Param("provider"), c.Query("redirect_uri"), "")
+	if err != nil {
+		switch err {
+		case services.ErrSSOProviderNotFound:
+			utils.NotFoundResponse(c, "登录方式不存在或已停用")
+		case services.ErrSSORedirectNotAllowed:
+			utils.BadRequestResponse(c, "回调地址不被允许", err)
+		default:
+			utils.InternalServerErrorResponse(c, "发起单点登录失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", authorize)
+}
+
+// SSOCallback 完成单点登录：前端回调页提交state与授权码/票据
+func (h *AuthHandler) SSOCallback(c *gin.Context) {
+	if h.ssoService == nil {
+		utils.NotFoundResponse(c, "单点登录未启用")
+		return
+	}
+
+	var req models.SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求格式不正确", err)
+		return
+	}
+	if req.Code == "" && req.Ticket == "" {
+		utils.BadRequestResponse(c, "缺少授权码或票据", fmt.Errorf("code or ticket is required"))
+		return
+	}
+
+	user, err := h.ssoService.CompleteLogin(&req)
+	if err != nil {
+		switch err {
+		case services.ErrSSOStateInvalid:
+			utils.UnauthorizedResponse(c, "登录已过期，请重新发起")
+		case services.ErrSSOAccountNotLinked:
+			utils.ForbiddenResponse(c, "该校园账号尚未关联，请先使用密码登录后在个人设置中关联")
+		default:
+			utils.UnauthorizedResponse(c, "单点登录失败")
+		}
+		return
+	}
+
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader("X-Device-ID")
+	}
+	loginResponse, err := h.userService.LoginWithExternalIdentity(user, &models.SessionDeviceInfo{
+		DeviceID:   req.DeviceID,
+		DeviceName: req.DeviceName,
+		UserAgent:  c.GetHeader("User-Agent"),
+		IPAddress:  c.ClientIP(),
+	}, req.TrustedDeviceToken)
+	if err != nil {
+		if err.Error() == "user account is disabled" {
+			utils.ForbiddenResponse(c, "账号已被禁用")
+			return
+		}
+		utils.UnauthorizedResponse(c, "登录失败")
+		return
+	}
+
+	h.respondLogin(c, loginResponse)
+}
+
+// respondLoginSuccess 登录成功响应
+func (h *AuthHandler) respondLoginSuccess(c *gin.Context, loginResponse *models.LoginResponse) {
 	// 设置JWT Cookie（可选）
 	if h.config.Environment == "production" {
 		c.SetSameSite(http.SameSiteStrictMode)
@@ -168,11 +361,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 返回成功响应
-	utils.SuccessResponse(c, http.StatusOK, "登录成功", gin.H{
-		"token":      loginResponse.Token,
-		"expires_at": loginResponse.ExpiresAt,
-		"user":       userData,
-	})
+	data := gin.H{
+		"token":         loginResponse.Token,
+		"refresh_token": loginResponse.RefreshToken,
+		"session_id":    loginResponse.SessionID,
+		"expires_at":    loginResponse.ExpiresAt,
+		"user":          userData,
+	}
+	if loginResponse.TrustedDeviceToken != "" {
+		data["trusted_device_token"] = loginResponse.TrustedDeviceToken
+	}
+	if len(loginResponse.RecoveryCodes) > 0 {
+		data["recovery_codes"] = loginResponse.RecoveryCodes
+	}
+	utils.SuccessResponse(c, http.StatusOK, "登录成功", data)
 }
 
 // GetCurrentUser 获取当前用户信息
@@ -227,6 +429,14 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 
 // Logout 用户登出
 func (h *AuthHandler) Logout(c *gin.Context) {
+	// 注销当前会话，对应的访问令牌和刷新令牌立即失效
+	if h.sessionService != nil {
+		userID, _ := middleware.GetUserID(c)
+		if sessionID := c.GetString("session_id"); sessionID != "" && userID != "" {
+			h.sessionService.RevokeSession(userID, sessionID, services.SessionRevokeLogout)
+		}
+	}
+
 	// 清除cookie（如果设置了）
 	c.SetCookie(
 		"auth_token",
@@ -269,32 +479,37 @@ func getZoneTypeFromLevel(level int) string {
 	}
 }
 
-// RefreshToken 刷新JWT令牌
+// RefreshToken 使用刷新令牌换取新的令牌对
+// 刷新令牌每次使用后轮换，旧令牌再次使用将注销整个会话
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	userID, exists := middleware.GetUserID(c)
-	if !exists {
-		utils.UnauthorizedResponse(c, "用户未认证")
+	if h.sessionService == nil {
+		utils.InternalServerErrorResponse(c, "令牌刷新不可用", fmt.Errorf("session service not configured"))
 		return
 	}
 
-	user, err := h.userService.GetUserByID(userID)
-	if err != nil {
-		utils.UnauthorizedResponse(c, "用户不存在")
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请提供刷新令牌", err)
 		return
 	}
+	if req.DeviceID == "" {
+		req.DeviceID = c.GetHeader("X-Device-ID")
+	}
 
-	// 生成新的JWT令牌
-	expiresAt := time.Now().Add(24 * time.Hour)
-	token, err := auth.GenerateJWT(user.ID, user.Role, h.config.JWTSecret, expiresAt)
+	tokens, err := h.sessionService.Refresh(req.RefreshToken, req.DeviceID, c.ClientIP())
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "令牌生成失败", err)
+		switch err {
+		case services.ErrRefreshTokenReused:
+			utils.UnauthorizedResponse(c, "刷新令牌已被使用，会话已注销，请重新登录")
+		case services.ErrSessionInactive:
+			utils.UnauthorizedResponse(c, "会话已失效，请重新登录")
+		default:
+			utils.UnauthorizedResponse(c, "刷新令牌无效")
+		}
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "令牌刷新成功", gin.H{
-		"token":      token,
-		"expires_at": expiresAt,
-	})
+	utils.SuccessResponse(c, http.StatusOK, "令牌刷新成功", tokens)
 }
 
 // CheckTokenExpiry 检查令牌过期时间
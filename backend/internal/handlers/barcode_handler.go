@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -29,7 +30,7 @@ func NewBarcodeHandler(letterService *services.LetterService, opcodeService *ser
 
 // BindBarcodeRequest 绑定条码请求
 type BindBarcodeRequest struct {
-	RecipientOPCode string `json:"recipient_op_code" binding:"required,len=6"`
+	RecipientOPCode string `json:"recipient_op_code" binding:"required,min=6,max=8"` // 6位或带校验位的7位格式
 	EnvelopeID      string `json:"envelope_id,omitempty"`
 }
 
@@ -39,6 +40,7 @@ type UpdateBarcodeStatusRequest struct {
 	CurrentOPCode string               `json:"current_op_code,omitempty"`
 	ScannedBy     string               `json:"scanned_by,omitempty"`
 	Note          string               `json:"note,omitempty"`
+	QRToken       string               `json:"qr_token,omitempty"` // 二维码中的签名令牌，可直接传扫码内容
 }
 
 // CreateBarcode 创建条码 - PRD规格: POST /api/barcodes
@@ -140,8 +142,31 @@ func (h *BarcodeHandler) BindBarcode(c *gin.Context) {
 		return
 	}
 
-	// 验证OP Code
-	if err := models.ValidateOPCode(req.RecipientOPCode); err != nil {
+	// 验证OP Code，写错时返回候选编码
+	if h.opcodeService != nil {
+		code, err := h.opcodeService.RequireOPCode(req.RecipientOPCode, "")
+		var invalid *services.OPCodeInvalidError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"code":    4001,
+				"message": "OP Code无效",
+				"error":   err.Error(),
+				"data":    invalid.Resolution,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"code":    5001,
+				"message": "OP Code验证失败",
+				"error":   err.Error(),
+			})
+			return
+		}
+		req.RecipientOPCode = code
+	} else if err := models.ValidateOPCode(req.RecipientOPCode); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"code":    4001,
@@ -263,6 +288,26 @@ func (h *BarcodeHandler) UpdateBarcodeStatus(c *gin.Context) {
 		return
 	}
 
+	// 送达只能由收件人当面签收确认，生成签收凭证
+	if req.Status == models.BarcodeStatusDelivered {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"code":    4009,
+			"message": "送达须由收件人当面签收确认",
+		})
+		return
+	}
+
+	// 校验二维码签名令牌，拒绝伪造或已作废的标签
+	if err := h.letterService.VerifyScanToken(letterCode.Code, req.QRToken); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"code":    4003,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 验证状态转换是否有效
 	if !letterCode.IsValidTransition(req.Status) {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -296,10 +341,6 @@ func (h *BarcodeHandler) UpdateBarcodeStatus(c *gin.Context) {
 	letterCode.ScanCount++
 	letterCode.UpdatedAt = now
 
-	if req.Status == models.BarcodeStatusDelivered {
-		letterCode.DeliveredAt = &now
-	}
-
 	if err := h.letterService.GetDB().Save(&letterCode).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -314,8 +355,6 @@ func (h *BarcodeHandler) UpdateBarcodeStatus(c *gin.Context) {
 	scanType := models.ScanEventTypeTransit
 	if req.Status == models.BarcodeStatusInTransit && oldStatus == models.BarcodeStatusBound {
 		scanType = models.ScanEventTypePickup
-	} else if req.Status == models.BarcodeStatusDelivered {
-		scanType = models.ScanEventTypeDelivery
 	}
 
 	h.recordScanEvent(c, letterCode.ID, user.ID, scanType, req.CurrentOPCode, oldStatus, req.Status, req.Note)
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BarcodeLifecycleHandler 条码生命周期管理处理器
+type BarcodeLifecycleHandler struct {
+	lifecycleService *services.BarcodeLifecycleService
+}
+
+// NewBarcodeLifecycleHandler 创建条码生命周期管理处理器
+func NewBarcodeLifecycleHandler(lifecycleService *services.BarcodeLifecycleService) *BarcodeLifecycleHandler {
+	return &BarcodeLifecycleHandler{lifecycleService: lifecycleService}
+}
+
+// GetReport 条码滞留报告
+// @Summary 条码滞留报告
+// @Description 未绑定、绑定未收取、投递中三个阶段的条码数量、停留时长分布及即将过期数
+// @Tags 条码管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.BarcodeLifecycleReport}
+// @Router /api/v1/admin/barcodes/lifecycle [get]
+func (h *BarcodeLifecycleHandler) GetReport(c *gin.Context) {
+	report, err := h.lifecycleService.Report(time.Now())
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取条码滞留报告失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取条码滞留报告成功", report)
+}
+
+// RunExpiry 立即执行一次过期检查
+// @Summary 执行条码过期回收
+// @Tags 条码管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.BarcodeLifecycleRunResult}
+// @Router /api/v1/admin/barcodes/lifecycle/run [post]
+func (h *BarcodeLifecycleHandler) RunExpiry(c *gin.Context) {
+	result, err := h.lifecycleService.Run(time.Now())
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "条码过期回收失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "条码过期回收完成", result)
+}
+
+// Reclaim 手动回收条码
+// @Summary 手动回收条码
+// @Description 未绑定的条码置为已过期，绑定后未收取的置为已取消；信件退回草稿，信封退回库存
+// @Tags 条码管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "条码"
+// @Param request body models.ReclaimBarcodeRequest false "原因"
+// @Success 200 {object} utils.Response{data=models.LetterCode}
+// @Router /api/v1/admin/barcodes/{code}/reclaim [post]
+func (h *BarcodeLifecycleHandler) Reclaim(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.ReclaimBarcodeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+	}
+
+	code, err := h.lifecycleService.Reclaim(c.Param("code"), userID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBarcodeNotFound):
+			utils.NotFoundResponse(c, err.Error())
+		case errors.Is(err, services.ErrBarcodeNotReclaimable):
+			utils.ConflictResponse(c, "回收条码失败", err)
+		default:
+			utils.InternalServerErrorResponse(c, "回收条码失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "条码已回收", code)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
 	"strconv"
 
 	"openpenpal-backend/internal/middleware"
@@ -51,6 +53,10 @@ func (h *CourierHandler) ApplyCourier(c *gin.Context) {
 	}
 
 	courier, err := h.courierService.ApplyCourier(userID, &req)
+	if errors.Is(err, services.ErrSchoolNotVerified) {
+		resp.Error(c, http.StatusForbidden, "请先通过学校邮箱认证在校身份后再申请信使")
+		return
+	}
 	if err != nil {
 		resp.BadRequest(c, err.Error())
 		return
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataRequestHandler 数据主体请求处理器（个人数据导出与账户删除）
+type DataRequestHandler struct {
+	dataRequestService *services.DataRequestService
+}
+
+// NewDataRequestHandler 创建数据主体请求处理器
+func NewDataRequestHandler(dataRequestService *services.DataRequestService) *DataRequestHandler {
+	return &DataRequestHandler{
+		dataRequestService: dataRequestService,
+	}
+}
+
+// RequestExport 申请个人数据导出
+// @Summary 申请个人数据导出
+// @Description 收集用户在平台上的全部数据并生成可下载的ZIP包
+// @Tags DataRequests
+// @Produce json
+// @Success 201 {object} utils.Response{data=models.DataSubjectRequest}
+// @Router /api/v1/users/me/data-export [post]
+func (h *DataRequestHandler) RequestExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	request, err := h.dataRequestService.RequestExport(userID, c.ClientIP())
+	if err != nil {
+		if err.Error() == "an export request is already in progress" {
+			utils.ConflictResponse(c, "已有导出请求正在处理", err)
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Data export failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Data export ready", request)
+}
+
+// DownloadExport 下载个人数据导出包
+// @Summary 下载导出包
+// @Tags DataRequests
+// @Produce application/zip
+// @Param id path string true "请求ID"
+// @Router /api/v1/users/me/data-requests/{id}/download [get]
+func (h *DataRequestHandler) DownloadExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	request, file, err := h.dataRequestService.OpenExportArchive(userID, c.Param("id"))
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=openpenpal-data-%s.zip", request.ID))
+	c.Header("X-Content-SHA256", request.ArchiveHash)
+	c.DataFromReader(http.StatusOK, request.ArchiveSize, "application/zip", file, nil)
+}
+
+// RequestErasure 申请删除账户
+// @Summary 申请删除账户
+// @Description 冷静期结束后匿名化他人已收到的信件作者信息，删除其余个人数据与文件
+// @Tags DataRequests
+// @Accept json
+// @Produce json
+// @Param request body models.CreateErasureRequest true "删除请求"
+// @Success 201 {object} utils.Response{data=models.DataSubjectRequest}
+// @Router /api/v1/users/me/data-erasure [post]
+func (h *DataRequestHandler) RequestErasure(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.CreateErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	request, err := h.dataRequestService.RequestErasure(userID, &req, c.ClientIP())
+	if err != nil {
+		switch err.Error() {
+		case "password is incorrect":
+			utils.ForbiddenResponse(c, "密码错误")
+		case "re-authentication failed":
+			utils.ForbiddenResponse(c, "身份验证失败，请输入两步验证码或重新通过校园统一身份认证")
+		case "an erasure request is already scheduled":
+			utils.ConflictResponse(c, "账户删除申请已在处理中", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Erasure request failed", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Account erasure scheduled", request)
+}
+
+// CancelRequest 撤销冷静期内的删除申请
+// @Summary 撤销账户删除申请
+// @Tags DataRequests
+// @Param id path string true "请求ID"
+// @Router /api/v1/users/me/data-requests/{id}/cancel [post]
+func (h *DataRequestHandler) CancelRequest(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	if err := h.dataRequestService.CancelErasure(userID, c.Param("id")); err != nil {
+		utils.BadRequestResponse(c, "Cancel failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Erasure request cancelled", nil)
+}
+
+// GetMyRequests 获取当前用户的数据请求
+// @Summary 获取我的数据请求
+// @Tags DataRequests
+// @Produce json
+// @Router /api/v1/users/me/data-requests [get]
+func (h *DataRequestHandler) GetMyRequests(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	requests, err := h.dataRequestService.GetUserRequests(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get data requests", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", requests)
+}
+
+// AdminListRequests 管理员查询数据请求
+// @Summary 查询数据主体请求
+// @Tags DataRequests
+// @Produce json
+// @Router /api/v1/admin/data-requests [get]
+func (h *DataRequestHandler) AdminListRequests(c *gin.Context) {
+	var query models.DataRequestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.BadRequestResponse(c, "Invalid query parameters", err)
+		return
+	}
+
+	requests, total, err := h.dataRequestService.ListRequests(&query)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list data requests", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", gin.H{
+		"requests": requests,
+		"total":    total,
+		"page":     query.Page,
+		"limit":    query.Limit,
+	})
+}
+
+// AdminGetAuditLogs 管理员查看请求审计记录
+// @Summary 查看数据请求审计记录
+// @Tags DataRequests
+// @Param id path string true "请求ID"
+// @Router /api/v1/admin/data-requests/{id}/audit [get]
+func (h *DataRequestHandler) AdminGetAuditLogs(c *gin.Context) {
+	logs, err := h.dataRequestService.GetAuditLogs(c.Param("id"))
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get audit logs", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", logs)
+}
+
+// AdminProcessErasures 手动触发到期删除请求的执行
+// @Summary 执行到期的账户删除请求
+// @Tags DataRequests
+// @Router /api/v1/admin/data-requests/process-erasures [post]
+func (h *DataRequestHandler) AdminProcessErasures(c *gin.Context) {
+	processed, err := h.dataRequestService.ProcessDueErasures()
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to process erasures", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Erasures processed", gin.H{
+		"processed": processed,
+	})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnvelopeContestHandler 信封设计评选处理器
+type EnvelopeContestHandler struct {
+	contestService *services.EnvelopeContestService
+}
+
+// NewEnvelopeContestHandler 创建信封设计评选处理器
+func NewEnvelopeContestHandler(contestService *services.EnvelopeContestService) *EnvelopeContestHandler {
+	return &EnvelopeContestHandler{contestService: contestService}
+}
+
+// ListContests 评选列表
+// @Summary 信封设计评选列表
+// @Tags 信封评选
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/envelopes/contests [get]
+func (h *EnvelopeContestHandler) ListContests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	contests, total, err := h.contestService.ListContests(page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取评选列表失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取评选列表成功", gin.H{
+		"items": contests,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetContest 评选详情
+// @Summary 评选详情与参赛设计
+// @Description 投票结束前不公开得分；同时返回当前用户是否有资格参与和剩余票数
+// @Tags 信封评选
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "评选ID"
+// @Success 200 {object} utils.Response{data=models.EnvelopeContestDetail}
+// @Router /api/v1/envelopes/contests/{id} [get]
+func (h *EnvelopeContestHandler) GetContest(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	detail, err := h.contestService.GetContest(c.Param("id"), userID, canManageAllPrints(c))
+	if err != nil {
+		h.handleError(c, "获取评选详情失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取评选详情成功", detail)
+}
+
+// SubmitEntry 提交参赛设计
+// @Summary 提交参赛设计
+// @Tags 信封评选
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "评选ID"
+// @Param request body models.SubmitContestEntryRequest true "设计"
+// @Success 201 {object} utils.Response{data=models.EnvelopeDesign}
+// @Router /api/v1/envelopes/contests/{id}/entries [post]
+func (h *EnvelopeContestHandler) SubmitEntry(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.SubmitContestEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	design, err := h.contestService.SubmitEntry(c.Param("id"), userID, &req)
+	if err != nil {
+		h.handleError(c, "提交设计失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "设计已提交", design)
+}
+
+// Vote 为参赛设计投票
+// @Summary 为参赛设计投票
+// @Tags 信封评选
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "评选ID"
+// @Param design_id path string true "设计ID"
+// @Success 200 {object} utils.Response{data=models.ContestVoteResult}
+// @Router /api/v1/envelopes/contests/{id}/entries/{design_id}/vote [post]
+func (h *EnvelopeContestHandler) Vote(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	result, err := h.contestService.Vote(c.Param("id"), c.Param("design_id"), userID, &models.ContestVoteMeta{
+		IPAddress: c.ClientIP(),
+		DeviceID:  c.GetHeader("X-Device-ID"),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		h.handleError(c, "投票失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "投票成功", result)
+}
+
+// CreateContest 创建评选
+// @Summary 创建信封设计评选（管理员）
+// @Tags 信封评选
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateEnvelopeContestRequest true "评选设置"
+// @Success 201 {object} utils.Response{data=models.EnvelopeContest}
+// @Router /api/v1/admin/envelope-contests [post]
+func (h *EnvelopeContestHandler) CreateContest(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.CreateEnvelopeContestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	contest, err := h.contestService.CreateContest(userID, &req)
+	if err != nil {
+		h.handleError(c, "创建评选失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "评选已创建", contest)
+}
+
+// GetAnomalies 刷票检测报告
+// @Summary 评选刷票检测报告（管理员）
+// @Tags 信封评选
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "评选ID"
+// @Success 200 {object} utils.Response{data=models.ContestAnomalyReport}
+// @Router /api/v1/admin/envelope-contests/{id}/anomalies [get]
+func (h *EnvelopeContestHandler) GetAnomalies(c *gin.Context) {
+	report, err := h.contestService.AnomalyReport(c.Param("id"))
+	if err != nil {
+		h.handleError(c, "获取刷票检测报告失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取刷票检测报告成功", report)
+}
+
+// ReviewVote 复核投票
+// @Summary 复核被标记的投票（管理员）
+// @Tags 信封评选
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param vote_id path string true "投票ID"
+// @Param request body models.ReviewContestVoteRequest true "计入或作废"
+// @Success 200 {object} utils.Response{data=models.EnvelopeContestVote}
+// @Router /api/v1/admin/envelope-contests/votes/{vote_id}/review [post]
+func (h *EnvelopeContestHandler) ReviewVote(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.ReviewContestVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	vote, err := h.contestService.ReviewVote(c.Param("vote_id"), userID, req.Action)
+	if err != nil {
+		h.handleError(c, "复核投票失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "投票已复核", vote)
+}
+
+// Finalize 评出获胜设计
+// @Summary 评出获胜设计（管理员）
+// @Description 投票结束后按加权得分排名，获胜设计上架为可订购的信封；未手动执行时由定时任务自动评出
+// @Tags 信封评选
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "评选ID"
+// @Success 200 {object} utils.Response{data=models.ContestFinalizeResult}
+// @Router /api/v1/admin/envelope-contests/{id}/finalize [post]
+func (h *EnvelopeContestHandler) Finalize(c *gin.Context) {
+	result, err := h.contestService.Finalize(c.Param("id"))
+	if err != nil {
+		h.handleError(c, "评选结算失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "已评出获胜设计", result)
+}
+
+func (h *EnvelopeContestHandler) handleError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrContestNotFound), errors.Is(err, services.ErrContestEntryNotFound),
+		errors.Is(err, services.ErrContestVoteNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrContestAccountTooNew), errors.Is(err, services.ErrContestNotVerified),
+		errors.Is(err, services.ErrContestSchoolMismatch), errors.Is(err, services.ErrContestUserBlocked),
+		errors.Is(err, services.ErrContestOwnEntry):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrContestNotOpen), errors.Is(err, services.ErrContestNotEnded),
+		errors.Is(err, services.ErrContestFinalized), errors.Is(err, services.ErrContestAlreadyVoted),
+		errors.Is(err, services.ErrContestVoteLimit), errors.Is(err, services.ErrContestEntryLimit):
+		utils.ConflictResponse(c, message, err)
+	case errors.Is(err, services.ErrContestInvalidSchedule):
+		utils.BadRequestResponse(c, message, err)
+	default:
+		utils.InternalServerErrorResponse(c, message, err)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnvelopePrintHandler 信封标签打印处理器
+type EnvelopePrintHandler struct {
+	printService *services.EnvelopePrintService
+}
+
+// NewEnvelopePrintHandler 创建信封标签打印处理器
+func NewEnvelopePrintHandler(printService *services.EnvelopePrintService) *EnvelopePrintHandler {
+	return &EnvelopePrintHandler{printService: printService}
+}
+
+// canManageAllPrints 管理员可打印和查看全部信封的标签
+func canManageAllPrints(c *gin.Context) bool {
+	role, _ := middleware.GetUserRole(c)
+	return role == "admin" || role == string(models.RolePlatformAdmin) || role == string(models.RoleSuperAdmin)
+}
+
+// GetLayouts 获取标签排版
+// @Summary 标签排版列表
+// @Tags 信封打印
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/envelopes/print-layouts [get]
+func (h *EnvelopePrintHandler) GetLayouts(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "获取标签排版成功", h.printService.GetLayouts())
+}
+
+// CreatePrintBatch 创建打印批次
+// @Summary 生成信封标签 PDF
+// @Description 按订单或信封ID生成可打印的条码标签，使用信封设计图作为背景
+// @Tags 信封打印
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreatePrintBatchRequest true "打印参数"
+// @Success 201 {object} utils.Response{data=models.EnvelopePrintBatch}
+// @Router /api/v1/envelopes/print-batches [post]
+func (h *EnvelopePrintHandler) CreatePrintBatch(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.CreatePrintBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	batch, err := h.printService.CreatePrintBatch(userID, canManageAllPrints(c), &req)
+	if err != nil {
+		respondPrintError(c, "生成标签失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "标签已生成", batch)
+}
+
+// ReprintBatch 重印打印批次
+// @Summary 重印信封标签
+// @Description 重印整批或部分信封，需填写原因，新批次关联原批次
+// @Tags 信封打印
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "批次ID"
+// @Param request body models.ReprintBatchRequest true "重印参数"
+// @Router /api/v1/envelopes/print-batches/{id}/reprint [post]
+func (h *EnvelopePrintHandler) ReprintBatch(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.ReprintBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	batch, err := h.printService.ReprintBatch(c.Param("id"), userID, canManageAllPrints(c), &req)
+	if err != nil {
+		respondPrintError(c, "重印标签失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "标签已重印", batch)
+}
+
+// ListPrintBatches 打印批次列表
+// @Summary 打印批次列表
+// @Tags 信封打印
+// @Produce json
+// @Security BearerAuth
+// @Param order_id query string false "订单ID"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Router /api/v1/envelopes/print-batches [get]
+func (h *EnvelopePrintHandler) ListPrintBatches(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	batches, total, err := h.printService.ListPrintBatches(userID, canManageAllPrints(c), c.Query("order_id"), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取打印批次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取打印批次成功", gin.H{
+		"batches": batches,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetPrintBatch 打印批次详情
+// @Summary 打印批次详情
+// @Tags 信封打印
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "批次ID"
+// @Router /api/v1/envelopes/print-batches/{id} [get]
+func (h *EnvelopePrintHandler) GetPrintBatch(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	batch, err := h.printService.GetPrintBatch(c.Param("id"), userID, canManageAllPrints(c))
+	if err != nil {
+		respondPrintError(c, "获取打印批次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取打印批次成功", batch)
+}
+
+// DownloadPrintBatch 下载批次 PDF
+// @Summary 下载标签 PDF
+// @Tags 信封打印
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path string true "批次ID"
+// @Router /api/v1/envelopes/print-batches/{id}/pdf [get]
+func (h *EnvelopePrintHandler) DownloadPrintBatch(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	batch, err := h.printService.GetPrintBatch(c.Param("id"), userID, canManageAllPrints(c))
+	if err != nil {
+		respondPrintError(c, "获取打印批次失败", err)
+		return
+	}
+
+	file, err := h.printService.OpenBatchFile(batch)
+	if err != nil {
+		utils.NotFoundResponse(c, "标签文件不存在，请重印")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "读取标签文件失败", err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="labels-%s.pdf"`, batch.ID))
+	c.DataFromReader(http.StatusOK, info.Size(), "application/pdf", file, nil)
+}
+
+// GetEnvelopePrintHistory 信封打印记录
+// @Summary 信封打印记录
+// @Tags 信封打印
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信封ID"
+// @Router /api/v1/admin/envelopes/{id}/print-history [get]
+func (h *EnvelopePrintHandler) GetEnvelopePrintHistory(c *gin.Context) {
+	batches, err := h.printService.GetEnvelopePrintHistory(c.Param("id"))
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取打印记录失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取打印记录成功", batches)
+}
+
+// respondPrintError 按错误类型返回状态码
+func respondPrintError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPrintForbidden):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrPrintBatchNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrPrintLayoutUnknown),
+		errors.Is(err, services.ErrPrintNoEnvelopes),
+		errors.Is(err, services.ErrPrintMixedDesigns),
+		errors.Is(err, services.ErrPrintEnvelopeNotInBatch):
+		utils.BadRequestResponse(c, message, err)
+	default:
+		utils.InternalServerErrorResponse(c, message, err)
+	}
+}
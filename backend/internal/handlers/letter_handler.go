@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"openpenpal-backend/internal/middleware"
 	"openpenpal-backend/internal/models"
@@ -43,6 +44,19 @@ func (h *LetterHandler) CreateDraft(c *gin.Context) {
 
 	letter, err := h.letterService.CreateDraft(userID, &req)
 	if err != nil {
+		// OP Code写错时返回“您是不是要找”的候选
+		var invalid *services.OPCodeInvalidError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, response.StandardResponse{
+				Success:   false,
+				Data:      invalid.Resolution,
+				Message:   err.Error(),
+				Error:     invalid.Resolution.Reason,
+				Code:      http.StatusBadRequest,
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
 		resp.InternalServerError(c, err.Error())
 		return
 	}
@@ -124,6 +138,14 @@ func (h *LetterHandler) UpdateStatus(c *gin.Context) {
 	}
 
 	if err := h.letterService.UpdateStatus(code, &req, userID); err != nil {
+		if isQRTokenError(err) {
+			resp.Error(c, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrDeliveryReceiptRequired) {
+			resp.Error(c, http.StatusConflict, err.Error())
+			return
+		}
 		resp.InternalServerError(c, err.Error())
 		return
 	}
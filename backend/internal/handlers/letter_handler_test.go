@@ -354,13 +354,11 @@ func (suite *LetterHandlerTestSuite) TestGetLetterByCode_NotFound() {
 func (suite *LetterHandlerTestSuite) TestMarkAsRead_Success() {
 	// 创建测试信件并生成代码
 	letter := suite.createTestLetter()
-	letterCode, err := suite.letterService.GenerateCode(letter.ID)
+	_, err := suite.letterService.GenerateCode(letter.ID)
 	suite.NoError(err)
 
-	// 首先更新状态到delivered
-	err = suite.letterService.UpdateStatus(letterCode.Code, &models.UpdateLetterStatusRequest{
-		Status: models.StatusDelivered,
-	}, "test-courier")
+	// 首先更新状态到delivered（送达须经当面签收确认，这里直接置为送达）
+	err = suite.db.Model(&models.Letter{}).Where("id = ?", letter.ID).Update("status", models.StatusDelivered).Error
 	suite.NoError(err)
 
 	// 执行标记已读请求
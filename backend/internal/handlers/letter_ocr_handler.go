@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LetterOCRHandler 手写信件识别处理器
+type LetterOCRHandler struct {
+	ocrService *services.LetterOCRService
+}
+
+// NewLetterOCRHandler 创建手写信件识别处理器
+func NewLetterOCRHandler(ocrService *services.LetterOCRService) *LetterOCRHandler {
+	return &LetterOCRHandler{ocrService: ocrService}
+}
+
+// GetPreference 获取手写信件识别设置
+// @Summary 获取手写信件识别设置
+// @Tags Letters
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.OCRPreference}
+// @Router /api/v1/letters/ocr/preference [get]
+func (h *LetterOCRHandler) GetPreference(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	preference, err := h.ocrService.GetPreference(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取识别设置失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取识别设置成功", preference)
+}
+
+// UpdatePreference 开启或关闭手写信件识别
+// @Summary 设置手写信件识别
+// @Description 开启后，本人信件上传的照片会自动识别文字，识别结果须本人确认后才写入
+// @Tags Letters
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UpdateOCRPreferenceRequest true "是否开启"
+// @Success 200 {object} utils.Response{data=models.OCRPreference}
+// @Router /api/v1/letters/ocr/preference [put]
+func (h *LetterOCRHandler) UpdatePreference(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.UpdateOCRPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	preference, err := h.ocrService.SetPreference(userID, *req.Enabled)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "保存识别设置失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "识别设置已保存", preference)
+}
+
+// AddPhoto 为信件添加照片
+// @Summary 添加信件照片
+// @Description 图片先通过 /storage/upload 上传；寄信人开启识别时自动排队识别
+// @Tags Letters
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Param request body models.AddLetterPhotoRequest true "图片文件"
+// @Success 201 {object} utils.Response{data=models.AddLetterPhotoResponse}
+// @Router /api/v1/letters/{id}/photos [post]
+func (h *LetterOCRHandler) AddPhoto(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.AddLetterPhotoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	response, err := h.ocrService.AddPhoto(c.Param("id"), userID, &req)
+	if err != nil {
+		h.handleError(c, "添加照片失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "照片已添加", response)
+}
+
+// RequestOCR 对信件照片发起识别
+// @Summary 识别信件照片
+// @Tags Letters
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Param photo_id path string true "照片ID"
+// @Success 202 {object} utils.Response{data=models.LetterOCRJob}
+// @Router /api/v1/letters/{id}/photos/{photo_id}/ocr [post]
+func (h *LetterOCRHandler) RequestOCR(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	job, err := h.ocrService.RequestOCR(c.Param("id"), c.Param("photo_id"), userID)
+	if err != nil {
+		h.handleError(c, "发起识别失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "已加入识别队列", job)
+}
+
+// ListJobs 识别任务列表
+// @Summary 本人信件的识别任务
+// @Tags Letters
+// @Produce json
+// @Security BearerAuth
+// @Param letter_id query string false "信件ID"
+// @Param status query string false "pending/processing/recognized/failed/approved/rejected"
+// @Router /api/v1/letters/ocr/jobs [get]
+func (h *LetterOCRHandler) ListJobs(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	jobs, total, err := h.ocrService.ListJobs(userID, c.Query("letter_id"), c.Query("status"), page, limit)
+	if err != nil {
+		h.handleError(c, "获取识别任务失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取识别任务成功", gin.H{
+		"items": jobs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetJob 识别任务详情
+// @Summary 识别任务详情
+// @Description 包含识别全文和每段文字的位置与置信度
+// @Tags Letters
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path string true "识别任务ID"
+// @Success 200 {object} utils.Response{data=models.LetterOCRJob}
+// @Router /api/v1/letters/ocr/jobs/{job_id} [get]
+func (h *LetterOCRHandler) GetJob(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	job, err := h.ocrService.GetJob(c.Param("job_id"), userID)
+	if err != nil {
+		h.handleError(c, "获取识别任务失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取识别任务成功", job)
+}
+
+// ReviewJob 确认识别结果
+// @Summary 确认识别结果
+// @Description 确认后把（可修改的）识别文字写入草稿信件正文或博物馆藏品，也可以放弃；已寄出的信件不能改写正文
+// @Tags Letters
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path string true "识别任务ID"
+// @Param request body models.ReviewOCRJobRequest true "确认结果"
+// @Success 200 {object} utils.Response{data=models.LetterOCRJob}
+// @Router /api/v1/letters/ocr/jobs/{job_id}/review [post]
+func (h *LetterOCRHandler) ReviewJob(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.ReviewOCRJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	job, err := h.ocrService.Review(c.Param("job_id"), userID, &req)
+	if err != nil {
+		h.handleError(c, "确认识别结果失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "识别结果已处理", job)
+}
+
+// SearchHandwritten 搜索博物馆手写信件
+// @Summary 搜索博物馆手写信件
+// @Tags Museum
+// @Produce json
+// @Param q query string false "关键词"
+// @Router /api/v1/museum/handwritten [get]
+func (h *LetterOCRHandler) SearchHandwritten(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	items, total, err := h.ocrService.SearchHandwritten(c.Query("q"), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "搜索手写信件失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "搜索手写信件成功", gin.H{
+		"items": items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *LetterOCRHandler) handleError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrLetterOCRForbidden):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrLetterOCRLetterNotFound), errors.Is(err, services.ErrLetterOCRPhotoNotFound),
+		errors.Is(err, services.ErrLetterOCRJobNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrLetterOCRJobActive), errors.Is(err, services.ErrLetterOCRNotReviewable),
+		errors.Is(err, services.ErrLetterOCRLetterSent):
+		utils.ConflictResponse(c, message, err)
+	case errors.Is(err, services.ErrLetterOCRPhotoInvalid), errors.Is(err, services.ErrLetterOCREmptyText),
+		errors.Is(err, services.ErrLetterOCRNotInMuseum), errors.Is(err, services.ErrLetterOCRMuseumEdited):
+		utils.BadRequestResponse(c, message, err)
+	case errors.Is(err, services.ErrLetterOCRNotConfigured):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, message, err)
+	default:
+		utils.InternalServerErrorResponse(c, message, err)
+	}
+}
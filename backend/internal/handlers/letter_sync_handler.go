@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LetterSyncHandler 信件状态同步处理器
+type LetterSyncHandler struct {
+	syncService *services.LetterSyncService
+}
+
+// NewLetterSyncHandler 创建信件状态同步处理器
+func NewLetterSyncHandler(syncService *services.LetterSyncService) *LetterSyncHandler {
+	return &LetterSyncHandler{syncService: syncService}
+}
+
+// GetStatus 获取同步概况
+// @Summary 信件状态同步概况
+// @Description 待投递出站事件、已处理入站事件、未处理冲突数和最近对账时间
+// @Tags 信件同步
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.SyncStatusResponse}
+// @Router /api/v1/admin/sync/status [get]
+func (h *LetterSyncHandler) GetStatus(c *gin.Context) {
+	status, err := h.syncService.GetStatus()
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取同步概况失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取同步概况成功", status)
+}
+
+// GetDivergences 获取对账差异
+// @Summary 信件状态对账差异
+// @Tags 信件同步
+// @Produce json
+// @Security BearerAuth
+// @Param resolution query string false "处理方式 repaired_local/republished/conflict"
+// @Param page query int false "页码"
+// @Param limit query int false "每页数量"
+// @Router /api/v1/admin/sync/divergences [get]
+func (h *LetterSyncHandler) GetDivergences(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	divergences, total, err := h.syncService.GetDivergences(c.Query("resolution"), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取对账差异失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取对账差异成功", gin.H{
+		"divergences": divergences,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// Reconcile 立即发布对账快照
+// @Summary 立即对账
+// @Tags 信件同步
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/admin/sync/reconcile [post]
+func (h *LetterSyncHandler) Reconcile(c *gin.Context) {
+	published, err := h.syncService.Reconcile()
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "对账失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "对账快照已发布", gin.H{"published": published})
+}
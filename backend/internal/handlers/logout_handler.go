@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"openpenpal-backend/internal/services"
 	"openpenpal-backend/pkg/cache"
 
 	"github.com/gin-gonic/gin"
@@ -11,13 +12,15 @@ import (
 
 // LogoutHandler 处理用户注销
 type LogoutHandler struct {
-	blacklist *cache.TokenBlacklist
+	blacklist      *cache.TokenBlacklist
+	sessionService *services.SessionService
 }
 
 // NewLogoutHandler 创建注销处理器
-func NewLogoutHandler() *LogoutHandler {
+func NewLogoutHandler(sessionService *services.SessionService) *LogoutHandler {
 	return &LogoutHandler{
-		blacklist: cache.GetTokenBlacklist(),
+		blacklist:      cache.GetTokenBlacklist(),
+		sessionService: sessionService,
 	}
 }
 
@@ -50,6 +53,11 @@ func (h *LogoutHandler) Logout(c *gin.Context) {
 	blacklistExpiry := time.Now().Add(24 * time.Hour) // 默认24小时
 	h.blacklist.Add(jti.(string), blacklistExpiry)
 
+	// 注销当前会话，刷新令牌一并失效
+	if sessionID := c.GetString("session_id"); sessionID != "" && userID != nil {
+		h.sessionService.RevokeSession(userID.(string), sessionID, services.SessionRevokeLogout)
+	}
+
 	// 记录注销日志
 	if userID != nil {
 		c.Set("audit_action", "logout")
@@ -68,7 +76,7 @@ func (h *LogoutHandler) Logout(c *gin.Context) {
 
 // LogoutAll 注销用户的所有Token
 // @Summary 注销所有会话
-// @Description 注销该用户的所有活跃会话（需要实现用户Token映射）
+// @Description 注销该用户在所有设备上的会话，已签发的访问令牌和刷新令牌全部失效
 // @Tags 认证
 // @Accept json
 // @Produce json
@@ -86,8 +94,17 @@ func (h *LogoutHandler) LogoutAll(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现用户所有Token的映射和批量注销
-	// 当前实现仅注销当前Token
+	// 注销全部会话，中间件据此拒绝这些会话签发的访问令牌
+	revoked, err := h.sessionService.RevokeAllSessions(userID.(string), services.SessionRevokeLogoutAll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "注销会话失败",
+		})
+		return
+	}
+
+	// 未绑定会话的旧Token仅能按JTI注销
 	jti, _ := c.Get("token_jti")
 	if jti != nil && jti != "" {
 		blacklistExpiry := time.Now().Add(24 * time.Hour)
@@ -102,8 +119,9 @@ func (h *LogoutHandler) LogoutAll(c *gin.Context) {
 		"success": true,
 		"message": "已注销所有会话",
 		"data": gin.H{
-			"user_id":     userID,
-			"logout_time": time.Now().Format(time.RFC3339),
+			"user_id":          userID,
+			"revoked_sessions": revoked,
+			"logout_time":      time.Now().Format(time.RFC3339),
 		},
 	})
 }
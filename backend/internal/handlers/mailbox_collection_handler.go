@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MailboxCollectionHandler 公共信箱收取处理器
+type MailboxCollectionHandler struct {
+	collectionService *services.MailboxCollectionService
+}
+
+// NewMailboxCollectionHandler 创建公共信箱收取处理器
+func NewMailboxCollectionHandler(collectionService *services.MailboxCollectionService) *MailboxCollectionHandler {
+	return &MailboxCollectionHandler{collectionService: collectionService}
+}
+
+// SetSchedule 设置信箱收取计划
+// @Summary 设置信箱收取计划
+// @Description 二级及以上信使或管理员设置每天的收取时间、星期和容量，未开始的轮次按新计划重新生成
+// @Tags Courier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "信箱OP Code"
+// @Param request body models.MailboxScheduleRequest true "收取计划"
+// @Success 200 {object} utils.Response{data=models.MailboxSchedule}
+// @Router /api/v1/courier/mailboxes/{code}/schedule [put]
+func (h *MailboxCollectionHandler) SetSchedule(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.MailboxScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	schedule, err := h.collectionService.SetSchedule(userID, c.Param("code"), &req)
+	if err != nil {
+		h.handleError(c, "设置收取计划失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "收取计划已保存", schedule)
+}
+
+// ListSchedules 收取计划列表
+// @Summary 信箱收取计划列表
+// @Tags Courier
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/courier/mailboxes/schedules [get]
+func (h *MailboxCollectionHandler) ListSchedules(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	schedules, err := h.collectionService.ListSchedules(userID)
+	if err != nil {
+		h.handleError(c, "获取收取计划失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取收取计划成功", schedules)
+}
+
+// GetFillStats 信箱装满程度统计
+// @Summary 信箱装满程度与收取频率建议
+// @Tags Courier
+// @Produce json
+// @Security BearerAuth
+// @Param prefix query string false "OP Code前缀，如 PK 或 PK5F"
+// @Param days query int false "统计天数，默认30"
+// @Success 200 {object} utils.Response{data=[]models.MailboxFillStats}
+// @Router /api/v1/courier/mailboxes/stats [get]
+func (h *MailboxCollectionHandler) GetFillStats(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+
+	stats, err := h.collectionService.FillStats(userID, c.Query("prefix"), days)
+	if err != nil {
+		h.handleError(c, "获取信箱统计失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取信箱统计成功", stats)
+}
+
+// StartRun 扫描信箱二维码开箱
+// @Summary 开箱收取
+// @Description 接手当前时段的计划收取轮次，没有计划时创建临时轮次
+// @Tags Courier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.StartCollectionRunRequest true "信箱"
+// @Success 200 {object} utils.Response{data=models.MailboxCollectionRun}
+// @Router /api/v1/courier/collections/start [post]
+func (h *MailboxCollectionHandler) StartRun(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.StartCollectionRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	run, err := h.collectionService.StartRun(userID, &req)
+	if err != nil {
+		h.handleError(c, "开箱失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "已开箱，请逐封扫描信件", run)
+}
+
+// ScanLetters 批量登记开箱扫到的信件
+// @Summary 登记开箱扫到的信件
+// @Description 每封信单独处理，待收取的信件自动转为已收取
+// @Tags Courier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "收取轮次ID"
+// @Param request body models.CollectionScanRequest true "信件条码"
+// @Success 200 {object} utils.Response{data=models.CollectionScanResponse}
+// @Router /api/v1/courier/collections/{id}/scan [post]
+func (h *MailboxCollectionHandler) ScanLetters(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.CollectionScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	response, err := h.collectionService.ScanLetters(c.Param("id"), userID, &req)
+	if err != nil {
+		h.handleError(c, "登记信件失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "扫码完成", response)
+}
+
+// CompleteRun 结束收取
+// @Summary 结束收取
+// @Tags Courier
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "收取轮次ID"
+// @Param request body models.CompleteCollectionRunRequest false "备注"
+// @Success 200 {object} utils.Response{data=models.MailboxCollectionRun}
+// @Router /api/v1/courier/collections/{id}/complete [post]
+func (h *MailboxCollectionHandler) CompleteRun(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.CompleteCollectionRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+	}
+
+	run, err := h.collectionService.CompleteRun(c.Param("id"), userID, &req)
+	if err != nil {
+		h.handleError(c, "结束收取失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "本轮收取已完成", run)
+}
+
+// GetRun 收取轮次详情
+// @Summary 收取轮次详情
+// @Tags Courier
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "收取轮次ID"
+// @Router /api/v1/courier/collections/{id} [get]
+func (h *MailboxCollectionHandler) GetRun(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	run, err := h.collectionService.GetRun(c.Param("id"), userID)
+	if err != nil {
+		h.handleError(c, "获取收取轮次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取收取轮次成功", run)
+}
+
+// ListRuns 收取轮次列表
+// @Summary 收取轮次列表
+// @Tags Courier
+// @Produce json
+// @Security BearerAuth
+// @Param box_op_code query string false "信箱OP Code"
+// @Param status query string false "scheduled/in_progress/completed/missed"
+// @Router /api/v1/courier/collections [get]
+func (h *MailboxCollectionHandler) ListRuns(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	runs, total, err := h.collectionService.ListRuns(userID, c.Query("box_op_code"), c.Query("status"), page, limit)
+	if err != nil {
+		h.handleError(c, "获取收取轮次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取收取轮次成功", gin.H{
+		"items": runs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *MailboxCollectionHandler) handleError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrMailboxForbidden):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrMailboxNotFound), errors.Is(err, services.ErrCollectionRunNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrCollectionRunClosed), errors.Is(err, services.ErrCollectionRunBusy):
+		utils.ConflictResponse(c, message, err)
+	case errors.Is(err, services.ErrMailboxScheduleInvalid):
+		utils.BadRequestResponse(c, message, err)
+	default:
+		utils.InternalServerErrorResponse(c, message, err)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OPCodeForwardHandler OP Code转寄处理器
+type OPCodeForwardHandler struct {
+	forwardService *services.OPCodeForwardService
+}
+
+// NewOPCodeForwardHandler 创建OP Code转寄处理器
+func NewOPCodeForwardHandler(forwardService *services.OPCodeForwardService) *OPCodeForwardHandler {
+	return &OPCodeForwardHandler{forwardService: forwardService}
+}
+
+// CreateForward 登记转寄
+// @Summary 登记OP Code转寄
+// @Description 搬离宿舍后把寄往旧编码的信件转寄到新编码，已在途的信件同时改投
+// @Tags OP Code
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateOPCodeForwardRequest true "转寄规则"
+// @Success 201 {object} utils.Response{data=models.OPCodeForward}
+// @Router /api/v1/opcode/forwards [post]
+func (h *OPCodeForwardHandler) CreateForward(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req models.CreateOPCodeForwardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数错误", err)
+		return
+	}
+
+	forward, err := h.forwardService.CreateForward(userID, &req)
+	if err != nil {
+		var invalid *services.OPCodeInvalidError
+		switch {
+		case errors.Is(err, services.ErrForwardNotHolder):
+			utils.ForbiddenResponse(c, err.Error())
+		case errors.As(err, &invalid),
+			errors.Is(err, services.ErrForwardBadCode),
+			errors.Is(err, services.ErrForwardSameCode),
+			errors.Is(err, services.ErrForwardCycle),
+			errors.Is(err, services.ErrForwardExpiry):
+			utils.BadRequestResponse(c, "登记转寄失败", err)
+		default:
+			utils.InternalServerErrorResponse(c, "登记转寄失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "转寄已登记", forward)
+}
+
+// ListForwards 我的转寄规则
+// @Summary 我的转寄规则
+// @Tags OP Code
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/opcode/forwards [get]
+func (h *OPCodeForwardHandler) ListForwards(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	forwards, err := h.forwardService.ListForwards(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取转寄规则失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取转寄规则成功", forwards)
+}
+
+// CancelForward 取消转寄
+// @Summary 取消转寄
+// @Tags OP Code
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "转寄规则ID"
+// @Router /api/v1/opcode/forwards/{id} [delete]
+func (h *OPCodeForwardHandler) CancelForward(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.forwardService.CancelForward(c.Param("id"), userID); err != nil {
+		if errors.Is(err, services.ErrForwardNotFound) {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		utils.InternalServerErrorResponse(c, "取消转寄失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "转寄已取消", nil)
+}
+
+// GetLetterReroutes 信件转寄记录
+// @Summary 信件转寄记录
+// @Tags 管理员
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Router /api/v1/admin/letters/{id}/reroutes [get]
+func (h *OPCodeForwardHandler) GetLetterReroutes(c *gin.Context) {
+	reroutes, err := h.forwardService.GetLetterReroutes(c.Param("id"))
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取转寄记录失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取转寄记录成功", reroutes)
+}
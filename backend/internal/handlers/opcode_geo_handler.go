@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/geo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导入的 GeoJSON 文件上限
+const maxGeoJSONImportSize = 10 << 20
+
+// ResolveLocation 由GPS坐标解析所在片区
+func (h *OPCodeHandler) ResolveLocation(c *gin.Context) {
+	var req struct {
+		Latitude  *float64 `form:"lat" binding:"required,min=-90,max=90"`
+		Longitude *float64 `form:"lng" binding:"required,min=-180,max=180"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	location, err := h.opcodeService.ResolveLocation(*req.Latitude, *req.Longitude)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"code":    5001,
+			"message": "解析位置失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if location == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"code":    4004,
+			"message": "该位置不在已登记的片区内",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"code":    200,
+		"message": "解析成功",
+		"data":    location,
+	})
+}
+
+// NearestOPCodes 查询附近的投递点
+func (h *OPCodeHandler) NearestOPCodes(c *gin.Context) {
+	var req models.OPCodeNearestRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	nearby, err := h.opcodeService.NearestOPCodes(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"code":    5001,
+			"message": "查询附近投递点失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"code":    200,
+		"message": "查询成功",
+		"data":    nearby,
+	})
+}
+
+// ExportPublicGeoJSON 公开的校园地图：片区边界和公开投递点，直接返回 GeoJSON
+func (h *OPCodeHandler) ExportPublicGeoJSON(c *gin.Context) {
+	h.exportGeoJSON(c, true)
+}
+
+// AdminExportGeoJSON 导出完整校园地图，包含非公开投递点
+func (h *OPCodeHandler) AdminExportGeoJSON(c *gin.Context) {
+	if !h.requireOPCodeAdmin(c) {
+		return
+	}
+	h.exportGeoJSON(c, false)
+}
+
+func (h *OPCodeHandler) exportGeoJSON(c *gin.Context, publicOnly bool) {
+	collection, err := h.opcodeService.ExportGeoJSON(c.Query("school_code"), publicOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"code":    5001,
+			"message": "导出校园地图失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, collection)
+}
+
+// AdminImportGeoJSON 导入校园地图，请求体为 GeoJSON 或 multipart 的 file 字段
+// dry_run=true 时只校验并返回导入结果，不写入
+func (h *OPCodeHandler) AdminImportGeoJSON(c *gin.Context) {
+	if !h.requireOPCodeAdmin(c) {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGeoJSONImportSize)
+	var data []byte
+	var err error
+	if file, fileErr := c.FormFile("file"); fileErr == nil {
+		var reader io.ReadCloser
+		if reader, err = file.Open(); err == nil {
+			data, err = io.ReadAll(reader)
+			reader.Close()
+		}
+	} else {
+		data, err = c.GetRawData()
+	}
+	if err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "请上传 GeoJSON 文件",
+		})
+		return
+	}
+
+	result, err := h.opcodeService.ImportGeoJSON(data, c.Query("dry_run") == "true")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, geo.ErrInvalidCollection) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "导入校园地图失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"code":    200,
+		"message": "导入完成",
+		"data":    result,
+	})
+}
+
+// requireOPCodeAdmin 校验平台管理员权限，失败时已写入响应
+func (h *OPCodeHandler) requireOPCodeAdmin(c *gin.Context) bool {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "用户未认证",
+		})
+		return false
+	}
+	user := userInterface.(*models.User)
+	if user.Role != models.RolePlatformAdmin && user.Role != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"code":    4003,
+			"message": "权限不足",
+		})
+		return false
+	}
+	return true
+}
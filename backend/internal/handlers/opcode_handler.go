@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -50,6 +51,15 @@ func (h *OPCodeHandler) ApplyOPCode(c *gin.Context) {
 
 	// 创建申请
 	application, err := h.opcodeService.ApplyForOPCode(user.ID, &req)
+	if errors.Is(err, services.ErrSchoolNotVerified) || errors.Is(err, services.ErrSchoolMismatch) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"code":    4003,
+			"message": "请先通过学校邮箱认证在校身份，且只能为认证的学校申请",
+			"error":   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -69,6 +79,8 @@ func (h *OPCodeHandler) ApplyOPCode(c *gin.Context) {
 }
 
 // ValidateOPCode 验证OP Code格式和有效性
+// 支持6位旧格式和带校验位的7位格式，无效时返回“您是不是要找”的候选编码
+// context 为可选的寄件人OP Code，同校候选优先
 func (h *OPCodeHandler) ValidateOPCode(c *gin.Context) {
 	code := c.Query("code")
 	if code == "" {
@@ -80,25 +92,25 @@ func (h *OPCodeHandler) ValidateOPCode(c *gin.Context) {
 		return
 	}
 
-	// 验证格式
-	if err := models.ValidateOPCode(code); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	result, err := h.opcodeService.ResolveOPCode(code, c.Query("context"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"code":    4001,
-			"message": "OP Code格式不正确",
+			"code":    5001,
+			"message": "验证失败",
 			"error":   err.Error(),
 		})
 		return
 	}
 
-	// 验证是否存在
-	isValid, err := h.opcodeService.ValidateOPCode(code)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// 格式不正确
+	if result.Reason == models.OPCodeReasonInvalidFormat {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"code":    5001,
-			"message": "验证失败",
-			"error":   err.Error(),
+			"code":    4001,
+			"message": "OP Code格式不正确",
+			"error":   result.Message,
+			"data":    result,
 		})
 		return
 	}
@@ -108,8 +120,10 @@ func (h *OPCodeHandler) ValidateOPCode(c *gin.Context) {
 		"code":    200,
 		"message": "验证成功",
 		"data": gin.H{
-			"code":     code,
-			"is_valid": isValid,
+			"code":         code,
+			"is_valid":     result.Valid,
+			"resolution":   result,
+			"checked_code": result.CheckedCode,
 		},
 	})
 }
@@ -257,6 +271,59 @@ func (h *OPCodeHandler) AdminReviewApplication(c *gin.Context) {
 	})
 }
 
+// AdminSetLocation 管理员设置投递点坐标与签收地理围栏
+func (h *OPCodeHandler) AdminSetLocation(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "用户未认证",
+		})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	// 检查管理员权限
+	if user.Role != models.RolePlatformAdmin && user.Role != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"code":    4003,
+			"message": "权限不足",
+		})
+		return
+	}
+
+	var req models.OPCodeLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"code":    4001,
+			"message": "请求参数无效",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	opCode, err := h.opcodeService.SetOPCodeLocation(c.Param("code"), &req)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"code":    4004,
+			"message": "OP Code不存在",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"code":    200,
+		"message": "投递点坐标已更新",
+		"data":    opCode,
+	})
+}
+
 // GetOPCode 根据编码获取OP Code信息
 func (h *OPCodeHandler) GetOPCode(c *gin.Context) {
 	code := c.Param("code")
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+	"openpenpal-backend/pkg/sheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导入文件上限
+const maxOPCodeImportSize = 10 << 20
+
+// OPCodeImportHandler OP Code批量导入处理器
+type OPCodeImportHandler struct {
+	importService *services.OPCodeImportService
+}
+
+// NewOPCodeImportHandler 创建OP Code批量导入处理器
+func NewOPCodeImportHandler(importService *services.OPCodeImportService) *OPCodeImportHandler {
+	return &OPCodeImportHandler{importService: importService}
+}
+
+// Import 上传 CSV/XLSX 批量导入学校、片区和点位
+// @Summary 批量导入OP Code
+// @Description dry_run=true 只返回校验报告；有错误时整批不写入
+// @Tags OP Code
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV 或 XLSX 文件"
+// @Param dry_run query bool false "仅校验"
+// @Router /api/v1/opcode/admin/imports [post]
+func (h *OPCodeImportHandler) Import(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOPCodeImportSize)
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequestResponse(c, "请上传 CSV 或 XLSX 文件", err)
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		utils.BadRequestResponse(c, "读取文件失败", err)
+		return
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		utils.BadRequestResponse(c, "读取文件失败", err)
+		return
+	}
+
+	record, err := h.importService.Import(userID, file.Filename, data, c.Query("dry_run") == "true")
+	if err != nil {
+		h.handleError(c, "批量导入失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "导入校验完成", record)
+}
+
+// ListImports 导入批次列表
+// @Summary 导入批次列表
+// @Tags OP Code
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/opcode/admin/imports [get]
+func (h *OPCodeImportHandler) ListImports(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	records, total, err := h.importService.List(userID, page, limit)
+	if err != nil {
+		h.handleError(c, "获取导入批次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取导入批次成功", gin.H{
+		"items": records,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetImport 导入批次详情及校验报告
+// @Summary 导入批次详情
+// @Tags OP Code
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "导入批次ID"
+// @Router /api/v1/opcode/admin/imports/{id} [get]
+func (h *OPCodeImportHandler) GetImport(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	record, err := h.importService.Get(c.Param("id"), userID)
+	if err != nil {
+		h.handleError(c, "获取导入批次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取导入批次成功", record)
+}
+
+// RollbackImport 按导入批次回滚
+// @Summary 回滚导入批次
+// @Tags OP Code
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "导入批次ID"
+// @Router /api/v1/opcode/admin/imports/{id}/rollback [post]
+func (h *OPCodeImportHandler) RollbackImport(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	record, err := h.importService.Rollback(c.Param("id"), userID)
+	if err != nil {
+		h.handleError(c, "回滚导入批次失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "导入批次已回滚", record)
+}
+
+// Export 导出学校编码树，格式与导入一致
+// @Summary 导出学校编码树
+// @Tags OP Code
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param school_code query string true "学校代码"
+// @Param format query string false "csv 或 xlsx，默认 csv"
+// @Router /api/v1/opcode/admin/export [get]
+func (h *OPCodeImportHandler) Export(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	schoolCode := strings.ToUpper(c.Query("school_code"))
+	format := strings.ToLower(c.DefaultQuery("format", sheet.FormatCSV))
+	if format != sheet.FormatCSV && format != sheet.FormatXLSX {
+		utils.BadRequestResponse(c, "format 仅支持 csv 或 xlsx", nil)
+		return
+	}
+
+	data, err := h.importService.Export(userID, schoolCode, format)
+	if err != nil {
+		h.handleError(c, "导出失败", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="opcodes_%s.%s"`, schoolCode, format))
+	c.Data(http.StatusOK, sheet.ContentType(format), data)
+}
+
+func (h *OPCodeImportHandler) handleError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrOPCodeImportForbidden):
+		utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, services.ErrOPCodeImportNotFound):
+		utils.NotFoundResponse(c, err.Error())
+	case errors.Is(err, services.ErrOPCodeImportNotApplied), errors.Is(err, services.ErrOPCodeImportInUse):
+		utils.ConflictResponse(c, message, err)
+	case errors.Is(err, services.ErrOPCodeImportBadSheet):
+		utils.BadRequestResponse(c, message, err)
+	default:
+		utils.InternalServerErrorResponse(c, message, err)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProofOfDeliveryHandler 签收凭证处理器
+type ProofOfDeliveryHandler struct {
+	podService *services.ProofOfDeliveryService
+}
+
+// NewProofOfDeliveryHandler 创建签收凭证处理器
+func NewProofOfDeliveryHandler(podService *services.ProofOfDeliveryService) *ProofOfDeliveryHandler {
+	return &ProofOfDeliveryHandler{podService: podService}
+}
+
+// IssueConfirmation 收件人获取签收确认码
+// @Summary 获取签收确认码
+// @Description 收件人在信使上门时出示6位确认码或二维码，30分钟内有效，仅可使用一次
+// @Tags 签收凭证
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Success 200 {object} utils.Response{data=models.DeliveryConfirmationResponse}
+// @Router /api/v1/letters/{id}/delivery-confirmation [post]
+func (h *ProofOfDeliveryHandler) IssueConfirmation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	confirmation, err := h.podService.IssueConfirmation(c.Param("id"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeliveryLetterNotFound):
+			utils.NotFoundResponse(c, "信件不存在")
+		case errors.Is(err, services.ErrDeliveryNotRecipient):
+			utils.ForbiddenResponse(c, "只有收件人可以生成签收确认码")
+		case errors.Is(err, services.ErrDeliveryNotDeliverable):
+			utils.BadRequestResponse(c, "信件当前不在投递中", err)
+		default:
+			utils.InternalServerErrorResponse(c, "生成签收确认码失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "请向信使出示确认码", confirmation)
+}
+
+// ConfirmDelivery 信使当面交付并提交收件人确认码
+// @Summary 签收确认送达
+// @Description 校验收件人确认码/二维码、投递点地理围栏和可选照片后标记送达，生成签名签收凭证
+// @Tags 签收凭证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "信件编号"
+// @Param request body models.ConfirmDeliveryRequest true "签收信息"
+// @Success 200 {object} utils.Response{data=models.DeliveryReceipt}
+// @Router /api/v1/courier/letters/{code}/deliver [post]
+func (h *ProofOfDeliveryHandler) ConfirmDelivery(c *gin.Context) {
+	courierID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.ConfirmDeliveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	receipt, err := h.podService.ConfirmDelivery(c.Param("code"), courierID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeliveryLetterNotFound):
+			utils.NotFoundResponse(c, "信件不存在")
+		case isQRTokenError(err):
+			utils.ForbiddenResponse(c, err.Error())
+		case errors.Is(err, services.ErrDeliveryCourierMismatch):
+			utils.ForbiddenResponse(c, "该信件未分配给您")
+		case errors.Is(err, services.ErrDeliveryNotDeliverable):
+			utils.BadRequestResponse(c, "信件当前不在投递中", err)
+		case errors.Is(err, services.ErrDeliveryConfirmationRequired):
+			utils.BadRequestResponse(c, "请收件人在App中生成签收确认码", err)
+		case errors.Is(err, services.ErrDeliveryConfirmationInvalid):
+			utils.BadRequestResponse(c, "签收确认码错误", err)
+		case errors.Is(err, services.ErrDeliveryLocationRequired):
+			utils.BadRequestResponse(c, "请开启定位后再确认送达", err)
+		case errors.Is(err, services.ErrDeliveryOutsideGeofence):
+			utils.BadRequestResponse(c, "当前位置不在投递点范围内", err)
+		case errors.Is(err, services.ErrDeliveryPhotoInvalid):
+			utils.BadRequestResponse(c, "签收照片无效", err)
+		default:
+			utils.InternalServerErrorResponse(c, "确认送达失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "签收成功", receipt)
+}
+
+// GetReceipt 获取信件签收凭证
+// @Summary 获取签收凭证
+// @Description 寄件人、收件人、投递信使和管理员可查看，返回签名校验结果用于处理投递纠纷
+// @Tags 签收凭证
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Success 200 {object} utils.Response{data=models.DeliveryReceiptResponse}
+// @Router /api/v1/letters/{id}/delivery-receipt [get]
+func (h *ProofOfDeliveryHandler) GetReceipt(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+	role, _ := middleware.GetUserRole(c)
+	isAdmin := role == string(models.RolePlatformAdmin) || role == string(models.RoleSuperAdmin)
+
+	receipt, err := h.podService.GetReceipt(c.Param("id"), userID, isAdmin)
+	if err != nil {
+		if errors.Is(err, services.ErrDeliveryReceiptNotFound) {
+			utils.NotFoundResponse(c, "签收凭证不存在")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "获取签收凭证失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", receipt)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QRTokenHandler 二维码签名令牌处理器
+type QRTokenHandler struct {
+	qrTokenService *services.QRTokenService
+	letterService  *services.LetterService
+}
+
+// NewQRTokenHandler 创建二维码签名令牌处理器
+func NewQRTokenHandler(qrTokenService *services.QRTokenService, letterService *services.LetterService) *QRTokenHandler {
+	return &QRTokenHandler{
+		qrTokenService: qrTokenService,
+		letterService:  letterService,
+	}
+}
+
+// GetPublicKeys 获取二维码验证公钥
+// @Summary 二维码验证公钥
+// @Description 信使端下载后可离线验证信件二维码签名，按 kid 选择公钥，包含轮换前的旧公钥
+// @Tags 二维码
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Router /api/v1/qr/keys [get]
+func (h *QRTokenHandler) GetPublicKeys(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	utils.SuccessResponse(c, http.StatusOK, "获取二维码公钥成功", gin.H{
+		"keys": h.qrTokenService.PublicKeys(),
+	})
+}
+
+// ReissueQRCode 重新签发信件二维码
+// @Summary 重新签发信件二维码
+// @Description 标签遗失或疑似被复印时重新打印，旧标签扫码将被拒绝
+// @Tags 二维码
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "信件ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/letters/{id}/reissue-qr [post]
+func (h *QRTokenHandler) ReissueQRCode(c *gin.Context) {
+	letterCode, err := h.letterService.ReissueQRCode(c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "重新签发二维码失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "二维码已重新签发，旧标签已作废", gin.H{
+		"letter_code":         letterCode.Code,
+		"qr_code_url":         letterCode.QRCodeURL,
+		"qr_token_expires_at": letterCode.QRTokenExpiresAt,
+	})
+}
+
+// isQRTokenError 二维码签名校验失败，按无权操作返回
+func isQRTokenError(err error) bool {
+	return errors.Is(err, services.ErrQRTokenRequired) ||
+		errors.Is(err, services.ErrQRTokenInvalid) ||
+		errors.Is(err, services.ErrQRTokenExpired) ||
+		errors.Is(err, services.ErrQRTokenMismatch) ||
+		errors.Is(err, services.ErrQRTokenRevoked)
+}
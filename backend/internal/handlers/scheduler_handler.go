@@ -454,6 +454,24 @@ func (h *SchedulerHandler) CreateDefaultTasks(c *gin.Context) {
 			MaxRetries:     2,
 			TimeoutSecs:    180,
 		},
+		{
+			Name:           "条码过期回收",
+			Description:    "每小时提醒即将过期的条码，回收超时未绑定或未收取的条码并退回信封",
+			TaskType:       models.TaskTypeLetterExpiration,
+			Priority:       models.TaskPriorityNormal,
+			CronExpression: "0 10 * * * *", // 每小时10分执行
+			MaxRetries:     2,
+			TimeoutSecs:    900,
+		},
+		{
+			Name:           "账户删除执行",
+			Description:    "每小时执行冷静期已结束的账户删除请求并清理过期导出包",
+			TaskType:       models.TaskTypeDataErasure,
+			Priority:       models.TaskPriorityHigh,
+			CronExpression: "0 30 * * * *", // 每小时30分执行
+			MaxRetries:     3,
+			TimeoutSecs:    1800,
+		},
 	}
 
 	var createdTasks []string
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchoolVerificationHandler 在校身份认证处理器
+type SchoolVerificationHandler struct {
+	verificationService *services.SchoolVerificationService
+}
+
+// NewSchoolVerificationHandler 创建在校身份认证处理器
+func NewSchoolVerificationHandler(verificationService *services.SchoolVerificationService) *SchoolVerificationHandler {
+	return &SchoolVerificationHandler{verificationService: verificationService}
+}
+
+// GetStatus 获取在校身份认证状态
+// @Summary 获取在校身份认证状态
+// @Tags 在校身份认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.SchoolVerificationStatusResponse}
+// @Router /api/v1/users/me/school-verification [get]
+func (h *SchoolVerificationHandler) GetStatus(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	status, err := h.verificationService.GetStatus(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get verification status", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", status)
+}
+
+// StartVerification 发送学校邮箱验证码
+// @Summary 申请在校身份认证
+// @Description 向学校域名邮箱发送6位验证码，15分钟内有效
+// @Tags 在校身份认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.StartSchoolVerificationRequest true "学校与邮箱"
+// @Success 200 {object} utils.Response{data=models.SchoolVerification}
+// @Router /api/v1/users/me/school-verification [post]
+func (h *SchoolVerificationHandler) StartVerification(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.StartSchoolVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	verification, err := h.verificationService.StartVerification(userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSchoolNotFound):
+			utils.NotFoundResponse(c, "学校不存在或未开通")
+		case errors.Is(err, services.ErrSchoolEmailNotAllowed):
+			utils.BadRequestResponse(c, "请使用该学校的校园邮箱", err)
+		case errors.Is(err, services.ErrSchoolEmailInUse):
+			utils.ConflictResponse(c, "该邮箱已被其他账号认证", err)
+		case errors.Is(err, services.ErrSchoolVerificationTooOften):
+			utils.ErrorResponse(c, http.StatusTooManyRequests, "验证码发送过于频繁，请稍后再试", err)
+		default:
+			utils.InternalServerErrorResponse(c, "发送验证码失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "验证码已发送至学校邮箱", verification)
+}
+
+// ConfirmVerification 提交邮箱验证码完成认证
+// @Summary 确认在校身份认证
+// @Tags 在校身份认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ConfirmSchoolVerificationRequest true "验证码"
+// @Success 200 {object} utils.Response{data=models.SchoolVerification}
+// @Router /api/v1/users/me/school-verification/confirm [post]
+func (h *SchoolVerificationHandler) ConfirmVerification(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.ConfirmSchoolVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	verification, err := h.verificationService.ConfirmVerification(userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSchoolVerificationCode):
+			utils.BadRequestResponse(c, "验证码错误", err)
+		case errors.Is(err, services.ErrSchoolVerificationInvalid):
+			utils.BadRequestResponse(c, "验证码已过期，请重新获取", err)
+		default:
+			utils.InternalServerErrorResponse(c, "认证失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "在校身份认证成功", verification)
+}
+
+// AdminListVerifications 管理员查询认证记录
+// @Summary 查询在校身份认证记录
+// @Tags 在校身份认证
+// @Produce json
+// @Security BearerAuth
+// @Router /api/v1/admin/school-verifications [get]
+func (h *SchoolVerificationHandler) AdminListVerifications(c *gin.Context) {
+	var query models.SchoolVerificationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.BadRequestResponse(c, "Invalid query parameters", err)
+		return
+	}
+
+	verifications, total, err := h.verificationService.ListVerifications(&query)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list verifications", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", gin.H{
+		"verifications": verifications,
+		"total":         total,
+		"page":          query.Page,
+		"limit":         query.Limit,
+	})
+}
+
+// AdminRevokeVerification 管理员撤销认证
+// @Summary 撤销在校身份认证
+// @Tags 在校身份认证
+// @Accept json
+// @Security BearerAuth
+// @Param id path string true "认证ID"
+// @Param request body models.RevokeSchoolVerificationRequest true "撤销原因"
+// @Router /api/v1/admin/school-verifications/{id}/revoke [post]
+func (h *SchoolVerificationHandler) AdminRevokeVerification(c *gin.Context) {
+	var req models.RevokeSchoolVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	if err := h.verificationService.RevokeVerification(c.Param("id"), req.Reason); err != nil {
+		if errors.Is(err, services.ErrSchoolVerificationInvalid) {
+			utils.NotFoundResponse(c, "认证记录不存在或已失效")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to revoke verification", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "认证已撤销", nil)
+}
+
+// AdminUpdateEmailDomains 管理员设置学校认证邮箱域名
+// @Summary 设置学校认证邮箱域名
+// @Tags 在校身份认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "2位学校代码"
+// @Param request body models.UpdateSchoolEmailDomainsRequest true "邮箱域名"
+// @Success 200 {object} utils.Response{data=models.OPCodeSchool}
+// @Router /api/v1/admin/schools/{code}/email-domains [put]
+func (h *SchoolVerificationHandler) AdminUpdateEmailDomains(c *gin.Context) {
+	var req models.UpdateSchoolEmailDomainsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	school, err := h.verificationService.UpdateSchoolEmailDomains(c.Param("code"), req.EmailDomains)
+	if err != nil {
+		if errors.Is(err, services.ErrSchoolNotFound) {
+			utils.NotFoundResponse(c, "学校不存在")
+			return
+		}
+		utils.BadRequestResponse(c, "设置邮箱域名失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "邮箱域名已更新", school)
+}
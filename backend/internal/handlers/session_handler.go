@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler 登录会话管理处理器
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+// NewSessionHandler 创建会话管理处理器
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// GetSessions 获取当前用户的登录会话
+// @Summary 获取登录设备列表
+// @Tags 认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.SessionInfo}
+// @Router /api/v1/auth/sessions [get]
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "用户未认证")
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(userID, c.GetString("session_id"))
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取会话列表失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "获取会话列表成功", sessions)
+}
+
+// RevokeSession 注销指定会话（踢下线某台设备）
+// @Summary 注销指定会话
+// @Tags 认证
+// @Security BearerAuth
+// @Param id path string true "会话ID"
+// @Router /api/v1/auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "用户未认证")
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(userID, c.Param("id"), services.SessionRevokeByUser); err != nil {
+		utils.NotFoundResponse(c, "会话不存在或已注销")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "会话已注销", nil)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandler 外部身份关联与身份提供方管理处理器
+type SSOHandler struct {
+	ssoService *services.SSOService
+}
+
+// NewSSOHandler 创建外部身份处理器
+func NewSSOHandler(ssoService *services.SSOService) *SSOHandler {
+	return &SSOHandler{ssoService: ssoService}
+}
+
+// GetIdentities 获取已关联的校园账号
+// @Summary 获取已关联的外部身份
+// @Tags 单点登录
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.UserIdentity}
+// @Router /api/v1/users/me/identities [get]
+func (h *SSOHandler) GetIdentities(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	identities, err := h.ssoService.GetUserIdentities(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get identities", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", identities)
+}
+
+// BeginLink 发起关联校园账号
+// @Summary 发起关联外部身份
+// @Tags 单点登录
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "身份提供方标识"
+// @Param redirect_uri query string false "回调地址"
+// @Success 200 {object} utils.Response{data=models.SSOAuthorizeResponse}
+// @Router /api/v1/users/me/identities/{provider}/link [post]
+func (h *SSOHandler) BeginLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	authorize, err := h.ssoService.BeginLogin(c.Param("provider"), c.Query("redirect_uri"), userID)
+	if err != nil {
+		switch err {
+		case services.ErrSSOProviderNotFound:
+			utils.NotFoundResponse(c, "登录方式不存在或已停用")
+		case services.ErrSSORedirectNotAllowed:
+			utils.BadRequestResponse(c, "回调地址不被允许", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to start linking", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", authorize)
+}
+
+// BeginReauth 敏感操作前通过校园账号重新认证
+// @Summary 发起单点登录重新认证
+// @Description 用于没有本地密码的自动创建账号删除账户等敏感操作；回调参数随敏感操作请求一并提交
+// @Tags 单点登录
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "身份提供方标识"
+// @Param redirect_uri query string false "回调地址"
+// @Success 200 {object} utils.Response{data=models.SSOAuthorizeResponse}
+// @Router /api/v1/users/me/identities/{provider}/reauth [post]
+func (h *SSOHandler) BeginReauth(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	authorize, err := h.ssoService.BeginReauth(c.Param("provider"), c.Query("redirect_uri"), userID)
+	if err != nil {
+		switch err {
+		case services.ErrSSOProviderNotFound:
+			utils.NotFoundResponse(c, "登录方式不存在或已停用")
+		case services.ErrSSORedirectNotAllowed:
+			utils.BadRequestResponse(c, "回调地址不被允许", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to start re-authentication", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", authorize)
+}
+
+// CompleteLink 完成关联校园账号
+// @Summary 完成关联外部身份
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SSOCallbackRequest true "回调参数"
+// @Success 200 {object} utils.Response{data=models.UserIdentity}
+// @Router /api/v1/users/me/identities/callback [post]
+func (h *SSOHandler) CompleteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+	if req.Code == "" && req.Ticket == "" {
+		utils.BadRequestResponse(c, "缺少授权码或票据", fmt.Errorf("code or ticket is required"))
+		return
+	}
+
+	identity, err := h.ssoService.CompleteLink(userID, &req)
+	if err != nil {
+		switch err {
+		case services.ErrSSOStateInvalid:
+			utils.BadRequestResponse(c, "关联已过期，请重新发起", err)
+		case services.ErrSSOIdentityInUse:
+			utils.ConflictResponse(c, "该校园账号已关联其他用户", err)
+		default:
+			utils.BadRequestResponse(c, "关联校园账号失败", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "校园账号已关联", identity)
+}
+
+// Unlink 解除关联校园账号
+// @Summary 解除关联外部身份
+// @Tags 单点登录
+// @Security BearerAuth
+// @Param id path string true "关联ID"
+// @Router /api/v1/users/me/identities/{id} [delete]
+func (h *SSOHandler) Unlink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	if err := h.ssoService.UnlinkIdentity(userID, c.Param("id")); err != nil {
+		switch err {
+		case services.ErrSSOAccountNotLinked:
+			utils.NotFoundResponse(c, "关联不存在")
+		case services.ErrSSOLastIdentity:
+			utils.BadRequestResponse(c, "这是账号唯一的登录方式，无法解除关联", err)
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to unlink identity", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "已解除关联", nil)
+}
+
+// AdminListProviders 管理员获取身份提供方列表
+// @Summary 获取身份提供方列表
+// @Tags 单点登录
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.IdentityProvider}
+// @Router /api/v1/admin/identity-providers [get]
+func (h *SSOHandler) AdminListProviders(c *gin.Context) {
+	providers, err := h.ssoService.ListProviders(false)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list identity providers", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", providers)
+}
+
+// AdminCreateProvider 管理员创建身份提供方
+// @Summary 创建身份提供方
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.IdentityProvider true "身份提供方配置"
+// @Router /api/v1/admin/identity-providers [post]
+func (h *SSOHandler) AdminCreateProvider(c *gin.Context) {
+	var provider models.IdentityProvider
+	if err := c.ShouldBindJSON(&provider); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	if err := h.ssoService.CreateProvider(&provider); err != nil {
+		utils.BadRequestResponse(c, "创建身份提供方失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "身份提供方已创建", provider)
+}
+
+// AdminUpdateProvider 管理员更新身份提供方
+// @Summary 更新身份提供方
+// @Tags 单点登录
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "身份提供方ID"
+// @Param request body models.IdentityProvider true "身份提供方配置"
+// @Router /api/v1/admin/identity-providers/{id} [put]
+func (h *SSOHandler) AdminUpdateProvider(c *gin.Context) {
+	var provider models.IdentityProvider
+	if err := c.ShouldBindJSON(&provider); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	updated, err := h.ssoService.UpdateProvider(c.Param("id"), &provider)
+	if err != nil {
+		if err == services.ErrSSOProviderNotFound {
+			utils.NotFoundResponse(c, "身份提供方不存在")
+			return
+		}
+		utils.BadRequestResponse(c, "更新身份提供方失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "身份提供方已更新", updated)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"net/http"
+
+	"openpenpal-backend/internal/middleware"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/services"
+	"openpenpal-backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler 两步验证管理处理器
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	userService      *services.UserService
+}
+
+// NewTwoFactorHandler 创建两步验证管理处理器
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, userService *services.UserService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		userService:      userService,
+	}
+}
+
+// GetStatus 获取两步验证状态
+// @Summary 获取两步验证状态
+// @Tags 两步验证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.TwoFactorStatus}
+// @Router /api/v1/users/me/2fa [get]
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	status, err := h.twoFactorService.GetStatus(userID, user.Role)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get two-factor status", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Success", status)
+}
+
+// BeginEnrollment 开始绑定验证器
+// @Summary 生成TOTP密钥与绑定二维码
+// @Tags 两步验证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.TwoFactorEnrollment}
+// @Router /api/v1/users/me/2fa/enroll [post]
+func (h *TwoFactorHandler) BeginEnrollment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	enrollment, err := h.twoFactorService.BeginEnrollment(userID)
+	if err != nil {
+		if err == services.ErrTwoFactorAlreadyActive {
+			utils.ConflictResponse(c, "两步验证已启用", err)
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to start enrollment", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "请使用验证器App扫描二维码", enrollment)
+}
+
+// ConfirmEnrollment 确认绑定并启用两步验证
+// @Summary 确认绑定验证器
+// @Description 验证码正确后启用两步验证，并返回仅显示一次的恢复码
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "验证码"
+// @Router /api/v1/users/me/2fa/confirm [post]
+func (h *TwoFactorHandler) ConfirmEnrollment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		utils.BadRequestResponse(c, "验证码错误或绑定未开始", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "两步验证已启用", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// Disable 关闭两步验证
+// @Summary 关闭两步验证
+// @Tags 两步验证
+// @Accept json
+// @Security BearerAuth
+// @Param request body models.DisableTwoFactorRequest true "密码与验证码"
+// @Router /api/v1/users/me/2fa/disable [post]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Password, req.Code); err != nil {
+		if err == services.ErrTwoFactorRequired {
+			utils.ForbiddenResponse(c, "当前角色必须启用两步验证")
+			return
+		}
+		utils.BadRequestResponse(c, "关闭两步验证失败", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "两步验证已关闭", nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Tags 两步验证
+// @Accept json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "验证码"
+// @Router /api/v1/users/me/2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "Invalid request data", err)
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		utils.BadRequestResponse(c, "验证码错误", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "恢复码已重新生成", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// RevokeTrustedDevices 撤销所有已记住的设备
+// @Summary 撤销已记住的设备
+// @Tags 两步验证
+// @Security BearerAuth
+// @Router /api/v1/users/me/2fa/trusted-devices [delete]
+func (h *TwoFactorHandler) RevokeTrustedDevices(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	revoked, err := h.twoFactorService.RevokeTrustedDevices(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to revoke trusted devices", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "已撤销记住的设备", gin.H{
+		"revoked": revoked,
+	})
+}
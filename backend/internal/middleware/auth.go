@@ -68,6 +68,20 @@ func AuthMiddleware(config *config.Config, db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// 检查Token所属会话是否已被注销（注销全部会话、修改密码、刷新令牌重放）
+		if claims.SessionID != "" {
+			var session models.UserSession
+			if err := db.Select("id", "revoked_at", "expires_at").Where("id = ?", claims.SessionID).First(&session).Error; err != nil || !session.IsActive() {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "会话已失效",
+					"message": "该登录会话已被注销或过期，请重新登录",
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		// 首先尝试从缓存获取用户信息
 		user, cached := userCache.Get(claims.UserID)
 		if !cached {
@@ -120,6 +134,7 @@ func AuthMiddleware(config *config.Config, db *gorm.DB) gin.HandlerFunc {
 		c.Set("user_role", claims.Role)
 		c.Set("user", user)
 		c.Set("token_jti", claims.RegisteredClaims.ID) // 保存JWT ID用于注销
+		c.Set("session_id", claims.SessionID)
 
 		// 添加性能监控头
 		duration := time.Since(start)
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// 条码滞留统计的阶段；bound_uncollected 为已绑定但信件仍未被收取
+const (
+	BarcodeStageUnactivated      = "unactivated"
+	BarcodeStageBoundUncollected = "bound_uncollected"
+	BarcodeStageInTransit        = "in_transit"
+)
+
+// BarcodeLifecycleRunResult 一次条码生命周期检查的处理结果
+type BarcodeLifecycleRunResult struct {
+	Scheduled         int       `json:"scheduled"` // 补设过期时间的旧条码
+	Warned            int       `json:"warned"`
+	Expired           int       `json:"expired"`   // 未激活超时，置为已过期
+	Cancelled         int       `json:"cancelled"` // 绑定后未收取超时，置为已取消
+	EnvelopesReturned int       `json:"envelopes_returned"`
+	RanAt             time.Time `json:"ran_at"`
+}
+
+// BarcodeLifecyclePolicy 当前生效的过期配置
+type BarcodeLifecyclePolicy struct {
+	UnactivatedTTLHours int `json:"unactivated_ttl_hours"`
+	BoundTTLHours       int `json:"bound_ttl_hours"`
+	WarnBeforeHours     int `json:"warn_before_hours"`
+}
+
+// BarcodeStageStats 某一阶段的条码滞留情况，按停留时长分段
+type BarcodeStageStats struct {
+	Stage      string         `json:"stage"`
+	Count      int            `json:"count"`
+	DueSoon    int            `json:"due_soon"` // 提醒期内即将回收
+	Overdue    int            `json:"overdue"`  // 已过期待下次检查回收
+	AgeBuckets map[string]int `json:"age_buckets"`
+	OldestAt   *time.Time     `json:"oldest_at,omitempty"`
+}
+
+// BarcodeLifecycleReport 条码滞留报告
+type BarcodeLifecycleReport struct {
+	Policy      BarcodeLifecyclePolicy `json:"policy"`
+	Stages      []BarcodeStageStats    `json:"stages"`
+	Expired     int64                  `json:"expired"`
+	Cancelled   int64                  `json:"cancelled"`
+	Delivered   int64                  `json:"delivered"`
+	GeneratedAt time.Time              `json:"generated_at"`
+}
+
+// ReclaimBarcodeRequest 管理员手动回收条码
+type ReclaimBarcodeRequest struct {
+	Reason string `json:"reason" binding:"max=200"`
+}
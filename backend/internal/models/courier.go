@@ -194,6 +194,7 @@ const (
 	CourierTaskStatusInTransit = "in_transit" // 配送中
 	CourierTaskStatusDelivered = "delivered"  // 已送达
 	CourierTaskStatusFailed    = "failed"     // 配送失败
+	CourierTaskStatusRerouted  = "rerouted"   // 收件人地址变更，由新的投递段接替
 )
 
 // CourierTaskPriority 信使任务优先级常量
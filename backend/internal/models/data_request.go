@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// DataRequestType 数据主体请求类型
+type DataRequestType string
+
+const (
+	DataRequestTypeExport  DataRequestType = "export"  // 个人数据导出
+	DataRequestTypeErasure DataRequestType = "erasure" // 账户删除（被遗忘权）
+)
+
+// DataRequestStatus 数据主体请求状态
+type DataRequestStatus string
+
+const (
+	DataRequestStatusPending    DataRequestStatus = "pending"    // 待处理
+	DataRequestStatusProcessing DataRequestStatus = "processing" // 处理中
+	DataRequestStatusReady      DataRequestStatus = "ready"      // 导出包可下载
+	DataRequestStatusScheduled  DataRequestStatus = "scheduled"  // 删除冷静期中
+	DataRequestStatusCompleted  DataRequestStatus = "completed"  // 已完成
+	DataRequestStatusCancelled  DataRequestStatus = "cancelled"  // 已取消
+	DataRequestStatusExpired    DataRequestStatus = "expired"    // 导出包已过期
+	DataRequestStatusFailed     DataRequestStatus = "failed"     // 处理失败
+)
+
+// DataSubjectRequest 数据主体请求（导出/删除）
+type DataSubjectRequest struct {
+	ID           string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID       string            `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	Type         DataRequestType   `json:"type" gorm:"type:varchar(20);not null;index"`
+	Status       DataRequestStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	Reason       string            `json:"reason,omitempty" gorm:"type:text"`
+	ArchivePath  string            `json:"-" gorm:"type:varchar(500)"`           // 导出包路径（不对外暴露）
+	ArchiveSize  int64             `json:"archive_size" gorm:"default:0"`        // 导出包大小（字节）
+	ArchiveHash  string            `json:"archive_hash" gorm:"type:varchar(64)"` // 导出包SHA256
+	ScheduledFor *time.Time        `json:"scheduled_for,omitempty" gorm:"index"` // 删除执行时间（冷静期结束）
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty" gorm:"index"`    // 导出包下载截止时间
+	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
+	ErrorMessage string            `json:"error_message,omitempty" gorm:"type:text"`
+	RequestIP    string            `json:"-" gorm:"type:varchar(45)"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (DataSubjectRequest) TableName() string {
+	return "data_subject_requests"
+}
+
+// IsDownloadable 检查导出包是否可下载
+func (r *DataSubjectRequest) IsDownloadable() bool {
+	if r.Type != DataRequestTypeExport || r.Status != DataRequestStatusReady {
+		return false
+	}
+	return r.ExpiresAt == nil || time.Now().Before(*r.ExpiresAt)
+}
+
+// CanBeCancelled 检查请求是否可以取消（仅冷静期内的删除请求）
+func (r *DataSubjectRequest) CanBeCancelled() bool {
+	return r.Type == DataRequestTypeErasure && r.Status == DataRequestStatusScheduled
+}
+
+// DataRequestAuditLog 数据主体请求审计记录
+// 账户删除后仍保留，用于向数据办公室证明请求已被处理
+type DataRequestAuditLog struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	RequestID string         `json:"request_id" gorm:"type:varchar(36);not null;index"`
+	UserID    string         `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	Action    string         `json:"action" gorm:"type:varchar(50);not null"` // requested, exported, downloaded, cancelled, erased, failed
+	ActorID   string         `json:"actor_id" gorm:"type:varchar(36)"`        // 操作者（用户本人、管理员或system）
+	Details   datatypes.JSON `json:"details,omitempty" gorm:"type:jsonb"`     // 各数据类别的处理数量等
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// TableName 设置表名
+func (DataRequestAuditLog) TableName() string {
+	return "data_request_audit_logs"
+}
+
+// CreateErasureRequest 申请删除账户请求
+type CreateErasureRequest struct {
+	Password string `json:"password"`
+	Reason   string `json:"reason"`
+
+	// 自动创建的校园账号没有本地密码，改用两步验证码或刚完成的单点登录重新认证
+	TwoFactorCode string              `json:"two_factor_code"`
+	SSOReauth     *SSOCallbackRequest `json:"sso_reauth"`
+}
+
+// DataRequestQuery 数据主体请求查询参数
+type DataRequestQuery struct {
+	UserID string            `form:"user_id"`
+	Type   DataRequestType   `form:"type"`
+	Status DataRequestStatus `form:"status"`
+	Page   int               `form:"page,default=1"`
+	Limit  int               `form:"limit,default=20"`
+}
@@ -17,6 +17,8 @@ const (
 	DesignStatusPending  = "pending"
 	DesignStatusApproved = "approved"
 	DesignStatusRejected = "rejected"
+	DesignStatusContest  = "contest"  // 评选活动参赛中，不可订购
+	DesignStatusUnplaced = "unplaced" // 评选结束未获胜
 )
 
 // EnvelopeDesign 信封设计 - 增强OP Code支持
@@ -39,6 +41,11 @@ type EnvelopeDesign struct {
 	SupportedOPCodePrefix string  `json:"supported_op_code_prefix,omitempty" gorm:"type:varchar(4);index"` // 支持的OP Code前缀(如:PK5F)
 	Price                 float64 `json:"price" gorm:"type:decimal(10,2);default:3.00"`                    // 信封价格
 
+	// 设计评选
+	ContestID    string  `json:"contest_id,omitempty" gorm:"type:varchar(36);index"`
+	ContestScore float64 `json:"contest_score"`          // 有效投票的加权得分
+	ContestRank  int     `json:"contest_rank,omitempty"` // 评选结束后的名次
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
@@ -141,9 +148,9 @@ type EnvelopeDesignListRequest struct {
 
 // BindBarcodeRequest 绑定条码请求 - FSD 6.2
 type BindBarcodeRequest struct {
-	RecipientCode string `json:"recipient_code" binding:"required,len=6"` // OP Code编码
-	LetterID      string `json:"letter_id" binding:"required"`            // 信件编号
-	EnvelopeID    string `json:"envelope_id,omitempty"`                   // 可选，信封编号
+	RecipientCode string `json:"recipient_code" binding:"required,min=6,max=8"` // OP Code编码，可带校验位
+	LetterID      string `json:"letter_id" binding:"required"`                  // 信件编号
+	EnvelopeID    string `json:"envelope_id,omitempty"`                         // 可选，信封编号
 }
 
 // UpdateBarcodeStatusRequest 更新物流状态请求 - FSD 6.3
@@ -153,6 +160,7 @@ type UpdateBarcodeStatusRequest struct {
 	Location   string `json:"location,omitempty"`                                                 // 位置信息
 	OPCode     string `json:"op_code,omitempty"`                                                  // 位置OP Code
 	Notes      string `json:"notes,omitempty"`                                                    // 备注
+	QRToken    string `json:"qr_token,omitempty"`                                                 // 二维码中的签名令牌，可直接传扫码内容
 }
 
 // BatchGenerateEnvelopeRequest 批量生成信封请求 - FSD接口
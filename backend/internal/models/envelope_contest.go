@@ -0,0 +1,187 @@
+package models
+
+import "time"
+
+// 信封设计评选状态
+const (
+	ContestStatusActive    = "active"    // 按时间推进征稿与投票
+	ContestStatusFinalized = "finalized" // 已评出获胜设计
+)
+
+// 评选阶段，由时间推算
+const (
+	ContestPhaseUpcoming   = "upcoming"   // 未开始征稿
+	ContestPhaseSubmission = "submission" // 征稿中
+	ContestPhaseReview     = "review"     // 征稿结束，等待投票
+	ContestPhaseVoting     = "voting"     // 投票中
+	ContestPhaseEnded      = "ended"      // 投票结束，等待评出获胜设计
+	ContestPhaseFinalized  = "finalized"
+)
+
+// 评选投票状态
+const (
+	ContestVoteCounted = "counted" // 计入得分
+	ContestVoteFlagged = "flagged" // 疑似刷票，待管理员复核，不计分
+	ContestVoteVoid    = "void"    // 管理员确认作废
+)
+
+// 投票异常原因
+const (
+	ContestFlagRisk         = "risk_alert"    // 积分风控检测到高风险行为
+	ContestFlagSharedIP     = "shared_ip"     // 同一IP多个账号投票
+	ContestFlagSharedDevice = "shared_device" // 同一设备多个账号投票
+	ContestFlagBurst        = "burst"         // 短时间内集中涌入新账号投票
+)
+
+// EnvelopeContest 信封设计评选：征稿期提交设计，投票期投票，结束后得分最高的设计上架
+type EnvelopeContest struct {
+	ID          string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Title       string `json:"title" gorm:"type:varchar(100);not null"`
+	Theme       string `json:"theme" gorm:"type:varchar(100)"`
+	Description string `json:"description" gorm:"type:text"`
+	SchoolCode  string `json:"school_code,omitempty" gorm:"type:varchar(2);index"` // 为空时全平台，否则仅限认证为该校的用户
+	Status      string `json:"status" gorm:"type:varchar(20);not null;index"`
+
+	SubmissionStartAt time.Time `json:"submission_start_at"`
+	SubmissionEndAt   time.Time `json:"submission_end_at"`
+	VotingStartAt     time.Time `json:"voting_start_at"`
+	VotingEndAt       time.Time `json:"voting_end_at" gorm:"index"`
+
+	// 参与规则
+	MaxVotesPerUser   int  `json:"max_votes_per_user"`   // 每人可投设计数
+	MaxEntriesPerUser int  `json:"max_entries_per_user"` // 每人可提交设计数
+	MinAccountAgeDays int  `json:"min_account_age_days"` // 注册满多少天才能参与
+	RequireVerified   bool `json:"require_verified"`     // 是否要求在校身份认证
+
+	WinnerCount int     `json:"winner_count"`                           // 获胜设计数
+	WinnerPrice float64 `json:"winner_price" gorm:"type:decimal(10,2)"` // 获胜设计上架价格
+
+	CreatedBy   string     `json:"created_by" gorm:"type:varchar(36)"`
+	FinalizedAt *time.Time `json:"finalized_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Phase string `json:"phase" gorm:"-"`
+}
+
+func (EnvelopeContest) TableName() string {
+	return "envelope_contests"
+}
+
+// CurrentPhase 评选当前阶段
+func (c *EnvelopeContest) CurrentPhase(now time.Time) string {
+	switch {
+	case c.Status == ContestStatusFinalized:
+		return ContestPhaseFinalized
+	case now.Before(c.SubmissionStartAt):
+		return ContestPhaseUpcoming
+	case now.Before(c.SubmissionEndAt):
+		return ContestPhaseSubmission
+	case now.Before(c.VotingStartAt):
+		return ContestPhaseReview
+	case now.Before(c.VotingEndAt):
+		return ContestPhaseVoting
+	default:
+		return ContestPhaseEnded
+	}
+}
+
+// EnvelopeContestVote 评选投票，每人每个设计一票，按账号可信度加权
+type EnvelopeContestVote struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ContestID  string     `json:"contest_id" gorm:"type:varchar(36);not null;index"`
+	DesignID   string     `json:"design_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_contest_vote_voter"`
+	VoterID    string     `json:"voter_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_contest_vote_voter;index"`
+	Weight     float64    `json:"weight"`
+	Status     string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	FlagReason string     `json:"flag_reason,omitempty" gorm:"type:varchar(255)"`
+	IPAddress  string     `json:"ip_address,omitempty" gorm:"type:varchar(45);index"`
+	DeviceID   string     `json:"device_id,omitempty" gorm:"type:varchar(100);index"`
+	ReviewedBy string     `json:"reviewed_by,omitempty" gorm:"type:varchar(36)"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (EnvelopeContestVote) TableName() string {
+	return "envelope_contest_votes"
+}
+
+// CreateEnvelopeContestRequest 创建评选
+type CreateEnvelopeContestRequest struct {
+	Title             string    `json:"title" binding:"required,max=100"`
+	Theme             string    `json:"theme"`
+	Description       string    `json:"description"`
+	SchoolCode        string    `json:"school_code" binding:"omitempty,len=2"`
+	SubmissionStartAt time.Time `json:"submission_start_at" binding:"required"`
+	SubmissionEndAt   time.Time `json:"submission_end_at" binding:"required"`
+	VotingStartAt     time.Time `json:"voting_start_at" binding:"required"`
+	VotingEndAt       time.Time `json:"voting_end_at" binding:"required"`
+	MaxVotesPerUser   int       `json:"max_votes_per_user" binding:"omitempty,min=1,max=20"`
+	MaxEntriesPerUser int       `json:"max_entries_per_user" binding:"omitempty,min=1,max=10"`
+	MinAccountAgeDays *int      `json:"min_account_age_days" binding:"omitempty,min=0,max=365"`
+	RequireVerified   *bool     `json:"require_verified"`
+	WinnerCount       int       `json:"winner_count" binding:"omitempty,min=1,max=20"`
+	WinnerPrice       float64   `json:"winner_price" binding:"omitempty,min=0"`
+}
+
+// SubmitContestEntryRequest 提交参赛设计
+type SubmitContestEntryRequest struct {
+	Theme       string `json:"theme" binding:"required,max=100"`
+	ImageURL    string `json:"image_url" binding:"required"`
+	Description string `json:"description"`
+}
+
+// ReviewContestVoteRequest 复核被标记的投票
+type ReviewContestVoteRequest struct {
+	Action string `json:"action" binding:"required,oneof=count void"`
+}
+
+// ContestVoteMeta 投票请求来源，用于刷票检测
+type ContestVoteMeta struct {
+	IPAddress string
+	DeviceID  string
+	UserAgent string
+}
+
+// EnvelopeContestDetail 评选详情；投票结束前不公开得分
+type EnvelopeContestDetail struct {
+	Contest      *EnvelopeContest `json:"contest"`
+	Entries      []EnvelopeDesign `json:"entries"`
+	ScoresHidden bool             `json:"scores_hidden"`
+
+	// 当前用户
+	Eligible         bool     `json:"eligible"`
+	IneligibleReason string   `json:"ineligible_reason,omitempty"`
+	VotedDesignIDs   []string `json:"voted_design_ids"`
+	VotesRemaining   int      `json:"votes_remaining"`
+}
+
+// ContestVoteResult 投票结果；不返回是否被标记，避免刷票者据此调整
+type ContestVoteResult struct {
+	DesignID       string `json:"design_id"`
+	VotesRemaining int    `json:"votes_remaining"`
+}
+
+// ContestVoteCluster 多个账号共用的IP或设备
+type ContestVoteCluster struct {
+	Kind   string `json:"kind"` // ip, device
+	Value  string `json:"value"`
+	Voters int64  `json:"voters"`
+}
+
+// ContestAnomalyReport 评选刷票检测报告
+type ContestAnomalyReport struct {
+	ContestID     string                `json:"contest_id"`
+	CountedVotes  int64                 `json:"counted_votes"`
+	FlaggedVotes  int64                 `json:"flagged_votes"`
+	VoidVotes     int64                 `json:"void_votes"`
+	FlagsByReason map[string]int64      `json:"flags_by_reason"`
+	Clusters      []ContestVoteCluster  `json:"clusters"`
+	Flagged       []EnvelopeContestVote `json:"flagged"`
+}
+
+// ContestFinalizeResult 评选结果
+type ContestFinalizeResult struct {
+	Contest *EnvelopeContest `json:"contest"`
+	Winners []EnvelopeDesign `json:"winners"`
+}
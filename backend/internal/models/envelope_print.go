@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// 标签条码类型
+const (
+	PrintSymbologyCode128 = "code128"
+	PrintSymbologyQR      = "qr"
+)
+
+// EnvelopePrintBatch 信封标签打印批次，每次打印（含重印）都生成一条记录和对应的 PDF
+type EnvelopePrintBatch struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	DesignID       string    `json:"design_id" gorm:"type:varchar(36);index"`
+	OrderID        string    `json:"order_id,omitempty" gorm:"type:varchar(36);index"`
+	Layout         string    `json:"layout" gorm:"type:varchar(30);not null"`
+	Symbology      string    `json:"symbology" gorm:"type:varchar(20);not null"`
+	StartPosition  int       `json:"start_position" gorm:"default:0"`
+	LabelCount     int       `json:"label_count"`
+	PageCount      int       `json:"page_count"`
+	ArtworkApplied bool      `json:"artwork_applied"`                                       // 设计图是否成功作为背景
+	ReprintOfID    *string   `json:"reprint_of_id,omitempty" gorm:"type:varchar(36);index"` // 重印的原批次
+	Reason         string    `json:"reason,omitempty" gorm:"type:varchar(255)"`             // 重印原因
+	CreatedBy      string    `json:"created_by" gorm:"type:varchar(36);not null;index"`
+	FilePath       string    `json:"-" gorm:"type:varchar(500)"`
+	Checksum       string    `json:"checksum" gorm:"type:varchar(64)"` // PDF 的 SHA-256
+	CreatedAt      time.Time `json:"created_at"`
+
+	Items []EnvelopePrintItem `json:"items,omitempty" gorm:"foreignKey:BatchID"`
+}
+
+func (EnvelopePrintBatch) TableName() string {
+	return "envelope_print_batches"
+}
+
+// EnvelopePrintItem 打印批次中的单个标签
+type EnvelopePrintItem struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	BatchID    string    `json:"batch_id" gorm:"type:varchar(36);not null;index"`
+	EnvelopeID string    `json:"envelope_id" gorm:"type:varchar(36);not null;index"`
+	BarcodeID  string    `json:"barcode_id" gorm:"type:varchar(100);not null"`
+	Position   int       `json:"position"` // 在批次中的顺序
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (EnvelopePrintItem) TableName() string {
+	return "envelope_print_items"
+}
+
+// CreatePrintBatchRequest 创建打印批次请求，按订单或信封ID选择，两者至少一个
+type CreatePrintBatchRequest struct {
+	OrderID       string   `json:"order_id"`
+	EnvelopeIDs   []string `json:"envelope_ids" binding:"omitempty,max=500"`
+	Layout        string   `json:"layout" binding:"required"`
+	Symbology     string   `json:"symbology" binding:"omitempty,oneof=code128 qr"`
+	StartPosition int      `json:"start_position" binding:"min=0"` // 续打用过一部分的标签纸
+}
+
+// ReprintBatchRequest 重印请求，未指定信封时重印整批
+type ReprintBatchRequest struct {
+	Reason        string   `json:"reason" binding:"required,max=255"`
+	EnvelopeIDs   []string `json:"envelope_ids"`
+	Layout        string   `json:"layout"`
+	StartPosition int      `json:"start_position" binding:"min=0"`
+}
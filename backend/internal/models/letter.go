@@ -76,6 +76,8 @@ type Letter struct {
 	// OP Code System - 核心地址标识
 	RecipientOPCode string         `json:"recipient_op_code" gorm:"type:varchar(6);index"` // 收件人OP Code，如: PK5F3D
 	SenderOPCode    string         `json:"sender_op_code" gorm:"type:varchar(6);index"`    // 发件人OP Code（可选）
+	DeliveryOPCode  string         `json:"-" gorm:"type:varchar(6);index"`                 // 实际投递OP Code，收件人登记转寄后与收件人OP Code不同，不对寄件人公开
+	AddressChanged  bool           `json:"address_changed" gorm:"default:false"`           // 收件人地址已变更（收件人同意告知寄件人时才标记）
 	ShareCount      int            `json:"share_count" gorm:"default:0"`
 	ViewCount       int            `json:"view_count" gorm:"default:0"`
 	ReplyTo         string         `json:"reply_to,omitempty" gorm:"type:varchar(36);index;constraint:OnDelete:SET NULL;"`
@@ -128,6 +130,13 @@ type LetterCode struct {
 	LastScannedAt *time.Time    `json:"last_scanned_at,omitempty"`                                  // 最后扫码时间
 	ScanCount     int           `json:"scan_count" gorm:"default:0"`                                // 扫码次数
 
+	// 二维码签名令牌，重新打印标签时更换序号使旧标签失效
+	QRTokenNonce     string     `json:"-" gorm:"type:varchar(32)"`
+	QRTokenExpiresAt *time.Time `json:"qr_token_expires_at,omitempty"`
+
+	// 未绑定或绑定后未收取的条码到 ExpiresAt 自动回收，过期前提醒一次
+	ExpiryWarnedAt *time.Time `json:"expiry_warned_at,omitempty"`
+
 	// 关联
 	Letter   Letter    `json:"letter,omitempty" gorm:"foreignKey:LetterID;references:ID;constraint:OnDelete:CASCADE;"`
 	Envelope *Envelope `json:"envelope,omitempty" gorm:"foreignKey:EnvelopeID;references:ID;constraint:OnDelete:SET NULL;"`
@@ -196,6 +205,7 @@ type StatusLog struct {
 	UpdatedBy string       `json:"updated_by" gorm:"type:varchar(36)"`
 	Location  string       `json:"location,omitempty" gorm:"type:varchar(255)"`
 	Note      string       `json:"note,omitempty" gorm:"type:text"`
+	ReceiptID *string      `json:"receipt_id,omitempty" gorm:"type:varchar(36);index"` // 签收凭证ID（送达时）
 	CreatedAt time.Time    `json:"created_at"`
 
 	// 关联
@@ -206,9 +216,11 @@ type StatusLog struct {
 type LetterPhoto struct {
 	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
 	LetterID  string    `json:"letter_id" gorm:"type:varchar(36);not null;index"`
-	ImageURL  string    `json:"image_url" gorm:"type:varchar(500);not null"`
-	IsPublic  bool      `json:"is_public" gorm:"default:false"`
-	CreatedAt time.Time `json:"created_at"`
+	ImageURL   string    `json:"image_url" gorm:"type:varchar(500);not null"`
+	FileID     string    `json:"file_id,omitempty" gorm:"type:varchar(36);index"` // 存储文件ID，OCR从此读取原图
+	UploadedBy string    `json:"uploaded_by,omitempty" gorm:"type:varchar(36)"`
+	IsPublic   bool      `json:"is_public" gorm:"default:false"`
+	CreatedAt  time.Time `json:"created_at"`
 
 	// 关联
 	Letter Letter `json:"letter,omitempty" gorm:"foreignKey:LetterID;references:ID;constraint:OnDelete:CASCADE;"`
@@ -220,8 +232,8 @@ type CreateLetterRequest struct {
 	Content         string      `json:"content" binding:"required"`
 	Style           LetterStyle `json:"style" binding:"required"`
 	ReplyTo         string      `json:"reply_to,omitempty"`
-	RecipientOPCode string      `json:"recipient_op_code" binding:"required,len=6"` // PRD要求：必填OP Code
-	SenderOPCode    string      `json:"sender_op_code,omitempty" binding:"omitempty,len=6"`
+	RecipientOPCode string      `json:"recipient_op_code" binding:"required,min=6,max=8"` // PRD要求：必填OP Code，可带校验位
+	SenderOPCode    string      `json:"sender_op_code,omitempty" binding:"omitempty,min=6,max=8"`
 }
 
 // UpdateLetterStatusRequest 更新信件状态请求
@@ -229,6 +241,7 @@ type UpdateLetterStatusRequest struct {
 	Status   LetterStatus `json:"status" binding:"required"`
 	Location string       `json:"location,omitempty"`
 	Note     string       `json:"note,omitempty"`
+	QRToken  string       `json:"qr_token,omitempty"` // 扫码得到的签名令牌或二维码内容
 }
 
 // UpdateLetterRequest 更新信件内容请求
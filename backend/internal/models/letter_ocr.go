@@ -0,0 +1,110 @@
+package models
+
+import "time"
+
+// 手写信件识别任务状态
+const (
+	LetterOCRPending    = "pending"    // 等待识别
+	LetterOCRProcessing = "processing" // 识别中
+	LetterOCRRecognized = "recognized" // 已识别，等待寄信人确认
+	LetterOCRFailed     = "failed"     // 多次识别失败
+	LetterOCRApproved   = "approved"   // 寄信人已确认并写入
+	LetterOCRRejected   = "rejected"   // 寄信人放弃识别结果
+)
+
+// 识别文字写入位置
+const (
+	LetterOCRTargetLetter = "letter" // 信件正文
+	LetterOCRTargetMuseum = "museum" // 博物馆藏品的识别文字
+)
+
+// 写入方式
+const (
+	LetterOCRModeReplace = "replace"
+	LetterOCRModeAppend  = "append"
+)
+
+// OCRPreference 寄信人是否同意对自己的手写信件照片自动识别，默认不识别
+type OCRPreference struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(36)"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (OCRPreference) TableName() string {
+	return "ocr_preferences"
+}
+
+// LetterOCRJob 一张信件照片的识别任务
+type LetterOCRJob struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	LetterID    string     `json:"letter_id" gorm:"type:varchar(36);not null;index"`
+	PhotoID     string     `json:"photo_id" gorm:"type:varchar(36);not null;index"`
+	SenderID    string     `json:"sender_id" gorm:"type:varchar(36);not null;index"` // 信件寄信人，负责确认结果
+	RequestedBy string     `json:"requested_by" gorm:"type:varchar(36)"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	Engine      string     `json:"engine,omitempty" gorm:"size:50"`
+	Text        string     `json:"text,omitempty" gorm:"type:text"`
+	Confidence  float64    `json:"confidence"`
+	Error       string     `json:"error,omitempty" gorm:"size:500"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	// 寄信人确认
+	ReviewedText string     `json:"reviewed_text,omitempty" gorm:"type:text"`
+	Target       string     `json:"target,omitempty" gorm:"type:varchar(20)"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty" gorm:"type:varchar(36)"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Regions []LetterOCRRegion `json:"regions,omitempty" gorm:"foreignKey:JobID;constraint:OnDelete:CASCADE"`
+}
+
+func (LetterOCRJob) TableName() string {
+	return "letter_ocr_jobs"
+}
+
+// LetterOCRRegion 识别出的一段文字及其位置和置信度
+type LetterOCRRegion struct {
+	ID         string  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	JobID      string  `json:"job_id" gorm:"type:varchar(36);not null;index"`
+	Seq        int     `json:"seq"`
+	Text       string  `json:"text" gorm:"type:text"`
+	Confidence float64 `json:"confidence"`
+	X1         int     `json:"x1"`
+	Y1         int     `json:"y1"`
+	X2         int     `json:"x2"`
+	Y2         int     `json:"y2"`
+}
+
+func (LetterOCRRegion) TableName() string {
+	return "letter_ocr_regions"
+}
+
+// UpdateOCRPreferenceRequest 设置是否自动识别
+type UpdateOCRPreferenceRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// AddLetterPhotoRequest 为信件添加照片，图片先通过存储接口上传
+type AddLetterPhotoRequest struct {
+	FileID   string `json:"file_id" binding:"required"`
+	IsPublic bool   `json:"is_public"`
+}
+
+// AddLetterPhotoResponse 添加照片结果，寄信人同意识别时附带识别任务
+type AddLetterPhotoResponse struct {
+	Photo  *LetterPhoto  `json:"photo"`
+	OCRJob *LetterOCRJob `json:"ocr_job,omitempty"`
+}
+
+// ReviewOCRJobRequest 寄信人确认识别结果；text 为空时使用识别原文，写入博物馆藏品时只能使用识别原文
+type ReviewOCRJobRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve reject"`
+	Text   string `json:"text"`
+	Target string `json:"target" binding:"omitempty,oneof=letter museum"`
+	Mode   string `json:"mode" binding:"omitempty,oneof=replace append"`
+}
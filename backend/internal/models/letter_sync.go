@@ -0,0 +1,221 @@
+package models
+
+import "time"
+
+// 信件/任务同步事件类型（与 courier-service 共用）
+const (
+	SyncEventLetterStatusChanged = "letter.status_changed" // 信件状态变化
+	SyncEventLetterTaskAssigned  = "letter.task_assigned"  // 按OP Code分配信使任务
+	SyncEventLetterRerouted      = "letter.rerouted"       // 收件人地址变更，信件改投新的OP Code
+	SyncEventLetterSnapshot      = "letter.snapshot"       // 本服务对账快照
+	SyncEventTaskStatusChanged   = "task.status_changed"   // courier-service 任务状态变化
+	SyncEventTaskSnapshot        = "task.snapshot"         // courier-service 对账快照
+)
+
+// 事件来源
+const (
+	SyncSourceBackend        = "backend"
+	SyncSourceCourierService = "courier-service"
+)
+
+// SyncSchemaVersion 事件格式版本
+const SyncSchemaVersion = 1
+
+// Redis 事件队列，每个方向一个
+const (
+	SyncQueueToBackend        = "openpenpal:sync:to_backend"
+	SyncQueueToCourierService = "openpenpal:sync:to_courier_service"
+)
+
+// 应用失败的事件按次数退避重试，超过上限转入死信队列等待人工处理
+const (
+	SyncRetryToBackend             = "openpenpal:sync:to_backend:retry"    // 待重试事件，score 为重试时间
+	SyncAttemptsToBackend          = "openpenpal:sync:to_backend:attempts" // 各事件已失败次数
+	SyncDeadLetterToBackend        = "openpenpal:sync:to_backend:dead"
+	SyncRetryToCourierService      = "openpenpal:sync:to_courier_service:retry"
+	SyncAttemptsToCourierService   = "openpenpal:sync:to_courier_service:attempts"
+	SyncDeadLetterToCourierService = "openpenpal:sync:to_courier_service:dead"
+)
+
+// 统一生命周期状态，信件状态和信使任务状态都映射到这里再比较
+const (
+	LifecycleCreated   = "created"
+	LifecycleAssigned  = "assigned"
+	LifecycleCollected = "collected"
+	LifecycleInTransit = "in_transit"
+	LifecycleDelivered = "delivered"
+	LifecycleFailed    = "failed"
+	LifecycleReturned  = "returned"
+	LifecycleCanceled  = "canceled"
+)
+
+// 出站事件状态
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+)
+
+// 入站事件处理结果
+const (
+	SyncResultApplied   = "applied"   // 已应用到本地信件
+	SyncResultDuplicate = "duplicate" // 重复投递
+	SyncResultStale     = "stale"     // 早于本地最近一次变化
+	SyncResultIgnored   = "ignored"   // 本地无对应信件
+	SyncResultInSync    = "in_sync"   // 与本地一致
+	SyncResultRejected  = "rejected"  // 送达未引用有效签收凭证，不应用
+)
+
+// 对账差异处理方式
+const (
+	DivergenceRepairedLocal = "repaired_local" // 对方更新，已修复本地
+	DivergenceRepublished   = "republished"    // 本地更新，已重新发布快照让对方修复
+	DivergenceConflict      = "conflict"       // 同一时刻两边状态不同，需人工处理
+	DivergenceRejected      = "rejected"       // 对方报告送达但无有效签收凭证，需人工处理
+)
+
+// SyncEvent 信件/任务生命周期事件
+type SyncEvent struct {
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Source         string    `json:"source"`
+	SchemaVersion  int       `json:"schema_version"`
+	LetterCode     string    `json:"letter_code"`
+	Status         string    `json:"status"`               // 统一生命周期状态
+	CourierID      string    `json:"courier_id,omitempty"` // 信使用户ID
+	PickupOPCode   string    `json:"pickup_op_code,omitempty"`
+	DeliveryOPCode string    `json:"delivery_op_code,omitempty"`
+	CurrentOPCode  string    `json:"current_op_code,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	Note           string    `json:"note,omitempty"`
+	ReceiptID      string    `json:"receipt_id,omitempty"` // 送达时的签收凭证ID
+	OccurredAt     time.Time `json:"occurred_at"`          // 状态实际发生的时间，快照为本地最近一次变化时间
+}
+
+// IsSnapshot 是否为对账快照
+func (e *SyncEvent) IsSnapshot() bool {
+	return e.EventType == SyncEventLetterSnapshot || e.EventType == SyncEventTaskSnapshot
+}
+
+// LetterOutboxEvent 信件出站事件，与信件状态在同一事务中写入
+type LetterOutboxEvent struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"` // 即 event_id
+	EventType   string     `json:"event_type" gorm:"type:varchar(50);not null"`
+	LetterCode  string     `json:"letter_code" gorm:"type:varchar(50);not null;index"`
+	Payload     string     `json:"payload" gorm:"type:text;not null"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// TableName 设置表名
+func (LetterOutboxEvent) TableName() string {
+	return "letter_outbox_events"
+}
+
+// LetterSyncInbox 已处理的 courier-service 事件，按 event_id 去重
+type LetterSyncInbox struct {
+	EventID     string    `json:"event_id" gorm:"primaryKey;type:varchar(36)"`
+	EventType   string    `json:"event_type" gorm:"type:varchar(50)"`
+	Source      string    `json:"source" gorm:"type:varchar(30)"`
+	LetterCode  string    `json:"letter_code" gorm:"type:varchar(50);index"`
+	Result      string    `json:"result" gorm:"type:varchar(20)"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// TableName 设置表名
+func (LetterSyncInbox) TableName() string {
+	return "letter_sync_inbox"
+}
+
+// LetterSyncState 每封信最近一次已知的生命周期状态，按发生时间先后决定是否应用对方事件
+type LetterSyncState struct {
+	LetterCode  string    `json:"letter_code" gorm:"primaryKey;type:varchar(50)"`
+	Status      string    `json:"status" gorm:"type:varchar(20);not null"`
+	LastEventAt time.Time `json:"last_event_at" gorm:"not null"`
+	LastEventID string    `json:"last_event_id" gorm:"type:varchar(36)"`
+	LastSource  string    `json:"last_source" gorm:"type:varchar(30)"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (LetterSyncState) TableName() string {
+	return "letter_sync_states"
+}
+
+// LetterSyncDivergence 对账发现的两边状态差异
+type LetterSyncDivergence struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	LetterCode    string    `json:"letter_code" gorm:"type:varchar(50);not null;index"`
+	LocalStatus   string    `json:"local_status" gorm:"type:varchar(20)"`
+	RemoteStatus  string    `json:"remote_status" gorm:"type:varchar(20)"`
+	RemoteSource  string    `json:"remote_source" gorm:"type:varchar(30)"`
+	LocalEventAt  time.Time `json:"local_event_at"`
+	RemoteEventAt time.Time `json:"remote_event_at"`
+	Resolution    string    `json:"resolution" gorm:"type:varchar(20);index"`
+	DetectedAt    time.Time `json:"detected_at" gorm:"index"`
+}
+
+// TableName 设置表名
+func (LetterSyncDivergence) TableName() string {
+	return "letter_sync_divergences"
+}
+
+// SyncStatusResponse 同步概况
+type SyncStatusResponse struct {
+	PendingOutbox    int64      `json:"pending_outbox"`
+	OldestPendingAt  *time.Time `json:"oldest_pending_at,omitempty"`
+	ProcessedInbox   int64      `json:"processed_inbox"`
+	OpenConflicts    int64      `json:"open_conflicts"`
+	DeadLetters      int64      `json:"dead_letters"`
+	LastReconciledAt *time.Time `json:"last_reconciled_at,omitempty"`
+}
+
+// lifecycleRank 生命周期先后顺序，用于合并信件与任务状态
+var lifecycleRank = map[string]int{
+	LifecycleCreated:   0,
+	LifecycleAssigned:  1,
+	LifecycleCollected: 2,
+	LifecycleInTransit: 3,
+	LifecycleDelivered: 4,
+}
+
+// LetterLifecycleStatus 由信件状态和最新的信使任务得出统一生命周期状态，无法对应时返回空
+// 信件已送达时以送达为准；任务失败且信件未送达时为失败；其余取两者中更靠后的进度
+func LetterLifecycleStatus(letterStatus LetterStatus, task *CourierTask) string {
+	if letterStatus == StatusDelivered || letterStatus == StatusRead {
+		return LifecycleDelivered
+	}
+
+	status := ""
+	switch letterStatus {
+	case StatusCollected:
+		status = LifecycleCollected
+	case StatusInTransit:
+		status = LifecycleInTransit
+	}
+
+	if task == nil {
+		return status
+	}
+
+	taskStatus := ""
+	switch task.Status {
+	case CourierTaskStatusPending:
+		taskStatus = LifecycleAssigned
+	case CourierTaskStatusCollected:
+		taskStatus = LifecycleCollected
+	case CourierTaskStatusInTransit:
+		taskStatus = LifecycleInTransit
+	case CourierTaskStatusDelivered:
+		taskStatus = LifecycleDelivered
+	case CourierTaskStatusFailed:
+		return LifecycleFailed
+	}
+
+	if status == "" || lifecycleRank[taskStatus] > lifecycleRank[status] {
+		return taskStatus
+	}
+	return status
+}
@@ -0,0 +1,160 @@
+package models
+
+import "time"
+
+// 信箱收取轮次状态
+const (
+	CollectionRunScheduled  = "scheduled"   // 待收取
+	CollectionRunInProgress = "in_progress" // 信使已开箱，正在逐封扫码
+	CollectionRunCompleted  = "completed"   // 已清空信箱
+	CollectionRunMissed     = "missed"      // 超过宽限时间无人收取
+)
+
+// 开箱扫码结果
+const (
+	CollectionScanCollected = "collected" // 已收取
+	CollectionScanDuplicate = "duplicate" // 本轮已扫过
+	CollectionScanRejected  = "rejected"  // 信件不存在或状态不符，未收取
+)
+
+// 收取频率建议
+const (
+	CollectionAdviceIncrease = "increase" // 信箱接近装满，应增加收取次数
+	CollectionAdviceDecrease = "decrease" // 多数轮次为空，可减少收取次数
+	CollectionAdviceKeep     = "keep"
+)
+
+// MailboxSchedule 公共信箱收取计划，每个信箱一条
+type MailboxSchedule struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	BoxOPCode   string    `json:"box_op_code" gorm:"type:varchar(6);not null;uniqueIndex"`
+	PickupTimes string    `json:"pickup_times" gorm:"size:200;not null"`              // 每天收取时间，如 08:00,17:30
+	Weekdays    string    `json:"weekdays" gorm:"size:20;default:'1,2,3,4,5,6,7'"`    // 1=周一 … 7=周日
+	CourierID   string    `json:"courier_id,omitempty" gorm:"type:varchar(36);index"` // 负责信使，空则由片区信使领取
+	Capacity    int       `json:"capacity" gorm:"default:50"`                         // 信箱容量（封）
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	CreatedBy   string    `json:"created_by" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (MailboxSchedule) TableName() string {
+	return "mailbox_schedules"
+}
+
+// MailboxCollectionRun 一次信箱收取；按计划生成，或信使临时开箱时创建（无计划ID）
+type MailboxCollectionRun struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ScheduleID  *string    `json:"schedule_id,omitempty" gorm:"type:varchar(36);uniqueIndex:idx_mailbox_run_slot"`
+	BoxOPCode   string     `json:"box_op_code" gorm:"type:varchar(6);not null;index"`
+	CourierID   string     `json:"courier_id,omitempty" gorm:"type:varchar(36);index"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	ScheduledAt time.Time  `json:"scheduled_at" gorm:"uniqueIndex:idx_mailbox_run_slot"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Capacity    int        `json:"capacity"`     // 收取时的信箱容量
+	LetterCount int        `json:"letter_count"` // 本轮收取的信件数
+	Latitude    *float64   `json:"latitude,omitempty"`
+	Longitude   *float64   `json:"longitude,omitempty"`
+	Notes       string     `json:"notes,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Items []MailboxCollectionItem `json:"items,omitempty" gorm:"foreignKey:RunID"`
+}
+
+func (MailboxCollectionRun) TableName() string {
+	return "mailbox_collection_runs"
+}
+
+// FillRate 本轮收取时信箱的装满程度
+func (r *MailboxCollectionRun) FillRate() float64 {
+	if r.Capacity <= 0 {
+		return 0
+	}
+	return float64(r.LetterCount) / float64(r.Capacity)
+}
+
+// MailboxCollectionItem 开箱时扫到的一封信
+type MailboxCollectionItem struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	RunID      string    `json:"run_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_mailbox_run_letter"`
+	LetterCode string    `json:"letter_code" gorm:"type:varchar(50);not null;uniqueIndex:idx_mailbox_run_letter"`
+	LetterID   string    `json:"letter_id,omitempty" gorm:"type:varchar(36);index"`
+	Result     string    `json:"result" gorm:"type:varchar(20);not null"`
+	Reason     string    `json:"reason,omitempty" gorm:"size:200"`
+	ScannedAt  time.Time `json:"scanned_at"`
+}
+
+func (MailboxCollectionItem) TableName() string {
+	return "mailbox_collection_items"
+}
+
+// MailboxScheduleRequest 设置信箱收取计划
+type MailboxScheduleRequest struct {
+	PickupTimes []string `json:"pickup_times" binding:"required,min=1,max=12"` // HH:MM
+	Weekdays    []int    `json:"weekdays" binding:"omitempty,dive,min=1,max=7"`
+	CourierID   string   `json:"courier_id"`
+	Capacity    int      `json:"capacity" binding:"omitempty,min=1,max=1000"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// StartCollectionRunRequest 信使扫描信箱二维码开箱
+type StartCollectionRunRequest struct {
+	BoxOPCode string   `json:"box_op_code" binding:"required"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+}
+
+// CollectionScanItem 一封信的条码，可附带标签上的签名令牌
+type CollectionScanItem struct {
+	Code    string `json:"code" binding:"required"`
+	QRToken string `json:"qr_token"`
+}
+
+// CollectionScanRequest 开箱后批量登记扫到的信件
+type CollectionScanRequest struct {
+	Items []CollectionScanItem `json:"items" binding:"required,min=1,max=200,dive"`
+}
+
+// CollectionScanResult 单封信的扫码结果
+type CollectionScanResult struct {
+	Code   string `json:"code"`
+	Result string `json:"result"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CollectionScanResponse 批量扫码结果
+type CollectionScanResponse struct {
+	RunID       string                 `json:"run_id"`
+	Collected   int                    `json:"collected"`
+	Duplicates  int                    `json:"duplicates"`
+	Rejected    int                    `json:"rejected"`
+	LetterCount int                    `json:"letter_count"` // 本轮累计收取
+	Results     []CollectionScanResult `json:"results"`
+}
+
+// CompleteCollectionRunRequest 结束收取
+type CompleteCollectionRunRequest struct {
+	Notes string `json:"notes"`
+}
+
+// MailboxFillStats 信箱装满程度统计，用于调整收取频率
+type MailboxFillStats struct {
+	BoxOPCode              string  `json:"box_op_code"`
+	PointName              string  `json:"point_name,omitempty"`
+	Capacity               int     `json:"capacity"`
+	Days                   int     `json:"days"`
+	RunsCompleted          int     `json:"runs_completed"`
+	RunsMissed             int     `json:"runs_missed"`
+	EmptyRuns              int     `json:"empty_runs"`
+	TotalLetters           int     `json:"total_letters"`
+	AvgLetters             float64 `json:"avg_letters"`
+	MaxLetters             int     `json:"max_letters"`
+	AvgFillRate            float64 `json:"avg_fill_rate"`
+	PeakFillRate           float64 `json:"peak_fill_rate"`
+	LettersPerDay          float64 `json:"letters_per_day"`
+	PickupsPerDay          float64 `json:"pickups_per_day"` // 当前计划每天收取次数
+	SuggestedPickupsPerDay float64 `json:"suggested_pickups_per_day"`
+	Advice                 string  `json:"advice"`
+}
@@ -46,6 +46,7 @@ type MuseumItem struct {
 
 	// OP Code System Integration - 地理位置标记
 	OriginOPCode string    `json:"origin_op_code,omitempty" gorm:"type:varchar(6);index"` // 来源地理位置OP Code
+	Transcript   string    `json:"transcript,omitempty" gorm:"type:text"`                 // 手写信件经寄信人确认的识别文字
 	CreatedAt    time.Time `json:"createdAt" gorm:"column:created_at"`
 	UpdatedAt    time.Time `json:"updatedAt" gorm:"column:updated_at"`
 
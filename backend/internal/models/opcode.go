@@ -25,6 +25,15 @@ type OPCode struct {
 	IsPublic    bool   `json:"is_public" gorm:"default:false"`     // 后两位是否公开
 	IsActive    bool   `json:"is_active" gorm:"default:true"`      // 是否激活
 
+	// 校验位：新签发的编码必须以带校验位的7位格式书写，存量编码在过渡期内两种格式均可
+	CheckRequired bool   `json:"check_required" gorm:"default:false"`
+	CheckedCode   string `json:"checked_code" gorm:"-"` // 带校验位的书写格式，查询后填充
+
+	// 地理位置（投递地理围栏校验）
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	GeofenceRadius int      `json:"geofence_radius" gorm:"default:0"` // 围栏半径（米），0表示使用默认值
+
 	// 绑定信息
 	BindingType   string  `json:"binding_type" gorm:"size:20"`             // 绑定类型: user/shop/public
 	BindingID     *string `json:"binding_id,omitempty"`                    // 绑定对象ID
@@ -56,16 +65,17 @@ type SignalCode = OPCode
 
 // OPCodeSchool 学校编码映射表
 type OPCodeSchool struct {
-	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	SchoolCode string    `json:"school_code" gorm:"unique;not null;size:2"` // 2位学校代码
-	SchoolName string    `json:"school_name" gorm:"not null;size:100"`
-	FullName   string    `json:"full_name" gorm:"size:200"`
-	City       string    `json:"city" gorm:"size:50"`
-	Province   string    `json:"province" gorm:"size:50"`
-	IsActive   bool      `json:"is_active" gorm:"default:true"`
-	ManagedBy  string    `json:"managed_by"` // 四级信使ID
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID           string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SchoolCode   string    `json:"school_code" gorm:"unique;not null;size:2"` // 2位学校代码
+	SchoolName   string    `json:"school_name" gorm:"not null;size:100"`
+	FullName     string    `json:"full_name" gorm:"size:200"`
+	City         string    `json:"city" gorm:"size:50"`
+	Province     string    `json:"province" gorm:"size:50"`
+	EmailDomains string    `json:"email_domains" gorm:"type:text"` // 在校身份认证邮箱域名，逗号分隔，如 pku.edu.cn,stu.pku.edu.cn
+	IsActive     bool      `json:"is_active" gorm:"default:true"`
+	ManagedBy    string    `json:"managed_by"` // 四级信使ID
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // OPCodeArea 片区编码映射表
@@ -80,6 +90,15 @@ type OPCodeArea struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
+	// 片区边界（GeoJSON Polygon/MultiPolygon），外包框用于空间查询预筛
+	Boundary  string   `json:"-" gorm:"type:text"`
+	MinLat    float64  `json:"-" gorm:"index:idx_op_code_area_bbox"`
+	MinLng    float64  `json:"-" gorm:"index:idx_op_code_area_bbox"`
+	MaxLat    float64  `json:"-"`
+	MaxLng    float64  `json:"-"`
+	CenterLat *float64 `json:"center_lat,omitempty"`
+	CenterLng *float64 `json:"center_lng,omitempty"`
+
 	// 联合唯一索引
 	UniqueIndex string `gorm:"uniqueIndex:idx_school_area,unique"`
 }
@@ -147,6 +166,12 @@ func (OPCode) TableName() string {
 	return "op_codes"
 }
 
+// AfterFind 填充带校验位的书写格式
+func (o *OPCode) AfterFind(tx *gorm.DB) error {
+	o.CheckedCode = WithOPCodeCheckChar(o.Code)
+	return nil
+}
+
 func (OPCodeSchool) TableName() string {
 	return "op_code_schools"
 }
@@ -254,6 +279,13 @@ type OPCodeAssignRequest struct {
 	Comment       string `json:"comment"`
 }
 
+// OPCodeLocationRequest 设置投递点坐标与地理围栏请求
+type OPCodeLocationRequest struct {
+	Latitude       *float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude      *float64 `json:"longitude" binding:"required,min=-180,max=180"`
+	GeofenceRadius int      `json:"geofence_radius" binding:"omitempty,min=10,max=2000"` // 米，不填使用默认值
+}
+
 // OPCodeSearchRequest OP Code搜索请求
 type OPCodeSearchRequest struct {
 	Code       string `form:"code"`
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// OP Code 校验位
+//
+// 采用 ISO 7064 MOD 37,36：校验位与编码同为 0-9A-Z，可检出全部单字符错误和相邻字符颠倒。
+// 带校验位的书写格式为7位，如 PK5F3D 写作 PK5F3D-? （连字符可省略），数据库中仍只存6位编码。
+
+const opCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var (
+	ErrOPCodeCheckMismatch = errors.New("OP Code校验位不正确，可能有字符输入错误")
+	ErrOPCodeCheckRequired = errors.New("该OP Code需填写带校验位的7位格式")
+)
+
+// OP Code 解析结果原因
+const (
+	OPCodeReasonInvalidFormat = "invalid_format" // 长度或字符不合法
+	OPCodeReasonCheckMismatch = "check_mismatch" // 校验位不符
+	OPCodeReasonCheckRequired = "check_required" // 需使用带校验位格式
+	OPCodeReasonNotFound      = "not_found"      // 编码不存在或已停用
+)
+
+// OPCodeCheckChar 计算6位OP Code的校验位
+func OPCodeCheckChar(code string) (byte, error) {
+	if err := ValidateOPCode(code); err != nil {
+		return 0, err
+	}
+
+	p := 36
+	for _, ch := range strings.ToUpper(code) {
+		s := (p + strings.IndexRune(opCodeAlphabet, ch)) % 36
+		if s == 0 {
+			s = 36
+		}
+		p = s * 2 % 37
+	}
+	return opCodeAlphabet[(37-p)%36], nil
+}
+
+// WithOPCodeCheckChar 返回带校验位的7位书写格式，编码不合法时原样返回
+func WithOPCodeCheckChar(code string) string {
+	check, err := OPCodeCheckChar(code)
+	if err != nil {
+		return code
+	}
+	return strings.ToUpper(code) + string(check)
+}
+
+// CleanOPCodeInput 统一用户输入：转大写，去掉空格和连字符
+func CleanOPCodeInput(input string) string {
+	input = strings.ToUpper(strings.TrimSpace(input))
+	return strings.NewReplacer("-", "", " ", "").Replace(input)
+}
+
+// NormalizeOPCodeInput 解析6位（旧格式）或7位（带校验位）输入，返回6位编码及是否经过校验
+func NormalizeOPCodeInput(input string) (code string, checked bool, err error) {
+	cleaned := CleanOPCodeInput(input)
+	switch len(cleaned) {
+	case 6:
+		if err := ValidateOPCode(cleaned); err != nil {
+			return "", false, err
+		}
+		return cleaned, false, nil
+	case 7:
+		code = cleaned[:6]
+		check, err := OPCodeCheckChar(code)
+		if err != nil {
+			return "", false, err
+		}
+		if cleaned[6] != check {
+			return code, false, ErrOPCodeCheckMismatch
+		}
+		return code, true, nil
+	default:
+		return "", false, ValidateOPCode(cleaned)
+	}
+}
+
+// OPCodeSuggestion “您是不是要找”的候选编码
+type OPCodeSuggestion struct {
+	Code        string  `json:"code"`
+	CheckedCode string  `json:"checked_code"`
+	PointName   string  `json:"point_name,omitempty"` // 仅公开点位返回名称
+	AreaName    string  `json:"area_name,omitempty"`
+	SameArea    bool    `json:"same_area"` // 与输入同一片区/楼栋
+	Distance    float64 `json:"distance"`  // 编辑距离，形近字符按半个计
+}
+
+// OPCodeResolution OP Code输入的解析结果
+type OPCodeResolution struct {
+	Input       string             `json:"input"`
+	Code        string             `json:"code,omitempty"`         // 规范化后的6位编码
+	CheckedCode string             `json:"checked_code,omitempty"` // 带校验位的7位格式
+	Checked     bool               `json:"checked"`                // 输入是否带有正确的校验位
+	Valid       bool               `json:"valid"`
+	Reason      string             `json:"reason,omitempty"`
+	Message     string             `json:"message,omitempty"`
+	Suggestions []OPCodeSuggestion `json:"suggestions,omitempty"`
+}
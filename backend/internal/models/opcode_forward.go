@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// 转寄规则状态
+const (
+	OPCodeForwardStatusActive    = "active"
+	OPCodeForwardStatusCancelled = "cancelled"
+)
+
+// OPCodeForward OP Code转寄规则：住户搬离后，寄往旧编码的信件在有效期内改投新编码
+type OPCodeForward struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID        string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	FromCode      string     `json:"from_code" gorm:"type:varchar(6);not null;index"`
+	ToCode        string     `json:"to_code" gorm:"type:varchar(6);not null"`
+	NotifySenders bool       `json:"notify_senders" gorm:"default:false"` // 是否告知寄件人地址已变更（不透露新编码）
+	Status        string     `json:"status" gorm:"type:varchar(20);not null;default:'active';index"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"index"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+	RerouteCount  int        `json:"reroute_count" gorm:"default:0"` // 已转寄的信件数
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (OPCodeForward) TableName() string {
+	return "op_code_forwards"
+}
+
+// IsEffective 规则在指定时间是否生效
+func (f *OPCodeForward) IsEffective(at time.Time) bool {
+	return f.Status == OPCodeForwardStatusActive && at.Before(f.ExpiresAt)
+}
+
+// LetterReroute 信件转寄记录，在途信件会关闭原投递段并新建一段
+type LetterReroute struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	LetterID       string    `json:"letter_id" gorm:"type:varchar(36);not null;index"`
+	LetterCode     string    `json:"letter_code,omitempty" gorm:"type:varchar(50);index"`
+	ForwardID      string    `json:"forward_id" gorm:"type:varchar(36);not null;index"`
+	FromCode       string    `json:"from_code" gorm:"type:varchar(6);not null"`
+	ToCode         string    `json:"to_code" gorm:"type:varchar(6);not null"`
+	PreviousTaskID string    `json:"previous_task_id,omitempty" gorm:"type:varchar(36)"` // 被关闭的投递段
+	TaskID         string    `json:"task_id,omitempty" gorm:"type:varchar(36)"`          // 新的投递段
+	LetterStatus   string    `json:"letter_status" gorm:"type:varchar(20)"`              // 转寄时信件所处状态
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (LetterReroute) TableName() string {
+	return "letter_reroutes"
+}
+
+// DeliveryTarget 信件实际投递的OP Code，未转寄时即收件人OP Code
+func (l *Letter) DeliveryTarget() string {
+	if l.DeliveryOPCode != "" {
+		return l.DeliveryOPCode
+	}
+	return l.RecipientOPCode
+}
+
+// CreateOPCodeForwardRequest 登记转寄请求，有效期默认 180 天
+type CreateOPCodeForwardRequest struct {
+	FromCode      string     `json:"from_code" binding:"required,min=6,max=8"`
+	ToCode        string     `json:"to_code" binding:"required,min=6,max=8"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	NotifySenders bool       `json:"notify_senders"`
+}
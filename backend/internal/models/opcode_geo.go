@@ -0,0 +1,59 @@
+package models
+
+// GeoJSON 要素属性约定：
+//   - 片区：Polygon/MultiPolygon，properties.area 为4位片区前缀（如 PK5F），或分别给出 school_code 与 area_code；新建片区时 name 必填
+//   - 投递点：Point，properties.op_code 为6位编码，可选 geofence_radius（米）
+const (
+	GeoPropertyArea           = "area"
+	GeoPropertySchoolCode     = "school_code"
+	GeoPropertyAreaCode       = "area_code"
+	GeoPropertyName           = "name"
+	GeoPropertyOPCode         = "op_code"
+	GeoPropertyPointType      = "point_type"
+	GeoPropertyGeofenceRadius = "geofence_radius"
+	GeoPropertyKind           = "kind" // 导出时标注 area 或 point
+)
+
+// OPCodeGeoImportIssue 导入时被跳过的要素
+type OPCodeGeoImportIssue struct {
+	Feature int    `json:"feature"` // 要素序号，从0开始
+	Ref     string `json:"ref,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// OPCodeGeoImportResult GeoJSON 导入结果
+type OPCodeGeoImportResult struct {
+	DryRun        bool                   `json:"dry_run"`
+	AreasCreated  int                    `json:"areas_created"`
+	AreasUpdated  int                    `json:"areas_updated"`
+	PointsUpdated int                    `json:"points_updated"`
+	Issues        []OPCodeGeoImportIssue `json:"issues"`
+}
+
+// OPCodeAreaLocation 坐标所在的片区
+type OPCodeAreaLocation struct {
+	Prefix     string `json:"prefix"` // 4位片区前缀
+	SchoolCode string `json:"school_code"`
+	AreaCode   string `json:"area_code"`
+	AreaName   string `json:"area_name"`
+}
+
+// OPCodeNearby 附近的投递点
+type OPCodeNearby struct {
+	Code           string  `json:"code"`
+	PointType      string  `json:"point_type"`
+	PointName      string  `json:"point_name,omitempty"` // 仅公开点位返回名称
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// OPCodeNearestRequest 附近投递点查询
+type OPCodeNearestRequest struct {
+	Latitude   *float64 `form:"lat" binding:"required,min=-90,max=90"`
+	Longitude  *float64 `form:"lng" binding:"required,min=-180,max=180"`
+	Radius     float64  `form:"radius" binding:"omitempty,min=1,max=20000"` // 米，默认 1000
+	Limit      int      `form:"limit" binding:"omitempty,min=1,max=50"`     // 默认 10
+	PointType  string   `form:"point_type"`
+	SchoolCode string   `form:"school_code"`
+}
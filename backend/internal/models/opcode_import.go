@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// 批量导入状态
+const (
+	OPCodeImportStatusDryRun     = "dry_run"     // 仅校验
+	OPCodeImportStatusRejected   = "rejected"    // 校验未通过，未写入
+	OPCodeImportStatusApplied    = "applied"     // 已写入
+	OPCodeImportStatusRolledBack = "rolled_back" // 已按导入批次回滚
+)
+
+// 导入问题级别：error 阻止写入，warning 仅提示
+const (
+	OPCodeImportSeverityError   = "error"
+	OPCodeImportSeverityWarning = "warning"
+)
+
+// 导入问题类型
+const (
+	OPCodeImportIssueInvalid    = "invalid"            // 格式错误
+	OPCodeImportIssueDuplicate  = "duplicate"          // 文件内重复
+	OPCodeImportIssueConflict   = "hierarchy_conflict" // 与上级或已有数据不一致
+	OPCodeImportIssueReserved   = "reserved"           // 保留或已停用的编码
+	OPCodeImportIssueExists     = "exists"             // 已存在且内容相同，跳过
+	OPCodeImportIssuePermission = "permission"         // 超出操作人的管理范围
+)
+
+// 导入记录的层级
+const (
+	OPCodeImportKindSchool = "school"
+	OPCodeImportKindArea   = "area"
+	OPCodeImportKindPoint  = "point"
+)
+
+// OPCodeImport 学校/片区/点位批量导入批次
+type OPCodeImport struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	OperatorID     string     `json:"operator_id" gorm:"type:varchar(36);not null;index"`
+	FileName       string     `json:"file_name" gorm:"size:255"`
+	Format         string     `json:"format" gorm:"size:10"`
+	Status         string     `json:"status" gorm:"size:20;not null;index"`
+	TotalRows      int        `json:"total_rows"`
+	SchoolsCreated int        `json:"schools_created"`
+	AreasCreated   int        `json:"areas_created"`
+	PointsCreated  int        `json:"points_created"`
+	ErrorCount     int        `json:"error_count"`
+	WarningCount   int        `json:"warning_count"`
+	Report         string     `json:"-" gorm:"type:text"` // 问题列表 JSON
+	AppliedAt      *time.Time `json:"applied_at,omitempty"`
+	RolledBackAt   *time.Time `json:"rolled_back_at,omitempty"`
+	RolledBackBy   string     `json:"rolled_back_by,omitempty" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Issues []OPCodeImportIssue `json:"issues,omitempty" gorm:"-"`
+}
+
+func (OPCodeImport) TableName() string {
+	return "op_code_imports"
+}
+
+// OPCodeImportItem 导入批次创建的记录，用于回滚
+type OPCodeImportItem struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ImportID  string    `json:"import_id" gorm:"type:varchar(36);not null;index"`
+	Kind      string    `json:"kind" gorm:"size:10;not null"`
+	RecordID  string    `json:"record_id" gorm:"type:varchar(36);not null"`
+	Code      string    `json:"code" gorm:"size:6;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (OPCodeImportItem) TableName() string {
+	return "op_code_import_items"
+}
+
+// OPCodeImportIssue 校验报告中的一条问题，Row 为表格行号（表头为第1行）
+type OPCodeImportIssue struct {
+	Row      int    `json:"row"`
+	Code     string `json:"code,omitempty"`
+	Severity string `json:"severity"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// 签收确认方式
+const (
+	DeliveryConfirmByCode = "code" // 收件人出示6位确认码
+	DeliveryConfirmByQR   = "qr"   // 信使扫描收件人出示的二维码
+)
+
+// 地理围栏校验结果
+const (
+	GeofenceInside      = "inside"      // 在投递点范围内
+	GeofenceOutside     = "outside"     // 超出投递点范围
+	GeofenceUnavailable = "unavailable" // 投递点未设置坐标
+)
+
+// DeliveryConfirmation 收件人签收确认码（一次性）
+type DeliveryConfirmation struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	LetterID    string     `json:"letter_id" gorm:"type:varchar(36);not null;index"`
+	RecipientID string     `json:"recipient_id" gorm:"type:varchar(36);not null;index"`
+	CodeHash    string     `json:"-" gorm:"type:varchar(64);not null"`
+	TokenHash   string     `json:"-" gorm:"type:varchar(64);not null"` // 二维码令牌哈希
+	Attempts    int        `json:"-" gorm:"default:0"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (DeliveryConfirmation) TableName() string {
+	return "delivery_confirmations"
+}
+
+// DeliveryReceipt 签收凭证，送达时生成并由服务端签名，挂在送达的状态日志上
+type DeliveryReceipt struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	LetterID       string    `json:"letter_id" gorm:"type:varchar(36);not null;uniqueIndex"`
+	LetterCode     string    `json:"letter_code" gorm:"type:varchar(50);not null"`
+	CourierID      string    `json:"courier_id" gorm:"type:varchar(36);not null;index"`
+	RecipientID    string    `json:"recipient_id" gorm:"type:varchar(36);not null;index"`
+	ConfirmationID string    `json:"confirmation_id" gorm:"type:varchar(36);not null"`
+	ConfirmMethod  string    `json:"confirm_method" gorm:"type:varchar(10);not null"`
+	PhotoFileID    *string   `json:"photo_file_id,omitempty" gorm:"type:varchar(36)"`
+	PhotoHash      string    `json:"photo_hash,omitempty" gorm:"type:varchar(64)"` // 照片SHA256
+	TargetOPCode   string    `json:"target_op_code" gorm:"type:varchar(6)"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	GeofenceResult string    `json:"geofence_result" gorm:"type:varchar(20);not null"`
+	DistanceMeters *float64  `json:"distance_meters,omitempty"` // 距投递点距离
+	DeliveredAt    time.Time `json:"delivered_at"`
+	Signature      string    `json:"signature" gorm:"type:varchar(64);not null"` // HMAC-SHA256
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName 设置表名
+func (DeliveryReceipt) TableName() string {
+	return "delivery_receipts"
+}
+
+// ConfirmDeliveryRequest 信使当面签收请求，确认码与二维码二选一
+type ConfirmDeliveryRequest struct {
+	ConfirmationCode string   `json:"confirmation_code" binding:"omitempty,len=6"`
+	QRPayload        string   `json:"qr_payload"`
+	Latitude         *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude        *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	PhotoFileID      string   `json:"photo_file_id"` // 通过存储服务上传的照片ID
+	Location         string   `json:"location"`
+	Note             string   `json:"note"`
+	QRToken          string   `json:"qr_token"` // 信封二维码中的签名令牌，可直接传扫码内容
+}
+
+// DeliveryConfirmationResponse 收件人签收确认码
+type DeliveryConfirmationResponse struct {
+	ID        string    `json:"id"`
+	LetterID  string    `json:"letter_id"`
+	Code      string    `json:"code"`
+	QRPayload string    `json:"qr_payload"`
+	QRCode    string    `json:"qr_code"` // data URL格式的PNG
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DeliveryReceiptResponse 签收凭证及签名校验结果
+type DeliveryReceiptResponse struct {
+	Receipt        *DeliveryReceipt `json:"receipt"`
+	SignatureValid bool             `json:"signature_valid"`
+}
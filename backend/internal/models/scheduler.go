@@ -19,6 +19,7 @@ const (
 	TaskTypeBackupDatabase      TaskType = "backup_database"      // 数据库备份
 	TaskTypeImageOptimization   TaskType = "image_optimization"   // 图片优化
 	TaskTypeStatisticsUpdate    TaskType = "statistics_update"    // 统计数据更新
+	TaskTypeDataErasure         TaskType = "data_erasure"         // 账户删除请求执行
 )
 
 // SchedulerTaskStatus 定时任务状态
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SchoolVerificationStatus 在校身份认证状态
+type SchoolVerificationStatus string
+
+const (
+	SchoolVerificationPending  SchoolVerificationStatus = "pending"  // 验证码已发送，等待确认
+	SchoolVerificationVerified SchoolVerificationStatus = "verified" // 已认证，学期结束前有效
+	SchoolVerificationExpired  SchoolVerificationStatus = "expired"  // 学期结束，需重新认证
+	SchoolVerificationRevoked  SchoolVerificationStatus = "revoked"  // 被新认证取代或管理员撤销
+)
+
+// SchoolVerification 用户在校身份认证记录（通过学校邮箱验证码）
+type SchoolVerification struct {
+	ID            string                   `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID        string                   `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	SchoolCode    string                   `json:"school_code" gorm:"size:2;not null;index"`                                                                                    // OPCodeSchool 2位学校代码
+	Email         string                   `json:"email" gorm:"type:varchar(255);not null;index;uniqueIndex:idx_school_verifications_verified_email,where:status = 'verified'"` // 同一邮箱只能有一条已认证记录
+	Status        SchoolVerificationStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	CodeHash      string                   `json:"-" gorm:"type:varchar(64)"`
+	Attempts      int                      `json:"-" gorm:"default:0"`
+	CodeExpiresAt time.Time                `json:"code_expires_at"`
+	VerifiedAt    *time.Time               `json:"verified_at,omitempty"`
+	ExpiresAt     *time.Time               `json:"expires_at,omitempty"` // 认证有效期至当前学期结束
+	RevokedReason string                   `json:"revoked_reason,omitempty" gorm:"type:varchar(255)"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+
+	School *OPCodeSchool `json:"school,omitempty" gorm:"foreignKey:SchoolCode;references:SchoolCode"`
+}
+
+// TableName 设置表名
+func (SchoolVerification) TableName() string {
+	return "school_verifications"
+}
+
+// IsActive 检查认证当前是否有效
+func (v *SchoolVerification) IsActive() bool {
+	return v.Status == SchoolVerificationVerified && v.ExpiresAt != nil && time.Now().Before(*v.ExpiresAt)
+}
+
+// AllowedEmailDomains 解析学校允许的认证邮箱域名
+func (s *OPCodeSchool) AllowedEmailDomains() []string {
+	var domains []string
+	for _, domain := range strings.Split(s.EmailDomains, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains = append(domains, strings.TrimPrefix(domain, "@"))
+		}
+	}
+	return domains
+}
+
+// AllowsEmail 检查邮箱是否属于学校域名（含子域名）
+func (s *OPCodeSchool) AllowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return false
+	}
+	host := strings.ToLower(email[at+1:])
+	for _, domain := range s.AllowedEmailDomains() {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartSchoolVerificationRequest 申请在校身份认证请求
+type StartSchoolVerificationRequest struct {
+	SchoolCode string `json:"school_code" binding:"required,len=2"`
+	Email      string `json:"email" binding:"required,email"`
+}
+
+// ConfirmSchoolVerificationRequest 提交邮箱验证码请求
+type ConfirmSchoolVerificationRequest struct {
+	VerificationID string `json:"verification_id" binding:"required"`
+	Code           string `json:"code" binding:"required,len=6"`
+}
+
+// UpdateSchoolEmailDomainsRequest 设置学校认证邮箱域名请求
+type UpdateSchoolEmailDomainsRequest struct {
+	EmailDomains []string `json:"email_domains" binding:"required"`
+}
+
+// RevokeSchoolVerificationRequest 撤销在校身份认证请求
+type RevokeSchoolVerificationRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// SchoolVerificationQuery 管理员查询认证记录
+type SchoolVerificationQuery struct {
+	SchoolCode string                   `form:"school_code"`
+	UserID     string                   `form:"user_id"`
+	Status     SchoolVerificationStatus `form:"status"`
+	Page       int                      `form:"page,default=1"`
+	Limit      int                      `form:"limit,default=20"`
+}
+
+// SchoolVerificationStatusResponse 当前在校身份认证状态
+type SchoolVerificationStatusResponse struct {
+	Verified     bool                `json:"verified"`
+	Verification *SchoolVerification `json:"verification,omitempty"`
+	Pending      *SchoolVerification `json:"pending,omitempty"`
+}
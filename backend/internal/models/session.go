@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSession 用户登录会话（绑定设备）
+type UserSession struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID        string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	DeviceID      string     `json:"device_id" gorm:"type:varchar(100);index"` // 客户端生成的设备标识
+	DeviceName    string     `json:"device_name" gorm:"type:varchar(100)"`
+	UserAgent     string     `json:"user_agent" gorm:"type:varchar(500)"`
+	IPAddress     string     `json:"ip_address" gorm:"type:varchar(45)"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"index"` // 会话过期时间，每次刷新顺延
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+	RevokedReason string     `json:"revoked_reason,omitempty" gorm:"type:varchar(50)"` // logout, logout_all, password_changed, token_reuse, revoked
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// IsActive 检查会话是否有效
+func (s *UserSession) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// RefreshToken 刷新令牌（仅存储哈希，每次使用后轮换）
+type RefreshToken struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SessionID string     `json:"session_id" gorm:"type:varchar(36);not null;index"`
+	UserID    string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"` // SHA256(token)
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // 已轮换；再次出现即视为令牌被盗用
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// SessionDeviceInfo 登录设备信息
+type SessionDeviceInfo struct {
+	DeviceID   string
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	DeviceID     string `json:"device_id"`
+}
+
+// SessionInfo 会话列表项
+type SessionInfo struct {
+	UserSession
+	IsCurrent bool `json:"is_current"`
+}
+
+// SessionTokens 会话令牌对
+type SessionTokens struct {
+	SessionID        string    `json:"session_id"`
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
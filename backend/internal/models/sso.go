@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// IdentityProviderType 外部身份提供方类型
+type IdentityProviderType string
+
+const (
+	IdentityProviderOIDC IdentityProviderType = "oidc" // OpenID Connect（授权码 + PKCE）
+	IdentityProviderCAS  IdentityProviderType = "cas"  // CAS 3.0 票据校验
+)
+
+// IdentityProvider 校园统一身份认证配置
+type IdentityProvider struct {
+	ID   string               `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Slug string               `json:"slug" gorm:"type:varchar(50);uniqueIndex;not null"` // 登录地址中使用的标识，如 pku-cas
+	Name string               `json:"name" gorm:"type:varchar(100);not null"`
+	Type IdentityProviderType `json:"type" gorm:"type:varchar(10);not null"`
+
+	// OIDC：仅配置Issuer时通过 .well-known/openid-configuration 自动发现端点
+	Issuer                string `json:"issuer" gorm:"type:varchar(255)"`
+	ClientID              string `json:"client_id" gorm:"type:varchar(255)"`
+	ClientSecret          string `json:"client_secret,omitempty" gorm:"type:varchar(255)"`
+	AuthorizationEndpoint string `json:"authorization_endpoint" gorm:"type:varchar(255)"`
+	TokenEndpoint         string `json:"token_endpoint" gorm:"type:varchar(255)"`
+	JWKSURI               string `json:"jwks_uri" gorm:"type:varchar(255)"`
+	Scopes                string `json:"scopes" gorm:"type:varchar(255)"` // 空格分隔，默认 openid email profile
+
+	// CAS
+	CASBaseURL string `json:"cas_base_url" gorm:"type:varchar(255)"`
+
+	// 身份属性映射
+	SchoolCode    string         `json:"school_code" gorm:"type:varchar(20)"`   // 默认学校代码
+	SchoolClaim   string         `json:"school_claim" gorm:"type:varchar(100)"` // 携带学校/院系信息的claim或CAS属性名
+	SchoolMapping datatypes.JSON `json:"school_mapping" gorm:"type:jsonb"`      // claim值 -> 学校代码
+	AutoProvision bool           `json:"auto_provision" gorm:"default:false"`   // 首次登录自动创建账号
+	LinkByEmail   bool           `json:"link_by_email" gorm:"default:false"`    // 邮箱已验证时自动关联同邮箱的本地账号
+
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (IdentityProvider) TableName() string {
+	return "identity_providers"
+}
+
+// UserIdentity 本地账号与外部身份的关联
+type UserIdentity struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID          string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	ProviderID      string     `json:"provider_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject         string     `json:"subject" gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject"`
+	Email           string     `json:"email" gorm:"type:varchar(255)"`
+	AutoProvisioned bool       `json:"auto_provisioned" gorm:"default:false"` // 账号由该身份自动创建（无本地密码）
+	LastLoginAt     *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	Provider *IdentityProvider `json:"provider,omitempty" gorm:"foreignKey:ProviderID"`
+}
+
+// TableName 设置表名
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// SSOLoginState 单点登录跳转状态（state参数），一次性使用
+type SSOLoginState struct {
+	ID           string     `json:"id" gorm:"primaryKey;type:varchar(64)"` // 即 state 参数
+	ProviderID   string     `json:"provider_id" gorm:"type:varchar(36);not null"`
+	CodeVerifier string     `json:"-" gorm:"type:varchar(128)"` // PKCE
+	Nonce        string     `json:"-" gorm:"type:varchar(64)"`
+	RedirectURI  string     `json:"redirect_uri" gorm:"type:varchar(500);not null"`
+	LinkUserID   string     `json:"-" gorm:"type:varchar(36)"` // 非空表示已登录用户关联外部身份
+	Reauth       bool       `json:"-" gorm:"default:false"`    // 敏感操作前的重新认证，要求在身份提供方重新输入凭据
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (SSOLoginState) TableName() string {
+	return "sso_login_states"
+}
+
+// ExternalIdentity 外部身份提供方返回的用户身份
+type ExternalIdentity struct {
+	Subject       string                 `json:"subject"`
+	Email         string                 `json:"email"`
+	EmailVerified bool                   `json:"email_verified"`
+	Name          string                 `json:"name"`
+	Username      string                 `json:"username"`
+	Attributes    map[string]interface{} `json:"attributes"`
+}
+
+// SSOCallbackRequest 单点登录回调请求（前端回调页转发）
+type SSOCallbackRequest struct {
+	State  string `json:"state" binding:"required"`
+	Code   string `json:"code"`   // OIDC授权码
+	Ticket string `json:"ticket"` // CAS票据
+
+	// 登录设备信息，与密码登录一致
+	DeviceID           string `json:"device_id"`
+	DeviceName         string `json:"device_name"`
+	TrustedDeviceToken string `json:"trusted_device_token"`
+}
+
+// SSOAuthorizeResponse 单点登录跳转信息
+type SSOAuthorizeResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+)
+
+// UserTwoFactor 用户两步验证（TOTP）配置
+type UserTwoFactor struct {
+	UserID          string     `json:"user_id" gorm:"primaryKey;type:varchar(36)"`
+	SecretEncrypted string     `json:"-" gorm:"type:varchar(255);not null"` // AES-GCM加密后的TOTP密钥
+	SecretKeyID     string     `json:"-" gorm:"type:varchar(32)"`           // 加密所用密钥ID，为空表示早期由JWT密钥派生的密钥
+	EnabledAt       *time.Time `json:"enabled_at,omitempty"`                // 为空表示已生成密钥但尚未确认绑定
+	LastUsedStep    int64      `json:"-" gorm:"default:0"`                  // 最近一次通过验证的时间窗口，防止验证码重放
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (UserTwoFactor) TableName() string {
+	return "user_two_factors"
+}
+
+// IsEnabled 检查两步验证是否已启用
+func (t *UserTwoFactor) IsEnabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorRecoveryCode 两步验证恢复码（一次性）
+type TwoFactorRecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID    string     `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (TwoFactorRecoveryCode) TableName() string {
+	return "two_factor_recovery_codes"
+}
+
+// TrustedDevice 已记住的设备，在有效期内登录免两步验证
+type TrustedDevice struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID     string    `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	TokenHash  string    `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	DeviceName string    `json:"device_name" gorm:"type:varchar(100)"`
+	IPAddress  string    `json:"ip_address" gorm:"type:varchar(45)"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName 设置表名
+func (TrustedDevice) TableName() string {
+	return "trusted_devices"
+}
+
+// LoginChallengeType 登录挑战类型
+type LoginChallengeType string
+
+const (
+	LoginChallengeVerify LoginChallengeType = "verify" // 已启用两步验证，需输入验证码
+	LoginChallengeSetup  LoginChallengeType = "setup"  // 角色强制要求两步验证但尚未绑定，需先完成绑定
+)
+
+// LoginChallenge 密码验证通过后的第二步登录挑战
+type LoginChallenge struct {
+	ID         string             `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID     string             `json:"user_id" gorm:"type:varchar(36);not null;index"`
+	Type       LoginChallengeType `json:"type" gorm:"type:varchar(20);not null"`
+	Attempts   int                `json:"attempts" gorm:"default:0"`
+	DeviceID   string             `json:"-" gorm:"type:varchar(100)"`
+	DeviceName string             `json:"-" gorm:"type:varchar(100)"`
+	UserAgent  string             `json:"-" gorm:"type:varchar(500)"`
+	IPAddress  string             `json:"-" gorm:"type:varchar(45)"`
+	ExpiresAt  time.Time          `json:"expires_at"`
+	UsedAt     *time.Time         `json:"used_at,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// TableName 设置表名
+func (LoginChallenge) TableName() string {
+	return "login_challenges"
+}
+
+// TwoFactorEnrollment 两步验证绑定信息
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          string `json:"qr_code"` // data:image/png;base64
+}
+
+// TwoFactorStatus 两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"` // 当前角色是否强制要求
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+	TrustedDevices         int64      `json:"trusted_devices"`
+}
+
+// TwoFactorCodeRequest 提交验证码请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证请求
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"` // 自动创建的校园账号没有本地密码，仅凭验证码
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginChallengeRequest 完成登录挑战请求
+type LoginChallengeRequest struct {
+	ChallengeID    string `json:"challenge_id" binding:"required"`
+	Code           string `json:"code"`          // TOTP验证码
+	RecoveryCode   string `json:"recovery_code"` // 或一次性恢复码
+	RememberDevice bool   `json:"remember_device"`
+}
+
+// LoginChallengeSetupRequest 获取登录挑战绑定信息请求
+type LoginChallengeSetupRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+}
@@ -171,6 +171,7 @@ type User struct {
 	SchoolCode   string         `json:"school_code" gorm:"type:varchar(20);index"`
 	OPCode       string         `json:"op_code" gorm:"type:varchar(6);index"` // OP Code地址
 	IsActive     bool           `json:"is_active" gorm:"default:true"`
+	SSOOnly      bool           `json:"sso_only" gorm:"default:false"` // 由校园统一身份认证自动创建，没有可用的本地密码
 	LastLoginAt  *time.Time     `json:"last_login_at"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -207,16 +208,30 @@ type RegisterRequest struct {
 
 // LoginRequest 登录请求
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceID   string `json:"device_id"`   // 客户端设备标识，刷新令牌绑定该设备
+	DeviceName string `json:"device_name"` // 设备名称，用于会话列表展示
+	UserAgent  string `json:"-"`
+	IPAddress  string `json:"-"`
+
+	TrustedDeviceToken string `json:"trusted_device_token"` // 已记住设备的令牌，有效期内免两步验证
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"` // 刷新令牌，与前端类型保持一致
+	SessionID    string    `json:"session_id,omitempty"`
 	User         *User     `json:"user"`
 	ExpiresAt    time.Time `json:"expires_at"`
+
+	// 两步验证：密码通过后需凭 ChallengeID 完成第二步
+	TwoFactorRequired      bool     `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool     `json:"two_factor_setup_required,omitempty"`
+	ChallengeID            string   `json:"challenge_id,omitempty"`
+	TrustedDeviceToken     string   `json:"trusted_device_token,omitempty"`
+	RecoveryCodes          []string `json:"recovery_codes,omitempty"` // 仅在登录时完成强制绑定后返回一次
 }
 
 // UpdateProfileRequest 更新档案请求
@@ -15,8 +15,9 @@ import (
 
 // AdminService 管理后台服务
 type AdminService struct {
-	db     *gorm.DB
-	config *config.Config
+	db             *gorm.DB
+	config         *config.Config
+	sessionService *SessionService
 }
 
 // NewAdminService 创建管理后台服务实例
@@ -27,6 +28,11 @@ func NewAdminService(db *gorm.DB, config *config.Config) *AdminService {
 	}
 }
 
+// SetSessionService 设置会话服务（重置密码后注销全部会话）
+func (s *AdminService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
 // GetDashboardStats 获取管理后台统计数据
 func (s *AdminService) GetDashboardStats() (*models.AdminDashboardStats, error) {
 	stats := &models.AdminDashboardStats{}
@@ -815,6 +821,13 @@ func (s *AdminService) ResetUserPassword(userID string, newPassword string) erro
 		return fmt.Errorf("用户不存在")
 	}
 
+	// 管理员重置密码通常意味着账号可能已泄露，旧会话一并失效
+	if s.sessionService != nil {
+		if _, err := s.sessionService.RevokeAllSessions(userID, SessionRevokePasswordChanged); err != nil {
+			return fmt.Errorf("注销会话失败: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	barcodeLifecycleBatchSize = 500 // 每个阶段每次最多处理的条码数
+	barcodeLifecycleActor     = "system"
+	barcodeDefaultUnactivated = 30 * 24 * time.Hour
+	barcodeDefaultBound       = 14 * 24 * time.Hour
+	barcodeDefaultWarnBefore  = 72 * time.Hour
+)
+
+var (
+	ErrBarcodeNotFound       = errors.New("条码不存在")
+	ErrBarcodeNotReclaimable = errors.New("条码已被收取或已结束，不能回收")
+
+	// errBarcodeStateChanged 处理期间条码状态已被其他操作改变，跳过
+	errBarcodeStateChanged = errors.New("barcode state changed")
+)
+
+// barcodeAgeBuckets 滞留报告的停留时长分段
+var barcodeAgeBuckets = []struct {
+	label string
+	upTo  time.Duration
+}{
+	{"lt_1d", 24 * time.Hour},
+	{"1d_7d", 7 * 24 * time.Hour},
+	{"7d_30d", 30 * 24 * time.Hour},
+	{"gt_30d", 0},
+}
+
+// BarcodeLifecycleService 条码生命周期：未绑定或绑定后一直未收取的条码到期回收，
+// 过期前提醒寄信人，回收时信件退回草稿、信封退回库存
+type BarcodeLifecycleService struct {
+	db              *gorm.DB
+	config          *config.Config
+	notificationSvc *NotificationService
+}
+
+// NewBarcodeLifecycleService 创建条码生命周期服务
+func NewBarcodeLifecycleService(db *gorm.DB, cfg *config.Config) *BarcodeLifecycleService {
+	return &BarcodeLifecycleService{db: db, config: cfg}
+}
+
+// SetNotificationService 设置通知服务
+func (s *BarcodeLifecycleService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// barcodeUnactivatedTTL 生成后未绑定的条码有效期
+func barcodeUnactivatedTTL(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.BarcodeUnactivatedTTLDays <= 0 {
+		return barcodeDefaultUnactivated
+	}
+	return time.Duration(cfg.BarcodeUnactivatedTTLDays) * 24 * time.Hour
+}
+
+// barcodeBoundTTL 绑定后未被收取的条码有效期
+func barcodeBoundTTL(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.BarcodeBoundTTLDays <= 0 {
+		return barcodeDefaultBound
+	}
+	return time.Duration(cfg.BarcodeBoundTTLDays) * 24 * time.Hour
+}
+
+func barcodeWarnBefore(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.BarcodeExpiryWarnHours < 0 {
+		return barcodeDefaultWarnBefore
+	}
+	return time.Duration(cfg.BarcodeExpiryWarnHours) * time.Hour
+}
+
+// Policy 当前生效的过期配置
+func (s *BarcodeLifecycleService) Policy() models.BarcodeLifecyclePolicy {
+	return models.BarcodeLifecyclePolicy{
+		UnactivatedTTLHours: int(barcodeUnactivatedTTL(s.config).Hours()),
+		BoundTTLHours:       int(barcodeBoundTTL(s.config).Hours()),
+		WarnBeforeHours:     int(barcodeWarnBefore(s.config).Hours()),
+	}
+}
+
+// pendingLetterStatuses 信件尚未被收取；信箱收取等流程不改条码状态，只以信件状态判断是否已寄出
+var pendingLetterStatuses = []models.LetterStatus{models.StatusDraft, models.StatusGenerated}
+
+// unactivatedQuery 生成后尚未绑定信封、信件也未被收取的条码
+func (s *BarcodeLifecycleService) unactivatedQuery() *gorm.DB {
+	return s.pendingQuery(models.BarcodeStatusUnactivated)
+}
+
+// boundUncollectedQuery 已绑定但信件仍未被收取的条码
+func (s *BarcodeLifecycleService) boundUncollectedQuery() *gorm.DB {
+	return s.pendingQuery(models.BarcodeStatusBound)
+}
+
+func (s *BarcodeLifecycleService) pendingQuery(status models.BarcodeStatus) *gorm.DB {
+	return s.db.Model(&models.LetterCode{}).
+		Joins("JOIN letters ON letters.id = letter_codes.letter_id").
+		Where("letter_codes.status = ? AND letters.status IN ?", status, pendingLetterStatuses)
+}
+
+// Run 执行一次检查：补设旧条码的过期时间、提醒即将过期的条码、回收已过期的条码
+func (s *BarcodeLifecycleService) Run(now time.Time) (*models.BarcodeLifecycleRunResult, error) {
+	result := &models.BarcodeLifecycleRunResult{RanAt: now}
+
+	scheduled, err := s.scheduleExpiry()
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule barcode expiry: %w", err)
+	}
+	result.Scheduled = scheduled
+
+	warned, err := s.warnExpiring(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to warn expiring barcodes: %w", err)
+	}
+	result.Warned = warned
+
+	stages := []struct {
+		query  *gorm.DB
+		target models.BarcodeStatus
+		note   string
+		count  *int
+	}{
+		{s.unactivatedQuery(), models.BarcodeStatusExpired, "条码超时未绑定信封，已过期回收", &result.Expired},
+		{s.boundUncollectedQuery(), models.BarcodeStatusCancelled, "条码绑定后超时未被收取，已作废回收", &result.Cancelled},
+	}
+	for _, stage := range stages {
+		var codes []models.LetterCode
+		if err := stage.query.Preload("Letter").
+			Where("letter_codes.expires_at IS NOT NULL AND letter_codes.expires_at <= ?", now).
+			Order("letter_codes.expires_at ASC").Limit(barcodeLifecycleBatchSize).
+			Find(&codes).Error; err != nil {
+			return nil, fmt.Errorf("failed to load expired barcodes: %w", err)
+		}
+
+		for i := range codes {
+			returned, err := s.reclaim(&codes[i], stage.target, barcodeLifecycleActor, stage.note)
+			if errors.Is(err, errBarcodeStateChanged) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Barcode lifecycle: failed to reclaim %s: %v", codes[i].Code, err)
+				continue
+			}
+			*stage.count++
+			result.EnvelopesReturned += returned
+		}
+	}
+
+	return result, nil
+}
+
+// scheduleExpiry 为没有过期时间的旧条码按当前配置补设，未绑定按生成时间、已绑定按绑定时间计算
+func (s *BarcodeLifecycleService) scheduleExpiry() (int, error) {
+	scheduled := 0
+
+	var unactivated []models.LetterCode
+	if err := s.unactivatedQuery().Where("letter_codes.expires_at IS NULL").
+		Limit(barcodeLifecycleBatchSize).Find(&unactivated).Error; err != nil {
+		return 0, err
+	}
+	for _, code := range unactivated {
+		expiresAt := code.CreatedAt.Add(barcodeUnactivatedTTL(s.config))
+		if err := s.db.Model(&models.LetterCode{}).Where("id = ?", code.ID).
+			Update("expires_at", expiresAt).Error; err != nil {
+			return scheduled, err
+		}
+		scheduled++
+	}
+
+	var bound []models.LetterCode
+	if err := s.boundUncollectedQuery().Where("letter_codes.expires_at IS NULL").
+		Limit(barcodeLifecycleBatchSize).Find(&bound).Error; err != nil {
+		return scheduled, err
+	}
+	for _, code := range bound {
+		since := code.UpdatedAt
+		if code.BoundAt != nil {
+			since = *code.BoundAt
+		}
+		if err := s.db.Model(&models.LetterCode{}).Where("id = ?", code.ID).
+			Update("expires_at", since.Add(barcodeBoundTTL(s.config))).Error; err != nil {
+			return scheduled, err
+		}
+		scheduled++
+	}
+
+	return scheduled, nil
+}
+
+// warnExpiring 提醒寄信人条码即将过期，每个条码只提醒一次
+func (s *BarcodeLifecycleService) warnExpiring(now time.Time) (int, error) {
+	warnBefore := barcodeWarnBefore(s.config)
+	if warnBefore == 0 {
+		return 0, nil
+	}
+
+	warned := 0
+	for _, query := range []*gorm.DB{s.unactivatedQuery(), s.boundUncollectedQuery()} {
+		var codes []models.LetterCode
+		if err := query.Preload("Letter").
+			Where("letter_codes.expiry_warned_at IS NULL AND letter_codes.expires_at > ? AND letter_codes.expires_at <= ?",
+				now, now.Add(warnBefore)).
+			Limit(barcodeLifecycleBatchSize).Find(&codes).Error; err != nil {
+			return warned, err
+		}
+
+		for _, code := range codes {
+			if err := s.db.Model(&models.LetterCode{}).Where("id = ?", code.ID).
+				Update("expiry_warned_at", now).Error; err != nil {
+				return warned, err
+			}
+			warned++
+
+			message := "您的信件条码尚未绑定信封，到期后将自动作废"
+			if code.Status == models.BarcodeStatusBound {
+				message = "您的信件已绑定条码但尚未投递，到期后条码将作废，信件退回草稿"
+			}
+			s.notify(code.Letter.UserID, "barcode_expiring", map[string]interface{}{
+				"letter_id":    code.LetterID,
+				"barcode_code": code.Code,
+				"status":       string(code.Status),
+				"expires_at":   code.ExpiresAt,
+				"message":      message,
+			})
+		}
+	}
+
+	return warned, nil
+}
+
+// Reclaim 管理员手动回收一个未绑定或绑定后未收取的条码
+func (s *BarcodeLifecycleService) Reclaim(barcodeCode, operatorID, reason string) (*models.LetterCode, error) {
+	var code models.LetterCode
+	if err := s.db.Preload("Letter").Where("code = ?", strings.TrimSpace(barcodeCode)).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBarcodeNotFound
+		}
+		return nil, err
+	}
+
+	if code.Letter.Status != models.StatusDraft && code.Letter.Status != models.StatusGenerated {
+		return nil, ErrBarcodeNotReclaimable
+	}
+	var target models.BarcodeStatus
+	switch code.Status {
+	case models.BarcodeStatusUnactivated:
+		target = models.BarcodeStatusExpired
+	case models.BarcodeStatusBound:
+		target = models.BarcodeStatusCancelled
+	default:
+		return nil, ErrBarcodeNotReclaimable
+	}
+
+	note := "管理员回收条码"
+	if reason != "" {
+		note = fmt.Sprintf("%s: %s", note, reason)
+	}
+	if _, err := s.reclaim(&code, target, operatorID, note); err != nil {
+		if errors.Is(err, errBarcodeStateChanged) {
+			return nil, ErrBarcodeNotReclaimable
+		}
+		return nil, err
+	}
+
+	if err := s.db.First(&code, "id = ?", code.ID).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// reclaim 回收条码：条码置为终态，信件退回草稿，未完成的信使任务失败，绑定的信封退回库存
+func (s *BarcodeLifecycleService) reclaim(code *models.LetterCode, target models.BarcodeStatus, operatorID, note string) (int, error) {
+	now := time.Now()
+	returned := 0
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 以当前状态为条件更新，避免与同时进行的绑定或收取冲突
+		res := tx.Model(&models.LetterCode{}).Where("id = ? AND status = ?", code.ID, code.Status).
+			Updates(map[string]interface{}{
+				"status":     target,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errBarcodeStateChanged
+		}
+
+		res = tx.Model(&models.Letter{}).Where("id = ? AND status IN ?", code.LetterID, pendingLetterStatuses).
+			Updates(map[string]interface{}{
+				"status":     models.StatusDraft,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errBarcodeStateChanged
+		}
+
+		if err := tx.Create(&models.StatusLog{
+			ID:        uuid.New().String(),
+			LetterID:  code.LetterID,
+			Status:    models.StatusDraft,
+			UpdatedBy: operatorID,
+			Note:      note,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := failActiveCourierTasks(tx, code.Code, note); err != nil {
+			return err
+		}
+
+		n, err := returnEnvelopesToStock(tx, code)
+		if err != nil {
+			return err
+		}
+		returned = n
+
+		return recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, code.Code, note)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.notify(code.Letter.UserID, "barcode_expired", map[string]interface{}{
+		"letter_id":    code.LetterID,
+		"barcode_code": code.Code,
+		"status":       string(target),
+		"message":      note + "，信件已退回草稿，可重新生成条码",
+	})
+	return returned, nil
+}
+
+// failActiveCourierTasks 条码作废时未完成的信使任务随之失败
+func failActiveCourierTasks(tx *gorm.DB, letterCode, reason string) error {
+	return tx.Model(&models.CourierTask{}).
+		Where("letter_code = ? AND status NOT IN ?", letterCode,
+			[]string{models.CourierTaskStatusDelivered, models.CourierTaskStatusFailed, models.CourierTaskStatusRerouted}).
+		Updates(map[string]interface{}{
+			"status":         models.CourierTaskStatusFailed,
+			"failure_reason": reason,
+		}).Error
+}
+
+// returnEnvelopesToStock 把绑定到条码或信件上的信封恢复为未使用，信封条码被覆盖过的换回信封自己的编号
+func returnEnvelopesToStock(tx *gorm.DB, code *models.LetterCode) (int, error) {
+	query := tx.Where("letter_id = ?", code.LetterID)
+	if code.EnvelopeID != "" {
+		query = tx.Where("id = ? OR letter_id = ?", code.EnvelopeID, code.LetterID)
+	}
+
+	var envelopes []models.Envelope
+	if err := query.Find(&envelopes).Error; err != nil {
+		return 0, err
+	}
+
+	for _, envelope := range envelopes {
+		updates := map[string]interface{}{
+			"status":            models.EnvelopeStatusUnsent,
+			"letter_id":         "",
+			"used_at":           nil,
+			"recipient_op_code": "",
+			"updated_at":        time.Now(),
+		}
+		if envelope.BarcodeID == code.Code {
+			updates["barcode_id"] = "ENV-" + envelope.ID
+		}
+		if err := tx.Model(&models.Envelope{}).Where("id = ?", envelope.ID).Updates(updates).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return len(envelopes), nil
+}
+
+// Report 各阶段滞留的条码数量与停留时长分布
+func (s *BarcodeLifecycleService) Report(now time.Time) (*models.BarcodeLifecycleReport, error) {
+	report := &models.BarcodeLifecycleReport{
+		Policy:      s.Policy(),
+		GeneratedAt: now,
+	}
+	warnBefore := barcodeWarnBefore(s.config)
+
+	stages := []struct {
+		stage string
+		query *gorm.DB
+		since func(code *models.LetterCode) time.Time
+	}{
+		{models.BarcodeStageUnactivated, s.unactivatedQuery(), func(code *models.LetterCode) time.Time {
+			return code.CreatedAt
+		}},
+		{models.BarcodeStageBoundUncollected, s.boundUncollectedQuery(), func(code *models.LetterCode) time.Time {
+			if code.BoundAt != nil {
+				return *code.BoundAt
+			}
+			return code.UpdatedAt
+		}},
+		{models.BarcodeStageInTransit, s.db.Model(&models.LetterCode{}).
+			Where("letter_codes.status = ?", models.BarcodeStatusInTransit), func(code *models.LetterCode) time.Time {
+			if code.LastScannedAt != nil {
+				return *code.LastScannedAt
+			}
+			return code.UpdatedAt
+		}},
+	}
+
+	for _, stage := range stages {
+		var codes []models.LetterCode
+		if err := stage.query.Select("letter_codes.id", "letter_codes.created_at", "letter_codes.updated_at",
+			"letter_codes.bound_at", "letter_codes.last_scanned_at", "letter_codes.expires_at").
+			Find(&codes).Error; err != nil {
+			return nil, fmt.Errorf("failed to load barcodes: %w", err)
+		}
+
+		stats := models.BarcodeStageStats{
+			Stage:      stage.stage,
+			Count:      len(codes),
+			AgeBuckets: make(map[string]int, len(barcodeAgeBuckets)),
+		}
+		for _, bucket := range barcodeAgeBuckets {
+			stats.AgeBuckets[bucket.label] = 0
+		}
+
+		for i := range codes {
+			since := stage.since(&codes[i])
+			if stats.OldestAt == nil || since.Before(*stats.OldestAt) {
+				oldest := since
+				stats.OldestAt = &oldest
+			}
+			age := now.Sub(since)
+			for _, bucket := range barcodeAgeBuckets {
+				if bucket.upTo == 0 || age < bucket.upTo {
+					stats.AgeBuckets[bucket.label]++
+					break
+				}
+			}
+
+			// 投递中的条码不会自动回收，只统计停留时长
+			if stage.stage == models.BarcodeStageInTransit || codes[i].ExpiresAt == nil {
+				continue
+			}
+			switch {
+			case !codes[i].ExpiresAt.After(now):
+				stats.Overdue++
+			case codes[i].ExpiresAt.Before(now.Add(warnBefore)):
+				stats.DueSoon++
+			}
+		}
+		report.Stages = append(report.Stages, stats)
+	}
+
+	for status, count := range map[models.BarcodeStatus]*int64{
+		models.BarcodeStatusExpired:   &report.Expired,
+		models.BarcodeStatusCancelled: &report.Cancelled,
+		models.BarcodeStatusDelivered: &report.Delivered,
+	} {
+		if err := s.db.Model(&models.LetterCode{}).Where("status = ?", status).Count(count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count barcodes: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (s *BarcodeLifecycleService) notify(userID, notificationType string, data map[string]interface{}) {
+	if s.notificationSvc == nil || userID == "" {
+		return
+	}
+	go func() {
+		if err := s.notificationSvc.NotifyUser(userID, notificationType, data); err != nil {
+			log.Printf("Barcode lifecycle: failed to notify %s: %v", userID, err)
+		}
+	}()
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// BarcodeLifecycleTestSuite 条码生命周期测试套件
+type BarcodeLifecycleTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *BarcodeLifecycleService
+	sender  *models.User
+	admin   *models.User
+}
+
+func (suite *BarcodeLifecycleTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewBarcodeLifecycleService(db, &config.Config{
+		BarcodeUnactivatedTTLDays: 30,
+		BarcodeBoundTTLDays:       14,
+		BarcodeExpiryWarnHours:    72,
+	})
+	suite.sender = config.CreateTestUser(db, "barcode_sender", models.RoleUser)
+	suite.admin = config.CreateTestUser(db, "barcode_admin", models.RolePlatformAdmin)
+}
+
+// createCode 已生成条码的信件；expiresAt 为空表示升级前的旧条码
+func (suite *BarcodeLifecycleTestSuite) createCode(code string, status models.BarcodeStatus, letterStatus models.LetterStatus, createdAt time.Time, expiresAt *time.Time) *models.LetterCode {
+	letter := &models.Letter{
+		ID: uuid.New().String(), UserID: suite.sender.ID, Title: "写给未来", Content: "你好",
+		Status: letterStatus,
+	}
+	suite.NoError(suite.db.Create(letter).Error)
+	letterCode := &models.LetterCode{
+		ID: uuid.New().String(), LetterID: letter.ID, Code: code, Status: status,
+		ExpiresAt: expiresAt, CreatedAt: createdAt, UpdatedAt: createdAt,
+	}
+	if status == models.BarcodeStatusBound {
+		letterCode.BoundAt = &createdAt
+	}
+	suite.NoError(suite.db.Create(letterCode).Error)
+	return letterCode
+}
+
+func (suite *BarcodeLifecycleTestSuite) reload(code string) models.LetterCode {
+	var letterCode models.LetterCode
+	suite.NoError(suite.db.Preload("Letter").First(&letterCode, "code = ?", code).Error)
+	return letterCode
+}
+
+func (suite *BarcodeLifecycleTestSuite) TestRun_SchedulesLegacyCodesAndWarns() {
+	now := time.Now()
+	suite.createCode("OPLEGACY0001", models.BarcodeStatusUnactivated, models.StatusGenerated, now.Add(-28*24*time.Hour), nil)
+	suite.createCode("OPLEGACY0002", models.BarcodeStatusBound, models.StatusGenerated, now.Add(-2*24*time.Hour), nil)
+	// 已被信箱收取的信件条码仍是未激活，不参与回收
+	suite.createCode("OPLEGACY0003", models.BarcodeStatusUnactivated, models.StatusCollected, now.Add(-60*24*time.Hour), nil)
+
+	result, err := suite.service.Run(now)
+	suite.NoError(err)
+	suite.Equal(2, result.Scheduled)
+	suite.Equal(1, result.Warned)
+	suite.Zero(result.Expired)
+
+	legacy := suite.reload("OPLEGACY0001")
+	suite.NotNil(legacy.ExpiresAt)
+	suite.WithinDuration(now.Add(2*24*time.Hour), *legacy.ExpiresAt, time.Minute)
+	suite.NotNil(legacy.ExpiryWarnedAt)
+
+	bound := suite.reload("OPLEGACY0002")
+	suite.WithinDuration(now.Add(12*24*time.Hour), *bound.ExpiresAt, time.Minute)
+	suite.Nil(bound.ExpiryWarnedAt)
+
+	collected := suite.reload("OPLEGACY0003")
+	suite.Nil(collected.ExpiresAt)
+	suite.Equal(models.BarcodeStatusUnactivated, collected.Status)
+
+	// 每个条码只提醒一次
+	result, err = suite.service.Run(now.Add(time.Hour))
+	suite.NoError(err)
+	suite.Zero(result.Warned)
+}
+
+func (suite *BarcodeLifecycleTestSuite) TestRun_ExpiresAndReturnsEnvelope() {
+	past := time.Now().Add(-time.Hour)
+	unactivated := suite.createCode("OPEXPIRED001", models.BarcodeStatusUnactivated, models.StatusGenerated, past.Add(-30*24*time.Hour), &past)
+	bound := suite.createCode("OPEXPIRED002", models.BarcodeStatusBound, models.StatusGenerated, past.Add(-14*24*time.Hour), &past)
+
+	envelope := &models.Envelope{
+		ID: uuid.New().String(), DesignID: uuid.New().String(), UserID: suite.sender.ID, UsedBy: suite.sender.ID,
+		LetterID: bound.LetterID, BarcodeID: bound.Code, Status: models.EnvelopeStatusUsed, RecipientOPCode: "PK5F3D",
+	}
+	suite.NoError(suite.db.Create(envelope).Error)
+	suite.NoError(suite.db.Model(&models.LetterCode{}).Where("id = ?", bound.ID).Update("envelope_id", envelope.ID).Error)
+	suite.NoError(suite.db.Create(&models.CourierTask{
+		ID: uuid.New().String(), LetterCode: bound.Code, Status: models.CourierTaskStatusPending,
+	}).Error)
+
+	result, err := suite.service.Run(time.Now())
+	suite.NoError(err)
+	suite.Equal(1, result.Expired)
+	suite.Equal(1, result.Cancelled)
+	suite.Equal(1, result.EnvelopesReturned)
+
+	expired := suite.reload(unactivated.Code)
+	suite.Equal(models.BarcodeStatusExpired, expired.Status)
+	suite.Equal(models.StatusDraft, expired.Letter.Status)
+
+	cancelled := suite.reload(bound.Code)
+	suite.Equal(models.BarcodeStatusCancelled, cancelled.Status)
+	suite.Equal(models.StatusDraft, cancelled.Letter.Status)
+
+	var returned models.Envelope
+	suite.NoError(suite.db.First(&returned, "id = ?", envelope.ID).Error)
+	suite.Equal(models.EnvelopeStatusUnsent, returned.Status)
+	suite.Empty(returned.LetterID)
+	suite.Empty(returned.RecipientOPCode)
+	suite.Nil(returned.UsedAt)
+	suite.Equal("ENV-"+envelope.ID, returned.BarcodeID)
+
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "letter_code = ?", bound.Code).Error)
+	suite.Equal(models.CourierTaskStatusFailed, task.Status)
+
+	var logs int64
+	suite.db.Model(&models.StatusLog{}).Where("letter_id = ? AND status = ?", bound.LetterID, models.StatusDraft).Count(&logs)
+	suite.Equal(int64(1), logs)
+	var events int64
+	suite.db.Model(&models.LetterOutboxEvent{}).Where("letter_code = ?", bound.Code).Count(&events)
+	suite.Equal(int64(1), events)
+
+	// 再次执行不会重复处理
+	result, err = suite.service.Run(time.Now())
+	suite.NoError(err)
+	suite.Zero(result.Expired + result.Cancelled)
+}
+
+func (suite *BarcodeLifecycleTestSuite) TestGenerateCode_ReplacesReclaimedCode() {
+	past := time.Now().Add(-time.Hour)
+	stale := suite.createCode("OPSTALE00001", models.BarcodeStatusUnactivated, models.StatusGenerated, past, &past)
+	_, err := suite.service.Run(time.Now())
+	suite.NoError(err)
+
+	letterService := NewLetterService(suite.db, &config.Config{QRCodeStorePath: suite.T().TempDir(), BaseURL: "http://localhost"})
+	fresh, err := letterService.GenerateCode(stale.LetterID)
+	suite.NoError(err)
+	suite.NotEqual(stale.Code, fresh.Code)
+	suite.Equal(models.BarcodeStatusUnactivated, fresh.Status)
+	suite.NotNil(fresh.ExpiresAt)
+	suite.WithinDuration(time.Now().Add(30*24*time.Hour), *fresh.ExpiresAt, time.Minute)
+
+	var count int64
+	suite.db.Model(&models.LetterCode{}).Where("letter_id = ?", stale.LetterID).Count(&count)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *BarcodeLifecycleTestSuite) TestReclaim() {
+	future := time.Now().Add(10 * 24 * time.Hour)
+	code := suite.createCode("OPRECLAIM001", models.BarcodeStatusBound, models.StatusGenerated, time.Now(), &future)
+	suite.createCode("OPRECLAIM002", models.BarcodeStatusBound, models.StatusInTransit, time.Now(), &future)
+
+	reclaimed, err := suite.service.Reclaim(code.Code, suite.admin.ID, "信封损坏")
+	suite.NoError(err)
+	suite.Equal(models.BarcodeStatusCancelled, reclaimed.Status)
+
+	var statusLog models.StatusLog
+	suite.NoError(suite.db.Where("letter_id = ?", code.LetterID).First(&statusLog).Error)
+	suite.Equal(suite.admin.ID, statusLog.UpdatedBy)
+	suite.Contains(statusLog.Note, "信封损坏")
+
+	_, err = suite.service.Reclaim(code.Code, suite.admin.ID, "")
+	suite.ErrorIs(err, ErrBarcodeNotReclaimable)
+	_, err = suite.service.Reclaim("OPRECLAIM002", suite.admin.ID, "")
+	suite.ErrorIs(err, ErrBarcodeNotReclaimable)
+	_, err = suite.service.Reclaim("OPMISSING000", suite.admin.ID, "")
+	suite.ErrorIs(err, ErrBarcodeNotFound)
+}
+
+func (suite *BarcodeLifecycleTestSuite) TestReport() {
+	now := time.Now()
+	soon := now.Add(24 * time.Hour)
+	later := now.Add(20 * 24 * time.Hour)
+	past := now.Add(-time.Hour)
+	suite.createCode("OPREPORT0001", models.BarcodeStatusUnactivated, models.StatusGenerated, now.Add(-29*24*time.Hour), &soon)
+	suite.createCode("OPREPORT0002", models.BarcodeStatusUnactivated, models.StatusGenerated, now.Add(-2*time.Hour), &later)
+	suite.createCode("OPREPORT0003", models.BarcodeStatusBound, models.StatusGenerated, now.Add(-15*24*time.Hour), &past)
+	suite.createCode("OPREPORT0004", models.BarcodeStatusInTransit, models.StatusInTransit, now.Add(-3*24*time.Hour), nil)
+	suite.createCode("OPREPORT0005", models.BarcodeStatusExpired, models.StatusDraft, now.Add(-40*24*time.Hour), nil)
+
+	report, err := suite.service.Report(now)
+	suite.NoError(err)
+	suite.Equal(14*24, report.Policy.BoundTTLHours)
+	suite.Len(report.Stages, 3)
+
+	unactivated := report.Stages[0]
+	suite.Equal(models.BarcodeStageUnactivated, unactivated.Stage)
+	suite.Equal(2, unactivated.Count)
+	suite.Equal(1, unactivated.DueSoon)
+	suite.Equal(1, unactivated.AgeBuckets["lt_1d"])
+	suite.Equal(1, unactivated.AgeBuckets["7d_30d"])
+	suite.WithinDuration(now.Add(-29*24*time.Hour), *unactivated.OldestAt, time.Second)
+
+	bound := report.Stages[1]
+	suite.Equal(1, bound.Count)
+	suite.Equal(1, bound.Overdue)
+
+	inTransit := report.Stages[2]
+	suite.Equal(1, inTransit.Count)
+	suite.Equal(1, inTransit.AgeBuckets["1d_7d"])
+
+	suite.Equal(int64(1), report.Expired)
+}
+
+func TestBarcodeLifecycleService(t *testing.T) {
+	suite.Run(t, new(BarcodeLifecycleTestSuite))
+}
@@ -16,8 +16,11 @@ import (
 )
 
 type CourierService struct {
-	db        *gorm.DB
-	wsService WebSocketNotifier
+	db                        *gorm.DB
+	wsService                 WebSocketNotifier
+	schoolVerificationService *SchoolVerificationService
+	forwardSvc                *OPCodeForwardService
+	qrTokenSvc                *QRTokenService
 }
 
 // WebSocketNotifier - Interface for real-time notifications (SOTA: Dependency Inversion)
@@ -34,6 +37,21 @@ func (s *CourierService) SetWebSocketService(wsService WebSocketNotifier) {
 	s.wsService = wsService
 }
 
+// SetSchoolVerificationService 设置在校身份认证服务（仅认证学生可申请信使）
+func (s *CourierService) SetSchoolVerificationService(schoolVerificationService *SchoolVerificationService) {
+	s.schoolVerificationService = schoolVerificationService
+}
+
+// SetOPCodeForwardService 设置OP Code转寄服务，分配任务时按转寄改投
+func (s *CourierService) SetOPCodeForwardService(forwardSvc *OPCodeForwardService) {
+	s.forwardSvc = forwardSvc
+}
+
+// SetQRTokenService 设置二维码签名令牌服务，设置后扫码更新任务须通过签名校验
+func (s *CourierService) SetQRTokenService(qrTokenSvc *QRTokenService) {
+	s.qrTokenSvc = qrTokenSvc
+}
+
 // ApplyCourier 申请成为信使
 func (s *CourierService) ApplyCourier(userID string, req *models.CourierApplication) (*models.Courier, error) {
 	// 检查用户是否已经申请过
@@ -42,6 +60,18 @@ func (s *CourierService) ApplyCourier(userID string, req *models.CourierApplicat
 		return nil, errors.New("您已经申请过信使，请勿重复申请")
 	}
 
+	// 必须已认证在校身份，学校以认证结果为准
+	school := req.School
+	if s.schoolVerificationService != nil {
+		verification, err := s.schoolVerificationService.RequireVerified(userID, "")
+		if err != nil {
+			return nil, err
+		}
+		if verification.School != nil {
+			school = verification.School.SchoolName
+		}
+	}
+
 	// 检查联系方式是否已被使用
 	var duplicateContact models.Courier
 	if err := s.db.Where("contact = ?", req.Contact).First(&duplicateContact).Error; err == nil {
@@ -63,7 +93,7 @@ func (s *CourierService) ApplyCourier(userID string, req *models.CourierApplicat
 		UserID:          userID,
 		Name:            req.Name,
 		Contact:         req.Contact,
-		School:          req.School,
+		School:          school,
 		Zone:            req.Zone,
 		HasPrinter:      hasPrinter,
 		SelfIntro:       req.SelfIntro,
@@ -975,6 +1005,15 @@ func (s *CourierService) ValidateOPCodeAccess(courierID string, targetOPCode str
 
 // AssignTaskByOPCode 基于OP Code分配任务给信使
 func (s *CourierService) AssignTaskByOPCode(letterCode string, pickupOPCode string, deliveryOPCode string) (*models.CourierTask, error) {
+	// 收件人已登记转寄时直接分配到新编码
+	if s.forwardSvc != nil {
+		resolution, err := s.forwardSvc.Resolve(deliveryOPCode, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve forwarding: %w", err)
+		}
+		deliveryOPCode = resolution.Code
+	}
+
 	// 查找有权限处理该OP Code区域的信使
 	var couriers []models.Courier
 
@@ -1014,7 +1053,13 @@ func (s *CourierService) AssignTaskByOPCode(letterCode string, pickupOPCode stri
 		Deadline:       time.Now().Add(4 * time.Hour),
 	}
 
-	if err := s.db.Create(task).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return recordLetterSyncEvent(tx, models.SyncEventLetterTaskAssigned, letterCode, "")
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to create courier task: %w", err)
 	}
 
@@ -1033,13 +1078,25 @@ func (s *CourierService) AssignTaskByOPCode(letterCode string, pickupOPCode stri
 	return task, nil
 }
 
-// UpdateTaskLocation 更新任务位置（使用OP Code）
-func (s *CourierService) UpdateTaskLocation(taskID string, currentOPCode string, status string) error {
+// UpdateTaskLocation 更新任务位置（使用OP Code），qrToken 为扫描信封二维码得到的签名令牌
+func (s *CourierService) UpdateTaskLocation(taskID string, currentOPCode string, status string, qrToken string) error {
 	task := &models.CourierTask{}
 	if err := s.db.Where("id = ?", taskID).First(task).Error; err != nil {
 		return fmt.Errorf("task not found: %w", err)
 	}
 
+	// 送达只能由收件人当面签收确认，生成签收凭证
+	if status == models.CourierTaskStatusDelivered {
+		return ErrDeliveryReceiptRequired
+	}
+
+	// 校验二维码签名令牌，拒绝伪造或已作废的标签
+	if s.qrTokenSvc != nil {
+		if _, err := s.qrTokenSvc.VerifyScan(qrToken, task.LetterCode); err != nil {
+			return err
+		}
+	}
+
 	// 验证状态转换
 	validTransitions := map[string][]string{
 		models.CourierTaskStatusPending:   {models.CourierTaskStatusCollected},
@@ -1067,10 +1124,10 @@ func (s *CourierService) UpdateTaskLocation(taskID string, currentOPCode string,
 		"updated_at":      time.Now(),
 	}
 
-	if status == models.CourierTaskStatusDelivered {
-		now := time.Now()
-		updates["completed_at"] = &now
-	}
-
-	return s.db.Model(task).Updates(updates).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(task).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, task.LetterCode, "")
+	})
 }
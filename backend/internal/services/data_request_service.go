@@ -0,0 +1,736 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// DataExportRetention 导出包保留时长
+	DataExportRetention = 7 * 24 * time.Hour
+	// DataErasureGracePeriod 删除请求冷静期，期间用户可撤销
+	DataErasureGracePeriod = 14 * 24 * time.Hour
+
+	dataExportDir       = "exports/data-requests"
+	erasedAuthorName    = "已注销用户"
+	dataRequestActorSys = "system"
+)
+
+// DataRequestService 数据主体请求服务 - 个人数据导出与账户删除
+type DataRequestService struct {
+	db                  *gorm.DB
+	config              *config.Config
+	storageService      *StorageService
+	notificationService *NotificationService
+	twoFactorService    *TwoFactorService
+	ssoService          *SSOService
+}
+
+// NewDataRequestService 创建数据主体请求服务
+func NewDataRequestService(db *gorm.DB, config *config.Config) *DataRequestService {
+	return &DataRequestService{
+		db:     db,
+		config: config,
+	}
+}
+
+// SetStorageService 设置存储服务依赖（导出文件内容、删除文件）
+func (s *DataRequestService) SetStorageService(storageService *StorageService) {
+	s.storageService = storageService
+}
+
+// SetNotificationService 设置通知服务依赖
+func (s *DataRequestService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
+// SetTwoFactorService 设置两步验证服务依赖（无本地密码账号的重新认证）
+func (s *DataRequestService) SetTwoFactorService(twoFactorService *TwoFactorService) {
+	s.twoFactorService = twoFactorService
+}
+
+// SetSSOService 设置单点登录服务依赖（无本地密码账号的重新认证）
+func (s *DataRequestService) SetSSOService(ssoService *SSOService) {
+	s.ssoService = ssoService
+}
+
+// RequestExport 申请个人数据导出，同步生成导出包
+func (s *DataRequestService) RequestExport(userID, requestIP string) (*models.DataSubjectRequest, error) {
+	var pending int64
+	s.db.Model(&models.DataSubjectRequest{}).
+		Where("user_id = ? AND type = ? AND status IN ?", userID, models.DataRequestTypeExport,
+			[]models.DataRequestStatus{models.DataRequestStatusPending, models.DataRequestStatusProcessing}).
+		Count(&pending)
+	if pending > 0 {
+		return nil, fmt.Errorf("an export request is already in progress")
+	}
+
+	request := &models.DataSubjectRequest{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Type:      models.DataRequestTypeExport,
+		Status:    models.DataRequestStatusProcessing,
+		RequestIP: requestIP,
+	}
+	if err := s.db.Create(request).Error; err != nil {
+		return nil, fmt.Errorf("failed to create export request: %w", err)
+	}
+	s.audit(request, "requested", userID, nil)
+
+	counts, err := s.buildExportArchive(request)
+	if err != nil {
+		s.markFailed(request, err)
+		return nil, fmt.Errorf("failed to build export archive: %w", err)
+	}
+
+	expiresAt := time.Now().Add(DataExportRetention)
+	now := time.Now()
+	request.Status = models.DataRequestStatusReady
+	request.ExpiresAt = &expiresAt
+	request.CompletedAt = &now
+	if err := s.db.Save(request).Error; err != nil {
+		return nil, fmt.Errorf("failed to update export request: %w", err)
+	}
+	s.audit(request, "exported", dataRequestActorSys, counts)
+
+	if s.notificationService != nil {
+		go s.notificationService.NotifyUser(userID, "data_export_ready", map[string]interface{}{
+			"request_id": request.ID,
+			"expires_at": expiresAt,
+		})
+	}
+
+	return request, nil
+}
+
+// OpenExportArchive 打开导出包用于下载
+func (s *DataRequestService) OpenExportArchive(userID, requestID string) (*models.DataSubjectRequest, *os.File, error) {
+	var request models.DataSubjectRequest
+	if err := s.db.Where("id = ? AND user_id = ?", requestID, userID).First(&request).Error; err != nil {
+		return nil, nil, fmt.Errorf("data request not found")
+	}
+	if !request.IsDownloadable() {
+		return nil, nil, fmt.Errorf("export archive is not available")
+	}
+
+	file, err := os.Open(request.ArchivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open export archive: %w", err)
+	}
+	s.audit(&request, "downloaded", userID, nil)
+
+	return &request, file, nil
+}
+
+// RequestErasure 申请删除账户，冷静期结束后执行
+func (s *DataRequestService) RequestErasure(userID string, req *models.CreateErasureRequest, requestIP string) (*models.DataSubjectRequest, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	// 删除账户属于不可逆操作，必须再次验证身份
+	if err := s.reauthenticate(&user, req); err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	s.db.Model(&models.DataSubjectRequest{}).
+		Where("user_id = ? AND type = ? AND status IN ?", userID, models.DataRequestTypeErasure,
+			[]models.DataRequestStatus{models.DataRequestStatusScheduled, models.DataRequestStatusProcessing}).
+		Count(&existing)
+	if existing > 0 {
+		return nil, fmt.Errorf("an erasure request is already scheduled")
+	}
+
+	scheduledFor := time.Now().Add(DataErasureGracePeriod)
+	request := &models.DataSubjectRequest{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Type:         models.DataRequestTypeErasure,
+		Status:       models.DataRequestStatusScheduled,
+		Reason:       req.Reason,
+		ScheduledFor: &scheduledFor,
+		RequestIP:    requestIP,
+	}
+	if err := s.db.Create(request).Error; err != nil {
+		return nil, fmt.Errorf("failed to create erasure request: %w", err)
+	}
+	s.audit(request, "requested", userID, map[string]interface{}{"scheduled_for": scheduledFor})
+
+	if s.notificationService != nil {
+		go s.notificationService.NotifyUser(userID, "account_erasure_scheduled", map[string]interface{}{
+			"request_id":    request.ID,
+			"scheduled_for": scheduledFor,
+		})
+	}
+
+	return request, nil
+}
+
+// reauthenticate 删除账户前的重新认证：有本地密码的账号验证密码，
+// 自动创建的校园账号改用两步验证码或刚完成的单点登录重新认证
+func (s *DataRequestService) reauthenticate(user *models.User, req *models.CreateErasureRequest) error {
+	if !user.SSOOnly {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			return fmt.Errorf("password is incorrect")
+		}
+		return nil
+	}
+
+	switch {
+	case req.TwoFactorCode != "" && s.twoFactorService != nil:
+		if s.twoFactorService.VerifyCode(user.ID, req.TwoFactorCode) == nil {
+			return nil
+		}
+	case req.SSOReauth != nil && s.ssoService != nil:
+		if s.ssoService.VerifyReauth(user.ID, req.SSOReauth) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("re-authentication failed")
+}
+
+// CancelErasure 在冷静期内撤销删除请求
+func (s *DataRequestService) CancelErasure(userID, requestID string) error {
+	var request models.DataSubjectRequest
+	if err := s.db.Where("id = ? AND user_id = ?", requestID, userID).First(&request).Error; err != nil {
+		return fmt.Errorf("data request not found")
+	}
+	if !request.CanBeCancelled() {
+		return fmt.Errorf("request cannot be cancelled")
+	}
+
+	if err := s.db.Model(&request).Update("status", models.DataRequestStatusCancelled).Error; err != nil {
+		return fmt.Errorf("failed to cancel request: %w", err)
+	}
+	s.audit(&request, "cancelled", userID, nil)
+	return nil
+}
+
+// GetUserRequests 获取用户的数据请求列表
+func (s *DataRequestService) GetUserRequests(userID string) ([]models.DataSubjectRequest, error) {
+	var requests []models.DataSubjectRequest
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to get data requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ListRequests 管理员查询数据请求
+func (s *DataRequestService) ListRequests(query *models.DataRequestQuery) ([]models.DataSubjectRequest, int64, error) {
+	db := s.db.Model(&models.DataSubjectRequest{})
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+
+	var requests []models.DataSubjectRequest
+	err := db.Order("created_at DESC").Offset((query.Page - 1) * query.Limit).Limit(query.Limit).Find(&requests).Error
+	return requests, total, err
+}
+
+// GetAuditLogs 获取请求的审计记录
+func (s *DataRequestService) GetAuditLogs(requestID string) ([]models.DataRequestAuditLog, error) {
+	var logs []models.DataRequestAuditLog
+	err := s.db.Where("request_id = ?", requestID).Order("created_at ASC").Find(&logs).Error
+	return logs, err
+}
+
+// ProcessDueErasures 执行冷静期已结束的删除请求，并清理过期导出包
+func (s *DataRequestService) ProcessDueErasures() (int, error) {
+	s.expireArchives()
+
+	var due []models.DataSubjectRequest
+	if err := s.db.Where("type = ? AND status = ? AND scheduled_for <= ?",
+		models.DataRequestTypeErasure, models.DataRequestStatusScheduled, time.Now()).
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to query due erasures: %w", err)
+	}
+
+	processed := 0
+	for i := range due {
+		if err := s.ExecuteErasure(&due[i], dataRequestActorSys); err != nil {
+			log.Printf("Data erasure %s failed: %v", due[i].ID, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// ExecuteErasure 执行账户删除：
+// 他人已收到的信件保留但匿名化作者，私有数据硬删除，上传文件彻底清除，账户变为不可登录的墓碑记录
+func (s *DataRequestService) ExecuteErasure(request *models.DataSubjectRequest, actorID string) error {
+	if request.Type != models.DataRequestTypeErasure {
+		return fmt.Errorf("not an erasure request")
+	}
+	s.db.Model(request).Update("status", models.DataRequestStatusProcessing)
+
+	userID := request.UserID
+	counts := make(map[string]interface{})
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().First(&user, "id = ?", userID).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+
+		// 1. 他人已收到的信件：保留内容，匿名化作者
+		receivedStatuses := []models.LetterStatus{
+			models.StatusCollected, models.StatusInTransit, models.StatusDelivered, models.StatusRead,
+		}
+		result := tx.Model(&models.Letter{}).
+			Where("user_id = ? AND (status IN ? OR recipient_id <> '')", userID, receivedStatuses).
+			Updates(map[string]interface{}{
+				"author_name":    erasedAuthorName,
+				"sender_op_code": "",
+				"visibility":     models.VisibilityPrivate,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to anonymize letters: %w", result.Error)
+		}
+		counts["letters_anonymized"] = result.RowsAffected
+
+		// 2. 未被他人收到的信件（草稿、未寄出）：硬删除
+		var privateLetterIDs []string
+		tx.Model(&models.Letter{}).Unscoped().
+			Where("user_id = ? AND status NOT IN ? AND (recipient_id IS NULL OR recipient_id = '')", userID, receivedStatuses).
+			Pluck("id", &privateLetterIDs)
+		if len(privateLetterIDs) > 0 {
+			for _, model := range []interface{}{&models.LetterCode{}, &models.StatusLog{}, &models.LetterPhoto{}, &models.LetterLike{}, &models.LetterShare{}} {
+				if err := purgeWhere(tx, model, "letter_id IN ?", privateLetterIDs); err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id IN ?", privateLetterIDs).Delete(&models.Letter{}).Error; err != nil {
+				return fmt.Errorf("failed to delete private letters: %w", err)
+			}
+		}
+		counts["letters_deleted"] = len(privateLetterIDs)
+
+		// 回信：对方已读的保留内容并取消公开，未读的视同未送达直接删除
+		if tx.Migrator().HasTable(&models.LetterReply{}) {
+			result = tx.Model(&models.LetterReply{}).
+				Where("author_id = ? AND read_at IS NOT NULL", userID).
+				Update("is_public", false)
+			if result.Error != nil {
+				return fmt.Errorf("failed to anonymize letter replies: %w", result.Error)
+			}
+			counts["letter_replies_anonymized"] = result.RowsAffected
+
+			affected, err := purgeWhereCount(tx, &models.LetterReply{}, "author_id = ? AND read_at IS NULL", userID)
+			if err != nil {
+				return err
+			}
+			counts["letter_replies_deleted"] = affected
+		}
+
+		// 手写信识别结果含信件原文，随识别任务一并删除
+		if tx.Migrator().HasTable(&models.LetterOCRJob{}) {
+			var ocrJobIDs []string
+			tx.Model(&models.LetterOCRJob{}).Where("sender_id = ?", userID).Pluck("id", &ocrJobIDs)
+			if len(ocrJobIDs) > 0 {
+				if err := purgeWhere(tx, &models.LetterOCRRegion{}, "job_id IN ?", ocrJobIDs); err != nil {
+					return err
+				}
+				if err := purgeWhere(tx, &models.LetterOCRJob{}, "id IN ?", ocrJobIDs); err != nil {
+					return err
+				}
+			}
+			counts["letter_ocr_jobs"] = len(ocrJobIDs)
+		}
+
+		// 3. 其余个人数据：硬删除
+		personal := []struct {
+			name  string
+			model interface{}
+			where string
+		}{
+			{"comments", &models.Comment{}, "user_id = ?"},
+			{"comment_likes", &models.CommentLike{}, "user_id = ?"},
+			{"letter_likes", &models.LetterLike{}, "user_id = ?"},
+			{"letter_shares", &models.LetterShare{}, "user_id = ?"},
+			{"notifications", &models.Notification{}, "user_id = ?"},
+			{"notification_preferences", &models.NotificationPreference{}, "user_id = ?"},
+			{"ai_usage_logs", &models.AIUsageLog{}, "user_id = ?"},
+			{"credit_transactions", &models.CreditTransaction{}, "user_id = ?"},
+			{"user_credits", &models.UserCredit{}, "user_id = ?"},
+			{"follow_relationships", &models.UserRelationship{}, "follower_id = ? OR following_id = ?"},
+			{"privacy_settings", &models.PrivacySettings{}, "user_id = ?"},
+			{"user_profiles", &models.UserProfile{}, "user_id = ?"},
+			{"user_profiles_extended", &models.UserProfileExtended{}, "user_id = ?"},
+			{"user_privacy", &models.UserPrivacy{}, "user_id = ?"},
+			{"user_sessions", &models.UserSession{}, "user_id = ?"},
+			{"refresh_tokens", &models.RefreshToken{}, "user_id = ?"},
+			{"user_two_factors", &models.UserTwoFactor{}, "user_id = ?"},
+			{"two_factor_recovery_codes", &models.TwoFactorRecoveryCode{}, "user_id = ?"},
+			{"trusted_devices", &models.TrustedDevice{}, "user_id = ?"},
+			{"login_challenges", &models.LoginChallenge{}, "user_id = ?"},
+			{"user_identities", &models.UserIdentity{}, "user_id = ?"},
+			{"school_verifications", &models.SchoolVerification{}, "user_id = ?"},
+			{"delivery_confirmations", &models.DeliveryConfirmation{}, "recipient_id = ?"},
+			{"op_code_forwards", &models.OPCodeForward{}, "user_id = ?"},
+			{"ocr_preferences", &models.OCRPreference{}, "user_id = ?"},
+		}
+		for _, p := range personal {
+			args := []interface{}{userID}
+			if p.name == "follow_relationships" {
+				args = append(args, userID)
+			}
+			affected, err := purgeWhereCount(tx, p.model, p.where, args...)
+			if err != nil {
+				return err
+			}
+			counts[p.name] = affected
+		}
+
+		// 4. 扫描记录属于他人信件的投递轨迹，仅去除设备指纹
+		if tx.Migrator().HasTable(&models.ScanEvent{}) {
+			result = tx.Model(&models.ScanEvent{}).Where("scanned_by = ?", userID).
+				Updates(map[string]interface{}{"device_info": "", "user_agent": "", "ip_address": ""})
+			if result.Error != nil {
+				return fmt.Errorf("failed to scrub scan events: %w", result.Error)
+			}
+			counts["scan_events_scrubbed"] = result.RowsAffected
+		}
+
+		// 信箱收取轮次同属投递轨迹，仅去除信使位置和备注；收取计划解除指派，由片区信使接手
+		if tx.Migrator().HasTable(&models.MailboxCollectionRun{}) {
+			result = tx.Model(&models.MailboxCollectionRun{}).Where("courier_id = ?", userID).
+				Updates(map[string]interface{}{"latitude": nil, "longitude": nil, "notes": ""})
+			if result.Error != nil {
+				return fmt.Errorf("failed to scrub collection runs: %w", result.Error)
+			}
+			counts["mailbox_collection_runs_scrubbed"] = result.RowsAffected
+		}
+		// 评选投票保留以免改变已公布的得分，投票人指向墓碑账号，仅去除IP和设备标识
+		if tx.Migrator().HasTable(&models.EnvelopeContestVote{}) {
+			result = tx.Model(&models.EnvelopeContestVote{}).Where("voter_id = ?", userID).
+				Updates(map[string]interface{}{"ip_address": "", "device_id": ""})
+			if result.Error != nil {
+				return fmt.Errorf("failed to scrub contest votes: %w", result.Error)
+			}
+			counts["envelope_contest_votes_scrubbed"] = result.RowsAffected
+		}
+		if tx.Migrator().HasTable(&models.MailboxSchedule{}) {
+			result = tx.Model(&models.MailboxSchedule{}).Where("courier_id = ?", userID).Update("courier_id", "")
+			if result.Error != nil {
+				return fmt.Errorf("failed to unassign mailbox schedules: %w", result.Error)
+			}
+			counts["mailbox_schedules_unassigned"] = result.RowsAffected
+		}
+
+		// 5. 账户变为墓碑记录，保留ID以维持信件外键
+		// 使用完整ID，截断后可能与其他墓碑账号的用户名唯一索引冲突
+		tombstone := "deleted_" + userID
+		if err := tx.Unscoped().Model(&user).Updates(map[string]interface{}{
+			"username":      tombstone,
+			"email":         tombstone + "@erased.invalid",
+			"password_hash": randomUnusableHash(),
+			"nickname":      erasedAuthorName,
+			"avatar":        "",
+			"school_code":   "",
+			"op_code":       "",
+			"is_active":     false,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.markFailed(request, err)
+		return err
+	}
+
+	// 6. 文件清除在事务外执行（对象存储无法回滚）
+	if s.storageService != nil {
+		purged, err := s.storageService.PurgeUserFiles(userID)
+		if err != nil {
+			log.Printf("Data erasure %s: file purge incomplete: %v", request.ID, err)
+		}
+		counts["storage_files"] = purged
+	}
+	s.removeAvatarFiles(userID)
+	s.removeUserArchives(userID)
+
+	now := time.Now()
+	s.db.Model(request).Updates(map[string]interface{}{
+		"status":       models.DataRequestStatusCompleted,
+		"completed_at": &now,
+	})
+	s.audit(request, "erased", actorID, counts)
+	return nil
+}
+
+// collectUserData 收集用户在平台上的全部数据，按类别组织
+func (s *DataRequestService) collectUserData(userID string) (map[string]interface{}, []models.StorageFile, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, nil, fmt.Errorf("user not found: %w", err)
+	}
+	user.PasswordHash = ""
+
+	data := map[string]interface{}{"account": user}
+
+	sections := []struct {
+		name  string
+		dest  interface{}
+		where string
+		args  []interface{}
+	}{
+		{"profile", &[]models.UserProfile{}, "user_id = ?", []interface{}{userID}},
+		{"letters_sent", &[]models.Letter{}, "user_id = ? OR author_id = ?", []interface{}{userID, userID}},
+		{"letters_received", &[]models.Letter{}, "recipient_id = ?", []interface{}{userID}},
+		{"letter_replies", &[]models.LetterReply{}, "author_id = ?", []interface{}{userID}},
+		{"comments", &[]models.Comment{}, "user_id = ?", []interface{}{userID}},
+		{"credit_account", &[]models.UserCredit{}, "user_id = ?", []interface{}{userID}},
+		{"credit_transactions", &[]models.CreditTransaction{}, "user_id = ?", []interface{}{userID}},
+		{"scan_records", &[]models.ScanRecord{}, "courier_id = ?", []interface{}{userID}},
+		{"scan_events", &[]models.ScanEvent{}, "scanned_by = ?", []interface{}{userID}},
+		{"notifications", &[]models.Notification{}, "user_id = ?", []interface{}{userID}},
+		{"ai_usage_logs", &[]models.AIUsageLog{}, "user_id = ?", []interface{}{userID}},
+		{"following", &[]models.UserRelationship{}, "follower_id = ?", []interface{}{userID}},
+		{"followers", &[]models.UserRelationship{}, "following_id = ?", []interface{}{userID}},
+		{"privacy_settings", &[]models.PrivacySettings{}, "user_id = ?", []interface{}{userID}},
+		{"op_code_forwards", &[]models.OPCodeForward{}, "user_id = ?", []interface{}{userID}},
+		{"mailbox_collection_runs", &[]models.MailboxCollectionRun{}, "courier_id = ?", []interface{}{userID}},
+		{"ocr_preferences", &[]models.OCRPreference{}, "user_id = ?", []interface{}{userID}},
+		{"letter_ocr_jobs", &[]models.LetterOCRJob{}, "sender_id = ?", []interface{}{userID}},
+		{"envelope_contest_votes", &[]models.EnvelopeContestVote{}, "voter_id = ?", []interface{}{userID}},
+		{"storage_files", &[]models.StorageFile{}, "uploaded_by = ?", []interface{}{userID}},
+	}
+
+	for _, section := range sections {
+		if err := s.db.Where(section.where, section.args...).Find(section.dest).Error; err != nil {
+			// 个别表缺失不影响整体导出
+			log.Printf("Data export: skip section %s: %v", section.name, err)
+			continue
+		}
+		data[section.name] = section.dest
+	}
+
+	files := *(sections[len(sections)-1].dest.(*[]models.StorageFile))
+	return data, files, nil
+}
+
+// buildExportArchive 生成导出ZIP包：每个数据类别一个JSON文件，上传文件放在files目录
+func (s *DataRequestService) buildExportArchive(request *models.DataSubjectRequest) (map[string]interface{}, error) {
+	data, files, err := s.collectUserData(request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dataExportDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	archivePath := filepath.Join(dataExportDir, request.ID+".zip")
+
+	out, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	hasher := sha256.New()
+	zw := zip.NewWriter(io.MultiWriter(out, hasher))
+
+	counts := make(map[string]interface{})
+	for name, section := range data {
+		w, err := zw.Create(name + ".json")
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(section); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		counts[name] = sectionLength(section)
+	}
+
+	if s.storageService != nil {
+		included := 0
+		for _, file := range files {
+			if file.Status == models.FileStatusDeleted {
+				continue
+			}
+			reader, err := s.storageService.OpenFileContent(&file)
+			if err != nil {
+				log.Printf("Data export: skip file %s: %v", file.ID, err)
+				continue
+			}
+			w, err := zw.Create(filepath.ToSlash(filepath.Join("files", file.ID+"_"+filepath.Base(file.OriginalName))))
+			if err == nil {
+				_, err = io.Copy(w, reader)
+			}
+			reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to add file %s: %w", file.ID, err)
+			}
+			included++
+		}
+		counts["files_included"] = included
+	}
+
+	manifest, _ := zw.Create("README.txt")
+	fmt.Fprintf(manifest, "OpenPenPal personal data export\nUser: %s\nRequest: %s\nGenerated: %s\n",
+		request.UserID, request.ID, time.Now().Format(time.RFC3339))
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	info, err := out.Stat()
+	if err == nil {
+		request.ArchiveSize = info.Size()
+	}
+	request.ArchivePath = archivePath
+	request.ArchiveHash = hex.EncodeToString(hasher.Sum(nil))
+
+	return counts, nil
+}
+
+// expireArchives 删除过期的导出包
+func (s *DataRequestService) expireArchives() {
+	var expired []models.DataSubjectRequest
+	s.db.Where("type = ? AND status = ? AND expires_at <= ?",
+		models.DataRequestTypeExport, models.DataRequestStatusReady, time.Now()).Find(&expired)
+
+	for i := range expired {
+		_ = os.Remove(expired[i].ArchivePath)
+		s.db.Model(&expired[i]).Updates(map[string]interface{}{
+			"status":       models.DataRequestStatusExpired,
+			"archive_path": "",
+		})
+	}
+}
+
+// removeUserArchives 删除用户所有导出包（账户删除后不应保留副本）
+func (s *DataRequestService) removeUserArchives(userID string) {
+	var exports []models.DataSubjectRequest
+	s.db.Where("user_id = ? AND type = ? AND archive_path <> ''", userID, models.DataRequestTypeExport).Find(&exports)
+	for i := range exports {
+		_ = os.Remove(exports[i].ArchivePath)
+		s.db.Model(&exports[i]).Updates(map[string]interface{}{
+			"status":       models.DataRequestStatusExpired,
+			"archive_path": "",
+		})
+	}
+}
+
+// removeAvatarFiles 删除头像文件（头像由UserService直接写入uploads目录）
+func (s *DataRequestService) removeAvatarFiles(userID string) {
+	var user models.User
+	if err := s.db.Unscoped().Select("avatar").First(&user, "id = ?", userID).Error; err == nil && user.Avatar != "" {
+		_ = os.Remove(filepath.Join(".", user.Avatar))
+	}
+	matches, _ := filepath.Glob(filepath.Join("uploads", "avatars", userID+"*"))
+	for _, match := range matches {
+		_ = os.Remove(match)
+	}
+}
+
+// audit 写入审计记录
+func (s *DataRequestService) audit(request *models.DataSubjectRequest, action, actorID string, details map[string]interface{}) {
+	entry := &models.DataRequestAuditLog{
+		ID:        uuid.New().String(),
+		RequestID: request.ID,
+		UserID:    request.UserID,
+		Action:    action,
+		ActorID:   actorID,
+	}
+	if details != nil {
+		if raw, err := json.Marshal(details); err == nil {
+			entry.Details = datatypes.JSON(raw)
+		}
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		log.Printf("Failed to write data request audit log: %v", err)
+	}
+}
+
+// markFailed 标记请求失败
+func (s *DataRequestService) markFailed(request *models.DataSubjectRequest, cause error) {
+	s.db.Model(request).Updates(map[string]interface{}{
+		"status":        models.DataRequestStatusFailed,
+		"error_message": cause.Error(),
+	})
+	s.audit(request, "failed", dataRequestActorSys, map[string]interface{}{"error": cause.Error()})
+}
+
+// purgeWhere 硬删除满足条件的记录，表不存在时跳过
+func purgeWhere(tx *gorm.DB, model interface{}, where string, args ...interface{}) error {
+	_, err := purgeWhereCount(tx, model, where, args...)
+	return err
+}
+
+// purgeWhereCount 硬删除满足条件的记录并返回删除数量，表不存在时跳过
+func purgeWhereCount(tx *gorm.DB, model interface{}, where string, args ...interface{}) (int64, error) {
+	if !tx.Migrator().HasTable(model) {
+		return 0, nil
+	}
+	result := tx.Unscoped().Where(where, args...).Delete(model)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge %T: %w", model, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// sectionLength 统计导出类别中的记录数
+func sectionLength(section interface{}) int {
+	raw, err := json.Marshal(section)
+	if err != nil {
+		return 0
+	}
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) == nil {
+		return len(items)
+	}
+	return 1
+}
+
+// randomUnusableHash 生成无法登录的随机密码哈希
+func randomUnusableHash() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(buf)), bcrypt.MinCost)
+	if err != nil {
+		return "!erased"
+	}
+	return string(hash)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DataRequestServiceTestSuite 数据主体请求服务测试套件
+type DataRequestServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *DataRequestService
+	user    *models.User
+}
+
+func (suite *DataRequestServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.DataSubjectRequest{}, &models.DataRequestAuditLog{}, &models.LetterReply{}))
+	suite.db = db
+
+	suite.service = NewDataRequestService(db, config.GetTestConfig())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	suite.NoError(err)
+	suite.user = config.CreateTestUser(db, "erasureuser", models.RoleUser)
+	db.Model(suite.user).Update("password_hash", string(hash))
+}
+
+// TestRequestErasure_WrongPassword 测试密码错误时拒绝删除申请
+func (suite *DataRequestServiceTestSuite) TestRequestErasure_WrongPassword() {
+	_, err := suite.service.RequestErasure(suite.user.ID, &models.CreateErasureRequest{Password: "wrong"}, "127.0.0.1")
+
+	suite.Error(err)
+	suite.Equal("password is incorrect", err.Error())
+}
+
+// TestRequestErasure_Cancel 测试冷静期内撤销删除申请
+func (suite *DataRequestServiceTestSuite) TestRequestErasure_Cancel() {
+	request, err := suite.service.RequestErasure(suite.user.ID, &models.CreateErasureRequest{Password: "secret123"}, "127.0.0.1")
+	suite.NoError(err)
+	suite.Equal(models.DataRequestStatusScheduled, request.Status)
+
+	_, err = suite.service.RequestErasure(suite.user.ID, &models.CreateErasureRequest{Password: "secret123"}, "127.0.0.1")
+	suite.Error(err)
+
+	suite.NoError(suite.service.CancelErasure(suite.user.ID, request.ID))
+	suite.Error(suite.service.CancelErasure(suite.user.ID, request.ID))
+
+	// 冷静期未结束时不会执行
+	processed, err := suite.service.ProcessDueErasures()
+	suite.NoError(err)
+	suite.Equal(0, processed)
+}
+
+// TestExecuteErasure 测试删除执行：已投递信件匿名化，草稿删除，账户变为墓碑
+func (suite *DataRequestServiceTestSuite) TestExecuteErasure() {
+	delivered := config.CreateTestLetter(suite.db, suite.user.ID)
+	suite.db.Model(delivered).Updates(map[string]interface{}{
+		"status":      models.StatusDelivered,
+		"author_name": "Real Name",
+	})
+
+	draft := &models.Letter{
+		ID:      "draft-letter",
+		UserID:  suite.user.ID,
+		Title:   "Draft",
+		Content: "Private draft",
+		Style:   models.StyleClassic,
+		Status:  models.StatusDraft,
+	}
+	suite.NoError(suite.db.Create(draft).Error)
+
+	readAt := time.Now()
+	readReply := &models.LetterReply{ID: "reply-read", ThreadID: "thread-1", ReplyToLetter: delivered.ID,
+		AuthorID: suite.user.ID, Content: "Read reply", Style: models.StyleClassic, IsPublic: true, DeliveryCode: "REPLY-READ", ReadAt: &readAt}
+	unreadReply := &models.LetterReply{ID: "reply-unread", ThreadID: "thread-1", ReplyToLetter: delivered.ID,
+		AuthorID: suite.user.ID, Content: "Unread reply", Style: models.StyleClassic, DeliveryCode: "REPLY-UNREAD"}
+	suite.NoError(suite.db.Create(readReply).Error)
+	suite.NoError(suite.db.Create(unreadReply).Error)
+
+	suite.NoError(suite.db.Create(&models.OPCodeForward{ID: "forward-1", UserID: suite.user.ID, FromCode: "PK5F3D",
+		ToCode: "PK3D12", Status: models.OPCodeForwardStatusActive, ExpiresAt: time.Now().Add(24 * time.Hour)}).Error)
+	lat, lng := 39.99, 116.31
+	suite.NoError(suite.db.Create(&models.MailboxCollectionRun{ID: "run-1", BoxOPCode: "PK5F01", CourierID: suite.user.ID,
+		Status: models.CollectionRunCompleted, ScheduledAt: time.Now(), Latitude: &lat, Longitude: &lng}).Error)
+	suite.NoError(suite.db.Create(&models.LetterOCRJob{ID: "ocr-job-1", LetterID: delivered.ID, PhotoID: "photo-1",
+		SenderID: suite.user.ID, Status: models.LetterOCRRecognized, Text: "Handwritten text"}).Error)
+	suite.NoError(suite.db.Create(&models.LetterOCRRegion{ID: "ocr-region-1", JobID: "ocr-job-1", Text: "Handwritten"}).Error)
+	suite.NoError(suite.db.Create(&models.OCRPreference{UserID: suite.user.ID}).Error)
+	suite.NoError(suite.db.Create(&models.EnvelopeContestVote{ID: "vote-1", ContestID: "contest-1", DesignID: "design-1",
+		VoterID: suite.user.ID, Weight: 1, Status: models.ContestVoteCounted, IPAddress: "10.0.0.8", DeviceID: "device-1"}).Error)
+
+	request, err := suite.service.RequestErasure(suite.user.ID, &models.CreateErasureRequest{Password: "secret123"}, "127.0.0.1")
+	suite.NoError(err)
+
+	// 模拟冷静期结束
+	past := time.Now().Add(-time.Minute)
+	suite.db.Model(request).Update("scheduled_for", &past)
+
+	processed, err := suite.service.ProcessDueErasures()
+	suite.NoError(err)
+	suite.Equal(1, processed)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", delivered.ID).Error)
+	suite.Equal(erasedAuthorName, letter.AuthorName)
+	suite.Equal(delivered.Content, letter.Content)
+
+	var draftCount int64
+	suite.db.Unscoped().Model(&models.Letter{}).Where("id = ?", draft.ID).Count(&draftCount)
+	suite.Equal(int64(0), draftCount)
+
+	var reply models.LetterReply
+	suite.NoError(suite.db.First(&reply, "id = ?", readReply.ID).Error)
+	suite.False(reply.IsPublic)
+	var unreadCount int64
+	suite.db.Model(&models.LetterReply{}).Where("id = ?", unreadReply.ID).Count(&unreadCount)
+	suite.Equal(int64(0), unreadCount)
+
+	var forwardCount int64
+	suite.db.Model(&models.OPCodeForward{}).Where("user_id = ?", suite.user.ID).Count(&forwardCount)
+	suite.Equal(int64(0), forwardCount)
+
+	var run models.MailboxCollectionRun
+	suite.NoError(suite.db.First(&run, "id = ?", "run-1").Error)
+	suite.Nil(run.Latitude)
+	suite.Nil(run.Longitude)
+
+	var ocrCount int64
+	suite.db.Model(&models.LetterOCRJob{}).Where("sender_id = ?", suite.user.ID).Count(&ocrCount)
+	suite.Equal(int64(0), ocrCount)
+	suite.db.Model(&models.LetterOCRRegion{}).Where("job_id = ?", "ocr-job-1").Count(&ocrCount)
+	suite.Equal(int64(0), ocrCount)
+	suite.db.Model(&models.OCRPreference{}).Where("user_id = ?", suite.user.ID).Count(&ocrCount)
+	suite.Equal(int64(0), ocrCount)
+
+	var vote models.EnvelopeContestVote
+	suite.NoError(suite.db.First(&vote, "id = ?", "vote-1").Error)
+	suite.Empty(vote.IPAddress)
+	suite.Empty(vote.DeviceID)
+
+	var user models.User
+	suite.NoError(suite.db.Unscoped().First(&user, "id = ?", suite.user.ID).Error)
+	suite.False(user.IsActive)
+	suite.Equal("deleted_"+suite.user.ID, user.Username)
+	suite.Contains(user.Email, "@erased.invalid")
+	suite.Error(bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("secret123")))
+
+	var stored models.DataSubjectRequest
+	suite.NoError(suite.db.First(&stored, "id = ?", request.ID).Error)
+	suite.Equal(models.DataRequestStatusCompleted, stored.Status)
+
+	logs, err := suite.service.GetAuditLogs(request.ID)
+	suite.NoError(err)
+	suite.Equal("erased", logs[len(logs)-1].Action)
+}
+
+// TestRequestExport 测试导出包包含个人数据且不含密码哈希
+func (suite *DataRequestServiceTestSuite) TestRequestExport() {
+	letter := config.CreateTestLetter(suite.db, suite.user.ID)
+
+	request, err := suite.service.RequestExport(suite.user.ID, "127.0.0.1")
+	suite.NoError(err)
+	defer func() {
+		os.Remove(request.ArchivePath)
+		os.Remove(dataExportDir)
+		os.Remove(filepath.Dir(dataExportDir))
+	}()
+	suite.Equal(models.DataRequestStatusReady, request.Status)
+	suite.NotNil(request.ExpiresAt)
+
+	reader, err := zip.OpenReader(request.ArchivePath)
+	suite.NoError(err)
+	defer reader.Close()
+	contents := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		suite.NoError(err)
+		raw, err := io.ReadAll(rc)
+		rc.Close()
+		suite.NoError(err)
+		contents[file.Name] = string(raw)
+	}
+	suite.Contains(contents["account.json"], suite.user.ID)
+	suite.NotContains(contents["account.json"], "$2a$")
+	suite.Contains(contents["letters_sent.json"], letter.ID)
+	suite.Contains(contents, "README.txt")
+
+	// 进行中的导出不可重复申请
+	suite.db.Model(request).Update("status", models.DataRequestStatusProcessing)
+	_, err = suite.service.RequestExport(suite.user.ID, "127.0.0.1")
+	suite.Error(err)
+
+	_, file, err := suite.service.OpenExportArchive("other-user", request.ID)
+	suite.Error(err)
+	suite.Nil(file)
+}
+
+func TestDataRequestServiceSuite(t *testing.T) {
+	suite.Run(t, new(DataRequestServiceTestSuite))
+}
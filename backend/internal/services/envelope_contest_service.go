@@ -0,0 +1,822 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	contestFinalizeInterval      = 10 * time.Minute
+	contestDefaultMaxVotes       = 3
+	contestDefaultMaxEntries     = 2
+	contestDefaultMinAccountDays = 7
+	contestDefaultWinnerPrice    = 3.00
+	contestUnverifiedWeight      = 0.5 // 未认证在校身份的票权
+	contestMinWeight             = 0.1 // 风险分数再高也保留的最低票权
+	contestSharedVoterLimit      = 3   // 同一IP或设备的投票账号数达到此值即标记
+	contestBurstWindow           = 10 * time.Minute
+	contestBurstVotes            = 10  // 窗口内单个设计的投票数达到此值才检查集中涌入
+	contestBurstNewAccountShare  = 0.6 // 其中新账号占比超过此值视为刷票
+	contestNewAccountAge         = 30 * 24 * time.Hour
+	contestVoteActionType        = "envelope_contest_vote"
+	contestFlaggedListLimit      = 200
+	contestClusterListLimit      = 20
+)
+
+var (
+	ErrContestNotFound        = errors.New("评选活动不存在")
+	ErrContestInvalidSchedule = errors.New("征稿与投票时间不正确")
+	ErrContestNotOpen         = errors.New("评选活动当前不在此阶段")
+	ErrContestNotEnded        = errors.New("投票尚未结束")
+	ErrContestFinalized       = errors.New("评选活动已结束")
+	ErrContestEntryNotFound   = errors.New("参赛设计不存在")
+	ErrContestEntryLimit      = errors.New("已达到本次评选的投稿上限")
+	ErrContestOwnEntry        = errors.New("不能为自己的设计投票")
+	ErrContestAlreadyVoted    = errors.New("已经为此设计投过票")
+	ErrContestVoteLimit       = errors.New("本次评选的票数已用完")
+	ErrContestVoteNotFound    = errors.New("投票记录不存在")
+	ErrContestAccountTooNew   = errors.New("账号注册时间不足，暂不能参与评选")
+	ErrContestNotVerified     = errors.New("参与评选需要先完成在校身份认证")
+	ErrContestSchoolMismatch  = errors.New("本次评选仅限本校认证用户参与")
+	ErrContestUserBlocked     = errors.New("账号因异常行为暂时不能参与评选")
+)
+
+// EnvelopeContestService 信封设计评选：按征稿期、投票期推进，投票按账号可信度加权并检测刷票，结束后获胜设计自动上架
+type EnvelopeContestService struct {
+	db                    *gorm.DB
+	schoolVerificationSvc *SchoolVerificationService
+	fraudEngine           AntiFraudEngine
+	notificationSvc       *NotificationService
+}
+
+// NewEnvelopeContestService 创建信封设计评选服务
+func NewEnvelopeContestService(db *gorm.DB) *EnvelopeContestService {
+	return &EnvelopeContestService{db: db}
+}
+
+// SetSchoolVerificationService 设置在校身份认证服务；未设置时要求认证的评选无人可参与
+func (s *EnvelopeContestService) SetSchoolVerificationService(schoolVerificationSvc *SchoolVerificationService) {
+	s.schoolVerificationSvc = schoolVerificationSvc
+}
+
+// SetFraudEngine 设置防作弊引擎（积分限制服务），提供封禁、风险分数与异常行为信号
+func (s *EnvelopeContestService) SetFraudEngine(fraudEngine AntiFraudEngine) {
+	s.fraudEngine = fraudEngine
+}
+
+// SetNotificationService 设置通知服务
+func (s *EnvelopeContestService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// Start 启动评选结算协程，投票结束的评选自动评出获胜设计
+func (s *EnvelopeContestService) Start() {
+	go func() {
+		ticker := time.NewTicker(contestFinalizeInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.FinalizeDue(time.Now()); err != nil {
+				log.Printf("Envelope contest: failed to finalize contests: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// CreateContest 管理员创建评选；限定学校的评选要求在校身份认证
+func (s *EnvelopeContestService) CreateContest(adminID string, req *models.CreateEnvelopeContestRequest) (*models.EnvelopeContest, error) {
+	if !req.SubmissionStartAt.Before(req.SubmissionEndAt) || req.VotingStartAt.Before(req.SubmissionEndAt) ||
+		!req.VotingStartAt.Before(req.VotingEndAt) {
+		return nil, ErrContestInvalidSchedule
+	}
+
+	contest := &models.EnvelopeContest{
+		ID:                uuid.New().String(),
+		Title:             req.Title,
+		Theme:             req.Theme,
+		Description:       req.Description,
+		SchoolCode:        strings.ToUpper(req.SchoolCode),
+		Status:            models.ContestStatusActive,
+		SubmissionStartAt: req.SubmissionStartAt,
+		SubmissionEndAt:   req.SubmissionEndAt,
+		VotingStartAt:     req.VotingStartAt,
+		VotingEndAt:       req.VotingEndAt,
+		MaxVotesPerUser:   req.MaxVotesPerUser,
+		MaxEntriesPerUser: req.MaxEntriesPerUser,
+		MinAccountAgeDays: contestDefaultMinAccountDays,
+		RequireVerified:   true,
+		WinnerCount:       req.WinnerCount,
+		WinnerPrice:       req.WinnerPrice,
+		CreatedBy:         adminID,
+	}
+	if contest.MaxVotesPerUser == 0 {
+		contest.MaxVotesPerUser = contestDefaultMaxVotes
+	}
+	if contest.MaxEntriesPerUser == 0 {
+		contest.MaxEntriesPerUser = contestDefaultMaxEntries
+	}
+	if req.MinAccountAgeDays != nil {
+		contest.MinAccountAgeDays = *req.MinAccountAgeDays
+	}
+	if req.RequireVerified != nil {
+		contest.RequireVerified = *req.RequireVerified
+	}
+	if contest.SchoolCode != "" {
+		contest.RequireVerified = true
+	}
+	if contest.WinnerCount == 0 {
+		contest.WinnerCount = 1
+	}
+	if contest.WinnerPrice == 0 {
+		contest.WinnerPrice = contestDefaultWinnerPrice
+	}
+
+	if err := s.db.Create(contest).Error; err != nil {
+		return nil, fmt.Errorf("failed to create contest: %w", err)
+	}
+	contest.Phase = contest.CurrentPhase(time.Now())
+	return contest, nil
+}
+
+// ListContests 评选列表，进行中的在前
+func (s *EnvelopeContestService) ListContests(page, limit int) ([]models.EnvelopeContest, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&models.EnvelopeContest{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var contests []models.EnvelopeContest
+	if err := query.Order("status ASC, voting_end_at DESC").Offset((page - 1) * limit).Limit(limit).
+		Find(&contests).Error; err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	for i := range contests {
+		contests[i].Phase = contests[i].CurrentPhase(now)
+	}
+	return contests, total, nil
+}
+
+func (s *EnvelopeContestService) getContest(contestID string) (*models.EnvelopeContest, error) {
+	var contest models.EnvelopeContest
+	if err := s.db.First(&contest, "id = ?", contestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrContestNotFound
+		}
+		return nil, err
+	}
+	contest.Phase = contest.CurrentPhase(time.Now())
+	return &contest, nil
+}
+
+// GetContest 评选详情与参赛设计；投票结束前只有管理员能看到得分
+func (s *EnvelopeContestService) GetContest(contestID, userID string, isAdmin bool) (*models.EnvelopeContestDetail, error) {
+	contest, err := s.getContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.EnvelopeContestDetail{
+		Contest:        contest,
+		ScoresHidden:   !isAdmin && contest.Phase != models.ContestPhaseEnded && contest.Phase != models.ContestPhaseFinalized,
+		VotedDesignIDs: []string{},
+	}
+
+	order := "created_at ASC"
+	if !detail.ScoresHidden {
+		order = "contest_score DESC, vote_count DESC, created_at ASC"
+	}
+	if err := s.db.Where("contest_id = ?", contest.ID).Order(order).Find(&detail.Entries).Error; err != nil {
+		return nil, err
+	}
+	if detail.ScoresHidden {
+		for i := range detail.Entries {
+			detail.Entries[i].VoteCount = 0
+			detail.Entries[i].ContestScore = 0
+		}
+	}
+
+	if _, err := s.checkEligibility(contest, userID); err != nil {
+		detail.IneligibleReason = err.Error()
+	} else {
+		detail.Eligible = true
+	}
+	if err := s.db.Model(&models.EnvelopeContestVote{}).Where("contest_id = ? AND voter_id = ?", contest.ID, userID).
+		Pluck("design_id", &detail.VotedDesignIDs).Error; err != nil {
+		return nil, err
+	}
+	if remaining := contest.MaxVotesPerUser - len(detail.VotedDesignIDs); remaining > 0 {
+		detail.VotesRemaining = remaining
+	}
+	return detail, nil
+}
+
+// checkEligibility 检查账号状态、注册时长与在校身份认证，返回是否已认证
+func (s *EnvelopeContestService) checkEligibility(contest *models.EnvelopeContest, userID string) (bool, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return false, ErrContestUserBlocked
+	}
+	if !user.IsActive {
+		return false, ErrContestUserBlocked
+	}
+	if time.Since(user.CreatedAt) < time.Duration(contest.MinAccountAgeDays)*24*time.Hour {
+		return false, ErrContestAccountTooNew
+	}
+
+	if s.fraudEngine != nil {
+		if blocked, err := s.fraudEngine.IsUserBlocked(userID); err != nil {
+			log.Printf("Envelope contest: failed to check block status of %s: %v", userID, err)
+		} else if blocked {
+			return false, ErrContestUserBlocked
+		}
+	}
+
+	verified := false
+	var verifyErr error = ErrSchoolNotVerified
+	if s.schoolVerificationSvc != nil {
+		_, verifyErr = s.schoolVerificationSvc.RequireVerified(userID, contest.SchoolCode)
+		verified = verifyErr == nil
+	}
+	if !verified && contest.RequireVerified {
+		switch {
+		case errors.Is(verifyErr, ErrSchoolMismatch):
+			return false, ErrContestSchoolMismatch
+		case errors.Is(verifyErr, ErrSchoolNotVerified):
+			return false, ErrContestNotVerified
+		default:
+			return false, verifyErr
+		}
+	}
+	return verified, nil
+}
+
+// SubmitEntry 征稿期提交参赛设计，评选结束前不可订购
+func (s *EnvelopeContestService) SubmitEntry(contestID, userID string, req *models.SubmitContestEntryRequest) (*models.EnvelopeDesign, error) {
+	contest, err := s.getContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+	if contest.Phase != models.ContestPhaseSubmission {
+		return nil, ErrContestNotOpen
+	}
+	if _, err := s.checkEligibility(contest, userID); err != nil {
+		return nil, err
+	}
+
+	var entries int64
+	s.db.Model(&models.EnvelopeDesign{}).Where("contest_id = ? AND creator_id = ?", contest.ID, userID).Count(&entries)
+	if entries >= int64(contest.MaxEntriesPerUser) {
+		return nil, ErrContestEntryLimit
+	}
+
+	var user models.User
+	if err := s.db.Select("nickname", "username").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	creatorName := user.Nickname
+	if creatorName == "" {
+		creatorName = user.Username
+	}
+	designType := "city"
+	if contest.SchoolCode != "" {
+		designType = "school"
+	}
+
+	design := &models.EnvelopeDesign{
+		ID:           uuid.New().String(),
+		SchoolCode:   contest.SchoolCode,
+		Type:         designType,
+		Theme:        req.Theme,
+		ImageURL:     req.ImageURL,
+		ThumbnailURL: req.ImageURL,
+		CreatorID:    userID,
+		CreatorName:  creatorName,
+		Description:  req.Description,
+		Status:       models.DesignStatusContest,
+		Period:       contest.Title,
+		IsActive:     true,
+		ContestID:    contest.ID,
+	}
+	if err := s.db.Create(design).Error; err != nil {
+		return nil, fmt.Errorf("failed to create contest entry: %w", err)
+	}
+	return design, nil
+}
+
+// Vote 投票期为参赛设计投票；疑似刷票的票照常记录但不计分，等待管理员复核
+func (s *EnvelopeContestService) Vote(contestID, designID, voterID string, meta *models.ContestVoteMeta) (*models.ContestVoteResult, error) {
+	contest, err := s.getContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+	if contest.Phase != models.ContestPhaseVoting {
+		return nil, ErrContestNotOpen
+	}
+
+	var design models.EnvelopeDesign
+	if err := s.db.First(&design, "id = ? AND contest_id = ? AND status = ?", designID, contest.ID, models.DesignStatusContest).
+		Error; err != nil {
+		return nil, ErrContestEntryNotFound
+	}
+	if design.CreatorID == voterID {
+		return nil, ErrContestOwnEntry
+	}
+
+	verified, err := s.checkEligibility(contest, voterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 预先检查，避免注定被拒绝的投票也记入风控行为；以事务内加锁后的复核为准
+	if _, err := checkVoteQuota(s.db, contest, design.ID, voterID); err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &models.ContestVoteMeta{}
+	}
+
+	vote := &models.EnvelopeContestVote{
+		ID:        uuid.New().String(),
+		ContestID: contest.ID,
+		DesignID:  design.ID,
+		VoterID:   voterID,
+		Weight:    s.voteWeight(voterID, verified),
+		Status:    models.ContestVoteCounted,
+		IPAddress: meta.IPAddress,
+		DeviceID:  meta.DeviceID,
+		CreatedAt: time.Now(),
+	}
+	if s.riskAlert(voterID, contest.ID, meta) {
+		vote.Status = models.ContestVoteFlagged
+		vote.FlagReason = models.ContestFlagRisk
+	}
+
+	var used int64
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定投票人账号行，同一用户的并发投票依次计数，避免超出票数上限
+		var voter models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&voter, "id = ?", voterID).Error; err != nil {
+			return err
+		}
+
+		quota, err := checkVoteQuota(tx, contest, design.ID, voterID)
+		if err != nil {
+			return err
+		}
+		used = quota
+
+		if err := tx.Create(vote).Error; err != nil {
+			return err
+		}
+
+		affected := map[string]bool{design.ID: true}
+		if err := s.flagSharedSources(tx, contest.ID, vote, affected); err != nil {
+			return err
+		}
+		if err := s.flagBurst(tx, contest.ID, design.ID, vote.CreatedAt, affected); err != nil {
+			return err
+		}
+		for affectedID := range affected {
+			if err := refreshContestTally(tx, affectedID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrContestAlreadyVoted) || errors.Is(err, ErrContestVoteLimit) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record vote: %w", err)
+	}
+
+	return &models.ContestVoteResult{
+		DesignID:       design.ID,
+		VotesRemaining: contest.MaxVotesPerUser - int(used) - 1,
+	}, nil
+}
+
+// checkVoteQuota 检查是否已投过该设计及剩余票数，返回已用票数
+func checkVoteQuota(db *gorm.DB, contest *models.EnvelopeContest, designID, voterID string) (int64, error) {
+	var used, existing int64
+	if err := db.Model(&models.EnvelopeContestVote{}).Where("contest_id = ? AND voter_id = ?", contest.ID, voterID).
+		Count(&used).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.EnvelopeContestVote{}).Where("design_id = ? AND voter_id = ?", designID, voterID).
+		Count(&existing).Error; err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return used, ErrContestAlreadyVoted
+	}
+	if used >= int64(contest.MaxVotesPerUser) {
+		return used, ErrContestVoteLimit
+	}
+	return used, nil
+}
+
+// voteWeight 票权：未认证减半，再按积分风控的风险分数折减
+func (s *EnvelopeContestService) voteWeight(voterID string, verified bool) float64 {
+	weight := 1.0
+	if !verified {
+		weight = contestUnverifiedWeight
+	}
+	if s.fraudEngine != nil {
+		if risk, err := s.fraudEngine.GetRiskScore(voterID); err == nil {
+			weight *= 1 - math.Min(math.Max(risk, 0), 1)
+		}
+	}
+	return math.Round(math.Max(weight, contestMinWeight)*100) / 100
+}
+
+// riskAlert 将投票记录为积分风控的用户行为，复用其频率、IP、设备异常检测
+func (s *EnvelopeContestService) riskAlert(voterID, contestID string, meta *models.ContestVoteMeta) bool {
+	if s.fraudEngine == nil {
+		return false
+	}
+
+	action := &models.UserCreditAction{
+		ID:         uuid.New().String(),
+		UserID:     voterID,
+		ActionType: contestVoteActionType,
+		IPAddress:  meta.IPAddress,
+		DeviceID:   meta.DeviceID,
+		UserAgent:  meta.UserAgent,
+		Reference:  contestID,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.db.Create(action).Error; err != nil {
+		log.Printf("Envelope contest: failed to record vote action: %v", err)
+	}
+
+	alert, err := s.fraudEngine.DetectAnomalous(voterID, contestVoteActionType, map[string]string{
+		"ip_address": meta.IPAddress,
+		"device_id":  meta.DeviceID,
+		"user_agent": meta.UserAgent,
+		"reference":  contestID,
+		"points":     "0",
+	})
+	if err != nil {
+		log.Printf("Envelope contest: failed to detect anomalous voting: %v", err)
+		return false
+	}
+	return alert != nil && alert.Severity == models.SeverityHigh
+}
+
+// flagSharedSources 同一IP或设备的投票账号达到上限时，标记该来源在本次评选中的全部计分票
+func (s *EnvelopeContestService) flagSharedSources(tx *gorm.DB, contestID string, vote *models.EnvelopeContestVote, affected map[string]bool) error {
+	sources := []struct {
+		column, value, reason string
+	}{
+		{"ip_address", vote.IPAddress, models.ContestFlagSharedIP},
+		{"device_id", vote.DeviceID, models.ContestFlagSharedDevice},
+	}
+	for _, source := range sources {
+		if source.value == "" {
+			continue
+		}
+		var voters int64
+		if err := tx.Model(&models.EnvelopeContestVote{}).
+			Where("contest_id = ? AND "+source.column+" = ?", contestID, source.value).
+			Distinct("voter_id").Count(&voters).Error; err != nil {
+			return err
+		}
+		if voters < contestSharedVoterLimit {
+			continue
+		}
+		if err := flagContestVotes(tx, source.reason, affected,
+			"contest_id = ? AND "+source.column+" = ?", contestID, source.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagBurst 单个设计短时间内涌入大量投票且多数来自新注册账号时，标记其中新账号的票
+func (s *EnvelopeContestService) flagBurst(tx *gorm.DB, contestID, designID string, now time.Time, affected map[string]bool) error {
+	since := now.Add(-contestBurstWindow)
+	var recent []struct {
+		ID        string
+		CreatedAt time.Time
+	}
+	if err := tx.Table("envelope_contest_votes AS v").
+		Select("v.id, u.created_at").
+		Joins("JOIN users u ON u.id = v.voter_id").
+		Where("v.design_id = ? AND v.created_at >= ? AND v.status <> ?", designID, since, models.ContestVoteVoid).
+		Scan(&recent).Error; err != nil {
+		return err
+	}
+	if len(recent) < contestBurstVotes {
+		return nil
+	}
+
+	var newVoteIDs []string
+	for _, r := range recent {
+		if now.Sub(r.CreatedAt) < contestNewAccountAge {
+			newVoteIDs = append(newVoteIDs, r.ID)
+		}
+	}
+	if float64(len(newVoteIDs))/float64(len(recent)) <= contestBurstNewAccountShare {
+		return nil
+	}
+	return flagContestVotes(tx, models.ContestFlagBurst, affected, "contest_id = ? AND id IN ?", contestID, newVoteIDs)
+}
+
+// flagContestVotes 把符合条件的计分票标记为待复核，并记下受影响的设计
+func flagContestVotes(tx *gorm.DB, reason string, affected map[string]bool, query string, args ...interface{}) error {
+	var designIDs []string
+	if err := tx.Model(&models.EnvelopeContestVote{}).Where(query, args...).Where("status = ?", models.ContestVoteCounted).
+		Distinct("design_id").Pluck("design_id", &designIDs).Error; err != nil {
+		return err
+	}
+	if len(designIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.EnvelopeContestVote{}).Where(query, args...).Where("status = ?", models.ContestVoteCounted).
+		Updates(map[string]interface{}{
+			"status":      models.ContestVoteFlagged,
+			"flag_reason": reason,
+		}).Error; err != nil {
+		return err
+	}
+	for _, id := range designIDs {
+		affected[id] = true
+	}
+	return nil
+}
+
+// refreshContestTally 按计分票重算设计的票数与加权得分
+func refreshContestTally(tx *gorm.DB, designID string) error {
+	var tally struct {
+		Votes int
+		Score float64
+	}
+	if err := tx.Model(&models.EnvelopeContestVote{}).
+		Select("COUNT(*) AS votes, COALESCE(SUM(weight), 0) AS score").
+		Where("design_id = ? AND status = ?", designID, models.ContestVoteCounted).
+		Scan(&tally).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.EnvelopeDesign{}).Where("id = ?", designID).Updates(map[string]interface{}{
+		"vote_count":    tally.Votes,
+		"contest_score": math.Round(tally.Score*100) / 100,
+	}).Error
+}
+
+// ReviewVote 管理员复核投票：计入得分或作废
+func (s *EnvelopeContestService) ReviewVote(voteID, adminID, action string) (*models.EnvelopeContestVote, error) {
+	var vote models.EnvelopeContestVote
+	if err := s.db.First(&vote, "id = ?", voteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrContestVoteNotFound
+		}
+		return nil, err
+	}
+	contest, err := s.getContest(vote.ContestID)
+	if err != nil {
+		return nil, err
+	}
+	if contest.Status == models.ContestStatusFinalized {
+		return nil, ErrContestFinalized
+	}
+
+	status := models.ContestVoteVoid
+	if action == "count" {
+		status = models.ContestVoteCounted
+	}
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&vote).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return refreshContestTally(tx, vote.DesignID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+// AnomalyReport 评选刷票检测报告：各状态票数、标记原因、共用IP/设备的账号群与待复核的票
+func (s *EnvelopeContestService) AnomalyReport(contestID string) (*models.ContestAnomalyReport, error) {
+	contest, err := s.getContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ContestAnomalyReport{
+		ContestID:     contest.ID,
+		FlagsByReason: map[string]int64{},
+		Clusters:      []models.ContestVoteCluster{},
+	}
+
+	var byStatus []struct {
+		Status string
+		Count  int64
+	}
+	if err := s.db.Model(&models.EnvelopeContestVote{}).Select("status, COUNT(*) AS count").
+		Where("contest_id = ?", contest.ID).Group("status").Scan(&byStatus).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range byStatus {
+		switch row.Status {
+		case models.ContestVoteCounted:
+			report.CountedVotes = row.Count
+		case models.ContestVoteFlagged:
+			report.FlaggedVotes = row.Count
+		case models.ContestVoteVoid:
+			report.VoidVotes = row.Count
+		}
+	}
+
+	var byReason []struct {
+		FlagReason string
+		Count      int64
+	}
+	if err := s.db.Model(&models.EnvelopeContestVote{}).Select("flag_reason, COUNT(*) AS count").
+		Where("contest_id = ? AND status = ?", contest.ID, models.ContestVoteFlagged).
+		Group("flag_reason").Scan(&byReason).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range byReason {
+		report.FlagsByReason[row.FlagReason] = row.Count
+	}
+
+	for _, source := range []struct{ kind, column string }{{"ip", "ip_address"}, {"device", "device_id"}} {
+		var clusters []struct {
+			Value  string
+			Voters int64
+		}
+		if err := s.db.Model(&models.EnvelopeContestVote{}).
+			Select(source.column+" AS value, COUNT(DISTINCT voter_id) AS voters").
+			Where("contest_id = ? AND "+source.column+" <> ''", contest.ID).
+			Group(source.column).Having("COUNT(DISTINCT voter_id) > 1").
+			Order("voters DESC").Limit(contestClusterListLimit).Scan(&clusters).Error; err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters {
+			report.Clusters = append(report.Clusters, models.ContestVoteCluster{
+				Kind: source.kind, Value: cluster.Value, Voters: cluster.Voters,
+			})
+		}
+	}
+	sort.SliceStable(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Voters > report.Clusters[j].Voters
+	})
+
+	if err := s.db.Where("contest_id = ? AND status = ?", contest.ID, models.ContestVoteFlagged).
+		Order("created_at DESC").Limit(contestFlaggedListLimit).Find(&report.Flagged).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// FinalizeDue 评出所有投票已结束的评选，返回处理的评选数
+func (s *EnvelopeContestService) FinalizeDue(now time.Time) (int, error) {
+	var contests []models.EnvelopeContest
+	if err := s.db.Where("status = ? AND voting_end_at <= ?", models.ContestStatusActive, now).
+		Find(&contests).Error; err != nil {
+		return 0, err
+	}
+
+	finalized := 0
+	for i := range contests {
+		if _, err := s.finalize(&contests[i]); err != nil {
+			if errors.Is(err, ErrContestFinalized) {
+				continue
+			}
+			log.Printf("Envelope contest: failed to finalize %s: %v", contests[i].ID, err)
+			continue
+		}
+		finalized++
+	}
+	return finalized, nil
+}
+
+// Finalize 管理员在投票结束后立即评出获胜设计
+func (s *EnvelopeContestService) Finalize(contestID string) (*models.ContestFinalizeResult, error) {
+	contest, err := s.getContest(contestID)
+	if err != nil {
+		return nil, err
+	}
+	switch contest.Phase {
+	case models.ContestPhaseFinalized:
+		return nil, ErrContestFinalized
+	case models.ContestPhaseEnded:
+	default:
+		return nil, ErrContestNotEnded
+	}
+	return s.finalize(contest)
+}
+
+// finalize 按加权得分排名，前 WinnerCount 名（得分大于0）转为可订购的信封设计，其余标记未获胜
+func (s *EnvelopeContestService) finalize(contest *models.EnvelopeContest) (*models.ContestFinalizeResult, error) {
+	result := &models.ContestFinalizeResult{Contest: contest, Winners: []models.EnvelopeDesign{}}
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.EnvelopeContest{}).Where("id = ? AND status = ?", contest.ID, models.ContestStatusActive).
+			Updates(map[string]interface{}{
+				"status":       models.ContestStatusFinalized,
+				"finalized_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrContestFinalized
+		}
+
+		var entries []models.EnvelopeDesign
+		if err := tx.Where("contest_id = ? AND status = ?", contest.ID, models.DesignStatusContest).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := refreshContestTally(tx, entries[i].ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("contest_id = ? AND status = ?", contest.ID, models.DesignStatusContest).
+			Order("contest_score DESC, vote_count DESC, created_at ASC").Find(&entries).Error; err != nil {
+			return err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			entry.ContestRank = i + 1
+			entry.Status = models.DesignStatusUnplaced
+			entry.IsActive = false
+			if i < contest.WinnerCount && entry.ContestScore > 0 {
+				entry.Status = models.DesignStatusApproved
+				entry.IsActive = true
+				entry.Price = contest.WinnerPrice
+			}
+			if err := tx.Model(&models.EnvelopeDesign{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+				"contest_rank": entry.ContestRank,
+				"status":       entry.Status,
+				"is_active":    entry.IsActive,
+				"price":        entry.Price,
+			}).Error; err != nil {
+				return err
+			}
+			if entry.Status == models.DesignStatusApproved {
+				result.Winners = append(result.Winners, *entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contest.Status = models.ContestStatusFinalized
+	contest.FinalizedAt = &now
+	contest.Phase = models.ContestPhaseFinalized
+	for _, winner := range result.Winners {
+		s.notify(winner.CreatorID, "envelope_contest_won", map[string]interface{}{
+			"contest_id": contest.ID,
+			"design_id":  winner.ID,
+			"rank":       winner.ContestRank,
+			"message":    fmt.Sprintf("你的设计在「%s」中获胜，已上架为可订购的信封", contest.Title),
+		})
+	}
+	return result, nil
+}
+
+func (s *EnvelopeContestService) notify(userID, notificationType string, data map[string]interface{}) {
+	if s.notificationSvc == nil || userID == "" {
+		return
+	}
+	go func() {
+		if err := s.notificationSvc.NotifyUser(userID, notificationType, data); err != nil {
+			log.Printf("Envelope contest: failed to notify %s: %v", userID, err)
+		}
+	}()
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// fakeFraudEngine 按用户设定风险分数、封禁状态与风控告警
+type fakeFraudEngine struct {
+	risk    map[string]float64
+	blocked map[string]bool
+	alerted map[string]bool
+}
+
+func (f *fakeFraudEngine) DetectAnomalous(userID string, actionType string, metadata map[string]string) (*models.FraudAlert, error) {
+	if !f.alerted[userID] {
+		return nil, nil
+	}
+	return &models.FraudAlert{UserID: userID, AlertType: models.AlertTypeFrequency, Severity: models.SeverityHigh}, nil
+}
+
+func (f *fakeFraudEngine) GetRiskScore(userID string) (float64, error) {
+	return f.risk[userID], nil
+}
+
+func (f *fakeFraudEngine) UpdateRiskScore(userID string, increment float64) error {
+	return nil
+}
+
+func (f *fakeFraudEngine) BlockUser(userID string, reason string, duration time.Duration) error {
+	f.blocked[userID] = true
+	return nil
+}
+
+func (f *fakeFraudEngine) IsUserBlocked(userID string) (bool, error) {
+	return f.blocked[userID], nil
+}
+
+// EnvelopeContestTestSuite 信封设计评选测试套件
+type EnvelopeContestTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	service  *EnvelopeContestService
+	fraud    *fakeFraudEngine
+	admin    *models.User
+	designer *models.User
+	voterSeq int
+}
+
+func (suite *EnvelopeContestTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.OPCodeSchool{}, &models.SchoolVerification{}, &models.UserCreditAction{}))
+	suite.db = db
+
+	suite.fraud = &fakeFraudEngine{risk: map[string]float64{}, blocked: map[string]bool{}, alerted: map[string]bool{}}
+	suite.service = NewEnvelopeContestService(db)
+	suite.service.SetSchoolVerificationService(NewSchoolVerificationService(db, config.GetTestConfig()))
+	suite.service.SetFraudEngine(suite.fraud)
+
+	suite.admin = config.CreateTestUser(db, "contest_admin", models.RolePlatformAdmin)
+	suite.designer = suite.createUser("contest_designer", 90, "PK")
+	suite.voterSeq = 0
+}
+
+// createUser 注册 ageDays 天的用户；school 非空时已认证为该校学生
+func (suite *EnvelopeContestTestSuite) createUser(username string, ageDays int, school string) *models.User {
+	user := config.CreateTestUser(suite.db, username, models.RoleUser)
+	createdAt := time.Now().Add(-time.Duration(ageDays) * 24 * time.Hour)
+	suite.NoError(suite.db.Model(user).Update("created_at", createdAt).Error)
+	user.CreatedAt = createdAt
+
+	if school != "" {
+		verifiedAt := time.Now().Add(-24 * time.Hour)
+		expiresAt := time.Now().Add(90 * 24 * time.Hour)
+		suite.NoError(suite.db.Create(&models.SchoolVerification{
+			ID: "verification-" + username, UserID: user.ID, SchoolCode: school, Email: username + "@pku.edu.cn",
+			Status: models.SchoolVerificationVerified, CodeExpiresAt: verifiedAt, VerifiedAt: &verifiedAt, ExpiresAt: &expiresAt,
+		}).Error)
+	}
+	return user
+}
+
+func (suite *EnvelopeContestTestSuite) createVoter(ageDays int, school string) *models.User {
+	suite.voterSeq++
+	return suite.createUser(fmt.Sprintf("contest_voter_%d", suite.voterSeq), ageDays, school)
+}
+
+// createContest 创建处于征稿期的评选
+func (suite *EnvelopeContestTestSuite) createContest(schoolCode string, requireVerified bool) *models.EnvelopeContest {
+	now := time.Now()
+	contest, err := suite.service.CreateContest(suite.admin.ID, &models.CreateEnvelopeContestRequest{
+		Title:             "2026 秋季校园信封",
+		SchoolCode:        schoolCode,
+		SubmissionStartAt: now.Add(-time.Hour),
+		SubmissionEndAt:   now.Add(time.Hour),
+		VotingStartAt:     now.Add(time.Hour),
+		VotingEndAt:       now.Add(2 * time.Hour),
+		MaxVotesPerUser:   2,
+		RequireVerified:   &requireVerified,
+		WinnerCount:       1,
+		WinnerPrice:       4.5,
+	})
+	suite.NoError(err)
+	suite.Equal(models.ContestPhaseSubmission, contest.Phase)
+	return contest
+}
+
+// moveTo 调整评选时间进入指定阶段
+func (suite *EnvelopeContestTestSuite) moveTo(contest *models.EnvelopeContest, phase string) {
+	now := time.Now()
+	votingEnd := now.Add(time.Hour)
+	if phase == models.ContestPhaseEnded {
+		votingEnd = now.Add(-time.Minute)
+	}
+	suite.NoError(suite.db.Model(&models.EnvelopeContest{}).Where("id = ?", contest.ID).Updates(map[string]interface{}{
+		"submission_start_at": now.Add(-3 * time.Hour),
+		"submission_end_at":   now.Add(-2 * time.Hour),
+		"voting_start_at":     now.Add(-2 * time.Hour),
+		"voting_end_at":       votingEnd,
+	}).Error)
+}
+
+func (suite *EnvelopeContestTestSuite) submit(contest *models.EnvelopeContest, user *models.User, theme string) *models.EnvelopeDesign {
+	design, err := suite.service.SubmitEntry(contest.ID, user.ID, &models.SubmitContestEntryRequest{
+		Theme: theme, ImageURL: "/uploads/" + theme + ".png",
+	})
+	suite.NoError(err)
+	return design
+}
+
+func (suite *EnvelopeContestTestSuite) vote(contest *models.EnvelopeContest, design *models.EnvelopeDesign, voter *models.User, ip string) error {
+	_, err := suite.service.Vote(contest.ID, design.ID, voter.ID, &models.ContestVoteMeta{IPAddress: ip})
+	return err
+}
+
+func (suite *EnvelopeContestTestSuite) reloadDesign(id string) models.EnvelopeDesign {
+	var design models.EnvelopeDesign
+	suite.NoError(suite.db.First(&design, "id = ?", id).Error)
+	return design
+}
+
+func (suite *EnvelopeContestTestSuite) TestCreateContest_ValidatesSchedule() {
+	now := time.Now()
+	_, err := suite.service.CreateContest(suite.admin.ID, &models.CreateEnvelopeContestRequest{
+		Title:             "时间错误",
+		SubmissionStartAt: now,
+		SubmissionEndAt:   now.Add(2 * time.Hour),
+		VotingStartAt:     now.Add(time.Hour),
+		VotingEndAt:       now.Add(3 * time.Hour),
+	})
+	suite.ErrorIs(err, ErrContestInvalidSchedule)
+
+	// 限定学校的评选始终要求认证
+	contest := suite.createContest("pk", false)
+	suite.Equal("PK", contest.SchoolCode)
+	suite.True(contest.RequireVerified)
+	suite.Equal(contestDefaultMinAccountDays, contest.MinAccountAgeDays)
+}
+
+func (suite *EnvelopeContestTestSuite) TestEligibility() {
+	contest := suite.createContest("PK", true)
+
+	_, err := suite.service.SubmitEntry(contest.ID, suite.createVoter(1, "PK").ID, &models.SubmitContestEntryRequest{Theme: "a", ImageURL: "/a.png"})
+	suite.ErrorIs(err, ErrContestAccountTooNew)
+	_, err = suite.service.SubmitEntry(contest.ID, suite.createVoter(30, "").ID, &models.SubmitContestEntryRequest{Theme: "a", ImageURL: "/a.png"})
+	suite.ErrorIs(err, ErrContestNotVerified)
+	_, err = suite.service.SubmitEntry(contest.ID, suite.createVoter(30, "QH").ID, &models.SubmitContestEntryRequest{Theme: "a", ImageURL: "/a.png"})
+	suite.ErrorIs(err, ErrContestSchoolMismatch)
+
+	blocked := suite.createVoter(30, "PK")
+	suite.fraud.blocked[blocked.ID] = true
+	_, err = suite.service.SubmitEntry(contest.ID, blocked.ID, &models.SubmitContestEntryRequest{Theme: "a", ImageURL: "/a.png"})
+	suite.ErrorIs(err, ErrContestUserBlocked)
+
+	design := suite.submit(contest, suite.designer, "银杏")
+	suite.Equal(models.DesignStatusContest, design.Status)
+	suite.Equal(contest.ID, design.ContestID)
+	suite.Equal("PK", design.SchoolCode)
+	suite.submit(contest, suite.designer, "未名湖")
+	_, err = suite.service.SubmitEntry(contest.ID, suite.designer.ID, &models.SubmitContestEntryRequest{Theme: "博雅塔", ImageURL: "/c.png"})
+	suite.ErrorIs(err, ErrContestEntryLimit)
+
+	// 征稿期不能投票
+	err = suite.vote(contest, design, suite.createVoter(30, "PK"), "10.0.0.1")
+	suite.ErrorIs(err, ErrContestNotOpen)
+}
+
+func (suite *EnvelopeContestTestSuite) TestVote_WeightsAndCaps() {
+	contest := suite.createContest("", false)
+	first := suite.submit(contest, suite.designer, "银杏")
+	second := suite.submit(contest, suite.designer, "未名湖")
+	third := suite.submit(contest, suite.createVoter(60, "PK"), "博雅塔")
+	suite.moveTo(contest, models.ContestPhaseVoting)
+
+	verified := suite.createVoter(30, "PK")
+	unverified := suite.createVoter(30, "")
+	risky := suite.createVoter(30, "PK")
+	suite.fraud.risk[risky.ID] = 0.6
+
+	suite.NoError(suite.vote(contest, first, verified, "10.0.0.1"))
+	suite.NoError(suite.vote(contest, first, unverified, "10.0.0.2"))
+	suite.NoError(suite.vote(contest, first, risky, "10.0.0.3"))
+
+	design := suite.reloadDesign(first.ID)
+	suite.Equal(3, design.VoteCount)
+	suite.InDelta(1.0+0.5+0.4, design.ContestScore, 0.001)
+
+	suite.ErrorIs(suite.vote(contest, first, verified, "10.0.0.1"), ErrContestAlreadyVoted)
+	suite.ErrorIs(suite.vote(contest, first, suite.designer, "10.0.0.4"), ErrContestOwnEntry)
+	result, err := suite.service.Vote(contest.ID, second.ID, verified.ID, &models.ContestVoteMeta{IPAddress: "10.0.0.1"})
+	suite.NoError(err)
+	suite.Zero(result.VotesRemaining)
+	suite.ErrorIs(suite.vote(contest, third, verified, "10.0.0.1"), ErrContestVoteLimit)
+
+	// 投票期间普通用户看不到得分
+	detail, err := suite.service.GetContest(contest.ID, verified.ID, false)
+	suite.NoError(err)
+	suite.True(detail.ScoresHidden)
+	suite.True(detail.Eligible)
+	suite.Len(detail.Entries, 3)
+	suite.Len(detail.VotedDesignIDs, 2)
+	suite.Zero(detail.VotesRemaining)
+	for _, entry := range detail.Entries {
+		suite.Zero(entry.ContestScore)
+	}
+
+	adminView, err := suite.service.GetContest(contest.ID, suite.admin.ID, true)
+	suite.NoError(err)
+	suite.False(adminView.ScoresHidden)
+	suite.Equal(first.ID, adminView.Entries[0].ID)
+
+	// 投票也作为积分风控的用户行为记录
+	var actions int64
+	suite.db.Model(&models.UserCreditAction{}).Where("action_type = ?", contestVoteActionType).Count(&actions)
+	suite.Equal(int64(4), actions)
+}
+
+func (suite *EnvelopeContestTestSuite) TestVote_FlagsSharedIPAndRiskAlerts() {
+	contest := suite.createContest("", false)
+	design := suite.submit(contest, suite.designer, "银杏")
+	suite.moveTo(contest, models.ContestPhaseVoting)
+
+	honest := suite.createVoter(30, "PK")
+	suite.NoError(suite.vote(contest, design, honest, "10.0.0.1"))
+	for i := 0; i < contestSharedVoterLimit; i++ {
+		suite.NoError(suite.vote(contest, design, suite.createVoter(30, ""), "10.9.9.9"))
+	}
+	alerted := suite.createVoter(30, "PK")
+	suite.fraud.alerted[alerted.ID] = true
+	suite.NoError(suite.vote(contest, design, alerted, "10.0.0.2"))
+
+	reloaded := suite.reloadDesign(design.ID)
+	suite.Equal(1, reloaded.VoteCount)
+	suite.InDelta(1.0, reloaded.ContestScore, 0.001)
+
+	report, err := suite.service.AnomalyReport(contest.ID)
+	suite.NoError(err)
+	suite.Equal(int64(1), report.CountedVotes)
+	suite.Equal(int64(4), report.FlaggedVotes)
+	suite.Equal(int64(3), report.FlagsByReason[models.ContestFlagSharedIP])
+	suite.Equal(int64(1), report.FlagsByReason[models.ContestFlagRisk])
+	suite.Len(report.Clusters, 1)
+	suite.Equal("10.9.9.9", report.Clusters[0].Value)
+	suite.Equal(int64(3), report.Clusters[0].Voters)
+	suite.Len(report.Flagged, 4)
+
+	// 管理员复核后计入得分
+	var alertedVote models.EnvelopeContestVote
+	suite.NoError(suite.db.First(&alertedVote, "voter_id = ?", alerted.ID).Error)
+	_, err = suite.service.ReviewVote(alertedVote.ID, suite.admin.ID, "count")
+	suite.NoError(err)
+	reloaded = suite.reloadDesign(design.ID)
+	suite.Equal(2, reloaded.VoteCount)
+	suite.InDelta(2.0, reloaded.ContestScore, 0.001)
+
+	_, err = suite.service.ReviewVote("missing", suite.admin.ID, "void")
+	suite.ErrorIs(err, ErrContestVoteNotFound)
+}
+
+func (suite *EnvelopeContestTestSuite) TestVote_FlagsBurstOfNewAccounts() {
+	now := time.Now()
+	minAge := 0
+	notRequired := false
+	contest, err := suite.service.CreateContest(suite.admin.ID, &models.CreateEnvelopeContestRequest{
+		Title:             "开放评选",
+		SubmissionStartAt: now.Add(-time.Hour),
+		SubmissionEndAt:   now.Add(time.Hour),
+		VotingStartAt:     now.Add(time.Hour),
+		VotingEndAt:       now.Add(2 * time.Hour),
+		MinAccountAgeDays: &minAge,
+		RequireVerified:   &notRequired,
+	})
+	suite.NoError(err)
+	design := suite.submit(contest, suite.designer, "银杏")
+	suite.moveTo(contest, models.ContestPhaseVoting)
+
+	veteran := suite.createVoter(120, "PK")
+	suite.NoError(suite.vote(contest, design, veteran, "10.0.1.1"))
+	for i := 0; i < contestBurstVotes-1; i++ {
+		suite.NoError(suite.vote(contest, design, suite.createVoter(2, ""), fmt.Sprintf("10.0.2.%d", i)))
+	}
+
+	// 老账号的票保留，新账号集中涌入的票被标记
+	reloaded := suite.reloadDesign(design.ID)
+	suite.Equal(1, reloaded.VoteCount)
+	var burst int64
+	suite.db.Model(&models.EnvelopeContestVote{}).Where("flag_reason = ?", models.ContestFlagBurst).Count(&burst)
+	suite.Equal(int64(contestBurstVotes-1), burst)
+}
+
+func (suite *EnvelopeContestTestSuite) TestFinalize_PublishesWinner() {
+	contest := suite.createContest("", false)
+	winner := suite.submit(contest, suite.designer, "银杏")
+	runnerUp := suite.submit(contest, suite.createVoter(60, "PK"), "未名湖")
+	empty := suite.submit(contest, suite.createVoter(60, "PK"), "博雅塔")
+	suite.moveTo(contest, models.ContestPhaseVoting)
+
+	for i := 0; i < 2; i++ {
+		suite.NoError(suite.vote(contest, winner, suite.createVoter(30, "PK"), fmt.Sprintf("10.0.3.%d", i)))
+	}
+	suite.NoError(suite.vote(contest, runnerUp, suite.createVoter(30, "PK"), "10.0.4.1"))
+
+	_, err := suite.service.Finalize(contest.ID)
+	suite.ErrorIs(err, ErrContestNotEnded)
+
+	suite.moveTo(contest, models.ContestPhaseEnded)
+	finalized, err := suite.service.FinalizeDue(time.Now())
+	suite.NoError(err)
+	suite.Equal(1, finalized)
+
+	published := suite.reloadDesign(winner.ID)
+	suite.Equal(models.DesignStatusApproved, published.Status)
+	suite.True(published.IsActive)
+	suite.Equal(4.5, published.Price)
+	suite.Equal(1, published.ContestRank)
+
+	second := suite.reloadDesign(runnerUp.ID)
+	suite.Equal(models.DesignStatusUnplaced, second.Status)
+	suite.False(second.IsActive)
+	suite.Equal(2, second.ContestRank)
+	suite.Equal(models.DesignStatusUnplaced, suite.reloadDesign(empty.ID).Status)
+
+	// 获胜设计可直接订购
+	envelopeService := NewEnvelopeService(suite.db)
+	order, err := envelopeService.CreateEnvelopeOrder(suite.admin.ID, winner.ID, 2)
+	suite.NoError(err)
+	suite.Equal(9.0, order.TotalPrice)
+	_, err = envelopeService.CreateEnvelopeOrder(suite.admin.ID, runnerUp.ID, 1)
+	suite.Error(err)
+
+	// 参赛设计不能绕过评选直接投票
+	suite.Error(envelopeService.VoteForDesign(suite.admin.ID, runnerUp.ID))
+
+	_, err = suite.service.Finalize(contest.ID)
+	suite.ErrorIs(err, ErrContestFinalized)
+	finalized, err = suite.service.FinalizeDue(time.Now())
+	suite.NoError(err)
+	suite.Zero(finalized)
+
+	var stored models.EnvelopeContest
+	suite.NoError(suite.db.First(&stored, "id = ?", contest.ID).Error)
+	suite.Equal(models.ContestStatusFinalized, stored.Status)
+	suite.NotNil(stored.FinalizedAt)
+}
+
+func TestEnvelopeContestService(t *testing.T) {
+	suite.Run(t, new(EnvelopeContestTestSuite))
+}
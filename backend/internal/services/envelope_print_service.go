@@ -0,0 +1,370 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/label"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPrintLayoutUnknown      = errors.New("不支持的标签排版")
+	ErrPrintNoEnvelopes        = errors.New("没有可打印的信封")
+	ErrPrintMixedDesigns       = errors.New("同一批次只能打印同一款信封设计")
+	ErrPrintForbidden          = errors.New("无权打印这些信封")
+	ErrPrintBatchNotFound      = errors.New("打印批次不存在")
+	ErrPrintEnvelopeNotInBatch = errors.New("重印的信封不在原批次中")
+	ErrArtworkNotAllowed       = errors.New("信封设计图地址不在允许范围内")
+)
+
+// maxArtworkSize 信封设计图下载大小上限
+const maxArtworkSize = 10 << 20
+
+// EnvelopePrintService 信封标签打印服务
+// 按排版将信封条码渲染为 PDF，每次打印和重印都记录批次及其中的信封，便于追溯
+type EnvelopePrintService struct {
+	db         *gorm.DB
+	config     *config.Config
+	httpClient *http.Client
+}
+
+// NewEnvelopePrintService 创建信封标签打印服务
+func NewEnvelopePrintService(db *gorm.DB, config *config.Config) *EnvelopePrintService {
+	return &EnvelopePrintService{
+		db:         db,
+		config:     config,
+		httpClient: newArtworkHTTPClient(config.LabelArtworkHosts),
+	}
+}
+
+// newArtworkHTTPClient 下载设计图的客户端：只访问允许的域名，且拒绝连接内网和回环地址
+// 地址检查在建立连接时进行，重定向和 DNS 重绑定也无法绕过
+func newArtworkHTTPClient(allowedHosts string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrArtworkNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 || !artworkHostAllowed(req.URL, allowedHosts) {
+				return ErrArtworkNotAllowed
+			}
+			return nil
+		},
+	}
+}
+
+// artworkHostAllowed 设计图地址是否为允许的外部域名（含子域名）
+func artworkHostAllowed(u *url.URL, allowedHosts string) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range strings.Split(allowedHosts, ",") {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (host == allowed || strings.HasSuffix(host, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublicIP 是否为公网地址
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// GetLayouts 可用的标签排版
+func (s *EnvelopePrintService) GetLayouts() []label.Layout {
+	return label.Layouts()
+}
+
+// CreatePrintBatch 创建打印批次，canPrintAll 为 false 时只能打印自己购买的信封
+func (s *EnvelopePrintService) CreatePrintBatch(userID string, canPrintAll bool, req *models.CreatePrintBatchRequest) (*models.EnvelopePrintBatch, error) {
+	query := s.db.Model(&models.Envelope{}).Where("status <> ?", models.EnvelopeStatusCancelled)
+	switch {
+	case len(req.EnvelopeIDs) > 0:
+		query = query.Where("id IN ?", req.EnvelopeIDs)
+	case req.OrderID != "":
+		var order models.EnvelopeOrder
+		if err := s.db.First(&order, "id = ?", req.OrderID).Error; err != nil {
+			return nil, fmt.Errorf("订单不存在: %w", err)
+		}
+		if !canPrintAll && order.UserID != userID {
+			return nil, ErrPrintForbidden
+		}
+		// 订单生成的信封条码以订单号前8位为前缀
+		query = query.Where("design_id = ? AND user_id = ? AND barcode_id LIKE ?", order.DesignID, order.UserID, "ENV-"+req.OrderID[:min(8, len(req.OrderID))]+"-%")
+	default:
+		return nil, ErrPrintNoEnvelopes
+	}
+
+	var envelopes []models.Envelope
+	if err := query.Order("barcode_id ASC").Find(&envelopes).Error; err != nil {
+		return nil, err
+	}
+
+	batch := &models.EnvelopePrintBatch{
+		OrderID:       req.OrderID,
+		Layout:        req.Layout,
+		Symbology:     req.Symbology,
+		StartPosition: req.StartPosition,
+		CreatedBy:     userID,
+	}
+	if err := s.renderBatch(batch, envelopes, userID, canPrintAll); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// ReprintBatch 重印整批或其中部分信封，生成新的批次并关联原批次
+func (s *EnvelopePrintService) ReprintBatch(batchID, userID string, canPrintAll bool, req *models.ReprintBatchRequest) (*models.EnvelopePrintBatch, error) {
+	original, err := s.GetPrintBatch(batchID, userID, canPrintAll)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopeIDs := make([]string, 0, len(original.Items))
+	inBatch := make(map[string]bool, len(original.Items))
+	for _, item := range original.Items {
+		inBatch[item.EnvelopeID] = true
+		envelopeIDs = append(envelopeIDs, item.EnvelopeID)
+	}
+	if len(req.EnvelopeIDs) > 0 {
+		for _, id := range req.EnvelopeIDs {
+			if !inBatch[id] {
+				return nil, ErrPrintEnvelopeNotInBatch
+			}
+		}
+		envelopeIDs = req.EnvelopeIDs
+	}
+
+	var envelopes []models.Envelope
+	if err := s.db.Where("id IN ?", envelopeIDs).Order("barcode_id ASC").Find(&envelopes).Error; err != nil {
+		return nil, err
+	}
+
+	layout := req.Layout
+	if layout == "" {
+		layout = original.Layout
+	}
+	batch := &models.EnvelopePrintBatch{
+		OrderID:       original.OrderID,
+		Layout:        layout,
+		Symbology:     original.Symbology,
+		StartPosition: req.StartPosition,
+		ReprintOfID:   &original.ID,
+		Reason:        req.Reason,
+		CreatedBy:     userID,
+	}
+	if err := s.renderBatch(batch, envelopes, userID, canPrintAll); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// GetPrintBatch 获取打印批次及其中的信封
+func (s *EnvelopePrintService) GetPrintBatch(batchID, userID string, canViewAll bool) (*models.EnvelopePrintBatch, error) {
+	var batch models.EnvelopePrintBatch
+	err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).First(&batch, "id = ?", batchID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPrintBatchNotFound
+		}
+		return nil, err
+	}
+	if !canViewAll && batch.CreatedBy != userID {
+		return nil, ErrPrintBatchNotFound
+	}
+	return &batch, nil
+}
+
+// ListPrintBatches 打印批次列表，按时间倒序
+func (s *EnvelopePrintService) ListPrintBatches(userID string, canViewAll bool, orderID string, page, limit int) ([]models.EnvelopePrintBatch, int64, error) {
+	query := s.db.Model(&models.EnvelopePrintBatch{})
+	if !canViewAll {
+		query = query.Where("created_by = ?", userID)
+	}
+	if orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var batches []models.EnvelopePrintBatch
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&batches).Error
+	return batches, total, err
+}
+
+// GetEnvelopePrintHistory 信封的全部打印记录
+func (s *EnvelopePrintService) GetEnvelopePrintHistory(envelopeID string) ([]models.EnvelopePrintBatch, error) {
+	var batches []models.EnvelopePrintBatch
+	err := s.db.Where("id IN (?)", s.db.Model(&models.EnvelopePrintItem{}).Select("batch_id").Where("envelope_id = ?", envelopeID)).
+		Order("created_at ASC").Find(&batches).Error
+	return batches, err
+}
+
+// OpenBatchFile 打开批次 PDF 文件
+func (s *EnvelopePrintService) OpenBatchFile(batch *models.EnvelopePrintBatch) (*os.File, error) {
+	return os.Open(batch.FilePath)
+}
+
+// renderBatch 校验信封、渲染 PDF 并保存批次记录
+func (s *EnvelopePrintService) renderBatch(batch *models.EnvelopePrintBatch, envelopes []models.Envelope, userID string, canPrintAll bool) error {
+	layout, ok := label.GetLayout(batch.Layout)
+	if !ok {
+		return ErrPrintLayoutUnknown
+	}
+	if batch.Symbology == "" {
+		batch.Symbology = models.PrintSymbologyCode128
+	}
+	if len(envelopes) == 0 {
+		return ErrPrintNoEnvelopes
+	}
+
+	designID := envelopes[0].DesignID
+	for _, envelope := range envelopes {
+		if envelope.DesignID != designID {
+			return ErrPrintMixedDesigns
+		}
+		if !canPrintAll && envelope.UserID != userID {
+			return ErrPrintForbidden
+		}
+	}
+
+	var design models.EnvelopeDesign
+	if err := s.db.First(&design, "id = ?", designID).Error; err != nil {
+		return fmt.Errorf("信封设计不存在: %w", err)
+	}
+	background := s.loadArtwork(design.ImageURL)
+
+	labels := make([]label.Label, len(envelopes))
+	for i, envelope := range envelopes {
+		labels[i] = label.Label{
+			Code:            envelope.BarcodeID,
+			RecipientOPCode: models.WithOPCodeCheckChar(envelope.RecipientOPCode), // 标签上印带校验位的格式
+			SenderOPCode:    models.WithOPCodeCheckChar(envelope.SenderOPCode),
+			Caption:         design.Theme,
+		}
+	}
+	content, pages, err := label.Render(labels, label.Options{
+		Layout:        layout,
+		Symbology:     batch.Symbology,
+		Background:    background,
+		StartPosition: batch.StartPosition,
+	})
+	if err != nil {
+		return fmt.Errorf("渲染标签失败: %w", err)
+	}
+
+	batch.ID = uuid.New().String()
+	batch.DesignID = designID
+	batch.LabelCount = len(labels)
+	batch.PageCount = pages
+	batch.ArtworkApplied = background != nil
+	sum := sha256.Sum256(content)
+	batch.Checksum = hex.EncodeToString(sum[:])
+
+	if err := os.MkdirAll(s.config.LabelStorePath, 0755); err != nil {
+		return fmt.Errorf("创建标签目录失败: %w", err)
+	}
+	batch.FilePath = filepath.Join(s.config.LabelStorePath, batch.ID+".pdf")
+	if err := os.WriteFile(batch.FilePath, content, 0644); err != nil {
+		return fmt.Errorf("保存标签文件失败: %w", err)
+	}
+
+	batch.Items = make([]models.EnvelopePrintItem, len(envelopes))
+	for i, envelope := range envelopes {
+		batch.Items[i] = models.EnvelopePrintItem{
+			ID:         uuid.New().String(),
+			BatchID:    batch.ID,
+			EnvelopeID: envelope.ID,
+			BarcodeID:  envelope.BarcodeID,
+			Position:   i + 1,
+		}
+	}
+	if err := s.db.Create(batch).Error; err != nil {
+		os.Remove(batch.FilePath)
+		return fmt.Errorf("保存打印批次失败: %w", err)
+	}
+	return nil
+}
+
+// loadArtwork 加载信封设计图，失败时不使用背景继续打印
+func (s *EnvelopePrintService) loadArtwork(imageURL string) *label.Image {
+	if imageURL == "" {
+		return nil
+	}
+
+	data, err := s.readArtwork(imageURL)
+	if err == nil {
+		var img *label.Image
+		if img, err = label.LoadImage(data); err == nil {
+			return img
+		}
+	}
+	log.Printf("Envelope artwork %s unavailable, printing without background: %v", imageURL, err)
+	return nil
+}
+
+func (s *EnvelopePrintService) readArtwork(imageURL string) ([]byte, error) {
+	// 本服务上传的文件直接读取本地 uploads 目录
+	localPath := strings.TrimPrefix(imageURL, strings.TrimRight(s.config.BaseURL, "/"))
+	if strings.HasPrefix(localPath, "/uploads/") {
+		cleaned := filepath.Clean(localPath)
+		if !strings.HasPrefix(cleaned, string(filepath.Separator)+"uploads"+string(filepath.Separator)) {
+			return nil, ErrArtworkNotAllowed
+		}
+		return os.ReadFile(filepath.Join(".", cleaned))
+	}
+
+	// 设计图地址由用户提交，只下载配置允许的外部域名
+	u, err := url.Parse(imageURL)
+	if err != nil || !artworkHostAllowed(u, s.config.LabelArtworkHosts) {
+		return nil, ErrArtworkNotAllowed
+	}
+
+	resp, err := s.httpClient.Get(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("artwork download returned %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxArtworkSize))
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// EnvelopePrintServiceTestSuite 信封标签打印服务测试套件
+type EnvelopePrintServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *EnvelopePrintService
+	owner   *models.User
+	other   *models.User
+	order   *models.EnvelopeOrder
+}
+
+func (suite *EnvelopePrintServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+
+	// 设计图放在工作目录的 uploads 下，按本地文件读取
+	workDir := suite.T().TempDir()
+	oldDir, _ := os.Getwd()
+	suite.NoError(os.Chdir(workDir))
+	suite.T().Cleanup(func() { os.Chdir(oldDir) })
+	suite.NoError(os.MkdirAll("uploads/designs", 0755))
+	artwork := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			artwork.Set(x, y, color.RGBA{R: 200, G: 120, B: 80, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	suite.NoError(png.Encode(&buf, artwork))
+	suite.NoError(os.WriteFile("uploads/designs/spring.png", buf.Bytes(), 0644))
+
+	cfg := config.GetTestConfig()
+	cfg.LabelStorePath = filepath.Join(workDir, "labels")
+	suite.service = NewEnvelopePrintService(db, cfg)
+
+	suite.owner = config.CreateTestUser(db, "printowner", models.RoleUser)
+	suite.other = config.CreateTestUser(db, "printother", models.RoleUser)
+
+	suite.NoError(db.Create(&models.EnvelopeDesign{
+		ID: "design-1", SchoolCode: "PK", Theme: "春日", ImageURL: cfg.BaseURL + "/uploads/designs/spring.png",
+		CreatorID: suite.owner.ID, Status: models.DesignStatusApproved,
+	}).Error)
+	suite.order = &models.EnvelopeOrder{
+		ID: "order123-0000-0000-0000-000000000000", UserID: suite.owner.ID, DesignID: "design-1",
+		Quantity: 30, TotalPrice: 90, Status: "paid", DeliveryInfo: "{}",
+	}
+	suite.NoError(db.Create(suite.order).Error)
+
+	service := NewEnvelopeService(db)
+	suite.NoError(service.GenerateEnvelopesForOrder(suite.order.ID))
+}
+
+func (suite *EnvelopePrintServiceTestSuite) TestCreatePrintBatch_Order() {
+	batch, err := suite.service.CreatePrintBatch(suite.owner.ID, false, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID,
+		Layout:  "a4_3x8",
+	})
+	suite.NoError(err)
+	suite.Equal(30, batch.LabelCount)
+	suite.Equal(2, batch.PageCount)
+	suite.Equal(models.PrintSymbologyCode128, batch.Symbology)
+	suite.True(batch.ArtworkApplied)
+	suite.Len(batch.Items, 30)
+	suite.Equal("ENV-order123-001", batch.Items[0].BarcodeID)
+
+	content, err := os.ReadFile(batch.FilePath)
+	suite.NoError(err)
+	suite.True(bytes.HasPrefix(content, []byte("%PDF-1.4")))
+	suite.Contains(string(content), "(ENV-order123-001) Tj")
+	suite.Contains(string(content), "/Subtype /Image")
+
+	// 续打用过 22 个标签位的纸，首页只剩 2 个
+	batch, err = suite.service.CreatePrintBatch(suite.owner.ID, false, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID, Layout: "a4_3x8", Symbology: models.PrintSymbologyQR, StartPosition: 22,
+	})
+	suite.NoError(err)
+	suite.Equal(3, batch.PageCount)
+}
+
+func (suite *EnvelopePrintServiceTestSuite) TestCreatePrintBatch_Validation() {
+	_, err := suite.service.CreatePrintBatch(suite.other.ID, false, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID, Layout: "a4_3x8",
+	})
+	suite.ErrorIs(err, ErrPrintForbidden)
+
+	_, err = suite.service.CreatePrintBatch(suite.owner.ID, false, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID, Layout: "a5_1x1",
+	})
+	suite.ErrorIs(err, ErrPrintLayoutUnknown)
+
+	// 管理员可打印他人的信封
+	batch, err := suite.service.CreatePrintBatch(suite.other.ID, true, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID, Layout: "envelope_dl",
+	})
+	suite.NoError(err)
+	suite.Equal(30, batch.PageCount)
+}
+
+func (suite *EnvelopePrintServiceTestSuite) TestReprintBatch() {
+	original, err := suite.service.CreatePrintBatch(suite.owner.ID, false, &models.CreatePrintBatchRequest{
+		OrderID: suite.order.ID, Layout: "a4_3x8",
+	})
+	suite.NoError(err)
+	damaged := []string{original.Items[3].EnvelopeID, original.Items[4].EnvelopeID}
+
+	_, err = suite.service.ReprintBatch(original.ID, suite.other.ID, false, &models.ReprintBatchRequest{Reason: "卡纸"})
+	suite.ErrorIs(err, ErrPrintBatchNotFound)
+
+	_, err = suite.service.ReprintBatch(original.ID, suite.owner.ID, false, &models.ReprintBatchRequest{
+		Reason: "卡纸", EnvelopeIDs: []string{"not-in-batch"},
+	})
+	suite.ErrorIs(err, ErrPrintEnvelopeNotInBatch)
+
+	time.Sleep(10 * time.Millisecond)
+	reprint, err := suite.service.ReprintBatch(original.ID, suite.owner.ID, false, &models.ReprintBatchRequest{
+		Reason: "卡纸", EnvelopeIDs: damaged,
+	})
+	suite.NoError(err)
+	suite.Equal(original.ID, *reprint.ReprintOfID)
+	suite.Equal(2, reprint.LabelCount)
+	suite.Equal("卡纸", reprint.Reason)
+
+	history, err := suite.service.GetEnvelopePrintHistory(damaged[0])
+	suite.NoError(err)
+	suite.Len(history, 2)
+	suite.Equal(original.ID, history[0].ID)
+	suite.Equal(reprint.ID, history[1].ID)
+
+	batches, total, err := suite.service.ListPrintBatches(suite.owner.ID, false, suite.order.ID, 1, 10)
+	suite.NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Equal(reprint.ID, batches[0].ID)
+}
+
+// TestReadArtwork_RejectsUntrustedLocations 测试设计图只读取 uploads 和允许的外部公网域名
+func (suite *EnvelopePrintServiceTestSuite) TestReadArtwork_RejectsUntrustedLocations() {
+	suite.NoError(os.WriteFile("secret.txt", []byte("secret"), 0644))
+	_, err := suite.service.readArtwork("/uploads/../secret.txt")
+	suite.ErrorIs(err, ErrArtworkNotAllowed)
+
+	data, err := suite.service.readArtwork("/uploads/designs/spring.png")
+	suite.NoError(err)
+	suite.NotEmpty(data)
+
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer server.Close()
+
+	// 未配置允许的域名时不下载任何外部地址
+	_, err = suite.service.readArtwork(server.URL + "/art.png")
+	suite.ErrorIs(err, ErrArtworkNotAllowed)
+	_, err = suite.service.readArtwork("file:///etc/passwd")
+	suite.ErrorIs(err, ErrArtworkNotAllowed)
+
+	// 即使域名在允许范围内，解析到回环地址也拒绝连接
+	cfg := config.GetTestConfig()
+	cfg.LabelArtworkHosts = "127.0.0.1"
+	service := NewEnvelopePrintService(suite.db, cfg)
+	_, err = service.readArtwork(server.URL + "/art.png")
+	suite.Error(err)
+	suite.Zero(hits)
+}
+
+func TestEnvelopePrintServiceSuite(t *testing.T) {
+	suite.Run(t, new(EnvelopePrintServiceTestSuite))
+}
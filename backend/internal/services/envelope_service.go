@@ -109,6 +109,18 @@ func (s *EnvelopeService) GetDesigns(filters map[string]interface{}) ([]models.E
 
 // VoteForDesign 为设计投票
 func (s *EnvelopeService) VoteForDesign(userID, designID string) error {
+	// 评选中的设计只能通过评选投票，由评选服务统一限票与检测刷票
+	var design models.EnvelopeDesign
+	if err := s.db.Select("id", "contest_id").First(&design, "id = ?", designID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("信封设计不存在")
+		}
+		return fmt.Errorf("查询设计失败: %v", err)
+	}
+	if design.ContestID != "" {
+		return errors.New("参赛设计请在评选活动中投票")
+	}
+
 	// 检查是否已投票
 	var count int64
 	if err := s.db.Model(&models.EnvelopeVote{}).
@@ -0,0 +1,611 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	letterOCRPollInterval   = 30 * time.Second
+	letterOCRBatchSize      = 10
+	letterOCRMaxAttempts    = 3
+	letterOCRJobTimeout     = 3 * time.Minute
+	letterOCRStaleAfter     = 10 * time.Minute // 识别中超过此时长视为进程中断，重新排队
+	letterOCRDefaultLang    = "zh"
+	letterOCRMaxErrorLength = 500
+)
+
+var (
+	ErrLetterOCRNotConfigured   = errors.New("文字识别服务未配置")
+	ErrLetterOCRForbidden       = errors.New("无权操作此信件的照片或识别结果")
+	ErrLetterOCRLetterNotFound  = errors.New("信件不存在")
+	ErrLetterOCRPhotoNotFound   = errors.New("照片不存在")
+	ErrLetterOCRPhotoInvalid    = errors.New("图片文件不存在或不是本人上传的图片")
+	ErrLetterOCRJobNotFound     = errors.New("识别任务不存在")
+	ErrLetterOCRJobActive       = errors.New("该照片已有进行中的识别任务")
+	ErrLetterOCRNotReviewable   = errors.New("识别任务不在待确认状态")
+	ErrLetterOCREmptyText       = errors.New("识别文字为空")
+	ErrLetterOCRNotInMuseum     = errors.New("信件尚未收录到博物馆")
+	ErrLetterOCRLetterSent      = errors.New("信件已寄出，识别文字不能再写入正文")
+	ErrLetterOCRMuseumEdited    = errors.New("已收录的藏品只能写入未经修改的识别原文")
+	errLetterOCRJobAlreadyTaken = errors.New("ocr job already taken")
+)
+
+// LetterOCRService 手写信件识别：照片上传后按寄信人设置排队识别，寄信人确认后写入信件正文或博物馆藏品
+type LetterOCRService struct {
+	db              *gorm.DB
+	config          *config.Config
+	client          OCRClient
+	storageSvc      *StorageService
+	notificationSvc *NotificationService
+	wake            chan struct{}
+}
+
+// NewLetterOCRService 创建手写信件识别服务
+func NewLetterOCRService(db *gorm.DB, cfg *config.Config) *LetterOCRService {
+	return &LetterOCRService{
+		db:     db,
+		config: cfg,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetClient 设置文字识别实现，未设置时照片照常保存但不识别
+func (s *LetterOCRService) SetClient(client OCRClient) {
+	s.client = client
+}
+
+// SetStorageService 设置存储服务（读取照片原图）
+func (s *LetterOCRService) SetStorageService(storageSvc *StorageService) {
+	s.storageSvc = storageSvc
+}
+
+// SetNotificationService 设置通知服务
+func (s *LetterOCRService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// Start 启动识别协程，新任务入队时立即唤醒
+func (s *LetterOCRService) Start() {
+	go func() {
+		ticker := time.NewTicker(letterOCRPollInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.ProcessPending(); err != nil {
+				log.Printf("Letter OCR: failed to process jobs: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+func (s *LetterOCRService) notifyWorker() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// GetPreference 获取用户的识别设置，未设置时为不识别
+func (s *LetterOCRService) GetPreference(userID string) (*models.OCRPreference, error) {
+	preference := &models.OCRPreference{UserID: userID}
+	err := s.db.First(preference, "user_id = ?", userID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return preference, nil
+}
+
+// SetPreference 开启或关闭自动识别
+func (s *LetterOCRService) SetPreference(userID string, enabled bool) (*models.OCRPreference, error) {
+	preference := &models.OCRPreference{UserID: userID, Enabled: enabled, UpdatedAt: time.Now()}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(preference).Error; err != nil {
+		return nil, err
+	}
+	return preference, nil
+}
+
+// canAddPhoto 寄信人、管理员，或持有该信件任务的信使可以为信件拍照
+func (s *LetterOCRService) canAddPhoto(letter *models.Letter, userID string) bool {
+	if letter.UserID == userID || s.isAdmin(userID) {
+		return true
+	}
+
+	var code models.LetterCode
+	if err := s.db.Select("code").First(&code, "letter_id = ?", letter.ID).Error; err != nil {
+		return false
+	}
+	var count int64
+	s.db.Model(&models.CourierTask{}).Where("letter_code = ? AND courier_id = ?", code.Code, userID).Count(&count)
+	return count > 0
+}
+
+func (s *LetterOCRService) isAdmin(userID string) bool {
+	var user models.User
+	if err := s.db.Select("role").First(&user, "id = ?", userID).Error; err != nil {
+		return false
+	}
+	return user.Role == models.RolePlatformAdmin || user.Role == models.RoleSuperAdmin
+}
+
+// AddPhoto 为信件添加照片；寄信人开启识别时自动创建识别任务
+func (s *LetterOCRService) AddPhoto(letterID, userID string, req *models.AddLetterPhotoRequest) (*models.AddLetterPhotoResponse, error) {
+	var letter models.Letter
+	if err := s.db.First(&letter, "id = ?", letterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLetterOCRLetterNotFound
+		}
+		return nil, err
+	}
+	if !s.canAddPhoto(&letter, userID) {
+		return nil, ErrLetterOCRForbidden
+	}
+
+	var file models.StorageFile
+	if err := s.db.First(&file, "id = ? AND uploaded_by = ? AND category = ? AND status = ?",
+		req.FileID, userID, models.FileCategoryImage, models.FileStatusActive).Error; err != nil {
+		return nil, ErrLetterOCRPhotoInvalid
+	}
+
+	preference, err := s.GetPreference(letter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	imageURL := file.PublicURL
+	if imageURL == "" {
+		imageURL = file.PrivateURL
+	}
+	photo := &models.LetterPhoto{
+		ID:         uuid.New().String(),
+		LetterID:   letter.ID,
+		ImageURL:   imageURL,
+		FileID:     file.ID,
+		UploadedBy: userID,
+		IsPublic:   req.IsPublic,
+	}
+	response := &models.AddLetterPhotoResponse{Photo: photo}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(photo).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.StorageFile{}).Where("id = ?", file.ID).
+			Updates(map[string]interface{}{"related_type": "letter", "related_id": letter.ID}).Error; err != nil {
+			return err
+		}
+
+		if !preference.Enabled || s.client == nil {
+			return nil
+		}
+		job, err := s.enqueue(tx, &letter, photo.ID, userID)
+		if err != nil {
+			return err
+		}
+		response.OCRJob = job
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.OCRJob != nil {
+		s.notifyWorker()
+	}
+	return response, nil
+}
+
+// RequestOCR 寄信人手动对一张照片发起识别，视为本次同意识别
+func (s *LetterOCRService) RequestOCR(letterID, photoID, userID string) (*models.LetterOCRJob, error) {
+	if s.client == nil {
+		return nil, ErrLetterOCRNotConfigured
+	}
+
+	var photo models.LetterPhoto
+	if err := s.db.Preload("Letter").First(&photo, "id = ? AND letter_id = ?", photoID, letterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLetterOCRPhotoNotFound
+		}
+		return nil, err
+	}
+	if photo.Letter.UserID != userID {
+		return nil, ErrLetterOCRForbidden
+	}
+	if photo.FileID == "" {
+		return nil, ErrLetterOCRPhotoInvalid
+	}
+
+	var active int64
+	s.db.Model(&models.LetterOCRJob{}).Where("photo_id = ? AND status IN ?", photoID,
+		[]string{models.LetterOCRPending, models.LetterOCRProcessing, models.LetterOCRRecognized}).Count(&active)
+	if active > 0 {
+		return nil, ErrLetterOCRJobActive
+	}
+
+	job, err := s.enqueue(s.db, &photo.Letter, photo.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyWorker()
+	return job, nil
+}
+
+func (s *LetterOCRService) enqueue(tx *gorm.DB, letter *models.Letter, photoID, requestedBy string) (*models.LetterOCRJob, error) {
+	job := &models.LetterOCRJob{
+		ID:          uuid.New().String(),
+		LetterID:    letter.ID,
+		PhotoID:     photoID,
+		SenderID:    letter.UserID,
+		RequestedBy: requestedBy,
+		Status:      models.LetterOCRPending,
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ProcessPending 处理排队中的识别任务，返回本次完成识别的任务数
+func (s *LetterOCRService) ProcessPending() (int, error) {
+	if s.client == nil {
+		return 0, nil
+	}
+
+	// 识别中途进程退出的任务重新排队
+	if err := s.db.Model(&models.LetterOCRJob{}).
+		Where("status = ? AND started_at < ?", models.LetterOCRProcessing, time.Now().Add(-letterOCRStaleAfter)).
+		Update("status", models.LetterOCRPending).Error; err != nil {
+		return 0, err
+	}
+
+	var jobs []models.LetterOCRJob
+	if err := s.db.Where("status = ?", models.LetterOCRPending).Order("created_at ASC").
+		Limit(letterOCRBatchSize).Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	recognized := 0
+	for i := range jobs {
+		err := s.processJob(&jobs[i])
+		if errors.Is(err, errLetterOCRJobAlreadyTaken) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Letter OCR: job %s failed: %v", jobs[i].ID, err)
+			continue
+		}
+		recognized++
+	}
+	return recognized, nil
+}
+
+func (s *LetterOCRService) processJob(job *models.LetterOCRJob) error {
+	now := time.Now()
+	res := s.db.Model(&models.LetterOCRJob{}).Where("id = ? AND status = ?", job.ID, models.LetterOCRPending).
+		Updates(map[string]interface{}{
+			"status":     models.LetterOCRProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"started_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errLetterOCRJobAlreadyTaken
+	}
+	job.Attempts++
+
+	result, err := s.recognize(job)
+	if err != nil {
+		return s.failAttempt(job, err)
+	}
+
+	completedAt := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", job.ID).Delete(&models.LetterOCRRegion{}).Error; err != nil {
+			return err
+		}
+		for i, region := range result.Regions {
+			if err := tx.Create(&models.LetterOCRRegion{
+				ID:         uuid.New().String(),
+				JobID:      job.ID,
+				Seq:        i + 1,
+				Text:       region.Text,
+				Confidence: region.Confidence,
+				X1:         region.BBox[0],
+				Y1:         region.BBox[1],
+				X2:         region.BBox[2],
+				Y2:         region.BBox[3],
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.LetterOCRJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":       models.LetterOCRRecognized,
+			"text":         result.Text,
+			"confidence":   result.Confidence,
+			"engine":       result.Engine,
+			"error":        "",
+			"completed_at": completedAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notify(job.SenderID, "letter_ocr_ready", map[string]interface{}{
+		"job_id":     job.ID,
+		"letter_id":  job.LetterID,
+		"confidence": result.Confidence,
+		"message":    "手写信件识别完成，请确认识别文字",
+	})
+	return nil
+}
+
+// recognize 读取照片原图并调用识别服务
+func (s *LetterOCRService) recognize(job *models.LetterOCRJob) (*OCRResult, error) {
+	if s.storageSvc == nil {
+		return nil, fmt.Errorf("storage service not configured")
+	}
+
+	var photo models.LetterPhoto
+	if err := s.db.First(&photo, "id = ?", job.PhotoID).Error; err != nil {
+		return nil, fmt.Errorf("photo not found: %w", err)
+	}
+	var file models.StorageFile
+	if err := s.db.First(&file, "id = ?", photo.FileID).Error; err != nil {
+		return nil, fmt.Errorf("photo file not found: %w", err)
+	}
+	content, err := s.storageSvc.OpenFileContent(&file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), letterOCRJobTimeout)
+	defer cancel()
+	return s.client.Recognize(ctx, &OCRRequest{
+		Image:       content,
+		FileName:    file.OriginalName,
+		Language:    letterOCRDefaultLang,
+		Handwriting: true,
+		RequestedBy: job.RequestedBy,
+	})
+}
+
+// failAttempt 识别失败时重新排队，超过次数后标记失败并通知寄信人
+func (s *LetterOCRService) failAttempt(job *models.LetterOCRJob, cause error) error {
+	message := cause.Error()
+	if len(message) > letterOCRMaxErrorLength {
+		message = message[:letterOCRMaxErrorLength]
+	}
+
+	status := models.LetterOCRPending
+	if job.Attempts >= letterOCRMaxAttempts {
+		status = models.LetterOCRFailed
+	}
+	if err := s.db.Model(&models.LetterOCRJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status": status,
+		"error":  message,
+	}).Error; err != nil {
+		return err
+	}
+
+	if status == models.LetterOCRFailed {
+		s.notify(job.SenderID, "letter_ocr_failed", map[string]interface{}{
+			"job_id":    job.ID,
+			"letter_id": job.LetterID,
+			"message":   "手写信件识别失败，可以稍后重新发起识别",
+		})
+	}
+	return cause
+}
+
+// ListJobs 寄信人的识别任务
+func (s *LetterOCRService) ListJobs(userID, letterID, status string, page, limit int) ([]models.LetterOCRJob, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&models.LetterOCRJob{}).Where("sender_id = ?", userID)
+	if letterID != "" {
+		query = query.Where("letter_id = ?", letterID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []models.LetterOCRJob
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// GetJob 识别任务详情，含每段文字的位置与置信度
+func (s *LetterOCRService) GetJob(jobID, userID string) (*models.LetterOCRJob, error) {
+	var job models.LetterOCRJob
+	if err := s.db.Preload("Regions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("seq ASC")
+	}).First(&job, "id = ?", jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLetterOCRJobNotFound
+		}
+		return nil, err
+	}
+	if job.SenderID != userID && !s.isAdmin(userID) {
+		return nil, ErrLetterOCRForbidden
+	}
+	return &job, nil
+}
+
+// Review 寄信人确认识别结果，确认后写入信件正文或博物馆藏品
+func (s *LetterOCRService) Review(jobID, userID string, req *models.ReviewOCRJobRequest) (*models.LetterOCRJob, error) {
+	var job models.LetterOCRJob
+	if err := s.db.First(&job, "id = ?", jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLetterOCRJobNotFound
+		}
+		return nil, err
+	}
+	if job.SenderID != userID {
+		return nil, ErrLetterOCRForbidden
+	}
+	if job.Status != models.LetterOCRRecognized {
+		return nil, ErrLetterOCRNotReviewable
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"reviewed_by": userID,
+		"reviewed_at": now,
+	}
+
+	if req.Action == "reject" {
+		updates["status"] = models.LetterOCRRejected
+		if err := s.db.Model(&models.LetterOCRJob{}).Where("id = ? AND status = ?", job.ID, models.LetterOCRRecognized).
+			Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		return s.GetJob(job.ID, userID)
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		text = strings.TrimSpace(job.Text)
+	}
+	if text == "" {
+		return nil, ErrLetterOCREmptyText
+	}
+	target := req.Target
+	if target == "" {
+		target = models.LetterOCRTargetLetter
+	}
+	// 藏品文字对所有人公开且可被搜索，寄信人不能借识别结果改写藏品内容
+	if target == models.LetterOCRTargetMuseum && text != strings.TrimSpace(job.Text) {
+		return nil, ErrLetterOCRMuseumEdited
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = models.LetterOCRModeReplace
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.LetterOCRJob{}).Where("id = ? AND status = ?", job.ID, models.LetterOCRRecognized).
+			Updates(map[string]interface{}{
+				"status":        models.LetterOCRApproved,
+				"reviewed_text": text,
+				"target":        target,
+				"reviewed_by":   userID,
+				"reviewed_at":   now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrLetterOCRNotReviewable
+		}
+
+		if target == models.LetterOCRTargetMuseum {
+			var item models.MuseumItem
+			if err := tx.Where("source_type = ? AND source_id = ?", models.SourceTypeLetter, job.LetterID).
+				First(&item).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrLetterOCRNotInMuseum
+				}
+				return err
+			}
+			if item.SubmittedBy != userID {
+				return ErrLetterOCRForbidden
+			}
+			return tx.Model(&item).Update("transcript", mergeOCRText(item.Transcript, text, mode)).Error
+		}
+
+		// 寄出后的信件正文已由收信人读到，只允许写入草稿
+		var letter models.Letter
+		if err := tx.First(&letter, "id = ?", job.LetterID).Error; err != nil {
+			return err
+		}
+		if letter.Status != models.StatusDraft {
+			return ErrLetterOCRLetterSent
+		}
+		return tx.Model(&letter).Where("status = ?", models.StatusDraft).
+			Update("content", mergeOCRText(letter.Content, text, mode)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetJob(job.ID, userID)
+}
+
+// mergeOCRText 覆盖或追加识别文字
+func mergeOCRText(existing, text, mode string) string {
+	if mode == models.LetterOCRModeAppend && strings.TrimSpace(existing) != "" {
+		return existing + "\n\n" + text
+	}
+	return text
+}
+
+// SearchHandwritten 在已收录博物馆的手写信件识别文字中搜索
+func (s *LetterOCRService) SearchHandwritten(query string, page, limit int) ([]models.MuseumItem, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	db := s.db.Model(&models.MuseumItem{}).
+		Where("status = ? AND transcript IS NOT NULL AND transcript <> ''", models.MuseumItemApproved)
+	if query = strings.TrimSpace(query); query != "" {
+		like := "%" + query + "%"
+		db = db.Where("transcript LIKE ? OR title LIKE ?", like, like)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []models.MuseumItem
+	if err := db.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (s *LetterOCRService) notify(userID, notificationType string, data map[string]interface{}) {
+	if s.notificationSvc == nil || userID == "" {
+		return
+	}
+	go func() {
+		if err := s.notificationSvc.NotifyUser(userID, notificationType, data); err != nil {
+			log.Printf("Letter OCR: failed to notify %s: %v", userID, err)
+		}
+	}()
+}
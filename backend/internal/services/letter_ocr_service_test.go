@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// fakeOCRClient 记录收到的图片，按设定返回结果或错误
+type fakeOCRClient struct {
+	result *OCRResult
+	err    error
+	images []string
+	calls  int
+}
+
+func (f *fakeOCRClient) Recognize(ctx context.Context, req *OCRRequest) (*OCRResult, error) {
+	f.calls++
+	data, _ := io.ReadAll(req.Image)
+	f.images = append(f.images, string(data))
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.result, nil
+}
+
+// LetterOCRTestSuite 手写信件识别测试套件
+type LetterOCRTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *LetterOCRService
+	client  *fakeOCRClient
+	sender  *models.User
+	courier *models.User
+	other   *models.User
+	letter  *models.Letter
+	baseDir string
+}
+
+func (suite *LetterOCRTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.LetterPhoto{}, &models.StorageFile{}, &models.StorageConfig{}))
+	suite.db = db
+
+	suite.baseDir = suite.T().TempDir()
+	suite.NoError(db.Create(&models.StorageConfig{
+		ID: uuid.New().String(), Provider: models.StorageProviderLocal, DisplayName: "本地存储",
+		Config: `{"base_path": "` + suite.baseDir + `", "base_url": "/uploads"}`, IsEnabled: true, IsDefault: true,
+	}).Error)
+
+	suite.client = &fakeOCRClient{result: &OCRResult{
+		Text: "亲爱的朋友\n见字如面", Confidence: 0.82, Engine: "paddle",
+		Regions: []OCRRegion{
+			{Text: "亲爱的朋友", Confidence: 0.9, BBox: [4]int{10, 10, 200, 40}},
+			{Text: "见字如面", Confidence: 0.74, BBox: [4]int{10, 50, 180, 80}},
+		},
+	}}
+	suite.service = NewLetterOCRService(db, &config.Config{})
+	suite.service.SetClient(suite.client)
+	suite.service.SetStorageService(NewStorageService(db, &config.Config{}))
+
+	suite.sender = config.CreateTestUser(db, "ocr_sender", models.RoleUser)
+	suite.courier = config.CreateTestUser(db, "ocr_courier", models.RoleCourierLevel1)
+	suite.other = config.CreateTestUser(db, "ocr_other", models.RoleUser)
+
+	suite.letter = &models.Letter{
+		ID: uuid.New().String(), UserID: suite.sender.ID, Title: "手写的信", Content: "草稿",
+		Status: models.StatusInTransit,
+	}
+	suite.NoError(db.Create(suite.letter).Error)
+	suite.NoError(db.Create(&models.LetterCode{
+		ID: uuid.New().String(), LetterID: suite.letter.ID, Code: "OPOCR0000001", Status: models.BarcodeStatusInTransit,
+	}).Error)
+	suite.NoError(db.Create(&models.CourierTask{
+		ID: uuid.New().String(), LetterCode: "OPOCR0000001", CourierID: suite.courier.ID, Status: models.CourierTaskStatusCollected,
+	}).Error)
+}
+
+// uploadImage 模拟通过存储接口上传的图片
+func (suite *LetterOCRTestSuite) uploadImage(userID, content string) *models.StorageFile {
+	id := uuid.New().String()
+	objectKey := filepath.Join("images", id+".jpg")
+	suite.NoError(os.MkdirAll(filepath.Join(suite.baseDir, "images"), 0755))
+	suite.NoError(os.WriteFile(filepath.Join(suite.baseDir, objectKey), []byte(content), 0644))
+
+	file := &models.StorageFile{
+		ID: id, FileName: id + ".jpg", OriginalName: "letter.jpg", FileSize: int64(len(content)),
+		MimeType: "image/jpeg", Category: models.FileCategoryImage, Provider: models.StorageProviderLocal,
+		ObjectKey: objectKey, PublicURL: "/uploads/" + objectKey, UploadedBy: userID, Status: models.FileStatusActive,
+	}
+	suite.NoError(suite.db.Create(file).Error)
+	return file
+}
+
+// recognizedJob 寄信人开启识别并完成一次识别
+func (suite *LetterOCRTestSuite) recognizedJob() *models.LetterOCRJob {
+	_, err := suite.service.SetPreference(suite.sender.ID, true)
+	suite.NoError(err)
+	file := suite.uploadImage(suite.sender.ID, "photo-bytes")
+	response, err := suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.NoError(err)
+	suite.NotNil(response.OCRJob)
+	processed, err := suite.service.ProcessPending()
+	suite.NoError(err)
+	suite.Equal(1, processed)
+	return response.OCRJob
+}
+
+func (suite *LetterOCRTestSuite) TestAddPhoto_RequiresSenderOptIn() {
+	preference, err := suite.service.GetPreference(suite.sender.ID)
+	suite.NoError(err)
+	suite.False(preference.Enabled)
+
+	file := suite.uploadImage(suite.sender.ID, "photo-bytes")
+	response, err := suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.NoError(err)
+	suite.Equal(file.ID, response.Photo.FileID)
+	suite.Equal(file.PublicURL, response.Photo.ImageURL)
+	suite.Nil(response.OCRJob)
+
+	var stored models.StorageFile
+	suite.NoError(suite.db.First(&stored, "id = ?", file.ID).Error)
+	suite.Equal("letter", stored.RelatedType)
+	suite.Equal(suite.letter.ID, stored.RelatedID)
+
+	// 信使拍的照片按寄信人的设置识别
+	_, err = suite.service.SetPreference(suite.sender.ID, true)
+	suite.NoError(err)
+	courierFile := suite.uploadImage(suite.courier.ID, "courier-photo")
+	response, err = suite.service.AddPhoto(suite.letter.ID, suite.courier.ID, &models.AddLetterPhotoRequest{FileID: courierFile.ID})
+	suite.NoError(err)
+	suite.NotNil(response.OCRJob)
+	suite.Equal(suite.sender.ID, response.OCRJob.SenderID)
+	suite.Equal(suite.courier.ID, response.OCRJob.RequestedBy)
+
+	// 关闭后不再识别
+	_, err = suite.service.SetPreference(suite.sender.ID, false)
+	suite.NoError(err)
+	response, err = suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: suite.uploadImage(suite.sender.ID, "x").ID})
+	suite.NoError(err)
+	suite.Nil(response.OCRJob)
+}
+
+func (suite *LetterOCRTestSuite) TestAddPhoto_Permissions() {
+	file := suite.uploadImage(suite.other.ID, "photo-bytes")
+	_, err := suite.service.AddPhoto(suite.letter.ID, suite.other.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.ErrorIs(err, ErrLetterOCRForbidden)
+
+	// 不能使用别人上传的文件
+	_, err = suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.ErrorIs(err, ErrLetterOCRPhotoInvalid)
+
+	_, err = suite.service.AddPhoto(uuid.New().String(), suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.ErrorIs(err, ErrLetterOCRLetterNotFound)
+}
+
+func (suite *LetterOCRTestSuite) TestProcessPending_StoresRegions() {
+	job := suite.recognizedJob()
+	suite.Equal([]string{"photo-bytes"}, suite.client.images)
+
+	detail, err := suite.service.GetJob(job.ID, suite.sender.ID)
+	suite.NoError(err)
+	suite.Equal(models.LetterOCRRecognized, detail.Status)
+	suite.Equal("亲爱的朋友\n见字如面", detail.Text)
+	suite.Equal("paddle", detail.Engine)
+	suite.Equal(1, detail.Attempts)
+	suite.NotNil(detail.CompletedAt)
+	suite.Len(detail.Regions, 2)
+	suite.Equal(1, detail.Regions[0].Seq)
+	suite.Equal("见字如面", detail.Regions[1].Text)
+	suite.Equal(0.74, detail.Regions[1].Confidence)
+	suite.Equal(180, detail.Regions[1].X2)
+
+	// 识别结果只有寄信人能看
+	_, err = suite.service.GetJob(job.ID, suite.other.ID)
+	suite.ErrorIs(err, ErrLetterOCRForbidden)
+
+	// 识别不会直接改动信件
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal("草稿", letter.Content)
+}
+
+func (suite *LetterOCRTestSuite) TestProcessPending_RetriesThenFails() {
+	suite.client.err = errors.New("ocr service unavailable")
+	_, err := suite.service.SetPreference(suite.sender.ID, true)
+	suite.NoError(err)
+	file := suite.uploadImage(suite.sender.ID, "photo-bytes")
+	response, err := suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.NoError(err)
+
+	for i := 1; i <= letterOCRMaxAttempts; i++ {
+		processed, err := suite.service.ProcessPending()
+		suite.NoError(err)
+		suite.Zero(processed)
+
+		var job models.LetterOCRJob
+		suite.NoError(suite.db.First(&job, "id = ?", response.OCRJob.ID).Error)
+		suite.Equal(i, job.Attempts)
+		suite.Contains(job.Error, "unavailable")
+		if i < letterOCRMaxAttempts {
+			suite.Equal(models.LetterOCRPending, job.Status)
+		} else {
+			suite.Equal(models.LetterOCRFailed, job.Status)
+		}
+	}
+
+	_, err = suite.service.ProcessPending()
+	suite.NoError(err)
+	suite.Equal(letterOCRMaxAttempts, suite.client.calls)
+
+	// 失败后寄信人可以重新发起识别
+	suite.client.err = nil
+	job, err := suite.service.RequestOCR(suite.letter.ID, response.Photo.ID, suite.sender.ID)
+	suite.NoError(err)
+	_, err = suite.service.RequestOCR(suite.letter.ID, response.Photo.ID, suite.sender.ID)
+	suite.ErrorIs(err, ErrLetterOCRJobActive)
+	processed, err := suite.service.ProcessPending()
+	suite.NoError(err)
+	suite.Equal(1, processed)
+
+	detail, err := suite.service.GetJob(job.ID, suite.sender.ID)
+	suite.NoError(err)
+	suite.Equal(models.LetterOCRRecognized, detail.Status)
+}
+
+func (suite *LetterOCRTestSuite) TestRequestOCR() {
+	file := suite.uploadImage(suite.sender.ID, "photo-bytes")
+	response, err := suite.service.AddPhoto(suite.letter.ID, suite.sender.ID, &models.AddLetterPhotoRequest{FileID: file.ID})
+	suite.NoError(err)
+
+	_, err = suite.service.RequestOCR(suite.letter.ID, response.Photo.ID, suite.courier.ID)
+	suite.ErrorIs(err, ErrLetterOCRForbidden)
+	_, err = suite.service.RequestOCR(suite.letter.ID, uuid.New().String(), suite.sender.ID)
+	suite.ErrorIs(err, ErrLetterOCRPhotoNotFound)
+
+	unconfigured := NewLetterOCRService(suite.db, &config.Config{})
+	_, err = unconfigured.RequestOCR(suite.letter.ID, response.Photo.ID, suite.sender.ID)
+	suite.ErrorIs(err, ErrLetterOCRNotConfigured)
+
+	job, err := suite.service.RequestOCR(suite.letter.ID, response.Photo.ID, suite.sender.ID)
+	suite.NoError(err)
+	suite.Equal(models.LetterOCRPending, job.Status)
+}
+
+func (suite *LetterOCRTestSuite) TestReview_ApproveToLetter() {
+	job := suite.recognizedJob()
+
+	_, err := suite.service.Review(job.ID, suite.other.ID, &models.ReviewOCRJobRequest{Action: "approve"})
+	suite.ErrorIs(err, ErrLetterOCRForbidden)
+
+	// 寄出后的信件正文不能改写，任务仍待确认
+	_, err = suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{Action: "approve"})
+	suite.ErrorIs(err, ErrLetterOCRLetterSent)
+	var pending models.LetterOCRJob
+	suite.NoError(suite.db.First(&pending, "id = ?", job.ID).Error)
+	suite.Equal(models.LetterOCRRecognized, pending.Status)
+
+	suite.db.Model(suite.letter).Update("status", models.StatusDraft)
+	reviewed, err := suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{
+		Action: "approve", Text: "亲爱的朋友：\n见字如面。", Mode: models.LetterOCRModeAppend,
+	})
+	suite.NoError(err)
+	suite.Equal(models.LetterOCRApproved, reviewed.Status)
+	suite.Equal(models.LetterOCRTargetLetter, reviewed.Target)
+	suite.Equal(suite.sender.ID, reviewed.ReviewedBy)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal("草稿\n\n亲爱的朋友：\n见字如面。", letter.Content)
+
+	// 已确认的结果不能再次确认
+	_, err = suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{Action: "approve"})
+	suite.ErrorIs(err, ErrLetterOCRNotReviewable)
+}
+
+func (suite *LetterOCRTestSuite) TestReview_Reject() {
+	job := suite.recognizedJob()
+
+	reviewed, err := suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{Action: "reject"})
+	suite.NoError(err)
+	suite.Equal(models.LetterOCRRejected, reviewed.Status)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal("草稿", letter.Content)
+}
+
+func (suite *LetterOCRTestSuite) TestReview_ApproveToMuseumAndSearch() {
+	job := suite.recognizedJob()
+
+	_, err := suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{
+		Action: "approve", Target: models.LetterOCRTargetMuseum,
+	})
+	suite.ErrorIs(err, ErrLetterOCRNotInMuseum)
+
+	itemID := uuid.New().String()
+	suite.NoError(suite.db.Create(&models.MuseumItem{
+		ID: itemID, SourceType: models.SourceTypeLetter, SourceID: suite.letter.ID,
+		Title: "毕业那年的信", Status: models.MuseumItemApproved, SubmittedBy: suite.courier.ID,
+	}).Error)
+
+	// 藏品由他人提交时寄信人不能写入
+	_, err = suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{
+		Action: "approve", Target: models.LetterOCRTargetMuseum,
+	})
+	suite.ErrorIs(err, ErrLetterOCRForbidden)
+	suite.NoError(suite.db.Model(&models.MuseumItem{}).Where("id = ?", itemID).Update("submitted_by", suite.sender.ID).Error)
+
+	// 已收录的藏品不能写入修改过的文字
+	_, err = suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{
+		Action: "approve", Target: models.LetterOCRTargetMuseum, Text: "改写后的内容",
+	})
+	suite.ErrorIs(err, ErrLetterOCRMuseumEdited)
+
+	_, err = suite.service.Review(job.ID, suite.sender.ID, &models.ReviewOCRJobRequest{
+		Action: "approve", Target: models.LetterOCRTargetMuseum,
+	})
+	suite.NoError(err)
+
+	var item models.MuseumItem
+	suite.NoError(suite.db.First(&item, "source_id = ?", suite.letter.ID).Error)
+	suite.Equal("亲爱的朋友\n见字如面", item.Transcript)
+
+	// 信件正文保持不变
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal("草稿", letter.Content)
+
+	items, total, err := suite.service.SearchHandwritten("见字如面", 1, 20)
+	suite.NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal(item.ID, items[0].ID)
+
+	_, total, err = suite.service.SearchHandwritten("不存在的句子", 1, 20)
+	suite.NoError(err)
+	suite.Zero(total)
+}
+
+// TestHTTPOCRClient_ServiceKey 测试调用 ocr-service 使用服务密钥而不是用户令牌
+func (suite *LetterOCRTestSuite) TestHTTPOCRClient_ServiceKey() {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"code":0,"data":{"results":{"text":"你好","confidence":0.9,"blocks":[]},"metadata":{"processing_method":"paddle"}}}`))
+	}))
+	defer server.Close()
+
+	cfg := config.GetTestConfig()
+	cfg.OCRServiceURL = server.URL
+	cfg.OCRServiceAPIKey = "ocr-service-key"
+	result, err := NewHTTPOCRClient(cfg).Recognize(context.Background(), &OCRRequest{
+		Image: strings.NewReader("image"), FileName: "letter.png", Language: "zh", RequestedBy: suite.sender.ID,
+	})
+	suite.NoError(err)
+	suite.Equal("你好", result.Text)
+	suite.Equal("ocr-service-key", header.Get("X-Service-Key"))
+	suite.Equal(suite.sender.ID, header.Get("X-Requested-By"))
+	suite.Empty(header.Get("Authorization"))
+}
+
+func TestLetterOCRService(t *testing.T) {
+	suite.Run(t, new(LetterOCRTestSuite))
+}
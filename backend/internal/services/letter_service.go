@@ -28,8 +28,10 @@ type LetterService struct {
 	creditTaskSvc   *CreditTaskService // 积分任务服务
 	aiSvc           *AIService
 	wsService       *websocket.WebSocketService
-	opcodeService   *OPCodeService // OP Code验证服务
-	userSvc         *UserService   // 用户服务
+	opcodeService   *OPCodeService        // OP Code验证服务
+	userSvc         *UserService          // 用户服务
+	qrTokenSvc      *QRTokenService       // 二维码签名令牌服务
+	forwardSvc      *OPCodeForwardService // OP Code转寄服务
 }
 
 func NewLetterService(db *gorm.DB, config *config.Config) *LetterService {
@@ -39,6 +41,11 @@ func NewLetterService(db *gorm.DB, config *config.Config) *LetterService {
 	}
 }
 
+// SetOPCodeForwardService 设置OP Code转寄服务，写信时按收件人登记的转寄改投
+func (s *LetterService) SetOPCodeForwardService(forwardSvc *OPCodeForwardService) {
+	s.forwardSvc = forwardSvc
+}
+
 // SetCourierTaskService 设置信使任务服务（避免循环依赖）
 func (s *LetterService) SetCourierTaskService(courierTaskSvc *CourierTaskService) {
 	s.courierTaskSvc = courierTaskSvc
@@ -69,6 +76,11 @@ func (s *LetterService) SetAIService(aiSvc *AIService) {
 	s.aiSvc = aiSvc
 }
 
+// SetQRTokenService 设置二维码签名令牌服务，设置后生成的二维码携带签名令牌
+func (s *LetterService) SetQRTokenService(qrTokenSvc *QRTokenService) {
+	s.qrTokenSvc = qrTokenSvc
+}
+
 // SetOPCodeService 设置OP Code服务（避免循环依赖）
 func (s *LetterService) SetOPCodeService(opcodeService *OPCodeService) {
 	s.opcodeService = opcodeService
@@ -88,28 +100,29 @@ func (s *LetterService) GetDB() *gorm.DB {
 func (s *LetterService) CreateDraft(userID string, req *models.CreateLetterRequest) (*models.Letter, error) {
 	// PRD要求：验证收件人OP Code
 	if req.RecipientOPCode != "" {
-		// 验证OP Code格式
-		if err := models.ValidateOPCode(req.RecipientOPCode); err != nil {
-			return nil, fmt.Errorf("收件人OP Code格式不正确: %w", err)
-		}
-
-		// 验证OP Code是否存在（如果有OP Code服务）
+		// 验证OP Code是否存在（如果有OP Code服务），支持带校验位格式，写错时附带候选编码
 		if s.opcodeService != nil {
-			isValid, err := s.opcodeService.ValidateOPCode(req.RecipientOPCode)
+			code, err := s.opcodeService.RequireOPCode(req.RecipientOPCode, req.SenderOPCode)
 			if err != nil {
-				return nil, fmt.Errorf("OP Code验证失败: %w", err)
+				return nil, fmt.Errorf("收件人OP Code无效: %w", err)
 			}
-			if !isValid {
-				return nil, fmt.Errorf("收件人OP Code不存在或已失效")
+			req.RecipientOPCode = code
+		} else {
+			code, _, err := models.NormalizeOPCodeInput(req.RecipientOPCode)
+			if err != nil {
+				return nil, fmt.Errorf("收件人OP Code格式不正确: %w", err)
 			}
+			req.RecipientOPCode = code
 		}
 	}
 
 	// 验证发件人OP Code（如果提供）
 	if req.SenderOPCode != "" {
-		if err := models.ValidateOPCode(req.SenderOPCode); err != nil {
+		code, _, err := models.NormalizeOPCodeInput(req.SenderOPCode)
+		if err != nil {
 			return nil, fmt.Errorf("发件人OP Code格式不正确: %w", err)
 		}
+		req.SenderOPCode = code
 	}
 
 	letter := &models.Letter{
@@ -126,6 +139,13 @@ func (s *LetterService) CreateDraft(userID string, req *models.CreateLetterReque
 		SenderOPCode:    req.SenderOPCode,    // 可选的发件人OP Code
 	}
 
+	// 收件人已登记转寄时改投新编码，寄件人只能看到地址已变更
+	if s.forwardSvc != nil {
+		if err := s.forwardSvc.ApplyToLetter(letter); err != nil {
+			return nil, fmt.Errorf("解析转寄失败: %w", err)
+		}
+	}
+
 	if err := s.db.Create(letter).Error; err != nil {
 		return nil, fmt.Errorf("failed to create draft: %w", err)
 	}
@@ -150,17 +170,24 @@ func (s *LetterService) GenerateCode(letterID string) (*models.LetterCode, error
 		return nil, fmt.Errorf("letter not found: %w", err)
 	}
 
-	// 检查是否已经生成过编号
+	// 检查是否已经生成过编号；已过期或作废回收的编号换发新编号
 	var existingCode models.LetterCode
+	staleCodeID := ""
 	if err := s.db.First(&existingCode, "letter_id = ?", letterID).Error; err == nil {
-		return &existingCode, nil
+		if existingCode.Status != models.BarcodeStatusExpired && existingCode.Status != models.BarcodeStatusCancelled {
+			return &existingCode, nil
+		}
+		staleCodeID = existingCode.ID
 	}
 
 	// 生成唯一编号
 	code := utils.GenerateLetterCode()
 
-	// 生成包含OP Code信息的结构化QR码数据
-	qrData := s.generateEnhancedQRData(letter.ID, code, letter.RecipientOPCode, letter.SenderOPCode)
+	// 生成二维码内容：配置签名服务时为带签名令牌的阅读地址，否则为结构化数据
+	qrData, tokenNonce, tokenExpiresAt, err := s.buildQRContent(letter.ID, code, letter.RecipientOPCode, letter.SenderOPCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign qr token: %w", err)
+	}
 
 	// 生成二维码（使用增强数据）
 	qrCodeFileName := fmt.Sprintf("%s.png", code)
@@ -177,17 +204,29 @@ func (s *LetterService) GenerateCode(letterID string) (*models.LetterCode, error
 		return nil, fmt.Errorf("failed to generate qr code: %w", err)
 	}
 
-	// 保存到数据库
+	// 保存到数据库，未绑定信封的编号到期自动回收
+	expiresAt := time.Now().Add(barcodeUnactivatedTTL(s.config))
 	letterCode := &models.LetterCode{
 		ID:         uuid.New().String(),
 		LetterID:   letterID,
 		Code:       code,
 		QRCodeURL:  qrCodeURL,
 		QRCodePath: qrCodePath,
+		ExpiresAt:  &expiresAt,
+
+		QRTokenNonce:     tokenNonce,
+		QRTokenExpiresAt: tokenExpiresAt,
 	}
 
 	tx := s.db.Begin()
 
+	if staleCodeID != "" {
+		if err := tx.Delete(&models.LetterCode{}, "id = ?", staleCodeID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to remove stale letter code: %w", err)
+		}
+	}
+
 	// 创建编号记录
 	if err := tx.Create(letterCode).Error; err != nil {
 		tx.Rollback()
@@ -289,6 +328,16 @@ func (s *LetterService) UpdateStatus(code string, req *models.UpdateLetterStatus
 		return fmt.Errorf("letter not found: %w", err)
 	}
 
+	// 送达只能由收件人当面签收确认，生成签收凭证
+	if req.Status == models.StatusDelivered {
+		return ErrDeliveryReceiptRequired
+	}
+
+	// 校验二维码签名令牌，拒绝伪造或已作废的标签
+	if err := s.VerifyScanToken(code, req.QRToken); err != nil {
+		return err
+	}
+
 	tx := s.db.Begin()
 
 	// 更新信件状态
@@ -311,6 +360,16 @@ func (s *LetterService) UpdateStatus(code string, req *models.UpdateLetterStatus
 		return fmt.Errorf("failed to create status log: %w", err)
 	}
 
+	// 同步信使任务并记录出站事件
+	if err := alignActiveCourierTask(tx, code, req.Status); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update courier task: %w", err)
+	}
+	if err := recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, code, req.Note); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record sync event: %w", err)
+	}
+
 	tx.Commit()
 
 	// Send status update notifications
@@ -973,6 +1032,64 @@ func (s *LetterService) generateQRCode(code string) (string, error) {
 	return qrFilePath, nil
 }
 
+// buildQRContent 生成二维码内容，返回签名令牌的标签序号和过期时间（未启用签名时为空）
+func (s *LetterService) buildQRContent(letterID, code, recipientOPCode, senderOPCode string) (string, string, *time.Time, error) {
+	if s.qrTokenSvc == nil {
+		return s.generateEnhancedQRData(letterID, code, recipientOPCode, senderOPCode), "", nil, nil
+	}
+
+	token, nonce, expiresAt, err := s.qrTokenSvc.Issue(code, recipientOPCode)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return s.qrTokenSvc.QRContent(s.config.FrontendURL, code, token), nonce, &expiresAt, nil
+}
+
+// VerifyScanToken 扫码改状态前校验二维码签名令牌，未启用签名时直接通过
+func (s *LetterService) VerifyScanToken(code, token string) error {
+	if s.qrTokenSvc == nil {
+		return nil
+	}
+	_, err := s.qrTokenSvc.VerifyScan(token, code)
+	return err
+}
+
+// ReissueQRCode 重新签发二维码并覆盖标签图片，旧标签（包括复印件）随即失效
+func (s *LetterService) ReissueQRCode(letterID string) (*models.LetterCode, error) {
+	if s.qrTokenSvc == nil {
+		return nil, errors.New("qr token signing is not enabled")
+	}
+
+	var letterCode models.LetterCode
+	if err := s.db.Preload("Letter").First(&letterCode, "letter_id = ?", letterID).Error; err != nil {
+		return nil, fmt.Errorf("letter code not found: %w", err)
+	}
+
+	qrData, nonce, expiresAt, err := s.buildQRContent(letterID, letterCode.Code, letterCode.Letter.RecipientOPCode, letterCode.Letter.SenderOPCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign qr token: %w", err)
+	}
+	if err := utils.EnsureDir(s.config.QRCodeStorePath); err != nil {
+		return nil, fmt.Errorf("failed to create qr code directory: %w", err)
+	}
+	qrCodePath := filepath.Join(s.config.QRCodeStorePath, fmt.Sprintf("%s.png", letterCode.Code))
+	if err := qrcode.WriteFile(qrData, qrcode.Medium, 256, qrCodePath); err != nil {
+		return nil, fmt.Errorf("failed to generate qr code: %w", err)
+	}
+
+	if err := s.db.Model(&letterCode).Updates(map[string]interface{}{
+		"qr_token_nonce":      nonce,
+		"qr_token_expires_at": expiresAt,
+		"qr_code_path":        qrCodePath,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update letter code: %w", err)
+	}
+	letterCode.QRTokenNonce = nonce
+	letterCode.QRTokenExpiresAt = expiresAt
+	letterCode.QRCodePath = qrCodePath
+	return &letterCode, nil
+}
+
 // generateEnhancedQRData 生成包含OP Code信息的增强QR码数据
 func (s *LetterService) generateEnhancedQRData(letterID, code, recipientOPCode, senderOPCode string) string {
 	// 创建结构化QR码数据
@@ -1580,9 +1697,11 @@ func (s *LetterService) GetWritingSuggestions(ctx context.Context, content, styl
 func (s *LetterService) BindBarcodeToEnvelope(req *models.BindBarcodeRequest, operatorID string) (*models.EnvelopeWithBarcodeResponse, error) {
 	// 验证OP Code格式
 	if s.opcodeService != nil {
-		if valid, err := s.opcodeService.ValidateOPCode(req.RecipientCode); !valid {
+		code, err := s.opcodeService.RequireOPCode(req.RecipientCode, "")
+		if err != nil {
 			return nil, fmt.Errorf("invalid OP Code: %w", err)
 		}
+		req.RecipientCode = code
 	}
 
 	// FSD增强：验证操作员权限 - 确保操作员有权限在目标区域绑定条码
@@ -1640,16 +1759,19 @@ func (s *LetterService) BindBarcodeToEnvelope(req *models.BindBarcodeRequest, op
 		}
 	}()
 
-	// 更新LetterCode状态和关联信息
+	// 更新LetterCode状态和关联信息，绑定后重新计算回收时间
 	now := time.Now()
+	expiresAt := now.Add(barcodeBoundTTL(s.config))
 	updates := map[string]interface{}{
-		"status":          models.BarcodeStatusBound,
-		"recipient_code":  req.RecipientCode,
-		"bound_at":        &now,
-		"last_scanned_by": operatorID,
-		"last_scanned_at": &now,
-		"scan_count":      gorm.Expr("scan_count + 1"),
-		"updated_at":      now,
+		"status":           models.BarcodeStatusBound,
+		"recipient_code":   req.RecipientCode,
+		"bound_at":         &now,
+		"expires_at":       &expiresAt,
+		"expiry_warned_at": nil,
+		"last_scanned_by":  operatorID,
+		"last_scanned_at":  &now,
+		"scan_count":       gorm.Expr("scan_count + 1"),
+		"updated_at":       now,
 	}
 
 	if req.EnvelopeID != "" {
@@ -1750,13 +1872,19 @@ func (s *LetterService) UpdateBarcodeStatus(barcodeCode string, req *models.Upda
 	case "in_transit":
 		newStatus = models.BarcodeStatusInTransit
 	case "delivered":
-		newStatus = models.BarcodeStatusDelivered
+		// 送达只能由收件人当面签收确认，生成签收凭证
+		return ErrDeliveryReceiptRequired
 	case "failed":
 		newStatus = models.BarcodeStatusCancelled
 	default:
 		return fmt.Errorf("invalid status: %s", req.Status)
 	}
 
+	// 校验二维码签名令牌，拒绝伪造或已作废的标签
+	if err := s.VerifyScanToken(barcodeCode, req.QRToken); err != nil {
+		return err
+	}
+
 	// 检查状态转换是否有效
 	if !letterCode.IsValidTransition(newStatus) {
 		return fmt.Errorf("invalid status transition from %s to %s",
@@ -1781,11 +1909,6 @@ func (s *LetterService) UpdateBarcodeStatus(barcodeCode string, req *models.Upda
 		"updated_at":      now,
 	}
 
-	// 如果是已送达状态，设置送达时间
-	if newStatus == models.BarcodeStatusDelivered {
-		updates["delivered_at"] = &now
-	}
-
 	if err := tx.Model(&letterCode).Updates(updates).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to update barcode status: %w", err)
@@ -1798,8 +1921,6 @@ func (s *LetterService) UpdateBarcodeStatus(barcodeCode string, req *models.Upda
 		letterStatus = models.StatusCollected
 	case models.BarcodeStatusInTransit:
 		letterStatus = models.StatusInTransit
-	case models.BarcodeStatusDelivered:
-		letterStatus = models.StatusDelivered
 	case models.BarcodeStatusCancelled:
 		letterStatus = models.StatusDraft // 回退到草稿状态
 	default:
@@ -1826,6 +1947,20 @@ func (s *LetterService) UpdateBarcodeStatus(barcodeCode string, req *models.Upda
 		return fmt.Errorf("failed to create status log: %w", err)
 	}
 
+	// 同步信使任务并记录出站事件，条码作废时任务随之失败
+	taskErr := alignActiveCourierTask(tx, barcodeCode, letterStatus)
+	if newStatus == models.BarcodeStatusCancelled {
+		taskErr = failActiveCourierTasks(tx, barcodeCode, req.Notes)
+	}
+	if taskErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update courier task: %w", taskErr)
+	}
+	if err := recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, barcodeCode, req.Notes); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record sync event: %w", err)
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -377,12 +377,8 @@ func (suite *LetterServiceTestSuite) createDeliveredLetter() *models.Letter {
 	err = suite.letterService.UpdateStatus(letterCode.Code, req2, "test-courier")
 	suite.NoError(err)
 
-	req3 := &models.UpdateLetterStatusRequest{
-		Status:   models.StatusDelivered,
-		Location: "Test Location",
-		Note:     "Delivered",
-	}
-	err = suite.letterService.UpdateStatus(letterCode.Code, req3, "test-courier")
+	// 送达须经当面签收确认，这里直接置为送达
+	err = suite.db.Model(&models.Letter{}).Where("id = ?", letter.ID).Update("status", models.StatusDelivered).Error
 	suite.NoError(err)
 
 	// 重新获取更新后的信件
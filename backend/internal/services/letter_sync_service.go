@@ -0,0 +1,823 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"openpenpal-backend/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	letterSyncDispatchInterval  = 2 * time.Second  // 出站事件投递间隔
+	letterSyncDispatchBatchSize = 100              // 每次投递的最大事件数
+	letterSyncConsumeTimeout    = 5 * time.Second  // 等待 courier-service 事件的超时
+	letterSyncReconcileInterval = 10 * time.Minute // 对账间隔
+	letterSyncReconcileWindow   = 24 * time.Hour   // 对账覆盖最近更新的信件
+	letterSyncMaxAttempts       = 8                // 事件应用失败的最大次数，超过后转入死信队列
+	letterSyncRetryBaseDelay    = 2 * time.Second  // 首次重试间隔，之后每次翻倍
+	letterSyncRetryMaxDelay     = 5 * time.Minute  // 最长重试间隔
+)
+
+var (
+	ErrInvalidSyncEvent      = errors.New("sync event is missing required fields")
+	ErrUnsupportedSyncSchema = errors.New("unsupported sync event schema version")
+)
+
+// LetterSyncService 信件状态与 courier-service 任务状态的双向同步
+// 信件状态变化与出站事件在同一事务写入，再由投递协程推送到 Redis；
+// courier-service 的事件按 event_id 去重、按发生时间取最新后应用；
+// 定时对账发布快照，两边据此发现并修复状态差异
+type LetterSyncService struct {
+	db              *gorm.DB
+	redis           *redis.Client
+	notificationSvc *NotificationService
+	podService      *ProofOfDeliveryService
+
+	mu               sync.RWMutex
+	lastReconciledAt *time.Time
+}
+
+// letterSyncLocal 信件在本地的同步视图
+type letterSyncLocal struct {
+	code      *models.LetterCode
+	task      *models.CourierTask
+	status    string
+	at        time.Time
+	receiptID string
+}
+
+// letterStatusChange 应用事件引起的信件状态变化，提交后通知寄件人
+type letterStatusChange struct {
+	letterID string
+	userID   string
+	code     string
+	status   models.LetterStatus
+	location string
+}
+
+// NewLetterSyncService 创建信件状态同步服务，redis 为空时只记录出站事件不投递
+func NewLetterSyncService(db *gorm.DB, redisClient *redis.Client) *LetterSyncService {
+	return &LetterSyncService{
+		db:    db,
+		redis: redisClient,
+	}
+}
+
+// SetNotificationService 设置通知服务
+func (s *LetterSyncService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// SetProofOfDeliveryService 设置签收凭证服务，对方的送达事件须引用有效签收凭证才应用
+func (s *LetterSyncService) SetProofOfDeliveryService(podService *ProofOfDeliveryService) {
+	s.podService = podService
+}
+
+// Start 启动投递、消费和对账协程
+func (s *LetterSyncService) Start() {
+	if s.redis != nil {
+		go s.runDispatcher()
+		go s.runConsumer()
+	} else {
+		log.Printf("Letter sync: redis unavailable, outbox events will be kept until redis is configured")
+	}
+	go s.runReconciler()
+}
+
+// PublishPending 按写入顺序投递待发送事件，遇到错误即停止，下次从失败处继续
+func (s *LetterSyncService) PublishPending() (int, error) {
+	if s.redis == nil {
+		return 0, nil
+	}
+
+	var events []models.LetterOutboxEvent
+	if err := s.db.Where("status = ?", models.OutboxStatusPending).
+		Order("created_at ASC").Limit(letterSyncDispatchBatchSize).Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	published := 0
+	for i := range events {
+		if err := s.redis.LPush(ctx, models.SyncQueueToCourierService, events[i].Payload).Err(); err != nil {
+			s.db.Model(&events[i]).Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": err.Error(),
+			})
+			return published, err
+		}
+
+		// 推送成功但标记失败时会重复投递，由对方按 event_id 去重
+		now := time.Now()
+		if err := s.db.Model(&events[i]).Updates(map[string]interface{}{
+			"status":       models.OutboxStatusPublished,
+			"published_at": &now,
+		}).Error; err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// ApplyEvent 应用一条 courier-service 事件，返回处理结果
+// 应用过程不写出站事件，避免两边来回回显
+func (s *LetterSyncService) ApplyEvent(event *models.SyncEvent) (string, error) {
+	if event.EventID == "" || event.LetterCode == "" || event.Status == "" || event.OccurredAt.IsZero() {
+		return "", ErrInvalidSyncEvent
+	}
+	if event.SchemaVersion > models.SyncSchemaVersion {
+		return "", ErrUnsupportedSyncSchema
+	}
+
+	var result string
+	var change *letterStatusChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		inbox := &models.LetterSyncInbox{
+			EventID:     event.EventID,
+			EventType:   event.EventType,
+			Source:      event.Source,
+			LetterCode:  event.LetterCode,
+			Result:      models.SyncResultApplied,
+			ProcessedAt: time.Now(),
+		}
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(inbox)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			result = models.SyncResultDuplicate
+			return nil
+		}
+
+		var err error
+		result, change, err = s.applyEvent(tx, event)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.LetterSyncInbox{}).Where("event_id = ?", event.EventID).
+			Update("result", result).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if change != nil {
+		s.notifyStatusChange(change)
+	}
+
+	return result, nil
+}
+
+// Reconcile 为最近有进度的信件发布对账快照
+func (s *LetterSyncService) Reconcile() (int, error) {
+	since := time.Now().Add(-letterSyncReconcileWindow)
+
+	var taskCodes []string
+	if err := s.db.Model(&models.CourierTask{}).Where("updated_at >= ?", since).
+		Order("created_at DESC").Pluck("letter_code", &taskCodes).Error; err != nil {
+		return 0, err
+	}
+	var letterCodes []string
+	if err := s.db.Model(&models.LetterCode{}).
+		Joins("JOIN letters ON letters.id = letter_codes.letter_id").
+		Where("letters.updated_at >= ? AND letters.status IN ?", since,
+			[]models.LetterStatus{models.StatusCollected, models.StatusInTransit, models.StatusDelivered}).
+		Pluck("letter_codes.code", &letterCodes).Error; err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	published := 0
+	for _, code := range append(taskCodes, letterCodes...) {
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		local, err := loadLetterSyncLocal(s.db, code)
+		if err != nil {
+			return published, err
+		}
+		if local.status == "" {
+			continue
+		}
+
+		event := newLetterSyncEvent(code, local, models.SyncEventLetterSnapshot, local.at)
+		if err := enqueueLetterSyncEvent(s.db, event); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.lastReconciledAt = &now
+	s.mu.Unlock()
+
+	return published, nil
+}
+
+// GetDivergences 查询对账差异
+func (s *LetterSyncService) GetDivergences(resolution string, page, limit int) ([]models.LetterSyncDivergence, int64, error) {
+	var divergences []models.LetterSyncDivergence
+	var total int64
+
+	query := s.db.Model(&models.LetterSyncDivergence{})
+	if resolution != "" {
+		query = query.Where("resolution = ?", resolution)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("detected_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&divergences).Error
+	return divergences, total, err
+}
+
+// GetStatus 获取同步概况
+func (s *LetterSyncService) GetStatus() (*models.SyncStatusResponse, error) {
+	status := &models.SyncStatusResponse{}
+
+	if err := s.db.Model(&models.LetterOutboxEvent{}).
+		Where("status = ?", models.OutboxStatusPending).Count(&status.PendingOutbox).Error; err != nil {
+		return nil, err
+	}
+	if status.PendingOutbox > 0 {
+		var oldest models.LetterOutboxEvent
+		if err := s.db.Where("status = ?", models.OutboxStatusPending).
+			Order("created_at ASC").First(&oldest).Error; err == nil {
+			status.OldestPendingAt = &oldest.CreatedAt
+		}
+	}
+	if err := s.db.Model(&models.LetterSyncInbox{}).Count(&status.ProcessedInbox).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.LetterSyncDivergence{}).
+		Where("resolution IN ?", []string{models.DivergenceConflict, models.DivergenceRejected}).
+		Count(&status.OpenConflicts).Error; err != nil {
+		return nil, err
+	}
+	if s.redis != nil {
+		deadLetters, err := s.redis.LLen(context.Background(), models.SyncDeadLetterToBackend).Result()
+		if err != nil {
+			return nil, err
+		}
+		status.DeadLetters = deadLetters
+	}
+
+	s.mu.RLock()
+	status.LastReconciledAt = s.lastReconciledAt
+	s.mu.RUnlock()
+
+	return status, nil
+}
+
+// runDispatcher 定时投递出站事件
+func (s *LetterSyncService) runDispatcher() {
+	ticker := time.NewTicker(letterSyncDispatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.PublishPending(); err != nil {
+			log.Printf("Letter sync dispatch failed: %v", err)
+		}
+	}
+}
+
+// runConsumer 消费 courier-service 发来的事件
+func (s *LetterSyncService) runConsumer() {
+	ctx := context.Background()
+
+	for {
+		if err := s.requeueDueRetries(ctx); err != nil {
+			log.Printf("Letter sync retry requeue failed: %v", err)
+		}
+
+		result, err := s.redis.BRPop(ctx, letterSyncConsumeTimeout, models.SyncQueueToBackend).Result()
+		if err != nil {
+			if err != redis.Nil {
+				log.Printf("Letter sync consumer error: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if len(result) < 2 {
+			continue
+		}
+
+		s.consume(ctx, result[1])
+	}
+}
+
+// consume 应用一条事件，失败时按次数退避重试，无法处理或超过重试上限的进入死信队列
+func (s *LetterSyncService) consume(ctx context.Context, payload string) {
+	var event models.SyncEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Failed to unmarshal sync event: %v", err)
+		s.deadLetter(ctx, payload, "")
+		return
+	}
+
+	if _, err := s.ApplyEvent(&event); err != nil {
+		log.Printf("Failed to apply sync event %s: %v", event.EventID, err)
+		if errors.Is(err, ErrInvalidSyncEvent) || errors.Is(err, ErrUnsupportedSyncSchema) {
+			s.deadLetter(ctx, payload, event.EventID)
+			return
+		}
+		// 事务已回滚，稍后重试
+		s.scheduleRetry(ctx, payload, event.EventID)
+		return
+	}
+
+	s.redis.HDel(ctx, models.SyncAttemptsToBackend, event.EventID)
+}
+
+// scheduleRetry 记录失败次数并按指数退避放入重试集合
+func (s *LetterSyncService) scheduleRetry(ctx context.Context, payload, eventID string) {
+	attempts, err := s.redis.HIncrBy(ctx, models.SyncAttemptsToBackend, eventID, 1).Result()
+	if err != nil {
+		log.Printf("Failed to count sync event attempts %s: %v", eventID, err)
+		attempts = 1
+	}
+	if attempts >= letterSyncMaxAttempts {
+		log.Printf("Sync event %s failed %d times, moved to dead letter queue", eventID, attempts)
+		s.deadLetter(ctx, payload, eventID)
+		return
+	}
+
+	retryAt := time.Now().Add(letterSyncRetryDelay(int(attempts)))
+	if err := s.redis.ZAdd(ctx, models.SyncRetryToBackend, &redis.Z{
+		Score:  float64(retryAt.Unix()),
+		Member: payload,
+	}).Err(); err != nil {
+		log.Printf("Failed to schedule sync event retry %s: %v", eventID, err)
+	}
+}
+
+// deadLetter 放入死信队列，不再自动重试
+func (s *LetterSyncService) deadLetter(ctx context.Context, payload, eventID string) {
+	if err := s.redis.LPush(ctx, models.SyncDeadLetterToBackend, payload).Err(); err != nil {
+		log.Printf("Failed to dead-letter sync event %s: %v", eventID, err)
+	}
+	if eventID != "" {
+		s.redis.HDel(ctx, models.SyncAttemptsToBackend, eventID)
+	}
+}
+
+// requeueDueRetries 把到期的重试事件放回消费队列，多个消费者时只有移出成功的一方放回
+func (s *LetterSyncService) requeueDueRetries(ctx context.Context) error {
+	due, err := s.redis.ZRangeByScore(ctx, models.SyncRetryToBackend, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range due {
+		removed, err := s.redis.ZRem(ctx, models.SyncRetryToBackend, payload).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		if err := s.redis.LPush(ctx, models.SyncQueueToBackend, payload).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// letterSyncRetryDelay 第 attempts 次失败后的重试间隔
+func letterSyncRetryDelay(attempts int) time.Duration {
+	delay := letterSyncRetryBaseDelay
+	for i := 1; i < attempts && delay < letterSyncRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > letterSyncRetryMaxDelay {
+		delay = letterSyncRetryMaxDelay
+	}
+	return delay
+}
+
+// runReconciler 定时对账
+func (s *LetterSyncService) runReconciler() {
+	ticker := time.NewTicker(letterSyncReconcileInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.Reconcile(); err != nil {
+			log.Printf("Letter sync reconcile failed: %v", err)
+		}
+	}
+}
+
+// applyEvent 在事务内比较本地状态并应用事件
+func (s *LetterSyncService) applyEvent(tx *gorm.DB, event *models.SyncEvent) (string, *letterStatusChange, error) {
+	local, err := loadLetterSyncLocal(tx, event.LetterCode)
+	if err != nil {
+		return "", nil, err
+	}
+	if local.code == nil {
+		return models.SyncResultIgnored, nil, nil
+	}
+
+	if event.IsSnapshot() {
+		return s.reconcileSnapshot(tx, local, event)
+	}
+
+	if !event.OccurredAt.After(local.at) {
+		return models.SyncResultStale, nil, nil
+	}
+	if local.status == event.Status {
+		return models.SyncResultInSync, nil, saveLetterSyncState(tx, event.LetterCode, event.Status, event.OccurredAt, event.EventID, event.Source)
+	}
+
+	verified, err := s.verifyDeliveryReceipt(tx, event)
+	if err != nil {
+		return "", nil, err
+	}
+	if !verified {
+		return models.SyncResultRejected, nil, nil
+	}
+
+	change, err := applyLetterLifecycle(tx, local, event)
+	if err != nil {
+		return "", nil, err
+	}
+	return models.SyncResultApplied, change, nil
+}
+
+// reconcileSnapshot 对比对方快照，较新的一方为准
+func (s *LetterSyncService) reconcileSnapshot(tx *gorm.DB, local *letterSyncLocal, event *models.SyncEvent) (string, *letterStatusChange, error) {
+	if local.status == event.Status {
+		return models.SyncResultInSync, nil, nil
+	}
+
+	divergence := &models.LetterSyncDivergence{
+		ID:            uuid.New().String(),
+		LetterCode:    event.LetterCode,
+		LocalStatus:   local.status,
+		RemoteStatus:  event.Status,
+		RemoteSource:  event.Source,
+		LocalEventAt:  local.at,
+		RemoteEventAt: event.OccurredAt,
+		DetectedAt:    time.Now(),
+	}
+
+	var change *letterStatusChange
+	switch {
+	case event.OccurredAt.After(local.at):
+		verified, err := s.verifyDeliveryReceipt(tx, event)
+		if err != nil {
+			return "", nil, err
+		}
+		if !verified {
+			divergence.Resolution = models.DivergenceRejected
+			break
+		}
+		if change, err = applyLetterLifecycle(tx, local, event); err != nil {
+			return "", nil, err
+		}
+		divergence.Resolution = models.DivergenceRepairedLocal
+	case local.at.After(event.OccurredAt) && local.status != "":
+		// 本地更新，重新发布本地快照让对方修复
+		snapshot := newLetterSyncEvent(event.LetterCode, local, models.SyncEventLetterSnapshot, local.at)
+		if err := enqueueLetterSyncEvent(tx, snapshot); err != nil {
+			return "", nil, err
+		}
+		divergence.Resolution = models.DivergenceRepublished
+	default:
+		// 发生时间相同无法判断先后，只登记不处理，避免两边互相覆盖
+		divergence.Resolution = models.DivergenceConflict
+	}
+
+	if err := tx.Create(divergence).Error; err != nil {
+		return "", nil, err
+	}
+
+	return models.SyncResultApplied, change, nil
+}
+
+// verifyDeliveryReceipt 送达事件须引用本信件签名有效的签收凭证，防止绕过当面签收直接标记送达
+// 其他状态的事件直接通过
+func (s *LetterSyncService) verifyDeliveryReceipt(tx *gorm.DB, event *models.SyncEvent) (bool, error) {
+	if event.Status != models.LifecycleDelivered {
+		return true, nil
+	}
+	if s.podService == nil || event.ReceiptID == "" {
+		return false, nil
+	}
+
+	var receipt models.DeliveryReceipt
+	err := tx.First(&receipt, "id = ?", event.ReceiptID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return receipt.LetterCode == event.LetterCode && s.podService.VerifyReceipt(&receipt), nil
+}
+
+// notifyStatusChange 通知寄件人同步过来的信件状态变化
+func (s *LetterSyncService) notifyStatusChange(change *letterStatusChange) {
+	if s.notificationSvc == nil {
+		return
+	}
+
+	var notificationType string
+	switch change.status {
+	case models.StatusCollected:
+		notificationType = "letter_collected"
+	case models.StatusInTransit:
+		notificationType = "letter_in_transit"
+	case models.StatusDelivered:
+		notificationType = "letter_delivered"
+	default:
+		return
+	}
+
+	if err := s.notificationSvc.NotifyUser(change.userID, notificationType, map[string]interface{}{
+		"letter_id": change.letterID,
+		"code":      change.code,
+		"status":    change.status,
+		"location":  change.location,
+	}); err != nil {
+		fmt.Printf("Failed to send notification: %v\n", err)
+	}
+}
+
+// recordLetterSyncEvent 在信件或信使任务状态变化的事务内写入出站事件，需在更新之后调用
+func recordLetterSyncEvent(tx *gorm.DB, eventType, letterCode, note string) error {
+	local, err := loadLetterSyncLocal(tx, letterCode)
+	if err != nil {
+		return err
+	}
+	if local.status == "" {
+		return nil
+	}
+
+	event := newLetterSyncEvent(letterCode, local, eventType, time.Now())
+	event.Note = note
+	if err := enqueueLetterSyncEvent(tx, event); err != nil {
+		return err
+	}
+
+	return saveLetterSyncState(tx, letterCode, local.status, event.OccurredAt, event.EventID, event.Source)
+}
+
+// loadLetterSyncLocal 读取信件、最新信使任务及其统一状态
+// 同步状态与本地一致时取记录的时间，否则本地被绕过同步直接修改过，以最近更新时间为准
+func loadLetterSyncLocal(db *gorm.DB, letterCode string) (*letterSyncLocal, error) {
+	local := &letterSyncLocal{}
+
+	var code models.LetterCode
+	err := db.Preload("Letter").First(&code, "code = ?", letterCode).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		local.code = &code
+	}
+
+	var task models.CourierTask
+	err = db.Where("letter_code = ?", letterCode).Order("created_at DESC").First(&task).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		local.task = &task
+	}
+
+	var letterStatus models.LetterStatus
+	if local.code != nil {
+		letterStatus = local.code.Letter.Status
+		local.at = local.code.Letter.UpdatedAt
+	}
+	local.status = models.LetterLifecycleStatus(letterStatus, local.task)
+	if local.task != nil && local.task.UpdatedAt.After(local.at) {
+		local.at = local.task.UpdatedAt
+	}
+
+	var state models.LetterSyncState
+	err = db.First(&state, "letter_code = ?", letterCode).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && state.Status == local.status {
+		local.at = state.LastEventAt
+	}
+
+	// 已送达的信件随事件带上签收凭证，供对方校验
+	if local.status == models.LifecycleDelivered && local.code != nil {
+		var receiptIDs []string
+		if err := db.Model(&models.DeliveryReceipt{}).Where("letter_id = ?", local.code.LetterID).
+			Pluck("id", &receiptIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(receiptIDs) > 0 {
+			local.receiptID = receiptIDs[0]
+		}
+	}
+
+	return local, nil
+}
+
+// newLetterSyncEvent 由本地信件视图生成同步事件
+func newLetterSyncEvent(letterCode string, local *letterSyncLocal, eventType string, occurredAt time.Time) *models.SyncEvent {
+	event := &models.SyncEvent{
+		EventID:       uuid.New().String(),
+		EventType:     eventType,
+		Source:        models.SyncSourceBackend,
+		SchemaVersion: models.SyncSchemaVersion,
+		LetterCode:    letterCode,
+		Status:        local.status,
+		ReceiptID:     local.receiptID,
+		OccurredAt:    occurredAt,
+	}
+	if local.task != nil {
+		event.CourierID = local.task.CourierID
+		event.PickupOPCode = local.task.PickupOPCode
+		event.DeliveryOPCode = local.task.DeliveryOPCode
+		event.CurrentOPCode = local.task.CurrentOPCode
+		event.Location = local.task.CurrentLocation
+	}
+	return event
+}
+
+// enqueueLetterSyncEvent 写入出站事件
+func enqueueLetterSyncEvent(tx *gorm.DB, event *models.SyncEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.LetterOutboxEvent{
+		ID:         event.EventID,
+		EventType:  event.EventType,
+		LetterCode: event.LetterCode,
+		Payload:    string(payload),
+		Status:     models.OutboxStatusPending,
+	}).Error
+}
+
+// saveLetterSyncState 记录信件最近一次已知状态
+func saveLetterSyncState(tx *gorm.DB, letterCode, status string, occurredAt time.Time, eventID, source string) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.LetterSyncState{
+		LetterCode:  letterCode,
+		Status:      status,
+		LastEventAt: occurredAt,
+		LastEventID: eventID,
+		LastSource:  source,
+	}).Error
+}
+
+// applyLetterLifecycle 按事件状态更新信件和信使任务并记录同步状态
+func applyLetterLifecycle(tx *gorm.DB, local *letterSyncLocal, event *models.SyncEvent) (*letterStatusChange, error) {
+	at := event.OccurredAt
+	letter := &local.code.Letter
+
+	location := event.Location
+	if location == "" {
+		location = event.CurrentOPCode
+	}
+
+	// 信件状态只跟随取件、在途、送达三个阶段，已读的信件不再回退
+	var change *letterStatusChange
+	var letterStatus models.LetterStatus
+	switch event.Status {
+	case models.LifecycleCollected:
+		letterStatus = models.StatusCollected
+	case models.LifecycleInTransit:
+		letterStatus = models.StatusInTransit
+	case models.LifecycleDelivered:
+		letterStatus = models.StatusDelivered
+	}
+	if letterStatus != "" && letter.Status != letterStatus && letter.Status != models.StatusRead {
+		if err := tx.Model(&models.Letter{}).Where("id = ?", letter.ID).Update("status", letterStatus).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.StatusLog{
+			ID:        uuid.New().String(),
+			LetterID:  letter.ID,
+			Status:    letterStatus,
+			UpdatedBy: event.CourierID,
+			Location:  location,
+			Note:      event.Note,
+			CreatedAt: at,
+		}).Error; err != nil {
+			return nil, err
+		}
+		change = &letterStatusChange{
+			letterID: letter.ID,
+			userID:   letter.UserID,
+			code:     event.LetterCode,
+			status:   letterStatus,
+			location: location,
+		}
+	}
+
+	if err := applyCourierTaskLifecycle(tx, local, event); err != nil {
+		return nil, err
+	}
+
+	return change, saveLetterSyncState(tx, event.LetterCode, event.Status, at, event.EventID, event.Source)
+}
+
+// applyCourierTaskLifecycle 同步信使任务状态，已分配但本地还没有任务时补建
+func applyCourierTaskLifecycle(tx *gorm.DB, local *letterSyncLocal, event *models.SyncEvent) error {
+	at := event.OccurredAt
+	task := local.task
+
+	if task == nil {
+		if event.Status != models.LifecycleAssigned || event.CourierID == "" || event.DeliveryOPCode == "" {
+			return nil
+		}
+		return tx.Create(&models.CourierTask{
+			ID:             uuid.New().String(),
+			CourierID:      event.CourierID,
+			LetterCode:     event.LetterCode,
+			Title:          fmt.Sprintf("配送至 %s", event.DeliveryOPCode),
+			TargetLocation: event.DeliveryOPCode,
+			PickupOPCode:   event.PickupOPCode,
+			DeliveryOPCode: event.DeliveryOPCode,
+			CurrentOPCode:  event.CurrentOPCode,
+			Status:         models.CourierTaskStatusPending,
+			Priority:       models.CourierTaskPriorityNormal,
+			EstimatedTime:  30,
+			Reward:         10,
+			Deadline:       at.Add(4 * time.Hour),
+		}).Error
+	}
+
+	updates := map[string]interface{}{}
+	switch event.Status {
+	case models.LifecycleAssigned:
+		updates["status"] = models.CourierTaskStatusPending
+	case models.LifecycleCollected:
+		updates["status"] = models.CourierTaskStatusCollected
+	case models.LifecycleInTransit:
+		updates["status"] = models.CourierTaskStatusInTransit
+	case models.LifecycleDelivered:
+		updates["status"] = models.CourierTaskStatusDelivered
+		updates["completed_at"] = &at
+	case models.LifecycleFailed:
+		updates["status"] = models.CourierTaskStatusFailed
+		updates["failure_reason"] = event.Note
+	case models.LifecycleReturned:
+		updates["status"] = models.CourierTaskStatusFailed
+		updates["failure_reason"] = "退回寄件人"
+	case models.LifecycleCanceled:
+		updates["status"] = models.CourierTaskStatusFailed
+		updates["failure_reason"] = "任务已取消"
+	default:
+		return nil
+	}
+	if event.CourierID != "" {
+		updates["courier_id"] = event.CourierID
+	}
+	if event.CurrentOPCode != "" {
+		updates["current_op_code"] = event.CurrentOPCode
+	}
+
+	return tx.Model(&models.CourierTask{}).Where("id = ?", task.ID).Updates(updates).Error
+}
+
+// alignActiveCourierTask 信件状态被直接更新时，让进行中的信使任务跟上同一进度
+func alignActiveCourierTask(tx *gorm.DB, letterCode string, status models.LetterStatus) error {
+	updates := map[string]interface{}{}
+	switch status {
+	case models.StatusCollected:
+		updates["status"] = models.CourierTaskStatusCollected
+	case models.StatusInTransit:
+		updates["status"] = models.CourierTaskStatusInTransit
+	case models.StatusDelivered:
+		now := time.Now()
+		updates["status"] = models.CourierTaskStatusDelivered
+		updates["completed_at"] = &now
+	default:
+		return nil
+	}
+
+	return tx.Model(&models.CourierTask{}).
+		Where("letter_code = ? AND status NOT IN ?", letterCode,
+			[]string{models.CourierTaskStatusDelivered, models.CourierTaskStatusFailed, models.CourierTaskStatusRerouted}).
+		Updates(updates).Error
+}
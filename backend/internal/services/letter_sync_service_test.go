@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// LetterSyncServiceTestSuite 信件状态同步服务测试套件
+type LetterSyncServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *LetterSyncService
+	pod     *ProofOfDeliveryService
+	sender  *models.User
+	courier *models.User
+	letter  *models.Letter
+}
+
+func (suite *LetterSyncServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.NoError(db.AutoMigrate(&models.DeliveryReceipt{}))
+	suite.pod = NewProofOfDeliveryService(db, config.GetTestConfig())
+	suite.service = NewLetterSyncService(db, nil)
+	suite.service.SetProofOfDeliveryService(suite.pod)
+
+	suite.sender = config.CreateTestUser(db, "syncsender", models.RoleUser)
+	suite.courier = config.CreateTestUser(db, "synccourier", models.RoleCourierLevel1)
+
+	suite.letter = config.CreateTestLetter(db, suite.sender.ID)
+	suite.NoError(db.Model(suite.letter).Update("status", models.StatusGenerated).Error)
+	suite.NoError(db.Create(&models.LetterCode{ID: "code-1", LetterID: suite.letter.ID, Code: "OPSYNC01", Status: models.BarcodeStatusBound}).Error)
+	suite.NoError(db.Create(&models.CourierTask{
+		ID: "task-1", CourierID: suite.courier.ID, LetterCode: "OPSYNC01", Title: "测试信件",
+		SenderName: "寄件人", TargetLocation: "5号楼", PickupOPCode: "PK3D12", DeliveryOPCode: "PK5F01",
+		Status: models.CourierTaskStatusPending, Deadline: time.Now().Add(time.Hour),
+	}).Error)
+}
+
+func (suite *LetterSyncServiceTestSuite) courierEvent(id, status string, occurredAt time.Time) *models.SyncEvent {
+	return &models.SyncEvent{
+		EventID:       id,
+		EventType:     models.SyncEventTaskStatusChanged,
+		Source:        models.SyncSourceCourierService,
+		SchemaVersion: models.SyncSchemaVersion,
+		LetterCode:    "OPSYNC01",
+		Status:        status,
+		CourierID:     suite.courier.ID,
+		CurrentOPCode: "PK5F01",
+		OccurredAt:    occurredAt,
+	}
+}
+
+// deliveryReceipt 写入一张签名有效的签收凭证并返回其ID
+func (suite *LetterSyncServiceTestSuite) deliveryReceipt(letterID, letterCode string) string {
+	receipt := &models.DeliveryReceipt{
+		ID:             uuid.New().String(),
+		LetterID:       letterID,
+		LetterCode:     letterCode,
+		CourierID:      suite.courier.ID,
+		RecipientID:    suite.sender.ID,
+		ConfirmationID: uuid.New().String(),
+		ConfirmMethod:  models.DeliveryConfirmByCode,
+		TargetOPCode:   "PK5F01",
+		GeofenceResult: "unchecked",
+		DeliveredAt:    time.Now().Truncate(time.Second),
+	}
+	receipt.Signature = suite.pod.signReceipt(receipt)
+	suite.NoError(suite.db.Create(receipt).Error)
+	return receipt.ID
+}
+
+func (suite *LetterSyncServiceTestSuite) letterStatus() models.LetterStatus {
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	return letter.Status
+}
+
+// TestUpdateStatus_WritesOutbox 测试信件状态变化与出站事件在同一事务写入，并带动信使任务
+func (suite *LetterSyncServiceTestSuite) TestUpdateStatus_WritesOutbox() {
+	letterService := NewLetterService(suite.db, config.GetTestConfig())
+	err := letterService.UpdateStatus("OPSYNC01", &models.UpdateLetterStatusRequest{Status: models.StatusCollected}, suite.courier.ID)
+	suite.NoError(err)
+
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "id = ?", "task-1").Error)
+	suite.Equal(models.CourierTaskStatusCollected, task.Status)
+
+	var outbox []models.LetterOutboxEvent
+	suite.NoError(suite.db.Find(&outbox).Error)
+	suite.Require().Len(outbox, 1)
+	suite.Equal(models.OutboxStatusPending, outbox[0].Status)
+
+	var event models.SyncEvent
+	suite.NoError(json.Unmarshal([]byte(outbox[0].Payload), &event))
+	suite.Equal(models.SyncEventLetterStatusChanged, event.EventType)
+	suite.Equal(models.LifecycleCollected, event.Status)
+	suite.Equal(suite.courier.ID, event.CourierID)
+	suite.Equal("PK5F01", event.DeliveryOPCode)
+
+	var state models.LetterSyncState
+	suite.NoError(suite.db.First(&state, "letter_code = ?", "OPSYNC01").Error)
+	suite.Equal(models.LifecycleCollected, state.Status)
+	suite.Equal(event.EventID, state.LastEventID)
+
+	// 没有 Redis 时出站事件保留待投递
+	published, err := suite.service.PublishPending()
+	suite.NoError(err)
+	suite.Equal(0, published)
+}
+
+// TestApplyEvent_Idempotent 测试重复投递的事件只应用一次
+func (suite *LetterSyncServiceTestSuite) TestApplyEvent_Idempotent() {
+	event := suite.courierEvent("evt-1", models.LifecycleCollected, time.Now())
+
+	result, err := suite.service.ApplyEvent(event)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultApplied, result)
+
+	result, err = suite.service.ApplyEvent(event)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultDuplicate, result)
+
+	suite.Equal(models.StatusCollected, suite.letterStatus())
+
+	var logs int64
+	suite.db.Model(&models.StatusLog{}).Where("letter_id = ?", suite.letter.ID).Count(&logs)
+	suite.Equal(int64(1), logs)
+
+	// 应用对方事件不产生出站事件
+	var outbox int64
+	suite.db.Model(&models.LetterOutboxEvent{}).Count(&outbox)
+	suite.Equal(int64(0), outbox)
+
+	_, err = suite.service.ApplyEvent(&models.SyncEvent{EventID: "evt-2", LetterCode: "OPSYNC01"})
+	suite.ErrorIs(err, ErrInvalidSyncEvent)
+}
+
+// TestApplyEvent_LastWriterWins 测试乱序到达的旧事件不会覆盖较新的状态
+func (suite *LetterSyncServiceTestSuite) TestApplyEvent_LastWriterWins() {
+	now := time.Now()
+
+	delivered := suite.courierEvent("evt-delivered", models.LifecycleDelivered, now)
+	delivered.ReceiptID = suite.deliveryReceipt(suite.letter.ID, "OPSYNC01")
+	result, err := suite.service.ApplyEvent(delivered)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultApplied, result)
+
+	result, err = suite.service.ApplyEvent(suite.courierEvent("evt-transit", models.LifecycleInTransit, now.Add(-time.Minute)))
+	suite.NoError(err)
+	suite.Equal(models.SyncResultStale, result)
+
+	suite.Equal(models.StatusDelivered, suite.letterStatus())
+
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "id = ?", "task-1").Error)
+	suite.Equal(models.CourierTaskStatusDelivered, task.Status)
+	suite.NotNil(task.CompletedAt)
+}
+
+// TestApplyEvent_SnapshotReconcile 测试对账快照：本地较新时重新发布，对方较新时修复本地
+func (suite *LetterSyncServiceTestSuite) TestApplyEvent_SnapshotReconcile() {
+	letterService := NewLetterService(suite.db, config.GetTestConfig())
+	suite.NoError(letterService.UpdateStatus("OPSYNC01", &models.UpdateLetterStatusRequest{Status: models.StatusInTransit}, suite.courier.ID))
+
+	stale := suite.courierEvent("snap-1", models.LifecycleCollected, time.Now().Add(-time.Hour))
+	stale.EventType = models.SyncEventTaskSnapshot
+	result, err := suite.service.ApplyEvent(stale)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultApplied, result)
+	suite.Equal(models.StatusInTransit, suite.letterStatus())
+
+	var snapshots int64
+	suite.db.Model(&models.LetterOutboxEvent{}).Where("event_type = ?", models.SyncEventLetterSnapshot).Count(&snapshots)
+	suite.Equal(int64(1), snapshots)
+
+	newer := suite.courierEvent("snap-2", models.LifecycleDelivered, time.Now().Add(time.Minute))
+	newer.EventType = models.SyncEventTaskSnapshot
+	newer.ReceiptID = suite.deliveryReceipt(suite.letter.ID, "OPSYNC01")
+	_, err = suite.service.ApplyEvent(newer)
+	suite.NoError(err)
+	suite.Equal(models.StatusDelivered, suite.letterStatus())
+
+	divergences, total, err := suite.service.GetDivergences("", 1, 20)
+	suite.NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Equal(models.DivergenceRepairedLocal, divergences[0].Resolution)
+	suite.Equal(models.DivergenceRepublished, divergences[1].Resolution)
+
+	inSync := suite.courierEvent("snap-3", models.LifecycleDelivered, time.Now().Add(time.Minute))
+	inSync.EventType = models.SyncEventTaskSnapshot
+	result, err = suite.service.ApplyEvent(inSync)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultInSync, result)
+}
+
+// TestApplyEvent_DeliveredRequiresReceipt 测试对方的送达事件须引用本信件签名有效的签收凭证
+func (suite *LetterSyncServiceTestSuite) TestApplyEvent_DeliveredRequiresReceipt() {
+	now := time.Now()
+
+	result, err := suite.service.ApplyEvent(suite.courierEvent("evt-no-receipt", models.LifecycleDelivered, now))
+	suite.NoError(err)
+	suite.Equal(models.SyncResultRejected, result)
+
+	receiptID := suite.deliveryReceipt(suite.letter.ID, "OPSYNC01")
+	suite.NoError(suite.db.Model(&models.DeliveryReceipt{}).Where("id = ?", receiptID).Update("courier_id", "forged-courier").Error)
+	forged := suite.courierEvent("evt-forged", models.LifecycleDelivered, now.Add(time.Second))
+	forged.ReceiptID = receiptID
+	result, err = suite.service.ApplyEvent(forged)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultRejected, result)
+
+	otherLetter := config.CreateTestLetter(suite.db, suite.courier.ID)
+	mismatched := suite.courierEvent("evt-other-letter", models.LifecycleDelivered, now.Add(2*time.Second))
+	mismatched.ReceiptID = suite.deliveryReceipt(otherLetter.ID, "OPOTHER1")
+	result, err = suite.service.ApplyEvent(mismatched)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultRejected, result)
+
+	snapshot := suite.courierEvent("snap-no-receipt", models.LifecycleDelivered, now.Add(3*time.Second))
+	snapshot.EventType = models.SyncEventTaskSnapshot
+	_, err = suite.service.ApplyEvent(snapshot)
+	suite.NoError(err)
+
+	suite.Equal(models.StatusGenerated, suite.letterStatus())
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "id = ?", "task-1").Error)
+	suite.Equal(models.CourierTaskStatusPending, task.Status)
+
+	divergences, _, err := suite.service.GetDivergences(models.DivergenceRejected, 1, 20)
+	suite.NoError(err)
+	suite.Require().Len(divergences, 1)
+	suite.Equal(models.LifecycleDelivered, divergences[0].RemoteStatus)
+
+	status, err := suite.service.GetStatus()
+	suite.NoError(err)
+	suite.Equal(int64(1), status.OpenConflicts)
+
+	// 签收凭证有效时应用，本方对账快照带上凭证ID
+	suite.NoError(suite.db.Model(&models.DeliveryReceipt{}).Where("id = ?", receiptID).Update("courier_id", suite.courier.ID).Error)
+	valid := suite.courierEvent("evt-valid", models.LifecycleDelivered, now.Add(4*time.Second))
+	valid.ReceiptID = receiptID
+	result, err = suite.service.ApplyEvent(valid)
+	suite.NoError(err)
+	suite.Equal(models.SyncResultApplied, result)
+	suite.Equal(models.StatusDelivered, suite.letterStatus())
+
+	_, err = suite.service.Reconcile()
+	suite.NoError(err)
+	var outbox models.LetterOutboxEvent
+	suite.NoError(suite.db.Where("event_type = ? AND letter_code = ?", models.SyncEventLetterSnapshot, "OPSYNC01").First(&outbox).Error)
+	var published models.SyncEvent
+	suite.NoError(json.Unmarshal([]byte(outbox.Payload), &published))
+	suite.Equal(receiptID, published.ReceiptID)
+}
+
+// TestRetryDelay 测试应用失败的事件按次数指数退避，且不超过最长间隔
+func (suite *LetterSyncServiceTestSuite) TestRetryDelay() {
+	suite.Equal(letterSyncRetryBaseDelay, letterSyncRetryDelay(1))
+	suite.Equal(2*letterSyncRetryBaseDelay, letterSyncRetryDelay(2))
+	suite.Equal(8*letterSyncRetryBaseDelay, letterSyncRetryDelay(4))
+	suite.Equal(letterSyncRetryMaxDelay, letterSyncRetryDelay(letterSyncMaxAttempts*4))
+}
+
+func TestLetterSyncServiceSuite(t *testing.T) {
+	suite.Run(t, new(LetterSyncServiceTestSuite))
+}
@@ -0,0 +1,816 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mailboxRunHorizon       = 48 * time.Hour   // 提前生成收取轮次的时长
+	mailboxRunGrace         = 2 * time.Hour    // 超过计划时间多久未开箱视为漏收
+	mailboxRunEarly         = time.Hour        // 计划时间前多久可以开箱
+	mailboxRefreshInterval  = 15 * time.Minute // 生成轮次、标记漏收的周期
+	mailboxDefaultCapacity  = 50
+	mailboxTargetFillRate   = 0.6 // 建议频率按收取时装到六成估算
+	mailboxStatsDefaultDays = 30
+)
+
+var (
+	ErrMailboxNotFound        = errors.New("信箱不存在或不是公共信箱")
+	ErrMailboxForbidden       = errors.New("无权管理此信箱")
+	ErrMailboxScheduleInvalid = errors.New("收取计划不正确")
+	ErrCollectionRunNotFound  = errors.New("收取轮次不存在")
+	ErrCollectionRunClosed    = errors.New("收取轮次已结束")
+	ErrCollectionRunBusy      = errors.New("信箱正在由其他信使收取")
+)
+
+// MailboxCollectionService 公共信箱收取：按计划生成收取轮次，信使开箱后逐封扫码登记为已收取
+type MailboxCollectionService struct {
+	db              *gorm.DB
+	qrTokenSvc      *QRTokenService
+	notificationSvc *NotificationService
+}
+
+// NewMailboxCollectionService 创建信箱收取服务
+func NewMailboxCollectionService(db *gorm.DB) *MailboxCollectionService {
+	return &MailboxCollectionService{db: db}
+}
+
+// SetQRTokenService 设置二维码签名令牌服务，设置后开箱扫码同样校验签名
+func (s *MailboxCollectionService) SetQRTokenService(qrTokenSvc *QRTokenService) {
+	s.qrTokenSvc = qrTokenSvc
+}
+
+// SetNotificationService 设置通知服务
+func (s *MailboxCollectionService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// Start 启动收取轮次生成与漏收标记协程
+func (s *MailboxCollectionService) Start() {
+	go func() {
+		ticker := time.NewTicker(mailboxRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			if _, err := s.GenerateRuns(now); err != nil {
+				log.Printf("Mailbox collection: failed to generate runs: %v", err)
+			}
+			if _, err := s.MarkMissedRuns(now); err != nil {
+				log.Printf("Mailbox collection: failed to mark missed runs: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// accessPrefix 操作人可管理的编码前缀；管理员不限范围，信使须达到 minLevel
+func (s *MailboxCollectionService) accessPrefix(userID string, minLevel int) (string, bool, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return "", false, ErrMailboxForbidden
+	}
+	if user.Role == models.RolePlatformAdmin || user.Role == models.RoleSuperAdmin {
+		return "", true, nil
+	}
+
+	var courier models.Courier
+	if err := s.db.Where("user_id = ? AND status = ?", userID, "approved").First(&courier).Error; err != nil {
+		return "", false, ErrMailboxForbidden
+	}
+	prefix := strings.ToUpper(strings.ReplaceAll(courier.ManagedOPCodePrefix, "*", ""))
+	if courier.Level < minLevel || prefix == "" {
+		return "", false, ErrMailboxForbidden
+	}
+	return prefix, false, nil
+}
+
+func (s *MailboxCollectionService) checkAccess(userID, boxCode string, minLevel int) error {
+	prefix, all, err := s.accessPrefix(userID, minLevel)
+	if err != nil {
+		return err
+	}
+	if !all && !strings.HasPrefix(boxCode, prefix) {
+		return ErrMailboxForbidden
+	}
+	return nil
+}
+
+// findBox 查找启用中的公共信箱
+func (s *MailboxCollectionService) findBox(code string) (*models.OPCode, error) {
+	var box models.OPCode
+	err := s.db.Where("code = ? AND point_type = ? AND is_active = ?", strings.ToUpper(code), models.OPCodeTypeBox, true).
+		First(&box).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMailboxNotFound
+		}
+		return nil, err
+	}
+	return &box, nil
+}
+
+func (s *MailboxCollectionService) findSchedule(boxCode string) (*models.MailboxSchedule, error) {
+	var schedule models.MailboxSchedule
+	err := s.db.Where("box_op_code = ?", boxCode).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// SetSchedule 设置信箱收取计划，二级及以上信使或管理员可操作；未开始的轮次按新计划重新生成
+func (s *MailboxCollectionService) SetSchedule(operatorID, boxCode string, req *models.MailboxScheduleRequest) (*models.MailboxSchedule, error) {
+	box, err := s.findBox(boxCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAccess(operatorID, box.Code, 2); err != nil {
+		return nil, err
+	}
+
+	times, err := normalizePickupTimes(req.PickupTimes)
+	if err != nil {
+		return nil, err
+	}
+	weekdays := normalizeWeekdays(req.Weekdays)
+	if req.CourierID != "" {
+		var count int64
+		if err := s.db.Model(&models.Courier{}).Where("user_id = ? AND status = ?", req.CourierID, "approved").
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: 负责信使不存在", ErrMailboxScheduleInvalid)
+		}
+	}
+
+	schedule, err := s.findSchedule(box.Code)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		schedule = &models.MailboxSchedule{
+			ID:        uuid.New().String(),
+			BoxOPCode: box.Code,
+			Capacity:  mailboxDefaultCapacity,
+			CreatedBy: operatorID,
+		}
+	}
+	schedule.PickupTimes = strings.Join(times, ",")
+	schedule.Weekdays = weekdays
+	schedule.CourierID = req.CourierID
+	if req.Capacity > 0 {
+		schedule.Capacity = req.Capacity
+	}
+	schedule.IsActive = req.IsActive == nil || *req.IsActive
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if schedule.CreatedAt.IsZero() {
+			if err := tx.Create(schedule).Error; err != nil {
+				return err
+			}
+			// is_active 有默认值，新建停用的计划需单独更新
+			if !schedule.IsActive {
+				return tx.Model(schedule).Update("is_active", false).Error
+			}
+		} else if err := tx.Save(schedule).Error; err != nil {
+			return err
+		}
+		return tx.Where("schedule_id = ? AND status = ? AND scheduled_at > ?", schedule.ID, models.CollectionRunScheduled, now).
+			Delete(&models.MailboxCollectionRun{}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存收取计划失败: %w", err)
+	}
+
+	if schedule.IsActive {
+		if _, err := s.generateScheduleRuns(schedule, now); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// ListSchedules 收取计划列表，信使只能看到管理范围内的信箱
+func (s *MailboxCollectionService) ListSchedules(operatorID string) ([]models.MailboxSchedule, error) {
+	prefix, all, err := s.accessPrefix(operatorID, 1)
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.Order("box_op_code")
+	if !all {
+		query = query.Where("box_op_code LIKE ?", prefix+"%")
+	}
+	var schedules []models.MailboxSchedule
+	return schedules, query.Find(&schedules).Error
+}
+
+// normalizePickupTimes 校验 HH:MM 并排序去重
+func normalizePickupTimes(values []string) ([]string, error) {
+	var times []string
+	for _, value := range values {
+		t, err := time.Parse("15:04", strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 收取时间须为 HH:MM：%s", ErrMailboxScheduleInvalid, value)
+		}
+		times = append(times, t.Format("15:04"))
+	}
+	sort.Strings(times)
+	times = uniqueStrings(times)
+	if len(times) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要一个收取时间", ErrMailboxScheduleInvalid)
+	}
+	return times, nil
+}
+
+// normalizeWeekdays 排序去重，未指定时为每天
+func normalizeWeekdays(values []int) string {
+	seen := make(map[int]bool)
+	var days []string
+	for day := 1; day <= 7; day++ {
+		seen[day] = len(values) == 0
+	}
+	for _, day := range values {
+		seen[day] = true
+	}
+	for day := 1; day <= 7; day++ {
+		if seen[day] {
+			days = append(days, strconv.Itoa(day))
+		}
+	}
+	return strings.Join(days, ",")
+}
+
+// scheduleSlots 计划在 [from, to) 内的收取时间
+func scheduleSlots(schedule *models.MailboxSchedule, from, to time.Time) []time.Time {
+	weekdays := make(map[int]bool)
+	for _, day := range strings.Split(schedule.Weekdays, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(day)); err == nil {
+			weekdays[n] = true
+		}
+	}
+
+	var slots []time.Time
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		if !weekdays[weekday] {
+			continue
+		}
+		for _, value := range strings.Split(schedule.PickupTimes, ",") {
+			t, err := time.Parse("15:04", value)
+			if err != nil {
+				continue
+			}
+			slot := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+			if !slot.Before(from) && slot.Before(to) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	return slots
+}
+
+// GenerateRuns 为启用中的计划生成未来的收取轮次，已存在的跳过
+func (s *MailboxCollectionService) GenerateRuns(now time.Time) (int, error) {
+	var schedules []models.MailboxSchedule
+	if err := s.db.Where("is_active = ?", true).Find(&schedules).Error; err != nil {
+		return 0, err
+	}
+	created := 0
+	for i := range schedules {
+		n, err := s.generateScheduleRuns(&schedules[i], now)
+		if err != nil {
+			return created, err
+		}
+		created += n
+	}
+	return created, nil
+}
+
+func (s *MailboxCollectionService) generateScheduleRuns(schedule *models.MailboxSchedule, now time.Time) (int, error) {
+	slots := scheduleSlots(schedule, now, now.Add(mailboxRunHorizon))
+	if len(slots) == 0 {
+		return 0, nil
+	}
+	runs := make([]models.MailboxCollectionRun, 0, len(slots))
+	for _, slot := range slots {
+		scheduleID := schedule.ID
+		runs = append(runs, models.MailboxCollectionRun{
+			ID:          uuid.New().String(),
+			ScheduleID:  &scheduleID,
+			BoxOPCode:   schedule.BoxOPCode,
+			CourierID:   schedule.CourierID,
+			Status:      models.CollectionRunScheduled,
+			ScheduledAt: slot,
+			Capacity:    schedule.Capacity,
+		})
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&runs)
+	return int(result.RowsAffected), result.Error
+}
+
+// MarkMissedRuns 超过宽限时间仍未开箱的轮次标记为漏收
+func (s *MailboxCollectionService) MarkMissedRuns(now time.Time) (int64, error) {
+	result := s.db.Model(&models.MailboxCollectionRun{}).
+		Where("status = ? AND scheduled_at < ?", models.CollectionRunScheduled, now.Add(-mailboxRunGrace)).
+		Update("status", models.CollectionRunMissed)
+	return result.RowsAffected, result.Error
+}
+
+// StartRun 信使扫描信箱二维码开箱：接手当前时段的计划轮次，没有时创建临时轮次
+func (s *MailboxCollectionService) StartRun(courierID string, req *models.StartCollectionRunRequest) (*models.MailboxCollectionRun, error) {
+	box, err := s.findBox(req.BoxOPCode)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.findSchedule(box.Code)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil || schedule.CourierID != courierID {
+		if err := s.checkAccess(courierID, box.Code, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	var active models.MailboxCollectionRun
+	err = s.db.Where("box_op_code = ? AND status = ?", box.Code, models.CollectionRunInProgress).First(&active).Error
+	if err == nil {
+		if active.CourierID != courierID {
+			return nil, ErrCollectionRunBusy
+		}
+		return &active, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	capacity := mailboxDefaultCapacity
+	if schedule != nil {
+		capacity = schedule.Capacity
+	}
+	updates := map[string]interface{}{
+		"status":     models.CollectionRunInProgress,
+		"courier_id": courierID,
+		"started_at": now,
+		"capacity":   capacity,
+		"latitude":   req.Latitude,
+		"longitude":  req.Longitude,
+	}
+
+	var due models.MailboxCollectionRun
+	err = s.db.Where("box_op_code = ? AND status = ? AND scheduled_at BETWEEN ? AND ?",
+		box.Code, models.CollectionRunScheduled, now.Add(-mailboxRunGrace), now.Add(mailboxRunEarly)).
+		Order("scheduled_at ASC").First(&due).Error
+	if err == nil {
+		// 只有仍处于待收取的轮次可以接手，避免两名信使同时开箱
+		result := s.db.Model(&models.MailboxCollectionRun{}).
+			Where("id = ? AND status = ?", due.ID, models.CollectionRunScheduled).Updates(updates)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrCollectionRunBusy
+		}
+		return s.loadRun(due.ID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	run := &models.MailboxCollectionRun{
+		ID:          uuid.New().String(),
+		BoxOPCode:   box.Code,
+		CourierID:   courierID,
+		Status:      models.CollectionRunInProgress,
+		ScheduledAt: now,
+		StartedAt:   &now,
+		Capacity:    capacity,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("创建收取轮次失败: %w", err)
+	}
+	return run, nil
+}
+
+func (s *MailboxCollectionService) loadRun(runID string) (*models.MailboxCollectionRun, error) {
+	var run models.MailboxCollectionRun
+	if err := s.db.First(&run, "id = ?", runID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCollectionRunNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// openRun 信使自己进行中的轮次
+func (s *MailboxCollectionService) openRun(runID, courierID string) (*models.MailboxCollectionRun, error) {
+	run, err := s.loadRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.CourierID != courierID {
+		return nil, ErrCollectionRunNotFound
+	}
+	if run.Status != models.CollectionRunInProgress {
+		return nil, ErrCollectionRunClosed
+	}
+	return run, nil
+}
+
+// ScanLetters 登记开箱扫到的信件，每封单独处理：待收取的信件转为已收取并生成信使任务
+func (s *MailboxCollectionService) ScanLetters(runID, courierID string, req *models.CollectionScanRequest) (*models.CollectionScanResponse, error) {
+	run, err := s.openRun(runID, courierID)
+	if err != nil {
+		return nil, err
+	}
+
+	var scanned []string
+	if err := s.db.Model(&models.MailboxCollectionItem{}).Where("run_id = ?", run.ID).
+		Pluck("letter_code", &scanned).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(scanned))
+	for _, code := range scanned {
+		seen[code] = true
+	}
+
+	response := &models.CollectionScanResponse{RunID: run.ID, Results: []models.CollectionScanResult{}}
+	for _, item := range req.Items {
+		code := strings.TrimSpace(item.Code)
+		result := models.CollectionScanResult{Code: code}
+		if seen[code] {
+			result.Result = models.CollectionScanDuplicate
+			response.Duplicates++
+		} else if reason, err := s.collectLetter(run, courierID, code, item.QRToken); err != nil {
+			return nil, err
+		} else if reason != "" {
+			result.Result = models.CollectionScanRejected
+			result.Reason = reason
+			response.Rejected++
+		} else {
+			result.Result = models.CollectionScanCollected
+			response.Collected++
+			seen[code] = true
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if err := s.db.Model(&models.MailboxCollectionRun{}).Where("id = ?", run.ID).
+		Pluck("letter_count", &response.LetterCount).Error; err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// collectLetter 收取一封信，返回拒收原因；数据库错误时返回 error
+func (s *MailboxCollectionService) collectLetter(run *models.MailboxCollectionRun, courierID, code, qrToken string) (string, error) {
+	var letterCode models.LetterCode
+	if err := s.db.Preload("Letter").Preload("Letter.User").First(&letterCode, "code = ?", code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "信件不存在或编号错误", nil
+		}
+		return "", err
+	}
+	if s.qrTokenSvc != nil {
+		if _, err := s.qrTokenSvc.VerifyScan(qrToken, code); err != nil {
+			return err.Error(), nil
+		}
+	}
+	letter := &letterCode.Letter
+	if letter.Status != models.StatusGenerated {
+		return fmt.Sprintf("信件当前状态为 %s，不能收取", letter.Status), nil
+	}
+
+	now := time.Now()
+	var rejected string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Letter{}).Where("id = ? AND status = ?", letter.ID, models.StatusGenerated).
+			Update("status", models.StatusCollected)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			rejected = "信件已被其他信使收取"
+			return nil
+		}
+
+		if err := tx.Model(&models.LetterCode{}).Where("id = ?", letterCode.ID).Updates(map[string]interface{}{
+			"last_scanned_by": courierID,
+			"last_scanned_at": now,
+			"scan_count":      gorm.Expr("scan_count + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.StatusLog{
+			ID:        uuid.New().String(),
+			LetterID:  letter.ID,
+			Status:    models.StatusCollected,
+			UpdatedBy: courierID,
+			Location:  run.BoxOPCode,
+			Note:      "公共信箱收取",
+		}).Error; err != nil {
+			return err
+		}
+
+		// 已分配的任务跟上进度，否则由开箱信使接下投递任务
+		var activeTasks int64
+		if err := tx.Model(&models.CourierTask{}).Where("letter_code = ? AND status NOT IN ?", code,
+			[]string{models.CourierTaskStatusDelivered, models.CourierTaskStatusFailed, models.CourierTaskStatusRerouted}).
+			Count(&activeTasks).Error; err != nil {
+			return err
+		}
+		if activeTasks > 0 {
+			if err := alignActiveCourierTask(tx, code, models.StatusCollected); err != nil {
+				return err
+			}
+		} else if err := tx.Create(&models.CourierTask{
+			ID:              uuid.New().String(),
+			CourierID:       courierID,
+			LetterCode:      code,
+			Title:           "信箱收取: " + letter.Title,
+			SenderName:      getSenderName(letter),
+			TargetLocation:  letter.DeliveryTarget(),
+			CurrentLocation: run.BoxOPCode,
+			PickupOPCode:    run.BoxOPCode,
+			DeliveryOPCode:  letter.DeliveryTarget(),
+			CurrentOPCode:   run.BoxOPCode,
+			Status:          models.CourierTaskStatusCollected,
+			Priority:        "normal",
+			Deadline:        now.Add(48 * time.Hour),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.MailboxCollectionItem{
+			ID:         uuid.New().String(),
+			RunID:      run.ID,
+			LetterCode: code,
+			LetterID:   letter.ID,
+			Result:     models.CollectionScanCollected,
+			ScannedAt:  now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.MailboxCollectionRun{}).Where("id = ?", run.ID).
+			Update("letter_count", gorm.Expr("letter_count + 1")).Error; err != nil {
+			return err
+		}
+		return recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, code, "公共信箱收取")
+	})
+	if err != nil {
+		return "", fmt.Errorf("收取信件失败: %w", err)
+	}
+	if rejected != "" {
+		return rejected, nil
+	}
+
+	if s.notificationSvc != nil {
+		if err := s.notificationSvc.NotifyUser(letter.UserID, "letter_collected", map[string]interface{}{
+			"letter_id": letter.ID,
+			"code":      code,
+			"status":    models.StatusCollected,
+			"location":  run.BoxOPCode,
+		}); err != nil {
+			log.Printf("Failed to send collection notification: %v", err)
+		}
+	}
+	return "", nil
+}
+
+// CompleteRun 信箱已清空，结束本轮收取
+func (s *MailboxCollectionService) CompleteRun(runID, courierID string, req *models.CompleteCollectionRunRequest) (*models.MailboxCollectionRun, error) {
+	run, err := s.openRun(runID, courierID)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.MailboxCollectionItem{}).Where("run_id = ?", run.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := s.db.Model(&models.MailboxCollectionRun{}).
+		Where("id = ? AND status = ?", run.ID, models.CollectionRunInProgress).
+		Updates(map[string]interface{}{
+			"status":       models.CollectionRunCompleted,
+			"completed_at": now,
+			"letter_count": count,
+			"notes":        req.Notes,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCollectionRunClosed
+	}
+	return s.loadRun(run.ID)
+}
+
+// GetRun 收取轮次详情及扫到的信件，信使本人或管理范围内的上级可查看
+func (s *MailboxCollectionService) GetRun(runID, userID string) (*models.MailboxCollectionRun, error) {
+	var run models.MailboxCollectionRun
+	err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("scanned_at ASC")
+	}).First(&run, "id = ?", runID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCollectionRunNotFound
+		}
+		return nil, err
+	}
+	if run.CourierID != userID {
+		if err := s.checkAccess(userID, run.BoxOPCode, 2); err != nil {
+			return nil, ErrCollectionRunNotFound
+		}
+	}
+	return &run, nil
+}
+
+// ListRuns 收取轮次列表，按计划时间倒序；信使看到管理范围内的信箱
+func (s *MailboxCollectionService) ListRuns(userID, boxCode, status string, page, limit int) ([]models.MailboxCollectionRun, int64, error) {
+	prefix, all, err := s.accessPrefix(userID, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&models.MailboxCollectionRun{})
+	if !all {
+		query = query.Where("box_op_code LIKE ? OR courier_id = ?", prefix+"%", userID)
+	}
+	if boxCode != "" {
+		query = query.Where("box_op_code = ?", strings.ToUpper(boxCode))
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var runs []models.MailboxCollectionRun
+	err = query.Order("scheduled_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
+
+// FillStats 最近 days 天各信箱的装满程度和收取频率建议，最满的排在前面
+func (s *MailboxCollectionService) FillStats(userID, prefix string, days int) ([]models.MailboxFillStats, error) {
+	managed, all, err := s.accessPrefix(userID, 2)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.ToUpper(prefix)
+	if !all {
+		switch {
+		case strings.HasPrefix(managed, prefix):
+			prefix = managed
+		case !strings.HasPrefix(prefix, managed):
+			return nil, ErrMailboxForbidden
+		}
+	}
+	if days <= 0 || days > 180 {
+		days = mailboxStatsDefaultDays
+	}
+
+	var boxes []models.OPCode
+	if err := s.db.Where("point_type = ? AND is_active = ? AND code LIKE ?", models.OPCodeTypeBox, true, prefix+"%").
+		Order("code").Find(&boxes).Error; err != nil {
+		return nil, err
+	}
+	if len(boxes) == 0 {
+		return []models.MailboxFillStats{}, nil
+	}
+	codes := make([]string, len(boxes))
+	for i, box := range boxes {
+		codes[i] = box.Code
+	}
+
+	var schedules []models.MailboxSchedule
+	if err := s.db.Where("box_op_code IN ?", codes).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	scheduleByBox := make(map[string]*models.MailboxSchedule, len(schedules))
+	for i := range schedules {
+		scheduleByBox[schedules[i].BoxOPCode] = &schedules[i]
+	}
+
+	var runs []models.MailboxCollectionRun
+	if err := s.db.Where("box_op_code IN ? AND status IN ? AND scheduled_at >= ?", codes,
+		[]string{models.CollectionRunCompleted, models.CollectionRunMissed}, time.Now().AddDate(0, 0, -days)).
+		Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	runsByBox := make(map[string][]models.MailboxCollectionRun)
+	for _, run := range runs {
+		runsByBox[run.BoxOPCode] = append(runsByBox[run.BoxOPCode], run)
+	}
+
+	stats := make([]models.MailboxFillStats, 0, len(boxes))
+	for _, box := range boxes {
+		stats = append(stats, mailboxFillStats(box, scheduleByBox[box.Code], runsByBox[box.Code], days))
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].AvgFillRate > stats[j].AvgFillRate })
+	return stats, nil
+}
+
+// mailboxFillStats 汇总单个信箱的收取记录；建议频率使每次收取时信箱约装到六成，最少两天一次
+func mailboxFillStats(box models.OPCode, schedule *models.MailboxSchedule, runs []models.MailboxCollectionRun, days int) models.MailboxFillStats {
+	stat := models.MailboxFillStats{
+		BoxOPCode: box.Code,
+		PointName: box.PointName,
+		Capacity:  mailboxDefaultCapacity,
+		Days:      days,
+		Advice:    models.CollectionAdviceKeep,
+	}
+	if schedule != nil {
+		stat.Capacity = schedule.Capacity
+		if schedule.IsActive {
+			stat.PickupsPerDay = roundTo(float64(len(strings.Split(schedule.PickupTimes, ",")))*
+				float64(len(strings.Split(schedule.Weekdays, ",")))/7, 2)
+		}
+	}
+
+	var fillSum float64
+	for i := range runs {
+		run := &runs[i]
+		if run.Status == models.CollectionRunMissed {
+			stat.RunsMissed++
+			continue
+		}
+		stat.RunsCompleted++
+		stat.TotalLetters += run.LetterCount
+		if run.LetterCount == 0 {
+			stat.EmptyRuns++
+		}
+		if run.LetterCount > stat.MaxLetters {
+			stat.MaxLetters = run.LetterCount
+		}
+		fillRate := run.FillRate()
+		fillSum += fillRate
+		stat.PeakFillRate = math.Max(stat.PeakFillRate, fillRate)
+	}
+	stat.SuggestedPickupsPerDay = stat.PickupsPerDay
+	if stat.RunsCompleted == 0 {
+		return stat
+	}
+
+	stat.AvgLetters = roundTo(float64(stat.TotalLetters)/float64(stat.RunsCompleted), 2)
+	stat.AvgFillRate = roundTo(fillSum/float64(stat.RunsCompleted), 2)
+	stat.PeakFillRate = roundTo(stat.PeakFillRate, 2)
+	stat.LettersPerDay = roundTo(float64(stat.TotalLetters)/float64(days), 2)
+
+	// 按半次取整：0.5 表示两天一次
+	needed := stat.LettersPerDay / (float64(stat.Capacity) * mailboxTargetFillRate)
+	stat.SuggestedPickupsPerDay = math.Max(0.5, math.Ceil(needed*2)/2)
+	if stat.PeakFillRate >= 0.9 && stat.SuggestedPickupsPerDay <= stat.PickupsPerDay {
+		stat.SuggestedPickupsPerDay = math.Ceil(stat.PickupsPerDay) + 1
+	}
+
+	switch {
+	case stat.SuggestedPickupsPerDay > stat.PickupsPerDay:
+		stat.Advice = models.CollectionAdviceIncrease
+	case stat.SuggestedPickupsPerDay < stat.PickupsPerDay && stat.AvgFillRate < 0.3:
+		stat.Advice = models.CollectionAdviceDecrease
+	default:
+		stat.SuggestedPickupsPerDay = stat.PickupsPerDay
+	}
+	return stat
+}
+
+func roundTo(value float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+}
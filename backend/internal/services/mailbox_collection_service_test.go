@@ -0,0 +1,264 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// MailboxCollectionTestSuite 公共信箱收取测试套件
+type MailboxCollectionTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *MailboxCollectionService
+	courier *models.User
+	manager *models.User
+	sender  *models.User
+}
+
+func (suite *MailboxCollectionTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewMailboxCollectionService(db)
+
+	suite.courier = config.CreateTestUser(db, "box_courier", models.RoleCourierLevel1)
+	suite.NoError(db.Model(config.CreateTestCourier(db, suite.courier.ID, 1)).Update("managed_op_code_prefix", "PK5F").Error)
+	suite.manager = config.CreateTestUser(db, "box_manager", models.RoleCourierLevel2)
+	suite.NoError(db.Model(config.CreateTestCourier(db, suite.manager.ID, 2)).Update("managed_op_code_prefix", "PK5F").Error)
+	suite.sender = config.CreateTestUser(db, "box_sender", models.RoleUser)
+
+	for _, code := range []string{"PK5FB1", "PK5FB2"} {
+		suite.NoError(db.Create(&models.OPCode{
+			ID: uuid.New().String(), Code: code, SchoolCode: "PK", AreaCode: "5F", PointCode: code[4:],
+			PointType: models.OPCodeTypeBox, PointName: "信箱" + code[4:], IsActive: true, ManagedBy: "admin",
+		}).Error)
+	}
+	suite.NoError(db.Create(&models.OPCode{
+		ID: uuid.New().String(), Code: "PK5F3D", SchoolCode: "PK", AreaCode: "5F", PointCode: "3D",
+		PointType: models.OPCodeTypeDormitory, IsActive: true, ManagedBy: "admin",
+	}).Error)
+}
+
+// createLetter 投进信箱的信件，条码为 code
+func (suite *MailboxCollectionTestSuite) createLetter(code string, status models.LetterStatus) *models.Letter {
+	letter := &models.Letter{
+		ID: uuid.New().String(), UserID: suite.sender.ID, Title: "给新同学的信", Content: "你好",
+		Status: status, RecipientOPCode: "PK3D12",
+	}
+	suite.NoError(suite.db.Create(letter).Error)
+	suite.NoError(suite.db.Create(&models.LetterCode{
+		ID: uuid.New().String(), LetterID: letter.ID, Code: code, Status: models.BarcodeStatusBound,
+	}).Error)
+	return letter
+}
+
+func (suite *MailboxCollectionTestSuite) TestSetSchedule_GeneratesRuns() {
+	schedule, err := suite.service.SetSchedule(suite.manager.ID, "pk5fb1", &models.MailboxScheduleRequest{
+		PickupTimes: []string{"17:30", "08:00", "17:30"},
+		Capacity:    30,
+	})
+	suite.NoError(err)
+	suite.Equal("08:00,17:30", schedule.PickupTimes)
+	suite.Equal("1,2,3,4,5,6,7", schedule.Weekdays)
+	suite.Equal(30, schedule.Capacity)
+
+	var runs []models.MailboxCollectionRun
+	suite.NoError(suite.db.Where("schedule_id = ?", schedule.ID).Find(&runs).Error)
+	suite.GreaterOrEqual(len(runs), 3)
+	suite.LessOrEqual(len(runs), 4)
+	for _, run := range runs {
+		suite.Equal(models.CollectionRunScheduled, run.Status)
+		suite.True(run.ScheduledAt.After(time.Now()))
+	}
+
+	// 重复生成不会产生重复轮次
+	created, err := suite.service.GenerateRuns(time.Now())
+	suite.NoError(err)
+	suite.Zero(created)
+
+	// 修改计划后未开始的轮次按新计划重新生成
+	_, err = suite.service.SetSchedule(suite.manager.ID, "PK5FB1", &models.MailboxScheduleRequest{
+		PickupTimes: []string{"12:00"},
+	})
+	suite.NoError(err)
+	var count int64
+	suite.db.Model(&models.MailboxCollectionRun{}).Where("schedule_id = ?", schedule.ID).Count(&count)
+	suite.GreaterOrEqual(count, int64(1))
+	suite.LessOrEqual(count, int64(2))
+
+	_, err = suite.service.SetSchedule(suite.manager.ID, "PK5FB1", &models.MailboxScheduleRequest{PickupTimes: []string{"25:00"}})
+	suite.ErrorIs(err, ErrMailboxScheduleInvalid)
+	_, err = suite.service.SetSchedule(suite.courier.ID, "PK5FB1", &models.MailboxScheduleRequest{PickupTimes: []string{"08:00"}})
+	suite.ErrorIs(err, ErrMailboxForbidden)
+	_, err = suite.service.SetSchedule(suite.manager.ID, "PK5F3D", &models.MailboxScheduleRequest{PickupTimes: []string{"08:00"}})
+	suite.ErrorIs(err, ErrMailboxNotFound)
+}
+
+func (suite *MailboxCollectionTestSuite) TestEmptyBox_CollectsLetters() {
+	first := suite.createLetter("OPBOX001", models.StatusGenerated)
+	suite.createLetter("OPBOX002", models.StatusGenerated)
+	suite.createLetter("OPBOX003", models.StatusDraft)
+
+	run, err := suite.service.StartRun(suite.courier.ID, &models.StartCollectionRunRequest{BoxOPCode: "PK5FB1"})
+	suite.NoError(err)
+	suite.Equal(models.CollectionRunInProgress, run.Status)
+	suite.Nil(run.ScheduleID)
+	suite.Equal(mailboxDefaultCapacity, run.Capacity)
+
+	// 同一信使再次扫描信箱时继续本轮
+	again, err := suite.service.StartRun(suite.courier.ID, &models.StartCollectionRunRequest{BoxOPCode: "PK5FB1"})
+	suite.NoError(err)
+	suite.Equal(run.ID, again.ID)
+	_, err = suite.service.StartRun(suite.manager.ID, &models.StartCollectionRunRequest{BoxOPCode: "PK5FB1"})
+	suite.ErrorIs(err, ErrCollectionRunBusy)
+
+	response, err := suite.service.ScanLetters(run.ID, suite.courier.ID, &models.CollectionScanRequest{
+		Items: []models.CollectionScanItem{{Code: "OPBOX001"}, {Code: "OPBOX002"}, {Code: "OPBOX001"}, {Code: "OPBOX003"}, {Code: "OPNONE"}},
+	})
+	suite.NoError(err)
+	suite.Equal(2, response.Collected)
+	suite.Equal(1, response.Duplicates)
+	suite.Equal(2, response.Rejected)
+	suite.Equal(2, response.LetterCount)
+	suite.Equal(models.CollectionScanDuplicate, response.Results[2].Result)
+	suite.Equal(models.CollectionScanRejected, response.Results[3].Result)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", first.ID).Error)
+	suite.Equal(models.StatusCollected, letter.Status)
+
+	var task models.CourierTask
+	suite.NoError(suite.db.Where("letter_code = ?", "OPBOX001").First(&task).Error)
+	suite.Equal(suite.courier.ID, task.CourierID)
+	suite.Equal(models.CourierTaskStatusCollected, task.Status)
+	suite.Equal("PK5FB1", task.PickupOPCode)
+	suite.Equal("PK3D12", task.DeliveryOPCode)
+
+	var event models.LetterOutboxEvent
+	suite.NoError(suite.db.Where("letter_code = ?", "OPBOX001").First(&event).Error)
+	suite.Contains(event.Payload, `"status":"collected"`)
+
+	// 下一批扫码时，上一批已收取的信件识别为重复
+	response, err = suite.service.ScanLetters(run.ID, suite.courier.ID, &models.CollectionScanRequest{
+		Items: []models.CollectionScanItem{{Code: "OPBOX002"}},
+	})
+	suite.NoError(err)
+	suite.Equal(1, response.Duplicates)
+
+	completed, err := suite.service.CompleteRun(run.ID, suite.courier.ID, &models.CompleteCollectionRunRequest{Notes: "已清空"})
+	suite.NoError(err)
+	suite.Equal(models.CollectionRunCompleted, completed.Status)
+	suite.Equal(2, completed.LetterCount)
+	suite.NotNil(completed.CompletedAt)
+
+	_, err = suite.service.ScanLetters(run.ID, suite.courier.ID, &models.CollectionScanRequest{
+		Items: []models.CollectionScanItem{{Code: "OPBOX003"}},
+	})
+	suite.ErrorIs(err, ErrCollectionRunClosed)
+
+	detail, err := suite.service.GetRun(run.ID, suite.manager.ID)
+	suite.NoError(err)
+	suite.Len(detail.Items, 2)
+}
+
+func (suite *MailboxCollectionTestSuite) TestStartRun_TakesDueScheduledRun() {
+	schedule, err := suite.service.SetSchedule(suite.manager.ID, "PK5FB1", &models.MailboxScheduleRequest{
+		PickupTimes: []string{"08:00"},
+		Capacity:    20,
+	})
+	suite.NoError(err)
+	scheduleID := schedule.ID
+	due := &models.MailboxCollectionRun{
+		ID: uuid.New().String(), ScheduleID: &scheduleID, BoxOPCode: "PK5FB1",
+		Status: models.CollectionRunScheduled, ScheduledAt: time.Now().Add(-30 * time.Minute), Capacity: 20,
+	}
+	suite.NoError(suite.db.Create(due).Error)
+	overdue := &models.MailboxCollectionRun{
+		ID: uuid.New().String(), ScheduleID: &scheduleID, BoxOPCode: "PK5FB1",
+		Status: models.CollectionRunScheduled, ScheduledAt: time.Now().Add(-3 * time.Hour), Capacity: 20,
+	}
+	suite.NoError(suite.db.Create(overdue).Error)
+
+	run, err := suite.service.StartRun(suite.courier.ID, &models.StartCollectionRunRequest{BoxOPCode: "PK5FB1"})
+	suite.NoError(err)
+	suite.Equal(due.ID, run.ID)
+	suite.Equal(suite.courier.ID, run.CourierID)
+	suite.Equal(20, run.Capacity)
+
+	missed, err := suite.service.MarkMissedRuns(time.Now())
+	suite.NoError(err)
+	suite.Equal(int64(1), missed)
+	suite.NoError(suite.db.First(overdue, "id = ?", overdue.ID).Error)
+	suite.Equal(models.CollectionRunMissed, overdue.Status)
+
+	// 管理范围外的信使不能开箱
+	outsider := config.CreateTestUser(suite.db, "qh_courier", models.RoleCourierLevel1)
+	suite.NoError(suite.db.Model(config.CreateTestCourier(suite.db, outsider.ID, 1)).Update("managed_op_code_prefix", "QH").Error)
+	_, err = suite.service.StartRun(outsider.ID, &models.StartCollectionRunRequest{BoxOPCode: "PK5FB2"})
+	suite.ErrorIs(err, ErrMailboxForbidden)
+}
+
+func (suite *MailboxCollectionTestSuite) TestFillStats_Advice() {
+	for box, capacity := range map[string]int{"PK5FB1": 10, "PK5FB2": 50} {
+		_, err := suite.service.SetSchedule(suite.manager.ID, box, &models.MailboxScheduleRequest{
+			PickupTimes: []string{"08:00"}, Capacity: capacity,
+		})
+		suite.NoError(err)
+	}
+
+	// B1 容量10，每天一次，最多收到9封；B2 容量50，多数轮次为空
+	for i, letters := range []int{4, 9, 5, 6} {
+		suite.createRun("PK5FB1", 10, letters, models.CollectionRunCompleted, i+1)
+	}
+	for i, letters := range []int{0, 0, 1, 0} {
+		suite.createRun("PK5FB2", 50, letters, models.CollectionRunCompleted, i+1)
+	}
+	suite.createRun("PK5FB2", 50, 0, models.CollectionRunMissed, 5)
+
+	stats, err := suite.service.FillStats(suite.manager.ID, "", 7)
+	suite.NoError(err)
+	suite.Len(stats, 2)
+
+	full := stats[0]
+	suite.Equal("PK5FB1", full.BoxOPCode)
+	suite.Equal(4, full.RunsCompleted)
+	suite.Equal(24, full.TotalLetters)
+	suite.Equal(9, full.MaxLetters)
+	suite.Equal(0.6, full.AvgFillRate)
+	suite.Equal(0.9, full.PeakFillRate)
+	suite.Equal(1.0, full.PickupsPerDay)
+	suite.Equal(models.CollectionAdviceIncrease, full.Advice)
+	suite.Greater(full.SuggestedPickupsPerDay, full.PickupsPerDay)
+
+	quiet := stats[1]
+	suite.Equal("PK5FB2", quiet.BoxOPCode)
+	suite.Equal(3, quiet.EmptyRuns)
+	suite.Equal(1, quiet.RunsMissed)
+	suite.Equal(models.CollectionAdviceDecrease, quiet.Advice)
+	suite.Equal(0.5, quiet.SuggestedPickupsPerDay)
+
+	_, err = suite.service.FillStats(suite.courier.ID, "", 7)
+	suite.ErrorIs(err, ErrMailboxForbidden)
+	_, err = suite.service.FillStats(suite.manager.ID, "QH", 7)
+	suite.ErrorIs(err, ErrMailboxForbidden)
+}
+
+func (suite *MailboxCollectionTestSuite) createRun(box string, capacity, letters int, status string, daysAgo int) {
+	suite.NoError(suite.db.Create(&models.MailboxCollectionRun{
+		ID: uuid.New().String(), BoxOPCode: box, CourierID: suite.courier.ID, Status: status,
+		ScheduledAt: time.Now().AddDate(0, 0, -daysAgo), Capacity: capacity, LetterCount: letters,
+		Notes: fmt.Sprintf("第%d天", daysAgo),
+	}).Error)
+}
+
+func TestMailboxCollectionService(t *testing.T) {
+	suite.Run(t, new(MailboxCollectionTestSuite))
+}
@@ -191,6 +191,45 @@ func (s *NotificationService) sendEmailNotification(notification *models.Notific
 	s.db.Save(emailLog)
 }
 
+// SendTransactionalEmail 向指定邮箱发送事务性邮件（如验证码），不受通知偏好影响
+func (s *NotificationService) SendTransactionalEmail(userID, toEmail, subject, content string) error {
+	now := time.Now()
+	emailLog := &models.EmailLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		ToEmail:   toEmail,
+		FromEmail: s.config.EmailFromAddress,
+		Subject:   subject,
+		Provider:  "smtp",
+		Status:    models.NotificationPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.db.Create(emailLog)
+
+	userName := toEmail
+	var user models.User
+	if err := s.db.Select("username").First(&user, "id = ?", userID).Error; err == nil {
+		userName = user.Username
+	}
+
+	err := s.sendSMTPEmail(toEmail, subject, content, userName)
+
+	now = time.Now()
+	if err != nil {
+		emailLog.Status = models.NotificationFailed
+		emailLog.ErrorMessage = err.Error()
+		emailLog.RetryCount++
+	} else {
+		emailLog.Status = models.NotificationSent
+		emailLog.SentAt = &now
+	}
+	emailLog.UpdatedAt = now
+	s.db.Save(emailLog)
+
+	return err
+}
+
 // sendWebSocketNotification 发送WebSocket通知（内部方法）
 func (s *NotificationService) sendWebSocketNotification(notification *models.Notification, user *models.User) {
 	now := time.Now()
@@ -430,6 +469,10 @@ func (s *NotificationService) buildNotificationContent(notificationType string,
 		return "新配送任务", "系统为您创建了新的配送任务。"
 	case "system_maintenance":
 		return "系统维护通知", "系统将于指定时间进行维护，期间可能影响服务使用。"
+	case "data_export_ready":
+		return "个人数据导出已完成", "您申请的个人数据导出包已生成，请在7天内下载。"
+	case "account_erasure_scheduled":
+		return "账户删除申请已受理", "您的账户将在14天冷静期结束后被永久删除，期间可随时撤销申请。"
 	default:
 		return "系统通知", "您有一条新的系统通知。"
 	}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+)
+
+const ocrHTTPTimeout = 2 * time.Minute // 手写识别较慢，多引擎投票时更久
+
+// OCRRequest 一张待识别的图片
+type OCRRequest struct {
+	Image       io.Reader
+	FileName    string
+	Language    string
+	Handwriting bool
+	RequestedBy string
+}
+
+// OCRRegion 识别出的一段文字，BBox 为 x1,y1,x2,y2
+type OCRRegion struct {
+	Text       string
+	Confidence float64
+	BBox       [4]int
+}
+
+// OCRResult 识别结果
+type OCRResult struct {
+	Text       string
+	Confidence float64
+	Engine     string
+	Regions    []OCRRegion
+}
+
+// OCRClient 文字识别实现
+type OCRClient interface {
+	Recognize(ctx context.Context, req *OCRRequest) (*OCRResult, error)
+}
+
+// HTTPOCRClient 调用 services/ocr-service 的识别接口
+type HTTPOCRClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewHTTPOCRClient 创建 ocr-service 客户端，使用仅对识别接口有效的服务密钥认证，不代表任何用户
+func NewHTTPOCRClient(cfg *config.Config) *HTTPOCRClient {
+	return &HTTPOCRClient{
+		baseURL:    strings.TrimRight(cfg.OCRServiceURL, "/"),
+		apiKey:     cfg.OCRServiceAPIKey,
+		httpClient: &http.Client{Timeout: ocrHTTPTimeout},
+	}
+}
+
+// ocrServiceResponse ocr-service 统一响应中的识别结果
+type ocrServiceResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data *struct {
+		Results *struct {
+			Text       string  `json:"text"`
+			Confidence float64 `json:"confidence"`
+			Blocks     []struct {
+				Text       string  `json:"text"`
+				Confidence float64 `json:"confidence"`
+				BBox       []int   `json:"bbox"`
+			} `json:"blocks"`
+		} `json:"results"`
+		Metadata struct {
+			ProcessingMethod string `json:"processing_method"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+// Recognize 上传图片到 /api/ocr/recognize，保留全部文字块由寄信人确认
+func (c *HTTPOCRClient) Recognize(ctx context.Context, req *OCRRequest) (*OCRResult, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, req.Image); err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	fields := map[string]string{
+		"language":             req.Language,
+		"is_handwriting":       fmt.Sprintf("%t", req.Handwriting),
+		"enhance":              "true",
+		"confidence_threshold": "0",
+	}
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/ocr/recognize", &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	httpReq.Header.Set("X-Service-Key", c.apiKey)
+	httpReq.Header.Set("X-Requested-By", req.RequestedBy)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ocr service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed ocrServiceResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("invalid ocr response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || parsed.Data == nil || parsed.Data.Results == nil {
+		return nil, fmt.Errorf("ocr failed (HTTP %d): %s", resp.StatusCode, parsed.Msg)
+	}
+
+	results := parsed.Data.Results
+	result := &OCRResult{
+		Text:       results.Text,
+		Confidence: results.Confidence,
+		Engine:     parsed.Data.Metadata.ProcessingMethod,
+	}
+	for _, block := range results.Blocks {
+		region := OCRRegion{Text: block.Text, Confidence: block.Confidence}
+		copy(region.BBox[:], block.BBox)
+		result.Regions = append(result.Regions, region)
+	}
+	return result, nil
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrForwardNotHolder = errors.New("只能为自己当前绑定的OP Code登记转寄")
+	ErrForwardSameCode  = errors.New("转寄目标不能与原编码相同")
+	ErrForwardCycle     = errors.New("转寄规则会形成循环")
+	ErrForwardExpiry    = errors.New("转寄有效期须在一年以内")
+	ErrForwardNotFound  = errors.New("转寄规则不存在")
+	ErrForwardBadCode   = errors.New("OP Code格式不正确")
+)
+
+const (
+	forwardDefaultTTL = 180 * 24 * time.Hour // 默认有效期，覆盖一个学期
+	forwardMaxTTL     = 366 * 24 * time.Hour
+	forwardMaxHops    = 5 // 连续搬家时沿规则链最多转寄的次数
+)
+
+// 会被转寄的信件状态：尚未送达的信件
+var forwardableLetterStatuses = []models.LetterStatus{
+	models.StatusDraft, models.StatusGenerated, models.StatusCollected, models.StatusInTransit,
+}
+
+// ForwardResolution 转寄解析结果
+type ForwardResolution struct {
+	Code    string                // 最终投递的OP Code
+	Forward *models.OPCodeForward // 原编码上生效的第一条规则，未转寄时为空
+	Hops    int
+}
+
+// OPCodeForwardService OP Code转寄服务
+// 住户搬离后登记旧编码到新编码的转寄，写信和分配信使任务时按规则改投，已在途的信件关闭原投递段并新建一段
+type OPCodeForwardService struct {
+	db              *gorm.DB
+	opcodeService   *OPCodeService
+	notificationSvc *NotificationService
+}
+
+// NewOPCodeForwardService 创建OP Code转寄服务
+func NewOPCodeForwardService(db *gorm.DB) *OPCodeForwardService {
+	return &OPCodeForwardService{db: db}
+}
+
+// SetOPCodeService 设置OP Code服务，用于校验转寄目标
+func (s *OPCodeForwardService) SetOPCodeService(opcodeService *OPCodeService) {
+	s.opcodeService = opcodeService
+}
+
+// SetNotificationService 设置通知服务（避免循环依赖）
+func (s *OPCodeForwardService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// Resolve 沿转寄规则链解析实际投递的OP Code
+func (s *OPCodeForwardService) Resolve(code string, at time.Time) (*ForwardResolution, error) {
+	result := &ForwardResolution{Code: code}
+	visited := map[string]bool{code: true}
+	for result.Hops < forwardMaxHops {
+		forward, err := s.effectiveForward(s.db, result.Code, at)
+		if err != nil {
+			return nil, err
+		}
+		if forward == nil || visited[forward.ToCode] {
+			break
+		}
+		if result.Forward == nil {
+			result.Forward = forward
+		}
+		visited[forward.ToCode] = true
+		result.Code = forward.ToCode
+		result.Hops++
+	}
+	return result, nil
+}
+
+// ApplyToLetter 写信时按转寄规则设置信件的实际投递编码
+func (s *OPCodeForwardService) ApplyToLetter(letter *models.Letter) error {
+	if letter.RecipientOPCode == "" {
+		return nil
+	}
+	resolution, err := s.Resolve(letter.RecipientOPCode, time.Now())
+	if err != nil {
+		return err
+	}
+	if resolution.Forward == nil {
+		return nil
+	}
+	letter.DeliveryOPCode = resolution.Code
+	letter.AddressChanged = resolution.Forward.NotifySenders
+	return nil
+}
+
+// CreateForward 登记转寄，同时改投已寄往旧编码、尚未送达的信件
+func (s *OPCodeForwardService) CreateForward(userID string, req *models.CreateOPCodeForwardRequest) (*models.OPCodeForward, error) {
+	fromCode, _, err := models.NormalizeOPCodeInput(req.FromCode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 原编码 %v", ErrForwardBadCode, err)
+	}
+	var toCode string
+	if s.opcodeService != nil {
+		if toCode, err = s.opcodeService.RequireOPCode(req.ToCode, fromCode); err != nil {
+			return nil, fmt.Errorf("新OP Code无效: %w", err)
+		}
+	} else if toCode, _, err = models.NormalizeOPCodeInput(req.ToCode); err != nil {
+		return nil, fmt.Errorf("%w: 新编码 %v", ErrForwardBadCode, err)
+	}
+	if fromCode == toCode {
+		return nil, ErrForwardSameCode
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(forwardDefaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.Sub(now) > forwardMaxTTL {
+		return nil, ErrForwardExpiry
+	}
+
+	if err := s.checkHolder(userID, fromCode); err != nil {
+		return nil, err
+	}
+
+	// 新编码沿现有规则链不能回到原编码
+	code := toCode
+	for hop := 0; hop < forwardMaxHops; hop++ {
+		next, err := s.effectiveForward(s.db, code, now)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		if next.ToCode == fromCode {
+			return nil, ErrForwardCycle
+		}
+		code = next.ToCode
+	}
+
+	forward := &models.OPCodeForward{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		FromCode:      fromCode,
+		ToCode:        toCode,
+		NotifySenders: req.NotifySenders,
+		Status:        models.OPCodeForwardStatusActive,
+		ExpiresAt:     expiresAt,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.effectiveForward(tx, fromCode, now)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			// 重新登记时替换旧规则；其他用户的规则是前任住户留下的，由当前住户的规则取代
+			if err := tx.Model(existing).Updates(map[string]interface{}{
+				"status":       models.OPCodeForwardStatusCancelled,
+				"cancelled_at": &now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(forward).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.rerouteLetters(forward)
+	if err != nil {
+		return nil, fmt.Errorf("转寄已登记，但改投在途信件失败: %w", err)
+	}
+	forward.RerouteCount = count
+	return forward, nil
+}
+
+// CancelForward 取消转寄，已改投的信件不会改回
+// 登记人之外，原编码的当前住户也可以取消前任住户留下的规则
+func (s *OPCodeForwardService) CancelForward(forwardID, userID string) error {
+	var forward models.OPCodeForward
+	if err := s.db.Where("id = ? AND status = ?", forwardID, models.OPCodeForwardStatusActive).First(&forward).Error; err != nil {
+		return ErrForwardNotFound
+	}
+	if forward.UserID != userID && s.checkHolder(userID, forward.FromCode) != nil {
+		return ErrForwardNotFound
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.OPCodeForward{}).
+		Where("id = ? AND status = ?", forwardID, models.OPCodeForwardStatusActive).
+		Updates(map[string]interface{}{
+			"status":       models.OPCodeForwardStatusCancelled,
+			"cancelled_at": &now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrForwardNotFound
+	}
+	return nil
+}
+
+// ListForwards 用户登记的转寄规则
+func (s *OPCodeForwardService) ListForwards(userID string) ([]models.OPCodeForward, error) {
+	var forwards []models.OPCodeForward
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&forwards).Error
+	return forwards, err
+}
+
+// GetLetterReroutes 信件的转寄记录
+func (s *OPCodeForwardService) GetLetterReroutes(letterID string) ([]models.LetterReroute, error) {
+	var reroutes []models.LetterReroute
+	err := s.db.Where("letter_id = ?", letterID).Order("created_at ASC").Find(&reroutes).Error
+	return reroutes, err
+}
+
+// effectiveForward 编码上当前生效的转寄规则
+func (s *OPCodeForwardService) effectiveForward(db *gorm.DB, code string, at time.Time) (*models.OPCodeForward, error) {
+	var forwards []models.OPCodeForward
+	err := db.Where("from_code = ? AND status = ? AND expires_at > ?", code, models.OPCodeForwardStatusActive, at).
+		Order("created_at DESC").Limit(1).Find(&forwards).Error
+	if err != nil || len(forwards) == 0 {
+		return nil, err
+	}
+	return &forwards[0], nil
+}
+
+// checkHolder 只有当前绑定该编码的用户才能登记，编码转给新住户后前任住户不能再截走其信件
+func (s *OPCodeForwardService) checkHolder(userID, code string) error {
+	var opCode models.OPCode
+	err := s.db.Where("code = ?", code).Limit(1).Find(&opCode).Error
+	if err != nil {
+		return err
+	}
+	if opCode.BindingID == nil || *opCode.BindingID != userID {
+		return ErrForwardNotHolder
+	}
+	return nil
+}
+
+// rerouteLetters 改投寄往原编码、尚未送达的信件
+func (s *OPCodeForwardService) rerouteLetters(forward *models.OPCodeForward) (int, error) {
+	var letters []models.Letter
+	err := s.db.Where("status IN ?", forwardableLetterStatuses).
+		Where("delivery_op_code = ? OR ((delivery_op_code IS NULL OR delivery_op_code = '') AND recipient_op_code = ?)", forward.FromCode, forward.FromCode).
+		Find(&letters).Error
+	if err != nil {
+		return 0, err
+	}
+
+	resolution, err := s.Resolve(forward.ToCode, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range letters {
+		task, err := s.rerouteLetter(&letters[i], forward, resolution.Code)
+		if err != nil {
+			return count, err
+		}
+		count++
+		s.notifyReroute(&letters[i], forward, task)
+	}
+
+	if count > 0 {
+		if err := s.db.Model(forward).UpdateColumn("reroute_count", gorm.Expr("reroute_count + ?", count)).Error; err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// rerouteLetter 改投单封信件；信使任务进行中时关闭原投递段并由同一信使接续新的一段
+func (s *OPCodeForwardService) rerouteLetter(letter *models.Letter, forward *models.OPCodeForward, toCode string) (*models.CourierTask, error) {
+	var newTask *models.CourierTask
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"delivery_op_code": toCode}
+		if forward.NotifySenders {
+			updates["address_changed"] = true
+		}
+		if err := tx.Model(&models.Letter{}).Where("id = ?", letter.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 草稿尚未进入投递，只更新投递编码
+		if letter.Status == models.StatusDraft {
+			return nil
+		}
+
+		reroute := &models.LetterReroute{
+			ID:           uuid.New().String(),
+			LetterID:     letter.ID,
+			ForwardID:    forward.ID,
+			FromCode:     letter.DeliveryTarget(),
+			ToCode:       toCode,
+			LetterStatus: string(letter.Status),
+		}
+
+		var codes []models.LetterCode
+		if err := tx.Where("letter_id = ?", letter.ID).Limit(1).Find(&codes).Error; err != nil {
+			return err
+		}
+		if len(codes) > 0 {
+			reroute.LetterCode = codes[0].Code
+
+			var tasks []models.CourierTask
+			err := tx.Where("letter_code = ? AND status NOT IN ?", reroute.LetterCode,
+				[]string{models.CourierTaskStatusDelivered, models.CourierTaskStatusFailed, models.CourierTaskStatusRerouted}).
+				Order("created_at DESC").Limit(1).Find(&tasks).Error
+			if err != nil {
+				return err
+			}
+			if len(tasks) > 0 {
+				var err error
+				if newTask, err = openRerouteLeg(tx, &tasks[0], reroute.FromCode, toCode); err != nil {
+					return err
+				}
+				reroute.PreviousTaskID = tasks[0].ID
+				reroute.TaskID = newTask.ID
+			}
+		}
+
+		if err := tx.Create(reroute).Error; err != nil {
+			return err
+		}
+		if reroute.LetterCode == "" {
+			return nil
+		}
+		return recordLetterSyncEvent(tx, models.SyncEventLetterRerouted, reroute.LetterCode, "收件人地址变更，已转寄")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("改投信件 %s 失败: %w", letter.ID, err)
+	}
+	return newTask, nil
+}
+
+// openRerouteLeg 关闭原投递段，新建一段从当前位置投递到新编码，进度和信使保持不变
+func openRerouteLeg(tx *gorm.DB, task *models.CourierTask, fromCode, toCode string) (*models.CourierTask, error) {
+	if err := tx.Model(&models.CourierTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+		"status":         models.CourierTaskStatusRerouted,
+		"failure_reason": "收件人地址变更，已转寄",
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	pickup := task.CurrentOPCode
+	if pickup == "" {
+		pickup = task.PickupOPCode
+	}
+	leg := &models.CourierTask{
+		ID:              uuid.New().String(),
+		CourierID:       task.CourierID,
+		LetterCode:      task.LetterCode,
+		Title:           fmt.Sprintf("转寄至 %s", toCode),
+		SenderName:      task.SenderName,
+		SenderPhone:     task.SenderPhone,
+		RecipientHint:   task.RecipientHint,
+		TargetLocation:  toCode,
+		CurrentLocation: task.CurrentLocation,
+		PickupOPCode:    pickup,
+		DeliveryOPCode:  toCode,
+		CurrentOPCode:   task.CurrentOPCode,
+		Priority:        task.Priority,
+		Status:          task.Status,
+		EstimatedTime:   task.EstimatedTime,
+		Reward:          task.Reward,
+		Deadline:        time.Now().Add(4 * time.Hour),
+		Instructions:    fmt.Sprintf("收件人已从 %s 搬离，改投 %s", fromCode, toCode),
+	}
+	if err := tx.Create(leg).Error; err != nil {
+		return nil, err
+	}
+	return leg, nil
+}
+
+// notifyReroute 通知信使新的投递段；收件人同意时告知寄件人地址已变更，不透露新编码
+func (s *OPCodeForwardService) notifyReroute(letter *models.Letter, forward *models.OPCodeForward, task *models.CourierTask) {
+	if s.notificationSvc == nil {
+		return
+	}
+	if task != nil {
+		if err := s.notificationSvc.NotifyUser(task.CourierID, "task_rerouted", map[string]interface{}{
+			"task_id":          task.ID,
+			"letter_code":      task.LetterCode,
+			"delivery_op_code": task.DeliveryOPCode,
+			"message":          "收件人地址已变更，请改投新的地址",
+		}); err != nil {
+			log.Printf("Failed to notify courier %s of reroute: %v", task.CourierID, err)
+		}
+	}
+	if forward.NotifySenders && letter.Status != models.StatusDraft && letter.UserID != forward.UserID {
+		if err := s.notificationSvc.NotifyUser(letter.UserID, "letter_address_changed", map[string]interface{}{
+			"letter_id": letter.ID,
+			"title":     letter.Title,
+			"message":   "收件人的地址已变更，您的信件将转寄到收件人的新地址",
+		}); err != nil {
+			log.Printf("Failed to notify sender %s of reroute: %v", letter.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// OPCodeForwardServiceTestSuite OP Code转寄服务测试套件
+type OPCodeForwardServiceTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	service  *OPCodeForwardService
+	resident *models.User
+	sender   *models.User
+	courier  *models.User
+}
+
+func (suite *OPCodeForwardServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewOPCodeForwardService(db)
+	suite.service.SetOPCodeService(NewOPCodeService(db))
+
+	suite.resident = config.CreateTestUser(db, "forwardresident", models.RoleUser)
+	suite.sender = config.CreateTestUser(db, "forwardsender", models.RoleUser)
+	suite.courier = config.CreateTestUser(db, "forwardcourier", models.RoleCourierLevel1)
+
+	// 住户从 PK5F3D 搬到 PK3D12
+	suite.createOPCode("PK5F3D", &suite.resident.ID)
+	suite.createOPCode("PK3D12", nil)
+	suite.createOPCode("PK2A01", nil)
+}
+
+func (suite *OPCodeForwardServiceTestSuite) createOPCode(code string, bindingID *string) {
+	suite.NoError(suite.db.Create(&models.OPCode{
+		ID:         uuid.New().String(),
+		Code:       code,
+		SchoolCode: code[:2],
+		AreaCode:   code[2:4],
+		PointCode:  code[4:6],
+		PointType:  models.OPCodeTypeDormitory,
+		PointName:  "宿舍" + code,
+		IsActive:   true,
+		BindingID:  bindingID,
+		ManagedBy:  "courier",
+	}).Error)
+}
+
+func (suite *OPCodeForwardServiceTestSuite) forward(userID, from, to string) (*models.OPCodeForward, error) {
+	return suite.service.CreateForward(userID, &models.CreateOPCodeForwardRequest{
+		FromCode: from, ToCode: to, NotifySenders: true,
+	})
+}
+
+func (suite *OPCodeForwardServiceTestSuite) TestCreateForward_RequiresHolder() {
+	_, err := suite.forward(suite.sender.ID, "PK5F3D", "PK3D12")
+	suite.ErrorIs(err, ErrForwardNotHolder)
+
+	// 曾获批该编码但绑定已转给新住户的前任住户不能再登记
+	suite.NoError(suite.db.Create(&models.OPCodeApplication{
+		ID: uuid.New().String(), UserID: suite.sender.ID, SchoolCode: "PK", AreaCode: "2A",
+		PointType: models.OPCodeTypeDormitory, Status: models.OPCodeStatusApproved, AssignedCode: "PK2A01",
+	}).Error)
+	_, err = suite.forward(suite.sender.ID, "PK2A01", "PK3D12")
+	suite.ErrorIs(err, ErrForwardNotHolder)
+
+	_, err = suite.forward(suite.resident.ID, "PK5F3D", "PK5F3D")
+	suite.ErrorIs(err, ErrForwardSameCode)
+}
+
+func (suite *OPCodeForwardServiceTestSuite) TestCreateForward_RejectsCycleAndSupersedes() {
+	_, err := suite.forward(suite.resident.ID, "PK5F3D", "PK3D12")
+	suite.NoError(err)
+
+	// 新住户不能把规则链绕回原编码
+	suite.createOPCode("PK3D13", &suite.sender.ID)
+	suite.NoError(suite.db.Model(&models.OPCode{}).Where("code = ?", "PK3D12").Update("binding_id", suite.sender.ID).Error)
+	_, err = suite.forward(suite.sender.ID, "PK3D12", "PK5F3D")
+	suite.ErrorIs(err, ErrForwardCycle)
+
+	// 编码转给新住户后，新住户的规则取代前任住户留下的规则
+	suite.NoError(suite.db.Model(&models.OPCode{}).Where("code = ?", "PK5F3D").Update("binding_id", suite.sender.ID).Error)
+	superseding, err := suite.forward(suite.sender.ID, "PK5F3D", "PK2A01")
+	suite.NoError(err)
+	resolution, err := suite.service.Resolve("PK5F3D", time.Now())
+	suite.NoError(err)
+	suite.Equal("PK2A01", resolution.Code)
+
+	// 新住户也可以直接取消该编码上的规则，前任住户则不能再取消
+	suite.ErrorIs(suite.service.CancelForward(superseding.ID, suite.resident.ID), ErrForwardNotFound)
+	suite.NoError(suite.service.CancelForward(superseding.ID, suite.sender.ID))
+
+	// 沿规则链解析：PK3D12 再转寄到 PK3D13
+	_, err = suite.forward(suite.sender.ID, "PK3D12", "PK3D13")
+	suite.NoError(err)
+	_, err = suite.forward(suite.sender.ID, "PK5F3D", "PK3D12")
+	suite.NoError(err)
+	resolution, err = suite.service.Resolve("PK5F3D", time.Now())
+	suite.NoError(err)
+	suite.Equal("PK3D13", resolution.Code)
+	suite.Equal(2, resolution.Hops)
+}
+
+func (suite *OPCodeForwardServiceTestSuite) TestCreateDraft_UsesForward() {
+	_, err := suite.forward(suite.resident.ID, "PK5F3D", "PK3D12")
+	suite.NoError(err)
+
+	letterService := NewLetterService(suite.db, config.GetTestConfig())
+	letterService.SetOPCodeForwardService(suite.service)
+	letter, err := letterService.CreateDraft(suite.sender.ID, &models.CreateLetterRequest{
+		Content: "你好", Style: models.StyleClassic, RecipientOPCode: "PK5F3D",
+	})
+	suite.NoError(err)
+	// 寄件人看到的仍是原编码，新编码不对外暴露
+	suite.Equal("PK5F3D", letter.RecipientOPCode)
+	suite.Equal("PK3D12", letter.DeliveryTarget())
+	suite.True(letter.AddressChanged)
+}
+
+func (suite *OPCodeForwardServiceTestSuite) TestCreateForward_ReroutesInTransitLetter() {
+	letter := config.CreateTestLetter(suite.db, suite.sender.ID)
+	suite.NoError(suite.db.Model(letter).Updates(map[string]interface{}{
+		"status": models.StatusInTransit, "recipient_op_code": "PK5F3D",
+	}).Error)
+	suite.NoError(suite.db.Create(&models.LetterCode{ID: "code-fwd", LetterID: letter.ID, Code: "OPFWD001", Status: models.BarcodeStatusInTransit}).Error)
+	suite.NoError(suite.db.Create(&models.CourierTask{
+		ID: "task-fwd", CourierID: suite.courier.ID, LetterCode: "OPFWD001", Title: "测试信件",
+		SenderName: "寄件人", TargetLocation: "5号楼", PickupOPCode: "PK2A01", DeliveryOPCode: "PK5F3D",
+		CurrentOPCode: "PK5F01", Status: models.CourierTaskStatusInTransit, Deadline: time.Now().Add(time.Hour),
+	}).Error)
+
+	forward, err := suite.forward(suite.resident.ID, "PK5F3D", "PK3D12")
+	suite.NoError(err)
+	suite.Equal(1, forward.RerouteCount)
+
+	var previous models.CourierTask
+	suite.NoError(suite.db.First(&previous, "id = ?", "task-fwd").Error)
+	suite.Equal(models.CourierTaskStatusRerouted, previous.Status)
+
+	var reroutes []models.LetterReroute
+	suite.NoError(suite.db.Where("letter_id = ?", letter.ID).Find(&reroutes).Error)
+	suite.Require().Len(reroutes, 1)
+	suite.Equal("task-fwd", reroutes[0].PreviousTaskID)
+
+	// 新投递段由同一信使从当前位置接续，进度不变
+	var leg models.CourierTask
+	suite.NoError(suite.db.First(&leg, "id = ?", reroutes[0].TaskID).Error)
+	suite.Equal(suite.courier.ID, leg.CourierID)
+	suite.Equal("PK5F01", leg.PickupOPCode)
+	suite.Equal("PK3D12", leg.DeliveryOPCode)
+	suite.Equal(models.CourierTaskStatusInTransit, leg.Status)
+
+	var updated models.Letter
+	suite.NoError(suite.db.First(&updated, "id = ?", letter.ID).Error)
+	suite.Equal("PK5F3D", updated.RecipientOPCode)
+	suite.Equal("PK3D12", updated.DeliveryOPCode)
+
+	var outbox []models.LetterOutboxEvent
+	suite.NoError(suite.db.Where("letter_code = ?", "OPFWD001").Find(&outbox).Error)
+	suite.Require().Len(outbox, 1)
+	var event models.SyncEvent
+	suite.NoError(json.Unmarshal([]byte(outbox[0].Payload), &event))
+	suite.Equal(models.SyncEventLetterRerouted, event.EventType)
+	suite.Equal("PK3D12", event.DeliveryOPCode)
+}
+
+func TestOPCodeForwardServiceSuite(t *testing.T) {
+	suite.Run(t, new(OPCodeForwardServiceTestSuite))
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/geo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	opCodeNearestDefaultRadius = 1000.0 // 米
+	opCodeNearestDefaultLimit  = 10
+	geoImportMinGeofence       = 10
+	geoImportMaxGeofence       = 2000
+)
+
+// errGeoImportDryRun 试运行时回滚导入事务
+var errGeoImportDryRun = errors.New("geojson import dry run")
+
+// ImportGeoJSON 导入校园地图：片区多边形写入边界，投递点写入坐标
+// 先处理片区再处理投递点，投递点落在所属片区边界之外时跳过；dryRun 只校验不落库
+func (s *OPCodeService) ImportGeoJSON(data []byte, dryRun bool) (*models.OPCodeGeoImportResult, error) {
+	collection, err := geo.ParseFeatureCollection(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.OPCodeGeoImportResult{DryRun: dryRun, Issues: []models.OPCodeGeoImportIssue{}}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var points []int
+		for i := range collection.Features {
+			feature := &collection.Features[i]
+			if feature.Geometry == nil {
+				result.Issues = append(result.Issues, models.OPCodeGeoImportIssue{Feature: i, Reason: "缺少几何数据"})
+				continue
+			}
+			switch feature.Geometry.Type {
+			case geo.TypePoint:
+				points = append(points, i)
+			case geo.TypePolygon, geo.TypeMultiPolygon:
+				ref, reason, err := s.importGeoArea(tx, feature, result)
+				if err != nil {
+					return err
+				}
+				if reason != "" {
+					result.Issues = append(result.Issues, models.OPCodeGeoImportIssue{Feature: i, Ref: ref, Reason: reason})
+				}
+			default:
+				result.Issues = append(result.Issues, models.OPCodeGeoImportIssue{
+					Feature: i, Reason: fmt.Sprintf("不支持的几何类型 %s", feature.Geometry.Type),
+				})
+			}
+		}
+
+		for _, i := range points {
+			ref, reason, err := s.importGeoPoint(tx, &collection.Features[i], result)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				result.Issues = append(result.Issues, models.OPCodeGeoImportIssue{Feature: i, Ref: ref, Reason: reason})
+			}
+		}
+
+		if dryRun {
+			return errGeoImportDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGeoImportDryRun) {
+		return nil, fmt.Errorf("导入校园地图失败: %w", err)
+	}
+	return result, nil
+}
+
+// importGeoArea 导入片区边界，片区不存在时按 name 新建；返回跳过原因
+func (s *OPCodeService) importGeoArea(tx *gorm.DB, feature *geo.Feature, result *models.OPCodeGeoImportResult) (string, string, error) {
+	prefix := strings.ToUpper(feature.StringProperty(models.GeoPropertyArea))
+	if prefix == "" {
+		prefix = strings.ToUpper(feature.StringProperty(models.GeoPropertySchoolCode) + feature.StringProperty(models.GeoPropertyAreaCode))
+	}
+	if len(prefix) != 4 || !isAlphanumeric(prefix) {
+		return prefix, "片区前缀须为4位字母或数字", nil
+	}
+
+	boundary, err := feature.Geometry.MultiPolygon()
+	if err != nil {
+		return prefix, err.Error(), nil
+	}
+	encoded, err := boundary.Marshal()
+	if err != nil {
+		return prefix, err.Error(), nil
+	}
+	box := boundary.BBox()
+	center := box.Center()
+	updates := map[string]interface{}{
+		"boundary":   encoded,
+		"min_lat":    box.MinLat,
+		"min_lng":    box.MinLng,
+		"max_lat":    box.MaxLat,
+		"max_lng":    box.MaxLng,
+		"center_lat": center.Lat,
+		"center_lng": center.Lng,
+	}
+	name := strings.TrimSpace(feature.StringProperty(models.GeoPropertyName))
+
+	var areas []models.OPCodeArea
+	if err := tx.Where("school_code = ? AND area_code = ?", prefix[:2], prefix[2:]).Limit(1).Find(&areas).Error; err != nil {
+		return prefix, "", err
+	}
+	if len(areas) > 0 {
+		if name != "" {
+			updates["area_name"] = name
+		}
+		if err := tx.Model(&areas[0]).Updates(updates).Error; err != nil {
+			return prefix, "", err
+		}
+		result.AreasUpdated++
+		return prefix, "", nil
+	}
+
+	if name == "" {
+		return prefix, "新建片区须提供 name", nil
+	}
+	var schools int64
+	if err := tx.Model(&models.OPCodeSchool{}).Where("school_code = ?", prefix[:2]).Count(&schools).Error; err != nil {
+		return prefix, "", err
+	}
+	if schools == 0 {
+		return prefix, fmt.Sprintf("学校代码 %s 不存在", prefix[:2]), nil
+	}
+	area := &models.OPCodeArea{
+		ID:          uuid.New().String(),
+		SchoolCode:  prefix[:2],
+		AreaCode:    prefix[2:],
+		AreaName:    name,
+		IsActive:    true,
+		ManagedBy:   "system",
+		UniqueIndex: prefix,
+	}
+	if err := tx.Create(area).Error; err != nil {
+		return prefix, "", err
+	}
+	if err := tx.Model(area).Updates(updates).Error; err != nil {
+		return prefix, "", err
+	}
+	result.AreasCreated++
+	return prefix, "", nil
+}
+
+// importGeoPoint 导入投递点坐标，编码须已存在；返回跳过原因
+func (s *OPCodeService) importGeoPoint(tx *gorm.DB, feature *geo.Feature, result *models.OPCodeGeoImportResult) (string, string, error) {
+	ref := feature.StringProperty(models.GeoPropertyOPCode)
+	code, _, err := models.NormalizeOPCodeInput(ref)
+	if err != nil {
+		return ref, err.Error(), nil
+	}
+	pt, err := feature.Geometry.Point()
+	if err != nil {
+		return code, err.Error(), nil
+	}
+
+	var opCodes []models.OPCode
+	if err := tx.Where("code = ?", code).Limit(1).Find(&opCodes).Error; err != nil {
+		return code, "", err
+	}
+	if len(opCodes) == 0 {
+		return code, "OP Code不存在", nil
+	}
+
+	var areas []models.OPCodeArea
+	if err := tx.Where("school_code = ? AND area_code = ?", code[:2], code[2:4]).Limit(1).Find(&areas).Error; err != nil {
+		return code, "", err
+	}
+	if len(areas) > 0 && areas[0].Boundary != "" {
+		boundary, err := geo.UnmarshalMultiPolygon(areas[0].Boundary)
+		if err == nil && !boundary.Contains(pt) {
+			return code, fmt.Sprintf("坐标不在片区 %s 边界内", code[:4]), nil
+		}
+	}
+
+	updates := map[string]interface{}{"latitude": pt.Lat, "longitude": pt.Lng}
+	if radius, ok := feature.NumberProperty(models.GeoPropertyGeofenceRadius); ok {
+		if radius < geoImportMinGeofence || radius > geoImportMaxGeofence {
+			return code, fmt.Sprintf("围栏半径须在 %d-%d 米之间", geoImportMinGeofence, geoImportMaxGeofence), nil
+		}
+		updates["geofence_radius"] = int(radius)
+	}
+	if err := tx.Model(&opCodes[0]).Updates(updates).Error; err != nil {
+		return code, "", err
+	}
+	result.PointsUpdated++
+	return code, "", nil
+}
+
+// ExportGeoJSON 导出校园地图，schoolCode 为空时导出全部学校
+// publicOnly 为 true 时只包含公开投递点，供信使服务等外部拉取
+func (s *OPCodeService) ExportGeoJSON(schoolCode string, publicOnly bool) (*geo.FeatureCollection, error) {
+	collection := geo.NewFeatureCollection()
+
+	areaQuery := s.db.Where("is_active = ? AND boundary IS NOT NULL AND boundary <> ''", true)
+	pointQuery := s.db.Where("is_active = ? AND latitude IS NOT NULL AND longitude IS NOT NULL", true)
+	if schoolCode != "" {
+		areaQuery = areaQuery.Where("school_code = ?", strings.ToUpper(schoolCode))
+		pointQuery = pointQuery.Where("school_code = ?", strings.ToUpper(schoolCode))
+	}
+	if publicOnly {
+		pointQuery = pointQuery.Where("is_public = ?", true)
+	}
+
+	var areas []models.OPCodeArea
+	if err := areaQuery.Order("school_code, area_code").Find(&areas).Error; err != nil {
+		return nil, err
+	}
+	for _, area := range areas {
+		boundary, err := geo.UnmarshalMultiPolygon(area.Boundary)
+		if err != nil {
+			continue
+		}
+		collection.Features = append(collection.Features, geo.Feature{
+			Type:     geo.TypeFeature,
+			ID:       area.SchoolCode + area.AreaCode,
+			Geometry: boundary.Geometry(),
+			Properties: map[string]interface{}{
+				models.GeoPropertyKind:       "area",
+				models.GeoPropertyArea:       area.SchoolCode + area.AreaCode,
+				models.GeoPropertySchoolCode: area.SchoolCode,
+				models.GeoPropertyAreaCode:   area.AreaCode,
+				models.GeoPropertyName:       area.AreaName,
+			},
+		})
+	}
+
+	var points []models.OPCode
+	if err := pointQuery.Order("code").Find(&points).Error; err != nil {
+		return nil, err
+	}
+	for _, point := range points {
+		properties := map[string]interface{}{
+			models.GeoPropertyKind:      "point",
+			models.GeoPropertyOPCode:    point.Code,
+			models.GeoPropertyPointType: point.PointType,
+		}
+		if point.IsPublic || !publicOnly {
+			properties[models.GeoPropertyName] = point.PointName
+		}
+		if point.GeofenceRadius > 0 {
+			properties[models.GeoPropertyGeofenceRadius] = point.GeofenceRadius
+		}
+		collection.Features = append(collection.Features, geo.Feature{
+			Type:       geo.TypeFeature,
+			ID:         point.Code,
+			Geometry:   geo.PointGeometry(geo.Point{Lat: *point.Latitude, Lng: *point.Longitude}),
+			Properties: properties,
+		})
+	}
+
+	return collection, nil
+}
+
+// ResolveLocation 由GPS坐标判断所在片区，多个片区重叠时取范围较小的；不在任何片区内时返回 nil
+func (s *OPCodeService) ResolveLocation(lat, lng float64) (*models.OPCodeAreaLocation, error) {
+	pt := geo.Point{Lat: lat, Lng: lng}
+
+	var areas []models.OPCodeArea
+	err := s.db.Where("is_active = ? AND boundary IS NOT NULL AND boundary <> ''", true).
+		Where("min_lat <= ? AND max_lat >= ? AND min_lng <= ? AND max_lng >= ?", lat, lat, lng, lng).
+		Find(&areas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.OPCodeArea
+	bestSpan := 0.0
+	for i := range areas {
+		boundary, err := geo.UnmarshalMultiPolygon(areas[i].Boundary)
+		if err != nil || !boundary.Contains(pt) {
+			continue
+		}
+		span := boundary.BBox().Span()
+		if best == nil || span < bestSpan {
+			best, bestSpan = &areas[i], span
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return &models.OPCodeAreaLocation{
+		Prefix:     best.SchoolCode + best.AreaCode,
+		SchoolCode: best.SchoolCode,
+		AreaCode:   best.AreaCode,
+		AreaName:   best.AreaName,
+	}, nil
+}
+
+// NearestOPCodes 查询半径内最近的投递点，按距离升序
+func (s *OPCodeService) NearestOPCodes(req *models.OPCodeNearestRequest) ([]models.OPCodeNearby, error) {
+	origin := geo.Point{Lat: *req.Latitude, Lng: *req.Longitude}
+	radius := req.Radius
+	if radius <= 0 {
+		radius = opCodeNearestDefaultRadius
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = opCodeNearestDefaultLimit
+	}
+
+	box := geo.BBoxAround(origin, radius)
+	query := s.db.Where("is_active = ? AND latitude IS NOT NULL AND longitude IS NOT NULL", true).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", box.MinLat, box.MaxLat, box.MinLng, box.MaxLng)
+	if req.PointType != "" {
+		query = query.Where("point_type = ?", req.PointType)
+	}
+	if req.SchoolCode != "" {
+		query = query.Where("school_code = ?", strings.ToUpper(req.SchoolCode))
+	}
+	var candidates []models.OPCode
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	nearby := make([]models.OPCodeNearby, 0, len(candidates))
+	for _, candidate := range candidates {
+		pt := geo.Point{Lat: *candidate.Latitude, Lng: *candidate.Longitude}
+		distance := geo.DistanceMeters(origin, pt)
+		if distance > radius {
+			continue
+		}
+		item := models.OPCodeNearby{
+			Code:           candidate.Code,
+			PointType:      candidate.PointType,
+			Latitude:       pt.Lat,
+			Longitude:      pt.Lng,
+			DistanceMeters: distance,
+		}
+		if candidate.IsPublic {
+			item.PointName = candidate.PointName
+		}
+		nearby = append(nearby, item)
+	}
+
+	sort.Slice(nearby, func(i, j int) bool {
+		if nearby[i].DistanceMeters != nearby[j].DistanceMeters {
+			return nearby[i].DistanceMeters < nearby[j].DistanceMeters
+		}
+		return nearby[i].Code < nearby[j].Code
+	})
+	if len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	return nearby, nil
+}
+
+func isAlphanumeric(value string) bool {
+	for _, ch := range value {
+		if !(ch >= '0' && ch <= '9' || ch >= 'A' && ch <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
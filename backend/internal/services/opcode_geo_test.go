@@ -0,0 +1,185 @@
+package services
+
+import (
+	"testing"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/geo"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// 两个相邻片区：PK5F 为带空洞的方形，PK3D 在其东侧；PK9Z 不存在，需要新建
+const campusGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"area": "PK5F"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[116.300, 39.990], [116.305, 39.990], [116.305, 39.995], [116.300, 39.995], [116.300, 39.990]],
+       [[116.302, 39.992], [116.303, 39.992], [116.303, 39.993], [116.302, 39.993], [116.302, 39.992]]
+     ]}},
+    {"type": "Feature", "properties": {"school_code": "PK", "area_code": "3D", "name": "东区"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[116.305, 39.990], [116.310, 39.990], [116.310, 39.995], [116.305, 39.995], [116.305, 39.990]]
+     ]}},
+    {"type": "Feature", "properties": {"area": "PK9Z", "name": "新建片区"},
+     "geometry": {"type": "MultiPolygon", "coordinates": [
+       [[[116.320, 39.990], [116.321, 39.990], [116.321, 39.991], [116.320, 39.990]]],
+       [[[116.330, 39.990], [116.331, 39.990], [116.331, 39.991], [116.330, 39.990]]]
+     ]}},
+    {"type": "Feature", "properties": {"op_code": "PK5F3D", "geofence_radius": 80},
+     "geometry": {"type": "Point", "coordinates": [116.301, 39.991]}},
+    {"type": "Feature", "properties": {"op_code": "PK5F3E"},
+     "geometry": {"type": "Point", "coordinates": [116.308, 39.991]}},
+    {"type": "Feature", "properties": {"op_code": "PK3D01"},
+     "geometry": {"type": "Point", "coordinates": [116.306, 39.991]}},
+    {"type": "Feature", "properties": {"op_code": "PK3D99"},
+     "geometry": {"type": "Point", "coordinates": [116.306, 39.991]}},
+    {"type": "Feature", "properties": {"area": "PK7A", "name": "未闭合"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[116.300, 39.990], [116.305, 39.990], [116.305, 39.995], [116.300, 39.995]]
+     ]}}
+  ]
+}`
+
+// OPCodeGeoTestSuite 校园地图导入与空间查询测试套件
+type OPCodeGeoTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *OPCodeService
+}
+
+func (suite *OPCodeGeoTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewOPCodeService(db)
+
+	suite.NoError(db.Create(&models.OPCodeSchool{ID: uuid.New().String(), SchoolCode: "PK", SchoolName: "北京大学", IsActive: true}).Error)
+	for _, area := range []string{"5F", "3D"} {
+		suite.NoError(db.Create(&models.OPCodeArea{
+			ID: uuid.New().String(), SchoolCode: "PK", AreaCode: area, AreaName: "片区" + area, IsActive: true, UniqueIndex: "PK" + area,
+		}).Error)
+	}
+	for _, code := range []string{"PK5F3D", "PK5F3E", "PK3D01"} {
+		suite.NoError(db.Create(&models.OPCode{
+			ID: uuid.New().String(), Code: code, SchoolCode: code[:2], AreaCode: code[2:4], PointCode: code[4:6],
+			PointType: models.OPCodeTypeDormitory, PointName: "宿舍" + code, IsActive: true, IsPublic: code != "PK3D01", ManagedBy: "courier",
+		}).Error)
+	}
+}
+
+func (suite *OPCodeGeoTestSuite) TestImport_DryRunWritesNothing() {
+	result, err := suite.service.ImportGeoJSON([]byte(campusGeoJSON), true)
+	suite.NoError(err)
+	suite.True(result.DryRun)
+	suite.Equal(2, result.AreasUpdated)
+	suite.Equal(1, result.AreasCreated)
+
+	var count int64
+	suite.NoError(suite.db.Model(&models.OPCodeArea{}).Where("boundary <> ''").Count(&count).Error)
+	suite.Zero(count)
+}
+
+func (suite *OPCodeGeoTestSuite) TestImport_AreasAndPoints() {
+	result, err := suite.service.ImportGeoJSON([]byte(campusGeoJSON), false)
+	suite.NoError(err)
+	suite.Equal(1, result.AreasCreated)
+	suite.Equal(2, result.AreasUpdated)
+	suite.Equal(2, result.PointsUpdated)
+
+	// 不在所属片区内的点、不存在的编码、未闭合的环都会被跳过
+	reasons := map[string]string{}
+	for _, issue := range result.Issues {
+		reasons[issue.Ref] = issue.Reason
+	}
+	suite.Len(reasons, 3)
+	suite.Contains(reasons["PK5F3E"], "边界")
+	suite.Contains(reasons["PK3D99"], "不存在")
+	suite.Contains(reasons["PK7A"], "闭合")
+
+	var point models.OPCode
+	suite.NoError(suite.db.First(&point, "code = ?", "PK5F3D").Error)
+	suite.Require().NotNil(point.Latitude)
+	suite.InDelta(39.991, *point.Latitude, 1e-9)
+	suite.Equal(80, point.GeofenceRadius)
+
+	var area models.OPCodeArea
+	suite.NoError(suite.db.First(&area, "school_code = ? AND area_code = ?", "PK", "3D").Error)
+	suite.Equal("东区", area.AreaName)
+	suite.Require().NotNil(area.CenterLat)
+	suite.InDelta(39.9925, *area.CenterLat, 1e-9)
+}
+
+func (suite *OPCodeGeoTestSuite) TestResolveLocation() {
+	_, err := suite.service.ImportGeoJSON([]byte(campusGeoJSON), false)
+	suite.NoError(err)
+
+	location, err := suite.service.ResolveLocation(39.991, 116.301)
+	suite.NoError(err)
+	suite.Require().NotNil(location)
+	suite.Equal("PK5F", location.Prefix)
+	suite.Equal("片区5F", location.AreaName)
+
+	location, err = suite.service.ResolveLocation(39.991, 116.308)
+	suite.NoError(err)
+	suite.Require().NotNil(location)
+	suite.Equal("PK3D", location.Prefix)
+
+	// 空洞内部和片区之外都无法解析
+	location, err = suite.service.ResolveLocation(39.9925, 116.3025)
+	suite.NoError(err)
+	suite.Nil(location)
+	location, err = suite.service.ResolveLocation(40.1, 116.301)
+	suite.NoError(err)
+	suite.Nil(location)
+
+	// 多边形之间的空白处不属于 MultiPolygon
+	location, err = suite.service.ResolveLocation(39.9901, 116.3207)
+	suite.NoError(err)
+	suite.Require().NotNil(location)
+	suite.Equal("PK9Z", location.Prefix)
+	location, err = suite.service.ResolveLocation(39.9901, 116.325)
+	suite.NoError(err)
+	suite.Nil(location)
+}
+
+func (suite *OPCodeGeoTestSuite) TestNearestAndExport() {
+	_, err := suite.service.ImportGeoJSON([]byte(campusGeoJSON), false)
+	suite.NoError(err)
+
+	lat, lng := 39.991, 116.3055
+	nearby, err := suite.service.NearestOPCodes(&models.OPCodeNearestRequest{Latitude: &lat, Longitude: &lng, Radius: 500})
+	suite.NoError(err)
+	suite.Require().Len(nearby, 2)
+	suite.Equal("PK3D01", nearby[0].Code)
+	suite.Empty(nearby[0].PointName, "非公开点位不返回名称")
+	suite.Equal("PK5F3D", nearby[1].Code)
+	suite.Less(nearby[0].DistanceMeters, nearby[1].DistanceMeters)
+
+	nearby, err = suite.service.NearestOPCodes(&models.OPCodeNearestRequest{Latitude: &lat, Longitude: &lng, Radius: 100})
+	suite.NoError(err)
+	suite.Len(nearby, 1)
+
+	// 公开导出不含非公开点位，导出结果可以再次导入
+	collection, err := suite.service.ExportGeoJSON("PK", true)
+	suite.NoError(err)
+	kinds := map[string]int{}
+	for _, feature := range collection.Features {
+		kinds[feature.StringProperty(models.GeoPropertyKind)]++
+		suite.NotEqual("PK3D01", feature.StringProperty(models.GeoPropertyOPCode))
+	}
+	suite.Equal(3, kinds["area"])
+	suite.Equal(1, kinds["point"])
+
+	boundary, err := collection.Features[0].Geometry.MultiPolygon()
+	suite.NoError(err)
+	suite.True(boundary.Contains(geo.Point{Lat: 39.991, Lng: 116.306}))
+}
+
+func TestOPCodeGeoSuite(t *testing.T) {
+	suite.Run(t, new(OPCodeGeoTestSuite))
+}
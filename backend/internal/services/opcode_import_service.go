@@ -0,0 +1,826 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/sheet"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOPCodeImportForbidden  = errors.New("无权批量导入OP Code")
+	ErrOPCodeImportNotFound   = errors.New("导入批次不存在")
+	ErrOPCodeImportNotApplied = errors.New("只有已写入的导入批次可以回滚")
+	ErrOPCodeImportInUse      = errors.New("导入的编码已被使用，不能回滚")
+	ErrOPCodeImportBadSheet   = errors.New("表格格式不正确")
+)
+
+const (
+	opCodeImportMaxRows   = 20000
+	opCodeImportBatchSize = 500
+)
+
+// opCodeImportColumns 表头及可识别的中文别名，导出时使用英文列名
+var opCodeImportColumns = []struct {
+	key     string
+	aliases []string
+}{
+	{"school_code", []string{"学校代码"}},
+	{"school_name", []string{"学校名称"}},
+	{"city", []string{"城市"}},
+	{"province", []string{"省份"}},
+	{"area_code", []string{"片区代码"}},
+	{"area_name", []string{"片区名称"}},
+	{"point_code", []string{"点位代码"}},
+	{"point_type", []string{"点位类型"}},
+	{"point_name", []string{"点位名称"}},
+	{"full_address", []string{"详细地址"}},
+	{"is_public", []string{"是否公开"}},
+}
+
+// 保留的片区/点位代码，不通过导入签发
+var reservedOPCodeSegments = map[string]string{
+	"00": "00 为学校和片区集散点保留",
+}
+
+var importablePointTypes = map[string]bool{
+	models.OPCodeTypeDormitory: true,
+	models.OPCodeTypeShop:      true,
+	models.OPCodeTypeBox:       true,
+	models.OPCodeTypeClub:      true,
+}
+
+// OPCodeImportService 学校、片区、点位批量导入导出
+// 每行描述一个层级：只有学校代码为学校行，有片区代码为片区行，有点位代码为点位行
+type OPCodeImportService struct {
+	db *gorm.DB
+}
+
+// NewOPCodeImportService 创建批量导入服务
+func NewOPCodeImportService(db *gorm.DB) *OPCodeImportService {
+	return &OPCodeImportService{db: db}
+}
+
+// opCodeImportScope 操作人可导入的范围：平台管理员不限，三、四级信使限于所管理的学校
+type opCodeImportScope struct {
+	all    bool
+	prefix string
+}
+
+func (sc *opCodeImportScope) allows(code string) bool {
+	return sc.all || strings.HasPrefix(code, sc.prefix)
+}
+
+func (s *OPCodeImportService) scopeFor(operatorID string) (*opCodeImportScope, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", operatorID).Error; err != nil {
+		return nil, ErrOPCodeImportForbidden
+	}
+	switch user.Role {
+	case models.RolePlatformAdmin, models.RoleSuperAdmin:
+		return &opCodeImportScope{all: true}, nil
+	case models.RoleCourierLevel3, models.RoleCourierLevel4:
+		var courier models.Courier
+		if err := s.db.Where("user_id = ? AND status = ?", operatorID, "approved").First(&courier).Error; err != nil {
+			return nil, ErrOPCodeImportForbidden
+		}
+		prefix := strings.ToUpper(strings.ReplaceAll(courier.ManagedOPCodePrefix, "*", ""))
+		if len(prefix) < 2 {
+			return nil, ErrOPCodeImportForbidden
+		}
+		return &opCodeImportScope{prefix: prefix[:2]}, nil
+	}
+	return nil, ErrOPCodeImportForbidden
+}
+
+// opCodeImportRow 表格中的一行
+type opCodeImportRow struct {
+	row         int
+	schoolCode  string
+	schoolName  string
+	city        string
+	province    string
+	areaCode    string
+	areaName    string
+	pointCode   string
+	pointType   string
+	pointName   string
+	fullAddress string
+	isPublic    bool
+}
+
+// opCodeImportPlan 校验结果与待创建的记录
+type opCodeImportPlan struct {
+	schools []*models.OPCodeSchool
+	areas   []*models.OPCodeArea
+	points  []*models.OPCode
+	issues  []models.OPCodeImportIssue
+}
+
+func (p *opCodeImportPlan) add(row int, code, severity, issueType, format string, args ...interface{}) {
+	p.issues = append(p.issues, models.OPCodeImportIssue{
+		Row: row, Code: code, Severity: severity, Type: issueType, Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (p *opCodeImportPlan) counts() (int, int) {
+	errorCount, warningCount := 0, 0
+	for _, issue := range p.issues {
+		if issue.Severity == models.OPCodeImportSeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	return errorCount, warningCount
+}
+
+// Import 导入表格；dryRun 只生成校验报告，有错误时整批不写入
+func (s *OPCodeImportService) Import(operatorID, fileName string, data []byte, dryRun bool) (*models.OPCodeImport, error) {
+	scope, err := s.scopeFor(operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	format := sheet.DetectFormat(fileName, data)
+	table, err := sheet.Read(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOPCodeImportBadSheet, err)
+	}
+	rows, err := parseOPCodeImportRows(table)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.plan(rows, scope, operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.OPCodeImport{
+		ID:         uuid.New().String(),
+		OperatorID: operatorID,
+		FileName:   fileName,
+		Format:     format,
+		TotalRows:  len(rows),
+		Issues:     plan.issues,
+	}
+	record.ErrorCount, record.WarningCount = plan.counts()
+	report, err := json.Marshal(plan.issues)
+	if err != nil {
+		return nil, err
+	}
+	record.Report = string(report)
+
+	switch {
+	case record.ErrorCount > 0:
+		record.Status = models.OPCodeImportStatusRejected
+	case dryRun:
+		record.Status = models.OPCodeImportStatusDryRun
+	default:
+		record.Status = models.OPCodeImportStatusApplied
+	}
+	if record.Status != models.OPCodeImportStatusRejected {
+		record.SchoolsCreated, record.AreasCreated, record.PointsCreated = len(plan.schools), len(plan.areas), len(plan.points)
+	}
+	if record.Status != models.OPCodeImportStatusApplied {
+		if err := s.db.Create(record).Error; err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+
+	now := time.Now()
+	record.AppliedAt = &now
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return applyOPCodeImportPlan(tx, record.ID, plan)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("写入导入数据失败: %w", err)
+	}
+	return record, nil
+}
+
+// parseOPCodeImportRows 按表头解析数据行，空行跳过
+func parseOPCodeImportRows(table [][]string) ([]opCodeImportRow, error) {
+	if len(table) < 2 {
+		return nil, fmt.Errorf("%w: 没有数据行", ErrOPCodeImportBadSheet)
+	}
+	if len(table)-1 > opCodeImportMaxRows {
+		return nil, fmt.Errorf("%w: 单次最多导入 %d 行", ErrOPCodeImportBadSheet, opCodeImportMaxRows)
+	}
+
+	columns := make(map[string]int)
+	for i, header := range table[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		for _, column := range opCodeImportColumns {
+			if header == column.key {
+				columns[column.key] = i
+			}
+			for _, alias := range column.aliases {
+				if header == alias {
+					columns[column.key] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["school_code"]; !ok {
+		return nil, fmt.Errorf("%w: 表头缺少 school_code 列", ErrOPCodeImportBadSheet)
+	}
+
+	var rows []opCodeImportRow
+	for i, values := range table[1:] {
+		get := func(key string) string {
+			if index, ok := columns[key]; ok && index < len(values) {
+				return strings.TrimSpace(values[index])
+			}
+			return ""
+		}
+		row := opCodeImportRow{
+			row:         i + 2,
+			schoolCode:  strings.ToUpper(get("school_code")),
+			schoolName:  get("school_name"),
+			city:        get("city"),
+			province:    get("province"),
+			areaCode:    padOPCodeSegment(get("area_code")),
+			areaName:    get("area_name"),
+			pointCode:   padOPCodeSegment(get("point_code")),
+			pointType:   strings.ToLower(get("point_type")),
+			pointName:   get("point_name"),
+			fullAddress: get("full_address"),
+			isPublic:    parseImportBool(get("is_public")),
+		}
+		if row.schoolCode == "" && row.areaCode == "" && row.pointCode == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: 没有数据行", ErrOPCodeImportBadSheet)
+	}
+	return rows, nil
+}
+
+// padOPCodeSegment 大写并补齐前导零，Excel 会把 01 存成数字 1
+func padOPCodeSegment(value string) string {
+	value = strings.ToUpper(value)
+	if len(value) == 1 && value[0] >= '0' && value[0] <= '9' {
+		return "0" + value
+	}
+	return value
+}
+
+func parseImportBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "y", "是":
+		return true
+	}
+	return false
+}
+
+// plan 校验每一行并与已有数据比对，生成报告和待创建记录
+func (s *OPCodeImportService) plan(rows []opCodeImportRow, scope *opCodeImportScope, operatorID string) (*opCodeImportPlan, error) {
+	plan := &opCodeImportPlan{issues: []models.OPCodeImportIssue{}}
+	schools := make(map[string]*opCodeImportRow)
+	areas := make(map[string]*opCodeImportRow)
+	points := make(map[string]*opCodeImportRow)
+	var schoolOrder, areaOrder, pointOrder []string
+
+	for i := range rows {
+		row := &rows[i]
+		code := row.schoolCode + row.areaCode + row.pointCode
+		if len(row.schoolCode) != 2 || !isAlphanumeric(row.schoolCode) {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueInvalid, "学校代码须为2位字母或数字")
+			continue
+		}
+		if row.areaCode != "" && (len(row.areaCode) != 2 || !isAlphanumeric(row.areaCode)) {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueInvalid, "片区代码须为2位字母或数字")
+			continue
+		}
+		if row.pointCode != "" {
+			if row.areaCode == "" {
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict, "点位缺少所属片区代码")
+				continue
+			}
+			if len(row.pointCode) != 2 || !isAlphanumeric(row.pointCode) {
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueInvalid, "点位代码须为2位字母或数字")
+				continue
+			}
+			if !importablePointTypes[row.pointType] {
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueInvalid, "点位类型须为 dormitory、shop、box 或 club")
+				continue
+			}
+		}
+		if !scope.allows(code) {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssuePermission, "超出管理范围 %s", scope.prefix)
+			continue
+		}
+		if reason, ok := reservedOPCodeSegments[row.areaCode]; ok {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueReserved, "%s", reason)
+			continue
+		}
+		if reason, ok := reservedOPCodeSegments[row.pointCode]; ok {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueReserved, "%s", reason)
+			continue
+		}
+
+		// 同一学校、片区在文件中多次出现时名称必须一致，以第一个带名称的行为准
+		if first, ok := schools[row.schoolCode]; !ok {
+			schools[row.schoolCode] = row
+			schoolOrder = append(schoolOrder, row.schoolCode)
+		} else if row.schoolName != "" {
+			if first.schoolName == "" {
+				schools[row.schoolCode] = row
+			} else if first.schoolName != row.schoolName {
+				plan.add(row.row, row.schoolCode, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict,
+					"学校名称与第%d行不一致：%s / %s", first.row, first.schoolName, row.schoolName)
+			}
+		}
+		if row.areaCode == "" {
+			continue
+		}
+		prefix := row.schoolCode + row.areaCode
+		if first, ok := areas[prefix]; !ok {
+			areas[prefix] = row
+			areaOrder = append(areaOrder, prefix)
+		} else if row.areaName != "" {
+			if first.areaName == "" {
+				areas[prefix] = row
+			} else if first.areaName != row.areaName {
+				plan.add(row.row, prefix, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict,
+					"片区名称与第%d行不一致：%s / %s", first.row, first.areaName, row.areaName)
+			}
+		}
+		if row.pointCode == "" {
+			continue
+		}
+		if first, ok := points[code]; ok {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueDuplicate, "与第%d行重复", first.row)
+			continue
+		}
+		points[code] = row
+		pointOrder = append(pointOrder, code)
+	}
+
+	if err := s.planSchools(plan, schools, schoolOrder, scope, operatorID); err != nil {
+		return nil, err
+	}
+	if err := s.planAreas(plan, areas, areaOrder, operatorID); err != nil {
+		return nil, err
+	}
+	if err := s.planPoints(plan, points, pointOrder, operatorID); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(plan.issues, func(i, j int) bool { return plan.issues[i].Row < plan.issues[j].Row })
+	return plan, nil
+}
+
+func (s *OPCodeImportService) planSchools(plan *opCodeImportPlan, rows map[string]*opCodeImportRow, order []string, scope *opCodeImportScope, operatorID string) error {
+	var existing []models.OPCodeSchool
+	if err := s.db.Where("school_code IN ?", order).Find(&existing).Error; err != nil {
+		return err
+	}
+	byCode := make(map[string]models.OPCodeSchool, len(existing))
+	for _, school := range existing {
+		byCode[school.SchoolCode] = school
+	}
+
+	for _, code := range order {
+		row := rows[code]
+		if school, ok := byCode[code]; ok {
+			if row.schoolName != "" && row.schoolName != school.SchoolName {
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict,
+					"学校 %s 已存在，名称为 %s", code, school.SchoolName)
+			}
+			continue
+		}
+		if row.schoolName == "" {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict, "学校 %s 不存在，须提供学校名称", code)
+			continue
+		}
+		if !scope.all {
+			plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssuePermission, "只有平台管理员可以新建学校")
+			continue
+		}
+		plan.schools = append(plan.schools, &models.OPCodeSchool{
+			ID:         uuid.New().String(),
+			SchoolCode: code,
+			SchoolName: row.schoolName,
+			FullName:   row.schoolName,
+			City:       row.city,
+			Province:   row.province,
+			IsActive:   true,
+			ManagedBy:  operatorID,
+		})
+	}
+	return nil
+}
+
+func (s *OPCodeImportService) planAreas(plan *opCodeImportPlan, rows map[string]*opCodeImportRow, order []string, operatorID string) error {
+	byPrefix := make(map[string]models.OPCodeArea)
+	for _, chunk := range chunkStrings(order, opCodeImportBatchSize) {
+		var existing []models.OPCodeArea
+		if err := s.db.Where("school_code || area_code IN ?", chunk).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, area := range existing {
+			byPrefix[area.SchoolCode+area.AreaCode] = area
+		}
+	}
+
+	for _, prefix := range order {
+		row := rows[prefix]
+		if area, ok := byPrefix[prefix]; ok {
+			if row.areaName != "" && row.areaName != area.AreaName {
+				plan.add(row.row, prefix, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict,
+					"片区 %s 已存在，名称为 %s", prefix, area.AreaName)
+			}
+			continue
+		}
+		if row.areaName == "" {
+			plan.add(row.row, prefix, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict, "片区 %s 不存在，须提供片区名称", prefix)
+			continue
+		}
+		plan.areas = append(plan.areas, &models.OPCodeArea{
+			ID:          uuid.New().String(),
+			SchoolCode:  prefix[:2],
+			AreaCode:    prefix[2:],
+			AreaName:    row.areaName,
+			IsActive:    true,
+			ManagedBy:   operatorID,
+			UniqueIndex: prefix,
+		})
+	}
+	return nil
+}
+
+func (s *OPCodeImportService) planPoints(plan *opCodeImportPlan, rows map[string]*opCodeImportRow, order []string, operatorID string) error {
+	byCode := make(map[string]models.OPCode)
+	for _, chunk := range chunkStrings(order, opCodeImportBatchSize) {
+		var existing []models.OPCode
+		// 含已删除的编码：停用过的编码不再签发，避免寄给旧住户的信件误投
+		if err := s.db.Unscoped().Where("code IN ?", chunk).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, opCode := range existing {
+			byCode[opCode.Code] = opCode
+		}
+	}
+
+	now := time.Now()
+	for _, code := range order {
+		row := rows[code]
+		if existing, ok := byCode[code]; ok {
+			switch {
+			case !existing.IsActive || existing.DeletedAt.Valid:
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueReserved, "编码 %s 已停用，不能重新签发", code)
+			case existing.PointType == row.pointType && (row.pointName == "" || existing.PointName == row.pointName):
+				plan.add(row.row, code, models.OPCodeImportSeverityWarning, models.OPCodeImportIssueExists, "编码 %s 已存在，跳过", code)
+			default:
+				plan.add(row.row, code, models.OPCodeImportSeverityError, models.OPCodeImportIssueConflict,
+					"编码 %s 已存在且类型或名称不同：%s %s", code, existing.PointType, existing.PointName)
+			}
+			continue
+		}
+		plan.points = append(plan.points, &models.OPCode{
+			ID:            uuid.New().String(),
+			Code:          code,
+			SchoolCode:    code[:2],
+			AreaCode:      code[2:4],
+			PointCode:     code[4:],
+			PointType:     row.pointType,
+			PointName:     row.pointName,
+			FullAddress:   row.fullAddress,
+			IsPublic:      row.isPublic,
+			IsActive:      true,
+			CheckRequired: true,
+			BindingStatus: "pending",
+			ManagedBy:     operatorID,
+			ApprovedBy:    &operatorID,
+			ApprovedAt:    &now,
+		})
+	}
+	return nil
+}
+
+// applyOPCodeImportPlan 写入记录并登记到导入批次
+func applyOPCodeImportPlan(tx *gorm.DB, importID string, plan *opCodeImportPlan) error {
+	var items []models.OPCodeImportItem
+	item := func(kind, recordID, code string) {
+		items = append(items, models.OPCodeImportItem{
+			ID: uuid.New().String(), ImportID: importID, Kind: kind, RecordID: recordID, Code: code,
+		})
+	}
+
+	if len(plan.schools) > 0 {
+		if err := tx.CreateInBatches(plan.schools, opCodeImportBatchSize).Error; err != nil {
+			return err
+		}
+		for _, school := range plan.schools {
+			item(models.OPCodeImportKindSchool, school.ID, school.SchoolCode)
+		}
+	}
+	if len(plan.areas) > 0 {
+		if err := tx.CreateInBatches(plan.areas, opCodeImportBatchSize).Error; err != nil {
+			return err
+		}
+		for _, area := range plan.areas {
+			item(models.OPCodeImportKindArea, area.ID, area.SchoolCode+area.AreaCode)
+		}
+	}
+	if len(plan.points) > 0 {
+		if err := tx.CreateInBatches(plan.points, opCodeImportBatchSize).Error; err != nil {
+			return err
+		}
+		for _, point := range plan.points {
+			item(models.OPCodeImportKindPoint, point.ID, point.Code)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(items, opCodeImportBatchSize).Error
+}
+
+// Rollback 按导入批次删除其创建的学校、片区和点位
+// 点位已被绑定、写信使用，或片区/学校下已有其他批次的数据时拒绝回滚
+func (s *OPCodeImportService) Rollback(importID, operatorID string) (*models.OPCodeImport, error) {
+	record, err := s.Get(importID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != models.OPCodeImportStatusApplied {
+		return nil, ErrOPCodeImportNotApplied
+	}
+
+	var items []models.OPCodeImportItem
+	if err := s.db.Where("import_id = ?", importID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	ids := map[string][]string{}
+	codes := map[string][]string{}
+	for _, item := range items {
+		ids[item.Kind] = append(ids[item.Kind], item.RecordID)
+		codes[item.Kind] = append(codes[item.Kind], item.Code)
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 先占住批次状态，并发的回滚请求只有一个能继续
+		result := tx.Model(&models.OPCodeImport{}).
+			Where("id = ? AND status = ?", importID, models.OPCodeImportStatusApplied).
+			Updates(map[string]interface{}{
+				"status":         models.OPCodeImportStatusRolledBack,
+				"rolled_back_at": &now,
+				"rolled_back_by": operatorID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOPCodeImportNotApplied
+		}
+
+		// 锁住导入的编码再检查使用情况，检查与删除之间不会被绑定或投递
+		for _, chunk := range chunkStrings(ids[models.OPCodeImportKindPoint], opCodeImportBatchSize) {
+			var locked []models.OPCode
+			if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", chunk).Find(&locked).Error; err != nil {
+				return err
+			}
+		}
+		inUse, err := s.importedCodesInUse(tx, codes)
+		if err != nil {
+			return err
+		}
+		if len(inUse) > 0 {
+			if len(inUse) > 10 {
+				inUse = append(inUse[:10], "…")
+			}
+			return fmt.Errorf("%w: %s", ErrOPCodeImportInUse, strings.Join(inUse, ", "))
+		}
+
+		for _, chunk := range chunkStrings(ids[models.OPCodeImportKindPoint], opCodeImportBatchSize) {
+			if err := tx.Unscoped().Where("id IN ?", chunk).Delete(&models.OPCode{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, chunk := range chunkStrings(ids[models.OPCodeImportKindArea], opCodeImportBatchSize) {
+			if err := tx.Where("id IN ?", chunk).Delete(&models.OPCodeArea{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(ids[models.OPCodeImportKindSchool]) > 0 {
+			if err := tx.Where("id IN ?", ids[models.OPCodeImportKindSchool]).Delete(&models.OPCodeSchool{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrOPCodeImportNotApplied) || errors.Is(err, ErrOPCodeImportInUse) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("回滚导入批次失败: %w", err)
+	}
+	record.Status = models.OPCodeImportStatusRolledBack
+	record.RolledBackAt = &now
+	record.RolledBackBy = operatorID
+	return record, nil
+}
+
+// importedCodesInUse 导入的编码中已被使用的部分
+func (s *OPCodeImportService) importedCodesInUse(tx *gorm.DB, codes map[string][]string) ([]string, error) {
+	var inUse []string
+	points := codes[models.OPCodeImportKindPoint]
+	imported := make(map[string]bool, len(points))
+	for _, code := range points {
+		imported[code] = true
+	}
+
+	for _, chunk := range chunkStrings(points, opCodeImportBatchSize) {
+		var used []string
+		if err := tx.Model(&models.OPCode{}).Where("code IN ?", chunk).
+			Where("binding_id IS NOT NULL OR usage_count > 0").Pluck("code", &used).Error; err != nil {
+			return nil, err
+		}
+		inUse = append(inUse, used...)
+
+		var addressed []string
+		if err := tx.Model(&models.Letter{}).Where("recipient_op_code IN ? OR delivery_op_code IN ?", chunk, chunk).
+			Distinct().Pluck("recipient_op_code", &addressed).Error; err != nil {
+			return nil, err
+		}
+		inUse = append(inUse, addressed...)
+	}
+
+	// 片区和学校下出现了本批次之外的点位或片区
+	for _, chunk := range chunkStrings(codes[models.OPCodeImportKindArea], opCodeImportBatchSize) {
+		var others []string
+		if err := tx.Unscoped().Model(&models.OPCode{}).Where("school_code || area_code IN ?", chunk).Pluck("code", &others).Error; err != nil {
+			return nil, err
+		}
+		for _, code := range others {
+			if !imported[code] {
+				inUse = append(inUse, code)
+			}
+		}
+	}
+	if schools := codes[models.OPCodeImportKindSchool]; len(schools) > 0 {
+		importedAreas := make(map[string]bool)
+		for _, prefix := range codes[models.OPCodeImportKindArea] {
+			importedAreas[prefix] = true
+		}
+		var areas []models.OPCodeArea
+		if err := tx.Where("school_code IN ?", schools).Find(&areas).Error; err != nil {
+			return nil, err
+		}
+		for _, area := range areas {
+			if !importedAreas[area.SchoolCode+area.AreaCode] {
+				inUse = append(inUse, area.SchoolCode+area.AreaCode)
+			}
+		}
+	}
+
+	sort.Strings(inUse)
+	return uniqueStrings(inUse), nil
+}
+
+// Get 获取导入批次及校验报告，信使只能查看自己的批次
+func (s *OPCodeImportService) Get(importID, operatorID string) (*models.OPCodeImport, error) {
+	scope, err := s.scopeFor(operatorID)
+	if err != nil {
+		return nil, err
+	}
+	var record models.OPCodeImport
+	if err := s.db.First(&record, "id = ?", importID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOPCodeImportNotFound
+		}
+		return nil, err
+	}
+	if !scope.all && record.OperatorID != operatorID {
+		return nil, ErrOPCodeImportNotFound
+	}
+	if record.Report != "" {
+		if err := json.Unmarshal([]byte(record.Report), &record.Issues); err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+// List 导入批次列表，按时间倒序
+func (s *OPCodeImportService) List(operatorID string, page, limit int) ([]models.OPCodeImport, int64, error) {
+	scope, err := s.scopeFor(operatorID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&models.OPCodeImport{})
+	if !scope.all {
+		query = query.Where("operator_id = ?", operatorID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []models.OPCodeImport
+	err = query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&records).Error
+	return records, total, err
+}
+
+// Export 导出学校的编码树，格式与导入一致，可修改后重新导入
+func (s *OPCodeImportService) Export(operatorID, schoolCode, format string) ([]byte, error) {
+	scope, err := s.scopeFor(operatorID)
+	if err != nil {
+		return nil, err
+	}
+	schoolCode = strings.ToUpper(schoolCode)
+	if len(schoolCode) != 2 || !scope.allows(schoolCode) {
+		return nil, ErrOPCodeImportForbidden
+	}
+
+	var school models.OPCodeSchool
+	if err := s.db.Where("school_code = ?", schoolCode).Limit(1).Find(&school).Error; err != nil {
+		return nil, err
+	}
+	var areas []models.OPCodeArea
+	if err := s.db.Where("school_code = ?", schoolCode).Order("area_code").Find(&areas).Error; err != nil {
+		return nil, err
+	}
+	var points []models.OPCode
+	if err := s.db.Where("school_code = ? AND is_active = ?", schoolCode, true).Order("code").Find(&points).Error; err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(opCodeImportColumns))
+	for i, column := range opCodeImportColumns {
+		header[i] = column.key
+	}
+	rows := [][]string{header}
+	rows = append(rows, []string{schoolCode, school.SchoolName, school.City, school.Province, "", "", "", "", "", "", ""})
+	areaNames := make(map[string]string, len(areas))
+	for _, area := range areas {
+		areaNames[area.AreaCode] = area.AreaName
+		rows = append(rows, []string{schoolCode, "", "", "", area.AreaCode, area.AreaName, "", "", "", "", ""})
+	}
+	for _, point := range points {
+		public := "false"
+		if point.IsPublic {
+			public = "true"
+		}
+		rows = append(rows, []string{
+			schoolCode, "", "", "", point.AreaCode, areaNames[point.AreaCode],
+			point.PointCode, point.PointType, point.PointName, point.FullAddress, public,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := sheet.Write(&buf, format, rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for len(values) > size {
+		chunks = append(chunks, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
+
+// uniqueStrings 去掉已排序切片中的重复项
+func uniqueStrings(values []string) []string {
+	result := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"testing"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/sheet"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// 新学校 QH：学校行、两个片区、三个点位；点位代码 1 按 01 处理
+const schoolImportCSV = `school_code,school_name,city,province,area_code,area_name,point_code,point_type,point_name,full_address,is_public
+QH,清华大学,北京,北京,,,,,,,
+QH,,,,5F,紫荆公寓,,,,,
+QH,,,,5F,紫荆公寓,1,dormitory,紫荆1号楼,紫荆公寓1号楼,true
+QH,,,,5F,,02,dormitory,紫荆2号楼,,false
+QH,,,,3D,东区,A1,shop,东区超市,,是
+`
+
+// OPCodeImportTestSuite OP Code批量导入测试套件
+type OPCodeImportTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *OPCodeImportService
+	admin   *models.User
+}
+
+func (suite *OPCodeImportTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewOPCodeImportService(db)
+	suite.admin = config.CreateTestUser(db, "opcode_admin", models.RolePlatformAdmin)
+
+	suite.NoError(db.Create(&models.OPCodeSchool{ID: uuid.New().String(), SchoolCode: "PK", SchoolName: "北京大学", IsActive: true}).Error)
+	suite.NoError(db.Create(&models.OPCodeArea{
+		ID: uuid.New().String(), SchoolCode: "PK", AreaCode: "5F", AreaName: "五院", IsActive: true, UniqueIndex: "PK5F",
+	}).Error)
+	suite.NoError(db.Create(&models.OPCode{
+		ID: uuid.New().String(), Code: "PK5F3D", SchoolCode: "PK", AreaCode: "5F", PointCode: "3D",
+		PointType: models.OPCodeTypeDormitory, PointName: "3D宿舍", IsActive: true, ManagedBy: "courier",
+	}).Error)
+	suite.NoError(db.Create(&models.OPCode{
+		ID: uuid.New().String(), Code: "PK5F3E", SchoolCode: "PK", AreaCode: "5F", PointCode: "3E",
+		PointType: models.OPCodeTypeDormitory, PointName: "已拆除宿舍", IsActive: true, ManagedBy: "courier",
+	}).Error)
+	// is_active 有默认值，停用需单独更新
+	suite.NoError(db.Model(&models.OPCode{}).Where("code = ?", "PK5F3E").Update("is_active", false).Error)
+}
+
+func (suite *OPCodeImportTestSuite) countCodes(schoolCode string) int64 {
+	var count int64
+	suite.db.Model(&models.OPCode{}).Where("school_code = ?", schoolCode).Count(&count)
+	return count
+}
+
+func (suite *OPCodeImportTestSuite) TestImport_DryRunWritesNothing() {
+	record, err := suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), true)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusDryRun, record.Status)
+	suite.Equal(5, record.TotalRows)
+	suite.Equal(1, record.SchoolsCreated)
+	suite.Equal(2, record.AreasCreated)
+	suite.Equal(3, record.PointsCreated)
+	suite.Zero(record.ErrorCount)
+
+	suite.Zero(suite.countCodes("QH"))
+	var schools int64
+	suite.db.Model(&models.OPCodeSchool{}).Where("school_code = ?", "QH").Count(&schools)
+	suite.Zero(schools)
+}
+
+func (suite *OPCodeImportTestSuite) TestImport_AppliesAndRecordsItems() {
+	record, err := suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), false)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusApplied, record.Status)
+	suite.NotNil(record.AppliedAt)
+
+	var point models.OPCode
+	suite.NoError(suite.db.Where("code = ?", "QH5F01").First(&point).Error)
+	suite.Equal("紫荆1号楼", point.PointName)
+	suite.True(point.IsPublic)
+	suite.True(point.CheckRequired)
+	suite.Equal(int64(3), suite.countCodes("QH"))
+
+	var area models.OPCodeArea
+	suite.NoError(suite.db.Where("school_code = ? AND area_code = ?", "QH", "3D").First(&area).Error)
+	suite.Equal("东区", area.AreaName)
+
+	var items int64
+	suite.db.Model(&models.OPCodeImportItem{}).Where("import_id = ?", record.ID).Count(&items)
+	suite.Equal(int64(6), items)
+
+	// 再次导入同一文件：已存在的编码只提示，不重复创建
+	again, err := suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), false)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusApplied, again.Status)
+	suite.Zero(again.SchoolsCreated + again.AreasCreated + again.PointsCreated)
+	suite.Equal(3, again.WarningCount)
+	suite.Equal(int64(3), suite.countCodes("QH"))
+}
+
+func (suite *OPCodeImportTestSuite) TestImport_RejectsWholeFileOnErrors() {
+	data := `学校代码,片区代码,片区名称,点位代码,点位类型,点位名称
+PK,5F,,00,dormitory,集散点
+PK,5F,,3D,dormitory,3D宿舍
+PK,5F,,3E,dormitory,新宿舍
+PK,5F,,4A,dormitory,4A宿舍
+PK,5F,,4A,dormitory,4A宿舍
+PK,5F,六院,4B,dormitory,4B宿舍
+PK,5F,,4C,library,图书馆
+`
+	record, err := suite.service.Import(suite.admin.ID, "bad.csv", []byte(data), false)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusRejected, record.Status)
+	suite.Equal(5, record.ErrorCount)
+	suite.Equal(1, record.WarningCount)
+	suite.Zero(record.PointsCreated)
+
+	types := map[int]string{}
+	for _, issue := range record.Issues {
+		types[issue.Row] = issue.Type
+	}
+	suite.Equal(models.OPCodeImportIssueReserved, types[2])
+	suite.Equal(models.OPCodeImportIssueExists, types[3])
+	suite.Equal(models.OPCodeImportIssueReserved, types[4])
+	suite.Equal(models.OPCodeImportIssueDuplicate, types[6])
+	suite.Equal(models.OPCodeImportIssueConflict, types[7])
+	suite.Equal(models.OPCodeImportIssueInvalid, types[8])
+
+	// 整批未写入
+	suite.Equal(int64(2), suite.countCodes("PK"))
+
+	stored, err := suite.service.Get(record.ID, suite.admin.ID)
+	suite.NoError(err)
+	suite.Len(stored.Issues, len(record.Issues))
+}
+
+func (suite *OPCodeImportTestSuite) TestImport_CourierLimitedToManagedSchool() {
+	user := config.CreateTestUser(suite.db, "senior_courier", models.RoleCourierLevel3)
+	suite.NoError(suite.db.Create(&models.Courier{
+		ID: uuid.New().String(), UserID: user.ID, Name: "三级信使", Contact: "138", School: "北京大学", Zone: "PK",
+		Status: "approved", Level: 3, ManagedOPCodePrefix: "PK",
+	}).Error)
+
+	data := `school_code,area_code,area_name,point_code,point_type,point_name
+PK,5F,,4A,dormitory,4A宿舍
+QH,5F,紫荆公寓,01,dormitory,紫荆1号楼
+`
+	record, err := suite.service.Import(user.ID, "mixed.csv", []byte(data), true)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusRejected, record.Status)
+	suite.Len(record.Issues, 1)
+	suite.Equal(models.OPCodeImportIssuePermission, record.Issues[0].Type)
+	suite.Equal(3, record.Issues[0].Row)
+
+	// 信使不能新建学校
+	record, err = suite.service.Import(user.ID, "new.csv", []byte("school_code,school_name\nPK,北京大学\n"), true)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusDryRun, record.Status)
+
+	_, err = suite.service.Export(user.ID, "QH", sheet.FormatCSV)
+	suite.ErrorIs(err, ErrOPCodeImportForbidden)
+
+	student := config.CreateTestUser(suite.db, "student", models.RoleUser)
+	_, err = suite.service.Import(student.ID, "pk.csv", []byte(data), true)
+	suite.ErrorIs(err, ErrOPCodeImportForbidden)
+}
+
+func (suite *OPCodeImportTestSuite) TestExportXLSX_RoundTrip() {
+	_, err := suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), false)
+	suite.NoError(err)
+
+	data, err := suite.service.Export(suite.admin.ID, "QH", sheet.FormatXLSX)
+	suite.NoError(err)
+	rows, err := sheet.Read(sheet.FormatXLSX, data)
+	suite.NoError(err)
+	suite.Len(rows, 1+1+2+3)
+	suite.Equal([]string{"QH", "清华大学", "北京", "北京", "", "", "", "", "", "", ""}, rows[1])
+
+	// 导出的表格可以原样导入，全部为已存在
+	record, err := suite.service.Import(suite.admin.ID, "export.xlsx", data, true)
+	suite.NoError(err)
+	suite.Equal(sheet.FormatXLSX, record.Format)
+	suite.Zero(record.ErrorCount)
+	suite.Equal(3, record.WarningCount)
+	suite.Zero(record.PointsCreated)
+}
+
+func (suite *OPCodeImportTestSuite) TestRollback() {
+	record, err := suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), false)
+	suite.NoError(err)
+
+	rolledBack, err := suite.service.Rollback(record.ID, suite.admin.ID)
+	suite.NoError(err)
+	suite.Equal(models.OPCodeImportStatusRolledBack, rolledBack.Status)
+	var count int64
+	suite.db.Unscoped().Model(&models.OPCode{}).Where("school_code = ?", "QH").Count(&count)
+	suite.Zero(count)
+	suite.db.Model(&models.OPCodeSchool{}).Where("school_code = ?", "QH").Count(&count)
+	suite.Zero(count)
+
+	_, err = suite.service.Rollback(record.ID, suite.admin.ID)
+	suite.ErrorIs(err, ErrOPCodeImportNotApplied)
+
+	// 重新导入后点位被绑定，不能回滚
+	record, err = suite.service.Import(suite.admin.ID, "qh.csv", []byte(schoolImportCSV), false)
+	suite.NoError(err)
+	bindingID := "user-1"
+	suite.NoError(suite.db.Model(&models.OPCode{}).Where("code = ?", "QH5F02").Update("binding_id", &bindingID).Error)
+
+	_, err = suite.service.Rollback(record.ID, suite.admin.ID)
+	suite.ErrorIs(err, ErrOPCodeImportInUse)
+	suite.Contains(err.Error(), "QH5F02")
+	suite.Equal(int64(3), suite.countCodes("QH"))
+	var stored models.OPCodeImport
+	suite.NoError(suite.db.First(&stored, "id = ?", record.ID).Error)
+	suite.Equal(models.OPCodeImportStatusApplied, stored.Status, "回滚失败时批次状态不变")
+
+	_, err = suite.service.Rollback(uuid.New().String(), suite.admin.ID)
+	suite.ErrorIs(err, ErrOPCodeImportNotFound)
+}
+
+func TestOPCodeImportService(t *testing.T) {
+	suite.Run(t, new(OPCodeImportTestSuite))
+}
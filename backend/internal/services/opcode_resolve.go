@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"openpenpal-backend/internal/models"
+)
+
+// 形近字符，手写和口述时最容易混淆，替换代价按半个字符计
+var opCodeConfusables = map[[2]byte]bool{}
+
+func init() {
+	for _, pair := range []string{"0O", "0D", "1I", "1L", "2Z", "5S", "6G", "8B", "UV", "MN"} {
+		opCodeConfusables[[2]byte{pair[0], pair[1]}] = true
+		opCodeConfusables[[2]byte{pair[1], pair[0]}] = true
+	}
+}
+
+const (
+	opCodeSuggestMaxDistance = 2.0
+	opCodeSuggestDefaultSize = 5
+)
+
+// OPCodeInvalidError OP Code无效，附带解析结果和候选编码
+type OPCodeInvalidError struct {
+	Resolution *models.OPCodeResolution
+}
+
+func (e *OPCodeInvalidError) Error() string {
+	return e.Resolution.Message
+}
+
+// SetLegacyFormatDeadline 设置6位旧格式的过渡期截止时间，零值表示不限期
+func (s *OPCodeService) SetLegacyFormatDeadline(deadline time.Time) {
+	s.legacyUntil = deadline
+}
+
+// legacyFormatAllowed 过渡期内存量编码可使用不带校验位的6位格式
+func (s *OPCodeService) legacyFormatAllowed(opCode *models.OPCode, at time.Time) bool {
+	if opCode.CheckRequired {
+		return false
+	}
+	return s.legacyUntil.IsZero() || at.Before(s.legacyUntil)
+}
+
+// ResolveOPCode 解析用户填写的OP Code，无效时给出候选
+// contextOPCode 为寄件人等上下文编码，同校候选优先
+func (s *OPCodeService) ResolveOPCode(input, contextOPCode string) (*models.OPCodeResolution, error) {
+	result := &models.OPCodeResolution{Input: input}
+
+	code, checked, err := models.NormalizeOPCodeInput(input)
+	if err != nil {
+		result.Reason = models.OPCodeReasonInvalidFormat
+		if errors.Is(err, models.ErrOPCodeCheckMismatch) {
+			result.Reason = models.OPCodeReasonCheckMismatch
+		}
+		result.Message = err.Error()
+		return s.withSuggestions(result, contextOPCode)
+	}
+	result.Code = code
+	result.CheckedCode = models.WithOPCodeCheckChar(code)
+	result.Checked = checked
+
+	var opCode models.OPCode
+	if err := s.db.Where("code = ? AND is_active = ?", code, true).Limit(1).Find(&opCode).Error; err != nil {
+		return nil, err
+	}
+	if opCode.ID == "" {
+		result.Reason = models.OPCodeReasonNotFound
+		result.Message = "OP Code不存在或已失效"
+		return s.withSuggestions(result, contextOPCode)
+	}
+
+	if !checked && !s.legacyFormatAllowed(&opCode, time.Now()) {
+		// 无法判断6位输入是否有误，不给候选，请用户核对后填写完整格式
+		result.Reason = models.OPCodeReasonCheckRequired
+		result.Message = models.ErrOPCodeCheckRequired.Error()
+		return result, nil
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+// RequireOPCode 解析并校验OP Code，返回6位编码；无效时返回 *OPCodeInvalidError
+func (s *OPCodeService) RequireOPCode(input, contextOPCode string) (string, error) {
+	result, err := s.ResolveOPCode(input, contextOPCode)
+	if err != nil {
+		return "", err
+	}
+	if !result.Valid {
+		return "", &OPCodeInvalidError{Resolution: result}
+	}
+	return result.Code, nil
+}
+
+func (s *OPCodeService) withSuggestions(result *models.OPCodeResolution, contextOPCode string) (*models.OPCodeResolution, error) {
+	suggestions, err := s.SuggestOPCodes(result.Input, contextOPCode, opCodeSuggestDefaultSize)
+	if err != nil {
+		return nil, err
+	}
+	result.Suggestions = suggestions
+	return result, nil
+}
+
+// SuggestOPCodes “您是不是要找”：按编辑距离查找相近的有效编码
+//
+// 候选范围按层级收窄：与输入同校的编码、与上下文同校的编码，以及片区和点位都相同的编码（学校代码写错）。
+// 排序依次考虑编辑距离、书写的校验位是否吻合、是否同一片区/楼栋、是否与上下文同校，最后按使用次数。
+// 非公开点位不返回完整编码和校验位，只提示所在片区/楼栋。
+func (s *OPCodeService) SuggestOPCodes(input, contextOPCode string, limit int) ([]models.OPCodeSuggestion, error) {
+	cleaned := models.CleanOPCodeInput(input)
+	if len(cleaned) < 5 || len(cleaned) > 7 {
+		return nil, nil
+	}
+	body := cleaned
+	var checkHint byte
+	if len(cleaned) == 7 {
+		body, checkHint = cleaned[:6], cleaned[6]
+	}
+	if limit <= 0 {
+		limit = opCodeSuggestDefaultSize
+	}
+
+	contextSchool := models.CleanOPCodeInput(contextOPCode)
+	if len(contextSchool) >= 2 {
+		contextSchool = contextSchool[:2]
+	} else {
+		contextSchool = ""
+	}
+
+	query := s.db.Where("is_active = ?", true)
+	if len(body) == 6 {
+		query = query.Where("school_code IN ? OR (area_code = ? AND point_code = ?)", []string{body[:2], contextSchool}, body[2:4], body[4:6])
+	} else {
+		query = query.Where("school_code IN ?", []string{body[:2], contextSchool})
+	}
+	var candidates []models.OPCode
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		suggestion models.OPCodeSuggestion
+		checkMatch bool
+		sameSchool bool
+		public     bool
+		usage      int
+	}
+	var matches []scored
+	for _, candidate := range candidates {
+		distance := opCodeDistance(body, candidate.Code)
+		if distance > opCodeSuggestMaxDistance {
+			continue
+		}
+		checked := models.WithOPCodeCheckChar(candidate.Code)
+		suggestion := models.OPCodeSuggestion{
+			Code:        candidate.Code,
+			CheckedCode: checked,
+			SameArea:    len(body) >= 4 && candidate.SchoolCode+candidate.AreaCode == body[:4],
+			Distance:    distance,
+		}
+		if candidate.IsPublic {
+			suggestion.PointName = candidate.PointName
+		}
+		matches = append(matches, scored{
+			suggestion: suggestion,
+			checkMatch: checkHint != 0 && checked[6] == checkHint,
+			sameSchool: contextSchool != "" && candidate.SchoolCode == contextSchool,
+			public:     candidate.IsPublic,
+			usage:      candidate.UsageCount,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.suggestion.Distance != b.suggestion.Distance {
+			return a.suggestion.Distance < b.suggestion.Distance
+		}
+		if a.checkMatch != b.checkMatch {
+			return a.checkMatch
+		}
+		if a.suggestion.SameArea != b.suggestion.SameArea {
+			return a.suggestion.SameArea
+		}
+		if a.sameSchool != b.sameSchool {
+			return a.sameSchool
+		}
+		if a.usage != b.usage {
+			return a.usage > b.usage
+		}
+		return a.suggestion.Code < b.suggestion.Code
+	})
+
+	// 校验接口对外公开，非公开点位只给到片区/楼栋（XXXX**），同一片区只出现一次
+	suggestions := make([]models.OPCodeSuggestion, 0, limit)
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		suggestion := match.suggestion
+		if !match.public {
+			suggestion.Code = models.FormatOPCode(suggestion.Code, true)
+			suggestion.CheckedCode = ""
+		}
+		if seen[suggestion.Code] {
+			continue
+		}
+		seen[suggestion.Code] = true
+		suggestions = append(suggestions, suggestion)
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return s.fillAreaNames(suggestions)
+}
+
+// fillAreaNames 补充片区/楼栋名称，便于用户确认
+func (s *OPCodeService) fillAreaNames(suggestions []models.OPCodeSuggestion) ([]models.OPCodeSuggestion, error) {
+	if len(suggestions) == 0 {
+		return suggestions, nil
+	}
+	schools := make([]string, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		schools = append(schools, suggestion.Code[:2])
+	}
+	var areas []models.OPCodeArea
+	if err := s.db.Where("school_code IN ? AND is_active = ?", schools, true).Find(&areas).Error; err != nil {
+		return nil, fmt.Errorf("查询片区失败: %w", err)
+	}
+	names := make(map[string]string, len(areas))
+	for _, area := range areas {
+		names[area.SchoolCode+area.AreaCode] = area.AreaName
+	}
+	for i := range suggestions {
+		suggestions[i].AreaName = names[suggestions[i].Code[:4]]
+	}
+	return suggestions, nil
+}
+
+// opCodeDistance Damerau-Levenshtein（相邻颠倒算一次）编辑距离，形近字符替换按 0.5 计
+func opCodeDistance(a, b string) float64 {
+	d := make([][]float64, len(a)+1)
+	for i := range d {
+		d[i] = make([]float64, len(b)+1)
+		d[i][0] = float64(i)
+	}
+	for j := 0; j <= len(b); j++ {
+		d[0][j] = float64(j)
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1.0
+			if a[i-1] == b[j-1] {
+				cost = 0
+			} else if opCodeConfusables[[2]byte{a[i-1], b[j-1]}] {
+				cost = 0.5
+			}
+			d[i][j] = math.Min(math.Min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = math.Min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// OPCodeResolveTestSuite OP Code校验位与候选建议测试套件
+type OPCodeResolveTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service *OPCodeService
+}
+
+func (suite *OPCodeResolveTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+	suite.service = NewOPCodeService(db)
+
+	// PK5F 片区两个相邻宿舍，PK3D 另一片区，QH 另一所学校
+	suite.createOPCode("PK5F3D", false, true, 10)
+	suite.createOPCode("PK5F3E", false, false, 2)
+	suite.createOPCode("PK3D3D", false, false, 50)
+	suite.createOPCode("QH5F3D", false, false, 0)
+	suite.createOPCode("PK5F01", true, true, 0)
+	suite.NoError(db.Create(&models.OPCodeArea{
+		ID: uuid.New().String(), SchoolCode: "PK", AreaCode: "5F", AreaName: "第五片区", IsActive: true, UniqueIndex: "PK5F",
+	}).Error)
+}
+
+func (suite *OPCodeResolveTestSuite) createOPCode(code string, checkRequired, public bool, usage int) {
+	suite.NoError(suite.db.Create(&models.OPCode{
+		ID:            uuid.New().String(),
+		Code:          code,
+		SchoolCode:    code[:2],
+		AreaCode:      code[2:4],
+		PointCode:     code[4:6],
+		PointType:     models.OPCodeTypeDormitory,
+		PointName:     "宿舍" + code,
+		IsPublic:      public,
+		IsActive:      true,
+		CheckRequired: checkRequired,
+		ManagedBy:     "courier",
+		UsageCount:    usage,
+	}).Error)
+}
+
+func (suite *OPCodeResolveTestSuite) TestCheckChar_DetectsTypos() {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	code := "PK5F3D"
+	checked := models.WithOPCodeCheckChar(code)
+	suite.Len(checked, 7)
+
+	normalized, ok, err := models.NormalizeOPCodeInput("pk5f3d-" + checked[6:])
+	suite.NoError(err)
+	suite.True(ok)
+	suite.Equal(code, normalized)
+
+	// 任意单字符替换都能被检出
+	for i := 0; i < len(checked); i++ {
+		for _, ch := range alphabet {
+			if byte(ch) == checked[i] {
+				continue
+			}
+			typo := checked[:i] + string(ch) + checked[i+1:]
+			_, _, err := models.NormalizeOPCodeInput(typo)
+			suite.ErrorIs(err, models.ErrOPCodeCheckMismatch, typo)
+		}
+	}
+
+	// 相邻字符颠倒都能被检出
+	for i := 0; i+1 < len(checked); i++ {
+		if checked[i] == checked[i+1] {
+			continue
+		}
+		swapped := checked[:i] + string(checked[i+1]) + string(checked[i]) + checked[i+2:]
+		_, _, err := models.NormalizeOPCodeInput(swapped)
+		suite.ErrorIs(err, models.ErrOPCodeCheckMismatch, swapped)
+	}
+}
+
+func (suite *OPCodeResolveTestSuite) TestResolve_DualFormat() {
+	// 存量编码过渡期内两种格式都可以
+	result, err := suite.service.ResolveOPCode("PK5F3D", "")
+	suite.NoError(err)
+	suite.True(result.Valid)
+	suite.False(result.Checked)
+
+	result, err = suite.service.ResolveOPCode(models.WithOPCodeCheckChar("PK5F3D"), "")
+	suite.NoError(err)
+	suite.True(result.Valid)
+	suite.True(result.Checked)
+
+	// 新签发的编码必须带校验位
+	result, err = suite.service.ResolveOPCode("PK5F01", "")
+	suite.NoError(err)
+	suite.False(result.Valid)
+	suite.Equal(models.OPCodeReasonCheckRequired, result.Reason)
+	suite.Empty(result.Suggestions)
+
+	code, err := suite.service.RequireOPCode(models.WithOPCodeCheckChar("PK5F01"), "")
+	suite.NoError(err)
+	suite.Equal("PK5F01", code)
+
+	// 过渡期结束后存量编码也必须带校验位
+	suite.service.SetLegacyFormatDeadline(time.Now().Add(-time.Hour))
+	_, err = suite.service.RequireOPCode("PK5F3D", "")
+	var invalid *OPCodeInvalidError
+	suite.True(errors.As(err, &invalid))
+	suite.Equal(models.OPCodeReasonCheckRequired, invalid.Resolution.Reason)
+}
+
+func (suite *OPCodeResolveTestSuite) TestResolve_Suggestions() {
+	// 不存在的编码：按编辑距离排序，距离相同时按使用次数，其他片区距离过远不出现
+	// 非公开点位 PK5F3E 只给出片区
+	result, err := suite.service.ResolveOPCode("PK5F3F", "")
+	suite.NoError(err)
+	suite.False(result.Valid)
+	suite.Equal(models.OPCodeReasonNotFound, result.Reason)
+	suite.Require().Len(result.Suggestions, 3)
+	suite.Equal("PK5F3D", result.Suggestions[0].Code)
+	suite.Equal(models.WithOPCodeCheckChar("PK5F3D"), result.Suggestions[0].CheckedCode)
+	suite.True(result.Suggestions[0].SameArea)
+	suite.Equal("第五片区", result.Suggestions[0].AreaName)
+	suite.Equal("宿舍PK5F3D", result.Suggestions[0].PointName)
+	suite.Equal("PK5F**", result.Suggestions[1].Code)
+	suite.Empty(result.Suggestions[1].CheckedCode, "非公开点位不返回完整编码")
+	suite.Empty(result.Suggestions[1].PointName, "非公开点位不返回名称")
+	suite.Equal("第五片区", result.Suggestions[1].AreaName)
+
+	suite.Equal("PK5F01", result.Suggestions[2].Code)
+
+	// 学校代码无法识别时，与寄件人同校的候选优先
+	result, err = suite.service.ResolveOPCode("XX5F3D", "QH0101")
+	suite.NoError(err)
+	suite.Require().Len(result.Suggestions, 2)
+	suite.Equal("QH5F**", result.Suggestions[0].Code)
+	suite.Equal("PK5F3D", result.Suggestions[1].Code)
+
+	// 形近字符：O 写成了 0
+	result, err = suite.service.ResolveOPCode("PK5F0D", "")
+	suite.NoError(err)
+	suite.Require().NotEmpty(result.Suggestions)
+	suite.Equal("PK5F3D", result.Suggestions[0].Code)
+
+	// 学校代码写错，片区和点位相同的编码会被找到
+	result, err = suite.service.ResolveOPCode("PX5F3D", "")
+	suite.NoError(err)
+	suite.Require().NotEmpty(result.Suggestions)
+	suite.Equal("PK5F3D", result.Suggestions[0].Code)
+
+	// 校验位不符：书写的校验位吻合的候选优先，非公开点位仍不暴露完整编码
+	intended := models.WithOPCodeCheckChar("PK5F3E")
+	result, err = suite.service.ResolveOPCode("PK5F3F"+intended[6:], "")
+	suite.NoError(err)
+	suite.Equal(models.OPCodeReasonCheckMismatch, result.Reason)
+	suite.Require().NotEmpty(result.Suggestions)
+	suite.Equal("PK5F**", result.Suggestions[0].Code)
+	suite.Empty(result.Suggestions[0].CheckedCode)
+}
+
+func (suite *OPCodeResolveTestSuite) TestCreateDraft_ReturnsSuggestions() {
+	letterService := NewLetterService(suite.db, config.GetTestConfig())
+	letterService.SetOPCodeService(suite.service)
+	user := config.CreateTestUser(suite.db, "opcodesender", models.RoleUser)
+
+	_, err := letterService.CreateDraft(user.ID, &models.CreateLetterRequest{
+		Content: "你好", Style: models.StyleClassic, RecipientOPCode: "PK5F3F",
+	})
+	var invalid *OPCodeInvalidError
+	suite.Require().True(errors.As(err, &invalid))
+	suite.NotEmpty(invalid.Resolution.Suggestions)
+
+	letter, err := letterService.CreateDraft(user.ID, &models.CreateLetterRequest{
+		Content: "你好", Style: models.StyleClassic, RecipientOPCode: models.WithOPCodeCheckChar("PK5F3D"),
+	})
+	suite.NoError(err)
+	suite.Equal("PK5F3D", letter.RecipientOPCode)
+}
+
+func TestOPCodeResolveSuite(t *testing.T) {
+	suite.Run(t, new(OPCodeResolveTestSuite))
+}
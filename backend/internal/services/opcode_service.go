@@ -12,7 +12,9 @@ import (
 
 // OPCodeService OP Code服务 - 管理6位编码系统
 type OPCodeService struct {
-	db *gorm.DB
+	db                        *gorm.DB
+	schoolVerificationService *SchoolVerificationService
+	legacyUntil               time.Time // 6位旧格式过渡期截止时间
 }
 
 // NewOPCodeService 创建OP Code服务
@@ -20,6 +22,11 @@ func NewOPCodeService(db *gorm.DB) *OPCodeService {
 	return &OPCodeService{db: db}
 }
 
+// SetSchoolVerificationService 设置在校身份认证服务（仅认证学校的用户可申请）
+func (s *OPCodeService) SetSchoolVerificationService(schoolVerificationService *SchoolVerificationService) {
+	s.schoolVerificationService = schoolVerificationService
+}
+
 // ApplyForOPCode 申请OP Code
 func (s *OPCodeService) ApplyForOPCode(userID string, req *models.OPCodeRequest) (*models.OPCodeApplication, error) {
 	// 验证学校和片区代码格式
@@ -31,6 +38,13 @@ func (s *OPCodeService) ApplyForOPCode(userID string, req *models.OPCodeRequest)
 	req.SchoolCode = strings.ToUpper(req.SchoolCode)
 	req.AreaCode = strings.ToUpper(req.AreaCode)
 
+	// 只能为已认证的本校申请
+	if s.schoolVerificationService != nil {
+		if _, err := s.schoolVerificationService.RequireVerified(userID, req.SchoolCode); err != nil {
+			return nil, err
+		}
+	}
+
 	// 创建申请记录
 	application := &models.OPCodeApplication{
 		ID:          generateID(),
@@ -103,6 +117,7 @@ func (s *OPCodeService) AssignOPCode(reviewerID string, applicationID string, po
 		FullAddress:   application.FullAddress,
 		IsPublic:      false, // Default to false for privacy
 		IsActive:      true,
+		CheckRequired: true, // 新签发编码一律使用带校验位格式
 		BindingType:   "user",
 		BindingID:     &application.UserID,
 		BindingStatus: "approved",
@@ -153,6 +168,25 @@ func (s *OPCodeService) GetOPCodeByCode(code string, includePrivate bool) (*mode
 	return &opCode, nil
 }
 
+// SetOPCodeLocation 设置投递点坐标与签收地理围栏半径
+func (s *OPCodeService) SetOPCodeLocation(code string, req *models.OPCodeLocationRequest) (*models.SignalCode, error) {
+	var opCode models.SignalCode
+	if err := s.db.Where("code = ?", strings.ToUpper(code)).First(&opCode).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"latitude":        *req.Latitude,
+		"longitude":       *req.Longitude,
+		"geofence_radius": req.GeofenceRadius,
+	}
+	if err := s.db.Model(&opCode).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update location: %w", err)
+	}
+
+	return &opCode, nil
+}
+
 // ValidateCourierAccess 验证信使是否有权限访问某个OP Code
 func (s *OPCodeService) ValidateCourierAccess(courierID string, targetOPCode string) (bool, error) {
 	targetOPCode = strings.ToUpper(targetOPCode)
@@ -272,21 +306,11 @@ func (s *OPCodeService) GetOPCodeStats(schoolCode string) (*models.OPCodeStats,
 
 // MigrateZoneToOPCode 将旧的Zone系统迁移到OP Code
 // ValidateOPCode 验证OP Code格式和有效性
+// 支持6位旧格式和7位带校验位格式，无效时返回 *OPCodeInvalidError
 func (s *OPCodeService) ValidateOPCode(code string) (bool, error) {
-	// 验证格式
-	if len(code) != 6 {
-		return false, fmt.Errorf("OP Code must be exactly 6 characters")
-	}
-
-	// 验证是否存在于数据库
-	var opcode models.OPCode
-	if err := s.db.Where("code = ? AND is_active = ?", code, true).First(&opcode).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return false, fmt.Errorf("OP Code not found or inactive")
-		}
+	if _, err := s.RequireOPCode(code, ""); err != nil {
 		return false, err
 	}
-
 	return true, nil
 }
 
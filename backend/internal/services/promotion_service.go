@@ -12,7 +12,8 @@ import (
 
 // PromotionService SOTA级别的晋升系统服务
 type PromotionService struct {
-	db *gorm.DB
+	db                        *gorm.DB
+	schoolVerificationService *SchoolVerificationService
 }
 
 // NewPromotionService 创建晋升服务实例
@@ -75,6 +76,11 @@ func (LevelRequirement) TableName() string {
 	return "courier_level_requirements"
 }
 
+// SetSchoolVerificationService 设置在校身份认证服务（晋升需认证在校身份）
+func (s *PromotionService) SetSchoolVerificationService(schoolVerificationService *SchoolVerificationService) {
+	s.schoolVerificationService = schoolVerificationService
+}
+
 // SubmitUpgradeRequest 提交晋升申请 - SOTA实现
 func (s *PromotionService) SubmitUpgradeRequest(userID string, currentLevel, requestLevel int, reason string, evidence map[string]interface{}) (*UpgradeRequest, error) {
 	// 1. 验证输入参数
@@ -82,6 +88,13 @@ func (s *PromotionService) SubmitUpgradeRequest(userID string, currentLevel, req
 		return nil, fmt.Errorf("只能申请晋升到下一级")
 	}
 
+	// 在校身份认证过期后需重新认证才能晋升
+	if s.schoolVerificationService != nil {
+		if _, err := s.schoolVerificationService.RequireVerified(userID, ""); err != nil {
+			return nil, fmt.Errorf("请先完成在校身份认证: %w", err)
+		}
+	}
+
 	// 2. 检查是否有未处理的申请
 	var existingRequest UpgradeRequest
 	result := s.db.Where("courier_id = ? AND status = 'pending'", userID).First(&existingRequest)
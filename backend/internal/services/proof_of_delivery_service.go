@@ -0,0 +1,431 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+const (
+	deliveryConfirmationTTL      = 30 * time.Minute
+	deliveryConfirmationAttempts = 5
+	deliveryGeofenceRadius       = 200 // 默认投递点围栏半径（米）
+	deliveryQRPrefix             = "OPPOD"
+)
+
+var (
+	ErrDeliveryLetterNotFound       = errors.New("letter not found")
+	ErrDeliveryNotRecipient         = errors.New("only the recipient can confirm delivery")
+	ErrDeliveryNotDeliverable       = errors.New("letter is not out for delivery")
+	ErrDeliveryCourierMismatch      = errors.New("letter is not assigned to this courier")
+	ErrDeliveryConfirmationRequired = errors.New("recipient has not issued a confirmation code")
+	ErrDeliveryConfirmationInvalid  = errors.New("invalid confirmation code")
+	ErrDeliveryLocationRequired     = errors.New("courier location is required for this delivery point")
+	ErrDeliveryOutsideGeofence      = errors.New("courier is outside the delivery point geofence")
+	ErrDeliveryPhotoInvalid         = errors.New("invalid delivery photo")
+	ErrDeliveryReceiptNotFound      = errors.New("delivery receipt not found")
+	ErrDeliveryReceiptRequired      = errors.New("delivery must be confirmed by the recipient with a signed receipt")
+)
+
+// ProofOfDeliveryService 签收凭证服务 - 收件人确认码、照片、地理围栏与签名凭证
+type ProofOfDeliveryService struct {
+	db              *gorm.DB
+	config          *config.Config
+	notificationSvc *NotificationService
+	qrTokenSvc      *QRTokenService
+}
+
+// NewProofOfDeliveryService 创建签收凭证服务
+func NewProofOfDeliveryService(db *gorm.DB, config *config.Config) *ProofOfDeliveryService {
+	return &ProofOfDeliveryService{
+		db:     db,
+		config: config,
+	}
+}
+
+// SetNotificationService 设置通知服务
+func (s *ProofOfDeliveryService) SetNotificationService(notificationSvc *NotificationService) {
+	s.notificationSvc = notificationSvc
+}
+
+// SetQRTokenService 设置二维码签名令牌服务，设置后确认送达须扫描信封上的签名二维码
+func (s *ProofOfDeliveryService) SetQRTokenService(qrTokenSvc *QRTokenService) {
+	s.qrTokenSvc = qrTokenSvc
+}
+
+// IssueConfirmation 收件人生成一次性签收确认码和二维码，旧的未使用确认码作废
+func (s *ProofOfDeliveryService) IssueConfirmation(letterID, userID string) (*models.DeliveryConfirmationResponse, error) {
+	var letter models.Letter
+	if err := s.db.First(&letter, "id = ?", letterID).Error; err != nil {
+		return nil, ErrDeliveryLetterNotFound
+	}
+	if s.resolveRecipient(&letter) != userID {
+		return nil, ErrDeliveryNotRecipient
+	}
+	if letter.Status != models.StatusCollected && letter.Status != models.StatusInTransit {
+		return nil, ErrDeliveryNotDeliverable
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	confirmation := &models.DeliveryConfirmation{
+		ID:          uuid.New().String(),
+		LetterID:    letter.ID,
+		RecipientID: userID,
+		ExpiresAt:   now.Add(deliveryConfirmationTTL),
+	}
+	confirmation.CodeHash = hashVerificationCode(confirmation.ID, code)
+	confirmation.TokenHash = sha256Hex(token)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DeliveryConfirmation{}).
+			Where("letter_id = ? AND used_at IS NULL AND expires_at > ?", letter.ID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(confirmation).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue delivery confirmation: %w", err)
+	}
+
+	payload := strings.Join([]string{deliveryQRPrefix, confirmation.ID, token}, ":")
+	png, err := qrcode.Encode(payload, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	return &models.DeliveryConfirmationResponse{
+		ID:        confirmation.ID,
+		LetterID:  letter.ID,
+		Code:      code,
+		QRPayload: payload,
+		QRCode:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		ExpiresAt: confirmation.ExpiresAt,
+	}, nil
+}
+
+// ConfirmDelivery 信使当面交付：校验围栏、照片和收件人确认码后标记送达并生成签名凭证
+func (s *ProofOfDeliveryService) ConfirmDelivery(letterCode, courierID string, req *models.ConfirmDeliveryRequest) (*models.DeliveryReceipt, error) {
+	if req.ConfirmationCode == "" && req.QRPayload == "" {
+		return nil, ErrDeliveryConfirmationInvalid
+	}
+
+	var code models.LetterCode
+	if err := s.db.Preload("Letter").First(&code, "code = ?", letterCode).Error; err != nil {
+		return nil, ErrDeliveryLetterNotFound
+	}
+	letter := &code.Letter
+	if letter.Status != models.StatusCollected && letter.Status != models.StatusInTransit {
+		return nil, ErrDeliveryNotDeliverable
+	}
+	// 交付时扫描信封二维码，拒绝伪造或已作废的标签
+	if s.qrTokenSvc != nil {
+		if _, err := s.qrTokenSvc.VerifyScan(req.QRToken, letterCode); err != nil {
+			return nil, err
+		}
+	}
+
+	var task models.CourierTask
+	err := s.db.Where("letter_code = ? AND courier_id = ? AND status NOT IN ?",
+		letterCode, courierID, []string{"delivered", "failed", models.CourierTaskStatusRerouted}).First(&task).Error
+	if err != nil {
+		return nil, ErrDeliveryCourierMismatch
+	}
+
+	targetOPCode := task.DeliveryOPCode
+	if targetOPCode == "" {
+		targetOPCode = letter.DeliveryTarget()
+	}
+	geofence, distance, err := s.checkGeofence(targetOPCode, req.Latitude, req.Longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	var photo *models.StorageFile
+	if req.PhotoFileID != "" {
+		photo = &models.StorageFile{}
+		err := s.db.First(photo, "id = ? AND uploaded_by = ? AND category = ? AND status = ?",
+			req.PhotoFileID, courierID, models.FileCategoryImage, models.FileStatusActive).Error
+		if err != nil {
+			return nil, ErrDeliveryPhotoInvalid
+		}
+	}
+
+	confirmation, method, err := s.verifyConfirmation(letter.ID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Truncate(time.Second)
+	receipt := &models.DeliveryReceipt{
+		ID:             uuid.New().String(),
+		LetterID:       letter.ID,
+		LetterCode:     letterCode,
+		CourierID:      courierID,
+		RecipientID:    confirmation.RecipientID,
+		ConfirmationID: confirmation.ID,
+		ConfirmMethod:  method,
+		TargetOPCode:   targetOPCode,
+		Latitude:       req.Latitude,
+		Longitude:      req.Longitude,
+		GeofenceResult: geofence,
+		DistanceMeters: distance,
+		DeliveredAt:    now,
+	}
+	if photo != nil {
+		receipt.PhotoFileID = &photo.ID
+		receipt.PhotoHash = photo.HashSHA256
+	}
+	receipt.Signature = s.signReceipt(receipt)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 确认码只能使用一次，并发提交时只有一个成功
+		result := tx.Model(&models.DeliveryConfirmation{}).
+			Where("id = ? AND used_at IS NULL", confirmation.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeliveryConfirmationRequired
+		}
+
+		if err := tx.Create(receipt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Letter{}).Where("id = ?", letter.ID).
+			Update("status", models.StatusDelivered).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LetterCode{}).Where("id = ?", code.ID).Updates(map[string]interface{}{
+			"status":          models.BarcodeStatusDelivered,
+			"delivered_at":    now,
+			"last_scanned_by": courierID,
+			"last_scanned_at": now,
+			"scan_count":      gorm.Expr("scan_count + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CourierTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"status":       "delivered",
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if photo != nil {
+			if err := tx.Model(&models.StorageFile{}).Where("id = ?", photo.ID).Updates(map[string]interface{}{
+				"related_type": "delivery_receipt",
+				"related_id":   receipt.ID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		statusLog := &models.StatusLog{
+			ID:        uuid.New().String(),
+			LetterID:  letter.ID,
+			Status:    models.StatusDelivered,
+			UpdatedBy: courierID,
+			Location:  req.Location,
+			Note:      req.Note,
+			ReceiptID: &receipt.ID,
+		}
+		if err := tx.Create(statusLog).Error; err != nil {
+			return err
+		}
+		return recordLetterSyncEvent(tx, models.SyncEventLetterStatusChanged, letterCode, req.Note)
+	})
+	if err != nil {
+		if errors.Is(err, ErrDeliveryConfirmationRequired) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to confirm delivery: %w", err)
+	}
+
+	if s.notificationSvc != nil {
+		data := map[string]interface{}{
+			"letter_id":  letter.ID,
+			"code":       letterCode,
+			"status":     models.StatusDelivered,
+			"receipt_id": receipt.ID,
+		}
+		if err := s.notificationSvc.NotifyUser(letter.UserID, "letter_delivered", data); err != nil {
+			fmt.Printf("Failed to send delivery notification: %v\n", err)
+		}
+		if err := s.notificationSvc.NotifyUser(receipt.RecipientID, "letter_received", data); err != nil {
+			fmt.Printf("Failed to send delivery notification: %v\n", err)
+		}
+	}
+
+	return receipt, nil
+}
+
+// GetReceipt 获取信件签收凭证并校验签名，仅寄件人、收件人、投递信使和管理员可查看
+func (s *ProofOfDeliveryService) GetReceipt(letterID, userID string, isAdmin bool) (*models.DeliveryReceiptResponse, error) {
+	var receipt models.DeliveryReceipt
+	if err := s.db.First(&receipt, "letter_id = ?", letterID).Error; err != nil {
+		return nil, ErrDeliveryReceiptNotFound
+	}
+
+	if !isAdmin && userID != receipt.CourierID && userID != receipt.RecipientID {
+		var letter models.Letter
+		if err := s.db.Select("id", "user_id").First(&letter, "id = ?", letterID).Error; err != nil || letter.UserID != userID {
+			return nil, ErrDeliveryReceiptNotFound
+		}
+	}
+
+	return &models.DeliveryReceiptResponse{
+		Receipt:        &receipt,
+		SignatureValid: s.VerifyReceipt(&receipt),
+	}, nil
+}
+
+// VerifyReceipt 校验签收凭证签名，凭证内容被篡改时返回false
+func (s *ProofOfDeliveryService) VerifyReceipt(receipt *models.DeliveryReceipt) bool {
+	expected := s.signReceipt(receipt)
+	return hmac.Equal([]byte(expected), []byte(receipt.Signature))
+}
+
+// resolveRecipient 收件人为信件指定用户，未指定时为绑定收件OP Code的用户
+func (s *ProofOfDeliveryService) resolveRecipient(letter *models.Letter) string {
+	if letter.RecipientID != "" {
+		return letter.RecipientID
+	}
+	if letter.DeliveryTarget() == "" {
+		return ""
+	}
+	// 已转寄的信件以转寄后的编码确定收件人
+	var opCode models.OPCode
+	err := s.db.Where("code = ? AND binding_type = ?", letter.DeliveryTarget(), "user").First(&opCode).Error
+	if err != nil || opCode.BindingID == nil {
+		return ""
+	}
+	return *opCode.BindingID
+}
+
+// checkGeofence 校验信使位置是否在投递点围栏内，投递点未设置坐标时不做限制
+func (s *ProofOfDeliveryService) checkGeofence(opCode string, lat, lng *float64) (string, *float64, error) {
+	var point models.OPCode
+	if opCode == "" || s.db.Where("code = ?", opCode).First(&point).Error != nil ||
+		point.Latitude == nil || point.Longitude == nil {
+		return models.GeofenceUnavailable, nil, nil
+	}
+	if lat == nil || lng == nil {
+		return "", nil, ErrDeliveryLocationRequired
+	}
+
+	radius := float64(point.GeofenceRadius)
+	if radius <= 0 {
+		radius = deliveryGeofenceRadius
+	}
+	distance := math.Round(haversineMeters(*lat, *lng, *point.Latitude, *point.Longitude))
+	if distance > radius {
+		return models.GeofenceOutside, &distance, ErrDeliveryOutsideGeofence
+	}
+	return models.GeofenceInside, &distance, nil
+}
+
+// verifyConfirmation 校验确认码或二维码，错误次数过多时确认码作废
+func (s *ProofOfDeliveryService) verifyConfirmation(letterID string, req *models.ConfirmDeliveryRequest) (*models.DeliveryConfirmation, string, error) {
+	var confirmation models.DeliveryConfirmation
+	err := s.db.Where("letter_id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?",
+		letterID, time.Now(), deliveryConfirmationAttempts).
+		Order("created_at DESC").First(&confirmation).Error
+	if err != nil {
+		return nil, "", ErrDeliveryConfirmationRequired
+	}
+
+	method := deliveryConfirmMethod(req)
+	var ok bool
+	if method == models.DeliveryConfirmByQR {
+		parts := strings.Split(req.QRPayload, ":")
+		ok = len(parts) == 3 && parts[0] == deliveryQRPrefix && parts[1] == confirmation.ID &&
+			subtle.ConstantTimeCompare([]byte(sha256Hex(parts[2])), []byte(confirmation.TokenHash)) == 1
+	} else {
+		ok = subtle.ConstantTimeCompare(
+			[]byte(hashVerificationCode(confirmation.ID, req.ConfirmationCode)),
+			[]byte(confirmation.CodeHash)) == 1
+	}
+	if !ok {
+		s.db.Model(&confirmation).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+		return nil, "", ErrDeliveryConfirmationInvalid
+	}
+	return &confirmation, method, nil
+}
+
+// deliveryConfirmMethod 请求使用的签收确认方式，优先使用二维码
+func deliveryConfirmMethod(req *models.ConfirmDeliveryRequest) string {
+	if req.QRPayload != "" {
+		return models.DeliveryConfirmByQR
+	}
+	return models.DeliveryConfirmByCode
+}
+
+// signReceipt 使用由JWT密钥派生的密钥对凭证关键字段做HMAC签名
+func (s *ProofOfDeliveryService) signReceipt(receipt *models.DeliveryReceipt) string {
+	photoID := ""
+	if receipt.PhotoFileID != nil {
+		photoID = *receipt.PhotoFileID
+	}
+	payload := strings.Join([]string{
+		receipt.ID,
+		receipt.LetterID,
+		receipt.LetterCode,
+		receipt.CourierID,
+		receipt.RecipientID,
+		receipt.ConfirmationID,
+		receipt.ConfirmMethod,
+		photoID,
+		receipt.PhotoHash,
+		receipt.TargetOPCode,
+		formatCoordinate(receipt.Latitude),
+		formatCoordinate(receipt.Longitude),
+		receipt.GeofenceResult,
+		receipt.DeliveredAt.UTC().Format(time.RFC3339),
+	}, "|")
+
+	key := sha256.Sum256([]byte("openpenpal-delivery-receipt:" + s.config.JWTSecret))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func formatCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", *value)
+}
+
+// haversineMeters 计算两点间球面距离（米）
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000.0
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// ProofOfDeliveryServiceTestSuite 签收凭证服务测试套件
+type ProofOfDeliveryServiceTestSuite struct {
+	suite.Suite
+	db        *gorm.DB
+	service   *ProofOfDeliveryService
+	sender    *models.User
+	recipient *models.User
+	courier   *models.User
+	letter    *models.Letter
+}
+
+func (suite *ProofOfDeliveryServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.OPCode{}, &models.StorageFile{}, &models.DeliveryConfirmation{}, &models.DeliveryReceipt{}))
+	suite.db = db
+	suite.service = NewProofOfDeliveryService(db, config.GetTestConfig())
+
+	suite.sender = config.CreateTestUser(db, "podsender", models.RoleUser)
+	suite.recipient = config.CreateTestUser(db, "podrecipient", models.RoleUser)
+	suite.courier = config.CreateTestUser(db, "podcourier", models.RoleCourierLevel1)
+
+	suite.letter = config.CreateTestLetter(db, suite.sender.ID)
+	suite.NoError(db.Model(suite.letter).Updates(map[string]interface{}{
+		"status":       models.StatusInTransit,
+		"recipient_id": suite.recipient.ID,
+	}).Error)
+	suite.NoError(db.Create(&models.LetterCode{ID: "code-1", LetterID: suite.letter.ID, Code: "OP7X1F2K", Status: models.BarcodeStatusInTransit}).Error)
+	suite.NoError(db.Create(&models.CourierTask{
+		ID: "task-1", CourierID: suite.courier.ID, LetterCode: "OP7X1F2K", Title: "测试信件",
+		SenderName: "寄件人", TargetLocation: "5号楼", DeliveryOPCode: "PK5F01", Status: "in_transit",
+		Deadline: time.Now().Add(time.Hour),
+	}).Error)
+
+	lat, lng := 39.9912, 116.3064
+	suite.NoError(db.Create(&models.OPCode{
+		ID: "op-1", Code: "PK5F01", SchoolCode: "PK", AreaCode: "5F", PointCode: "01",
+		PointType: "dormitory", ManagedBy: "admin", Latitude: &lat, Longitude: &lng, GeofenceRadius: 100,
+	}).Error)
+}
+
+func coordinate(v float64) *float64 {
+	return &v
+}
+
+// TestConfirmDelivery_WithCode 测试收件人确认码签收，生成签名凭证并挂在状态日志上
+func (suite *ProofOfDeliveryServiceTestSuite) TestConfirmDelivery_WithCode() {
+	_, err := suite.service.IssueConfirmation(suite.letter.ID, suite.sender.ID)
+	suite.ErrorIs(err, ErrDeliveryNotRecipient)
+
+	confirmation, err := suite.service.IssueConfirmation(suite.letter.ID, suite.recipient.ID)
+	suite.NoError(err)
+	suite.Len(confirmation.Code, 6)
+	suite.Contains(confirmation.QRCode, "data:image/png;base64,")
+
+	wrong := "000000"
+	if confirmation.Code == wrong {
+		wrong = "111111"
+	}
+	req := &models.ConfirmDeliveryRequest{ConfirmationCode: wrong, Latitude: coordinate(39.9913), Longitude: coordinate(116.3065)}
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, req)
+	suite.ErrorIs(err, ErrDeliveryConfirmationInvalid)
+
+	req.ConfirmationCode = confirmation.Code
+	receipt, err := suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, req)
+	suite.NoError(err)
+	suite.Equal(models.GeofenceInside, receipt.GeofenceResult)
+	suite.Equal(models.DeliveryConfirmByCode, receipt.ConfirmMethod)
+	suite.Equal(suite.recipient.ID, receipt.RecipientID)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal(models.StatusDelivered, letter.Status)
+
+	var statusLog models.StatusLog
+	suite.NoError(suite.db.Where("letter_id = ? AND status = ?", suite.letter.ID, models.StatusDelivered).First(&statusLog).Error)
+	suite.Require().NotNil(statusLog.ReceiptID)
+	suite.Equal(receipt.ID, *statusLog.ReceiptID)
+
+	// 确认码只能使用一次
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, req)
+	suite.Error(err)
+
+	// 寄件人可查看凭证，签名有效；篡改后失效
+	resp, err := suite.service.GetReceipt(suite.letter.ID, suite.sender.ID, false)
+	suite.NoError(err)
+	suite.True(resp.SignatureValid)
+
+	_, err = suite.service.GetReceipt(suite.letter.ID, "someone-else", false)
+	suite.ErrorIs(err, ErrDeliveryReceiptNotFound)
+
+	suite.db.Model(&models.DeliveryReceipt{}).Where("id = ?", receipt.ID).Update("courier_id", "forged-courier")
+	resp, err = suite.service.GetReceipt(suite.letter.ID, suite.recipient.ID, false)
+	suite.NoError(err)
+	suite.False(resp.SignatureValid)
+}
+
+// TestConfirmDelivery_WithQRAndPhoto 测试扫描收件人二维码并附带照片签收
+func (suite *ProofOfDeliveryServiceTestSuite) TestConfirmDelivery_WithQRAndPhoto() {
+	suite.NoError(suite.db.Create(&models.StorageFile{
+		ID: "photo-1", FileName: "p.jpg", OriginalName: "p.jpg", FileSize: 10, Category: models.FileCategoryImage,
+		Provider: models.StorageProviderLocal, ObjectKey: "images/p.jpg", Status: models.FileStatusActive,
+		UploadedBy: suite.courier.ID, HashSHA256: "abc123",
+	}).Error)
+
+	confirmation, err := suite.service.IssueConfirmation(suite.letter.ID, suite.recipient.ID)
+	suite.NoError(err)
+
+	receipt, err := suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, &models.ConfirmDeliveryRequest{
+		QRPayload:   confirmation.QRPayload,
+		Latitude:    coordinate(39.9912),
+		Longitude:   coordinate(116.3064),
+		PhotoFileID: "photo-1",
+	})
+	suite.NoError(err)
+	suite.Equal(models.DeliveryConfirmByQR, receipt.ConfirmMethod)
+	suite.Equal("abc123", receipt.PhotoHash)
+	suite.True(suite.service.VerifyReceipt(receipt))
+}
+
+// TestConfirmDelivery_Geofence 测试投递点围栏外或未提供定位时拒绝签收，且不消耗确认码
+func (suite *ProofOfDeliveryServiceTestSuite) TestConfirmDelivery_Geofence() {
+	confirmation, err := suite.service.IssueConfirmation(suite.letter.ID, suite.recipient.ID)
+	suite.NoError(err)
+
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, &models.ConfirmDeliveryRequest{
+		ConfirmationCode: confirmation.Code,
+	})
+	suite.ErrorIs(err, ErrDeliveryLocationRequired)
+
+	// 约1公里外
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, &models.ConfirmDeliveryRequest{
+		ConfirmationCode: confirmation.Code,
+		Latitude:         coordinate(40.0002),
+		Longitude:        coordinate(116.3064),
+	})
+	suite.ErrorIs(err, ErrDeliveryOutsideGeofence)
+
+	// 其他信使不能投递
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.sender.ID, &models.ConfirmDeliveryRequest{
+		ConfirmationCode: confirmation.Code,
+		Latitude:         coordinate(39.9912),
+		Longitude:        coordinate(116.3064),
+	})
+	suite.ErrorIs(err, ErrDeliveryCourierMismatch)
+
+	_, err = suite.service.ConfirmDelivery("OP7X1F2K", suite.courier.ID, &models.ConfirmDeliveryRequest{
+		ConfirmationCode: confirmation.Code,
+		Latitude:         coordinate(39.9912),
+		Longitude:        coordinate(116.3064),
+	})
+	suite.NoError(err)
+}
+
+// TestReceiptlessDelivery_Rejected 测试绕过当面签收直接标记送达的途径都被拒绝
+func (suite *ProofOfDeliveryServiceTestSuite) TestReceiptlessDelivery_Rejected() {
+	letterService := NewLetterService(suite.db, config.GetTestConfig())
+
+	err := letterService.UpdateStatus("OP7X1F2K", &models.UpdateLetterStatusRequest{Status: models.StatusDelivered}, suite.courier.ID)
+	suite.ErrorIs(err, ErrDeliveryReceiptRequired)
+
+	err = letterService.UpdateBarcodeStatus("OP7X1F2K", &models.UpdateBarcodeStatusRequest{Status: "delivered", OperatorID: suite.courier.ID})
+	suite.ErrorIs(err, ErrDeliveryReceiptRequired)
+
+	err = NewCourierService(suite.db).UpdateTaskLocation("task-1", "PK5F01", models.CourierTaskStatusDelivered, "")
+	suite.ErrorIs(err, ErrDeliveryReceiptRequired)
+
+	var letter models.Letter
+	suite.NoError(suite.db.First(&letter, "id = ?", suite.letter.ID).Error)
+	suite.Equal(models.StatusInTransit, letter.Status)
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "id = ?", "task-1").Error)
+	suite.Equal("in_transit", task.Status)
+}
+
+func TestProofOfDeliveryServiceSuite(t *testing.T) {
+	suite.Run(t, new(ProofOfDeliveryServiceTestSuite))
+}
@@ -2,11 +2,13 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/qrtoken"
 )
 
 // QRScanService - SOTA pattern: Single Responsibility + Strategy Pattern
@@ -16,6 +18,7 @@ type QRScanService struct {
 	courierService *CourierService
 	wsService      WebSocketNotifier
 	creditTaskSvc  *CreditTaskService // 积分任务服务
+	qrTokenSvc     *QRTokenService    // 二维码签名令牌服务
 }
 
 // Use the WebSocketNotifier interface from courier_service.go to avoid redeclaration
@@ -35,6 +38,11 @@ func (s *QRScanService) SetCreditTaskService(creditTaskSvc *CreditTaskService) {
 	s.creditTaskSvc = creditTaskSvc
 }
 
+// SetQRTokenService 设置二维码签名令牌服务，设置后扫码须通过签名校验
+func (s *QRScanService) SetQRTokenService(qrTokenSvc *QRTokenService) {
+	s.qrTokenSvc = qrTokenSvc
+}
+
 // QRScanRequest - Clean API contract
 type QRScanRequest struct {
 	Code      string  `json:"code" binding:"required"`
@@ -44,6 +52,7 @@ type QRScanRequest struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	Notes     string  `json:"notes"`
+	QRToken   string  `json:"qr_token"` // 二维码中的签名令牌，可直接传扫码内容
 }
 
 // QRScanResponse - Rich response with full context
@@ -80,6 +89,17 @@ type CourierTaskInfo struct {
 
 // ProcessQRScan - Main business logic with elegant error handling
 func (s *QRScanService) ProcessQRScan(req *QRScanRequest) (*QRScanResponse, error) {
+	// 任何状态变更前先校验签名令牌，拒绝复印或伪造的标签；未配置令牌服务时不允许扫码改状态
+	if s.qrTokenSvc == nil {
+		return nil, errors.New("qr token service is not configured")
+	}
+	if _, err := s.qrTokenSvc.VerifyScan(req.QRToken, req.Code); err != nil {
+		return &QRScanResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
 	// Strategy Pattern: Different handlers for different actions
 	switch req.Action {
 	case "pickup":
@@ -111,7 +131,7 @@ func (s *QRScanService) processPickup(req *QRScanRequest) (*QRScanResponse, erro
 	}
 
 	// 2. Validate courier permissions
-	canHandle, err := s.validateCourierPermissions(req.CourierID, letter.RecipientOPCode)
+	canHandle, err := s.validateCourierPermissions(req.CourierID, letter.DeliveryTarget())
 	if err != nil {
 		return nil, err
 	}
@@ -183,33 +203,8 @@ func (s *QRScanService) processDelivery(req *QRScanRequest) (*QRScanResponse, er
 		}, nil
 	}
 
-	// 3. Update letter to delivered
-	letter.Status = models.StatusDelivered
-	// Note: Letter model doesn't have DeliveredAt/DeliveryLocation fields, using status tracking
-
-	if err := s.db.Save(letter).Error; err != nil {
-		return nil, fmt.Errorf("failed to update letter status: %w", err)
-	}
-
-	// 4. Update courier task
-	s.completeCourierTask(letter.ID, req)
-
-	// 5. Update courier statistics
-	s.updateCourierStats(req.CourierID, true)
-
-	// 6. Record scan history
-	s.recordScanHistory(req, letter, "delivery")
-
-	// 7. Send notifications
-	s.notifyLetterStatusUpdate(letter, "已成功投递")
-	s.notifyDeliveryComplete(letter)
-
-	return &QRScanResponse{
-		Success:    true,
-		Letter:     s.mapLetterInfo(letter),
-		NextAction: "complete",
-		Message:    "信件投递成功！",
-	}, nil
+	// 3. 送达只能由收件人当面签收确认，由签收凭证服务标记送达，扫码不直接改为已送达
+	return nil, ErrDeliveryReceiptRequired
 }
 
 // Helper methods - Clean, focused implementations
@@ -256,11 +251,11 @@ func (s *QRScanService) createCourierTask(letter *models.Letter, req *QRScanRequ
 		LetterCode:     letterCode.Code,
 		Title:          "Letter Delivery: " + letter.Title,
 		SenderName:     getSenderName(letter),
-		TargetLocation: letter.RecipientOPCode,
+		TargetLocation: letter.DeliveryTarget(),
 		Status:         "accepted",
 		Priority:       "normal", // Default priority since Letter doesn't have Priority field
 		PickupOPCode:   letter.SenderOPCode,
-		DeliveryOPCode: letter.RecipientOPCode,
+		DeliveryOPCode: letter.DeliveryTarget(),
 		CurrentOPCode:  req.Location,
 		Reward:         s.calculateReward(letter),
 		CreatedAt:      time.Now(),
@@ -281,56 +276,6 @@ func (s *QRScanService) calculateReward(letter *models.Letter) int {
 	return baseReward
 }
 
-func (s *QRScanService) completeCourierTask(letterID string, req *QRScanRequest) {
-	s.db.Model(&models.CourierTask{}).
-		Where("letter_id = ? AND courier_id = ?", letterID, req.CourierID).
-		Updates(map[string]interface{}{
-			"status":          "completed",
-			"current_op_code": req.Location,
-			"delivery_notes":  req.Notes,
-			"completed_at":    time.Now(),
-		})
-}
-
-func (s *QRScanService) updateCourierStats(courierID string, success bool) {
-	// Get current courier stats before update
-	var courier models.Courier
-	err := s.db.Where("user_id = ?", courierID).First(&courier).Error
-	if err != nil {
-		fmt.Printf("Failed to get courier stats for rewards: %v\n", err)
-		return
-	}
-
-	// Update courier statistics - elegant increment
-	updates := map[string]interface{}{
-		"task_count": gorm.Expr("task_count + 1"),
-	}
-
-	if success {
-		updates["points"] = gorm.Expr("points + 10")
-	}
-
-	s.db.Model(&models.Courier{}).Where("user_id = ?", courierID).Updates(updates)
-
-	// 触发积分任务奖励 - FSD规格
-	if success && s.creditTaskSvc != nil {
-		go func() {
-			// 检查是否为首次完成任务（原来任务数为0）
-			if courier.TaskCount == 0 {
-				// 首次任务完成奖励 - 20积分
-				if err := s.creditTaskSvc.TriggerCourierFirstTask(courierID, "first_task_"+courierID); err != nil {
-					fmt.Printf("Failed to trigger courier first task reward: %v\n", err)
-				}
-			}
-			
-			// 正常投递奖励 - 5积分
-			if err := s.creditTaskSvc.TriggerCourierDeliveryReward(courierID, "delivery_"+time.Now().Format("20060102150405")); err != nil {
-				fmt.Printf("Failed to trigger courier delivery reward: %v\n", err)
-			}
-		}()
-	}
-}
-
 func (s *QRScanService) recordScanHistory(req *QRScanRequest, letter *models.Letter, action string) {
 	scanRecord := &models.ScanRecord{
 		CourierID:  req.CourierID,
@@ -359,18 +304,6 @@ func (s *QRScanService) notifyLetterStatusUpdate(letter *models.Letter, message
 	}
 }
 
-func (s *QRScanService) notifyDeliveryComplete(letter *models.Letter) {
-	if s.wsService != nil {
-		notification := map[string]interface{}{
-			"type":      "delivery_complete",
-			"letter_id": letter.ID,
-			"message":   "您的信件已成功投递",
-			"timestamp": time.Now().Unix(),
-		}
-		s.wsService.BroadcastToUser(letter.UserID, notification)
-	}
-}
-
 // Mapping methods - Clean data transformation
 func (s *QRScanService) mapLetterInfo(letter *models.Letter) *LetterInfo {
 	// Get letter code for display
@@ -459,6 +392,24 @@ func (s *QRScanService) ValidateQRCode(qrContent string) (bool, map[string]inter
 	var qrData map[string]interface{}
 	info := make(map[string]interface{})
 
+	// 签名令牌格式（阅读地址带 t 参数或令牌原文）
+	if token := ExtractQRToken(qrContent); qrtoken.IsToken(token) {
+		info["format"] = "signed"
+		if s.qrTokenSvc == nil {
+			info["error"] = "未启用二维码签名校验"
+			return false, info
+		}
+		claims, err := s.qrTokenSvc.Inspect(token)
+		if err != nil {
+			info["error"] = err.Error()
+			return false, info
+		}
+		info["letter_code"] = claims.Code
+		info["recipient_opcode"] = claims.RecipientOPCode
+		info["expires_at"] = time.Unix(claims.ExpiresAt, 0)
+		return true, info
+	}
+
 	if err := json.Unmarshal([]byte(qrContent), &qrData); err == nil {
 		// JSON格式QR码
 		if qrType, ok := qrData["type"].(string); ok && qrType == "openpenpal_letter" {
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/qrtoken"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQRTokenRequired = errors.New("扫码需要携带二维码签名令牌，请扫描信封上的二维码")
+	ErrQRTokenInvalid  = errors.New("二维码签名无效，标签可能被伪造")
+	ErrQRTokenExpired  = errors.New("二维码已过期，请重新打印标签")
+	ErrQRTokenMismatch = errors.New("二维码与信件编号不符")
+	ErrQRTokenRevoked  = errors.New("二维码已作废，标签已重新打印")
+)
+
+// devQRKeyID 未配置签名密钥时由 JWT 密钥派生的开发密钥
+const devQRKeyID = "dev"
+
+// QRTokenService 信件二维码签名令牌服务
+// 令牌内含信件编号、收件人OP Code、标签序号和有效期，信使端可凭公钥离线验证；
+// 服务端额外校验标签序号，重新打印标签后旧标签（包括复印件）失效
+type QRTokenService struct {
+	db       *gorm.DB
+	keys     *qrtoken.KeySet
+	ttl      time.Duration
+	required bool
+}
+
+// NewQRTokenService 按配置加载签名密钥
+func NewQRTokenService(db *gorm.DB, cfg *config.Config) (*QRTokenService, error) {
+	keys := qrtoken.NewKeySet()
+
+	if cfg.QRSigningKeys == "" {
+		if cfg.Environment == "production" {
+			log.Printf("Warning: QR_SIGNING_KEYS not configured, deriving QR signing key from JWT secret")
+		}
+		if err := keys.AddSeed(devQRKeyID, qrtoken.DeriveSeed(cfg.JWTSecret)); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range strings.Split(cfg.QRSigningKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid QR_SIGNING_KEYS entry %q, expected kid:seed", entry)
+		}
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QR signing key %s: %w", kid, err)
+		}
+		if err := keys.AddSeed(kid, seed); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.QRSigningKeyID != "" {
+		if err := keys.SetActive(cfg.QRSigningKeyID); err != nil {
+			return nil, err
+		}
+	}
+
+	ttlDays := cfg.QRTokenTTLDays
+	if ttlDays <= 0 {
+		ttlDays = 365
+	}
+
+	return &QRTokenService{
+		db:       db,
+		keys:     keys,
+		ttl:      time.Duration(ttlDays) * 24 * time.Hour,
+		required: cfg.QRTokenRequired,
+	}, nil
+}
+
+// Issue 为信件编号签发令牌，返回令牌、标签序号和过期时间
+func (s *QRTokenService) Issue(code, recipientOPCode string) (string, string, time.Time, error) {
+	nonce, err := qrtoken.NewNonce()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	token, err := s.keys.Sign(qrtoken.Claims{
+		Code:            code,
+		RecipientOPCode: recipientOPCode,
+		Nonce:           nonce,
+		IssuedAt:        now.Unix(),
+		ExpiresAt:       expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, nonce, expiresAt, nil
+}
+
+// VerifyScan 扫码改状态前校验令牌：签名、有效期、编号一致以及是否为最新打印的标签
+// 未携带令牌时仅在强制模式下拒绝，以兼容旧标签
+func (s *QRTokenService) VerifyScan(token, code string) (*qrtoken.Claims, error) {
+	token = ExtractQRToken(token)
+	if token == "" {
+		if s.required {
+			return nil, ErrQRTokenRequired
+		}
+		return nil, nil
+	}
+
+	claims, err := s.keys.Verify(token, time.Now())
+	switch {
+	case errors.Is(err, qrtoken.ErrExpired):
+		return nil, ErrQRTokenExpired
+	case err != nil:
+		return nil, ErrQRTokenInvalid
+	}
+	if claims.Code != code {
+		return nil, ErrQRTokenMismatch
+	}
+
+	var letterCode models.LetterCode
+	if err := s.db.Select("qr_token_nonce").Where("code = ?", code).First(&letterCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQRTokenMismatch
+		}
+		return nil, err
+	}
+	if letterCode.QRTokenNonce != "" && letterCode.QRTokenNonce != claims.Nonce {
+		return nil, ErrQRTokenRevoked
+	}
+	return claims, nil
+}
+
+// Inspect 仅校验签名和有效期，返回令牌声明，用于扫码预检
+func (s *QRTokenService) Inspect(content string) (*qrtoken.Claims, error) {
+	claims, err := s.keys.Verify(ExtractQRToken(content), time.Now())
+	switch {
+	case errors.Is(err, qrtoken.ErrExpired):
+		return claims, ErrQRTokenExpired
+	case err != nil:
+		return nil, ErrQRTokenInvalid
+	}
+	return claims, nil
+}
+
+// PublicKeys 返回全部验证公钥
+func (s *QRTokenService) PublicKeys() []qrtoken.PublicKey {
+	return s.keys.PublicKeys()
+}
+
+// QRContent 生成二维码内容：信件阅读地址，令牌放在 t 参数中，手机相机扫码直接打开阅读页
+func (s *QRTokenService) QRContent(frontendURL, code, token string) string {
+	return fmt.Sprintf("%s/read/%s?t=%s", strings.TrimRight(frontendURL, "/"), url.PathEscape(code), url.QueryEscape(token))
+}
+
+// ExtractQRToken 从扫码内容中提取令牌，支持令牌原文和带 t 参数的阅读地址
+func ExtractQRToken(content string) string {
+	content = strings.TrimSpace(content)
+	if content == "" || qrtoken.IsToken(content) {
+		return content
+	}
+	if parsed, err := url.Parse(content); err == nil {
+		if token := parsed.Query().Get("t"); qrtoken.IsToken(token) {
+			return token
+		}
+	}
+	return content
+}
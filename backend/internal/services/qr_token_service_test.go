@@ -0,0 +1,189 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/qrtoken"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// QRTokenServiceTestSuite 二维码签名令牌服务测试套件
+type QRTokenServiceTestSuite struct {
+	suite.Suite
+	db            *gorm.DB
+	cfg           *config.Config
+	service       *QRTokenService
+	letterService *LetterService
+	letter        *models.Letter
+}
+
+func (suite *QRTokenServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.db = db
+
+	suite.cfg = config.GetTestConfig()
+	suite.cfg.QRCodeStorePath = suite.T().TempDir()
+	suite.cfg.QRSigningKeys = "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 32))
+	suite.service, err = NewQRTokenService(db, suite.cfg)
+	suite.NoError(err)
+
+	suite.letterService = NewLetterService(db, suite.cfg)
+	suite.letterService.SetQRTokenService(suite.service)
+
+	user := config.CreateTestUser(db, "qrsender", models.RoleUser)
+	suite.letter = config.CreateTestLetter(db, user.ID)
+}
+
+func (suite *QRTokenServiceTestSuite) TestGenerateCodeEmbedsSignedToken() {
+	letterCode, err := suite.letterService.GenerateCode(suite.letter.ID)
+	suite.NoError(err)
+	suite.NotEmpty(letterCode.QRTokenNonce)
+	suite.NotNil(letterCode.QRTokenExpiresAt)
+
+	token, _, _, err := suite.service.Issue(letterCode.Code, suite.letter.RecipientOPCode)
+	suite.NoError(err)
+	content := suite.service.QRContent(suite.cfg.FrontendURL, letterCode.Code, token)
+	suite.True(strings.HasPrefix(content, suite.cfg.FrontendURL+"/read/"+letterCode.Code+"?t="))
+	suite.Equal(token, ExtractQRToken(content))
+}
+
+func (suite *QRTokenServiceTestSuite) TestVerifyScan() {
+	letterCode, err := suite.letterService.GenerateCode(suite.letter.ID)
+	suite.NoError(err)
+
+	// 当前标签的令牌
+	token, err := suite.service.keys.Sign(qrtoken.Claims{
+		Code:      letterCode.Code,
+		Nonce:     letterCode.QRTokenNonce,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	suite.NoError(err)
+	claims, err := suite.service.VerifyScan(token, letterCode.Code)
+	suite.NoError(err)
+	suite.Equal(letterCode.Code, claims.Code)
+
+	// 篡改内容
+	parts := strings.Split(token, ".")
+	forged := qrtoken.Prefix + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"c":"`+letterCode.Code+`","n":"x","exp":9999999999}`)) + "." + parts[3]
+	_, err = suite.service.VerifyScan(forged, letterCode.Code)
+	suite.ErrorIs(err, ErrQRTokenInvalid)
+
+	// 他人签名
+	otherKeys := qrtoken.NewKeySet()
+	suite.NoError(otherKeys.AddSeed("k1", []byte(strings.Repeat("x", ed25519.SeedSize))))
+	foreign, err := otherKeys.Sign(qrtoken.Claims{Code: letterCode.Code, Nonce: letterCode.QRTokenNonce})
+	suite.NoError(err)
+	_, err = suite.service.VerifyScan(foreign, letterCode.Code)
+	suite.ErrorIs(err, ErrQRTokenInvalid)
+
+	// 编号不符
+	_, err = suite.service.VerifyScan(token, "OPOTHER1")
+	suite.ErrorIs(err, ErrQRTokenMismatch)
+
+	// 已过期
+	expired, err := suite.service.keys.Sign(qrtoken.Claims{
+		Code:      letterCode.Code,
+		Nonce:     letterCode.QRTokenNonce,
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+	suite.NoError(err)
+	_, err = suite.service.VerifyScan(expired, letterCode.Code)
+	suite.ErrorIs(err, ErrQRTokenExpired)
+
+	// 重新打印后旧标签作废
+	_, err = suite.letterService.ReissueQRCode(suite.letter.ID)
+	suite.NoError(err)
+	_, err = suite.service.VerifyScan(token, letterCode.Code)
+	suite.ErrorIs(err, ErrQRTokenRevoked)
+}
+
+func (suite *QRTokenServiceTestSuite) TestUpdateStatusRequiresToken() {
+	letterCode, err := suite.letterService.GenerateCode(suite.letter.ID)
+	suite.NoError(err)
+
+	suite.service.required = true
+	err = suite.letterService.UpdateStatus(letterCode.Code, &models.UpdateLetterStatusRequest{Status: models.StatusCollected}, "courier")
+	suite.ErrorIs(err, ErrQRTokenRequired)
+
+	token, err := suite.service.keys.Sign(qrtoken.Claims{Code: letterCode.Code, Nonce: letterCode.QRTokenNonce})
+	suite.NoError(err)
+	err = suite.letterService.UpdateStatus(letterCode.Code, &models.UpdateLetterStatusRequest{
+		Status:  models.StatusCollected,
+		QRToken: suite.service.QRContent(suite.cfg.FrontendURL, letterCode.Code, token),
+	}, "courier")
+	suite.NoError(err)
+}
+
+// TestScanPathsRequireToken 测试条码更新、任务位置更新、确认送达和扫码服务同样校验签名令牌
+func (suite *QRTokenServiceTestSuite) TestScanPathsRequireToken() {
+	letterCode, err := suite.letterService.GenerateCode(suite.letter.ID)
+	suite.NoError(err)
+	suite.NoError(suite.db.Model(suite.letter).Update("status", models.StatusInTransit).Error)
+	suite.NoError(suite.db.Create(&models.CourierTask{
+		ID: "task-qr", CourierID: "courier", LetterCode: letterCode.Code, Title: "测试信件",
+		SenderName: "寄件人", TargetLocation: "5号楼", DeliveryOPCode: "PK5F01", Status: models.CourierTaskStatusPending,
+		Deadline: time.Now().Add(time.Hour),
+	}).Error)
+	suite.service.required = true
+
+	err = suite.letterService.UpdateBarcodeStatus(letterCode.Code, &models.UpdateBarcodeStatusRequest{Status: "in_transit", OperatorID: "courier"})
+	suite.ErrorIs(err, ErrQRTokenRequired)
+
+	pod := NewProofOfDeliveryService(suite.db, suite.cfg)
+	pod.SetQRTokenService(suite.service)
+	_, err = pod.ConfirmDelivery(letterCode.Code, "courier", &models.ConfirmDeliveryRequest{ConfirmationCode: "123456"})
+	suite.ErrorIs(err, ErrQRTokenRequired)
+
+	_, err = NewQRScanService(suite.db, suite.letterService, nil, nil).ProcessQRScan(&QRScanRequest{Code: letterCode.Code, CourierID: "courier", Action: "pickup"})
+	suite.Error(err)
+
+	courierService := NewCourierService(suite.db)
+	courierService.SetQRTokenService(suite.service)
+	err = courierService.UpdateTaskLocation("task-qr", "PK5F01", models.CourierTaskStatusCollected, "")
+	suite.ErrorIs(err, ErrQRTokenRequired)
+
+	foreign, err := suite.service.keys.Sign(qrtoken.Claims{Code: "OTHER001", Nonce: letterCode.QRTokenNonce})
+	suite.NoError(err)
+	err = courierService.UpdateTaskLocation("task-qr", "PK5F01", models.CourierTaskStatusCollected, foreign)
+	suite.ErrorIs(err, ErrQRTokenMismatch)
+
+	token, err := suite.service.keys.Sign(qrtoken.Claims{Code: letterCode.Code, Nonce: letterCode.QRTokenNonce})
+	suite.NoError(err)
+	err = courierService.UpdateTaskLocation("task-qr", "PK5F01", models.CourierTaskStatusCollected, suite.service.QRContent(suite.cfg.FrontendURL, letterCode.Code, token))
+	suite.NoError(err)
+
+	var task models.CourierTask
+	suite.NoError(suite.db.First(&task, "id = ?", "task-qr").Error)
+	suite.Equal(models.CourierTaskStatusCollected, task.Status)
+}
+
+func (suite *QRTokenServiceTestSuite) TestKeyRotation() {
+	suite.cfg.QRSigningKeys += ",k2:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	suite.cfg.QRSigningKeyID = "k2"
+	rotated, err := NewQRTokenService(suite.db, suite.cfg)
+	suite.NoError(err)
+	suite.Len(rotated.PublicKeys(), 2)
+
+	// 轮换前签发的令牌仍可验证
+	oldToken, _, _, err := suite.service.Issue("OPROTATE", "")
+	suite.NoError(err)
+	_, err = rotated.Inspect(oldToken)
+	suite.NoError(err)
+
+	newToken, _, _, err := rotated.Issue("OPROTATE", "")
+	suite.NoError(err)
+	suite.Contains(newToken, ".k2.")
+}
+
+func TestQRTokenServiceSuite(t *testing.T) {
+	suite.Run(t, new(QRTokenServiceTestSuite))
+}
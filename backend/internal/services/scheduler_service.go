@@ -24,6 +24,13 @@ type SchedulerService struct {
 	ctx      context.Context
 	cancel   context.CancelFunc
 	workerID string
+
+	dataRequestService *DataRequestService
+	sessionService     *SessionService
+	ssoService         *SSOService
+	barcodeLifecycle   *BarcodeLifecycleService
+
+	schoolVerificationService *SchoolVerificationService
 }
 
 // TaskWorker 任务执行器
@@ -53,6 +60,31 @@ func NewSchedulerService(db *gorm.DB) *SchedulerService {
 	}
 }
 
+// SetDataRequestService 设置数据主体请求服务（执行到期的账户删除）
+func (s *SchedulerService) SetDataRequestService(dataRequestService *DataRequestService) {
+	s.dataRequestService = dataRequestService
+}
+
+// SetBarcodeLifecycleService 设置条码生命周期服务（过期提醒与回收）
+func (s *SchedulerService) SetBarcodeLifecycleService(barcodeLifecycle *BarcodeLifecycleService) {
+	s.barcodeLifecycle = barcodeLifecycle
+}
+
+// SetSessionService 设置会话服务（系统维护时清理过期会话）
+func (s *SchedulerService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// SetSSOService 设置单点登录服务（系统维护时清理过期登录状态）
+func (s *SchedulerService) SetSSOService(ssoService *SSOService) {
+	s.ssoService = ssoService
+}
+
+// SetSchoolVerificationService 设置在校身份认证服务（系统维护时标记学期结束的认证）
+func (s *SchedulerService) SetSchoolVerificationService(schoolVerificationService *SchoolVerificationService) {
+	s.schoolVerificationService = schoolVerificationService
+}
+
 // Start 启动调度服务
 func (s *SchedulerService) Start() error {
 	log.Printf("Starting scheduler service with worker ID: %s", s.workerID)
@@ -549,6 +581,8 @@ func (s *SchedulerService) performTask(task *models.ScheduledTask) *models.Execu
 		return s.executeImageOptimizationTask(task)
 	case models.TaskTypeStatisticsUpdate:
 		return s.executeStatisticsUpdateTask(task)
+	case models.TaskTypeDataErasure:
+		return s.executeDataErasureTask(task)
 	default:
 		return &models.ExecutionResult{
 			Success: false,
@@ -580,9 +614,31 @@ func (s *SchedulerService) executeUserEngagementTask(task *models.ScheduledTask)
 
 func (s *SchedulerService) executeSystemMaintenanceTask(task *models.ScheduledTask) *models.ExecutionResult {
 	// 执行系统维护任务
+	result := "System maintenance completed"
+	if s.sessionService != nil {
+		cleaned, err := s.sessionService.CleanupExpired()
+		if err != nil {
+			return &models.ExecutionResult{
+				Success: false,
+				Error:   fmt.Sprintf("Session cleanup failed: %v", err),
+			}
+		}
+		result = fmt.Sprintf("System maintenance completed, cleaned up %d expired sessions", cleaned)
+	}
+	if s.ssoService != nil {
+		if cleaned, err := s.ssoService.CleanupExpiredStates(); err == nil && cleaned > 0 {
+			result += fmt.Sprintf(", %d expired sso states", cleaned)
+		}
+	}
+	if s.schoolVerificationService != nil {
+		if expired, err := s.schoolVerificationService.ExpireVerifications(); err == nil && expired > 0 {
+			result += fmt.Sprintf(", %d school verifications expired", expired)
+		}
+	}
+
 	return &models.ExecutionResult{
 		Success: true,
-		Result:  "System maintenance completed",
+		Result:  result,
 	}
 }
 
@@ -605,10 +661,26 @@ func (s *SchedulerService) executeNotificationCleanupTask(task *models.Scheduled
 }
 
 func (s *SchedulerService) executeLetterExpirationTask(task *models.ScheduledTask) *models.ExecutionResult {
-	// 处理过期信件
+	// 提醒即将过期的条码，回收超时未绑定或未收取的条码
+	if s.barcodeLifecycle == nil {
+		return &models.ExecutionResult{
+			Success: false,
+			Error:   "barcode lifecycle service not configured",
+		}
+	}
+
+	result, err := s.barcodeLifecycle.Run(time.Now())
+	if err != nil {
+		return &models.ExecutionResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
 	return &models.ExecutionResult{
 		Success: true,
-		Result:  "Letter expiration check completed",
+		Result: fmt.Sprintf("Barcodes: %d warned, %d expired, %d cancelled, %d envelopes returned",
+			result.Warned, result.Expired, result.Cancelled, result.EnvelopesReturned),
 	}
 }
 
@@ -644,6 +716,29 @@ func (s *SchedulerService) executeStatisticsUpdateTask(task *models.ScheduledTas
 	}
 }
 
+func (s *SchedulerService) executeDataErasureTask(task *models.ScheduledTask) *models.ExecutionResult {
+	// 执行冷静期已结束的账户删除请求
+	if s.dataRequestService == nil {
+		return &models.ExecutionResult{
+			Success: false,
+			Error:   "data request service not configured",
+		}
+	}
+
+	processed, err := s.dataRequestService.ProcessDueErasures()
+	if err != nil {
+		return &models.ExecutionResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &models.ExecutionResult{
+		Success: true,
+		Result:  fmt.Sprintf("Processed %d account erasure requests", processed),
+	}
+}
+
 // 辅助方法
 
 func (s *SchedulerService) getNextRunTime(cronExpr string) (time.Time, error) {
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	schoolVerificationCodeTTL      = 15 * time.Minute
+	schoolVerificationAttempts     = 5
+	schoolVerificationResendDelay  = time.Minute
+	schoolVerificationHourlyLimit  = 5
+	schoolVerificationSupersededBy = "superseded by a newer verification"
+)
+
+var (
+	ErrSchoolNotFound             = errors.New("school not found or inactive")
+	ErrSchoolEmailNotAllowed      = errors.New("email domain is not allowed for this school")
+	ErrSchoolEmailInUse           = errors.New("email is already verified by another account")
+	ErrSchoolVerificationTooOften = errors.New("verification code requested too frequently")
+	ErrSchoolVerificationInvalid  = errors.New("verification expired or invalid")
+	ErrSchoolVerificationCode     = errors.New("invalid verification code")
+	ErrSchoolNotVerified          = errors.New("school affiliation is not verified")
+	ErrSchoolMismatch             = errors.New("verified school does not match")
+)
+
+// VerificationMailer 发送认证验证码邮件
+type VerificationMailer interface {
+	SendTransactionalEmail(userID, toEmail, subject, content string) error
+}
+
+// SchoolVerificationService 在校身份认证服务 - 学校邮箱验证码，按学期过期
+type SchoolVerificationService struct {
+	db     *gorm.DB
+	config *config.Config
+	mailer VerificationMailer
+}
+
+// NewSchoolVerificationService 创建在校身份认证服务
+func NewSchoolVerificationService(db *gorm.DB, config *config.Config) *SchoolVerificationService {
+	return &SchoolVerificationService{
+		db:     db,
+		config: config,
+	}
+}
+
+// SetMailer 设置验证码邮件发送方
+func (s *SchoolVerificationService) SetMailer(mailer VerificationMailer) {
+	s.mailer = mailer
+}
+
+// StartVerification 校验学校邮箱域名并发送验证码
+func (s *SchoolVerificationService) StartVerification(userID string, req *models.StartSchoolVerificationRequest) (*models.SchoolVerification, error) {
+	if s.mailer == nil {
+		return nil, fmt.Errorf("verification mailer is not configured")
+	}
+
+	schoolCode := strings.ToUpper(req.SchoolCode)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var school models.OPCodeSchool
+	if err := s.db.Where("school_code = ? AND is_active = ?", schoolCode, true).First(&school).Error; err != nil {
+		return nil, ErrSchoolNotFound
+	}
+	if !school.AllowsEmail(email) {
+		return nil, ErrSchoolEmailNotAllowed
+	}
+
+	if err := s.checkEmailInUse(s.db, email, userID); err != nil {
+		return nil, err
+	}
+
+	// 发送频率限制
+	var recent int64
+	if err := s.db.Model(&models.SchoolVerification{}).
+		Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-schoolVerificationResendDelay)).
+		Count(&recent).Error; err != nil {
+		return nil, fmt.Errorf("failed to check verification rate: %w", err)
+	}
+	if recent > 0 {
+		return nil, ErrSchoolVerificationTooOften
+	}
+	if err := s.db.Model(&models.SchoolVerification{}).
+		Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-time.Hour)).
+		Count(&recent).Error; err != nil {
+		return nil, fmt.Errorf("failed to check verification rate: %w", err)
+	}
+	if recent >= schoolVerificationHourlyLimit {
+		return nil, ErrSchoolVerificationTooOften
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return nil, err
+	}
+
+	// 之前未完成的验证码作废
+	if err := s.db.Model(&models.SchoolVerification{}).
+		Where("user_id = ? AND status = ?", userID, models.SchoolVerificationPending).
+		Updates(map[string]interface{}{"status": models.SchoolVerificationRevoked, "revoked_reason": schoolVerificationSupersededBy}).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke pending verification: %w", err)
+	}
+
+	verificationID := uuid.New().String()
+	verification := &models.SchoolVerification{
+		ID:            verificationID,
+		UserID:        userID,
+		SchoolCode:    schoolCode,
+		Email:         email,
+		Status:        models.SchoolVerificationPending,
+		CodeHash:      hashVerificationCode(verificationID, code),
+		CodeExpiresAt: time.Now().Add(schoolVerificationCodeTTL),
+	}
+	if err := s.db.Create(verification).Error; err != nil {
+		return nil, fmt.Errorf("failed to create verification: %w", err)
+	}
+
+	subject := fmt.Sprintf("%s 在校身份认证验证码", school.SchoolName)
+	content := fmt.Sprintf("您的在校身份认证验证码为 %s，%d分钟内有效。如非本人操作，请忽略本邮件。", code, int(schoolVerificationCodeTTL.Minutes()))
+	if err := s.mailer.SendTransactionalEmail(userID, email, subject, content); err != nil {
+		s.db.Delete(verification)
+		return nil, fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	verification.School = &school
+	return verification, nil
+}
+
+// ConfirmVerification 校验验证码，认证有效期至当前学期结束
+func (s *SchoolVerificationService) ConfirmVerification(userID string, req *models.ConfirmSchoolVerificationRequest) (*models.SchoolVerification, error) {
+	var verification models.SchoolVerification
+	if err := s.db.Where("id = ? AND user_id = ? AND status = ?", req.VerificationID, userID, models.SchoolVerificationPending).
+		First(&verification).Error; err != nil {
+		return nil, ErrSchoolVerificationInvalid
+	}
+	if time.Now().After(verification.CodeExpiresAt) {
+		return nil, ErrSchoolVerificationInvalid
+	}
+
+	// 先原子地占用一次尝试机会再比对，并发请求也无法超出次数限制
+	result := s.db.Model(&models.SchoolVerification{}).
+		Where("id = ? AND status = ? AND attempts < ?", verification.ID, models.SchoolVerificationPending, schoolVerificationAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrSchoolVerificationInvalid
+	}
+
+	expected := hashVerificationCode(verification.ID, strings.TrimSpace(req.Code))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(verification.CodeHash)) != 1 {
+		return nil, ErrSchoolVerificationCode
+	}
+
+	now := time.Now()
+	expiresAt := schoolTermEnd(now)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 已过学期但尚未清理的认证先置为过期，再在事务内复查邮箱占用
+		if err := tx.Model(&models.SchoolVerification{}).
+			Where("email = ? AND status = ? AND expires_at <= ?", verification.Email, models.SchoolVerificationVerified, now).
+			Update("status", models.SchoolVerificationExpired).Error; err != nil {
+			return err
+		}
+		if err := s.checkEmailInUse(tx, verification.Email, userID); err != nil {
+			return err
+		}
+
+		// 每个用户同时只保留一个有效认证
+		if err := tx.Model(&models.SchoolVerification{}).
+			Where("user_id = ? AND status = ? AND id <> ?", userID, models.SchoolVerificationVerified, verification.ID).
+			Updates(map[string]interface{}{"status": models.SchoolVerificationRevoked, "revoked_reason": schoolVerificationSupersededBy}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.SchoolVerification{}).
+			Where("id = ? AND status = ?", verification.ID, models.SchoolVerificationPending).
+			Updates(map[string]interface{}{
+				"status":      models.SchoolVerificationVerified,
+				"verified_at": now,
+				"expires_at":  expiresAt,
+				"code_hash":   "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSchoolVerificationInvalid
+		}
+		// 认证通过的学校即用户所属学校
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("school_code", verification.SchoolCode).Error
+	})
+	if err != nil {
+		// 并发确认同一邮箱时由唯一索引兜底
+		if !errors.Is(err, ErrSchoolEmailInUse) && !errors.Is(err, ErrSchoolVerificationInvalid) &&
+			errors.Is(s.checkEmailInUse(s.db, verification.Email, userID), ErrSchoolEmailInUse) {
+			return nil, ErrSchoolEmailInUse
+		}
+		return nil, err
+	}
+
+	verification.Status = models.SchoolVerificationVerified
+	verification.VerifiedAt = &now
+	verification.ExpiresAt = &expiresAt
+	return &verification, nil
+}
+
+// checkEmailInUse 同一学校邮箱只能认证一个账号
+func (s *SchoolVerificationService) checkEmailInUse(db *gorm.DB, email, userID string) error {
+	var inUse int64
+	if err := db.Model(&models.SchoolVerification{}).
+		Where("email = ? AND user_id <> ? AND status = ? AND expires_at > ?", email, userID, models.SchoolVerificationVerified, time.Now()).
+		Count(&inUse).Error; err != nil {
+		return fmt.Errorf("failed to check verification email: %w", err)
+	}
+	if inUse > 0 {
+		return ErrSchoolEmailInUse
+	}
+	return nil
+}
+
+// GetStatus 获取用户在校身份认证状态
+func (s *SchoolVerificationService) GetStatus(userID string) (*models.SchoolVerificationStatusResponse, error) {
+	status := &models.SchoolVerificationStatusResponse{}
+
+	active, err := s.GetActiveVerification(userID)
+	if err != nil && !errors.Is(err, ErrSchoolNotVerified) {
+		return nil, err
+	}
+	if active != nil {
+		status.Verified = true
+		status.Verification = active
+	}
+
+	var pending models.SchoolVerification
+	if err := s.db.Where("user_id = ? AND status = ? AND code_expires_at > ?", userID, models.SchoolVerificationPending, time.Now()).
+		Order("created_at DESC").First(&pending).Error; err == nil {
+		status.Pending = &pending
+	}
+
+	return status, nil
+}
+
+// GetActiveVerification 获取用户当前有效的在校身份认证
+func (s *SchoolVerificationService) GetActiveVerification(userID string) (*models.SchoolVerification, error) {
+	var verification models.SchoolVerification
+	err := s.db.Preload("School").
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.SchoolVerificationVerified, time.Now()).
+		Order("verified_at DESC").First(&verification).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchoolNotVerified
+		}
+		return nil, fmt.Errorf("failed to query verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// RequireVerified 要求用户已认证在校身份；schoolCode非空时还须与认证学校一致
+func (s *SchoolVerificationService) RequireVerified(userID, schoolCode string) (*models.SchoolVerification, error) {
+	verification, err := s.GetActiveVerification(userID)
+	if err != nil {
+		return nil, err
+	}
+	if schoolCode != "" && !strings.EqualFold(schoolCode, verification.SchoolCode) {
+		return nil, ErrSchoolMismatch
+	}
+	return verification, nil
+}
+
+// ListVerifications 管理员查询认证记录
+func (s *SchoolVerificationService) ListVerifications(query *models.SchoolVerificationQuery) ([]models.SchoolVerification, int64, error) {
+	db := s.db.Model(&models.SchoolVerification{})
+	if query.SchoolCode != "" {
+		db = db.Where("school_code = ?", strings.ToUpper(query.SchoolCode))
+	}
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count verifications: %w", err)
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+
+	var verifications []models.SchoolVerification
+	if err := db.Order("created_at DESC").Offset((query.Page - 1) * query.Limit).Limit(query.Limit).
+		Find(&verifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list verifications: %w", err)
+	}
+	return verifications, total, nil
+}
+
+// RevokeVerification 管理员撤销认证（如发现冒用）
+func (s *SchoolVerificationService) RevokeVerification(verificationID, reason string) error {
+	result := s.db.Model(&models.SchoolVerification{}).
+		Where("id = ? AND status IN ?", verificationID, []models.SchoolVerificationStatus{models.SchoolVerificationVerified, models.SchoolVerificationPending}).
+		Updates(map[string]interface{}{"status": models.SchoolVerificationRevoked, "revoked_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSchoolVerificationInvalid
+	}
+	return nil
+}
+
+// UpdateSchoolEmailDomains 设置学校允许的认证邮箱域名
+func (s *SchoolVerificationService) UpdateSchoolEmailDomains(schoolCode string, domains []string) (*models.OPCodeSchool, error) {
+	var school models.OPCodeSchool
+	if err := s.db.Where("school_code = ?", strings.ToUpper(schoolCode)).First(&school).Error; err != nil {
+		return nil, ErrSchoolNotFound
+	}
+
+	cleaned := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+		if domain == "" {
+			continue
+		}
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, " ,@/") {
+			return nil, fmt.Errorf("invalid email domain: %s", domain)
+		}
+		cleaned = append(cleaned, domain)
+	}
+
+	school.EmailDomains = strings.Join(cleaned, ",")
+	if err := s.db.Model(&school).Update("email_domains", school.EmailDomains).Error; err != nil {
+		return nil, fmt.Errorf("failed to update email domains: %w", err)
+	}
+	return &school, nil
+}
+
+// ExpireVerifications 将学期结束的认证标记为过期
+func (s *SchoolVerificationService) ExpireVerifications() (int64, error) {
+	result := s.db.Model(&models.SchoolVerification{}).
+		Where("status = ? AND expires_at <= ?", models.SchoolVerificationVerified, time.Now()).
+		Update("status", models.SchoolVerificationExpired)
+	return result.RowsAffected, result.Error
+}
+
+// schoolTermEnd 计算当前学期结束时间：春季学期至9月1日，秋季学期（含寒假）至次年3月1日
+func schoolTermEnd(t time.Time) time.Time {
+	year := t.Year()
+	if t.Month() >= time.March && t.Month() < time.September {
+		return time.Date(year, time.September, 1, 0, 0, 0, 0, t.Location())
+	}
+	if t.Month() >= time.September {
+		year++
+	}
+	return time.Date(year, time.March, 1, 0, 0, 0, 0, t.Location())
+}
+
+// hashVerificationCode 验证码与认证记录绑定后哈希存储
+func hashVerificationCode(verificationID, code string) string {
+	return sha256Hex(verificationID + ":" + code)
+}
+
+// generateVerificationCode 生成6位数字验证码
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate verification code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
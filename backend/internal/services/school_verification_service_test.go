@@ -0,0 +1,212 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// fakeVerificationMailer 记录发送的验证码邮件
+type fakeVerificationMailer struct {
+	sent []string
+}
+
+func (m *fakeVerificationMailer) SendTransactionalEmail(userID, toEmail, subject, content string) error {
+	m.sent = append(m.sent, content)
+	return nil
+}
+
+// lastCode 从最近一封邮件中提取6位验证码
+func (m *fakeVerificationMailer) lastCode() string {
+	if len(m.sent) == 0 {
+		return ""
+	}
+	return regexp.MustCompile(`\d{6}`).FindString(m.sent[len(m.sent)-1])
+}
+
+// SchoolVerificationServiceTestSuite 在校身份认证服务测试套件
+type SchoolVerificationServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	mailer  *fakeVerificationMailer
+	service *SchoolVerificationService
+}
+
+func (suite *SchoolVerificationServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.OPCodeSchool{}, &models.OPCodeApplication{}, &models.SchoolVerification{}))
+	suite.db = db
+
+	suite.mailer = &fakeVerificationMailer{}
+	suite.service = NewSchoolVerificationService(db, config.GetTestConfig())
+	suite.service.SetMailer(suite.mailer)
+
+	suite.NoError(db.Create(&models.OPCodeSchool{ID: "school-pk", SchoolCode: "PK", SchoolName: "北京大学", EmailDomains: "pku.edu.cn", IsActive: true}).Error)
+	suite.NoError(db.Create(&models.OPCodeSchool{ID: "school-qh", SchoolCode: "QH", SchoolName: "清华大学", EmailDomains: "tsinghua.edu.cn", IsActive: true}).Error)
+}
+
+func (suite *SchoolVerificationServiceTestSuite) verify(userID, schoolCode, email string) *models.SchoolVerification {
+	pending, err := suite.service.StartVerification(userID, &models.StartSchoolVerificationRequest{SchoolCode: schoolCode, Email: email})
+	suite.NoError(err)
+	verification, err := suite.service.ConfirmVerification(userID, &models.ConfirmSchoolVerificationRequest{
+		VerificationID: pending.ID,
+		Code:           suite.mailer.lastCode(),
+	})
+	suite.NoError(err)
+	return verification
+}
+
+// TestVerification_EmailDomain 测试仅允许学校域名（含子域名）邮箱
+func (suite *SchoolVerificationServiceTestSuite) TestVerification_EmailDomain() {
+	user := config.CreateTestUser(suite.db, "domainuser", models.RoleUser)
+
+	_, err := suite.service.StartVerification(user.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "someone@gmail.com"})
+	suite.ErrorIs(err, ErrSchoolEmailNotAllowed)
+	_, err = suite.service.StartVerification(user.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "someone@fakepku.edu.cn"})
+	suite.ErrorIs(err, ErrSchoolEmailNotAllowed)
+
+	verification := suite.verify(user.ID, "pk", "Student@Stu.PKU.edu.cn")
+	suite.Equal(models.SchoolVerificationVerified, verification.Status)
+	suite.Equal("student@stu.pku.edu.cn", verification.Email)
+	suite.True(verification.ExpiresAt.After(time.Now()))
+
+	status, err := suite.service.GetStatus(user.ID)
+	suite.NoError(err)
+	suite.True(status.Verified)
+	suite.Equal("PK", status.Verification.SchoolCode)
+
+	var stored models.User
+	suite.NoError(suite.db.First(&stored, "id = ?", user.ID).Error)
+	suite.Equal("PK", stored.SchoolCode)
+}
+
+// TestVerification_CodeAttempts 测试错误验证码次数限制与发送频率限制
+func (suite *SchoolVerificationServiceTestSuite) TestVerification_CodeAttempts() {
+	user := config.CreateTestUser(suite.db, "attemptuser", models.RoleUser)
+
+	pending, err := suite.service.StartVerification(user.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "a@pku.edu.cn"})
+	suite.NoError(err)
+	code := suite.mailer.lastCode()
+	suite.Len(code, 6)
+
+	_, err = suite.service.StartVerification(user.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "a@pku.edu.cn"})
+	suite.ErrorIs(err, ErrSchoolVerificationTooOften)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < schoolVerificationAttempts; i++ {
+		_, err = suite.service.ConfirmVerification(user.ID, &models.ConfirmSchoolVerificationRequest{VerificationID: pending.ID, Code: wrong})
+		suite.ErrorIs(err, ErrSchoolVerificationCode)
+	}
+	_, err = suite.service.ConfirmVerification(user.ID, &models.ConfirmSchoolVerificationRequest{VerificationID: pending.ID, Code: code})
+	suite.ErrorIs(err, ErrSchoolVerificationInvalid)
+}
+
+// TestVerification_EmailInUse 测试同一学校邮箱不能认证多个账号
+func (suite *SchoolVerificationServiceTestSuite) TestVerification_EmailInUse() {
+	first := config.CreateTestUser(suite.db, "firstuser", models.RoleUser)
+	second := config.CreateTestUser(suite.db, "seconduser", models.RoleUser)
+
+	suite.verify(first.ID, "PK", "shared@pku.edu.cn")
+
+	_, err := suite.service.StartVerification(second.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "shared@pku.edu.cn"})
+	suite.ErrorIs(err, ErrSchoolEmailInUse)
+}
+
+// TestVerification_EmailInUseAtConfirm 测试两个账号同时申请同一邮箱，只有先确认的能通过
+func (suite *SchoolVerificationServiceTestSuite) TestVerification_EmailInUseAtConfirm() {
+	first := config.CreateTestUser(suite.db, "racefirst", models.RoleUser)
+	second := config.CreateTestUser(suite.db, "racesecond", models.RoleUser)
+
+	firstPending, err := suite.service.StartVerification(first.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "race@pku.edu.cn"})
+	suite.NoError(err)
+	firstCode := suite.mailer.lastCode()
+	secondPending, err := suite.service.StartVerification(second.ID, &models.StartSchoolVerificationRequest{SchoolCode: "PK", Email: "race@pku.edu.cn"})
+	suite.NoError(err)
+	secondCode := suite.mailer.lastCode()
+
+	_, err = suite.service.ConfirmVerification(first.ID, &models.ConfirmSchoolVerificationRequest{VerificationID: firstPending.ID, Code: firstCode})
+	suite.NoError(err)
+	_, err = suite.service.ConfirmVerification(second.ID, &models.ConfirmSchoolVerificationRequest{VerificationID: secondPending.ID, Code: secondCode})
+	suite.ErrorIs(err, ErrSchoolEmailInUse)
+
+	var stored models.SchoolVerification
+	suite.NoError(suite.db.First(&stored, "id = ?", secondPending.ID).Error)
+	suite.Equal(models.SchoolVerificationPending, stored.Status)
+
+	// 唯一索引拒绝同一邮箱的第二条已认证记录
+	suite.Error(suite.db.Model(&models.SchoolVerification{}).Where("id = ?", secondPending.ID).
+		Update("status", models.SchoolVerificationVerified).Error)
+}
+
+// TestRequireVerified_OPCodeApplication 测试未认证或非本校用户不能申请OP Code
+func (suite *SchoolVerificationServiceTestSuite) TestRequireVerified_OPCodeApplication() {
+	user := config.CreateTestUser(suite.db, "opcodeuser", models.RoleUser)
+	opcodeService := NewOPCodeService(suite.db)
+	opcodeService.SetSchoolVerificationService(suite.service)
+	req := func(school string) *models.OPCodeRequest {
+		return &models.OPCodeRequest{SchoolCode: school, AreaCode: "5F", PointType: "dormitory", PointName: "5号楼", FullAddress: "5号楼303", Reason: "宿舍收信"}
+	}
+
+	_, err := opcodeService.ApplyForOPCode(user.ID, req("PK"))
+	suite.ErrorIs(err, ErrSchoolNotVerified)
+
+	suite.verify(user.ID, "PK", "opcode@pku.edu.cn")
+
+	_, err = opcodeService.ApplyForOPCode(user.ID, req("QH"))
+	suite.ErrorIs(err, ErrSchoolMismatch)
+
+	application, err := opcodeService.ApplyForOPCode(user.ID, req("pk"))
+	suite.NoError(err)
+	suite.Equal("PK", application.SchoolCode)
+
+	courierService := NewCourierService(suite.db)
+	courierService.SetSchoolVerificationService(suite.service)
+	other := config.CreateTestUser(suite.db, "fakecourier", models.RoleUser)
+	_, err = courierService.ApplyCourier(other.ID, &models.CourierApplication{Name: "冒充者", Contact: "13800000000", School: "北京大学"})
+	suite.ErrorIs(err, ErrSchoolNotVerified)
+}
+
+// TestVerification_TermExpiry 测试学期结束后认证失效
+func (suite *SchoolVerificationServiceTestSuite) TestVerification_TermExpiry() {
+	user := config.CreateTestUser(suite.db, "termuser", models.RoleUser)
+	verification := suite.verify(user.ID, "PK", "term@pku.edu.cn")
+
+	suite.db.Model(&models.SchoolVerification{}).Where("id = ?", verification.ID).Update("expires_at", time.Now().Add(-time.Hour))
+
+	_, err := suite.service.RequireVerified(user.ID, "")
+	suite.ErrorIs(err, ErrSchoolNotVerified)
+
+	expired, err := suite.service.ExpireVerifications()
+	suite.NoError(err)
+	suite.Equal(int64(1), expired)
+}
+
+func (suite *SchoolVerificationServiceTestSuite) TestSchoolTermEnd() {
+	loc := time.Local
+	cases := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2026, time.March, 1, 0, 0, 0, 0, loc), time.Date(2026, time.September, 1, 0, 0, 0, 0, loc)},
+		{time.Date(2026, time.August, 31, 23, 0, 0, 0, loc), time.Date(2026, time.September, 1, 0, 0, 0, 0, loc)},
+		{time.Date(2026, time.September, 1, 0, 0, 0, 0, loc), time.Date(2027, time.March, 1, 0, 0, 0, 0, loc)},
+		{time.Date(2027, time.January, 15, 0, 0, 0, 0, loc), time.Date(2027, time.March, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		suite.Equal(tc.want, schoolTermEnd(tc.now), tc.now.String())
+	}
+}
+
+func TestSchoolVerificationServiceSuite(t *testing.T) {
+	suite.Run(t, new(SchoolVerificationServiceTestSuite))
+}
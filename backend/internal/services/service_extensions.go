@@ -20,6 +20,12 @@ func (s *UserService) AdminResetPassword(userID string, newPassword string) erro
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
+	if s.sessionService != nil {
+		if _, err := s.sessionService.RevokeAllSessions(userID, SessionRevokePasswordChanged); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
 	return nil
 }
 
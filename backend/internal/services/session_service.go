@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 会话注销原因
+const (
+	SessionRevokeLogout          = "logout"
+	SessionRevokeLogoutAll       = "logout_all"
+	SessionRevokePasswordChanged = "password_changed"
+	SessionRevokeTokenReuse      = "token_reuse"
+	SessionRevokeByUser          = "revoked"
+)
+
+var (
+	ErrInvalidRefreshToken   = errors.New("invalid refresh token")
+	ErrRefreshTokenReused    = errors.New("refresh token reuse detected")
+	ErrSessionInactive       = errors.New("session expired or revoked")
+	ErrSessionDeviceMismatch = errors.New("refresh token bound to another device")
+)
+
+// SessionService 登录会话服务：设备绑定会话与刷新令牌轮换
+type SessionService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+// NewSessionService 创建会话服务实例
+func NewSessionService(db *gorm.DB, config *config.Config) *SessionService {
+	return &SessionService{
+		db:     db,
+		config: config,
+	}
+}
+
+// CreateSession 登录成功后创建会话并签发令牌对
+func (s *SessionService) CreateSession(user *models.User, device *models.SessionDeviceInfo) (*models.SessionTokens, error) {
+	if device == nil {
+		device = &models.SessionDeviceInfo{}
+	}
+
+	if len(device.UserAgent) > 500 {
+		device.UserAgent = device.UserAgent[:500]
+	}
+
+	now := time.Now()
+	session := &models.UserSession{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		DeviceID:   device.DeviceID,
+		DeviceName: device.DeviceName,
+		UserAgent:  device.UserAgent,
+		IPAddress:  device.IPAddress,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.refreshTTL()),
+	}
+
+	var tokens *models.SessionTokens
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		var err error
+		tokens, err = s.issueTokens(tx, user, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧刷新令牌立即作废
+// 已作废的刷新令牌再次出现说明令牌已泄露，整个会话随即注销
+func (s *SessionService) Refresh(refreshToken, deviceID, ipAddress string) (*models.SessionTokens, error) {
+	var token models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashRefreshToken(refreshToken)).First(&token).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var session models.UserSession
+	if err := s.db.First(&session, "id = ?", token.SessionID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if !session.IsActive() {
+		return nil, ErrSessionInactive
+	}
+
+	if token.UsedAt != nil {
+		s.revokeReused(&session)
+		return nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.DeviceID != "" && session.DeviceID != deviceID {
+		return nil, ErrSessionDeviceMismatch
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ?", session.UserID).Error; err != nil {
+		return nil, ErrSessionInactive
+	}
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is disabled")
+	}
+
+	var tokens *models.SessionTokens
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发请求中只有一个能完成轮换
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		now := time.Now()
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(s.refreshTTL())
+		if ipAddress != "" {
+			session.IPAddress = ipAddress
+		}
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"ip_address":   session.IPAddress,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+
+		var err error
+		tokens, err = s.issueTokens(tx, &user, &session)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		s.revokeReused(&session)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ListSessions 获取用户的有效会话
+func (s *SessionService) ListSessions(userID, currentSessionID string) ([]models.SessionInfo, error) {
+	var sessions []models.UserSession
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	infos := make([]models.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, models.SessionInfo{
+			UserSession: session,
+			IsCurrent:   session.ID == currentSessionID,
+		})
+	}
+	return infos, nil
+}
+
+// RevokeSession 注销用户的指定会话
+func (s *SessionService) RevokeSession(userID, sessionID, reason string) error {
+	result := s.db.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// RevokeAllSessions 注销用户的全部会话，返回注销数量
+func (s *SessionService) RevokeAllSessions(userID, reason string) (int64, error) {
+	result := s.db.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// CleanupExpired 清理过期会话及其刷新令牌
+func (s *SessionService) CleanupExpired() (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -1)
+	if err := s.db.Where("expires_at < ?", cutoff).Delete(&models.RefreshToken{}).Error; err != nil {
+		return 0, err
+	}
+	result := s.db.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.UserSession{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	s.db.Where("session_id NOT IN (?)", s.db.Model(&models.UserSession{}).Select("id")).Delete(&models.RefreshToken{})
+	return result.RowsAffected, nil
+}
+
+// issueTokens 为会话签发访问令牌和新的刷新令牌
+func (s *SessionService) issueTokens(tx *gorm.DB, user *models.User, session *models.UserSession) (*models.SessionTokens, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := &models.RefreshToken{
+		ID:        uuid.New().String(),
+		SessionID: session.ID,
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.accessTTL())
+	accessToken, err := auth.GenerateSessionJWT(user.ID, user.Role, session.ID, s.config.JWTSecret, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &models.SessionTokens{
+		SessionID:        session.ID,
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+// revokeReused 刷新令牌被重放时注销整个会话
+func (s *SessionService) revokeReused(session *models.UserSession) {
+	log.Printf("Refresh token reuse detected for session %s (user %s), revoking session", session.ID, session.UserID)
+	if err := s.RevokeSession(session.UserID, session.ID, SessionRevokeTokenReuse); err != nil {
+		log.Printf("Failed to revoke session %s: %v", session.ID, err)
+	}
+}
+
+// accessTTL 访问令牌有效期
+func (s *SessionService) accessTTL() time.Duration {
+	systemConfig, err := NewSystemSettingsService(s.db, s.config).GetSystemConfig()
+	if err != nil || systemConfig.JWTExpiryHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(systemConfig.JWTExpiryHours) * time.Hour
+}
+
+// refreshTTL 刷新令牌（会话）有效期
+func (s *SessionService) refreshTTL() time.Duration {
+	systemConfig, err := NewSystemSettingsService(s.db, s.config).GetSystemConfig()
+	if err != nil || systemConfig.RefreshTokenDays <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(systemConfig.RefreshTokenDays) * 24 * time.Hour
+}
+
+// hashRefreshToken 刷新令牌只以哈希形式落库
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"testing"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/auth"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SessionServiceTestSuite 会话服务测试套件
+type SessionServiceTestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	config  *config.Config
+	service *SessionService
+	user    *models.User
+}
+
+func (suite *SessionServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(&models.UserSession{}, &models.RefreshToken{}))
+	suite.db = db
+
+	suite.config = config.GetTestConfig()
+	suite.service = NewSessionService(db, suite.config)
+	suite.user = config.CreateTestUser(db, "sessionuser", models.RoleUser)
+}
+
+func (suite *SessionServiceTestSuite) newSession(deviceID string) *models.SessionTokens {
+	tokens, err := suite.service.CreateSession(suite.user, &models.SessionDeviceInfo{
+		DeviceID:   deviceID,
+		DeviceName: "Test Phone",
+		IPAddress:  "127.0.0.1",
+	})
+	suite.NoError(err)
+	return tokens
+}
+
+// TestCreateSession 测试访问令牌绑定会话
+func (suite *SessionServiceTestSuite) TestCreateSession() {
+	tokens := suite.newSession("device-1")
+
+	claims, err := auth.ValidateJWT(tokens.AccessToken, suite.config.JWTSecret)
+	suite.NoError(err)
+	suite.Equal(tokens.SessionID, claims.SessionID)
+	suite.NotEmpty(tokens.RefreshToken)
+
+	var stored models.RefreshToken
+	suite.NoError(suite.db.First(&stored, "session_id = ?", tokens.SessionID).Error)
+	suite.NotEqual(tokens.RefreshToken, stored.TokenHash)
+}
+
+// TestRefresh_Rotation 测试刷新令牌轮换
+func (suite *SessionServiceTestSuite) TestRefresh_Rotation() {
+	tokens := suite.newSession("device-1")
+
+	rotated, err := suite.service.Refresh(tokens.RefreshToken, "device-1", "127.0.0.2")
+	suite.NoError(err)
+	suite.Equal(tokens.SessionID, rotated.SessionID)
+	suite.NotEqual(tokens.RefreshToken, rotated.RefreshToken)
+
+	_, err = suite.service.Refresh(rotated.RefreshToken, "device-1", "127.0.0.2")
+	suite.NoError(err)
+}
+
+// TestRefresh_ReuseRevokesSession 测试旧刷新令牌重放时注销整个会话
+func (suite *SessionServiceTestSuite) TestRefresh_ReuseRevokesSession() {
+	tokens := suite.newSession("device-1")
+
+	rotated, err := suite.service.Refresh(tokens.RefreshToken, "device-1", "")
+	suite.NoError(err)
+
+	_, err = suite.service.Refresh(tokens.RefreshToken, "device-1", "")
+	suite.ErrorIs(err, ErrRefreshTokenReused)
+
+	// 合法持有者的新令牌也随会话一起失效
+	_, err = suite.service.Refresh(rotated.RefreshToken, "device-1", "")
+	suite.ErrorIs(err, ErrSessionInactive)
+
+	var session models.UserSession
+	suite.NoError(suite.db.First(&session, "id = ?", tokens.SessionID).Error)
+	suite.False(session.IsActive())
+	suite.Equal(SessionRevokeTokenReuse, session.RevokedReason)
+}
+
+// TestRefresh_DeviceMismatch 测试刷新令牌绑定设备
+func (suite *SessionServiceTestSuite) TestRefresh_DeviceMismatch() {
+	tokens := suite.newSession("device-1")
+
+	_, err := suite.service.Refresh(tokens.RefreshToken, "device-2", "")
+	suite.ErrorIs(err, ErrSessionDeviceMismatch)
+
+	_, err = suite.service.Refresh("not-a-token", "device-1", "")
+	suite.ErrorIs(err, ErrInvalidRefreshToken)
+}
+
+// TestRevokeAllSessions 测试注销全部会话
+func (suite *SessionServiceTestSuite) TestRevokeAllSessions() {
+	first := suite.newSession("device-1")
+	second := suite.newSession("device-2")
+
+	sessions, err := suite.service.ListSessions(suite.user.ID, first.SessionID)
+	suite.NoError(err)
+	suite.Len(sessions, 2)
+
+	revoked, err := suite.service.RevokeAllSessions(suite.user.ID, SessionRevokeLogoutAll)
+	suite.NoError(err)
+	suite.Equal(int64(2), revoked)
+
+	_, err = suite.service.Refresh(second.RefreshToken, "device-2", "")
+	suite.ErrorIs(err, ErrSessionInactive)
+
+	sessions, err = suite.service.ListSessions(suite.user.ID, "")
+	suite.NoError(err)
+	suite.Empty(sessions)
+}
+
+// TestChangePassword_RevokesSessions 测试修改密码后注销全部会话
+func (suite *SessionServiceTestSuite) TestChangePassword_RevokesSessions() {
+	userService := NewUserService(suite.db, suite.config)
+	userService.SetSessionService(suite.service)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	suite.NoError(err)
+	suite.db.Model(suite.user).Update("password_hash", string(hash))
+
+	login, err := userService.Login(&models.LoginRequest{Username: "sessionuser", Password: "secret123", DeviceID: "device-1"})
+	suite.NoError(err)
+	suite.NotEmpty(login.RefreshToken)
+
+	err = userService.ChangePassword(suite.user.ID, &models.ChangePasswordRequest{OldPassword: "secret123", NewPassword: "newsecret123"})
+	suite.NoError(err)
+
+	_, err = suite.service.Refresh(login.RefreshToken, "device-1", "")
+	suite.ErrorIs(err, ErrSessionInactive)
+}
+
+// TestAdminResetPassword_RevokesSessions 测试管理员重置密码后注销全部会话
+func (suite *SessionServiceTestSuite) TestAdminResetPassword_RevokesSessions() {
+	adminService := NewAdminService(suite.db, suite.config)
+	adminService.SetSessionService(suite.service)
+	userService := NewUserService(suite.db, suite.config)
+	userService.SetSessionService(suite.service)
+
+	first := suite.newSession("device-1")
+	suite.NoError(adminService.ResetUserPassword(suite.user.ID, "reset123456"))
+	_, err := suite.service.Refresh(first.RefreshToken, "device-1", "")
+	suite.ErrorIs(err, ErrSessionInactive)
+
+	var session models.UserSession
+	suite.NoError(suite.db.First(&session, "id = ?", first.SessionID).Error)
+	suite.Equal(SessionRevokePasswordChanged, session.RevokedReason)
+
+	second := suite.newSession("device-2")
+	suite.NoError(userService.AdminResetPassword(suite.user.ID, "reset654321"))
+	_, err = suite.service.Refresh(second.RefreshToken, "device-2", "")
+	suite.ErrorIs(err, ErrSessionInactive)
+}
+
+func TestSessionServiceSuite(t *testing.T) {
+	suite.Run(t, new(SessionServiceTestSuite))
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"openpenpal-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const oidcCacheTTL = time.Hour
+
+// oidcMetadata OIDC发现文档中用到的字段
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcCacheEntry struct {
+	metadata  *oidcMetadata
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// oidcCache 缓存发现文档与签名公钥，公钥在遇到未知kid时刷新
+type oidcCache struct {
+	mu      sync.Mutex
+	entries map[string]*oidcCacheEntry
+}
+
+func newOIDCCache() *oidcCache {
+	return &oidcCache{entries: make(map[string]*oidcCacheEntry)}
+}
+
+func (c *oidcCache) invalidate(issuer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, issuer)
+}
+
+// oidcProvider OpenID Connect 授权码 + PKCE 实现
+type oidcProvider struct {
+	provider *models.IdentityProvider
+	client   *http.Client
+	cache    *oidcCache
+}
+
+// AuthorizationURL 生成OIDC授权地址
+func (p *oidcProvider) AuthorizationURL(state *models.SSOLoginState) (string, error) {
+	metadata, err := p.metadata()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.provider.Scopes
+	if scopes == "" {
+		scopes = ssoDefaultScopes
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.provider.ClientID)
+	params.Set("redirect_uri", state.RedirectURI)
+	params.Set("scope", scopes)
+	params.Set("state", state.ID)
+	params.Set("nonce", state.Nonce)
+	params.Set("code_challenge", pkceChallenge(state.CodeVerifier))
+	params.Set("code_challenge_method", "S256")
+	if state.Reauth {
+		// 要求重新输入凭据，不复用身份提供方已有的登录会话
+		params.Set("prompt", "login")
+		params.Set("max_age", "0")
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Authenticate 用授权码换取并校验id_token
+func (p *oidcProvider) Authenticate(state *models.SSOLoginState, req *models.SSOCallbackRequest) (*models.ExternalIdentity, error) {
+	if req.Code == "" {
+		return nil, fmt.Errorf("authorization code is required")
+	}
+	metadata, err := p.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", req.Code)
+	form.Set("redirect_uri", state.RedirectURI)
+	form.Set("client_id", p.provider.ClientID)
+	form.Set("code_verifier", state.CodeVerifier)
+	if p.provider.ClientSecret != "" {
+		form.Set("client_secret", p.provider.ClientSecret)
+	}
+
+	resp, err := p.client.PostForm(metadata.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token response does not contain an id_token")
+	}
+
+	identity, err := p.verifyIDToken(metadata, tokenResp.IDToken, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if state.Reauth {
+		// 身份提供方可能忽略 prompt=login，以 auth_time 确认是本次跳转后重新认证的
+		authTime, _ := identity.Attributes["auth_time"].(float64)
+		if authTime == 0 || time.Unix(int64(authTime), 0).Before(state.CreatedAt.Add(-time.Minute)) {
+			return nil, fmt.Errorf("identity provider did not re-authenticate the user")
+		}
+	}
+	return identity, nil
+}
+
+// verifyIDToken 校验id_token签名、issuer、audience、有效期与nonce
+func (p *oidcProvider) verifyIDToken(metadata *oidcMetadata, rawToken, nonce string) (*models.ExternalIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(metadata, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+
+	identity := &models.ExternalIdentity{Attributes: map[string]interface{}(claims)}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims["preferred_username"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity, nil
+}
+
+// metadata 获取端点配置，未显式配置时通过发现文档补全
+func (p *oidcProvider) metadata() (*oidcMetadata, error) {
+	issuer := strings.TrimRight(p.provider.Issuer, "/")
+	if p.provider.AuthorizationEndpoint != "" && p.provider.TokenEndpoint != "" && p.provider.JWKSURI != "" {
+		return &oidcMetadata{
+			Issuer:                p.provider.Issuer,
+			AuthorizationEndpoint: p.provider.AuthorizationEndpoint,
+			TokenEndpoint:         p.provider.TokenEndpoint,
+			JWKSURI:               p.provider.JWKSURI,
+		}, nil
+	}
+
+	p.cache.mu.Lock()
+	entry, ok := p.cache.entries[p.provider.Issuer]
+	p.cache.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < oidcCacheTTL {
+		return entry.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	if err := p.getJSON(issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if metadata.Issuer != p.provider.Issuer && strings.TrimRight(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery issuer mismatch: %s", metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery document is incomplete")
+	}
+
+	p.cache.mu.Lock()
+	p.cache.entries[p.provider.Issuer] = &oidcCacheEntry{metadata: &metadata, fetchedAt: time.Now()}
+	p.cache.mu.Unlock()
+	return &metadata, nil
+}
+
+// signingKey 按kid查找RSA公钥，缓存未命中时重新拉取JWKS（应对密钥轮换）
+func (p *oidcProvider) signingKey(metadata *oidcMetadata, kid string) (*rsa.PublicKey, error) {
+	cacheKey := "jwks:" + metadata.JWKSURI
+
+	p.cache.mu.Lock()
+	entry, ok := p.cache.entries[cacheKey]
+	p.cache.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < oidcCacheTTL {
+		if key := lookupJWK(entry.keys, kid); key != nil {
+			return key, nil
+		}
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.cache.mu.Lock()
+	p.cache.entries[cacheKey] = &oidcCacheEntry{keys: keys, fetchedAt: time.Now()}
+	p.cache.mu.Unlock()
+
+	if key := lookupJWK(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+func (p *oidcProvider) getJSON(endpoint string, out interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// lookupJWK kid为空且只有一个密钥时直接使用该密钥
+func lookupJWK(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// casProvider CAS 3.0 票据校验实现
+type casProvider struct {
+	provider *models.IdentityProvider
+	client   *http.Client
+}
+
+// casServiceResponse CAS /p3/serviceValidate 响应
+type casServiceResponse struct {
+	XMLName xml.Name `xml:"serviceResponse"`
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			Values []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"attributes"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+// AuthorizationURL 生成CAS登录地址，service中携带state以便回调时定位
+func (p *casProvider) AuthorizationURL(state *models.SSOLoginState) (string, error) {
+	loginURL := p.baseURL() + "/login?service=" + url.QueryEscape(casServiceURL(state))
+	if state.Reauth {
+		// renew=true 要求重新输入凭据，校验票据时同样携带，由CAS拒绝单点会话签发的票据
+		loginURL += "&renew=true"
+	}
+	return loginURL, nil
+}
+
+// Authenticate 向CAS服务端校验票据
+func (p *casProvider) Authenticate(state *models.SSOLoginState, req *models.SSOCallbackRequest) (*models.ExternalIdentity, error) {
+	if req.Ticket == "" {
+		return nil, fmt.Errorf("cas ticket is required")
+	}
+
+	params := url.Values{}
+	params.Set("service", casServiceURL(state))
+	params.Set("ticket", req.Ticket)
+	if state.Reauth {
+		params.Set("renew", "true")
+	}
+
+	resp, err := p.client.Get(p.baseURL() + "/p3/serviceValidate?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("cas validation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cas validation returned %d", resp.StatusCode)
+	}
+
+	var result casServiceResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid cas response: %w", err)
+	}
+	if result.Failure != nil {
+		return nil, fmt.Errorf("cas authentication failed: %s %s", result.Failure.Code, strings.TrimSpace(result.Failure.Message))
+	}
+	if result.Success == nil {
+		return nil, fmt.Errorf("invalid cas response: no authentication result")
+	}
+
+	// 多值属性合并为数组
+	attributes := make(map[string]interface{})
+	for _, attr := range result.Success.Attributes.Values {
+		name := attr.XMLName.Local
+		value := strings.TrimSpace(attr.Value)
+		switch existing := attributes[name].(type) {
+		case nil:
+			attributes[name] = value
+		case string:
+			attributes[name] = []interface{}{existing, value}
+		case []interface{}:
+			attributes[name] = append(existing, value)
+		}
+	}
+
+	user := strings.TrimSpace(result.Success.User)
+	identity := &models.ExternalIdentity{
+		Subject:    user,
+		Username:   user,
+		Attributes: attributes,
+		// 校园CAS签发的邮箱视为已验证
+		EmailVerified: true,
+	}
+	identity.Email = firstAttribute(attributes, "mail", "email")
+	identity.Name = firstAttribute(attributes, "displayName", "name", "cn")
+	if identity.Email == "" {
+		identity.EmailVerified = false
+	}
+	return identity, nil
+}
+
+func (p *casProvider) baseURL() string {
+	return strings.TrimRight(p.provider.CASBaseURL, "/")
+}
+
+// casServiceURL CAS要求校验时的service与登录时完全一致
+func casServiceURL(state *models.SSOLoginState) string {
+	separator := "?"
+	if strings.Contains(state.RedirectURI, "?") {
+		separator = "&"
+	}
+	return state.RedirectURI + separator + "state=" + url.QueryEscape(state.ID)
+}
+
+func firstAttribute(attributes map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if values := claimStrings(attributes[name]); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
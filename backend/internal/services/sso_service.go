@@ -0,0 +1,565 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	ssoStateTTL        = 10 * time.Minute
+	ssoHTTPTimeout     = 10 * time.Second
+	ssoDefaultScopes   = "openid email profile"
+	ssoCallbackPath    = "/auth/sso/callback"
+	ssoPlaceholderMail = "sso.invalid" // IdP未提供邮箱时使用的不可投递域名
+)
+
+var (
+	ErrSSOProviderNotFound   = errors.New("identity provider not found or inactive")
+	ErrSSOStateInvalid       = errors.New("sso state expired or invalid")
+	ErrSSORedirectNotAllowed = errors.New("redirect uri is not allowed")
+	ErrSSOAccountNotLinked   = errors.New("external identity is not linked to any account")
+	ErrSSOIdentityInUse      = errors.New("external identity is already linked to another account")
+	ErrSSOLastIdentity       = errors.New("cannot unlink the only sign-in method of this account")
+	ErrSSOReauthMismatch     = errors.New("re-authenticated identity is not linked to this account")
+)
+
+var ssoUsernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// ExternalIdentityProvider 外部身份协议实现（OIDC、CAS）
+type ExternalIdentityProvider interface {
+	// AuthorizationURL 生成跳转到身份提供方的登录地址
+	AuthorizationURL(state *models.SSOLoginState) (string, error)
+	// Authenticate 校验回调参数（授权码或票据）并返回外部身份
+	Authenticate(state *models.SSOLoginState, req *models.SSOCallbackRequest) (*models.ExternalIdentity, error)
+}
+
+// SSOService 校园统一身份认证服务
+type SSOService struct {
+	db         *gorm.DB
+	config     *config.Config
+	httpClient *http.Client
+	oidcCache  *oidcCache
+}
+
+// NewSSOService 创建校园统一身份认证服务
+func NewSSOService(db *gorm.DB, config *config.Config) *SSOService {
+	return &SSOService{
+		db:         db,
+		config:     config,
+		httpClient: &http.Client{Timeout: ssoHTTPTimeout},
+		oidcCache:  newOIDCCache(),
+	}
+}
+
+// ListProviders 获取身份提供方列表
+func (s *SSOService) ListProviders(activeOnly bool) ([]models.IdentityProvider, error) {
+	var providers []models.IdentityProvider
+	query := s.db.Order("name ASC")
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	if err := query.Find(&providers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list identity providers: %w", err)
+	}
+	for i := range providers {
+		providers[i].ClientSecret = ""
+	}
+	return providers, nil
+}
+
+// CreateProvider 创建身份提供方
+func (s *SSOService) CreateProvider(provider *models.IdentityProvider) error {
+	if err := validateIdentityProvider(provider); err != nil {
+		return err
+	}
+	provider.ID = uuid.New().String()
+	if err := s.db.Create(provider).Error; err != nil {
+		return fmt.Errorf("failed to create identity provider: %w", err)
+	}
+	provider.ClientSecret = ""
+	return nil
+}
+
+// UpdateProvider 更新身份提供方，ClientSecret为空时保留原值
+func (s *SSOService) UpdateProvider(id string, update *models.IdentityProvider) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	if err := s.db.First(&provider, "id = ?", id).Error; err != nil {
+		return nil, ErrSSOProviderNotFound
+	}
+
+	update.ID = provider.ID
+	update.CreatedAt = provider.CreatedAt
+	if update.ClientSecret == "" {
+		update.ClientSecret = provider.ClientSecret
+	}
+	if err := validateIdentityProvider(update); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(update).Error; err != nil {
+		return nil, fmt.Errorf("failed to update identity provider: %w", err)
+	}
+	s.oidcCache.invalidate(update.Issuer)
+
+	update.ClientSecret = ""
+	return update, nil
+}
+
+// BeginLogin 生成单点登录跳转地址；linkUserID非空时为已登录用户关联外部身份
+func (s *SSOService) BeginLogin(slug, redirectURI, linkUserID string) (*models.SSOAuthorizeResponse, error) {
+	return s.beginLogin(slug, redirectURI, linkUserID, false)
+}
+
+// BeginReauth 已登录用户在敏感操作前到身份提供方重新认证，身份提供方不得复用已有登录会话
+func (s *SSOService) BeginReauth(slug, redirectURI, userID string) (*models.SSOAuthorizeResponse, error) {
+	return s.beginLogin(slug, redirectURI, userID, true)
+}
+
+func (s *SSOService) beginLogin(slug, redirectURI, linkUserID string, reauth bool) (*models.SSOAuthorizeResponse, error) {
+	provider, err := s.getActiveProvider(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if redirectURI == "" {
+		redirectURI = strings.TrimRight(s.config.FrontendURL, "/") + ssoCallbackPath
+	}
+	if !s.isAllowedRedirect(redirectURI) {
+		return nil, ErrSSORedirectNotAllowed
+	}
+
+	stateID, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &models.SSOLoginState{
+		ID:           stateID,
+		ProviderID:   provider.ID,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		RedirectURI:  redirectURI,
+		LinkUserID:   linkUserID,
+		Reauth:       reauth,
+		ExpiresAt:    time.Now().Add(ssoStateTTL),
+	}
+	if err := s.db.Create(state).Error; err != nil {
+		return nil, fmt.Errorf("failed to create sso state: %w", err)
+	}
+
+	authURL, err := s.protocolFor(provider).AuthorizationURL(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SSOAuthorizeResponse{
+		AuthorizationURL: authURL,
+		State:            state.ID,
+		ExpiresAt:        state.ExpiresAt,
+	}, nil
+}
+
+// CompleteLogin 校验回调并返回对应的本地账号（必要时关联或自动创建）
+func (s *SSOService) CompleteLogin(req *models.SSOCallbackRequest) (*models.User, error) {
+	provider, identity, err := s.authenticate(req, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveUser(provider, identity)
+}
+
+// CompleteLink 已登录用户完成外部身份关联
+func (s *SSOService) CompleteLink(userID string, req *models.SSOCallbackRequest) (*models.UserIdentity, error) {
+	provider, identity, err := s.authenticate(req, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.UserIdentity
+	err = s.db.Where("provider_id = ? AND subject = ?", provider.ID, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, ErrSSOIdentityInUse
+		}
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query identity: %w", err)
+	}
+
+	return s.linkIdentity(userID, provider, identity)
+}
+
+// VerifyReauth 校验重新认证的回调，外部身份必须已关联到该用户
+func (s *SSOService) VerifyReauth(userID string, req *models.SSOCallbackRequest) error {
+	provider, identity, err := s.authenticate(req, userID, true)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	s.db.Model(&models.UserIdentity{}).
+		Where("provider_id = ? AND subject = ? AND user_id = ?", provider.ID, identity.Subject, userID).
+		Count(&count)
+	if count == 0 {
+		return ErrSSOReauthMismatch
+	}
+	return nil
+}
+
+// GetUserIdentities 获取用户已关联的外部身份
+func (s *SSOService) GetUserIdentities(userID string) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := s.db.Preload("Provider").Where("user_id = ?", userID).
+		Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	for i := range identities {
+		if identities[i].Provider != nil {
+			identities[i].Provider.ClientSecret = ""
+		}
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity 解除外部身份关联；自动创建的账号不能解除唯一的登录方式
+func (s *SSOService) UnlinkIdentity(userID, identityID string) error {
+	var identity models.UserIdentity
+	if err := s.db.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+		return ErrSSOAccountNotLinked
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "sso_only").First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if identity.AutoProvisioned || user.SSOOnly {
+		var count int64
+		s.db.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count)
+		if count <= 1 {
+			return ErrSSOLastIdentity
+		}
+	}
+
+	return s.db.Delete(&identity).Error
+}
+
+// CleanupExpiredStates 清理过期的登录状态
+func (s *SSOService) CleanupExpiredStates() (int64, error) {
+	result := s.db.Where("expires_at < ?", time.Now()).Delete(&models.SSOLoginState{})
+	return result.RowsAffected, result.Error
+}
+
+// authenticate 消费一次性state并向身份提供方校验回调
+// 关联流程的state必须由发起关联的同一已登录用户完成，防止登录CSRF；重新认证的state不能用于登录或关联
+func (s *SSOService) authenticate(req *models.SSOCallbackRequest, linkUserID string, reauth bool) (*models.IdentityProvider, *models.ExternalIdentity, error) {
+	var state models.SSOLoginState
+	if err := s.db.First(&state, "id = ?", req.State).Error; err != nil {
+		return nil, nil, ErrSSOStateInvalid
+	}
+	if state.UsedAt != nil || time.Now().After(state.ExpiresAt) || state.LinkUserID != linkUserID || state.Reauth != reauth {
+		return nil, nil, ErrSSOStateInvalid
+	}
+
+	// 条件更新保证state只能被消费一次
+	now := time.Now()
+	result := s.db.Model(&models.SSOLoginState{}).
+		Where("id = ? AND used_at IS NULL", state.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("failed to consume sso state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrSSOStateInvalid
+	}
+
+	var provider models.IdentityProvider
+	if err := s.db.First(&provider, "id = ? AND is_active = ?", state.ProviderID, true).Error; err != nil {
+		return nil, nil, ErrSSOProviderNotFound
+	}
+
+	identity, err := s.protocolFor(&provider).Authenticate(&state, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity.Subject == "" {
+		return nil, nil, fmt.Errorf("identity provider returned an empty subject")
+	}
+
+	return &provider, identity, nil
+}
+
+// resolveUser 按已关联身份、已验证邮箱、自动创建的顺序确定本地账号
+func (s *SSOService) resolveUser(provider *models.IdentityProvider, identity *models.ExternalIdentity) (*models.User, error) {
+	var link models.UserIdentity
+	err := s.db.Where("provider_id = ? AND subject = ?", provider.ID, identity.Subject).First(&link).Error
+	if err == nil {
+		var user models.User
+		if err := s.db.First(&user, "id = ?", link.UserID).Error; err != nil {
+			return nil, ErrSSOAccountNotLinked
+		}
+		now := time.Now()
+		s.db.Model(&link).Updates(map[string]interface{}{"last_login_at": now, "email": identity.Email})
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query identity: %w", err)
+	}
+
+	if provider.LinkByEmail && identity.EmailVerified && identity.Email != "" {
+		var user models.User
+		if err := s.db.Where("LOWER(email) = ?", strings.ToLower(identity.Email)).First(&user).Error; err == nil {
+			if _, err := s.linkIdentity(user.ID, provider, identity); err != nil {
+				return nil, err
+			}
+			return &user, nil
+		}
+	}
+
+	if !provider.AutoProvision {
+		return nil, ErrSSOAccountNotLinked
+	}
+	return s.provisionUser(provider, identity)
+}
+
+// provisionUser 根据外部身份自动创建账号
+func (s *SSOService) provisionUser(provider *models.IdentityProvider, identity *models.ExternalIdentity) (*models.User, error) {
+	schoolCode := s.ResolveSchoolCode(provider, identity)
+	if schoolCode == "" {
+		return nil, fmt.Errorf("unable to determine school for external identity")
+	}
+
+	email := identity.Email
+	if email == "" {
+		email = fmt.Sprintf("%s@%s.%s", ssoUsernameSanitizer.ReplaceAllString(identity.Subject, "_"), provider.Slug, ssoPlaceholderMail)
+	}
+	var count int64
+	s.db.Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(email)).Count(&count)
+	if count > 0 {
+		// 同邮箱账号存在但未允许自动关联，需要用户登录后手动关联
+		return nil, ErrSSOAccountNotLinked
+	}
+
+	username, err := s.uniqueUsername(provider, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 自动创建的账号没有可用的本地密码，敏感操作改用两步验证或重新单点登录确认身份
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), s.config.BCryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	nickname := identity.Name
+	if nickname == "" {
+		nickname = username
+	}
+	if len([]rune(nickname)) > 50 {
+		nickname = string([]rune(nickname)[:50])
+	}
+
+	user := &models.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+		Nickname:     nickname,
+		Role:         models.RoleUser,
+		SchoolCode:   schoolCode,
+		IsActive:     true,
+		SSOOnly:      true,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		now := time.Now()
+		return tx.Create(&models.UserIdentity{
+			ID:              uuid.New().String(),
+			UserID:          user.ID,
+			ProviderID:      provider.ID,
+			Subject:         identity.Subject,
+			Email:           identity.Email,
+			AutoProvisioned: true,
+			LastLoginAt:     &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResolveSchoolCode 根据身份属性映射学校代码，未命中时使用提供方默认学校
+func (s *SSOService) ResolveSchoolCode(provider *models.IdentityProvider, identity *models.ExternalIdentity) string {
+	if provider.SchoolClaim != "" && len(provider.SchoolMapping) > 0 {
+		var mapping map[string]string
+		if err := json.Unmarshal(provider.SchoolMapping, &mapping); err == nil {
+			for _, value := range claimStrings(identity.Attributes[provider.SchoolClaim]) {
+				if code, ok := mapping[value]; ok {
+					return code
+				}
+			}
+		}
+	}
+	return provider.SchoolCode
+}
+
+func (s *SSOService) linkIdentity(userID string, provider *models.IdentityProvider, identity *models.ExternalIdentity) (*models.UserIdentity, error) {
+	now := time.Now()
+	link := &models.UserIdentity{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		ProviderID:  provider.ID,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	return link, nil
+}
+
+func (s *SSOService) uniqueUsername(provider *models.IdentityProvider, identity *models.ExternalIdentity) (string, error) {
+	base := identity.Username
+	if base == "" && identity.Email != "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	if base == "" {
+		base = identity.Subject
+	}
+	base = strings.Trim(ssoUsernameSanitizer.ReplaceAllString(base, "_"), "_")
+	if len(base) < 3 {
+		base = provider.Slug + "_" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		s.db.Model(&models.User{}).Unscoped().Where("username = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate, nil
+		}
+		suffix, err := randomToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + strings.ToLower(ssoUsernameSanitizer.ReplaceAllString(suffix, ""))
+	}
+	return "", fmt.Errorf("failed to allocate username")
+}
+
+func (s *SSOService) getActiveProvider(slug string) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	if err := s.db.Where("slug = ? AND is_active = ?", slug, true).First(&provider).Error; err != nil {
+		return nil, ErrSSOProviderNotFound
+	}
+	return &provider, nil
+}
+
+func (s *SSOService) protocolFor(provider *models.IdentityProvider) ExternalIdentityProvider {
+	if provider.Type == models.IdentityProviderCAS {
+		return &casProvider{provider: provider, client: s.httpClient}
+	}
+	return &oidcProvider{provider: provider, client: s.httpClient, cache: s.oidcCache}
+}
+
+// isAllowedRedirect 回调地址只能指向本站前端或后端，防止授权码外泄
+func (s *SSOService) isAllowedRedirect(redirectURI string) bool {
+	target, err := url.Parse(redirectURI)
+	if err != nil || target.Host == "" {
+		return false
+	}
+	for _, allowed := range []string{s.config.FrontendURL, s.config.BaseURL} {
+		base, err := url.Parse(allowed)
+		if err != nil || base.Host == "" {
+			continue
+		}
+		if target.Scheme == base.Scheme && target.Host == base.Host {
+			return true
+		}
+	}
+	return false
+}
+
+func validateIdentityProvider(provider *models.IdentityProvider) error {
+	if provider.Slug == "" || provider.Name == "" {
+		return fmt.Errorf("slug and name are required")
+	}
+	switch provider.Type {
+	case models.IdentityProviderOIDC:
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("issuer and client_id are required for oidc providers")
+		}
+	case models.IdentityProviderCAS:
+		if provider.CASBaseURL == "" {
+			return fmt.Errorf("cas_base_url is required for cas providers")
+		}
+	default:
+		return fmt.Errorf("unsupported identity provider type: %s", provider.Type)
+	}
+	if len(provider.SchoolMapping) > 0 {
+		var mapping map[string]string
+		if err := json.Unmarshal(provider.SchoolMapping, &mapping); err != nil {
+			return fmt.Errorf("school_mapping must be an object of strings: %w", err)
+		}
+	}
+	return nil
+}
+
+// pkceChallenge 计算PKCE S256 code_challenge
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// claimStrings 将claim值（字符串或数组）统一为字符串列表
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
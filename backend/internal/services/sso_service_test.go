@@ -0,0 +1,335 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/internal/testutils"
+	"openpenpal-backend/pkg/auth"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SSOServiceTestSuite 校园单点登录服务测试套件
+type SSOServiceTestSuite struct {
+	suite.Suite
+	db          *gorm.DB
+	idp         *testutils.FakeIdP
+	service     *SSOService
+	userService *UserService
+}
+
+func (suite *SSOServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(
+		&models.UserSession{}, &models.RefreshToken{},
+		&models.UserTwoFactor{}, &models.TwoFactorRecoveryCode{}, &models.TrustedDevice{}, &models.LoginChallenge{},
+		&models.IdentityProvider{}, &models.UserIdentity{}, &models.SSOLoginState{},
+		&models.DataSubjectRequest{}, &models.DataRequestAuditLog{},
+	))
+	suite.db = db
+	suite.idp = testutils.NewFakeIdP(suite.T())
+
+	cfg := config.GetTestConfig()
+	suite.service = NewSSOService(db, cfg)
+	suite.userService = NewUserService(db, cfg)
+	suite.userService.SetSessionService(NewSessionService(db, cfg))
+	twoFactor, err := NewTwoFactorService(db, cfg)
+	suite.NoError(err)
+	suite.userService.SetTwoFactorService(twoFactor)
+}
+
+func (suite *SSOServiceTestSuite) createOIDCProvider(autoProvision, linkByEmail bool) *models.IdentityProvider {
+	provider := &models.IdentityProvider{
+		Slug:          "partner-oidc",
+		Name:          "Partner University",
+		Type:          models.IdentityProviderOIDC,
+		Issuer:        suite.idp.Issuer(),
+		ClientID:      suite.idp.ClientID,
+		ClientSecret:  suite.idp.ClientSecret,
+		SchoolCode:    "PKU001",
+		SchoolClaim:   "campus",
+		SchoolMapping: datatypes.JSON(`{"main":"PKU001","medical":"PKU002"}`),
+		AutoProvision: autoProvision,
+		LinkByEmail:   linkByEmail,
+		IsActive:      true,
+	}
+	suite.NoError(suite.service.CreateProvider(provider))
+	return provider
+}
+
+func (suite *SSOServiceTestSuite) oidcCallback(slug, linkUserID string, identity testutils.FakeIdentity) *models.SSOCallbackRequest {
+	authorize, err := suite.service.BeginLogin(slug, "", linkUserID)
+	suite.NoError(err)
+	suite.Contains(authorize.AuthorizationURL, "code_challenge_method=S256")
+
+	code, state, err := suite.idp.AuthorizeOIDC(authorize.AuthorizationURL, identity)
+	suite.NoError(err)
+	return &models.SSOCallbackRequest{State: state, Code: code}
+}
+
+// TestOIDC_AutoProvision 测试OIDC首次登录自动创建账号并按claim映射学校
+func (suite *SSOServiceTestSuite) TestOIDC_AutoProvision() {
+	suite.createOIDCProvider(true, false)
+	identity := testutils.FakeIdentity{
+		Subject:  "oidc-123",
+		Email:    "li.lei@partner.edu.cn",
+		Name:     "李雷",
+		Username: "lilei",
+		Claims:   map[string]interface{}{"campus": "medical"},
+	}
+
+	req := suite.oidcCallback("partner-oidc", "", identity)
+	user, err := suite.service.CompleteLogin(req)
+	suite.NoError(err)
+	suite.Equal("lilei", user.Username)
+	suite.Equal("PKU002", user.SchoolCode)
+
+	// state只能使用一次
+	_, err = suite.service.CompleteLogin(req)
+	suite.ErrorIs(err, ErrSSOStateInvalid)
+
+	// 再次登录命中已关联身份
+	again, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", identity))
+	suite.NoError(err)
+	suite.Equal(user.ID, again.ID)
+
+	resp, err := suite.userService.LoginWithExternalIdentity(again, &models.SessionDeviceInfo{}, "")
+	suite.NoError(err)
+	suite.NotEmpty(resp.Token)
+
+	// 自动创建账号的唯一登录方式不能解除
+	identities, err := suite.service.GetUserIdentities(user.ID)
+	suite.NoError(err)
+	suite.Len(identities, 1)
+	suite.ErrorIs(suite.service.UnlinkIdentity(user.ID, identities[0].ID), ErrSSOLastIdentity)
+}
+
+// TestOIDC_LinkByVerifiedEmail 测试仅在邮箱已验证时自动关联已有账号
+func (suite *SSOServiceTestSuite) TestOIDC_LinkByVerifiedEmail() {
+	suite.createOIDCProvider(false, true)
+	existing := config.CreateTestUser(suite.db, "hanmeimei", models.RoleUser)
+
+	_, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", testutils.FakeIdentity{
+		Subject: "oidc-unverified",
+		Email:   existing.Email,
+	}))
+	suite.ErrorIs(err, ErrSSOAccountNotLinked)
+
+	user, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", testutils.FakeIdentity{
+		Subject:       "oidc-verified",
+		Email:         existing.Email,
+		EmailVerified: true,
+	}))
+	suite.NoError(err)
+	suite.Equal(existing.ID, user.ID)
+}
+
+// TestOIDC_LinkRequiresSameUser 测试关联流程只能由发起的已登录用户完成
+func (suite *SSOServiceTestSuite) TestOIDC_LinkRequiresSameUser() {
+	suite.createOIDCProvider(false, false)
+	owner := config.CreateTestUser(suite.db, "owneruser", models.RoleUser)
+	other := config.CreateTestUser(suite.db, "otheruser", models.RoleUser)
+	identity := testutils.FakeIdentity{Subject: "oidc-link"}
+
+	// 关联state不能用于登录
+	_, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", owner.ID, identity))
+	suite.ErrorIs(err, ErrSSOStateInvalid)
+
+	_, err = suite.service.CompleteLink(other.ID, suite.oidcCallback("partner-oidc", owner.ID, identity))
+	suite.ErrorIs(err, ErrSSOStateInvalid)
+
+	link, err := suite.service.CompleteLink(owner.ID, suite.oidcCallback("partner-oidc", owner.ID, identity))
+	suite.NoError(err)
+	suite.Equal(owner.ID, link.UserID)
+
+	_, err = suite.service.CompleteLink(other.ID, suite.oidcCallback("partner-oidc", other.ID, identity))
+	suite.ErrorIs(err, ErrSSOIdentityInUse)
+
+	user, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", identity))
+	suite.NoError(err)
+	suite.Equal(owner.ID, user.ID)
+}
+
+// TestCAS_TicketValidation 测试CAS票据校验与属性映射
+func (suite *SSOServiceTestSuite) TestCAS_TicketValidation() {
+	suite.NoError(suite.service.CreateProvider(&models.IdentityProvider{
+		Slug:          "campus-cas",
+		Name:          "校园统一身份认证",
+		Type:          models.IdentityProviderCAS,
+		CASBaseURL:    suite.idp.CASBaseURL(),
+		SchoolCode:    "BJDX01",
+		SchoolClaim:   "ou",
+		SchoolMapping: datatypes.JSON(`{"深圳研究生院":"BJDX02"}`),
+		AutoProvision: true,
+		IsActive:      true,
+	}))
+
+	authorize, err := suite.service.BeginLogin("campus-cas", "", "")
+	suite.NoError(err)
+	identity := testutils.FakeIdentity{
+		Subject: "2024010001",
+		Email:   "2024010001@stu.example.edu.cn",
+		Name:    "王芳",
+		Claims:  map[string]interface{}{"ou": "深圳研究生院"},
+	}
+
+	ticket, state, err := suite.idp.LoginCAS(authorize.AuthorizationURL, identity)
+	suite.NoError(err)
+	suite.Equal(authorize.State, state)
+
+	user, err := suite.service.CompleteLogin(&models.SSOCallbackRequest{State: state, Ticket: ticket})
+	suite.NoError(err)
+	suite.Equal("BJDX02", user.SchoolCode)
+	suite.Equal("2024010001", user.Username)
+
+	// 票据只能校验一次
+	authorize, err = suite.service.BeginLogin("campus-cas", "", "")
+	suite.NoError(err)
+	_, err = suite.service.CompleteLogin(&models.SSOCallbackRequest{State: authorize.State, Ticket: ticket})
+	suite.Error(err)
+}
+
+// TestBeginLogin_RejectsForeignRedirect 测试回调地址必须属于本站
+func (suite *SSOServiceTestSuite) TestBeginLogin_RejectsForeignRedirect() {
+	suite.createOIDCProvider(true, false)
+
+	_, err := suite.service.BeginLogin("partner-oidc", "https://evil.example.com/callback", "")
+	suite.ErrorIs(err, ErrSSORedirectNotAllowed)
+}
+
+// withoutParams 模拟忽略重新认证参数、复用已有会话的身份提供方
+func withoutParams(rawURL string, names ...string) string {
+	parsed, _ := url.Parse(rawURL)
+	query := parsed.Query()
+	for _, name := range names {
+		query.Del(name)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// TestReauth_SSOOnlyErasure 测试自动创建的账号通过重新单点登录申请删除账户
+func (suite *SSOServiceTestSuite) TestReauth_SSOOnlyErasure() {
+	suite.createOIDCProvider(true, false)
+	identity := testutils.FakeIdentity{Subject: "oidc-erase", Email: "erase@partner.edu.cn", Username: "eraseme"}
+	user, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", identity))
+	suite.NoError(err)
+	suite.True(user.SSOOnly)
+
+	_, err = suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", testutils.FakeIdentity{
+		Subject: "oidc-other", Email: "other@partner.edu.cn", Username: "other",
+	}))
+	suite.NoError(err)
+
+	dataRequests := NewDataRequestService(suite.db, config.GetTestConfig())
+	dataRequests.SetSSOService(suite.service)
+
+	// 没有本地密码，密码无法作为重新认证
+	_, err = dataRequests.RequestErasure(user.ID, &models.CreateErasureRequest{Password: "anything"}, "127.0.0.1")
+	suite.EqualError(err, "re-authentication failed")
+
+	// 普通登录的state不能充当重新认证
+	_, err = dataRequests.RequestErasure(user.ID, &models.CreateErasureRequest{
+		SSOReauth: suite.oidcCallback("partner-oidc", user.ID, identity),
+	}, "127.0.0.1")
+	suite.EqualError(err, "re-authentication failed")
+
+	reauth := func(ignoreParams bool, as testutils.FakeIdentity) *models.SSOCallbackRequest {
+		authorize, err := suite.service.BeginReauth("partner-oidc", "", user.ID)
+		suite.NoError(err)
+		suite.Contains(authorize.AuthorizationURL, "prompt=login")
+		authURL := authorize.AuthorizationURL
+		if ignoreParams {
+			authURL = withoutParams(authURL, "prompt", "max_age")
+		}
+		code, state, err := suite.idp.AuthorizeOIDC(authURL, as)
+		suite.NoError(err)
+		return &models.SSOCallbackRequest{State: state, Code: code}
+	}
+
+	// 身份提供方复用旧会话、他人的校园账号都不算重新认证
+	suite.Error(suite.service.VerifyReauth(user.ID, reauth(true, identity)))
+	suite.ErrorIs(suite.service.VerifyReauth(user.ID, reauth(false, testutils.FakeIdentity{Subject: "oidc-other"})), ErrSSOReauthMismatch)
+
+	request, err := dataRequests.RequestErasure(user.ID, &models.CreateErasureRequest{SSOReauth: reauth(false, identity)}, "127.0.0.1")
+	suite.NoError(err)
+	suite.Equal(models.DataRequestStatusScheduled, request.Status)
+}
+
+// TestReauth_CASRenew 测试CAS重新认证要求renew签发的票据
+func (suite *SSOServiceTestSuite) TestReauth_CASRenew() {
+	suite.NoError(suite.service.CreateProvider(&models.IdentityProvider{
+		Slug:          "campus-cas",
+		Name:          "校园统一身份认证",
+		Type:          models.IdentityProviderCAS,
+		CASBaseURL:    suite.idp.CASBaseURL(),
+		SchoolCode:    "BJDX01",
+		AutoProvision: true,
+		IsActive:      true,
+	}))
+	identity := testutils.FakeIdentity{Subject: "2024010002", Name: "张伟"}
+
+	authorize, err := suite.service.BeginLogin("campus-cas", "", "")
+	suite.NoError(err)
+	ticket, state, err := suite.idp.LoginCAS(authorize.AuthorizationURL, identity)
+	suite.NoError(err)
+	user, err := suite.service.CompleteLogin(&models.SSOCallbackRequest{State: state, Ticket: ticket})
+	suite.NoError(err)
+
+	// 单点会话签发的票据被拒绝
+	authorize, err = suite.service.BeginReauth("campus-cas", "", user.ID)
+	suite.NoError(err)
+	ticket, state, err = suite.idp.LoginCAS(withoutParams(authorize.AuthorizationURL, "renew"), identity)
+	suite.NoError(err)
+	suite.Error(suite.service.VerifyReauth(user.ID, &models.SSOCallbackRequest{State: state, Ticket: ticket}))
+
+	authorize, err = suite.service.BeginReauth("campus-cas", "", user.ID)
+	suite.NoError(err)
+	suite.Contains(authorize.AuthorizationURL, "renew=true")
+	ticket, state, err = suite.idp.LoginCAS(authorize.AuthorizationURL, identity)
+	suite.NoError(err)
+	suite.NoError(suite.service.VerifyReauth(user.ID, &models.SSOCallbackRequest{State: state, Ticket: ticket}))
+}
+
+// TestReauth_SSOOnlyTwoFactor 测试自动创建的账号凭两步验证码删除账户、关闭两步验证
+func (suite *SSOServiceTestSuite) TestReauth_SSOOnlyTwoFactor() {
+	suite.createOIDCProvider(true, false)
+	user, err := suite.service.CompleteLogin(suite.oidcCallback("partner-oidc", "", testutils.FakeIdentity{
+		Subject: "oidc-2fa", Email: "twofa@partner.edu.cn", Username: "twofa",
+	}))
+	suite.NoError(err)
+
+	twoFactor, err := NewTwoFactorService(suite.db, config.GetTestConfig())
+	suite.NoError(err)
+	codeAt := func(secret string, offset int) string {
+		code, err := auth.GenerateTOTPCode(secret, time.Now().Add(time.Duration(offset)*auth.TOTPPeriod*time.Second))
+		suite.NoError(err)
+		return code
+	}
+	enrollment, err := twoFactor.BeginEnrollment(user.ID)
+	suite.NoError(err)
+	_, err = twoFactor.ConfirmEnrollment(user.ID, codeAt(enrollment.Secret, -1))
+	suite.NoError(err)
+
+	dataRequests := NewDataRequestService(suite.db, config.GetTestConfig())
+	dataRequests.SetTwoFactorService(twoFactor)
+
+	_, err = dataRequests.RequestErasure(user.ID, &models.CreateErasureRequest{TwoFactorCode: "000000"}, "127.0.0.1")
+	suite.EqualError(err, "re-authentication failed")
+	_, err = dataRequests.RequestErasure(user.ID, &models.CreateErasureRequest{TwoFactorCode: codeAt(enrollment.Secret, 0)}, "127.0.0.1")
+	suite.NoError(err)
+
+	// 无需本地密码即可关闭两步验证
+	suite.NoError(twoFactor.Disable(user.ID, "", codeAt(enrollment.Secret, 1)))
+}
+
+func TestSSOServiceSuite(t *testing.T) {
+	suite.Run(t, new(SSOServiceTestSuite))
+}
@@ -271,6 +271,42 @@ func (s *StorageService) DeleteFile(fileID, userID string) error {
 	return nil
 }
 
+// OpenFileContent 读取文件内容（用于个人数据导出）
+func (s *StorageService) OpenFileContent(file *models.StorageFile) (io.ReadCloser, error) {
+	provider, err := s.getProviderByType(file.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储提供商失败: %w", err)
+	}
+	return s.createStorageProvider(provider).Download(file.ObjectKey)
+}
+
+// PurgeUserFiles 彻底删除用户上传的全部文件（对象与记录均删除，用于账户删除）
+func (s *StorageService) PurgeUserFiles(userID string) (int64, error) {
+	var files []models.StorageFile
+	if err := s.db.Unscoped().Where("uploaded_by = ?", userID).Find(&files).Error; err != nil {
+		return 0, fmt.Errorf("查询用户文件失败: %w", err)
+	}
+
+	var purged int64
+	for _, file := range files {
+		if provider, err := s.getProviderByType(file.Provider); err == nil {
+			if err := s.createStorageProvider(provider).Delete(file.ObjectKey); err != nil {
+				s.recordOperation(file.ID, "purge", userID, "failed", 0, 0, err.Error())
+			}
+			if file.Status != models.FileStatusDeleted {
+				s.updateStorageUsage(provider.ID, -file.FileSize)
+			}
+		}
+
+		if err := s.db.Unscoped().Delete(&file).Error; err != nil {
+			return purged, fmt.Errorf("删除文件记录失败: %w", err)
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
 // GetStorageStats 获取存储统计信息
 func (s *StorageService) GetStorageStats() (*models.StorageStats, error) {
 	stats := &models.StorageStats{
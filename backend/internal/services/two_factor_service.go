@@ -0,0 +1,524 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/auth"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer        = "OpenPenPal"
+	twoFactorSkew          = 1 // 允许前后各一个30秒窗口
+	recoveryCodeCount      = 10
+	loginChallengeTTL      = 5 * time.Minute
+	loginChallengeAttempts = 5
+	trustedDeviceTTL       = 30 * 24 * time.Hour
+)
+
+var (
+	ErrTwoFactorInvalidCode   = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnabled    = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyActive = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired      = errors.New("two-factor authentication is required for this role")
+	ErrLoginChallengeInvalid  = errors.New("login challenge expired or invalid")
+)
+
+// devTwoFactorKeyID 由 JWT 密钥派生的密钥，未配置加密密钥时使用；早期未记录密钥ID的密文也由它加密
+const devTwoFactorKeyID = "dev"
+
+// TwoFactorService 两步验证服务：TOTP绑定、恢复码、登录挑战与记住设备
+type TwoFactorService struct {
+	db        *gorm.DB
+	config    *config.Config
+	keys      map[string]cipher.AEAD
+	activeKey string
+}
+
+// NewTwoFactorService 创建两步验证服务实例，按配置加载TOTP密钥的加密密钥
+func NewTwoFactorService(db *gorm.DB, config *config.Config) (*TwoFactorService, error) {
+	s := &TwoFactorService{
+		db:     db,
+		config: config,
+		keys:   make(map[string]cipher.AEAD),
+	}
+
+	// 派生密钥始终保留用于解密旧密文，新密文只在未配置密钥时使用它
+	devKey := sha256.Sum256([]byte("openpenpal-totp:" + config.JWTSecret))
+	if err := s.addKey(devTwoFactorKeyID, devKey[:]); err != nil {
+		return nil, err
+	}
+	if config.TwoFactorEncryptionKeys == "" {
+		if config.Environment == "production" {
+			log.Printf("Warning: TWO_FACTOR_ENCRYPTION_KEYS not configured, deriving two-factor encryption key from JWT secret")
+		}
+		s.activeKey = devTwoFactorKeyID
+	}
+	for _, entry := range strings.Split(config.TwoFactorEncryptionKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, encoded, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" || kid == devTwoFactorKeyID || len(kid) > 32 {
+			return nil, fmt.Errorf("invalid TWO_FACTOR_ENCRYPTION_KEYS entry %q, expected kid:key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid two-factor encryption key %s, expected 32 bytes base64", kid)
+		}
+		if err := s.addKey(kid, key); err != nil {
+			return nil, err
+		}
+		if s.activeKey == "" {
+			s.activeKey = kid
+		}
+	}
+	if config.TwoFactorEncryptionKeyID != "" {
+		if _, ok := s.keys[config.TwoFactorEncryptionKeyID]; !ok {
+			return nil, fmt.Errorf("two-factor encryption key %s not configured", config.TwoFactorEncryptionKeyID)
+		}
+		s.activeKey = config.TwoFactorEncryptionKeyID
+	}
+
+	return s, nil
+}
+
+// IsRequiredForRole 管理员与三、四级信使可调配OP Code和积分，强制启用两步验证
+func (s *TwoFactorService) IsRequiredForRole(role models.UserRole) bool {
+	switch role {
+	case models.RoleSuperAdmin, models.RolePlatformAdmin, models.RoleCourierLevel3, models.RoleCourierLevel4:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsEnabled 检查用户是否已启用两步验证
+func (s *TwoFactorService) IsEnabled(userID string) bool {
+	var tf models.UserTwoFactor
+	if err := s.db.First(&tf, "user_id = ?", userID).Error; err != nil {
+		return false
+	}
+	return tf.IsEnabled()
+}
+
+// GetStatus 获取两步验证状态
+func (s *TwoFactorService) GetStatus(userID string, role models.UserRole) (*models.TwoFactorStatus, error) {
+	status := &models.TwoFactorStatus{Required: s.IsRequiredForRole(role)}
+
+	var tf models.UserTwoFactor
+	if err := s.db.First(&tf, "user_id = ?", userID).Error; err == nil && tf.IsEnabled() {
+		status.Enabled = true
+		status.EnabledAt = tf.EnabledAt
+	}
+
+	s.db.Model(&models.TwoFactorRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&status.RecoveryCodesRemaining)
+	s.db.Model(&models.TrustedDevice{}).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Count(&status.TrustedDevices)
+	return status, nil
+}
+
+// BeginEnrollment 生成新的TOTP密钥，需调用 ConfirmEnrollment 验证后才会启用
+func (s *TwoFactorService) BeginEnrollment(userID string) (*models.TwoFactorEnrollment, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	var existing models.UserTwoFactor
+	if err := s.db.First(&existing, "user_id = ?", userID).Error; err == nil && existing.IsEnabled() {
+		return nil, ErrTwoFactorAlreadyActive
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, keyID, err := s.encryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &models.UserTwoFactor{
+		UserID:          userID,
+		SecretEncrypted: encrypted,
+		SecretKeyID:     keyID,
+	}
+	if err := s.db.Save(tf).Error; err != nil {
+		return nil, fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	uri := auth.TOTPProvisioningURI(twoFactorIssuer, account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmEnrollment 校验验证码后启用两步验证，返回一次性恢复码
+func (s *TwoFactorService) ConfirmEnrollment(userID, code string) ([]string, error) {
+	var tf models.UserTwoFactor
+	if err := s.db.First(&tf, "user_id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("two-factor enrollment not started")
+	}
+	if tf.IsEnabled() {
+		return nil, ErrTwoFactorAlreadyActive
+	}
+	if err := s.verifyTOTP(&tf, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&tf).Update("enabled_at", &now).Error; err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+	return s.generateRecoveryCodes(userID)
+}
+
+// Disable 关闭两步验证，需同时验证密码和验证码
+func (s *TwoFactorService) Disable(userID, password, code string) error {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if s.IsRequiredForRole(user.Role) {
+		return ErrTwoFactorRequired
+	}
+	// 自动创建的校园账号没有本地密码，仅凭验证码确认身份
+	if !user.SSOOnly {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return fmt.Errorf("password is incorrect")
+		}
+	}
+	if err := s.VerifyCode(userID, code); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserTwoFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.TrustedDevice{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := s.VerifyCode(userID, code); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(userID)
+}
+
+// VerifyCode 校验TOTP验证码（同一验证码只能使用一次）
+func (s *TwoFactorService) VerifyCode(userID, code string) error {
+	var tf models.UserTwoFactor
+	if err := s.db.First(&tf, "user_id = ?", userID).Error; err != nil || !tf.IsEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+	return s.verifyTOTP(&tf, code)
+}
+
+// CreateChallenge 密码验证通过后创建第二步登录挑战
+func (s *TwoFactorService) CreateChallenge(userID string, challengeType models.LoginChallengeType, device *models.SessionDeviceInfo) (*models.LoginChallenge, error) {
+	challenge := &models.LoginChallenge{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Type:      challengeType,
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	}
+	if device != nil {
+		challenge.DeviceID = device.DeviceID
+		challenge.DeviceName = device.DeviceName
+		challenge.UserAgent = device.UserAgent
+		challenge.IPAddress = device.IPAddress
+	}
+	if err := s.db.Create(challenge).Error; err != nil {
+		return nil, fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// ChallengeEnrollment 强制绑定场景：凭登录挑战获取TOTP绑定信息
+func (s *TwoFactorService) ChallengeEnrollment(challengeID string) (*models.TwoFactorEnrollment, error) {
+	challenge, err := s.activeChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Type != models.LoginChallengeSetup {
+		return nil, ErrLoginChallengeInvalid
+	}
+	return s.BeginEnrollment(challenge.UserID)
+}
+
+// CompleteChallenge 校验第二步验证码，成功后挑战作废
+// 强制绑定场景下同时启用两步验证并返回恢复码
+func (s *TwoFactorService) CompleteChallenge(req *models.LoginChallengeRequest) (*models.LoginChallenge, []string, error) {
+	challenge, err := s.activeChallenge(req.ChallengeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 先计数再校验，防止并发请求绕过次数限制
+	result := s.db.Model(&models.LoginChallenge{}).
+		Where("id = ? AND attempts < ?", challenge.ID, loginChallengeAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, ErrLoginChallengeInvalid
+	}
+
+	var recoveryCodes []string
+	switch challenge.Type {
+	case models.LoginChallengeSetup:
+		recoveryCodes, err = s.ConfirmEnrollment(challenge.UserID, req.Code)
+	default:
+		if req.RecoveryCode != "" {
+			err = s.useRecoveryCode(challenge.UserID, req.RecoveryCode)
+		} else {
+			err = s.VerifyCode(challenge.UserID, req.Code)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	result = s.db.Model(&models.LoginChallenge{}).Where("id = ? AND used_at IS NULL", challenge.ID).Update("used_at", &now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, ErrLoginChallengeInvalid
+	}
+	return challenge, recoveryCodes, nil
+}
+
+// RememberDevice 记住当前设备，返回设备令牌（仅此一次明文返回）
+func (s *TwoFactorService) RememberDevice(userID, deviceName, ipAddress string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	device := &models.TrustedDevice{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		TokenHash:  sha256Hex(token),
+		DeviceName: deviceName,
+		IPAddress:  ipAddress,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(trustedDeviceTTL),
+	}
+	if err := s.db.Create(device).Error; err != nil {
+		return "", fmt.Errorf("failed to remember device: %w", err)
+	}
+	return token, nil
+}
+
+// IsTrustedDevice 检查设备令牌是否有效
+func (s *TwoFactorService) IsTrustedDevice(userID, token string) bool {
+	if token == "" {
+		return false
+	}
+	result := s.db.Model(&models.TrustedDevice{}).
+		Where("user_id = ? AND token_hash = ? AND expires_at > ?", userID, sha256Hex(token), time.Now()).
+		Update("last_used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// RevokeTrustedDevices 撤销用户全部已记住的设备
+func (s *TwoFactorService) RevokeTrustedDevices(userID string) (int64, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.TrustedDevice{})
+	return result.RowsAffected, result.Error
+}
+
+// activeChallenge 获取未过期、未使用且未超出尝试次数的登录挑战
+func (s *TwoFactorService) activeChallenge(challengeID string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	if err := s.db.First(&challenge, "id = ?", challengeID).Error; err != nil {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= loginChallengeAttempts {
+		return nil, ErrLoginChallengeInvalid
+	}
+	return &challenge, nil
+}
+
+// verifyTOTP 校验验证码并记录时间窗口，拒绝已使用过的窗口
+func (s *TwoFactorService) verifyTOTP(tf *models.UserTwoFactor, code string) error {
+	secret, err := s.decryptSecret(tf)
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now(), twoFactorSkew)
+	if !ok || step <= tf.LastUsedStep {
+		return ErrTwoFactorInvalidCode
+	}
+
+	result := s.db.Model(&models.UserTwoFactor{}).
+		Where("user_id = ? AND last_used_step < ?", tf.UserID, step).
+		Update("last_used_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return ErrTwoFactorInvalidCode
+	}
+	tf.LastUsedStep = step
+
+	// 旧密钥加密的密文在验证通过后改用当前密钥
+	if tf.SecretKeyID != s.activeKey {
+		s.reencryptSecret(tf, secret)
+	}
+	return nil
+}
+
+// reencryptSecret 用当前密钥重新加密TOTP密钥，失败不影响本次验证
+func (s *TwoFactorService) reencryptSecret(tf *models.UserTwoFactor, secret string) {
+	encrypted, keyID, err := s.encryptSecret(secret)
+	if err == nil {
+		err = s.db.Model(&models.UserTwoFactor{}).
+			Where("user_id = ? AND secret_encrypted = ?", tf.UserID, tf.SecretEncrypted).
+			Updates(map[string]interface{}{"secret_encrypted": encrypted, "secret_key_id": keyID}).Error
+	}
+	if err != nil {
+		log.Printf("Failed to re-encrypt two-factor secret for user %s: %v", tf.UserID, err)
+		return
+	}
+	tf.SecretEncrypted, tf.SecretKeyID = encrypted, keyID
+}
+
+// useRecoveryCode 使用一次性恢复码
+func (s *TwoFactorService) useRecoveryCode(userID, code string) error {
+	if !s.IsEnabled(userID) {
+		return ErrTwoFactorNotEnabled
+	}
+	result := s.db.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成新的恢复码并替换旧恢复码
+func (s *TwoFactorService) generateRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.TwoFactorRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		code := encoded[:4] + "-" + encoded[4:8]
+		codes = append(codes, code)
+		records = append(records, models.TwoFactorRecoveryCode{
+			ID:       uuid.New().String(),
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// encryptSecret 用当前密钥加密TOTP密钥，返回密文和密钥ID
+func (s *TwoFactorService) encryptSecret(secret string) (string, string, error) {
+	gcm := s.keys[s.activeKey]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), s.activeKey, nil
+}
+
+// decryptSecret 按记录的密钥ID解密TOTP密钥
+func (s *TwoFactorService) decryptSecret(tf *models.UserTwoFactor) (string, error) {
+	keyID := tf.SecretKeyID
+	if keyID == "" {
+		keyID = devTwoFactorKeyID
+	}
+	gcm, ok := s.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("two-factor encryption key %s not configured", keyID)
+	}
+	data, err := base64.StdEncoding.DecodeString(tf.SecretEncrypted)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid two-factor secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt two-factor secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+// addKey 加载一个AES-256-GCM密钥
+func (s *TwoFactorService) addKey(kid string, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.keys[kid] = gcm
+	return nil
+}
+
+// hashRecoveryCode 恢复码忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return sha256Hex(normalized)
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"openpenpal-backend/internal/config"
+	"openpenpal-backend/internal/models"
+	"openpenpal-backend/pkg/auth"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// TwoFactorServiceTestSuite 两步验证服务测试套件
+type TwoFactorServiceTestSuite struct {
+	suite.Suite
+	db          *gorm.DB
+	service     *TwoFactorService
+	userService *UserService
+}
+
+func (suite *TwoFactorServiceTestSuite) SetupTest() {
+	db, err := config.SetupTestDB()
+	suite.NoError(err)
+	suite.NoError(db.AutoMigrate(
+		&models.UserSession{}, &models.RefreshToken{},
+		&models.UserTwoFactor{}, &models.TwoFactorRecoveryCode{}, &models.TrustedDevice{}, &models.LoginChallenge{},
+	))
+	suite.db = db
+
+	cfg := config.GetTestConfig()
+	suite.service, err = NewTwoFactorService(db, cfg)
+	suite.NoError(err)
+	suite.userService = NewUserService(db, cfg)
+	suite.userService.SetSessionService(NewSessionService(db, cfg))
+	suite.userService.SetTwoFactorService(suite.service)
+}
+
+func (suite *TwoFactorServiceTestSuite) createUser(username string, role models.UserRole) *models.User {
+	user := config.CreateTestUser(suite.db, username, role)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	suite.NoError(err)
+	suite.db.Model(user).Update("password_hash", string(hash))
+	return user
+}
+
+// codeAt 生成指定时间窗口的验证码，避免同一窗口重放被拒绝
+func (suite *TwoFactorServiceTestSuite) codeAt(secret string, offset int) string {
+	code, err := auth.GenerateTOTPCode(secret, time.Now().Add(time.Duration(offset)*auth.TOTPPeriod*time.Second))
+	suite.NoError(err)
+	return code
+}
+
+func (suite *TwoFactorServiceTestSuite) enroll(userID string) (string, string, []string) {
+	enrollment, err := suite.service.BeginEnrollment(userID)
+	suite.NoError(err)
+	suite.Contains(enrollment.ProvisioningURI, "otpauth://totp/")
+
+	usedCode := suite.codeAt(enrollment.Secret, -1)
+	codes, err := suite.service.ConfirmEnrollment(userID, usedCode)
+	suite.NoError(err)
+	suite.Len(codes, recoveryCodeCount)
+	return enrollment.Secret, usedCode, codes
+}
+
+// TestLogin_WithoutTwoFactor 测试未启用两步验证的普通用户直接登录
+func (suite *TwoFactorServiceTestSuite) TestLogin_WithoutTwoFactor() {
+	suite.createUser("plainuser", models.RoleUser)
+
+	resp, err := suite.userService.Login(&models.LoginRequest{Username: "plainuser", Password: "secret123"})
+	suite.NoError(err)
+	suite.False(resp.TwoFactorRequired)
+	suite.NotEmpty(resp.Token)
+}
+
+// TestLogin_TwoStepChallenge 测试启用后登录需要第二步验证，且验证码不可重放
+func (suite *TwoFactorServiceTestSuite) TestLogin_TwoStepChallenge() {
+	user := suite.createUser("tfuser", models.RoleUser)
+	secret, usedCode, _ := suite.enroll(user.ID)
+
+	resp, err := suite.userService.Login(&models.LoginRequest{Username: "tfuser", Password: "secret123"})
+	suite.NoError(err)
+	suite.True(resp.TwoFactorRequired)
+	suite.Empty(resp.Token)
+
+	_, err = suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: "000000"})
+	suite.Error(err)
+
+	// 绑定时使用过的时间窗口不能再次使用
+	_, err = suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: usedCode})
+	suite.ErrorIs(err, ErrTwoFactorInvalidCode)
+
+	done, err := suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{
+		ChallengeID:    resp.ChallengeID,
+		Code:           suite.codeAt(secret, 0),
+		RememberDevice: true,
+	})
+	suite.NoError(err)
+	suite.NotEmpty(done.Token)
+	suite.NotEmpty(done.TrustedDeviceToken)
+
+	// 挑战只能使用一次
+	_, err = suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: suite.codeAt(secret, 1)})
+	suite.ErrorIs(err, ErrLoginChallengeInvalid)
+
+	// 记住的设备免两步验证
+	resp, err = suite.userService.Login(&models.LoginRequest{Username: "tfuser", Password: "secret123", TrustedDeviceToken: done.TrustedDeviceToken})
+	suite.NoError(err)
+	suite.False(resp.TwoFactorRequired)
+	suite.NotEmpty(resp.Token)
+}
+
+// TestLogin_RecoveryCode 测试恢复码一次性使用
+func (suite *TwoFactorServiceTestSuite) TestLogin_RecoveryCode() {
+	user := suite.createUser("recoveryuser", models.RoleUser)
+	_, _, codes := suite.enroll(user.ID)
+
+	resp, err := suite.userService.Login(&models.LoginRequest{Username: "recoveryuser", Password: "secret123"})
+	suite.NoError(err)
+	done, err := suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, RecoveryCode: codes[0]})
+	suite.NoError(err)
+	suite.NotEmpty(done.Token)
+
+	resp, err = suite.userService.Login(&models.LoginRequest{Username: "recoveryuser", Password: "secret123"})
+	suite.NoError(err)
+	_, err = suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, RecoveryCode: codes[0]})
+	suite.ErrorIs(err, ErrTwoFactorInvalidCode)
+}
+
+// TestLogin_MandatoryForAdmin 测试管理员未绑定时须在登录中完成绑定
+func (suite *TwoFactorServiceTestSuite) TestLogin_MandatoryForAdmin() {
+	suite.createUser("adminuser", models.RolePlatformAdmin)
+
+	resp, err := suite.userService.Login(&models.LoginRequest{Username: "adminuser", Password: "secret123"})
+	suite.NoError(err)
+	suite.True(resp.TwoFactorSetupRequired)
+	suite.Empty(resp.Token)
+
+	enrollment, err := suite.service.ChallengeEnrollment(resp.ChallengeID)
+	suite.NoError(err)
+
+	done, err := suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: suite.codeAt(enrollment.Secret, 0)})
+	suite.NoError(err)
+	suite.NotEmpty(done.Token)
+	suite.Len(done.RecoveryCodes, recoveryCodeCount)
+
+	err = suite.service.Disable(done.User.ID, "secret123", suite.codeAt(enrollment.Secret, 1))
+	suite.ErrorIs(err, ErrTwoFactorRequired)
+}
+
+// TestChallenge_AttemptLimit 测试登录挑战的尝试次数限制
+func (suite *TwoFactorServiceTestSuite) TestChallenge_AttemptLimit() {
+	user := suite.createUser("bruteuser", models.RoleCourierLevel3)
+	secret, _, _ := suite.enroll(user.ID)
+
+	resp, err := suite.userService.Login(&models.LoginRequest{Username: "bruteuser", Password: "secret123"})
+	suite.NoError(err)
+	suite.True(resp.TwoFactorRequired)
+
+	for i := 0; i < loginChallengeAttempts; i++ {
+		suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: "000000"})
+	}
+	_, err = suite.userService.CompleteLoginChallenge(&models.LoginChallengeRequest{ChallengeID: resp.ChallengeID, Code: suite.codeAt(secret, 0)})
+	suite.ErrorIs(err, ErrLoginChallengeInvalid)
+}
+
+// TestEncryptionKey_Rotation 测试配置独立加密密钥后旧密文仍可验证，并在验证后改用新密钥
+func (suite *TwoFactorServiceTestSuite) TestEncryptionKey_Rotation() {
+	user := suite.createUser("rotateuser", models.RoleUser)
+	secret, _, _ := suite.enroll(user.ID)
+
+	// 早期密文没有记录密钥ID
+	suite.NoError(suite.db.Model(&models.UserTwoFactor{}).Where("user_id = ?", user.ID).Update("secret_key_id", "").Error)
+
+	cfg := *config.GetTestConfig()
+	cfg.TwoFactorEncryptionKeys = "k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	rotated, err := NewTwoFactorService(suite.db, &cfg)
+	suite.Require().NoError(err)
+	suite.NoError(rotated.VerifyCode(user.ID, suite.codeAt(secret, 0)))
+
+	var tf models.UserTwoFactor
+	suite.NoError(suite.db.First(&tf, "user_id = ?", user.ID).Error)
+	suite.Equal("k1", tf.SecretKeyID)
+
+	// 轮换到 k2 后保留 k1 仍可解密
+	cfg.TwoFactorEncryptionKeys += ",k2:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	cfg.TwoFactorEncryptionKeyID = "k2"
+	rotated, err = NewTwoFactorService(suite.db, &cfg)
+	suite.Require().NoError(err)
+	suite.NoError(rotated.VerifyCode(user.ID, suite.codeAt(secret, 1)))
+	suite.NoError(suite.db.First(&tf, "user_id = ?", user.ID).Error)
+	suite.Equal("k2", tf.SecretKeyID)
+
+	// 缺少记录的密钥时无法解密
+	cfg.TwoFactorEncryptionKeys = "k3:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("3", 32)))
+	cfg.TwoFactorEncryptionKeyID = ""
+	missing, err := NewTwoFactorService(suite.db, &cfg)
+	suite.Require().NoError(err)
+	suite.Error(missing.VerifyCode(user.ID, suite.codeAt(secret, 2)))
+
+	cfg.TwoFactorEncryptionKeys = "k4:" + base64.StdEncoding.EncodeToString([]byte("short"))
+	_, err = NewTwoFactorService(suite.db, &cfg)
+	suite.Error(err)
+}
+
+func TestTwoFactorServiceSuite(t *testing.T) {
+	suite.Run(t, new(TwoFactorServiceTestSuite))
+}
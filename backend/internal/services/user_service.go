@@ -19,8 +19,10 @@ import (
 )
 
 type UserService struct {
-	db     *gorm.DB
-	config *config.Config
+	db               *gorm.DB
+	config           *config.Config
+	sessionService   *SessionService
+	twoFactorService *TwoFactorService
 }
 
 func NewUserService(db *gorm.DB, config *config.Config) *UserService {
@@ -30,6 +32,16 @@ func NewUserService(db *gorm.DB, config *config.Config) *UserService {
 	}
 }
 
+// SetSessionService 设置会话服务（登录创建会话，改密注销全部会话）
+func (s *UserService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// SetTwoFactorService 设置两步验证服务
+func (s *UserService) SetTwoFactorService(twoFactorService *TwoFactorService) {
+	s.twoFactorService = twoFactorService
+}
+
 // GetDB returns the database instance
 func (s *UserService) GetDB() *gorm.DB {
 	return s.db
@@ -111,6 +123,117 @@ func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
+	device := &models.SessionDeviceInfo{
+		DeviceID:   req.DeviceID,
+		DeviceName: req.DeviceName,
+		UserAgent:  req.UserAgent,
+		IPAddress:  req.IPAddress,
+	}
+
+	return s.beginLogin(&user, device, req.TrustedDeviceToken)
+}
+
+// LoginWithExternalIdentity 外部身份（校园SSO）认证通过后登录，同样需要完成两步验证
+func (s *UserService) LoginWithExternalIdentity(user *models.User, device *models.SessionDeviceInfo, trustedDeviceToken string) (*models.LoginResponse, error) {
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is disabled")
+	}
+	return s.beginLogin(user, device, trustedDeviceToken)
+}
+
+// beginLogin 第一步认证通过后决定是否需要两步验证挑战
+func (s *UserService) beginLogin(user *models.User, device *models.SessionDeviceInfo, trustedDeviceToken string) (*models.LoginResponse, error) {
+	// 两步验证：已启用的用户（记住的设备除外）和强制要求的角色需完成第二步
+	if s.twoFactorService != nil {
+		challengeType := models.LoginChallengeType("")
+		if s.twoFactorService.IsEnabled(user.ID) {
+			if !s.twoFactorService.IsTrustedDevice(user.ID, trustedDeviceToken) {
+				challengeType = models.LoginChallengeVerify
+			}
+		} else if s.twoFactorService.IsRequiredForRole(user.Role) {
+			challengeType = models.LoginChallengeSetup
+		}
+
+		if challengeType != "" {
+			challenge, err := s.twoFactorService.CreateChallenge(user.ID, challengeType, device)
+			if err != nil {
+				return nil, err
+			}
+			return &models.LoginResponse{
+				TwoFactorRequired:      challengeType == models.LoginChallengeVerify,
+				TwoFactorSetupRequired: challengeType == models.LoginChallengeSetup,
+				ChallengeID:            challenge.ID,
+				ExpiresAt:              challenge.ExpiresAt,
+			}, nil
+		}
+	}
+
+	return s.completeLogin(user, device)
+}
+
+// CompleteLoginChallenge 完成两步验证登录挑战并签发令牌
+func (s *UserService) CompleteLoginChallenge(req *models.LoginChallengeRequest) (*models.LoginResponse, error) {
+	if s.twoFactorService == nil {
+		return nil, fmt.Errorf("two-factor authentication is not available")
+	}
+
+	challenge, recoveryCodes, err := s.twoFactorService.CompleteChallenge(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ?", challenge.UserID).Error; err != nil {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is disabled")
+	}
+
+	response, err := s.completeLogin(&user, &models.SessionDeviceInfo{
+		DeviceID:   challenge.DeviceID,
+		DeviceName: challenge.DeviceName,
+		UserAgent:  challenge.UserAgent,
+		IPAddress:  challenge.IPAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	if req.RememberDevice {
+		token, err := s.twoFactorService.RememberDevice(user.ID, challenge.DeviceName, challenge.IPAddress)
+		if err == nil {
+			response.TrustedDeviceToken = token
+		}
+	}
+
+	return response, nil
+}
+
+// completeLogin 身份验证全部通过后签发令牌
+func (s *UserService) completeLogin(user *models.User, device *models.SessionDeviceInfo) (*models.LoginResponse, error) {
+	// 更新最后登录时间
+	now := time.Now()
+	s.db.Model(user).Update("last_login_at", &now)
+
+	// 创建设备绑定会话，签发访问令牌和刷新令牌
+	if s.sessionService != nil {
+		tokens, err := s.sessionService.CreateSession(user, device)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+
+		user.PasswordHash = ""
+		return &models.LoginResponse{
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			SessionID:    tokens.SessionID,
+			User:         user,
+			ExpiresAt:    tokens.ExpiresAt,
+		}, nil
+	}
+
 	// 从系统配置获取JWT过期时间
 	systemConfig, err := s.getSystemConfig()
 	if err != nil {
@@ -129,16 +252,12 @@ func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	// 更新最后登录时间
-	now := time.Now()
-	s.db.Model(&user).Update("last_login_at", &now)
-
 	// 清除密码哈希
 	user.PasswordHash = ""
 
 	return &models.LoginResponse{
 		Token:     token,
-		User:      &user,
+		User:      user,
 		ExpiresAt: expiresAt,
 	}, nil
 }
@@ -231,6 +350,13 @@ func (s *UserService) ChangePassword(userID string, req *models.ChangePasswordRe
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
+	// 密码变更后注销全部会话，已泄露的令牌随之失效
+	if s.sessionService != nil {
+		if _, err := s.sessionService.RevokeAllSessions(userID, SessionRevokePasswordChanged); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,293 @@
+package testutils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const fakeIdPKeyID = "fake-idp-key"
+
+// FakeIdentity 模拟身份提供方中的用户
+type FakeIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Claims        map[string]interface{} // 额外claim / CAS属性，如院系、学校
+}
+
+type fakeAuthorization struct {
+	identity      FakeIdentity
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	authTime      time.Time
+}
+
+type fakeTicket struct {
+	identity FakeIdentity
+	service  string
+	renew    bool // 用户重新输入了凭据，而非复用CAS单点会话
+}
+
+// FakeIdP 本地模拟的OIDC + CAS身份提供方，用于单点登录测试
+type FakeIdP struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key     *rsa.PrivateKey
+	mu      sync.Mutex
+	codes   map[string]fakeAuthorization
+	tickets map[string]fakeTicket
+}
+
+// NewFakeIdP 启动模拟身份提供方，测试结束时自动关闭
+func NewFakeIdP(t *testing.T) *FakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate fake idp key: %v", err)
+	}
+
+	idp := &FakeIdP{
+		ClientID:     "openpenpal-test",
+		ClientSecret: "fake-secret",
+		key:          key,
+		codes:        make(map[string]fakeAuthorization),
+		tickets:      make(map[string]fakeTicket),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/cas/p3/serviceValidate", idp.handleServiceValidate)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Server.Close)
+
+	return idp
+}
+
+// Issuer OIDC issuer地址
+func (f *FakeIdP) Issuer() string {
+	return f.Server.URL
+}
+
+// CASBaseURL CAS服务地址
+func (f *FakeIdP) CASBaseURL() string {
+	return f.Server.URL + "/cas"
+}
+
+// AuthorizeOIDC 模拟用户在IdP登录并同意授权，返回回调中的code与state
+func (f *FakeIdP) AuthorizeOIDC(authorizationURL string, identity FakeIdentity) (string, string, error) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != f.ClientID {
+		return "", "", fmt.Errorf("unknown client_id %q", query.Get("client_id"))
+	}
+	if query.Get("response_type") != "code" {
+		return "", "", fmt.Errorf("unsupported response_type %q", query.Get("response_type"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", "", fmt.Errorf("pkce S256 is required")
+	}
+
+	// 未要求重新登录时沿用IdP中较早建立的会话
+	authTime := time.Now().Add(-time.Hour)
+	if query.Get("prompt") == "login" || query.Get("max_age") == "0" {
+		authTime = time.Now()
+	}
+
+	code := f.randomValue()
+	f.mu.Lock()
+	f.codes[code] = fakeAuthorization{
+		identity:      identity,
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		authTime:      authTime,
+	}
+	f.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+// LoginCAS 模拟用户在CAS登录，返回签发的票据与service地址中携带的state
+func (f *FakeIdP) LoginCAS(loginURL string, identity FakeIdentity) (string, string, error) {
+	parsed, err := url.Parse(loginURL)
+	if err != nil {
+		return "", "", err
+	}
+	service := parsed.Query().Get("service")
+	serviceURL, err := url.Parse(service)
+	if err != nil || service == "" {
+		return "", "", fmt.Errorf("invalid service %q", service)
+	}
+
+	ticket := "ST-" + f.randomValue()
+	f.mu.Lock()
+	f.tickets[ticket] = fakeTicket{identity: identity, service: service, renew: parsed.Query().Get("renew") == "true"}
+	f.mu.Unlock()
+
+	return ticket, serviceURL.Query().Get("state"), nil
+}
+
+func (f *FakeIdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                f.Issuer(),
+		"authorization_endpoint":                f.Server.URL + "/authorize",
+		"token_endpoint":                        f.Server.URL + "/token",
+		"jwks_uri":                              f.Server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (f *FakeIdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": fakeIdPKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (f *FakeIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	f.mu.Lock()
+	grant, ok := f.codes[code]
+	delete(f.codes, code) // 授权码只能使用一次
+	f.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if r.PostForm.Get("client_id") != f.ClientID || r.PostForm.Get("client_secret") != f.ClientSecret {
+		writeFakeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != grant.redirectURI {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "pkce verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.Issuer(),
+		"sub":            grant.identity.Subject,
+		"aud":            f.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"auth_time":      grant.authTime.Unix(),
+		"email":          grant.identity.Email,
+		"email_verified": grant.identity.EmailVerified,
+		"name":           grant.identity.Name,
+	}
+	if grant.identity.Username != "" {
+		claims["preferred_username"] = grant.identity.Username
+	}
+	for name, value := range grant.identity.Claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = fakeIdPKeyID
+	idToken, err := token.SignedString(f.key)
+	if err != nil {
+		writeFakeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": f.randomValue(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (f *FakeIdP) handleServiceValidate(w http.ResponseWriter, r *http.Request) {
+	ticket := r.URL.Query().Get("ticket")
+	f.mu.Lock()
+	grant, ok := f.tickets[ticket]
+	delete(f.tickets, ticket)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	renewRequired := r.URL.Query().Get("renew") == "true"
+	if !ok || grant.service != r.URL.Query().Get("service") || (renewRequired && !grant.renew) {
+		fmt.Fprintf(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">`+
+			`<cas:authenticationFailure code="INVALID_TICKET">Ticket %s not recognized</cas:authenticationFailure>`+
+			`</cas:serviceResponse>`, xmlEscape(ticket))
+		return
+	}
+
+	attributes := ""
+	if grant.identity.Email != "" {
+		attributes += "<cas:mail>" + xmlEscape(grant.identity.Email) + "</cas:mail>"
+	}
+	if grant.identity.Name != "" {
+		attributes += "<cas:displayName>" + xmlEscape(grant.identity.Name) + "</cas:displayName>"
+	}
+	for name, value := range grant.identity.Claims {
+		attributes += fmt.Sprintf("<cas:%s>%s</cas:%s>", name, xmlEscape(fmt.Sprint(value)), name)
+	}
+
+	fmt.Fprintf(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">`+
+		`<cas:authenticationSuccess><cas:user>%s</cas:user><cas:attributes>%s</cas:attributes></cas:authenticationSuccess>`+
+		`</cas:serviceResponse>`, xmlEscape(grant.identity.Subject), attributes)
+}
+
+func (f *FakeIdP) randomValue() string {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
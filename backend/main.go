@@ -48,6 +48,7 @@ func main() {
 	userService := services.NewUserService(db, cfg)
 	letterService := services.NewLetterService(db, cfg)
 	envelopeService := services.NewEnvelopeService(db)
+	envelopePrintService := services.NewEnvelopePrintService(db, cfg) // 信封标签打印服务 - 条码标签PDF与打印批次
 	courierService := services.NewCourierService(db)
 	museumService := services.NewMuseumService(db)
 	aiService := services.NewAIService(db, cfg)
@@ -89,6 +90,26 @@ func main() {
 	cloudLetterService := services.NewCloudLetterService(db, cfg) // 云中锦书服务 - 自定义现实角色
 	contentSecurityService := services.NewContentSecurityService(db, cfg, aiService) // 内容安全服务 - XSS防护和敏感词管理
 	tagService := services.NewTagService(db) // 标签服务 - 内容发现与分类
+	dataRequestService := services.NewDataRequestService(db, cfg) // 数据主体请求服务 - 个人数据导出与账户删除
+	sessionService := services.NewSessionService(db, cfg) // 会话服务 - 设备绑定会话与刷新令牌轮换
+	twoFactorService, err := services.NewTwoFactorService(db, cfg) // 两步验证服务 - TOTP与恢复码
+	if err != nil {
+		log.Fatal("Failed to init two-factor service: %v", err)
+	}
+	ssoService := services.NewSSOService(db, cfg)             // 校园单点登录服务 - OIDC与CAS
+	schoolVerificationService := services.NewSchoolVerificationService(db, cfg) // 在校身份认证服务 - 学校邮箱验证码
+	podService := services.NewProofOfDeliveryService(db, cfg)                        // 签收凭证服务 - 收件人确认码与签名凭证
+	letterSyncService := services.NewLetterSyncService(db, redisClient)              // 信件状态同步服务 - 与courier-service任务状态双向同步
+	forwardService := services.NewOPCodeForwardService(db)                             // OP Code转寄服务 - 住户搬离后改投新编码
+	opcodeImportService := services.NewOPCodeImportService(db)                         // OP Code批量导入服务 - 新校区接入
+	mailboxCollectionService := services.NewMailboxCollectionService(db)               // 公共信箱收取服务 - 按计划开箱收信
+	barcodeLifecycleService := services.NewBarcodeLifecycleService(db, cfg)            // 条码生命周期服务 - 过期提醒与回收
+	letterOCRService := services.NewLetterOCRService(db, cfg)                          // 手写信件识别服务 - 照片识别后由寄信人确认
+	envelopeContestService := services.NewEnvelopeContestService(db)                   // 信封设计评选服务 - 限票加权与刷票检测
+	qrTokenService, err := services.NewQRTokenService(db, cfg) // 二维码签名令牌服务 - 防止复印标签冒充投递
+	if err != nil {
+		log.Fatal("Failed to init QR token service: %v", err)
+	}
 
 	// Phase 4.1: 初始化积分过期服务
 	creditExpirationService := services.NewCreditExpirationService(db, creditService, notificationService)
@@ -126,6 +147,14 @@ func main() {
 	letterService.SetOPCodeService(opcodeService) // PRD要求：集成OP Code验证
 	aiService.SetCreditTaskService(creditTaskService) // 新增：AI服务积分任务依赖
 	letterService.SetUserService(userService)     // 添加用户服务依赖
+	letterService.SetQRTokenService(qrTokenService)   // 二维码签名令牌
+	mailboxCollectionService.SetQRTokenService(qrTokenService)
+	podService.SetQRTokenService(qrTokenService)
+	courierService.SetQRTokenService(qrTokenService)
+	letterService.SetOPCodeForwardService(forwardService) // 收件人登记转寄时改投
+	courierService.SetOPCodeForwardService(forwardService)
+	forwardService.SetOPCodeService(opcodeService)
+	forwardService.SetNotificationService(notificationService)
 	envelopeService.SetCreditService(creditService)
 	envelopeService.SetUserService(userService) // FSD增强：OP Code区域验证
 	museumService.SetCreditService(creditService)
@@ -146,6 +175,49 @@ func main() {
 	cloudLetterService.SetNotificationService(notificationService)
 	// 配置标签服务依赖
 	tagService.SetAIService(aiService)
+	// 配置数据主体请求服务依赖
+	dataRequestService.SetStorageService(storageService)
+	dataRequestService.SetNotificationService(notificationService)
+	dataRequestService.SetTwoFactorService(twoFactorService)
+	dataRequestService.SetSSOService(ssoService)
+	schedulerService.SetDataRequestService(dataRequestService)
+	// 配置会话服务依赖
+	userService.SetSessionService(sessionService)
+	adminService.SetSessionService(sessionService)
+	userService.SetTwoFactorService(twoFactorService)
+	schedulerService.SetSessionService(sessionService)
+	schedulerService.SetSSOService(ssoService)
+	// 配置在校身份认证：OP Code与信使申请仅限已认证学生
+	schoolVerificationService.SetMailer(notificationService)
+	opcodeService.SetSchoolVerificationService(schoolVerificationService)
+	// OP Code 校验位过渡期：截止后存量编码也必须使用7位格式
+	if cfg.OPCodeLegacyUntil != "" {
+		if deadline, err := time.Parse("2006-01-02", cfg.OPCodeLegacyUntil); err == nil {
+			opcodeService.SetLegacyFormatDeadline(deadline)
+		} else {
+			log.Warn("Invalid OPCODE_LEGACY_UNTIL %q: %v", cfg.OPCodeLegacyUntil, err)
+		}
+	}
+	courierService.SetSchoolVerificationService(schoolVerificationService)
+	schedulerService.SetSchoolVerificationService(schoolVerificationService)
+	podService.SetNotificationService(notificationService)
+	letterSyncService.SetNotificationService(notificationService)
+	letterSyncService.SetProofOfDeliveryService(podService)
+	mailboxCollectionService.SetNotificationService(notificationService)
+	barcodeLifecycleService.SetNotificationService(notificationService)
+	schedulerService.SetBarcodeLifecycleService(barcodeLifecycleService)
+	letterOCRService.SetStorageService(storageService)
+	letterOCRService.SetNotificationService(notificationService)
+	if cfg.OCRServiceURL != "" && cfg.OCRServiceAPIKey != "" {
+		letterOCRService.SetClient(services.NewHTTPOCRClient(cfg))
+	} else if cfg.OCRServiceURL != "" {
+		log.Warn("OCR_SERVICE_URL is set but OCR_SERVICE_API_KEY is empty, handwriting OCR disabled")
+	}
+	envelopeContestService.SetSchoolVerificationService(schoolVerificationService)
+	envelopeContestService.SetNotificationService(notificationService)
+	if creditLimiterService != nil {
+		envelopeContestService.SetFraudEngine(creditLimiterService)
+	}
 
 	// 启动任务调度服务
 	if err := schedulerService.Start(); err != nil {
@@ -161,6 +233,18 @@ func main() {
 		log.Info("Credit activity scheduler started successfully")
 	}
 
+	// 启动信件状态同步（出站事件投递、courier-service事件消费与定时对账）
+	letterSyncService.Start()
+
+	// 启动公共信箱收取轮次生成与漏收标记
+	mailboxCollectionService.Start()
+
+	// 启动手写信件识别队列
+	letterOCRService.Start()
+
+	// 启动信封设计评选自动结算
+	envelopeContestService.Start()
+
 	// 注册默认调度任务
 	// TODO: Re-enable when scheduler tasks are fixed
 	/*
@@ -184,10 +268,15 @@ func main() {
 	// 初始化处理器
 	userHandler := handlers.NewUserHandler(userService)
 	authHandler := handlers.NewAuthHandler(userService, cfg) // 新增：专门的认证处理器
+	authHandler.SetSessionService(sessionService)
+	authHandler.SetTwoFactorService(twoFactorService)
+	authHandler.SetSSOService(ssoService)
 	letterHandler := handlers.NewLetterHandler(letterService, envelopeService)
 	envelopeHandler := handlers.NewEnvelopeHandler(envelopeService)
+	envelopePrintHandler := handlers.NewEnvelopePrintHandler(envelopePrintService)
 	courierHandler := handlers.NewCourierHandler(courierService)
 	promotionService := services.NewPromotionService(db)
+	promotionService.SetSchoolVerificationService(schoolVerificationService)
 	courierGrowthHandler := handlers.NewCourierGrowthHandler(courierService, userService, promotionService)
 	museumHandler := handlers.NewMuseumHandler(museumService)
 	aiHandler := handlers.NewAIHandler(aiService, configService, aiManager)
@@ -230,12 +319,28 @@ func main() {
 	
 	validationHandler := handlers.NewValidationHandler() // 安全验证处理器
 	tagHandler := handlers.NewTagHandler(tagService) // 标签处理器 - 内容发现与分类
+	dataRequestHandler := handlers.NewDataRequestHandler(dataRequestService) // 数据主体请求处理器
+	sessionHandler := handlers.NewSessionHandler(sessionService) // 会话管理处理器
+	logoutHandler := handlers.NewLogoutHandler(sessionService) // 注销处理器 - 注销全部会话
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, userService) // 两步验证处理器
+	ssoHandler := handlers.NewSSOHandler(ssoService)                                // 外部身份关联与身份提供方管理
+	schoolVerificationHandler := handlers.NewSchoolVerificationHandler(schoolVerificationService) // 在校身份认证处理器
+	podHandler := handlers.NewProofOfDeliveryHandler(podService)                                  // 签收凭证处理器
+	letterSyncHandler := handlers.NewLetterSyncHandler(letterSyncService)                         // 信件状态同步处理器
+	qrTokenHandler := handlers.NewQRTokenHandler(qrTokenService, letterService)                   // 二维码签名令牌处理器
+	forwardHandler := handlers.NewOPCodeForwardHandler(forwardService)                            // OP Code转寄处理器
+	opcodeImportHandler := handlers.NewOPCodeImportHandler(opcodeImportService)                   // OP Code批量导入处理器
+	mailboxCollectionHandler := handlers.NewMailboxCollectionHandler(mailboxCollectionService)    // 公共信箱收取处理器
+	barcodeLifecycleHandler := handlers.NewBarcodeLifecycleHandler(barcodeLifecycleService)       // 条码生命周期管理处理器
+	letterOCRHandler := handlers.NewLetterOCRHandler(letterOCRService)                            // 手写信件识别处理器
+	envelopeContestHandler := handlers.NewEnvelopeContestHandler(envelopeContestService)          // 信封设计评选处理器
 
 	// QR扫描服务和处理器 - SOTA集成：复用现有依赖
 	// TODO: Re-enable when QR scan handler is fixed
 	/*
 	qrScanService := services.NewQRScanService(db, letterService, courierService, wsAdapter)
 	qrScanService.SetCreditTaskService(creditTaskService) // 新增：积分任务服务依赖
+	qrScanService.SetQRTokenService(qrTokenService)       // 未设置时拒绝扫码改状态
 	qrScanHandler := handlers.NewQRScanHandler(qrScanService, middleware.NewAuthMiddleware(cfg, db))
 	*/
 	wsHandler := wsService.GetHandler()
@@ -340,18 +445,30 @@ func main() {
 			csrfProtected := auth.Group("/")
 			csrfProtected.Use(middleware.CSRFMiddleware())
 			{
-				csrfProtected.POST("/register", authHandler.Register) // 用户注册
-				csrfProtected.POST("/login", authHandler.Login)       // 用户登录
+				csrfProtected.POST("/register", authHandler.Register)          // 用户注册
+				csrfProtected.POST("/login", authHandler.Login)                // 用户登录
+				csrfProtected.POST("/2fa/verify", authHandler.VerifyTwoFactor) // 登录第二步：验证码/恢复码
+				csrfProtected.POST("/2fa/setup", authHandler.TwoFactorSetup)   // 强制两步验证角色首次绑定
+				csrfProtected.POST("/sso/callback", authHandler.SSOCallback)   // 完成校园单点登录
 			}
 
+			// 校园统一身份认证（OIDC/CAS）
+			auth.GET("/sso/providers", authHandler.SSOProviders)           // 可用的单点登录方式
+			auth.GET("/sso/:provider/authorize", authHandler.SSOAuthorize) // 获取单点登录跳转地址
+
+			// 刷新令牌轮换（访问令牌可能已过期，无需认证）
+			auth.POST("/refresh", authHandler.RefreshToken) // 刷新令牌
+
 			// 用户信息端点（需要认证）
 			authGroup := auth.Group("/")
 			authGroup.Use(middleware.AuthMiddleware(cfg, db))
 			{
-				authGroup.GET("/me", authHandler.GetCurrentUser)             // 获取当前用户信息
-				authGroup.POST("/logout", authHandler.Logout)                // 登出
-				authGroup.POST("/refresh", authHandler.RefreshToken)         // 刷新令牌
-				authGroup.GET("/check-expiry", authHandler.CheckTokenExpiry) // 检查令牌过期
+				authGroup.GET("/me", authHandler.GetCurrentUser)                // 获取当前用户信息
+				authGroup.POST("/logout", authHandler.Logout)                   // 登出
+				authGroup.POST("/logout-all", logoutHandler.LogoutAll)          // 注销所有会话
+				authGroup.GET("/sessions", sessionHandler.GetSessions)          // 登录设备列表
+				authGroup.DELETE("/sessions/:id", sessionHandler.RevokeSession) // 注销指定会话
+				authGroup.GET("/check-expiry", authHandler.CheckTokenExpiry)    // 检查令牌过期
 			}
 		}
 
@@ -383,6 +500,7 @@ func main() {
 			museum.GET("/exhibitions/:id/items", museumHandler.GetExhibitionItems) // 获取展览中的物品
 			museum.GET("/tags", museumHandler.GetMuseumTags)                       // 获取标签列表
 			museum.GET("/stats", museumHandler.GetMuseumStats)                     // 获取博物馆统计
+			museum.GET("/handwritten", letterOCRHandler.SearchHandwritten)         // 按识别文字搜索手写信件
 		}
 
 		// 公开的AI相关（无需认证）
@@ -407,6 +525,11 @@ func main() {
 		}
 
 		// 公开的OP Code查询（仅公开信息） - Temporarily disabled
+		// 二维码验证公钥（信使端离线验签）
+		public.GET("/qr/keys", qrTokenHandler.GetPublicKeys)
+		// 校园地图（片区边界与公开投递点，信使服务定期拉取）
+		public.GET("/opcode/geo", opcodeHandler.ExportPublicGeoJSON)
+
 		/*
 			opcode := public.Group("/opcode")
 			{
@@ -440,6 +563,33 @@ func main() {
 			users.DELETE("/me", userHandler.DeactivateAccount)
 			users.POST("/avatar", userHandler.UploadAvatar)
 			users.DELETE("/avatar", userHandler.RemoveAvatar)
+
+			// 个人数据导出与账户删除（被遗忘权）
+			users.POST("/me/data-export", dataRequestHandler.RequestExport)                // 申请数据导出
+			users.POST("/me/data-erasure", dataRequestHandler.RequestErasure)              // 申请删除账户
+			users.GET("/me/data-requests", dataRequestHandler.GetMyRequests)               // 获取我的数据请求
+			users.GET("/me/data-requests/:id/download", dataRequestHandler.DownloadExport) // 下载导出包
+			users.POST("/me/data-requests/:id/cancel", dataRequestHandler.CancelRequest)   // 撤销删除申请
+
+			// 两步验证
+			users.GET("/me/2fa", twoFactorHandler.GetStatus)                               // 两步验证状态
+			users.POST("/me/2fa/enroll", twoFactorHandler.BeginEnrollment)                 // 生成绑定二维码
+			users.POST("/me/2fa/confirm", twoFactorHandler.ConfirmEnrollment)              // 确认绑定
+			users.POST("/me/2fa/disable", twoFactorHandler.Disable)                        // 关闭两步验证
+			users.POST("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes) // 重新生成恢复码
+			users.DELETE("/me/2fa/trusted-devices", twoFactorHandler.RevokeTrustedDevices) // 撤销记住的设备
+
+			// 校园账号关联
+			users.GET("/me/identities", ssoHandler.GetIdentities)                 // 已关联的校园账号
+			users.POST("/me/identities/:provider/link", ssoHandler.BeginLink)     // 发起关联
+			users.POST("/me/identities/:provider/reauth", ssoHandler.BeginReauth) // 敏感操作前重新认证
+			users.POST("/me/identities/callback", ssoHandler.CompleteLink)        // 完成关联
+			users.DELETE("/me/identities/:id", ssoHandler.Unlink)                 // 解除关联
+
+			// 在校身份认证（学校邮箱验证码）
+			users.GET("/me/school-verification", schoolVerificationHandler.GetStatus)                    // 认证状态
+			users.POST("/me/school-verification", schoolVerificationHandler.StartVerification)           // 发送验证码
+			users.POST("/me/school-verification/confirm", schoolVerificationHandler.ConfirmVerification) // 确认验证码
 		}
 
 		// 信件相关
@@ -452,12 +602,23 @@ func main() {
 			letters.PUT("/:id", letterHandler.UpdateLetter)
 			letters.DELETE("/:id", letterHandler.DeleteLetter)
 			letters.POST("/:id/generate-code", letterHandler.GenerateCode)
+			letters.POST("/:id/delivery-confirmation", podHandler.IssueConfirmation) // 收件人生成签收确认码
+			letters.GET("/:id/delivery-receipt", podHandler.GetReceipt)              // 查看签收凭证
 
 			// 信封绑定相关
 			letters.POST("/:id/bind-envelope", letterHandler.BindEnvelope)
 			letters.DELETE("/:id/bind-envelope", letterHandler.UnbindEnvelope)
 			letters.GET("/:id/envelope", letterHandler.GetLetterEnvelope)
 
+			// 手写信件照片与文字识别
+			letters.GET("/ocr/preference", letterOCRHandler.GetPreference)             // 识别设置
+			letters.PUT("/ocr/preference", letterOCRHandler.UpdatePreference)          // 开启或关闭自动识别
+			letters.GET("/ocr/jobs", letterOCRHandler.ListJobs)                        // 识别任务列表
+			letters.GET("/ocr/jobs/:job_id", letterOCRHandler.GetJob)                  // 识别结果详情
+			letters.POST("/ocr/jobs/:job_id/review", letterOCRHandler.ReviewJob)       // 确认或放弃识别结果
+			letters.POST("/:id/photos", letterOCRHandler.AddPhoto)                     // 添加信件照片
+			letters.POST("/:id/photos/:photo_id/ocr", letterOCRHandler.RequestOCR)     // 对照片发起识别
+
 			// SOTA 回信系统路由 (扫码回信和线索保持) - 已实现
 			letters.GET("/scan-reply/:code", letterHandler.GetReplyInfoByCode) // 扫码获取回信信息
 			letters.POST("/replies", letterHandler.CreateReply)                // 创建回信
@@ -516,6 +677,17 @@ func main() {
 			courier.GET("/status", courierHandler.GetCourierStatus)
 			courier.GET("/profile", courierHandler.GetCourierProfile)
 			courier.POST("/letters/:code/status", letterHandler.UpdateStatus)
+			courier.POST("/letters/:code/deliver", podHandler.ConfirmDelivery) // 当面签收确认送达
+
+			// 公共信箱收取
+			courier.GET("/mailboxes/schedules", mailboxCollectionHandler.ListSchedules)   // 信箱收取计划列表
+			courier.PUT("/mailboxes/:code/schedule", mailboxCollectionHandler.SetSchedule) // 设置信箱收取计划
+			courier.GET("/mailboxes/stats", mailboxCollectionHandler.GetFillStats)        // 信箱装满程度与频率建议
+			courier.POST("/collections/start", mailboxCollectionHandler.StartRun)         // 扫描信箱二维码开箱
+			courier.GET("/collections", mailboxCollectionHandler.ListRuns)                // 收取轮次列表
+			courier.GET("/collections/:id", mailboxCollectionHandler.GetRun)              // 收取轮次详情
+			courier.POST("/collections/:id/scan", mailboxCollectionHandler.ScanLetters)   // 登记开箱扫到的信件
+			courier.POST("/collections/:id/complete", mailboxCollectionHandler.CompleteRun) // 结束收取
 
 			// 四级信使管理API
 			courier.POST("/create", courierHandler.CreateCourier)           // 创建下级信使
@@ -581,6 +753,20 @@ func main() {
 			envelopes.POST("/orders", envelopeHandler.CreateEnvelopeOrder)
 			envelopes.GET("/orders", envelopeHandler.GetEnvelopeOrders)
 			envelopes.POST("/orders/:id/pay", envelopeHandler.ProcessEnvelopePayment)
+
+			// 信封设计评选
+			envelopes.GET("/contests", envelopeContestHandler.ListContests)                               // 评选列表
+			envelopes.GET("/contests/:id", envelopeContestHandler.GetContest)                             // 评选详情与参赛设计
+			envelopes.POST("/contests/:id/entries", envelopeContestHandler.SubmitEntry)                   // 提交参赛设计
+			envelopes.POST("/contests/:id/entries/:design_id/vote", envelopeContestHandler.Vote)          // 投票
+
+			// 条码标签打印
+			envelopes.GET("/print-layouts", envelopePrintHandler.GetLayouts)
+			envelopes.POST("/print-batches", envelopePrintHandler.CreatePrintBatch)
+			envelopes.GET("/print-batches", envelopePrintHandler.ListPrintBatches)
+			envelopes.GET("/print-batches/:id", envelopePrintHandler.GetPrintBatch)
+			envelopes.GET("/print-batches/:id/pdf", envelopePrintHandler.DownloadPrintBatch)
+			envelopes.POST("/print-batches/:id/reprint", envelopePrintHandler.ReprintBatch)
 		}
 
 		// 博物馆相关
@@ -906,12 +1092,25 @@ func main() {
 			opcode.GET("/search/buildings", opcodeHandler.SearchBuildings)  // 搜索楼栋
 			opcode.GET("/search/points", opcodeHandler.SearchPoints)        // 搜索投递点
 			opcode.GET("/stats/:school_code", opcodeHandler.GetOPCodeStats) // 获取统计
+			opcode.GET("/geo/resolve", opcodeHandler.ResolveLocation)       // GPS坐标解析所在片区
+			opcode.GET("/geo/nearest", opcodeHandler.NearestOPCodes)        // 附近的投递点
+			opcode.POST("/forwards", forwardHandler.CreateForward)          // 登记转寄（搬离后改投新编码）
+			opcode.GET("/forwards", forwardHandler.ListForwards)            // 我的转寄规则
+			opcode.DELETE("/forwards/:id", forwardHandler.CancelForward)    // 取消转寄
 			opcode.GET("/:code", opcodeHandler.GetOPCode)                   // 获取OP Code信息
 
 			// 管理功能（需要额外权限验证）
 			opcodeAdmin := opcode.Group("/admin")
 			{
 				opcodeAdmin.POST("/applications/:application_id/review", opcodeHandler.AdminReviewApplication) // 审核申请
+				opcodeAdmin.PUT("/:code/location", opcodeHandler.AdminSetLocation)                             // 设置投递点坐标与签收围栏
+				opcodeAdmin.POST("/geo/import", opcodeHandler.AdminImportGeoJSON)                              // 导入校园地图 GeoJSON
+				opcodeAdmin.GET("/geo/export", opcodeHandler.AdminExportGeoJSON)                               // 导出完整校园地图
+				opcodeAdmin.POST("/imports", opcodeImportHandler.Import)                                       // 批量导入学校/片区/点位（CSV/XLSX）
+				opcodeAdmin.GET("/imports", opcodeImportHandler.ListImports)                                   // 导入批次列表
+				opcodeAdmin.GET("/imports/:id", opcodeImportHandler.GetImport)                                 // 导入批次详情与校验报告
+				opcodeAdmin.POST("/imports/:id/rollback", opcodeImportHandler.RollbackImport)                  // 按导入批次回滚
+				opcodeAdmin.GET("/export", opcodeImportHandler.Export)                                         // 导出学校编码树
 			}
 		}
 
@@ -975,6 +1174,11 @@ func main() {
 		admin.POST("/settings", systemSettingsHandler.ResetSettings)
 		admin.POST("/settings/test-email", systemSettingsHandler.TestEmailConfig)
 
+		// 信件状态同步（与courier-service）
+		admin.GET("/sync/status", letterSyncHandler.GetStatus)
+		admin.GET("/sync/divergences", letterSyncHandler.GetDivergences)
+		admin.POST("/sync/reconcile", letterSyncHandler.Reconcile)
+
 		// 用户管理
 		adminUsers := admin.Group("/users")
 		{
@@ -988,6 +1192,27 @@ func main() {
 			adminUsers.POST("/:id/reactivate", userHandler.AdminReactivateUser)
 		}
 
+		// 数据主体请求管理（导出/删除审计）
+		adminDataRequests := admin.Group("/data-requests")
+		{
+			adminDataRequests.GET("", dataRequestHandler.AdminListRequests)                          // 查询数据请求
+			adminDataRequests.GET("/:id/audit", dataRequestHandler.AdminGetAuditLogs)                // 查看审计记录
+			adminDataRequests.POST("/process-erasures", dataRequestHandler.AdminProcessErasures)     // 执行到期删除请求
+		}
+
+		// 校园统一身份认证配置
+		adminIdentityProviders := admin.Group("/identity-providers")
+		{
+			adminIdentityProviders.GET("", ssoHandler.AdminListProviders)      // 身份提供方列表
+			adminIdentityProviders.POST("", ssoHandler.AdminCreateProvider)    // 创建身份提供方
+			adminIdentityProviders.PUT("/:id", ssoHandler.AdminUpdateProvider) // 更新身份提供方
+		}
+
+		// 在校身份认证管理
+		admin.GET("/school-verifications", schoolVerificationHandler.AdminListVerifications)              // 查询认证记录
+		admin.POST("/school-verifications/:id/revoke", schoolVerificationHandler.AdminRevokeVerification) // 撤销认证
+		admin.PUT("/schools/:code/email-domains", schoolVerificationHandler.AdminUpdateEmailDomains)      // 设置学校认证邮箱域名
+
 		// 角色和任命管理
 		admin.GET("/roles", adminHandler.GetAppointableRoles)                     // 获取可任命角色列表
 		adminAppointments := admin.Group("/appointments")
@@ -1002,8 +1227,24 @@ func main() {
 		{
 			adminLetters.GET("/", adminHandler.GetLetters)                            // 获取信件列表
 			adminLetters.POST("/:id/moderate", adminHandler.ModerateLetter)          // 审核信件
+			adminLetters.POST("/:id/reissue-qr", qrTokenHandler.ReissueQRCode)        // 重新签发二维码，旧标签作废
+			adminLetters.GET("/:id/reroutes", forwardHandler.GetLetterReroutes)       // 信件转寄记录
 		}
 
+		// 信封标签打印记录
+		admin.GET("/envelopes/:id/print-history", envelopePrintHandler.GetEnvelopePrintHistory)
+
+		// 条码生命周期：滞留报告与过期回收
+		admin.GET("/barcodes/lifecycle", barcodeLifecycleHandler.GetReport)
+		admin.POST("/barcodes/lifecycle/run", barcodeLifecycleHandler.RunExpiry)
+		admin.POST("/barcodes/:code/reclaim", barcodeLifecycleHandler.Reclaim)
+
+		// 信封设计评选：创建、刷票复核与结算
+		admin.POST("/envelope-contests", envelopeContestHandler.CreateContest)
+		admin.GET("/envelope-contests/:id/anomalies", envelopeContestHandler.GetAnomalies)
+		admin.POST("/envelope-contests/:id/finalize", envelopeContestHandler.Finalize)
+		admin.POST("/envelope-contests/votes/:vote_id/review", envelopeContestHandler.ReviewVote)
+
 		// 信使管理
 		adminCouriers := admin.Group("/couriers")
 		{
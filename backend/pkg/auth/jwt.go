@@ -12,13 +12,19 @@ import (
 )
 
 type Claims struct {
-	UserID string          `json:"userId"`
-	Role   models.UserRole `json:"role"`
+	UserID    string          `json:"userId"`
+	Role      models.UserRole `json:"role"`
+	SessionID string          `json:"sid,omitempty"` // 所属登录会话，会话注销后令牌立即失效
 	jwt.RegisteredClaims
 }
 
 // GenerateJWT 生成JWT令牌
 func GenerateJWT(userID string, role models.UserRole, secret string, expiresAt time.Time) (string, error) {
+	return GenerateSessionJWT(userID, role, "", secret, expiresAt)
+}
+
+// GenerateSessionJWT 生成绑定登录会话的JWT令牌
+func GenerateSessionJWT(userID string, role models.UserRole, sessionID string, secret string, expiresAt time.Time) (string, error) {
 	// 生成唯一的JWT ID
 	jti, err := generateJTI()
 	if err != nil {
@@ -26,8 +32,9 @@ func GenerateJWT(userID string, role models.UserRole, secret string, expiresAt t
 	}
 
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        jti, // 添加JWT ID用于黑名单功能
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
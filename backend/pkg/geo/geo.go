@@ -0,0 +1,330 @@
+// Package geo 校园地理数据：GeoJSON 解析、点在多边形内判断与球面距离
+//
+// 坐标遵循 GeoJSON 约定，位置数组为 [经度, 纬度]，坐标系为 WGS84。
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GeoJSON 几何类型
+const (
+	TypePoint             = "Point"
+	TypePolygon           = "Polygon"
+	TypeMultiPolygon      = "MultiPolygon"
+	TypeFeature           = "Feature"
+	TypeFeatureCollection = "FeatureCollection"
+)
+
+const earthRadiusMeters = 6371000.0
+
+var (
+	ErrInvalidGeometry   = errors.New("几何数据无效")
+	ErrUnsupportedType   = errors.New("不支持的几何类型")
+	ErrInvalidCollection = errors.New("不是有效的 GeoJSON FeatureCollection")
+)
+
+// Point 经纬度坐标
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid 经纬度是否在合法范围内
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Polygon 多边形，第一个环为外边界，其余为内部空洞；环首尾闭合
+type Polygon [][]Point
+
+// MultiPolygon 多个多边形，用于不连续的片区
+type MultiPolygon []Polygon
+
+// BBox 外包框
+type BBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+// Contains 点是否在外包框内（含边界）
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+// Center 外包框中心
+func (b BBox) Center() Point {
+	return Point{Lat: (b.MinLat + b.MaxLat) / 2, Lng: (b.MinLng + b.MaxLng) / 2}
+}
+
+// Span 经纬度跨度之积，用于在重叠片区中挑选范围较小的一个
+func (b BBox) Span() float64 {
+	return (b.MaxLat - b.MinLat) * (b.MaxLng - b.MinLng)
+}
+
+// Extend 合并另一个外包框
+func (b BBox) Extend(other BBox) BBox {
+	return BBox{
+		MinLat: math.Min(b.MinLat, other.MinLat),
+		MinLng: math.Min(b.MinLng, other.MinLng),
+		MaxLat: math.Max(b.MaxLat, other.MaxLat),
+		MaxLng: math.Max(b.MaxLng, other.MaxLng),
+	}
+}
+
+// BBoxAround 以点为中心、半径为 radiusMeters 的外包框，用于空间查询预筛
+func BBoxAround(p Point, radiusMeters float64) BBox {
+	dLat := radiusMeters / earthRadiusMeters * 180 / math.Pi
+	cos := math.Cos(p.Lat * math.Pi / 180)
+	dLng := 180.0
+	if cos > 1e-6 {
+		dLng = math.Min(dLat/cos, 180)
+	}
+	return BBox{MinLat: p.Lat - dLat, MinLng: p.Lng - dLng, MaxLat: p.Lat + dLat, MaxLng: p.Lng + dLng}
+}
+
+// DistanceMeters 两点间球面距离（米），Haversine 公式
+func DistanceMeters(a, b Point) float64 {
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*math.Pi/180)*math.Cos(b.Lat*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadiusMeters * 2 * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
+
+// Contains 点是否在多边形内：在外边界内且不在任何空洞内
+func (p Polygon) Contains(pt Point) bool {
+	if len(p) == 0 || !ringContains(p[0], pt) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains 点是否在任一多边形内
+func (m MultiPolygon) Contains(pt Point) bool {
+	for _, polygon := range m {
+		if polygon.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
+
+// BBox 外边界的外包框
+func (m MultiPolygon) BBox() BBox {
+	box := BBox{MinLat: math.Inf(1), MinLng: math.Inf(1), MaxLat: math.Inf(-1), MaxLng: math.Inf(-1)}
+	for _, polygon := range m {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, pt := range polygon[0] {
+			box.MinLat = math.Min(box.MinLat, pt.Lat)
+			box.MinLng = math.Min(box.MinLng, pt.Lng)
+			box.MaxLat = math.Max(box.MaxLat, pt.Lat)
+			box.MaxLng = math.Max(box.MaxLng, pt.Lng)
+		}
+	}
+	return box
+}
+
+// ringContains 射线法判断点是否在环内
+func ringContains(ring []Point, pt Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > pt.Lat) != (b.Lat > pt.Lat) &&
+			pt.Lng < (b.Lng-a.Lng)*(pt.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Geometry GeoJSON 几何对象，坐标延迟解析
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Feature GeoJSON 要素
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection GeoJSON 要素集合
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection 创建空的要素集合
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: TypeFeatureCollection, Features: []Feature{}}
+}
+
+// ParseFeatureCollection 解析 GeoJSON FeatureCollection
+func ParseFeatureCollection(data []byte) (*FeatureCollection, error) {
+	var collection FeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCollection, err)
+	}
+	if collection.Type != TypeFeatureCollection {
+		return nil, ErrInvalidCollection
+	}
+	return &collection, nil
+}
+
+// StringProperty 读取字符串属性
+func (f *Feature) StringProperty(key string) string {
+	if value, ok := f.Properties[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// NumberProperty 读取数值属性
+func (f *Feature) NumberProperty(key string) (float64, bool) {
+	value, ok := f.Properties[key].(float64)
+	return value, ok
+}
+
+// Point 解析 Point 几何
+func (g *Geometry) Point() (Point, error) {
+	if g == nil || g.Type != TypePoint {
+		return Point{}, ErrUnsupportedType
+	}
+	var position []float64
+	if err := json.Unmarshal(g.Coordinates, &position); err != nil {
+		return Point{}, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+	return toPoint(position)
+}
+
+// MultiPolygon 解析 Polygon 或 MultiPolygon 几何，统一为 MultiPolygon
+func (g *Geometry) MultiPolygon() (MultiPolygon, error) {
+	if g == nil {
+		return nil, ErrUnsupportedType
+	}
+	var raw [][][][]float64
+	switch g.Type {
+	case TypePolygon:
+		var polygon [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		raw = [][][][]float64{polygon}
+	case TypeMultiPolygon:
+		if err := json.Unmarshal(g.Coordinates, &raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: 多边形为空", ErrInvalidGeometry)
+	}
+
+	result := make(MultiPolygon, 0, len(raw))
+	for _, rawPolygon := range raw {
+		if len(rawPolygon) == 0 {
+			return nil, fmt.Errorf("%w: 多边形缺少外边界", ErrInvalidGeometry)
+		}
+		polygon := make(Polygon, 0, len(rawPolygon))
+		for _, rawRing := range rawPolygon {
+			ring, err := toRing(rawRing)
+			if err != nil {
+				return nil, err
+			}
+			polygon = append(polygon, ring)
+		}
+		result = append(result, polygon)
+	}
+	return result, nil
+}
+
+// PointGeometry 生成 Point 几何
+func PointGeometry(p Point) *Geometry {
+	coordinates, _ := json.Marshal([]float64{p.Lng, p.Lat})
+	return &Geometry{Type: TypePoint, Coordinates: coordinates}
+}
+
+// Geometry 生成几何，只有一个多边形时输出 Polygon
+func (m MultiPolygon) Geometry() *Geometry {
+	raw := make([][][][]float64, len(m))
+	for i, polygon := range m {
+		raw[i] = make([][][]float64, len(polygon))
+		for j, ring := range polygon {
+			raw[i][j] = make([][]float64, len(ring))
+			for k, pt := range ring {
+				raw[i][j][k] = []float64{pt.Lng, pt.Lat}
+			}
+		}
+	}
+	if len(raw) == 1 {
+		coordinates, _ := json.Marshal(raw[0])
+		return &Geometry{Type: TypePolygon, Coordinates: coordinates}
+	}
+	coordinates, _ := json.Marshal(raw)
+	return &Geometry{Type: TypeMultiPolygon, Coordinates: coordinates}
+}
+
+// Marshal 序列化为 GeoJSON 几何字符串，便于入库
+func (m MultiPolygon) Marshal() (string, error) {
+	data, err := json.Marshal(m.Geometry())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// UnmarshalMultiPolygon 从入库的 GeoJSON 几何字符串还原
+func UnmarshalMultiPolygon(data string) (MultiPolygon, error) {
+	var geometry Geometry
+	if err := json.Unmarshal([]byte(data), &geometry); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+	return geometry.MultiPolygon()
+}
+
+func toPoint(position []float64) (Point, error) {
+	if len(position) < 2 {
+		return Point{}, fmt.Errorf("%w: 坐标至少包含经度和纬度", ErrInvalidGeometry)
+	}
+	pt := Point{Lng: position[0], Lat: position[1]}
+	if !pt.Valid() {
+		return Point{}, fmt.Errorf("%w: 坐标超出范围 [%v, %v]", ErrInvalidGeometry, position[0], position[1])
+	}
+	return pt, nil
+}
+
+// toRing 解析并校验环：至少 4 个位置且首尾闭合
+func toRing(raw [][]float64) ([]Point, error) {
+	if len(raw) < 4 {
+		return nil, fmt.Errorf("%w: 多边形环至少需要 4 个坐标", ErrInvalidGeometry)
+	}
+	ring := make([]Point, 0, len(raw))
+	for _, position := range raw {
+		pt, err := toPoint(position)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, pt)
+	}
+	if ring[0] != ring[len(ring)-1] {
+		return nil, fmt.Errorf("%w: 多边形环首尾未闭合", ErrInvalidGeometry)
+	}
+	return ring, nil
+}
@@ -0,0 +1,249 @@
+// Package label 条码标签排版，生成可直接打印的 PDF
+//
+// 支持 A4 不干胶标签纸（按行列排布）和信封尺寸页面（每页一个信封），
+// 条码可选 Code128 或二维码，信封设计图作为标签或整页背景。
+package label
+
+import (
+	"fmt"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// 条码类型
+const (
+	SymbologyCode128 = "code128"
+	SymbologyQR      = "qr"
+)
+
+// Layout 页面排版，尺寸单位为 pt
+type Layout struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width"`
+	LabelHeight float64 `json:"label_height"`
+	MarginLeft  float64 `json:"margin_left"`
+	MarginTop   float64 `json:"margin_top"`
+	GapX        float64 `json:"gap_x"`
+	GapY        float64 `json:"gap_y"`
+	Envelope    bool    `json:"envelope"` // 信封尺寸：背景铺满整页，标签区位于右下角
+}
+
+// PerPage 每页标签数
+func (l Layout) PerPage() int {
+	return l.Columns * l.Rows
+}
+
+// 内置排版
+var layouts = map[string]Layout{
+	"a4_3x8": {
+		Name: "a4_3x8", Description: "A4 不干胶 3×8（70×37mm）",
+		PageWidth: 210 * MM, PageHeight: 297 * MM, Columns: 3, Rows: 8,
+		LabelWidth: 70 * MM, LabelHeight: 37 * MM, MarginTop: 0.5 * MM,
+	},
+	"a4_2x7": {
+		Name: "a4_2x7", Description: "A4 不干胶 2×7（99.1×38.1mm）",
+		PageWidth: 210 * MM, PageHeight: 297 * MM, Columns: 2, Rows: 7,
+		LabelWidth: 99.1 * MM, LabelHeight: 38.1 * MM, MarginLeft: 4.65 * MM, MarginTop: 15.15 * MM, GapX: 2.5 * MM,
+	},
+	"envelope_dl": {
+		Name: "envelope_dl", Description: "DL 信封（220×110mm）",
+		PageWidth: 220 * MM, PageHeight: 110 * MM, Columns: 1, Rows: 1,
+		LabelWidth: 90 * MM, LabelHeight: 36 * MM, Envelope: true,
+	},
+	"envelope_c6": {
+		Name: "envelope_c6", Description: "C6 信封（162×114mm）",
+		PageWidth: 162 * MM, PageHeight: 114 * MM, Columns: 1, Rows: 1,
+		LabelWidth: 80 * MM, LabelHeight: 34 * MM, Envelope: true,
+	},
+}
+
+// GetLayout 按名称获取内置排版
+func GetLayout(name string) (Layout, bool) {
+	layout, ok := layouts[name]
+	return layout, ok
+}
+
+// Layouts 全部内置排版
+func Layouts() []Layout {
+	names := []string{"a4_3x8", "a4_2x7", "envelope_dl", "envelope_c6"}
+	result := make([]Layout, 0, len(names))
+	for _, name := range names {
+		result = append(result, layouts[name])
+	}
+	return result
+}
+
+// Label 单个标签内容
+type Label struct {
+	Code            string // 条码编号
+	QRContent       string // 二维码内容，为空时使用编号
+	RecipientOPCode string // 为空时留出手写格
+	SenderOPCode    string
+	Caption         string // 标签顶部的说明，如信封主题
+}
+
+// Options 渲染选项
+type Options struct {
+	Layout        Layout
+	Symbology     string
+	Background    *Image // 信封设计图，可为空
+	StartPosition int    // 从第几个标签位开始（0起），用于续打用过一部分的标签纸
+}
+
+// Render 渲染标签，返回 PDF 内容和页数
+func Render(labels []Label, opts Options) ([]byte, int, error) {
+	layout := opts.Layout
+	perPage := layout.PerPage()
+	if perPage <= 0 {
+		return nil, 0, fmt.Errorf("label: invalid layout %q", layout.Name)
+	}
+	if opts.StartPosition < 0 || opts.StartPosition >= perPage {
+		opts.StartPosition = 0
+	}
+
+	doc := NewDocument(layout.PageWidth, layout.PageHeight)
+	for i, item := range labels {
+		position := opts.StartPosition + i
+		slot := position % perPage
+		if slot == 0 || i == 0 {
+			doc.AddPage()
+		}
+
+		if layout.Envelope {
+			if opts.Background != nil {
+				doc.DrawImage(opts.Background, 0, 0, layout.PageWidth, layout.PageHeight)
+			}
+			x := layout.PageWidth - layout.LabelWidth - 8*MM
+			if err := drawLabel(doc, item, opts.Symbology, x, 8*MM, layout.LabelWidth, layout.LabelHeight, nil); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		column, row := slot%layout.Columns, slot/layout.Columns
+		x := layout.MarginLeft + float64(column)*(layout.LabelWidth+layout.GapX)
+		y := layout.PageHeight - layout.MarginTop - float64(row+1)*layout.LabelHeight - float64(row)*layout.GapY
+		if err := drawLabel(doc, item, opts.Symbology, x, y, layout.LabelWidth, layout.LabelHeight, opts.Background); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return doc.Bytes(), doc.PageCount(), nil
+}
+
+// drawLabel 在指定区域绘制一个标签
+func drawLabel(doc *Document, item Label, symbology string, x, y, width, height float64, background *Image) error {
+	if background != nil {
+		doc.DrawImage(background, x, y, width, height)
+	}
+
+	pad := 2.5 * MM
+	// 白底保证条码在设计图上可识读
+	doc.FillRect(x+pad/2, y+pad/2, width-pad, height-pad, 1)
+
+	recipient := item.RecipientOPCode
+	if recipient == "" {
+		recipient = "______"
+	}
+	sender := item.SenderOPCode
+	if sender == "" {
+		sender = "______"
+	}
+
+	if symbology == SymbologyQR {
+		size := height - 2*pad
+		content := item.QRContent
+		if content == "" {
+			content = item.Code
+		}
+		if err := drawQR(doc, content, x+pad, y+pad, size); err != nil {
+			return err
+		}
+
+		textX := x + pad*2 + size
+		top := y + height - pad
+		if item.Caption != "" {
+			doc.Text(textX, top-9, 8, item.Caption)
+			top -= 11
+		}
+		// 二维码占去左侧，右侧文字按窄栏排
+		doc.MonoText(textX, top-10, 7.5, item.Code)
+		doc.Text(textX, top-24, 8, "收件 "+recipient)
+		doc.Text(textX, top-36, 8, "寄件 "+sender)
+		return nil
+	}
+
+	modules, err := Code128(item.Code)
+	if err != nil {
+		return err
+	}
+
+	// 两侧各留 10 个模块的静区
+	available := width - 2*pad
+	moduleWidth := available / float64(len(modules)+20)
+	barHeight := height * 0.38
+	barX := x + pad + 10*moduleWidth
+	barY := y + pad + 10
+	drawBars(doc, modules, barX, barY, moduleWidth, barHeight)
+
+	codeSize := 8.0
+	doc.MonoText(x+(width-MonoTextWidth(codeSize, item.Code))/2, y+pad+1, codeSize, item.Code)
+
+	top := y + height - pad
+	if item.Caption != "" {
+		doc.Text(x+pad, top-9, 8, item.Caption)
+		top -= 11
+	}
+	doc.Text(x+pad, top-9, 7.5, "收件 OP Code: "+recipient+"   寄件 OP Code: "+sender)
+	return nil
+}
+
+// drawBars 绘制条码，相邻的条合并为一个矩形
+func drawBars(doc *Document, modules []bool, x, y, moduleWidth, height float64) {
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modules) && modules[i] {
+			i++
+		}
+		doc.FillRect(x+float64(start)*moduleWidth, y, float64(i-start)*moduleWidth, height, 0)
+	}
+}
+
+// drawQR 绘制二维码（含静区），逐行合并深色模块
+func drawQR(doc *Document, content string, x, y, size float64) error {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("label: failed to encode qr: %w", err)
+	}
+	// 保留四个模块的白边作为静区
+	bitmap := qr.Bitmap()
+	if len(bitmap) == 0 {
+		return nil
+	}
+
+	module := size / float64(len(bitmap))
+	for row, line := range bitmap {
+		rowY := y + size - float64(row+1)*module
+		for col := 0; col < len(line); {
+			if !line[col] {
+				col++
+				continue
+			}
+			start := col
+			for col < len(line) && line[col] {
+				col++
+			}
+			doc.FillRect(x+float64(start)*module, rowY, float64(col-start)*module, module, 0)
+		}
+	}
+	return nil
+}
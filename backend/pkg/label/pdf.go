@@ -0,0 +1,267 @@
+package label
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // 注册 JPEG 解码
+	_ "image/png"  // 注册 PNG 解码
+	"strings"
+	"unicode/utf16"
+)
+
+// MM 毫米换算为 PDF 点
+const MM = 72.0 / 25.4
+
+// Image PDF 图片对象，JPEG 原样嵌入，其他格式转为 RGB 后压缩
+type Image struct {
+	Width, Height int
+	colorSpace    string
+	filter        string
+	decode        string
+	data          []byte
+}
+
+// LoadImage 从 JPEG/PNG 文件内容创建图片
+func LoadImage(data []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("label: unsupported image: %w", err)
+	}
+
+	if format == "jpeg" {
+		img := &Image{Width: cfg.Width, Height: cfg.Height, filter: "DCTDecode", data: data}
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			img.colorSpace = "DeviceGray"
+		case color.CMYKModel:
+			// Adobe 写出的 CMYK JPEG 为反相存储
+			img.colorSpace, img.decode = "DeviceCMYK", "[1 0 1 0 1 0 1 0]"
+		default:
+			img.colorSpace = "DeviceRGB"
+		}
+		return img, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("label: failed to decode image: %w", err)
+	}
+	return FromImage(decoded), nil
+}
+
+// FromImage 将任意图片转为 RGB，透明部分按白色底合成
+func FromImage(src image.Image) *Image {
+	bounds := src.Bounds()
+	raw := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := src.At(x, y).RGBA()
+			white := 0xFFFF - a
+			raw = append(raw, byte((r+white)>>8), byte((g+white)>>8), byte((b+white)>>8))
+		}
+	}
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(raw)
+	w.Close()
+
+	return &Image{
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		colorSpace: "DeviceRGB",
+		filter:     "FlateDecode",
+		data:       compressed.Bytes(),
+	}
+}
+
+// Document 固定页面尺寸的 PDF 文档
+// 中文使用阅读器内置的 STSong-Light 字体，编号使用 Courier 等宽字体，均不嵌入字体文件
+type Document struct {
+	width, height float64
+	pages         []*bytes.Buffer
+	current       *bytes.Buffer
+	images        []*Image
+	imageIndex    map[*Image]int
+}
+
+// NewDocument 创建指定页面尺寸（pt）的文档
+func NewDocument(width, height float64) *Document {
+	return &Document{width: width, height: height, imageIndex: make(map[*Image]int)}
+}
+
+// AddPage 新增一页
+func (d *Document) AddPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+}
+
+// PageCount 页数
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if d.current == nil {
+		d.AddPage()
+	}
+	return d.current
+}
+
+// Text 输出中文或混排文字，坐标原点为页面左下角
+func (d *Document) Text(x, y, size float64, text string) {
+	fmt.Fprintf(d.page(), "0 g BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, hexText(text))
+}
+
+// MonoText 输出等宽的 ASCII 文字，用于条码下方的可读编号
+func (d *Document) MonoText(x, y, size float64, text string) {
+	fmt.Fprintf(d.page(), "0 g BT /F2 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", size, x, y, escapeText(text))
+}
+
+// FillRect 填充矩形，gray 为灰度（0 黑 1 白）
+func (d *Document) FillRect(x, y, width, height, gray float64) {
+	fmt.Fprintf(d.page(), "%.3f g %.3f %.3f %.3f %.3f re f\n", gray, x, y, width, height)
+}
+
+// StrokeRect 绘制矩形边框
+func (d *Document) StrokeRect(x, y, width, height float64) {
+	fmt.Fprintf(d.page(), "0 G 0.5 w %.2f %.2f %.2f %.2f re S\n", x, y, width, height)
+}
+
+// Line 绘制直线
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0 G 0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// DrawImage 将图片拉伸绘制到指定区域，同一图片在文档中只嵌入一次
+func (d *Document) DrawImage(img *Image, x, y, width, height float64) {
+	index, ok := d.imageIndex[img]
+	if !ok {
+		index = len(d.images)
+		d.images = append(d.images, img)
+		d.imageIndex[img] = index
+	}
+	fmt.Fprintf(d.page(), "q %.3f 0 0 %.3f %.3f %.3f cm /Im%d Do Q\n", width, height, x, y, index+1)
+}
+
+// Bytes 生成 PDF 文件内容
+func (d *Document) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	// 1 目录 2 页面树 3-5 中文字体 6 等宽字体，之后为图片，最后每页依次为页面对象和内容流
+	const firstImageObject = 7
+	firstPageObject := firstImageObject + len(d.images)
+
+	xobjects := make([]string, len(d.images))
+	for i := range d.images {
+		xobjects[i] = fmt.Sprintf("/Im%d %d 0 R", i+1, firstImageObject+i)
+	}
+	resources := "/Font << /F1 3 0 R /F2 6 0 R >>"
+	if len(xobjects) > 0 {
+		resources += " /XObject << " + strings.Join(xobjects, " ") + " >>"
+	}
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+i*2)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	object("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	object("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for _, img := range d.images {
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s",
+			img.Width, img.Height, img.colorSpace, img.filter)
+		if img.decode != "" {
+			dict += " /Decode " + img.decode
+		}
+		stream(dict, img.data)
+	}
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << %s >> /Contents %d 0 R >>",
+			d.width, d.height, resources, firstPageObject+i*2+1))
+		stream("", page.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// TextWidth 估算文字宽度：ASCII 半角，其余全角
+func TextWidth(size float64, text string) float64 {
+	width := 0.0
+	for _, r := range text {
+		if r < 0x80 {
+			width += size * 0.5
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// MonoTextWidth Courier 字体宽度
+func MonoTextWidth(size float64, text string) float64 {
+	return size * 0.6 * float64(len(text))
+}
+
+// hexText 将文字编码为 UCS-2 十六进制串，超出基本平面的字符以问号代替
+func hexText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// escapeText 转义 PDF 字符串，非 ASCII 字符以问号代替
+func escapeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+// Package qrtoken 信件二维码签名令牌
+//
+// 令牌格式：OPQ1.<kid>.<claims>.<signature>
+// claims 为 base64url 编码的 JSON，signature 为对 "OPQ1.<kid>.<claims>" 的 Ed25519 签名（base64url）。
+// 信使端持有公钥即可离线验证，kid 用于密钥轮换。
+package qrtoken
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Prefix 令牌版本前缀
+const Prefix = "OPQ1"
+
+// Algorithm 签名算法
+const Algorithm = "EdDSA"
+
+var (
+	ErrMalformed    = errors.New("qrtoken: malformed token")
+	ErrUnknownKey   = errors.New("qrtoken: unknown key id")
+	ErrBadSignature = errors.New("qrtoken: invalid signature")
+	ErrExpired      = errors.New("qrtoken: token expired")
+	ErrNoSigningKey = errors.New("qrtoken: no signing key configured")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Claims 令牌声明，字段名尽量短以控制二维码密度
+type Claims struct {
+	Code            string `json:"c"`           // 信件编号
+	RecipientOPCode string `json:"r,omitempty"` // 收件人OP Code，供信使离线分拣
+	Nonce           string `json:"n"`           // 标签序号，重新打印后旧标签失效
+	IssuedAt        int64  `json:"iat"`
+	ExpiresAt       int64  `json:"exp"`
+}
+
+// PublicKey 对外发布的公钥，格式兼容 JWK（OKP/Ed25519）
+type PublicKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	Algorithm string `json:"alg"`
+	X         string `json:"x"`
+	Active    bool   `json:"active"` // 当前用于签发
+}
+
+// KeySet 按 kid 管理的签名密钥，active 用于签发，其余仅用于验证
+type KeySet struct {
+	active  string
+	private map[string]ed25519.PrivateKey
+	public  map[string]ed25519.PublicKey
+}
+
+// NewKeySet 创建空密钥集
+func NewKeySet() *KeySet {
+	return &KeySet{
+		private: make(map[string]ed25519.PrivateKey),
+		public:  make(map[string]ed25519.PublicKey),
+	}
+}
+
+// AddSeed 以32字节种子添加签名密钥
+func (ks *KeySet) AddSeed(kid string, seed []byte) error {
+	if kid == "" || strings.Contains(kid, ".") {
+		return fmt.Errorf("qrtoken: invalid key id %q", kid)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("qrtoken: key %s seed must be %d bytes", kid, ed25519.SeedSize)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	ks.private[kid] = private
+	ks.public[kid] = private.Public().(ed25519.PublicKey)
+	if ks.active == "" {
+		ks.active = kid
+	}
+	return nil
+}
+
+// AddPublicKey 添加仅用于验证的公钥
+func (ks *KeySet) AddPublicKey(kid string, key ed25519.PublicKey) error {
+	if kid == "" || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("qrtoken: invalid public key %q", kid)
+	}
+	ks.public[kid] = key
+	return nil
+}
+
+// SetActive 设置签发使用的密钥
+func (ks *KeySet) SetActive(kid string) error {
+	if _, ok := ks.private[kid]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	ks.active = kid
+	return nil
+}
+
+// ActiveKeyID 当前签发使用的密钥ID
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active
+}
+
+// PublicKeys 返回全部公钥，供信使端离线验证
+func (ks *KeySet) PublicKeys() []PublicKey {
+	keys := make([]PublicKey, 0, len(ks.public))
+	for kid, key := range ks.public {
+		keys = append(keys, PublicKey{
+			KeyID:     kid,
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			Algorithm: Algorithm,
+			X:         encoding.EncodeToString(key),
+			Active:    kid == ks.active,
+		})
+	}
+	return keys
+}
+
+// Sign 使用当前密钥签发令牌，Nonce 为空时随机生成
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	private, ok := ks.private[ks.active]
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+	if claims.Nonce == "" {
+		nonce, err := NewNonce()
+		if err != nil {
+			return "", err
+		}
+		claims.Nonce = nonce
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := Prefix + "." + ks.active + "." + encoding.EncodeToString(payload)
+	signature := ed25519.Sign(private, []byte(signingInput))
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// Verify 校验签名和有效期，now 为零值时不检查有效期
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != Prefix {
+		return nil, ErrMalformed
+	}
+
+	key, ok := ks.public[parts[1]]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	signature, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !ed25519.Verify(key, []byte(strings.Join(parts[:3], ".")), signature) {
+		return nil, ErrBadSignature
+	}
+
+	payload, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Code == "" {
+		return nil, ErrMalformed
+	}
+	if !now.IsZero() && claims.ExpiresAt > 0 && now.Unix() >= claims.ExpiresAt {
+		return &claims, ErrExpired
+	}
+	return &claims, nil
+}
+
+// IsToken 内容是否为签名令牌格式
+func IsToken(content string) bool {
+	return strings.HasPrefix(content, Prefix+".") && strings.Count(content, ".") == 3
+}
+
+// NewNonce 生成标签序号
+func NewNonce() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("qrtoken: failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// DeriveSeed 由任意密钥材料派生32字节种子，用于未单独配置签名密钥的开发环境
+func DeriveSeed(secret string) []byte {
+	sum := sha256.Sum256([]byte("openpenpal-qr-signing:" + secret))
+	return sum[:]
+}
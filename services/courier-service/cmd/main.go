@@ -43,6 +43,25 @@ func main() {
 	// 初始化监控系统
 	monitoring.InitGlobalMetrics(logger)
 	monitoring.InitGlobalAlertManager(monitoring.GetGlobalRegistry(), logger)
+	alertManager := monitoring.GetGlobalAlertManager()
+	alertManager.SetGrouping([]string{"level"}, time.Duration(cfg.Alerting.GroupWaitSeconds)*time.Second)
+	minAlertLevel := monitoring.AlertLevel(cfg.Alerting.MinLevel)
+	if cfg.Alerting.WebhookURL != "" {
+		webhookHandler := monitoring.NewWebhookAlertHandler(cfg.Alerting.WebhookURL, cfg.Alerting.WebhookSecret)
+		webhookHandler.MinLevel = minAlertLevel
+		alertManager.RegisterHandler(webhookHandler)
+	}
+	if cfg.Alerting.ChatWebhookURL != "" {
+		chatHandler := monitoring.NewChatAlertHandler(monitoring.ChatAlertFlavor(cfg.Alerting.ChatType), cfg.Alerting.ChatWebhookURL, cfg.Alerting.ChatSecret)
+		chatHandler.MinLevel = minAlertLevel
+		alertManager.RegisterHandler(chatHandler)
+	}
+	if cfg.Alerting.SMTPHost != "" && len(cfg.Alerting.EmailTo) > 0 {
+		emailHandler := monitoring.NewEmailAlertHandler(cfg.Alerting.SMTPHost, cfg.Alerting.SMTPPort,
+			cfg.Alerting.SMTPUsername, cfg.Alerting.SMTPPassword, cfg.Alerting.EmailFrom, cfg.Alerting.EmailTo)
+		emailHandler.MinLevel = minAlertLevel
+		alertManager.RegisterHandler(emailHandler)
+	}
 
 	// 初始化数据库
 	db, err := config.InitDatabase(cfg.DatabaseURL)
@@ -62,7 +81,8 @@ func main() {
 	courierService := services.NewCourierService(db, redisClient, wsManager)
 	taskService := services.NewTaskService(db, redisClient, wsManager)
 	locationService := services.NewLocationService()
-	assignmentService := services.NewAssignmentService(db, locationService, wsManager)
+	availabilityService := services.NewAvailabilityService(db, wsManager)
+	assignmentService := services.NewAssignmentService(db, locationService, availabilityService, wsManager)
 	queueService := services.NewQueueService(redisClient, db, wsManager, assignmentService)
 	levelService := services.NewCourierLevelService(db, redisClient, wsManager)
 	growthService := services.NewCourierGrowthService(db, redisClient, wsManager)
@@ -71,12 +91,67 @@ func main() {
 	leaderboardService := services.NewLeaderboardService(db, wsManager)
 	hierarchicalAssignmentService := services.NewHierarchicalAssignmentService(db, assignmentService, hierarchyService, wsManager)
 	signalCodeService := services.NewSignalCodeService(db)
+	routePlannerService := services.NewRoutePlannerService(db, locationService)
+	relayService := services.NewRelayService(db, wsManager)
+	deliveryFailureService := services.NewDeliveryFailureService(db, queueService, wsManager)
+	if err := deliveryFailureService.EnsureDefaultPolicies(); err != nil {
+		logger.Warn("Failed to seed delivery retry policies", "error", err)
+	}
+	eventSyncService := services.NewEventSyncService(db, redisClient, wsManager)
+	settlementService := services.NewSettlementService(db, wsManager)
+	slaService := services.NewSLAService(db, hierarchicalAssignmentService, wsManager)
+	if err := slaService.EnsureDefaultPolicies(); err != nil {
+		logger.Warn("Failed to seed SLA policies", "error", err)
+	}
+
+	// 信件二维码签名验证，公钥来自配置或主服务
+	qrVerifier := utils.NewQRTokenVerifier(cfg.QRToken.Required)
+	if err := qrVerifier.LoadKeys(cfg.QRToken.PublicKeys); err != nil {
+		logger.Warn("Failed to load QR public keys", "error", err)
+	}
+	if cfg.QRToken.KeysURL != "" {
+		go qrVerifier.StartAutoRefresh(cfg.QRToken.KeysURL, time.Duration(cfg.QRToken.RefreshIntervalMinutes)*time.Minute, func(err error) {
+			logger.Warn("Failed to refresh QR public keys", "url", cfg.QRToken.KeysURL, "error", err)
+		})
+	}
+	taskService.SetQRTokenVerifier(qrVerifier)
+	relayService.SetQRTokenVerifier(qrVerifier)
+
+	// 送达须有主服务签收凭证，签名密钥与主服务共用 JWT 密钥派生
+	receiptVerifier := services.NewDeliveryReceiptVerifier(cfg.JWTSecret)
+	taskService.SetDeliveryReceiptVerifier(receiptVerifier)
+	relayService.SetDeliveryReceiptVerifier(receiptVerifier)
+	eventSyncService.SetDeliveryReceiptVerifier(receiptVerifier)
+
+	// 校园地图：片区边界与投递点坐标，来自本地文件或主服务
+	campusGeodata := utils.NewCampusGeodata()
+	if cfg.Geodata.File != "" {
+		if areas, points, err := campusGeodata.LoadFile(cfg.Geodata.File); err != nil {
+			logger.Warn("Failed to load campus geodata", "file", cfg.Geodata.File, "error", err)
+		} else {
+			logger.Info("Campus geodata loaded", "areas", areas, "points", points)
+		}
+	}
+	if cfg.Geodata.URL != "" {
+		go campusGeodata.StartAutoRefresh(cfg.Geodata.URL, time.Duration(cfg.Geodata.RefreshIntervalMinutes)*time.Minute, func(err error) {
+			logger.Warn("Failed to refresh campus geodata", "url", cfg.Geodata.URL, "error", err)
+		})
+	}
+	locationService.SetGeodata(campusGeodata)
+	taskService.SetLocationService(locationService)
+	taskService.SetDeliveryFailureService(deliveryFailureService)
+	eventSyncService.SetLocationService(locationService)
 
 	// 启动队列消费者
 	go queueService.ConsumeTaskQueues()
 	go queueService.ConsumeAssignmentQueue()
 	go queueService.ConsumeNotificationQueue()
 	go queueService.ProcessRetryQueue()
+	go deliveryFailureService.StartRetryScheduler()
+	go slaService.StartMonitor()
+	go eventSyncService.StartDispatcher()
+	go eventSyncService.StartConsumer()
+	go eventSyncService.StartReconciler()
 
 	// 初始化路由
 	router := gin.New() // 使用gin.New()而不是gin.Default()来完全控制中间件
@@ -144,13 +219,21 @@ func main() {
 	// 注册路由
 	handlers.RegisterCourierRoutes(api, courierService)
 	handlers.RegisterTaskRoutes(api, taskService, queueService)
-	handlers.RegisterScanRoutes(api, taskService, locationService)
+	handlers.RegisterScanRoutes(api, taskService, locationService, deliveryFailureService)
+	handlers.RegisterDeliveryFailureRoutes(api, deliveryFailureService)
+	handlers.RegisterRouteRoutes(api, routePlannerService)
 	handlers.RegisterCourierLevelRoutes(api, courierService, levelService)
 	handlers.RegisterCourierGrowthRoutes(api, growthService)
 	handlers.RegisterPostalManagementRoutes(api, postalService)
 	handlers.RegisterHierarchyRoutes(api, hierarchyService)
 	handlers.RegisterLeaderboardRoutes(api, leaderboardService)
 	handlers.RegisterHierarchicalAssignmentRoutes(api, hierarchicalAssignmentService)
+	handlers.RegisterRelayRoutes(api, relayService)
+	handlers.RegisterSLARoutes(api, slaService)
+	handlers.RegisterAvailabilityRoutes(api, availabilityService, assignmentService)
+	handlers.RegisterEventSyncRoutes(api, eventSyncService)
+	handlers.RegisterSettlementRoutes(api, settlementService)
+	handlers.RegisterAlertRoutes(api, alertManager)
 
 	// 注册信号编码路由 (不需要JWT认证，因为有些接口是公开的)
 	signalCodeHandler := handlers.NewSignalCodeHandler(signalCodeService)
@@ -186,7 +269,6 @@ func main() {
 
 	// 告警状态端点
 	router.GET("/alerts", func(c *gin.Context) {
-		alertManager := monitoring.GetGlobalAlertManager()
 		alerts := alertManager.GetActiveAlerts()
 		c.JSON(200, alerts)
 	})
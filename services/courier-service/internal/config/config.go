@@ -3,6 +3,7 @@ package config
 import (
 	"courier-service/internal/models"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -17,6 +18,41 @@ type Config struct {
 	JWTSecret    string
 	Environment  string
 	WebSocketURL string
+	Alerting     AlertingConfig
+	QRToken      QRTokenConfig
+	Geodata      GeodataConfig
+}
+
+// GeodataConfig 校园地图配置，URL 与本地文件都配置时先加载文件再定期从主服务刷新
+type GeodataConfig struct {
+	URL                    string // 主服务校园地图地址，如 http://backend:8080/api/v1/opcode/geo
+	File                   string // 本地 GeoJSON 文件
+	RefreshIntervalMinutes int
+}
+
+// QRTokenConfig 信件二维码签名验证配置
+type QRTokenConfig struct {
+	PublicKeys             string // kid:base64url公钥，逗号分隔
+	KeysURL                string // 主服务公钥地址，启动时和定期拉取
+	RefreshIntervalMinutes int
+	Required               bool // 扫码必须携带有效签名令牌
+}
+
+// AlertingConfig 告警通知渠道配置，未配置地址的渠道不启用
+type AlertingConfig struct {
+	MinLevel         string // 外部渠道的最低告警级别
+	GroupWaitSeconds int    // 分组等待时间
+	WebhookURL       string // 通用 Webhook
+	WebhookSecret    string // HMAC 签名密钥
+	ChatType         string // dingtalk 或 feishu
+	ChatWebhookURL   string // 群机器人地址
+	ChatSecret       string // 群机器人加签密钥
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	EmailFrom        string
+	EmailTo          []string // 逗号分隔
 }
 
 func Load() *Config {
@@ -30,6 +66,32 @@ func Load() *Config {
 		JWTSecret:    getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
 		Environment:  getEnv("ENVIRONMENT", "development"),
 		WebSocketURL: getEnv("WEBSOCKET_URL", "ws://localhost:8080/ws"),
+		Alerting: AlertingConfig{
+			MinLevel:         getEnv("ALERT_MIN_LEVEL", "WARNING"),
+			GroupWaitSeconds: getEnvInt("ALERT_GROUP_WAIT_SECONDS", 30),
+			WebhookURL:       getEnv("ALERT_WEBHOOK_URL", ""),
+			WebhookSecret:    getEnv("ALERT_WEBHOOK_SECRET", ""),
+			ChatType:         getEnv("ALERT_CHAT_TYPE", "dingtalk"),
+			ChatWebhookURL:   getEnv("ALERT_CHAT_WEBHOOK_URL", ""),
+			ChatSecret:       getEnv("ALERT_CHAT_SECRET", ""),
+			SMTPHost:         getEnv("ALERT_SMTP_HOST", ""),
+			SMTPPort:         getEnvInt("ALERT_SMTP_PORT", 587),
+			SMTPUsername:     getEnv("ALERT_SMTP_USERNAME", ""),
+			SMTPPassword:     getEnv("ALERT_SMTP_PASSWORD", ""),
+			EmailFrom:        getEnv("ALERT_EMAIL_FROM", ""),
+			EmailTo:          splitEnvList(getEnv("ALERT_EMAIL_TO", "")),
+		},
+		QRToken: QRTokenConfig{
+			PublicKeys:             getEnv("QR_PUBLIC_KEYS", ""),
+			KeysURL:                getEnv("QR_KEYS_URL", ""),
+			RefreshIntervalMinutes: getEnvInt("QR_KEYS_REFRESH_MINUTES", 60),
+			Required:               getEnv("QR_TOKEN_REQUIRED", "false") == "true",
+		},
+		Geodata: GeodataConfig{
+			URL:                    getEnv("CAMPUS_GEODATA_URL", ""),
+			File:                   getEnv("CAMPUS_GEODATA_FILE", ""),
+			RefreshIntervalMinutes: getEnvInt("CAMPUS_GEODATA_REFRESH_MINUTES", 60),
+		},
 	}
 }
 
@@ -40,6 +102,23 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func splitEnvList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func InitDatabase(databaseURL string) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
@@ -131,6 +210,25 @@ func autoMigrate(db *gorm.DB) error {
 		&models.TaskAssignmentHistory{},
 		&models.Task{},
 		&models.ScanRecord{},
+		&models.ScanSyncEvent{},
+		&models.RelayLeg{},
+		&models.RelayHandoff{},
+		&models.DeliveryRetryPolicy{},
+		&models.DeliveryAttempt{},
+		&models.TaskSLAPolicy{},
+		&models.SLABreach{},
+		&models.CourierAvailabilityWindow{},
+		&models.CourierTimeOff{},
+		&models.CourierDutyStatus{},
+		&models.TaskOutboxEvent{},
+		&models.TaskSyncInbox{},
+		&models.TaskSyncState{},
+		&models.TaskSyncDivergence{},
+		&models.SettlementPeriod{},
+		&models.CourierStatement{},
+		&models.StatementLine{},
+		&models.StatementDispute{},
+		&models.CourierIncentiveClaim{},
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/monitoring"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler 告警管理处理器
+type AlertHandler struct {
+	alertManager *monitoring.AlertManager
+}
+
+// NewAlertHandler 创建告警管理处理器
+func NewAlertHandler(alertManager *monitoring.AlertManager) *AlertHandler {
+	return &AlertHandler{
+		alertManager: alertManager,
+	}
+}
+
+// GetAlertHistory 获取告警通知历史（管理员功能）
+func (h *AlertHandler) GetAlertHistory(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	history := h.alertManager.GetAlertHistory(limit)
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"alerts": history,
+		"total":  len(history),
+	}))
+}
+
+// GetSilences 获取告警静默规则（管理员功能）
+func (h *AlertHandler) GetSilences(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	includeExpired := c.Query("include_expired") == "true"
+	c.JSON(http.StatusOK, models.SuccessResponse(h.alertManager.GetSilences(includeExpired)))
+}
+
+// CreateSilence 创建告警静默规则（管理员功能）
+func (h *AlertHandler) CreateSilence(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	var request models.AlertSilenceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	startsAt := time.Now()
+	if request.StartsAt != nil {
+		startsAt = *request.StartsAt
+	}
+
+	silence, err := h.alertManager.AddSilence(&monitoring.Silence{
+		Matchers:  request.Matchers,
+		Comment:   request.Comment,
+		CreatedBy: middleware.GetUserID(c),
+		StartsAt:  startsAt,
+		EndsAt:    startsAt.Add(time.Duration(request.DurationMinutes) * time.Minute),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Failed to create silence",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(silence))
+}
+
+// ExpireSilence 立即结束告警静默（管理员功能）
+func (h *AlertHandler) ExpireSilence(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	if err := h.alertManager.ExpireSilence(c.Param("id")); err != nil {
+		status, code := http.StatusInternalServerError, models.CodeInternalError
+		if errors.Is(err, monitoring.ErrSilenceNotFound) {
+			status, code = http.StatusNotFound, models.CodeNotFound
+		}
+		c.JSON(status, models.ErrorResponse(code, "Failed to expire silence", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{"id": c.Param("id")}))
+}
+
+// RegisterAlertRoutes 注册告警管理路由
+func RegisterAlertRoutes(router *gin.RouterGroup, alertManager *monitoring.AlertManager) {
+	handler := NewAlertHandler(alertManager)
+
+	admin := router.Group("/admin/alerts")
+	{
+		admin.GET("/history", handler.GetAlertHistory)
+		admin.GET("/silences", handler.GetSilences)
+		admin.POST("/silences", handler.CreateSilence)
+		admin.DELETE("/silences/:id", handler.ExpireSilence)
+	}
+}
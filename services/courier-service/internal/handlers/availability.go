@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AvailabilityHandler 信使排班处理器
+type AvailabilityHandler struct {
+	availabilityService *services.AvailabilityService
+	assignmentService   *services.AssignmentService
+}
+
+// NewAvailabilityHandler 创建信使排班处理器
+func NewAvailabilityHandler(availabilityService *services.AvailabilityService, assignmentService *services.AssignmentService) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		availabilityService: availabilityService,
+		assignmentService:   assignmentService,
+	}
+}
+
+// GetMyAvailability 获取我的排班和在岗状态
+func (h *AvailabilityHandler) GetMyAvailability(c *gin.Context) {
+	h.respondAvailability(c, middleware.GetUserID(c))
+}
+
+// GetCourierAvailability 查看指定信使的排班和在岗状态（管理员功能）
+func (h *AvailabilityHandler) GetCourierAvailability(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	h.respondAvailability(c, c.Param("user_id"))
+}
+
+// SetWeeklySchedule 设置每周可接单时段
+func (h *AvailabilityHandler) SetWeeklySchedule(c *gin.Context) {
+	var request models.WeeklyScheduleRequest
+	if !bindAvailabilityRequest(c, &request) {
+		return
+	}
+
+	windows, err := h.availabilityService.SetWeeklySchedule(middleware.GetUserID(c), &request)
+	if err != nil {
+		respondAvailabilityError(c, "Failed to set weekly schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(windows))
+}
+
+// AddTimeOff 登记不可用时段
+func (h *AvailabilityHandler) AddTimeOff(c *gin.Context) {
+	var request models.TimeOffRequest
+	if !bindAvailabilityRequest(c, &request) {
+		return
+	}
+
+	timeOff, err := h.availabilityService.AddTimeOff(middleware.GetUserID(c), &request)
+	if err != nil {
+		respondAvailabilityError(c, "Failed to add time off", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(timeOff))
+}
+
+// DeleteTimeOff 取消不可用时段
+func (h *AvailabilityHandler) DeleteTimeOff(c *gin.Context) {
+	if err := h.availabilityService.DeleteTimeOff(middleware.GetUserID(c), c.Param("id")); err != nil {
+		respondAvailabilityError(c, "Failed to delete time off", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(nil))
+}
+
+// SetDuty 切换在岗状态
+func (h *AvailabilityHandler) SetDuty(c *gin.Context) {
+	var request models.DutyStatusRequest
+	if !bindAvailabilityRequest(c, &request) {
+		return
+	}
+
+	duty, err := h.availabilityService.SetDuty(middleware.GetUserID(c), *request.OnDuty)
+	if err != nil {
+		respondAvailabilityError(c, "Failed to update duty status", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(duty))
+}
+
+// SetMyCapacity 设置我的接单容量
+func (h *AvailabilityHandler) SetMyCapacity(c *gin.Context) {
+	h.setCapacity(c, middleware.GetUserID(c))
+}
+
+// SetCourierCapacity 设置指定信使的接单容量（管理员功能）
+func (h *AvailabilityHandler) SetCourierCapacity(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+	h.setCapacity(c, c.Param("user_id"))
+}
+
+// GetPickupForecast 获取任务预计取件时间
+func (h *AvailabilityHandler) GetPickupForecast(c *gin.Context) {
+	forecast, err := h.assignmentService.ForecastPickup(c.Param("task_id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				models.CodeNotFound,
+				"Task not found",
+				err.Error(),
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to forecast pickup",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(forecast))
+}
+
+// respondAvailability 返回信使可用性概览
+func (h *AvailabilityHandler) respondAvailability(c *gin.Context, userID string) {
+	availability, err := h.availabilityService.GetAvailability(userID)
+	if err != nil {
+		respondAvailabilityError(c, "Failed to get availability", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(availability))
+}
+
+// setCapacity 更新接单容量
+func (h *AvailabilityHandler) setCapacity(c *gin.Context, userID string) {
+	var request models.CapacityRequest
+	if !bindAvailabilityRequest(c, &request) {
+		return
+	}
+
+	duty, err := h.availabilityService.SetCapacity(userID, request.MaxActiveTasks)
+	if err != nil {
+		respondAvailabilityError(c, "Failed to update capacity", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(duty))
+}
+
+// bindAvailabilityRequest 绑定请求体，失败时直接返回参数错误
+func bindAvailabilityRequest(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return false
+	}
+	return true
+}
+
+// respondAvailabilityError 将排班服务错误映射为HTTP响应
+func respondAvailabilityError(c *gin.Context, message string, err error) {
+	status, code := http.StatusInternalServerError, models.CodeInternalError
+	switch {
+	case errors.Is(err, services.ErrAvailabilityCourierNotFound), errors.Is(err, services.ErrTimeOffNotFound):
+		status, code = http.StatusNotFound, models.CodeNotFound
+	case errors.Is(err, services.ErrInvalidAvailabilityWindow), errors.Is(err, services.ErrInvalidTimeOff):
+		status, code = http.StatusBadRequest, models.CodeParamError
+	}
+
+	c.JSON(status, models.ErrorResponse(code, message, err.Error()))
+}
+
+// RegisterAvailabilityRoutes 注册信使排班相关路由
+func RegisterAvailabilityRoutes(router *gin.RouterGroup, availabilityService *services.AvailabilityService, assignmentService *services.AssignmentService) {
+	handler := NewAvailabilityHandler(availabilityService, assignmentService)
+
+	availability := router.Group("/availability")
+	{
+		availability.GET("/me", handler.GetMyAvailability)
+		availability.PUT("/me/schedule", handler.SetWeeklySchedule)
+		availability.POST("/me/time-off", handler.AddTimeOff)
+		availability.DELETE("/me/time-off/:id", handler.DeleteTimeOff)
+		availability.PUT("/me/duty", handler.SetDuty)
+		availability.PUT("/me/capacity", handler.SetMyCapacity)
+	}
+
+	router.GET("/tasks/:task_id/pickup-forecast", handler.GetPickupForecast)
+
+	admin := router.Group("/admin")
+	{
+		admin.GET("/couriers/:user_id/availability", handler.GetCourierAvailability)
+		admin.PUT("/couriers/:user_id/capacity", handler.SetCourierCapacity)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeliveryFailureHandler 投递失败处理器
+type DeliveryFailureHandler struct {
+	failureService *services.DeliveryFailureService
+}
+
+// NewDeliveryFailureHandler 创建投递失败处理器
+func NewDeliveryFailureHandler(failureService *services.DeliveryFailureService) *DeliveryFailureHandler {
+	return &DeliveryFailureHandler{
+		failureService: failureService,
+	}
+}
+
+// GetAttempts 获取任务的投递失败记录
+func (h *DeliveryFailureHandler) GetAttempts(c *gin.Context) {
+	attempts, err := h.failureService.GetAttempts(c.Param("task_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get delivery attempts",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(attempts))
+}
+
+// GetPolicies 获取重投策略（管理员功能）
+func (h *DeliveryFailureHandler) GetPolicies(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	policies, err := h.failureService.GetPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get retry policies",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(policies))
+}
+
+// UpdatePolicy 更新重投策略（管理员功能）
+func (h *DeliveryFailureHandler) UpdatePolicy(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	var request models.DeliveryRetryPolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	policy, err := h.failureService.UpdatePolicy(c.Param("reason"), middleware.GetUserID(c), &request)
+	if err != nil {
+		if errors.Is(err, services.ErrRetryPolicyNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				models.CodeNotFound,
+				"Retry policy not found",
+				err.Error(),
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to update retry policy",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(policy))
+}
+
+// requireAdminRole 检查管理员权限
+func requireAdminRole(c *gin.Context) bool {
+	role := middleware.GetUserRole(c)
+	if role != "admin" && role != "super_admin" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse(
+			models.CodeUnauthorized,
+			"Admin permission required",
+			nil,
+		))
+		return false
+	}
+	return true
+}
+
+// RegisterDeliveryFailureRoutes 注册投递失败相关路由
+func RegisterDeliveryFailureRoutes(router *gin.RouterGroup, failureService *services.DeliveryFailureService) {
+	handler := NewDeliveryFailureHandler(failureService)
+
+	router.GET("/tasks/:task_id/attempts", handler.GetAttempts)
+
+	admin := router.Group("/admin")
+	{
+		admin.GET("/delivery-retry-policies", handler.GetPolicies)
+		admin.PUT("/delivery-retry-policies/:reason", handler.UpdatePolicy)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventSyncHandler 信件状态同步处理器
+type EventSyncHandler struct {
+	eventSyncService *services.EventSyncService
+}
+
+// NewEventSyncHandler 创建信件状态同步处理器
+func NewEventSyncHandler(eventSyncService *services.EventSyncService) *EventSyncHandler {
+	return &EventSyncHandler{
+		eventSyncService: eventSyncService,
+	}
+}
+
+// GetSyncStatus 获取同步概况（管理员功能）
+func (h *EventSyncHandler) GetSyncStatus(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	status, err := h.eventSyncService.GetStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get sync status",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(status))
+}
+
+// GetDivergences 获取对账差异（管理员功能）
+func (h *EventSyncHandler) GetDivergences(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	var query models.SyncDivergenceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	divergences, total, err := h.eventSyncService.GetDivergences(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get sync divergences",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"divergences": divergences,
+		"total":       total,
+		"limit":       query.Limit,
+		"offset":      query.Offset,
+	}))
+}
+
+// Reconcile 立即发布对账快照（管理员功能）
+func (h *EventSyncHandler) Reconcile(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	published, err := h.eventSyncService.Reconcile()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to reconcile",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"published": published,
+	}))
+}
+
+// RegisterEventSyncRoutes 注册信件状态同步相关路由
+func RegisterEventSyncRoutes(router *gin.RouterGroup, eventSyncService *services.EventSyncService) {
+	handler := NewEventSyncHandler(eventSyncService)
+
+	admin := router.Group("/admin/sync")
+	{
+		admin.GET("/status", handler.GetSyncStatus)
+		admin.GET("/divergences", handler.GetDivergences)
+		admin.POST("/reconcile", handler.Reconcile)
+	}
+}
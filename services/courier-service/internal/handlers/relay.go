@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RelayHandler 跨校接力处理器
+type RelayHandler struct {
+	relayService *services.RelayService
+}
+
+// NewRelayHandler 创建跨校接力处理器
+func NewRelayHandler(relayService *services.RelayService) *RelayHandler {
+	return &RelayHandler{
+		relayService: relayService,
+	}
+}
+
+// PlanRelay 将跨校任务拆分为接力段
+// POST /api/courier/relay/tasks/:task_id/plan
+func (h *RelayHandler) PlanRelay(c *gin.Context) {
+	courierID, ok := requireRelayCourier(c)
+	if !ok {
+		return
+	}
+
+	chain, err := h.relayService.PlanRelay(courierID, c.Param("task_id"))
+	if err != nil {
+		respondRelayError(c, "Failed to plan relay", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(chain))
+}
+
+// GetRelayChain 获取接力链详情
+// GET /api/courier/relay/tasks/:task_id
+func (h *RelayHandler) GetRelayChain(c *gin.Context) {
+	if _, ok := requireRelayCourier(c); !ok {
+		return
+	}
+
+	chain, err := h.relayService.GetRelayChain(c.Param("task_id"))
+	if err != nil {
+		respondRelayError(c, "Failed to get relay chain", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(chain))
+}
+
+// CollectLetter 第一段信使收件扫码
+// POST /api/courier/relay/tasks/:task_id/collect
+func (h *RelayHandler) CollectLetter(c *gin.Context) {
+	h.handleScan(c, h.relayService.CollectLetter)
+}
+
+// IssueHandoffCode 当前保管信使申请交接码
+// POST /api/courier/relay/tasks/:task_id/handoff-code
+func (h *RelayHandler) IssueHandoffCode(c *gin.Context) {
+	courierID, ok := requireRelayCourier(c)
+	if !ok {
+		return
+	}
+
+	code, err := h.relayService.IssueHandoffCode(courierID, c.Param("task_id"))
+	if err != nil {
+		respondRelayError(c, "Failed to issue handoff code", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(code))
+}
+
+// HandOff 下一段信使扫码并输入交接码接手
+// POST /api/courier/relay/tasks/:task_id/handoff
+func (h *RelayHandler) HandOff(c *gin.Context) {
+	h.handleScan(c, h.relayService.HandOff)
+}
+
+// DeliverLetter 最后一段信使投递扫码
+// POST /api/courier/relay/tasks/:task_id/deliver
+func (h *RelayHandler) DeliverLetter(c *gin.Context) {
+	h.handleScan(c, h.relayService.DeliverLetter)
+}
+
+// AssignLeg 为接力段指定信使
+// PUT /api/courier/relay/legs/:leg_id/courier
+func (h *RelayHandler) AssignLeg(c *gin.Context) {
+	courierID, ok := requireRelayCourier(c)
+	if !ok {
+		return
+	}
+
+	var request models.RelayAssignLegRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request data",
+			err.Error(),
+		))
+		return
+	}
+
+	leg, err := h.relayService.AssignLeg(courierID, c.Param("leg_id"), &request)
+	if err != nil {
+		respondRelayError(c, "Failed to assign relay leg", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(leg))
+}
+
+// GetMyLegs 获取当前信使承接的接力段
+// GET /api/courier/relay/legs/me
+func (h *RelayHandler) GetMyLegs(c *gin.Context) {
+	courierID, ok := requireRelayCourier(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.relayService.GetCourierLegs(courierID, c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get relay legs",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(response))
+}
+
+// handleScan 收件、交接、投递扫码的公共处理
+func (h *RelayHandler) handleScan(c *gin.Context, scan func(courierID, taskID string, req *models.RelayScanRequest) (*models.RelayChain, error)) {
+	courierID, ok := requireRelayCourier(c)
+	if !ok {
+		return
+	}
+
+	// 收件和投递扫码可以不带请求体
+	var request models.RelayScanRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request data",
+			err.Error(),
+		))
+		return
+	}
+
+	chain, err := scan(courierID, c.Param("task_id"), &request)
+	if err != nil {
+		respondRelayError(c, "Failed to process relay scan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(chain))
+}
+
+// requireRelayCourier 获取当前信使用户ID
+func requireRelayCourier(c *gin.Context) (string, bool) {
+	courierID := middleware.GetUserID(c)
+	if courierID == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			models.CodeUnauthorized,
+			"User ID not found",
+			nil,
+		))
+		return "", false
+	}
+	return courierID, true
+}
+
+// respondRelayError 将接力服务错误映射为响应码
+func respondRelayError(c *gin.Context, message string, err error) {
+	status, code := http.StatusInternalServerError, models.CodeInternalError
+	switch {
+	case errors.Is(err, services.ErrRelayTaskNotFound), errors.Is(err, services.ErrRelayLegNotFound):
+		status, code = http.StatusNotFound, models.CodeNotFound
+	case isQRTokenError(err):
+		status, code = http.StatusForbidden, models.CodeUnauthorized
+	case errors.Is(err, services.ErrRelayPermissionDenied),
+		errors.Is(err, services.ErrRelayNotLegCourier),
+		errors.Is(err, services.ErrRelayCourierNotEligible),
+		errors.Is(err, services.ErrRelayHandoffCodeInvalid):
+		status, code = http.StatusForbidden, models.CodeUnauthorized
+	case errors.Is(err, services.ErrRelayNotRelayTask),
+		errors.Is(err, services.ErrRelayOPCodeMissing),
+		errors.Is(err, services.ErrRelayNotCrossSchool):
+		status, code = http.StatusBadRequest, models.CodeParamError
+	case errors.Is(err, services.ErrRelayAlreadyPlanned),
+		errors.Is(err, services.ErrRelayTaskNotPlannable),
+		errors.Is(err, services.ErrRelayLegStarted),
+		errors.Is(err, services.ErrRelayFromCourierMismatch),
+		errors.Is(err, services.ErrRelayInvalidState),
+		errors.Is(err, services.ErrDeliveryReceiptRequired):
+		status, code = http.StatusConflict, models.CodeConflict
+	}
+
+	c.JSON(status, models.ErrorResponse(code, message, err.Error()))
+}
+
+// RegisterRelayRoutes 注册跨校接力相关路由
+func RegisterRelayRoutes(router *gin.RouterGroup, relayService *services.RelayService) {
+	handler := NewRelayHandler(relayService)
+
+	relay := router.Group("/relay")
+	{
+		relay.POST("/tasks/:task_id/plan", handler.PlanRelay)
+		relay.GET("/tasks/:task_id", handler.GetRelayChain)
+		relay.POST("/tasks/:task_id/collect", handler.CollectLetter)
+		relay.POST("/tasks/:task_id/handoff-code", handler.IssueHandoffCode)
+		relay.POST("/tasks/:task_id/handoff", handler.HandOff)
+		relay.POST("/tasks/:task_id/deliver", handler.DeliverLetter)
+		relay.PUT("/legs/:leg_id/courier", handler.AssignLeg)
+		relay.GET("/legs/me", handler.GetMyLegs)
+	}
+}
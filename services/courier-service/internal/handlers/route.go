@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteHandler 路线规划处理器
+type RouteHandler struct {
+	routePlannerService *services.RoutePlannerService
+}
+
+// NewRouteHandler 创建路线规划处理器
+func NewRouteHandler(routePlannerService *services.RoutePlannerService) *RouteHandler {
+	return &RouteHandler{
+		routePlannerService: routePlannerService,
+	}
+}
+
+// PlanRoute 获取当前信使已接任务的建议取送顺序
+func (h *RouteHandler) PlanRoute(c *gin.Context) {
+	courierID := middleware.GetUserID(c)
+	if courierID == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			models.CodeUnauthorized,
+			"User ID not found",
+			nil,
+		))
+		return
+	}
+
+	var query models.RoutePlanQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	plan, err := h.routePlannerService.PlanRoute(courierID, &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to plan route",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(plan))
+}
+
+// RegisterRouteRoutes 注册路线规划相关路由
+func RegisterRouteRoutes(router *gin.RouterGroup, routePlannerService *services.RoutePlannerService) {
+	handler := NewRouteHandler(routePlannerService)
+
+	router.GET("/route/plan", handler.PlanRoute)
+}
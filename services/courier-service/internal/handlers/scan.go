@@ -4,7 +4,11 @@ import (
 	"courier-service/internal/middleware"
 	"courier-service/internal/models"
 	"courier-service/internal/services"
+	"courier-service/internal/utils"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,13 +17,15 @@ import (
 type ScanHandler struct {
 	taskService     *services.TaskService
 	locationService *services.LocationService
+	failureService  *services.DeliveryFailureService
 }
 
 // NewScanHandler 创建扫码处理器
-func NewScanHandler(taskService *services.TaskService, locationService *services.LocationService) *ScanHandler {
+func NewScanHandler(taskService *services.TaskService, locationService *services.LocationService, failureService *services.DeliveryFailureService) *ScanHandler {
 	return &ScanHandler{
 		taskService:     taskService,
 		locationService: locationService,
+		failureService:  failureService,
 	}
 }
 
@@ -57,8 +63,30 @@ func (h *ScanHandler) ScanLetterCode(c *gin.Context) {
 		return
 	}
 
+	// 投递失败按原因进入重投或退回流程
+	if request.Action == models.ScanActionFailed {
+		if err := h.taskService.VerifyScanToken(letterCode, request.QRToken, time.Now()); err != nil {
+			c.JSON(http.StatusForbidden, models.ErrorResponse(
+				models.CodeUnauthorized,
+				"Invalid QR code",
+				err.Error(),
+			))
+			return
+		}
+		h.reportFailure(c, letterCode, courierID, &request)
+		return
+	}
+
 	response, err := h.taskService.UpdateTaskStatus(letterCode, courierID, &request)
 	if err != nil {
+		if isQRTokenError(err) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse(
+				models.CodeUnauthorized,
+				"Invalid QR code",
+				err.Error(),
+			))
+			return
+		}
 		c.JSON(http.StatusConflict, models.ErrorResponse(
 			models.CodeConflict,
 			"Failed to update task status",
@@ -70,17 +98,107 @@ func (h *ScanHandler) ScanLetterCode(c *gin.Context) {
 	c.JSON(http.StatusOK, models.SuccessResponse(response))
 }
 
+// isQRTokenError 二维码签名校验失败
+func isQRTokenError(err error) bool {
+	return errors.Is(err, utils.ErrQRTokenMissing) ||
+		errors.Is(err, utils.ErrQRTokenInvalid) ||
+		errors.Is(err, utils.ErrQRTokenExpired) ||
+		errors.Is(err, utils.ErrQRTokenMismatch)
+}
+
+// reportFailure 上报投递失败
+func (h *ScanHandler) reportFailure(c *gin.Context, letterCode, courierID string, request *models.ScanRequest) {
+	reason := request.FailureReason
+	if reason == "" {
+		reason = models.FailureReasonOther
+	}
+
+	result, err := h.failureService.RecordFailure(courierID, letterCode, &models.DeliveryFailureRequest{
+		Reason:    reason,
+		Note:      request.Note,
+		PhotoURL:  request.PhotoURL,
+		OPCode:    request.OperatorOPCode,
+		Location:  request.Location,
+		Latitude:  request.Latitude,
+		Longitude: request.Longitude,
+	})
+	if err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, services.ErrFailureTaskNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse(
+			models.CodeConflict,
+			"Failed to record delivery failure",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(result))
+}
+
 // GetScanHistory 获取扫码历史记录
 func (h *ScanHandler) GetScanHistory(c *gin.Context) {
 	letterCode := c.Param("letter_code")
 	courierID := middleware.GetUserID(c)
 
-	// 这里可以添加获取扫码历史的逻辑
-	// 暂时返回空响应
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	// 按实际扫码时间排序，离线同步的扫码使用设备时间
+	records, err := h.taskService.GetScanHistory(letterCode, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get scan history",
+			err.Error(),
+		))
+		return
+	}
+
 	response := map[string]interface{}{
 		"letter_code":  letterCode,
 		"courier_id":   courierID,
-		"scan_records": []interface{}{},
+		"scan_records": records,
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(response))
+}
+
+// SyncOfflineScans 批量同步离线扫码
+func (h *ScanHandler) SyncOfflineScans(c *gin.Context) {
+	courierID := middleware.GetUserID(c)
+
+	if courierID == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			models.CodeUnauthorized,
+			"User ID not found",
+			nil,
+		))
+		return
+	}
+
+	var request models.ScanSyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	response, err := h.taskService.SyncOfflineScans(courierID, &request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to sync offline scans",
+			err.Error(),
+		))
+		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse(response))
@@ -104,9 +222,10 @@ func isValidScanAction(action string) bool {
 }
 
 // RegisterScanRoutes 注册扫码相关路由
-func RegisterScanRoutes(router *gin.RouterGroup, taskService *services.TaskService, locationService *services.LocationService) {
-	handler := NewScanHandler(taskService, locationService)
+func RegisterScanRoutes(router *gin.RouterGroup, taskService *services.TaskService, locationService *services.LocationService, failureService *services.DeliveryFailureService) {
+	handler := NewScanHandler(taskService, locationService, failureService)
 
+	router.POST("/scan/sync", handler.SyncOfflineScans)
 	router.POST("/scan/:letter_code", handler.ScanLetterCode)
 	router.GET("/scan/:letter_code/history", handler.GetScanHistory)
 }
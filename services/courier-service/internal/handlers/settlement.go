@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettlementHandler 收益结算处理器
+type SettlementHandler struct {
+	settlementService *services.SettlementService
+}
+
+// NewSettlementHandler 创建收益结算处理器
+func NewSettlementHandler(settlementService *services.SettlementService) *SettlementHandler {
+	return &SettlementHandler{
+		settlementService: settlementService,
+	}
+}
+
+// ListPeriods 获取结算周期列表
+func (h *SettlementHandler) ListPeriods(c *gin.Context) {
+	periods, err := h.settlementService.ListPeriods(c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get settlement periods",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(periods))
+}
+
+// GetMyStatement 获取我在指定周期的对账单
+func (h *SettlementHandler) GetMyStatement(c *gin.Context) {
+	statement, err := h.settlementService.GetStatement(c.Query("period_id"), middleware.GetUserID(c))
+	if err != nil {
+		respondSettlementError(c, "Failed to get statement", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(statement))
+}
+
+// ExportMyStatement 导出我的对账单
+func (h *SettlementHandler) ExportMyStatement(c *gin.Context) {
+	statement, err := h.settlementService.GetStatement(c.Query("period_id"), middleware.GetUserID(c))
+	if err != nil {
+		respondSettlementError(c, "Failed to export statement", err)
+		return
+	}
+
+	h.exportStatement(c, statement)
+}
+
+// CreateDispute 对对账单发起申诉
+func (h *SettlementHandler) CreateDispute(c *gin.Context) {
+	var request models.StatementDisputeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	dispute, err := h.settlementService.CreateDispute(middleware.GetUserID(c), c.Param("id"), &request)
+	if err != nil {
+		respondSettlementError(c, "Failed to create dispute", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(dispute))
+}
+
+// GetPendingDisputes 获取待我审批的申诉
+func (h *SettlementHandler) GetPendingDisputes(c *gin.Context) {
+	disputes, err := h.settlementService.GetPendingDisputes(middleware.GetUserID(c), isAdminRole(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get disputes",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(disputes))
+}
+
+// ResolveDispute 审批申诉
+func (h *SettlementHandler) ResolveDispute(c *gin.Context) {
+	var request models.ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	dispute, err := h.settlementService.ResolveDispute(middleware.GetUserID(c), isAdminRole(c), c.Param("id"), &request)
+	if err != nil {
+		respondSettlementError(c, "Failed to resolve dispute", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(dispute))
+}
+
+// CreatePeriod 创建结算周期（管理员功能）
+func (h *SettlementHandler) CreatePeriod(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	var request models.SettlementPeriodRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	period, err := h.settlementService.CreatePeriod(middleware.GetUserID(c), &request)
+	if err != nil {
+		respondSettlementError(c, "Failed to create settlement period", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(period))
+}
+
+// ClosePeriod 关账（管理员功能）
+func (h *SettlementHandler) ClosePeriod(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	period, err := h.settlementService.ClosePeriod(c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		respondSettlementError(c, "Failed to close settlement period", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(period))
+}
+
+// ListPeriodStatements 获取周期内所有信使的对账单（管理员功能）
+func (h *SettlementHandler) ListPeriodStatements(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	statements, err := h.settlementService.ListPeriodStatements(c.Param("id"))
+	if err != nil {
+		respondSettlementError(c, "Failed to get statements", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(statements))
+}
+
+// ExportStatement 导出任意信使的对账单（管理员功能）
+func (h *SettlementHandler) ExportStatement(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	statement, err := h.settlementService.GetStatementByID(c.Param("id"))
+	if err != nil {
+		respondSettlementError(c, "Failed to export statement", err)
+		return
+	}
+
+	h.exportStatement(c, statement)
+}
+
+// exportStatement 按 format 参数输出 CSV 或 PDF 文件
+func (h *SettlementHandler) exportStatement(c *gin.Context, statement *models.CourierStatement) {
+	var (
+		data        []byte
+		err         error
+		contentType string
+	)
+	format := c.DefaultQuery("format", "csv")
+	switch format {
+	case "csv":
+		data, err = h.settlementService.ExportCSV(statement)
+		contentType = "text/csv; charset=utf-8"
+	case "pdf":
+		data, err = h.settlementService.ExportPDF(statement)
+		contentType = "application/pdf"
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Unsupported export format",
+			nil,
+		))
+		return
+	}
+	if err != nil {
+		respondSettlementError(c, "Failed to export statement", err)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", statement.PeriodID, statement.CourierID, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// isAdminRole 当前用户是否为管理员
+func isAdminRole(c *gin.Context) bool {
+	role := middleware.GetUserRole(c)
+	return role == "admin" || role == "super_admin"
+}
+
+// respondSettlementError 将结算服务错误映射为响应
+func respondSettlementError(c *gin.Context, message string, err error) {
+	status, code := http.StatusInternalServerError, models.CodeInternalError
+	switch {
+	case errors.Is(err, services.ErrSettlementPeriodNotFound),
+		errors.Is(err, services.ErrSettlementStatementNotFound),
+		errors.Is(err, services.ErrSettlementLineNotFound),
+		errors.Is(err, services.ErrSettlementDisputeNotFound):
+		status, code = http.StatusNotFound, models.CodeNotFound
+	case errors.Is(err, services.ErrSettlementPermissionDenied):
+		status, code = http.StatusForbidden, models.CodeUnauthorized
+	case errors.Is(err, services.ErrSettlementPeriodClosed),
+		errors.Is(err, services.ErrSettlementPeriodOverlap),
+		errors.Is(err, services.ErrSettlementDisputesPending),
+		errors.Is(err, services.ErrSettlementDisputeResolved):
+		status, code = http.StatusConflict, models.CodeConflict
+	case errors.Is(err, services.ErrSettlementPeriodInvalid),
+		errors.Is(err, services.ErrSettlementPeriodNotEnded):
+		status, code = http.StatusBadRequest, models.CodeParamError
+	}
+	c.JSON(status, models.ErrorResponse(code, message, err.Error()))
+}
+
+// RegisterSettlementRoutes 注册收益结算路由
+func RegisterSettlementRoutes(router *gin.RouterGroup, settlementService *services.SettlementService) {
+	handler := NewSettlementHandler(settlementService)
+
+	settlement := router.Group("/settlement")
+	{
+		settlement.GET("/periods", handler.ListPeriods)
+		settlement.GET("/statements/me", handler.GetMyStatement)
+		settlement.GET("/statements/me/export", handler.ExportMyStatement)
+		settlement.POST("/statements/:id/disputes", handler.CreateDispute)
+		settlement.GET("/disputes/pending", handler.GetPendingDisputes)
+		settlement.POST("/disputes/:id/resolve", handler.ResolveDispute)
+	}
+
+	admin := router.Group("/admin/settlement")
+	{
+		admin.POST("/periods", handler.CreatePeriod)
+		admin.POST("/periods/:id/close", handler.ClosePeriod)
+		admin.GET("/periods/:id/statements", handler.ListPeriodStatements)
+		admin.GET("/statements/:id/export", handler.ExportStatement)
+	}
+}
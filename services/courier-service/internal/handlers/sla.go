@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"courier-service/internal/middleware"
+	"courier-service/internal/models"
+	"courier-service/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLAHandler 任务时效处理器
+type SLAHandler struct {
+	slaService *services.SLAService
+}
+
+// NewSLAHandler 创建任务时效处理器
+func NewSLAHandler(slaService *services.SLAService) *SLAHandler {
+	return &SLAHandler{
+		slaService: slaService,
+	}
+}
+
+// GetEscalatedBreaches 获取上报给我的超时任务
+func (h *SLAHandler) GetEscalatedBreaches(c *gin.Context) {
+	var query models.SLABreachQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	breaches, total, err := h.slaService.GetEscalatedBreaches(middleware.GetUserID(c), &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get SLA breaches",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(gin.H{
+		"breaches": breaches,
+		"total":    total,
+		"limit":    query.Limit,
+		"offset":   query.Offset,
+	}))
+}
+
+// ReassignBreachedTask 改派超时任务
+func (h *SLAHandler) ReassignBreachedTask(c *gin.Context) {
+	var request models.SLAReassignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	task, err := h.slaService.ReassignBreachedTask(middleware.GetUserID(c), c.Param("id"), &request)
+	if err != nil {
+		status, code := http.StatusBadRequest, models.CodeParamError
+		switch {
+		case errors.Is(err, services.ErrSLABreachNotFound):
+			status, code = http.StatusNotFound, models.CodeNotFound
+		case errors.Is(err, services.ErrSLAPermissionDenied):
+			status, code = http.StatusForbidden, models.CodeUnauthorized
+		case errors.Is(err, services.ErrSLABreachResolved):
+			status, code = http.StatusConflict, models.CodeConflict
+		}
+		c.JSON(status, models.ErrorResponse(code, "Failed to reassign task", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(task))
+}
+
+// GetMyCompliance 获取我的SLA达成情况
+func (h *SLAHandler) GetMyCompliance(c *gin.Context) {
+	until := time.Now()
+	var since time.Time
+	switch c.DefaultQuery("time_range", "monthly") {
+	case "daily":
+		since = until.AddDate(0, 0, -1)
+	case "weekly":
+		since = until.AddDate(0, 0, -7)
+	case "yearly":
+		since = until.AddDate(-1, 0, 0)
+	default:
+		since = until.AddDate(0, -1, 0)
+	}
+
+	compliance, err := h.slaService.GetCourierCompliance(middleware.GetUserID(c), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get SLA compliance",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(compliance))
+}
+
+// GetPolicies 获取SLA时限配置（管理员功能）
+func (h *SLAHandler) GetPolicies(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	policies, err := h.slaService.GetPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to get SLA policies",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(policies))
+}
+
+// UpdatePolicy 更新SLA时限配置（管理员功能）
+func (h *SLAHandler) UpdatePolicy(c *gin.Context) {
+	if !requireAdminRole(c) {
+		return
+	}
+
+	var request models.TaskSLAPolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			models.CodeParamError,
+			"Invalid request parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	policy, err := h.slaService.UpdatePolicy(c.Param("priority"), middleware.GetUserID(c), &request)
+	if err != nil {
+		if errors.Is(err, services.ErrSLAPolicyNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				models.CodeNotFound,
+				"SLA policy not found",
+				err.Error(),
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			models.CodeInternalError,
+			"Failed to update SLA policy",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(policy))
+}
+
+// RegisterSLARoutes 注册任务时效相关路由
+func RegisterSLARoutes(router *gin.RouterGroup, slaService *services.SLAService) {
+	handler := NewSLAHandler(slaService)
+
+	sla := router.Group("/sla")
+	{
+		sla.GET("/breaches", handler.GetEscalatedBreaches)
+		sla.POST("/breaches/:id/reassign", handler.ReassignBreachedTask)
+		sla.GET("/compliance/me", handler.GetMyCompliance)
+	}
+
+	admin := router.Group("/admin")
+	{
+		admin.GET("/sla-policies", handler.GetPolicies)
+		admin.PUT("/sla-policies/:priority", handler.UpdatePolicy)
+	}
+}
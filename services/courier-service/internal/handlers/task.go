@@ -140,6 +140,8 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		LetterID         string `json:"letter_id" binding:"required"`
 		PickupLocation   string `json:"pickup_location" binding:"required"`
 		DeliveryLocation string `json:"delivery_location" binding:"required"`
+		SenderID         string `json:"sender_id"`
+		SenderOPCode     string `json:"sender_op_code" binding:"omitempty,len=6"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -155,6 +157,8 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		request.LetterID,
 		request.PickupLocation,
 		request.DeliveryLocation,
+		request.SenderID,
+		request.SenderOPCode,
 		h.queueService,
 	)
 	if err != nil {
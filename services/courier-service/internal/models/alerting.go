@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// AlertSilenceRequest 创建告警静默
+type AlertSilenceRequest struct {
+	Matchers        map[string]string `json:"matchers" binding:"required"` // 如 {"alertname": "high_error_rate"} 或 {"level": "WARNING"}
+	Comment         string            `json:"comment" binding:"required,max=200"`
+	StartsAt        *time.Time        `json:"starts_at"` // 为空时立即生效
+	DurationMinutes int               `json:"duration_minutes" binding:"required,min=1,max=10080"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultCourierMaxActiveTasks 未设置容量时信使同时进行中的任务上限
+const DefaultCourierMaxActiveTasks = 5
+
+// CourierAvailabilityWindow 信使每周固定的可接单时段（服务器本地时间）
+type CourierAvailabilityWindow struct {
+	ID          string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CourierID   string    `gorm:"not null;index;type:varchar(36)" json:"courier_id"` // 信使用户ID
+	Weekday     int       `gorm:"not null" json:"weekday"`                           // 0=周日 … 6=周六
+	StartMinute int       `gorm:"not null" json:"start_minute"`                      // 当天0点起的分钟数
+	EndMinute   int       `gorm:"not null" json:"end_minute"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Covers 检查时刻是否落在该时段内
+func (w *CourierAvailabilityWindow) Covers(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	return int(t.Weekday()) == w.Weekday && minute >= w.StartMinute && minute < w.EndMinute
+}
+
+// CourierTimeOff 信使请假、考试等临时不可用时段
+type CourierTimeOff struct {
+	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CourierID string    `gorm:"not null;index;type:varchar(36)" json:"courier_id"` // 信使用户ID
+	StartAt   time.Time `gorm:"not null;index" json:"start_at"`
+	EndAt     time.Time `gorm:"not null;index" json:"end_at"`
+	Reason    string    `gorm:"type:varchar(200)" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CourierDutyStatus 信使实时在岗状态与接单容量
+type CourierDutyStatus struct {
+	CourierID      string     `gorm:"primaryKey;type:varchar(36)" json:"courier_id"` // 信使用户ID
+	OnDuty         bool       `gorm:"not null" json:"on_duty"`                       // 无记录时视为在岗
+	MaxActiveTasks int        `gorm:"default:5" json:"max_active_tasks"`
+	DutyChangedAt  *time.Time `json:"duty_changed_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// AvailabilityWindowRequest 每周时段，时间格式 HH:MM
+type AvailabilityWindowRequest struct {
+	Weekday int    `json:"weekday" binding:"min=0,max=6"`
+	Start   string `json:"start" binding:"required"`
+	End     string `json:"end" binding:"required"`
+}
+
+// WeeklyScheduleRequest 设置每周可接单时段（整体替换，传空列表表示不限时段）
+type WeeklyScheduleRequest struct {
+	Windows []AvailabilityWindowRequest `json:"windows" binding:"dive"`
+}
+
+// TimeOffRequest 登记不可用时段
+type TimeOffRequest struct {
+	StartAt time.Time `json:"start_at" binding:"required"`
+	EndAt   time.Time `json:"end_at" binding:"required"`
+	Reason  string    `json:"reason"`
+}
+
+// DutyStatusRequest 切换在岗状态
+type DutyStatusRequest struct {
+	OnDuty *bool `json:"on_duty" binding:"required"`
+}
+
+// CapacityRequest 设置接单容量
+type CapacityRequest struct {
+	MaxActiveTasks int `json:"max_active_tasks" binding:"required,min=1,max=50"`
+}
+
+// CourierAvailability 信使可用性概览
+type CourierAvailability struct {
+	CourierID       string                      `json:"courier_id"`
+	OnDuty          bool                        `json:"on_duty"`
+	MaxActiveTasks  int                         `json:"max_active_tasks"`
+	ActiveTasks     int                         `json:"active_tasks"`
+	AvailableNow    bool                        `json:"available_now"`               // 在岗、在时段内且有余量
+	NextAvailableAt *time.Time                  `json:"next_available_at,omitempty"` // 下线时为空
+	Windows         []CourierAvailabilityWindow `json:"windows"`
+	TimeOff         []CourierTimeOff            `json:"time_off"`
+}
+
+// PickupForecast 任务预计取件时间
+type PickupForecast struct {
+	TaskID            string     `json:"task_id"`
+	ExpectedPickupAt  *time.Time `json:"expected_pickup_at,omitempty"` // 无可用信使时为空
+	CourierID         *string    `json:"courier_id,omitempty"`         // 预计取件的信使用户ID
+	AvailableCouriers int        `json:"available_couriers"`           // 当前可接单的候选信使数
+}
@@ -96,16 +96,17 @@ type CourierStats struct {
 
 // CourierRanking 信使排行榜
 type CourierRanking struct {
-	ID           string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	CourierID    string    `gorm:"not null;index;type:varchar(36)" json:"courier_id"`
-	Courier      Courier   `gorm:"foreignKey:CourierID;references:ID" json:"courier"`
-	SchoolRank   int       `json:"school_rank"`
-	ZoneRank     int       `json:"zone_rank"`
-	NationalRank int       `json:"national_rank"`
-	Points       int       `json:"points"`
-	TotalTasks   int       `json:"total_tasks"`
-	SuccessRate  float64   `json:"success_rate"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CourierID         string    `gorm:"not null;index;type:varchar(36)" json:"courier_id"`
+	Courier           Courier   `gorm:"foreignKey:CourierID;references:ID" json:"courier"`
+	SchoolRank        int       `json:"school_rank"`
+	ZoneRank          int       `json:"zone_rank"`
+	NationalRank      int       `json:"national_rank"`
+	Points            int       `json:"points"`
+	TotalTasks        int       `json:"total_tasks"`
+	SuccessRate       float64   `json:"success_rate"`
+	SLAComplianceRate float64   `gorm:"default:100" json:"sla_compliance_rate"` // 近30天SLA达成率
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 // CourierLeaderboardRequest 排行榜请求
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+)
+
+// 投递失败原因
+const (
+	FailureReasonRecipientAbsent  = "recipient_absent"  // 收件人不在
+	FailureReasonWrongOPCode      = "wrong_op_code"     // OP Code错误，地址不存在或不符
+	FailureReasonAccessDenied     = "access_denied"     // 无法进入楼栋/宿舍
+	FailureReasonRecipientRefused = "recipient_refused" // 收件人拒收
+	FailureReasonOther            = "other"             // 其他原因
+)
+
+// 失败后的处理方式
+const (
+	FailureActionRetry          = "retry"            // 按策略延后重新派送
+	FailureActionReturnToSender = "return_to_sender" // 退回寄件人
+	FailureActionHoldAtStation  = "hold_at_station"  // 退件也无法送达，滞留站点并告警
+)
+
+// DeliveryRetryPolicy 按失败原因配置的重投策略
+type DeliveryRetryPolicy struct {
+	Reason            string    `gorm:"primaryKey;type:varchar(30)" json:"reason"`
+	MaxAttempts       int       `gorm:"not null;default:1" json:"max_attempts"`                       // 含首次投递在内的最多投递次数
+	RetryDelayMinutes []int     `gorm:"serializer:json;type:varchar(200)" json:"retry_delay_minutes"` // 第N次失败后的等待分钟数，不足时沿用最后一项
+	Description       string    `json:"description"`
+	UpdatedBy         string    `json:"updated_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// RetryDelay 第 attempt 次失败后距离下次投递的等待时间
+func (p *DeliveryRetryPolicy) RetryDelay(attempt int) time.Duration {
+	if len(p.RetryDelayMinutes) == 0 {
+		return time.Hour
+	}
+	index := attempt - 1
+	if index >= len(p.RetryDelayMinutes) {
+		index = len(p.RetryDelayMinutes) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return time.Duration(p.RetryDelayMinutes[index]) * time.Minute
+}
+
+// DefaultDeliveryRetryPolicies 默认重投策略，拒收和OP Code错误不重投直接退回
+var DefaultDeliveryRetryPolicies = []DeliveryRetryPolicy{
+	{
+		Reason:            FailureReasonRecipientAbsent,
+		MaxAttempts:       3,
+		RetryDelayMinutes: []int{120, 1440},
+		Description:       "收件人不在：2小时后重投，再失败次日重投",
+	},
+	{
+		Reason:            FailureReasonAccessDenied,
+		MaxAttempts:       2,
+		RetryDelayMinutes: []int{240},
+		Description:       "无法进入：4小时后重投一次",
+	},
+	{
+		Reason:      FailureReasonWrongOPCode,
+		MaxAttempts: 1,
+		Description: "OP Code错误：直接退回寄件人",
+	},
+	{
+		Reason:      FailureReasonRecipientRefused,
+		MaxAttempts: 1,
+		Description: "收件人拒收：直接退回寄件人",
+	},
+	{
+		Reason:            FailureReasonOther,
+		MaxAttempts:       2,
+		RetryDelayMinutes: []int{60},
+		Description:       "其他原因：1小时后重投一次",
+	},
+}
+
+// DeliveryAttempt 投递失败记录，每次失败一条
+type DeliveryAttempt struct {
+	ID           string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TaskID       string     `gorm:"not null;index" json:"task_id"`
+	LetterID     string     `gorm:"not null;index" json:"letter_id"`
+	CourierID    string     `gorm:"type:varchar(36)" json:"courier_id"`
+	AttemptNo    int        `gorm:"not null" json:"attempt_no"`
+	Reason       string     `gorm:"not null;type:varchar(30)" json:"reason"`
+	Note         string     `json:"note"`
+	PhotoURL     string     `json:"photo_url"`
+	OPCode       string     `json:"op_code" gorm:"type:varchar(6)"`
+	Location     string     `json:"location"`
+	Latitude     float64    `json:"latitude"`
+	Longitude    float64    `json:"longitude"`
+	NextAction   string     `gorm:"not null;type:varchar(20)" json:"next_action"`
+	NextRetryAt  *time.Time `json:"next_retry_at,omitempty"`
+	ReturnTaskID *string    `json:"return_task_id,omitempty"` // 退回寄件人时生成的任务
+	FailedAt     time.Time  `json:"failed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// DeliveryFailureRequest 投递失败上报
+type DeliveryFailureRequest struct {
+	Reason    string  `json:"reason" binding:"required,oneof=recipient_absent wrong_op_code access_denied recipient_refused other"`
+	Note      string  `json:"note"`
+	PhotoURL  string  `json:"photo_url"`
+	OPCode    string  `json:"op_code" binding:"omitempty,len=6"`
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DeliveryFailureResult 失败处理结果
+type DeliveryFailureResult struct {
+	Attempt    *DeliveryAttempt `json:"attempt"`
+	NextAction string           `json:"next_action"`
+	ReturnTask *Task            `json:"return_task,omitempty"`
+}
+
+// DeliveryRetryPolicyRequest 更新重投策略请求
+type DeliveryRetryPolicyRequest struct {
+	MaxAttempts       int    `json:"max_attempts" binding:"required,min=1,max=10"`
+	RetryDelayMinutes []int  `json:"retry_delay_minutes" binding:"dive,min=1,max=10080"`
+	Description       string `json:"description"`
+}
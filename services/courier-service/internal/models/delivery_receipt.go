@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// DeliveryReceipt 收件人当面签收后由主服务生成并签名的签收凭证
+// 表由主服务维护，本服务只读，用于确认送达确有签收
+type DeliveryReceipt struct {
+	ID             string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	LetterID       string    `gorm:"type:varchar(36)" json:"letter_id"`
+	LetterCode     string    `gorm:"type:varchar(50)" json:"letter_code"`
+	CourierID      string    `gorm:"type:varchar(36)" json:"courier_id"`
+	RecipientID    string    `gorm:"type:varchar(36)" json:"recipient_id"`
+	ConfirmationID string    `gorm:"type:varchar(36)" json:"confirmation_id"`
+	ConfirmMethod  string    `gorm:"type:varchar(10)" json:"confirm_method"`
+	PhotoFileID    *string   `gorm:"type:varchar(36)" json:"photo_file_id,omitempty"`
+	PhotoHash      string    `gorm:"type:varchar(64)" json:"photo_hash,omitempty"`
+	TargetOPCode   string    `gorm:"type:varchar(6)" json:"target_op_code"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	GeofenceResult string    `gorm:"type:varchar(20)" json:"geofence_result"`
+	DeliveredAt    time.Time `json:"delivered_at"`
+	Signature      string    `gorm:"type:varchar(64)" json:"signature"`
+}
+
+// TableName 与主服务共用的签收凭证表
+func (DeliveryReceipt) TableName() string {
+	return "delivery_receipts"
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"time"
+)
+
+// 同步事件类型（与 backend 共用）
+const (
+	SyncEventLetterStatusChanged = "letter.status_changed" // backend 信件状态变化
+	SyncEventLetterTaskAssigned  = "letter.task_assigned"  // backend 按OP Code分配信使任务
+	SyncEventLetterSnapshot      = "letter.snapshot"       // backend 对账快照
+	SyncEventLetterRerouted      = "letter.rerouted"       // backend 收件人转寄，改投新的OP Code
+	SyncEventTaskStatusChanged   = "task.status_changed"   // 本服务任务状态变化
+	SyncEventTaskSnapshot        = "task.snapshot"         // 本服务对账快照
+)
+
+// 事件来源
+const (
+	SyncSourceBackend        = "backend"
+	SyncSourceCourierService = "courier-service"
+)
+
+// SyncSchemaVersion 事件格式版本
+const SyncSchemaVersion = 1
+
+// Redis 事件队列，每个方向一个
+const (
+	SyncQueueToBackend        = "openpenpal:sync:to_backend"
+	SyncQueueToCourierService = "openpenpal:sync:to_courier_service"
+)
+
+// 应用失败的事件按次数退避重试，超过上限转入死信队列等待人工处理
+const (
+	SyncRetryToBackend             = "openpenpal:sync:to_backend:retry"    // 待重试事件，score 为重试时间
+	SyncAttemptsToBackend          = "openpenpal:sync:to_backend:attempts" // 各事件已失败次数
+	SyncDeadLetterToBackend        = "openpenpal:sync:to_backend:dead"
+	SyncRetryToCourierService      = "openpenpal:sync:to_courier_service:retry"
+	SyncAttemptsToCourierService   = "openpenpal:sync:to_courier_service:attempts"
+	SyncDeadLetterToCourierService = "openpenpal:sync:to_courier_service:dead"
+)
+
+// 统一生命周期状态，两边各自的状态都映射到这里再比较
+const (
+	LifecycleCreated   = "created"
+	LifecycleAssigned  = "assigned"
+	LifecycleCollected = "collected"
+	LifecycleInTransit = "in_transit"
+	LifecycleDelivered = "delivered"
+	LifecycleFailed    = "failed"
+	LifecycleReturned  = "returned"
+	LifecycleCanceled  = "canceled"
+)
+
+// 出站事件状态
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+)
+
+// 入站事件处理结果
+const (
+	SyncResultApplied   = "applied"   // 已应用到本地任务
+	SyncResultDuplicate = "duplicate" // 重复投递
+	SyncResultStale     = "stale"     // 早于本地最近一次变化
+	SyncResultIgnored   = "ignored"   // 本地无对应任务或为接力任务
+	SyncResultInSync    = "in_sync"   // 对账快照与本地一致
+	SyncResultRejected  = "rejected"  // 送达未引用有效签收凭证，不应用
+)
+
+// 对账差异处理方式
+const (
+	DivergenceRepairedLocal = "repaired_local" // 对方更新，已修复本地
+	DivergenceRepublished   = "republished"    // 本地更新，已重新发布快照让对方修复
+	DivergenceConflict      = "conflict"       // 同一时刻两边状态不同，需人工处理
+	DivergenceRejected      = "rejected"       // 对方报告送达但无有效签收凭证，需人工处理
+)
+
+// SyncEvent 信件/任务生命周期事件
+type SyncEvent struct {
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Source         string    `json:"source"`
+	SchemaVersion  int       `json:"schema_version"`
+	LetterCode     string    `json:"letter_code"`
+	Status         string    `json:"status"`               // 统一生命周期状态
+	CourierID      string    `json:"courier_id,omitempty"` // 信使用户ID
+	PickupOPCode   string    `json:"pickup_op_code,omitempty"`
+	DeliveryOPCode string    `json:"delivery_op_code,omitempty"`
+	CurrentOPCode  string    `json:"current_op_code,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	Note           string    `json:"note,omitempty"`
+	ReceiptID      string    `json:"receipt_id,omitempty"` // 送达时的签收凭证ID
+	OccurredAt     time.Time `json:"occurred_at"`          // 状态实际发生的时间，快照为本地最近一次变化时间
+}
+
+// IsSnapshot 是否为对账快照
+func (e *SyncEvent) IsSnapshot() bool {
+	return e.EventType == SyncEventLetterSnapshot || e.EventType == SyncEventTaskSnapshot
+}
+
+// TaskOutboxEvent 任务出站事件，与任务状态在同一事务中写入
+type TaskOutboxEvent struct {
+	ID          string     `gorm:"primaryKey;type:varchar(36)" json:"id"` // 即 event_id
+	EventType   string     `gorm:"not null;type:varchar(50)" json:"event_type"`
+	LetterCode  string     `gorm:"not null;index;type:varchar(50)" json:"letter_code"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"`
+	Status      string     `gorm:"not null;type:varchar(20);default:pending;index" json:"status"`
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// TaskSyncInbox 已处理的 backend 事件，按 event_id 去重
+type TaskSyncInbox struct {
+	EventID     string    `gorm:"primaryKey;type:varchar(36)" json:"event_id"`
+	EventType   string    `gorm:"type:varchar(50)" json:"event_type"`
+	Source      string    `gorm:"type:varchar(30)" json:"source"`
+	LetterCode  string    `gorm:"index;type:varchar(50)" json:"letter_code"`
+	Result      string    `gorm:"type:varchar(20)" json:"result"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// TaskSyncState 每封信最近一次已知的生命周期状态，按发生时间先后决定是否应用对方事件
+type TaskSyncState struct {
+	LetterCode  string    `gorm:"primaryKey;type:varchar(50)" json:"letter_code"`
+	Status      string    `gorm:"not null;type:varchar(20)" json:"status"`
+	LastEventAt time.Time `gorm:"not null" json:"last_event_at"`
+	LastEventID string    `gorm:"type:varchar(36)" json:"last_event_id"`
+	LastSource  string    `gorm:"type:varchar(30)" json:"last_source"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TaskSyncDivergence 对账发现的两边状态差异
+type TaskSyncDivergence struct {
+	ID            string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	LetterCode    string    `gorm:"not null;index;type:varchar(50)" json:"letter_code"`
+	LocalStatus   string    `gorm:"type:varchar(20)" json:"local_status"`
+	RemoteStatus  string    `gorm:"type:varchar(20)" json:"remote_status"`
+	RemoteSource  string    `gorm:"type:varchar(30)" json:"remote_source"`
+	LocalEventAt  time.Time `json:"local_event_at"`
+	RemoteEventAt time.Time `json:"remote_event_at"`
+	Resolution    string    `gorm:"type:varchar(20);index" json:"resolution"`
+	DetectedAt    time.Time `gorm:"index" json:"detected_at"`
+}
+
+// SyncDivergenceQuery 对账差异查询参数
+type SyncDivergenceQuery struct {
+	Resolution string `form:"resolution"`
+	Limit      int    `form:"limit,default=20"`
+	Offset     int    `form:"offset,default=0"`
+}
+
+// SyncStatusResponse 同步概况
+type SyncStatusResponse struct {
+	PendingOutbox    int64      `json:"pending_outbox"`
+	OldestPendingAt  *time.Time `json:"oldest_pending_at,omitempty"`
+	ProcessedInbox   int64      `json:"processed_inbox"`
+	OpenConflicts    int64      `json:"open_conflicts"`
+	DeadLetters      int64      `json:"dead_letters"`
+	LastReconciledAt *time.Time `json:"last_reconciled_at,omitempty"`
+}
+
+// TaskLifecycleStatus 任务状态映射为统一生命周期状态
+func TaskLifecycleStatus(status string) string {
+	switch status {
+	case TaskStatusAvailable:
+		return LifecycleCreated
+	case TaskStatusAccepted:
+		return LifecycleAssigned
+	case TaskStatusCollected:
+		return LifecycleCollected
+	case TaskStatusInTransit:
+		return LifecycleInTransit
+	case TaskStatusDelivered:
+		return LifecycleDelivered
+	case TaskStatusFailed:
+		return LifecycleFailed
+	case TaskStatusReturned:
+		return LifecycleReturned
+	case TaskStatusCanceled:
+		return LifecycleCanceled
+	default:
+		return ""
+	}
+}
+
+// TaskStatusFromLifecycle 统一生命周期状态映射为任务状态
+func TaskStatusFromLifecycle(status string) string {
+	switch status {
+	case LifecycleCreated:
+		return TaskStatusAvailable
+	case LifecycleAssigned:
+		return TaskStatusAccepted
+	case LifecycleCollected:
+		return TaskStatusCollected
+	case LifecycleInTransit:
+		return TaskStatusInTransit
+	case LifecycleDelivered:
+		return TaskStatusDelivered
+	case LifecycleFailed:
+		return TaskStatusFailed
+	case LifecycleReturned:
+		return TaskStatusReturned
+	case LifecycleCanceled:
+		return TaskStatusCanceled
+	default:
+		return ""
+	}
+}
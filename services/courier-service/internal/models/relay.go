@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+)
+
+// 接力段类型
+const (
+	RelayLegCollection    = "collection"    // 寄出楼栋信使收件
+	RelayLegConsolidation = "consolidation" // 寄出学校校级信使集中
+	RelayLegTransfer      = "transfer"      // 城市级信使跨校转运
+	RelayLegDelivery      = "delivery"      // 目的学校信使投递
+)
+
+// 接力段状态
+const (
+	RelayLegStatusPending   = "pending"    // 等待上一段交接
+	RelayLegStatusInCustody = "in_custody" // 信件由本段信使保管
+	RelayLegStatusHandedOff = "handed_off" // 已交接给下一段
+	RelayLegStatusDelivered = "delivered"  // 最后一段已投递
+)
+
+// RelayLegRewardShare 各段奖励占任务总奖励的比例
+var RelayLegRewardShare = map[string]float64{
+	RelayLegCollection:    0.2,
+	RelayLegConsolidation: 0.2,
+	RelayLegTransfer:      0.35,
+	RelayLegDelivery:      0.25,
+}
+
+// RelayLegCandidateLevels 各段可承接的信使等级，按优先顺序排列
+var RelayLegCandidateLevels = map[string][]int{
+	RelayLegCollection:    {CourierLevelOne, CourierLevelTwo},
+	RelayLegConsolidation: {CourierLevelThree},
+	RelayLegTransfer:      {CourierLevelFour},
+	RelayLegDelivery:      {CourierLevelOne, CourierLevelTwo, CourierLevelThree},
+}
+
+// RelayLeg 跨校接力段，一封信的路线沿信使层级拆成首尾相接的多段
+type RelayLeg struct {
+	ID             string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TaskID         string     `gorm:"not null;uniqueIndex:idx_relay_task_seq" json:"task_id"`
+	LetterID       string     `gorm:"not null;index" json:"letter_id"`
+	Sequence       int        `gorm:"not null;uniqueIndex:idx_relay_task_seq" json:"sequence"`
+	LegType        string     `gorm:"not null;type:varchar(20)" json:"leg_type"`
+	FromOPCode     string     `json:"from_op_code" gorm:"type:varchar(6)"`      // 本段起点（寄出地址或学校前缀）
+	ToOPCode       string     `json:"to_op_code" gorm:"type:varchar(6)"`        // 本段交接点
+	CourierID      *string    `gorm:"index;type:varchar(36)" json:"courier_id"` // 承接信使用户ID，未匹配到时为空
+	Status         string     `gorm:"not null;type:varchar(20);default:pending;index" json:"status"`
+	Reward         float64    `json:"reward"`                     // 本段奖励
+	CustodyStartAt *time.Time `json:"custody_start_at,omitempty"` // 接手时间
+	CustodyEndAt   *time.Time `json:"custody_end_at,omitempty"`   // 交出或投递时间
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// 交接码：保管信使申请后当面告知接手信使，只保存摘要
+	HandoffCodeHash      string     `gorm:"type:varchar(64)" json:"-"`
+	HandoffCodeExpiresAt *time.Time `json:"-"`
+	HandoffCodeAttempts  int        `gorm:"default:0" json:"-"`
+}
+
+// IsFinished 本段是否已结束保管
+func (l *RelayLeg) IsFinished() bool {
+	return l.Status == RelayLegStatusHandedOff || l.Status == RelayLegStatusDelivered
+}
+
+// RelayHandoff 信使之间的交接扫码记录
+type RelayHandoff struct {
+	ID            string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TaskID        string    `gorm:"not null;index" json:"task_id"`
+	LetterID      string    `gorm:"not null" json:"letter_id"`
+	FromLegID     string    `gorm:"not null;type:varchar(36)" json:"from_leg_id"`
+	ToLegID       string    `gorm:"not null;type:varchar(36)" json:"to_leg_id"`
+	FromCourierID string    `gorm:"not null;type:varchar(36)" json:"from_courier_id"`
+	ToCourierID   string    `gorm:"not null;type:varchar(36)" json:"to_courier_id"`
+	OPCode        string    `json:"op_code" gorm:"type:varchar(6)"` // 交接地点OP Code
+	Location      string    `json:"location"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Note          string    `json:"note"`
+	HandedOffAt   time.Time `json:"handed_off_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RelayAssignLegRequest 指定接力段信使请求
+type RelayAssignLegRequest struct {
+	CourierID string `json:"courier_id" binding:"required"` // 信使用户ID
+}
+
+// RelayScanRequest 接力扫码请求，收件、交接、投递共用
+type RelayScanRequest struct {
+	FromCourierID string  `json:"from_courier_id"` // 交接时为交出信使的用户ID
+	HandoffCode   string  `json:"handoff_code"`    // 交接时由交出信使出示的交接码
+	OPCode        string  `json:"op_code" binding:"omitempty,len=6"`
+	Location      string  `json:"location"`
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	Note          string  `json:"note"`
+	QRToken       string  `json:"qr_token,omitempty"` // 扫码时读到的签名令牌
+}
+
+// RelayHandoffCode 交出信使申请的一次性交接码，当面告知接手信使
+type RelayHandoffCode struct {
+	TaskID    string    `json:"task_id"`
+	LegID     string    `json:"leg_id"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RelayChain 接力链详情
+type RelayChain struct {
+	Task             *Task          `json:"task"`
+	Legs             []RelayLeg     `json:"legs"`
+	Handoffs         []RelayHandoff `json:"handoffs"`
+	CurrentLeg       *RelayLeg      `json:"current_leg,omitempty"`        // 当前保管段
+	CustodyCourierID *string        `json:"custody_courier_id,omitempty"` // 当前保管信使
+}
+
+// RelayLegListResponse 信使接力段列表
+type RelayLegListResponse struct {
+	Legs         []RelayLeg `json:"legs"`
+	Total        int64      `json:"total"`
+	EarnedReward float64    `json:"earned_reward"` // 已完成段的奖励合计
+}
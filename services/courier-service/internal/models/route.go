@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 路线站点类型
+const (
+	RouteStopPickup   = "pickup"   // 取件
+	RouteStopDelivery = "delivery" // 送达
+)
+
+// RoutePlanQuery 路线规划请求参数
+type RoutePlanQuery struct {
+	Lat            float64 `form:"lat" binding:"required,min=-90,max=90"`   // 信使当前纬度
+	Lng            float64 `form:"lng" binding:"required,min=-180,max=180"` // 信使当前经度
+	SpeedKmh       float64 `form:"speed_kmh" binding:"omitempty,min=1,max=60"`
+	ServiceMinutes int     `form:"service_minutes" binding:"omitempty,min=0,max=30"` // 每个站点的停留时间
+}
+
+// RouteStop 路线中的一个站点
+type RouteStop struct {
+	Sequence             int        `json:"sequence"`
+	TaskID               string     `json:"task_id"`
+	LetterID             string     `json:"letter_id"`
+	StopType             string     `json:"stop_type"` // pickup, delivery
+	Location             string     `json:"location"`
+	OPCode               string     `json:"op_code,omitempty"`
+	Lat                  float64    `json:"lat"`
+	Lng                  float64    `json:"lng"`
+	LegDistanceKm        float64    `json:"leg_distance_km"` // 距上一站距离
+	LegDistance          string     `json:"leg_distance"`
+	CumulativeDistanceKm float64    `json:"cumulative_distance_km"`
+	ETA                  time.Time  `json:"eta"`
+	Deadline             *time.Time `json:"deadline,omitempty"`
+	Late                 bool       `json:"late"` // 预计超过截止时间
+}
+
+// RoutePlan 信使多站点路线规划结果
+type RoutePlan struct {
+	CourierID          string      `json:"courier_id"`
+	OriginLat          float64     `json:"origin_lat"`
+	OriginLng          float64     `json:"origin_lng"`
+	Stops              []RouteStop `json:"stops"`
+	TotalDistanceKm    float64     `json:"total_distance_km"`
+	TotalDistance      string      `json:"total_distance"`
+	BaselineDistanceKm float64     `json:"baseline_distance_km"` // 按接单顺序逐单完成的距离
+	TotalMinutes       int         `json:"total_minutes"`
+	EstimatedFinishAt  time.Time   `json:"estimated_finish_at"`
+	LateStops          int         `json:"late_stops"`
+	UnplannedTaskIDs   []string    `json:"unplanned_task_ids,omitempty"` // 缺少坐标无法规划的任务
+	GeneratedAt        time.Time   `json:"generated_at"`
+}
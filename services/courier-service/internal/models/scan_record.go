@@ -31,6 +31,10 @@ type ScanRecord struct {
 	DeviceInfo        string `json:"device_info,omitempty" gorm:"type:json"`                   // 设备信息
 	IPAddress         string `json:"ip_address,omitempty" gorm:"type:varchar(45)"`             // IP地址
 	UserAgent         string `json:"user_agent,omitempty" gorm:"type:text"`                    // 用户代理
+
+	// 离线同步字段：Timestamp 为设备上的实际扫码时间
+	ClientEventID string     `json:"client_event_id,omitempty" gorm:"type:varchar(64);index"` // 客户端事件ID
+	SyncedAt      *time.Time `json:"synced_at,omitempty"`                                     // 离线扫码上传时间
 }
 
 // ScanRequest 扫码请求 - 增强FSD条码系统支持
@@ -48,6 +52,12 @@ type ScanRequest struct {
 	OperatorOPCode  string `json:"operator_op_code,omitempty"`  // 操作员OP Code位置
 	ScannerLevel    int    `json:"scanner_level,omitempty"`     // 扫码员级别（1-4）
 	ValidationType  string `json:"validation_type,omitempty"`   // 验证类型：quick/full
+
+	// 二维码签名令牌，可直接传扫码内容（带 t 参数的阅读地址）
+	QRToken string `json:"qr_token,omitempty"`
+
+	// 投递失败原因，action 为 failed 时使用，缺省为 other
+	FailureReason string `json:"failure_reason,omitempty" binding:"omitempty,oneof=recipient_absent wrong_op_code access_denied recipient_refused other"`
 }
 
 // ScanResponse 扫码响应 - 增强FSD条码系统支持
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+)
+
+// 离线扫码同步结果
+const (
+	ScanSyncApplied   = "applied"   // 已应用到任务
+	ScanSyncDuplicate = "duplicate" // 重复提交，返回首次处理结果
+	ScanSyncSkipped   = "skipped"   // 任务已处于该状态或更靠后的状态
+	ScanSyncConflict  = "conflict"  // 离线期间任务已改派等冲突
+	ScanSyncRejected  = "rejected"  // 事件本身无效
+)
+
+// 离线扫码同步冲突原因
+const (
+	ScanSyncReasonTaskNotFound      = "task_not_found"
+	ScanSyncReasonReassigned        = "task_reassigned"
+	ScanSyncReasonNotAssigned       = "task_not_assigned"
+	ScanSyncReasonBeforeAssignment  = "scan_before_assignment"
+	ScanSyncReasonAlreadyInStatus   = "already_in_status"
+	ScanSyncReasonSuperseded        = "superseded"
+	ScanSyncReasonInvalidTransition = "invalid_transition"
+	ScanSyncReasonFutureDeviceTime  = "device_time_in_future"
+	ScanSyncReasonStaleDeviceTime   = "device_time_too_old"
+	ScanSyncReasonRelayTask         = "relay_task"
+	ScanSyncReasonInvalidQRToken    = "invalid_qr_token"
+	ScanSyncReasonReceiptRequired   = "receipt_required"
+)
+
+// ScanSyncEvent 离线扫码事件处理记录，按 (courier_id, client_event_id) 保证幂等
+type ScanSyncEvent struct {
+	ID            string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CourierID     string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_scan_sync_client_event" json:"courier_id"`
+	ClientEventID string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_scan_sync_client_event" json:"client_event_id"`
+	DeviceID      string    `gorm:"type:varchar(64)" json:"device_id"`
+	LetterID      string    `gorm:"type:varchar(100);index" json:"letter_id"`
+	TaskID        string    `gorm:"type:varchar(36)" json:"task_id,omitempty"`
+	Action        string    `gorm:"type:varchar(20)" json:"action"`
+	Result        string    `gorm:"type:varchar(20);not null" json:"result"`
+	Reason        string    `gorm:"type:varchar(50)" json:"reason,omitempty"`
+	OldStatus     string    `gorm:"type:varchar(20)" json:"old_status,omitempty"`
+	NewStatus     string    `gorm:"type:varchar(20)" json:"new_status,omitempty"`
+	ScanRecordID  string    `gorm:"type:varchar(36)" json:"scan_record_id,omitempty"`
+	DeviceTime    time.Time `json:"device_time"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ScanSyncEventInput 客户端离线缓存的一次扫码
+type ScanSyncEventInput struct {
+	ClientEventID  string    `json:"client_event_id" binding:"required,max=64"`
+	LetterCode     string    `json:"letter_code" binding:"required"`
+	Action         string    `json:"action" binding:"required,oneof=collected in_transit delivered failed"`
+	DeviceTime     time.Time `json:"device_time" binding:"required"` // 设备上实际扫码时间
+	Location       string    `json:"location"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Note           string    `json:"note"`
+	PhotoURL       string    `json:"photo_url"`
+	OperatorOPCode string    `json:"operator_op_code,omitempty"`
+	QRToken        string    `json:"qr_token,omitempty"` // 扫码时读到的签名令牌
+
+	// 投递失败原因，action 为 failed 时使用，缺省为 other
+	FailureReason string `json:"failure_reason,omitempty" binding:"omitempty,oneof=recipient_absent wrong_op_code access_denied recipient_refused other"`
+}
+
+// ScanSyncRequest 批量同步离线扫码请求，事件按设备上的扫码顺序排列
+type ScanSyncRequest struct {
+	DeviceID string               `json:"device_id" binding:"max=64"`
+	Events   []ScanSyncEventInput `json:"events" binding:"required,min=1,max=200,dive"`
+}
+
+// ScanSyncResult 单个事件的同步结果
+type ScanSyncResult struct {
+	ClientEventID string    `json:"client_event_id"`
+	LetterCode    string    `json:"letter_code"`
+	Result        string    `json:"result"`
+	Reason        string    `json:"reason,omitempty"`
+	FirstResult   string    `json:"first_result,omitempty"` // 重复提交时首次处理的结果
+	OldStatus     string    `json:"old_status,omitempty"`
+	NewStatus     string    `json:"new_status,omitempty"`
+	ScanRecordID  string    `json:"scan_record_id,omitempty"`
+	DeviceTime    time.Time `json:"device_time"`
+}
+
+// ScanSyncResponse 批量同步结果汇总
+type ScanSyncResponse struct {
+	Results    []ScanSyncResult `json:"results"`
+	Applied    int              `json:"applied"`
+	Duplicates int              `json:"duplicates"`
+	Skipped    int              `json:"skipped"`
+	Conflicts  int              `json:"conflicts"`
+	Rejected   int              `json:"rejected"`
+	ServerTime time.Time        `json:"server_time"`
+}
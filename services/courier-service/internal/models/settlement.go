@@ -0,0 +1,140 @@
+package models
+
+import (
+	"time"
+)
+
+// 结算周期类型
+const (
+	SettlementPeriodMonthly = "monthly" // 按月
+	SettlementPeriodTerm    = "term"    // 按学期
+)
+
+// 结算周期状态
+const (
+	SettlementPeriodOpen   = "open"   // 进行中，对账单随业务实时重算
+	SettlementPeriodClosed = "closed" // 已关账，对账单不可再修改
+)
+
+// 对账单明细类型
+const (
+	StatementLineTask       = "task"        // 投递任务奖励
+	StatementLineRelayLeg   = "relay_leg"   // 跨校接力段奖励
+	StatementLineSLABonus   = "sla_bonus"   // 时效达标奖励
+	StatementLineSLAPenalty = "sla_penalty" // 超时扣款
+	StatementLineIncentive  = "incentive"   // 已领取的激励
+	StatementLineAdjustment = "adjustment"  // 申诉调整
+)
+
+// 申诉状态
+const (
+	StatementDisputePending  = "pending"
+	StatementDisputeApproved = "approved"
+	StatementDisputeRejected = "rejected"
+)
+
+// 结算规则
+const (
+	SettlementSLAPenaltyPerBreach = 1.0  // 每次超时扣款（元）
+	SettlementSLABonusRate        = 0.1  // 达标奖励占任务奖励的比例
+	SettlementSLABonusMinRate     = 95.0 // 达标所需的时效达成率（百分比）
+	SettlementSLABonusMinTasks    = 10   // 达标奖励所需的最少完成任务数
+)
+
+// SettlementPeriod 结算周期
+type SettlementPeriod struct {
+	ID        string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Name      string     `gorm:"not null;type:varchar(50)" json:"name"` // 如 2025-06、2025春季学期
+	Type      string     `gorm:"not null;type:varchar(20);index" json:"type"`
+	StartAt   time.Time  `gorm:"not null;index" json:"start_at"`
+	EndAt     time.Time  `gorm:"not null;index" json:"end_at"` // 不含
+	Status    string     `gorm:"not null;type:varchar(20);default:open;index" json:"status"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	ClosedBy  string     `json:"closed_by,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CourierStatement 信使在某个结算周期的对账单
+type CourierStatement struct {
+	ID                string          `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	PeriodID          string          `gorm:"not null;type:varchar(36);uniqueIndex:idx_statement_period_courier" json:"period_id"`
+	CourierID         string          `gorm:"not null;type:varchar(36);uniqueIndex:idx_statement_period_courier" json:"courier_id"` // 信使用户ID
+	TaskCount         int             `json:"task_count"`
+	TaskEarnings      float64         `json:"task_earnings"` // 任务与接力段奖励
+	SLABonus          float64         `json:"sla_bonus"`
+	SLAPenalty        float64         `json:"sla_penalty"` // 负数
+	IncentiveTotal    float64         `json:"incentive_total"`
+	AdjustmentTotal   float64         `json:"adjustment_total"`
+	TotalAmount       float64         `json:"total_amount"`
+	SLAComplianceRate float64         `json:"sla_compliance_rate"`
+	Status            string          `gorm:"not null;type:varchar(20);default:open" json:"status"` // 随周期 open/closed
+	GeneratedAt       time.Time       `json:"generated_at"`
+	Lines             []StatementLine `gorm:"foreignKey:StatementID" json:"lines,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// StatementLine 对账单明细
+type StatementLine struct {
+	ID          string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	StatementID string    `gorm:"not null;type:varchar(36);index" json:"statement_id"`
+	Type        string    `gorm:"not null;type:varchar(20)" json:"type"`
+	ReferenceID string    `gorm:"type:varchar(50)" json:"reference_id,omitempty"` // 任务ID、接力段ID、超时记录ID、申诉ID等
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Points      int       `json:"points,omitempty"` // 积分类激励只记积分，不计金额
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// CourierIncentiveClaim 信使领取激励的记录，计入领取时所在周期的对账单
+type CourierIncentiveClaim struct {
+	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CourierID string    `gorm:"not null;type:varchar(36);index" json:"courier_id"` // 信使用户ID
+	Type      string    `gorm:"not null;type:varchar(20)" json:"type"`
+	Reference string    `json:"reference,omitempty"`
+	Amount    float64   `json:"amount"`
+	Points    int       `json:"points"`
+	ClaimedAt time.Time `gorm:"index" json:"claimed_at"`
+}
+
+// StatementDispute 对账单申诉，由信使的上级审批，通过后以调整项计入对账单
+type StatementDispute struct {
+	ID              string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	StatementID     string     `gorm:"not null;type:varchar(36);index" json:"statement_id"`
+	PeriodID        string     `gorm:"not null;type:varchar(36);index" json:"period_id"`
+	CourierID       string     `gorm:"not null;type:varchar(36);index" json:"courier_id"`   // 申诉信使用户ID
+	ApproverID      *string    `gorm:"type:varchar(36);index" json:"approver_id,omitempty"` // 上级信使用户ID，无上级时由管理员审批
+	LineID          *string    `gorm:"type:varchar(36)" json:"line_id,omitempty"`           // 针对的明细
+	Reason          string     `gorm:"type:text;not null" json:"reason"`
+	RequestedAmount float64    `json:"requested_amount"` // 希望调整的金额，可为负
+	ApprovedAmount  *float64   `json:"approved_amount,omitempty"`
+	Status          string     `gorm:"not null;type:varchar(20);default:pending;index" json:"status"`
+	ResolvedBy      string     `json:"resolved_by,omitempty"`
+	ResolutionNote  string     `json:"resolution_note,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SettlementPeriodRequest 创建结算周期
+type SettlementPeriodRequest struct {
+	Name    string    `json:"name" binding:"required,max=50"`
+	Type    string    `json:"type" binding:"required,oneof=monthly term"`
+	StartAt time.Time `json:"start_at" binding:"required"`
+	EndAt   time.Time `json:"end_at" binding:"required"`
+}
+
+// StatementDisputeRequest 发起申诉
+type StatementDisputeRequest struct {
+	LineID          string  `json:"line_id"`
+	Reason          string  `json:"reason" binding:"required,max=500"`
+	RequestedAmount float64 `json:"requested_amount" binding:"required"`
+}
+
+// ResolveDisputeRequest 审批申诉
+type ResolveDisputeRequest struct {
+	Approve        *bool    `json:"approve" binding:"required"`
+	ApprovedAmount *float64 `json:"approved_amount"` // 为空时按申请金额
+	Note           string   `json:"note" binding:"max=500"`
+}
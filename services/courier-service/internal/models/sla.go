@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+)
+
+// SLA阶段
+const (
+	SLAStageAccept  = "accept"  // 创建 → 接取
+	SLAStageCollect = "collect" // 接取 → 收取
+	SLAStageDeliver = "deliver" // 收取 → 送达
+)
+
+// SLA超时记录状态
+const (
+	SLABreachOpen     = "open"
+	SLABreachResolved = "resolved"
+)
+
+// SLA超时处理结果
+const (
+	SLAResolutionCompletedLate = "completed_late" // 超时后自行完成该阶段
+	SLAResolutionReassigned    = "reassigned"     // 上级改派
+	SLAResolutionClosed        = "closed"         // 任务取消、失败或转入其他流程
+)
+
+// TaskSLAPolicy 按优先级配置的各阶段时限
+type TaskSLAPolicy struct {
+	Priority             string    `gorm:"primaryKey;type:varchar(20)" json:"priority"`
+	AcceptMinutes        int       `gorm:"not null" json:"accept_minutes"`
+	CollectMinutes       int       `gorm:"not null" json:"collect_minutes"`
+	DeliverMinutes       int       `gorm:"not null" json:"deliver_minutes"`
+	EscalateAfterMinutes int       `gorm:"not null" json:"escalate_after_minutes"` // 超时未处理时继续上报上一级的间隔
+	UpdatedBy            string    `json:"updated_by"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// StageLimit 指定阶段的时限
+func (p *TaskSLAPolicy) StageLimit(stage string) time.Duration {
+	switch stage {
+	case SLAStageAccept:
+		return time.Duration(p.AcceptMinutes) * time.Minute
+	case SLAStageCollect:
+		return time.Duration(p.CollectMinutes) * time.Minute
+	default:
+		return time.Duration(p.DeliverMinutes) * time.Minute
+	}
+}
+
+// DefaultTaskSLAPolicies 默认SLA时限
+var DefaultTaskSLAPolicies = []TaskSLAPolicy{
+	{Priority: TaskPriorityExpress, AcceptMinutes: 15, CollectMinutes: 60, DeliverMinutes: 180, EscalateAfterMinutes: 30},
+	{Priority: TaskPriorityUrgent, AcceptMinutes: 30, CollectMinutes: 120, DeliverMinutes: 360, EscalateAfterMinutes: 60},
+	{Priority: TaskPriorityNormal, AcceptMinutes: 120, CollectMinutes: 480, DeliverMinutes: 1440, EscalateAfterMinutes: 240},
+}
+
+// SLABreach 任务阶段超时记录，超时后逐级上报给上级信使
+type SLABreach struct {
+	ID               string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TaskID           string     `gorm:"not null;uniqueIndex:idx_sla_breach_stage" json:"task_id"`
+	Stage            string     `gorm:"not null;type:varchar(20);uniqueIndex:idx_sla_breach_stage" json:"stage"`
+	DueAt            time.Time  `gorm:"not null;uniqueIndex:idx_sla_breach_stage" json:"due_at"` // 同一阶段改派后重新计时，按到期时间区分
+	Priority         string     `gorm:"type:varchar(20)" json:"priority"`
+	CourierID        *string    `gorm:"index;type:varchar(36)" json:"courier_id,omitempty"` // 超时时负责的信使用户ID
+	DetectedAt       time.Time  `json:"detected_at"`
+	EscalationLevel  int        `gorm:"default:0" json:"escalation_level"`                    // 已上报层数
+	EscalatedTo      *string    `gorm:"index;type:varchar(36)" json:"escalated_to,omitempty"` // 当前处理人（信使用户ID）
+	EscalatedAt      *time.Time `json:"escalated_at,omitempty"`
+	NextEscalationAt *time.Time `gorm:"index" json:"next_escalation_at,omitempty"`
+	Status           string     `gorm:"not null;type:varchar(20);default:open;index" json:"status"`
+	Resolution       string     `gorm:"type:varchar(20)" json:"resolution,omitempty"`
+	ResolvedBy       string     `json:"resolved_by,omitempty"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// SLABreachView 上级信使查看的超时任务
+type SLABreachView struct {
+	SLABreach
+	Task           *Task `json:"task"`
+	OverdueMinutes int   `json:"overdue_minutes"`
+}
+
+// SLABreachQuery 超时任务查询参数
+type SLABreachQuery struct {
+	Status string `form:"status,default=open"`
+	Stage  string `form:"stage"`
+	Limit  int    `form:"limit,default=20"`
+	Offset int    `form:"offset,default=0"`
+}
+
+// SLAReassignRequest 上级改派超时任务
+type SLAReassignRequest struct {
+	NewCourierID string `json:"new_courier_id" binding:"required"` // 信使ID
+	Reason       string `json:"reason"`
+}
+
+// TaskSLAPolicyRequest 更新SLA时限请求
+type TaskSLAPolicyRequest struct {
+	AcceptMinutes        int `json:"accept_minutes" binding:"required,min=1"`
+	CollectMinutes       int `json:"collect_minutes" binding:"required,min=1"`
+	DeliverMinutes       int `json:"deliver_minutes" binding:"required,min=1"`
+	EscalateAfterMinutes int `json:"escalate_after_minutes" binding:"required,min=1"`
+}
+
+// SLACompliance 信使SLA达成情况
+type SLACompliance struct {
+	TotalStages    int     `json:"total_stages"`
+	OnTimeStages   int     `json:"on_time_stages"`
+	BreachedStages int     `json:"breached_stages"`
+	ComplianceRate float64 `json:"compliance_rate"` // 百分比，无记录时为100
+}
@@ -24,14 +24,26 @@ type Task struct {
 	ContactInfo       string     `json:"contact_info"`   // 联系方式
 	SpecialNote       string     `json:"special_note"`   // 特殊说明
 	AcceptedAt        *time.Time `json:"accepted_at,omitempty"`
+	CollectedAt       *time.Time `json:"collected_at,omitempty"`
 	CompletedAt       *time.Time `json:"completed_at,omitempty"`
 	Deadline          *time.Time `json:"deadline,omitempty"`
+	ExpectedPickupAt  *time.Time `json:"expected_pickup_at,omitempty"` // 按信使排班预测的取件时间
 
 	// FSD增强字段 - OP Code支持
 	PickupOPCode   string `json:"pickup_op_code,omitempty" gorm:"type:varchar(6);index"`   // 取件OP Code
 	DeliveryOPCode string `json:"delivery_op_code,omitempty" gorm:"type:varchar(6);index"` // 送达OP Code
 	CurrentOPCode  string `json:"current_op_code,omitempty" gorm:"type:varchar(6)"`        // 当前位置OP Code
 
+	IsRelay bool `json:"is_relay" gorm:"default:false"` // 跨校接力任务，状态由接力段交接推进
+
+	// 投递失败与退回
+	SenderID        *string    `json:"sender_id,omitempty" gorm:"type:varchar(36);index"`    // 寄件人用户ID，用于失败通知
+	SenderOPCode    string     `json:"sender_op_code,omitempty" gorm:"type:varchar(6)"`      // 寄件人OP Code，退回目的地
+	AttemptCount    int        `json:"attempt_count" gorm:"default:0"`                       // 已失败的投递次数
+	FailureReason   string     `json:"failure_reason,omitempty" gorm:"type:varchar(30)"`     // 最近一次失败原因
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty" gorm:"index"`                 // 计划重投时间
+	ReturnForTaskID *string    `json:"return_for_task_id,omitempty" gorm:"type:varchar(50)"` // 退件任务对应的原任务
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -61,6 +73,8 @@ const (
 	TaskStatusDelivered = "delivered"  // 已投递
 	TaskStatusFailed    = "failed"     // 投递失败
 	TaskStatusCanceled  = "canceled"   // 已取消
+	TaskStatusReturned  = "returned"   // 重投用尽，已转为退件任务
+	TaskStatusHeld      = "held"       // 退件也无法送达，信件滞留站点等待人工处理
 )
 
 // 任务优先级常量
@@ -76,6 +90,7 @@ var ValidTaskTransitions = map[string][]string{
 	TaskStatusAccepted:  {TaskStatusCollected, TaskStatusCanceled},
 	TaskStatusCollected: {TaskStatusInTransit},
 	TaskStatusInTransit: {TaskStatusDelivered, TaskStatusFailed},
+	TaskStatusFailed:    {TaskStatusAvailable, TaskStatusReturned, TaskStatusHeld},
 }
 
 // CanTransitionTo 检查是否可以转换到目标状态
@@ -107,5 +122,7 @@ func (t *Task) IsCompleted() bool {
 func (t *Task) IsActive() bool {
 	return t.Status != TaskStatusDelivered &&
 		t.Status != TaskStatusFailed &&
-		t.Status != TaskStatusCanceled
+		t.Status != TaskStatusCanceled &&
+		t.Status != TaskStatusReturned &&
+		t.Status != TaskStatusHeld
 }
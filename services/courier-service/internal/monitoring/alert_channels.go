@@ -0,0 +1,373 @@
+package monitoring
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 告警渠道默认参数
+const (
+	defaultAlertTimeout    = 10 * time.Second
+	defaultAlertMaxRetries = 3
+	defaultAlertRetryDelay = time.Second
+)
+
+// alertLevelRank 告警级别排序，用于渠道的最低级别过滤
+var alertLevelRank = map[AlertLevel]int{
+	AlertLevelInfo:     0,
+	AlertLevelWarning:  1,
+	AlertLevelCritical: 2,
+	AlertLevelFatal:    3,
+}
+
+// levelAtLeast 告警级别是否不低于 min，min 为空时不过滤
+func levelAtLeast(level, min AlertLevel) bool {
+	if min == "" {
+		return true
+	}
+	return alertLevelRank[level] >= alertLevelRank[min]
+}
+
+// alertPayload 通用 Webhook 的告警内容
+type alertPayload struct {
+	ID          string            `json:"id"`
+	Status      AlertStatus       `json:"status"`
+	Level       AlertLevel        `json:"level"`
+	Message     string            `json:"message"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartTime   time.Time         `json:"start_time"`
+	EndTime     *time.Time        `json:"end_time,omitempty"`
+	Count       int               `json:"count"`
+}
+
+// groupPayload 通用 Webhook 的告警分组内容
+type groupPayload struct {
+	GroupKey  string            `json:"group_key"`
+	Labels    map[string]string `json:"group_labels"`
+	Status    AlertStatus       `json:"status"`
+	Alerts    []alertPayload    `json:"alerts"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// WebhookAlertHandler Webhook告警处理器
+// 请求体使用 HMAC-SHA256 签名：X-Alert-Signature = hex(hmac(secret, timestamp + "." + body))
+type WebhookAlertHandler struct {
+	URL        string
+	Secret     string
+	MinLevel   AlertLevel
+	MaxRetries int
+	client     *http.Client
+}
+
+// NewWebhookAlertHandler 创建Webhook告警处理器，secret 为空时不签名
+func NewWebhookAlertHandler(webhookURL, secret string) *WebhookAlertHandler {
+	return &WebhookAlertHandler{
+		URL:        webhookURL,
+		Secret:     secret,
+		MaxRetries: defaultAlertMaxRetries,
+		client:     &http.Client{Timeout: defaultAlertTimeout},
+	}
+}
+
+func (h *WebhookAlertHandler) Handle(ctx context.Context, alert *Alert) error {
+	return h.HandleGroup(ctx, singleAlertGroup(alert))
+}
+
+// HandleGroup 以一个请求发送整组告警
+func (h *WebhookAlertHandler) HandleGroup(ctx context.Context, group *AlertGroup) error {
+	payload := groupPayload{
+		GroupKey:  group.Key,
+		Labels:    group.Labels,
+		Status:    group.Status(),
+		Timestamp: time.Now(),
+	}
+	for _, alert := range group.Alerts {
+		payload.Alerts = append(payload.Alerts, alertPayload{
+			ID:          alert.ID,
+			Status:      alert.Status,
+			Level:       alert.Level,
+			Message:     alert.Message,
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartTime:   alert.StartTime,
+			EndTime:     alert.EndTime,
+			Count:       alert.Count,
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if h.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		headers["X-Alert-Timestamp"] = timestamp
+		headers["X-Alert-Signature"] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	_, err = postAlertJSON(ctx, h.client, h.URL, body, headers, h.MaxRetries)
+	return err
+}
+
+func (h *WebhookAlertHandler) CanHandle(alert *Alert) bool {
+	return h.URL != "" && levelAtLeast(alert.Level, h.MinLevel)
+}
+
+func (h *WebhookAlertHandler) Priority() int {
+	return 500 // 中等优先级
+}
+
+// ChatAlertFlavor 群机器人类型
+type ChatAlertFlavor string
+
+const (
+	ChatAlertDingTalk ChatAlertFlavor = "dingtalk"
+	ChatAlertFeishu   ChatAlertFlavor = "feishu"
+)
+
+// ChatAlertHandler 钉钉/飞书群机器人告警处理器
+// Secret 为机器人的加签密钥，为空时按关键词或IP白名单方式发送
+type ChatAlertHandler struct {
+	URL        string
+	Secret     string
+	Flavor     ChatAlertFlavor
+	MinLevel   AlertLevel
+	MaxRetries int
+	client     *http.Client
+}
+
+// NewChatAlertHandler 创建群机器人告警处理器
+func NewChatAlertHandler(flavor ChatAlertFlavor, webhookURL, secret string) *ChatAlertHandler {
+	return &ChatAlertHandler{
+		URL:        webhookURL,
+		Secret:     secret,
+		Flavor:     flavor,
+		MaxRetries: defaultAlertMaxRetries,
+		client:     &http.Client{Timeout: defaultAlertTimeout},
+	}
+}
+
+func (h *ChatAlertHandler) Handle(ctx context.Context, alert *Alert) error {
+	return h.HandleGroup(ctx, singleAlertGroup(alert))
+}
+
+// HandleGroup 以一条群消息发送整组告警
+func (h *ChatAlertHandler) HandleGroup(ctx context.Context, group *AlertGroup) error {
+	title := fmt.Sprintf("[%s] courier-service %d 条告警", group.Status(), len(group.Alerts))
+	text := formatAlertGroupText(group)
+
+	var message map[string]interface{}
+	targetURL := h.URL
+	now := time.Now()
+
+	switch h.Flavor {
+	case ChatAlertFeishu:
+		message = map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": title + "\n" + text},
+		}
+		if h.Secret != "" {
+			// 飞书：以 timestamp\nsecret 为密钥对空串签名
+			timestamp := strconv.FormatInt(now.Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(timestamp+"\n"+h.Secret))
+			message["timestamp"] = timestamp
+			message["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+	default:
+		message = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": title,
+				"text":  "### " + title + "\n\n" + strings.ReplaceAll(text, "\n", "\n\n"),
+			},
+		}
+		if h.Secret != "" {
+			// 钉钉：以 secret 为密钥对 timestamp\nsecret 签名，放在 URL 参数中
+			timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(h.Secret))
+			mac.Write([]byte(timestamp + "\n" + h.Secret))
+			sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			separator := "?"
+			if strings.Contains(targetURL, "?") {
+				separator = "&"
+			}
+			targetURL += separator + "timestamp=" + timestamp + "&sign=" + sign
+		}
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	respBody, err := postAlertJSON(ctx, h.client, targetURL, body, nil, h.MaxRetries)
+	if err != nil {
+		return err
+	}
+
+	// 机器人接口出错时仍返回 200，需检查业务错误码（钉钉 errcode，飞书 code）
+	var result struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		if result.ErrCode != nil && *result.ErrCode != 0 {
+			return fmt.Errorf("dingtalk robot error %d: %s", *result.ErrCode, result.ErrMsg)
+		}
+		if result.Code != nil && *result.Code != 0 {
+			return fmt.Errorf("feishu robot error %d: %s", *result.Code, result.Msg)
+		}
+	}
+	return nil
+}
+
+func (h *ChatAlertHandler) CanHandle(alert *Alert) bool {
+	return h.URL != "" && levelAtLeast(alert.Level, h.MinLevel)
+}
+
+func (h *ChatAlertHandler) Priority() int {
+	return 400
+}
+
+// EmailAlertHandler SMTP邮件告警处理器
+type EmailAlertHandler struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	MinLevel AlertLevel
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailAlertHandler 创建邮件告警处理器，用户名为空时不做SMTP认证
+func NewEmailAlertHandler(host string, port int, username, password, from string, to []string) *EmailAlertHandler {
+	return &EmailAlertHandler{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+		To:       to,
+		sendMail: smtp.SendMail,
+	}
+}
+
+func (h *EmailAlertHandler) Handle(ctx context.Context, alert *Alert) error {
+	return h.HandleGroup(ctx, singleAlertGroup(alert))
+}
+
+// HandleGroup 以一封邮件发送整组告警
+func (h *EmailAlertHandler) HandleGroup(_ context.Context, group *AlertGroup) error {
+	subject := fmt.Sprintf("[%s] courier-service %d 条告警", group.Status(), len(group.Alerts))
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", h.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(h.To, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(formatAlertGroupText(group), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if h.Username != "" {
+		auth = smtp.PlainAuth("", h.Username, h.Password, h.Host)
+	}
+	send := h.sendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(fmt.Sprintf("%s:%d", h.Host, h.Port), auth, h.From, h.To, msg.Bytes())
+}
+
+func (h *EmailAlertHandler) CanHandle(alert *Alert) bool {
+	return h.Host != "" && len(h.To) > 0 && levelAtLeast(alert.Level, h.MinLevel)
+}
+
+func (h *EmailAlertHandler) Priority() int {
+	return 600
+}
+
+// formatAlertGroupText 生成告警组的纯文本描述，每条告警一行
+func formatAlertGroupText(group *AlertGroup) string {
+	lines := make([]string, 0, len(group.Alerts))
+	for _, alert := range group.Alerts {
+		line := fmt.Sprintf("%s %s %s（开始于 %s，触发 %d 次）", alert.Status, alert.ID, alert.Message,
+			alert.StartTime.Format("2006-01-02 15:04:05"), alert.Count)
+		if alert.EndTime != nil {
+			line += fmt.Sprintf("，已于 %s 恢复", alert.EndTime.Format("2006-01-02 15:04:05"))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// postAlertJSON 发送 JSON 请求并返回响应体，网络错误、429 和 5xx 时按指数退避重试
+func postAlertJSON(ctx context.Context, client *http.Client, targetURL string, body []byte, headers map[string]string, maxRetries int) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: defaultAlertTimeout}
+	}
+
+	delay := defaultAlertRetryDelay
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return respBody, nil
+		}
+		lastErr = fmt.Errorf("alert webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, lastErr
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,244 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSilenceNoMatchers  = errors.New("静默规则至少需要一个标签匹配条件")
+	ErrSilenceInvalidTime = errors.New("静默结束时间必须晚于开始时间和当前时间")
+	ErrSilenceNotFound    = errors.New("静默规则不存在")
+)
+
+// AlertGroup 按分组标签合并发送的一组告警
+type AlertGroup struct {
+	Key    string            `json:"key"`
+	Labels map[string]string `json:"labels"`
+	Alerts []*Alert          `json:"alerts"`
+}
+
+// Status 组内有任一告警在触发时为 FIRING，否则为 RESOLVED
+func (g *AlertGroup) Status() AlertStatus {
+	for _, alert := range g.Alerts {
+		if alert.Status == AlertStatusFiring {
+			return AlertStatusFiring
+		}
+	}
+	return AlertStatusResolved
+}
+
+// singleAlertGroup 将单条告警包装为告警组
+func singleAlertGroup(alert *Alert) *AlertGroup {
+	return &AlertGroup{
+		Key:    alert.ID,
+		Labels: alertLabels(alert),
+		Alerts: []*Alert{alert},
+	}
+}
+
+// GroupAlertHandler 支持按组发送的告警处理器，未实现时逐条调用 Handle
+type GroupAlertHandler interface {
+	AlertHandler
+	HandleGroup(ctx context.Context, group *AlertGroup) error
+}
+
+// alertLabels 告警标签，附加 alertname 和 level 供分组与静默匹配
+func alertLabels(alert *Alert) map[string]string {
+	labels := make(map[string]string, len(alert.Labels)+2)
+	for key, value := range alert.Labels {
+		labels[key] = value
+	}
+	labels["alertname"] = alert.ID
+	labels["level"] = string(alert.Level)
+	return labels
+}
+
+// groupKeyFor 按分组标签计算分组键
+func groupKeyFor(labels map[string]string, groupBy []string) (string, map[string]string) {
+	keys := append([]string(nil), groupBy...)
+	sort.Strings(keys)
+
+	groupLabels := make(map[string]string, len(keys))
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		groupLabels[key] = labels[key]
+		parts = append(parts, key+"="+labels[key])
+	}
+	return strings.Join(parts, ","), groupLabels
+}
+
+// AlertHistoryStore 告警历史存储
+type AlertHistoryStore interface {
+	Record(alert *Alert)
+	List(limit int) []*Alert
+}
+
+// MemoryAlertHistory 内存环形缓冲的告警历史，超出容量时丢弃最早的记录
+type MemoryAlertHistory struct {
+	mu       sync.RWMutex
+	entries  []*Alert
+	next     int
+	full     bool
+	capacity int
+}
+
+// NewMemoryAlertHistory 创建内存告警历史
+func NewMemoryAlertHistory(capacity int) *MemoryAlertHistory {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryAlertHistory{
+		entries:  make([]*Alert, capacity),
+		capacity: capacity,
+	}
+}
+
+// Record 记录一次告警通知
+func (h *MemoryAlertHistory) Record(alert *Alert) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = alert
+	h.next = (h.next + 1) % h.capacity
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// List 按时间倒序返回最近的 limit 条记录
+func (h *MemoryAlertHistory) List(limit int) []*Alert {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	size := h.next
+	if h.full {
+		size = h.capacity
+	}
+	if limit <= 0 || limit > size {
+		limit = size
+	}
+
+	result := make([]*Alert, 0, limit)
+	for i := 1; i <= limit; i++ {
+		result = append(result, h.entries[(h.next-i+h.capacity)%h.capacity])
+	}
+	return result
+}
+
+// Silence 告警静默规则，标签全部匹配的告警在有效期内不发送通知
+type Silence struct {
+	ID        string            `json:"id"`
+	Matchers  map[string]string `json:"matchers"` // 可匹配 alertname、level 和规则标签
+	Comment   string            `json:"comment"`
+	CreatedBy string            `json:"created_by"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// IsActive 静默规则在指定时间是否生效
+func (s *Silence) IsActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Matches 告警标签是否满足全部匹配条件
+func (s *Silence) Matches(labels map[string]string) bool {
+	for key, value := range s.Matchers {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// silenceRetention 过期静默规则的保留时间
+const silenceRetention = 24 * time.Hour
+
+// AddSilence 新增静默规则，开始时间为空时立即生效
+func (am *AlertManager) AddSilence(silence *Silence) (*Silence, error) {
+	now := time.Now()
+	if len(silence.Matchers) == 0 {
+		return nil, ErrSilenceNoMatchers
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		return nil, ErrSilenceInvalidTime
+	}
+
+	silence.ID = uuid.New().String()
+	silence.CreatedAt = now
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.pruneSilences(now)
+	am.silences[silence.ID] = silence
+	am.logger.Info("Alert silence created", "silence_id", silence.ID, "matchers", silence.Matchers, "ends_at", silence.EndsAt)
+	return silence, nil
+}
+
+// ExpireSilence 立即结束静默规则
+func (am *AlertManager) ExpireSilence(id string) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	silence, ok := am.silences[id]
+	if !ok {
+		return ErrSilenceNotFound
+	}
+	now := time.Now()
+	if silence.EndsAt.After(now) {
+		silence.EndsAt = now
+	}
+	return nil
+}
+
+// GetSilences 获取静默规则，includeExpired 为 false 时只返回未过期的
+func (am *AlertManager) GetSilences(includeExpired bool) []*Silence {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	now := time.Now()
+	am.pruneSilences(now)
+
+	result := make([]*Silence, 0, len(am.silences))
+	for _, silence := range am.silences {
+		if includeExpired || silence.EndsAt.After(now) {
+			copied := *silence
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// isSilenced 告警是否被静默，调用方需持有 am.mutex
+func (am *AlertManager) isSilenced(alert *Alert, now time.Time) bool {
+	labels := alertLabels(alert)
+	for _, silence := range am.silences {
+		if silence.IsActive(now) && silence.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSilences 清理过期较久的静默规则，调用方需持有 am.mutex
+func (am *AlertManager) pruneSilences(now time.Time) {
+	for id, silence := range am.silences {
+		if now.Sub(silence.EndsAt) > silenceRetention {
+			delete(am.silences, id)
+		}
+	}
+}
@@ -96,37 +96,36 @@ func (h *LogAlertHandler) Priority() int {
 	return 1000 // 低优先级
 }
 
-// WebhookAlertHandler Webhook告警处理器
-type WebhookAlertHandler struct {
-	URL    string
-	client interface{} // HTTP客户端接口
-}
-
-func (h *WebhookAlertHandler) Handle(_ context.Context, _ *Alert) error {
-	// 这里实现Webhook调用逻辑
-	// 发送HTTP POST请求到指定URL
-	return nil
-}
-
-func (h *WebhookAlertHandler) CanHandle(_ *Alert) bool {
-	return h.URL != ""
-}
+// 告警分组默认参数
+const (
+	defaultAlertGroupWait    = 30 * time.Second
+	defaultAlertHistoryLimit = 1000
+)
 
-func (h *WebhookAlertHandler) Priority() int {
-	return 500 // 中等优先级
+// alertNotification 最近一次发出的通知，用于去重
+type alertNotification struct {
+	status AlertStatus
+	at     time.Time
 }
 
 // AlertManager 告警管理器
 type AlertManager struct {
-	rules        map[string]*AlertRule
-	activeAlerts map[string]*Alert
-	handlers     []AlertHandler
-	registry     *MetricsRegistry
-	logger       logging.Logger
-	mutex        sync.RWMutex
-	ticker       *time.Ticker
-	ctx          context.Context
-	cancel       context.CancelFunc
+	rules         map[string]*AlertRule
+	activeAlerts  map[string]*Alert
+	handlers      []AlertHandler
+	registry      *MetricsRegistry
+	logger        logging.Logger
+	mutex         sync.RWMutex
+	ticker        *time.Ticker
+	ctx           context.Context
+	cancel        context.CancelFunc
+	history       AlertHistoryStore
+	silences      map[string]*Silence
+	notified      map[string]alertNotification
+	groupBy       []string
+	groupWait     time.Duration
+	pendingGroups map[string]*AlertGroup
+	groupMutex    sync.Mutex
 }
 
 // NewAlertManager 创建告警管理器
@@ -138,13 +137,19 @@ func NewAlertManager(registry *MetricsRegistry, logger logging.Logger) *AlertMan
 	ctx, cancel := context.WithCancel(context.Background())
 
 	am := &AlertManager{
-		rules:        make(map[string]*AlertRule),
-		activeAlerts: make(map[string]*Alert),
-		handlers:     make([]AlertHandler, 0),
-		registry:     registry,
-		logger:       logger,
-		ctx:          ctx,
-		cancel:       cancel,
+		rules:         make(map[string]*AlertRule),
+		activeAlerts:  make(map[string]*Alert),
+		handlers:      make([]AlertHandler, 0),
+		registry:      registry,
+		logger:        logger,
+		ctx:           ctx,
+		cancel:        cancel,
+		history:       NewMemoryAlertHistory(defaultAlertHistoryLimit),
+		silences:      make(map[string]*Silence),
+		notified:      make(map[string]alertNotification),
+		groupBy:       []string{"level"},
+		groupWait:     defaultAlertGroupWait,
+		pendingGroups: make(map[string]*AlertGroup),
 	}
 
 	// 注册默认处理器
@@ -177,6 +182,23 @@ func (am *AlertManager) RegisterHandler(handler AlertHandler) {
 	}
 }
 
+// SetGrouping 设置告警分组：相同分组标签的告警在 wait 时间内合并为一条通知，wait 为0时立即发送
+func (am *AlertManager) SetGrouping(groupBy []string, wait time.Duration) {
+	am.groupMutex.Lock()
+	defer am.groupMutex.Unlock()
+
+	am.groupBy = groupBy
+	am.groupWait = wait
+}
+
+// SetHistoryStore 替换告警历史存储
+func (am *AlertManager) SetHistoryStore(store AlertHistoryStore) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.history = store
+}
+
 // Start 启动告警管理器
 func (am *AlertManager) Start(interval time.Duration) {
 	am.mutex.Lock()
@@ -254,10 +276,12 @@ func (am *AlertManager) evaluateRule(rule *AlertRule) {
 		alert.LastUpdate = now
 		alert.Duration = now.Sub(alert.StartTime)
 
-		// 如果状态是PENDING且超过了持续时间，转为FIRING
+		// 如果状态是PENDING且超过了持续时间，转为FIRING；持续触发的告警每个冷却周期重复通知一次
 		if alert.Status == AlertStatusPending && alert.Duration >= rule.Duration {
 			alert.Status = AlertStatusFiring
 			am.fireAlert(alert)
+		} else if alert.Status == AlertStatusFiring {
+			am.fireAlert(alert)
 		}
 	} else {
 		// 创建新告警
@@ -302,16 +326,26 @@ func (am *AlertManager) resolveAlert(ruleNme string) {
 			"count", alert.Count,
 		)
 
-		// 通知处理器
-		go am.notifyHandlers(alert)
+		// 只有发出过触发通知的告警才发送恢复通知
+		if last, ok := am.notified[alert.ID]; ok && last.status == AlertStatusFiring {
+			am.notify(alert, now)
+		}
 
 		// 从活跃告警中移除
 		delete(am.activeAlerts, ruleNme)
 	}
 }
 
-// fireAlert 触发告警
+// fireAlert 触发告警，调用方需持有 am.mutex
+// 同一告警在冷却时间内不重复通知，未配置冷却时间的只通知一次
 func (am *AlertManager) fireAlert(alert *Alert) {
+	now := time.Now()
+	if last, ok := am.notified[alert.ID]; ok && last.status == AlertStatusFiring {
+		if alert.Rule == nil || alert.Rule.Cooldown <= 0 || now.Sub(last.at) < alert.Rule.Cooldown {
+			return
+		}
+	}
+
 	am.logger.Warn("Alert fired",
 		"alert_id", alert.ID,
 		"level", alert.Level,
@@ -319,25 +353,95 @@ func (am *AlertManager) fireAlert(alert *Alert) {
 		"count", alert.Count,
 	)
 
-	// 异步通知处理器
-	go am.notifyHandlers(alert)
+	am.notify(alert, now)
+}
+
+// notify 记录历史并将告警放入分组等待发送，被静默的告警只记录历史，调用方需持有 am.mutex
+func (am *AlertManager) notify(alert *Alert, now time.Time) {
+	snapshot := *alert
+	am.notified[alert.ID] = alertNotification{status: alert.Status, at: now}
+
+	if am.isSilenced(alert, now) {
+		snapshot.Status = AlertStatusSuppressed
+		am.history.Record(&snapshot)
+		am.logger.Info("Alert notification silenced", "alert_id", alert.ID, "status", alert.Status)
+		return
+	}
+
+	am.history.Record(&snapshot)
+
+	am.groupMutex.Lock()
+	defer am.groupMutex.Unlock()
+
+	key, groupLabels := groupKeyFor(alertLabels(&snapshot), am.groupBy)
+	group, exists := am.pendingGroups[key]
+	if !exists {
+		group = &AlertGroup{Key: key, Labels: groupLabels}
+		am.pendingGroups[key] = group
+		if am.groupWait > 0 {
+			time.AfterFunc(am.groupWait, func() { am.flushGroup(key) })
+		} else {
+			go am.flushGroup(key)
+		}
+	}
+
+	// 同一告警在组内只保留最新状态
+	for i, pending := range group.Alerts {
+		if pending.ID == snapshot.ID {
+			group.Alerts[i] = &snapshot
+			return
+		}
+	}
+	group.Alerts = append(group.Alerts, &snapshot)
 }
 
-// notifyHandlers 通知处理器
-func (am *AlertManager) notifyHandlers(alert *Alert) {
-	for _, handler := range am.handlers {
-		if handler.CanHandle(alert) {
-			if err := handler.Handle(am.ctx, alert); err != nil {
-				am.logger.Error("Alert handler failed",
-					"alert_id", alert.ID,
-					"handler", fmt.Sprintf("%T", handler),
-					"error", err,
-				)
+// flushGroup 发送分组内的告警
+func (am *AlertManager) flushGroup(key string) {
+	am.groupMutex.Lock()
+	group, exists := am.pendingGroups[key]
+	delete(am.pendingGroups, key)
+	am.groupMutex.Unlock()
+	if !exists || len(group.Alerts) == 0 {
+		return
+	}
+
+	am.mutex.RLock()
+	handlers := append([]AlertHandler(nil), am.handlers...)
+	am.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		if groupHandler, ok := handler.(GroupAlertHandler); ok {
+			subset := &AlertGroup{Key: group.Key, Labels: group.Labels}
+			for _, alert := range group.Alerts {
+				if handler.CanHandle(alert) {
+					subset.Alerts = append(subset.Alerts, alert)
+				}
+			}
+			if len(subset.Alerts) > 0 {
+				am.handleResult(handler, group.Key, groupHandler.HandleGroup(am.ctx, subset))
+			}
+			continue
+		}
+
+		for _, alert := range group.Alerts {
+			if handler.CanHandle(alert) {
+				am.handleResult(handler, alert.ID, handler.Handle(am.ctx, alert))
 			}
 		}
 	}
 }
 
+// handleResult 记录处理器发送失败
+func (am *AlertManager) handleResult(handler AlertHandler, key string, err error) {
+	if err != nil {
+		am.logger.Error("Alert handler failed",
+			"alert_key", key,
+			"handler", fmt.Sprintf("%T", handler),
+			"error", err,
+		)
+	}
+}
+
 // generateAlertMessage 生成告警消息
 func (am *AlertManager) generateAlertMessage(rule *AlertRule) string {
 	return fmt.Sprintf("[%s] %s", rule.Level, rule.Description)
@@ -356,10 +460,13 @@ func (am *AlertManager) GetActiveAlerts() map[string]*Alert {
 	return result
 }
 
-// GetAlertHistory 获取告警历史（这里简化实现）
-func (am *AlertManager) GetAlertHistory(_ int) []*Alert {
-	// 在实际实现中，应该从持久化存储中获取历史告警
-	return []*Alert{}
+// GetAlertHistory 获取最近的告警通知记录，按时间倒序
+func (am *AlertManager) GetAlertHistory(limit int) []*Alert {
+	am.mutex.RLock()
+	history := am.history
+	am.mutex.RUnlock()
+
+	return history.List(limit)
 }
 
 // 预定义告警规则
@@ -4,6 +4,7 @@ import (
 	"courier-service/internal/models"
 	"courier-service/internal/utils"
 	"fmt"
+	"log"
 	"sort"
 	"time"
 
@@ -12,17 +13,19 @@ import (
 
 // AssignmentService 任务分配服务
 type AssignmentService struct {
-	db              *gorm.DB
-	locationService *LocationService
-	wsManager       *utils.WebSocketManager
+	db                  *gorm.DB
+	locationService     *LocationService
+	availabilityService *AvailabilityService
+	wsManager           *utils.WebSocketManager
 }
 
 // NewAssignmentService 创建任务分配服务实例
-func NewAssignmentService(db *gorm.DB, locationService *LocationService, wsManager *utils.WebSocketManager) *AssignmentService {
+func NewAssignmentService(db *gorm.DB, locationService *LocationService, availabilityService *AvailabilityService, wsManager *utils.WebSocketManager) *AssignmentService {
 	return &AssignmentService{
-		db:              db,
-		locationService: locationService,
-		wsManager:       wsManager,
+		db:                  db,
+		locationService:     locationService,
+		availabilityService: availabilityService,
+		wsManager:           wsManager,
 	}
 }
 
@@ -48,27 +51,36 @@ func (s *AssignmentService) AutoAssignTask(task *models.Task) (*models.Courier,
 		return nil, gorm.ErrRecordNotFound
 	}
 
-	// 3. 计算信使评分并排序（考虑层级优先级）
-	courierScores := s.calculateCourierScoresWithHierarchy(nearbyCouries, task, pickupLat, pickupLng)
+	// 3. 只保留在岗、在排班时段内、未请假且有余量的信使；都不可用时记录预计取件时间
+	now := time.Now()
+	availableCouriers := s.availabilityService.FilterAvailable(nearbyCouries, now)
+	if len(availableCouriers) == 0 {
+		forecast := s.forecastFromCandidates(task, nearbyCouries, pickupLat, pickupLng, now)
+		s.db.Model(&models.Task{}).Where("task_id = ?", task.TaskID).Update("expected_pickup_at", forecast.ExpectedPickupAt)
+		return nil, ErrNoAvailableCourier
+	}
+
+	// 4. 计算信使评分并排序（考虑层级优先级）
+	courierScores := s.calculateCourierScoresWithHierarchy(availableCouriers, task, pickupLat, pickupLng)
 	if len(courierScores) == 0 {
 		return nil, gorm.ErrRecordNotFound
 	}
 
-	// 4. 选择最优信使
+	// 5. 选择最优信使
 	bestCourier := courierScores[0].Courier
 
-	// 5. 检查层级权限和区域管辖
+	// 6. 检查层级权限和区域管辖
 	if !s.validateAssignmentPermission(&bestCourier, task) {
 		return nil, fmt.Errorf("信使无权限处理该区域任务")
 	}
 
-	// 6. 更新任务分配信息
+	// 7. 更新任务分配信息
 	err = s.assignTaskToCourier(task, &bestCourier)
 	if err != nil {
 		return nil, err
 	}
 
-	// 7. 发送通知
+	// 8. 发送通知
 	s.notifyTaskAssignment(task, &bestCourier)
 
 	return &bestCourier, nil
@@ -257,25 +269,29 @@ func (s *AssignmentService) calculateIndividualScore(courier *models.Courier, ta
 
 // getCurrentTaskCount 获取信使当前的任务数量
 func (s *AssignmentService) getCurrentTaskCount(courierID string) int {
-	var count int64
-	s.db.Model(&models.Task{}).Where("courier_id = ? AND status IN ?", courierID,
-		[]string{models.TaskStatusAccepted, models.TaskStatusCollected, models.TaskStatusInTransit}).Count(&count)
-	return int(count)
+	return countActiveTasks(s.db, courierID)
 }
 
 // assignTaskToCourier 将任务分配给信使
 func (s *AssignmentService) assignTaskToCourier(task *models.Task, courier *models.Courier) error {
 	now := time.Now()
 	deadline := now.Add(4 * time.Hour) // 4小时截止
+	expectedPickupAt := now.Add(s.travelTime(courier, task))
 
 	updates := map[string]interface{}{
-		"courier_id":  &courier.UserID,
-		"status":      models.TaskStatusAccepted,
-		"accepted_at": &now,
-		"deadline":    &deadline,
+		"courier_id":         &courier.UserID,
+		"status":             models.TaskStatusAccepted,
+		"accepted_at":        &now,
+		"deadline":           &deadline,
+		"expected_pickup_at": &expectedPickupAt,
 	}
 
-	return s.db.Model(task).Where("task_id = ?", task.TaskID).Updates(updates).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(task).Where("task_id = ?", task.TaskID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, task.TaskID, "")
+	})
 }
 
 // notifyTaskAssignment 通知任务分配
@@ -318,8 +334,9 @@ func (s *AssignmentService) FindOptimalCouriersForTask(taskID string, limit int)
 		return nil, err
 	}
 
-	// 查找附近信使
+	// 查找附近信使，只考虑当前可接单的
 	nearbyCouries := s.findNearbyActiveCouriers(pickupLat, pickupLng, 15.0) // 15km范围
+	nearbyCouries = s.availabilityService.FilterAvailable(nearbyCouries, time.Now())
 
 	// 计算评分
 	courierScores := s.calculateCourierScores(nearbyCouries, pickupLat, pickupLng)
@@ -332,6 +349,90 @@ func (s *AssignmentService) FindOptimalCouriersForTask(taskID string, limit int)
 	return courierScores, nil
 }
 
+// ForecastPickup 预测任务的取件时间并写回任务
+// 已接取的任务按负责信使的排班推算，待分配的任务取候选信使中最早可接单的
+func (s *AssignmentService) ForecastPickup(taskID string) (*models.PickupForecast, error) {
+	var task models.Task
+	if err := s.db.Where("task_id = ?", taskID).First(&task).Error; err != nil {
+		return nil, err
+	}
+
+	pickupLat, pickupLng, err := s.locationService.ParseLocation(task.PickupLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var forecast *models.PickupForecast
+	switch {
+	case task.Status == models.TaskStatusAvailable:
+		candidates := s.findCouriersByHierarchy(&task, pickupLat, pickupLng, 10.0)
+		forecast = s.forecastFromCandidates(&task, candidates, pickupLat, pickupLng, now)
+	case task.Status == models.TaskStatusAccepted && task.CourierID != nil:
+		forecast = &models.PickupForecast{TaskID: task.TaskID, CourierID: task.CourierID}
+		var courier models.Courier
+		if err := s.db.Where("user_id = ?", *task.CourierID).First(&courier).Error; err != nil {
+			return nil, err
+		}
+		// 已接取的任务不占用新的余量，只看排班与请假
+		if start := s.availabilityService.NextOnShiftAt(courier.UserID, now); start != nil {
+			expected := start.Add(s.travelTime(&courier, &task))
+			forecast.ExpectedPickupAt = &expected
+		}
+	default:
+		// 已收取或已结束的任务不再预测
+		return &models.PickupForecast{TaskID: task.TaskID, CourierID: task.CourierID}, nil
+	}
+
+	if err := s.db.Model(&models.Task{}).Where("task_id = ?", task.TaskID).
+		Update("expected_pickup_at", forecast.ExpectedPickupAt).Error; err != nil {
+		return nil, err
+	}
+	return forecast, nil
+}
+
+// forecastFromCandidates 候选信使中最早可接单时间加上路程时间
+func (s *AssignmentService) forecastFromCandidates(task *models.Task, candidates []models.Courier, pickupLat, pickupLng float64, now time.Time) *models.PickupForecast {
+	forecast := &models.PickupForecast{
+		TaskID:            task.TaskID,
+		AvailableCouriers: len(s.availabilityService.FilterAvailable(candidates, now)),
+	}
+
+	for i := range candidates {
+		start := s.availabilityService.NextAvailableAt(candidates[i].UserID, now)
+		if start == nil {
+			continue
+		}
+		expected := start.Add(s.travelTimeFrom(&candidates[i], pickupLat, pickupLng))
+		if forecast.ExpectedPickupAt == nil || expected.Before(*forecast.ExpectedPickupAt) {
+			userID := candidates[i].UserID
+			forecast.ExpectedPickupAt = &expected
+			forecast.CourierID = &userID
+		}
+	}
+
+	return forecast
+}
+
+// travelTime 信使从服务区域到取件地点的路程时间
+func (s *AssignmentService) travelTime(courier *models.Courier, task *models.Task) time.Duration {
+	pickupLat, pickupLng, err := s.locationService.ParseLocation(task.PickupLocation)
+	if err != nil {
+		return 0
+	}
+	return s.travelTimeFrom(courier, pickupLat, pickupLng)
+}
+
+// travelTimeFrom 按路线规划的默认速度估算路程时间
+func (s *AssignmentService) travelTimeFrom(courier *models.Courier, pickupLat, pickupLng float64) time.Duration {
+	courierLat, courierLng, err := s.locationService.ParseLocation(courier.Zone)
+	if err != nil {
+		return 0
+	}
+	km := s.locationService.CalculateDistance(courierLat, courierLng, pickupLat, pickupLng)
+	return time.Duration(km / defaultRouteSpeedKmh * float64(time.Hour))
+}
+
 // BatchAssignTasks 批量自动分配任务
 func (s *AssignmentService) BatchAssignTasks(maxTasks int) (int, error) {
 	// 获取待分配的任务
@@ -373,12 +474,21 @@ func (s *AssignmentService) ReassignFailedTasks() error {
 	// 重置任务状态并重新分配
 	for _, task := range timeoutTasks {
 		// 重置任务
-		s.db.Model(&task).Updates(map[string]interface{}{
-			"courier_id":  nil,
-			"status":      models.TaskStatusAvailable,
-			"accepted_at": nil,
-			"deadline":    nil,
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&task).Updates(map[string]interface{}{
+				"courier_id":  nil,
+				"status":      models.TaskStatusAvailable,
+				"accepted_at": nil,
+				"deadline":    nil,
+			}).Error; err != nil {
+				return err
+			}
+			return recordTaskSyncEvent(tx, task.TaskID, "超时重新分配")
 		})
+		if err != nil {
+			log.Printf("Failed to reset timeout task %s: %v", task.TaskID, err)
+			continue
+		}
 
 		// 尝试重新分配
 		s.AutoAssignTask(&task)
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAvailabilityCourierNotFound = errors.New("信使不存在")
+	ErrInvalidAvailabilityWindow   = errors.New("可接单时段无效")
+	ErrInvalidTimeOff              = errors.New("不可用时段的结束时间必须晚于开始时间")
+	ErrTimeOffNotFound             = errors.New("不可用时段不存在")
+	ErrCourierAtCapacity           = errors.New("信使进行中的任务已达上限")
+	ErrNoAvailableCourier          = errors.New("当前没有在岗且有余量的信使")
+)
+
+// availabilityHorizon 预测下次可用时间时向后查找的范围
+const availabilityHorizon = 14 * 24 * time.Hour
+
+// AvailabilityService 信使排班与在岗状态服务
+type AvailabilityService struct {
+	db        *gorm.DB
+	wsManager *utils.WebSocketManager
+}
+
+// NewAvailabilityService 创建信使排班服务
+func NewAvailabilityService(db *gorm.DB, wsManager *utils.WebSocketManager) *AvailabilityService {
+	return &AvailabilityService{
+		db:        db,
+		wsManager: wsManager,
+	}
+}
+
+// GetAvailability 获取信使的排班、请假、在岗状态和当前余量
+func (s *AvailabilityService) GetAvailability(userID string) (*models.CourierAvailability, error) {
+	now := time.Now()
+	snapshot, err := s.loadSnapshot(userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CourierAvailability{
+		CourierID:       userID,
+		OnDuty:          snapshot.duty.OnDuty,
+		MaxActiveTasks:  snapshot.duty.MaxActiveTasks,
+		ActiveTasks:     snapshot.activeTasks,
+		AvailableNow:    snapshot.availableAt(now) && snapshot.hasCapacity(),
+		NextAvailableAt: snapshot.nextAvailableAt(now),
+		Windows:         snapshot.windows,
+		TimeOff:         snapshot.timeOff,
+	}, nil
+}
+
+// SetWeeklySchedule 整体替换信使的每周可接单时段
+func (s *AvailabilityService) SetWeeklySchedule(userID string, req *models.WeeklyScheduleRequest) ([]models.CourierAvailabilityWindow, error) {
+	if err := s.requireCourier(userID); err != nil {
+		return nil, err
+	}
+
+	windows := make([]models.CourierAvailabilityWindow, 0, len(req.Windows))
+	for _, input := range req.Windows {
+		start, err := parseClockMinute(input.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClockMinute(input.End)
+		if err != nil {
+			return nil, err
+		}
+		if start >= end {
+			return nil, fmt.Errorf("%w: %s-%s", ErrInvalidAvailabilityWindow, input.Start, input.End)
+		}
+		windows = append(windows, models.CourierAvailabilityWindow{
+			ID:          uuid.New().String(),
+			CourierID:   userID,
+			Weekday:     input.Weekday,
+			StartMinute: start,
+			EndMinute:   end,
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("courier_id = ?", userID).Delete(&models.CourierAvailabilityWindow{}).Error; err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// AddTimeOff 登记请假、考试等不可用时段
+func (s *AvailabilityService) AddTimeOff(userID string, req *models.TimeOffRequest) (*models.CourierTimeOff, error) {
+	if err := s.requireCourier(userID); err != nil {
+		return nil, err
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return nil, ErrInvalidTimeOff
+	}
+
+	timeOff := &models.CourierTimeOff{
+		ID:        uuid.New().String(),
+		CourierID: userID,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		Reason:    req.Reason,
+	}
+	if err := s.db.Create(timeOff).Error; err != nil {
+		return nil, err
+	}
+
+	return timeOff, nil
+}
+
+// DeleteTimeOff 取消不可用时段
+func (s *AvailabilityService) DeleteTimeOff(userID, timeOffID string) error {
+	result := s.db.Where("id = ? AND courier_id = ?", timeOffID, userID).Delete(&models.CourierTimeOff{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTimeOffNotFound
+	}
+	return nil
+}
+
+// SetDuty 切换在岗状态
+func (s *AvailabilityService) SetDuty(userID string, onDuty bool) (*models.CourierDutyStatus, error) {
+	if err := s.requireCourier(userID); err != nil {
+		return nil, err
+	}
+
+	duty, err := s.getDutyStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	duty.OnDuty = onDuty
+	duty.DutyChangedAt = &now
+	if err := s.db.Save(duty).Error; err != nil {
+		return nil, err
+	}
+
+	s.wsManager.BroadcastToAdmins(utils.WebSocketEvent{
+		Type: "COURIER_DUTY_CHANGED",
+		Data: map[string]interface{}{
+			"courier_id": userID,
+			"on_duty":    onDuty,
+		},
+		Timestamp: now,
+	})
+
+	return duty, nil
+}
+
+// SetCapacity 设置信使同时进行中的任务上限
+func (s *AvailabilityService) SetCapacity(userID string, maxActiveTasks int) (*models.CourierDutyStatus, error) {
+	if err := s.requireCourier(userID); err != nil {
+		return nil, err
+	}
+
+	duty, err := s.getDutyStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+	duty.MaxActiveTasks = maxActiveTasks
+	if err := s.db.Save(duty).Error; err != nil {
+		return nil, err
+	}
+
+	return duty, nil
+}
+
+// FilterAvailable 筛选指定时刻在岗、在排班时段内、未请假且有余量的信使
+func (s *AvailabilityService) FilterAvailable(couriers []models.Courier, at time.Time) []models.Courier {
+	available := make([]models.Courier, 0, len(couriers))
+	for _, courier := range couriers {
+		snapshot, err := s.loadSnapshot(courier.UserID, at)
+		if err != nil {
+			continue
+		}
+		if snapshot.availableAt(at) && snapshot.hasCapacity() {
+			available = append(available, courier)
+		}
+	}
+	return available
+}
+
+// NextAvailableAt 信使下次可接新任务的时间；下线、无余量或两周内无排班时返回 nil
+func (s *AvailabilityService) NextAvailableAt(userID string, from time.Time) *time.Time {
+	snapshot, err := s.loadSnapshot(userID, from)
+	if err != nil || !snapshot.hasCapacity() {
+		return nil
+	}
+	return snapshot.nextAvailableAt(from)
+}
+
+// NextOnShiftAt 信使下次在岗的时间，不考虑余量，用于预测已接任务的取件
+func (s *AvailabilityService) NextOnShiftAt(userID string, from time.Time) *time.Time {
+	snapshot, err := s.loadSnapshot(userID, from)
+	if err != nil {
+		return nil
+	}
+	return snapshot.nextAvailableAt(from)
+}
+
+// 私有方法
+
+// courierAvailabilitySnapshot 单个信使的可用性数据
+type courierAvailabilitySnapshot struct {
+	duty        *models.CourierDutyStatus
+	windows     []models.CourierAvailabilityWindow
+	timeOff     []models.CourierTimeOff
+	activeTasks int
+}
+
+// loadSnapshot 加载信使排班、尚未结束的请假和当前任务数
+func (s *AvailabilityService) loadSnapshot(userID string, from time.Time) (*courierAvailabilitySnapshot, error) {
+	duty, err := s.getDutyStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &courierAvailabilitySnapshot{
+		duty:        duty,
+		activeTasks: countActiveTasks(s.db, userID),
+	}
+	if err := s.db.Where("courier_id = ?", userID).
+		Order("weekday ASC, start_minute ASC").
+		Find(&snapshot.windows).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("courier_id = ? AND end_at > ?", userID, from).
+		Order("start_at ASC").
+		Find(&snapshot.timeOff).Error; err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// getDutyStatus 获取在岗状态
+func (s *AvailabilityService) getDutyStatus(userID string) (*models.CourierDutyStatus, error) {
+	return loadDutyStatus(s.db, userID)
+}
+
+// loadDutyStatus 获取在岗状态，未设置过时返回默认值（在岗、默认容量）
+func loadDutyStatus(db *gorm.DB, userID string) (*models.CourierDutyStatus, error) {
+	var duty models.CourierDutyStatus
+	if err := db.Where("courier_id = ?", userID).First(&duty).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &models.CourierDutyStatus{
+				CourierID:      userID,
+				OnDuty:         true,
+				MaxActiveTasks: models.DefaultCourierMaxActiveTasks,
+			}, nil
+		}
+		return nil, err
+	}
+	return &duty, nil
+}
+
+// requireCourier 检查用户是否为信使
+func (s *AvailabilityService) requireCourier(userID string) error {
+	var count int64
+	if err := s.db.Model(&models.Courier{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrAvailabilityCourierNotFound
+	}
+	return nil
+}
+
+// hasCapacity 是否还有接单余量
+func (a *courierAvailabilitySnapshot) hasCapacity() bool {
+	return a.activeTasks < a.duty.MaxActiveTasks
+}
+
+// availableAt 指定时刻是否在岗、在排班时段内且未请假
+func (a *courierAvailabilitySnapshot) availableAt(t time.Time) bool {
+	return a.duty.OnDuty && a.inSchedule(t) && a.timeOffAt(t) == nil
+}
+
+// inSchedule 未设置排班的信使视为全天可接单
+func (a *courierAvailabilitySnapshot) inSchedule(t time.Time) bool {
+	if len(a.windows) == 0 {
+		return true
+	}
+	for i := range a.windows {
+		if a.windows[i].Covers(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// timeOffAt 返回覆盖该时刻的请假记录
+func (a *courierAvailabilitySnapshot) timeOffAt(t time.Time) *models.CourierTimeOff {
+	for i := range a.timeOff {
+		if !t.Before(a.timeOff[i].StartAt) && t.Before(a.timeOff[i].EndAt) {
+			return &a.timeOff[i]
+		}
+	}
+	return nil
+}
+
+// nextWindowStart 返回 t 之后最近的排班时段开始时间
+func (a *courierAvailabilitySnapshot) nextWindowStart(t time.Time) *time.Time {
+	var next *time.Time
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for day := 0; day <= 7; day++ {
+		date := midnight.AddDate(0, 0, day)
+		for i := range a.windows {
+			if a.windows[i].Weekday != int(date.Weekday()) {
+				continue
+			}
+			start := date.Add(time.Duration(a.windows[i].StartMinute) * time.Minute)
+			if start.After(t) && (next == nil || start.Before(*next)) {
+				next = &start
+			}
+		}
+		if next != nil {
+			return next
+		}
+	}
+	return nil
+}
+
+// nextAvailableAt 从 from 起跳过请假和排班空档，找到第一个可接单时刻
+func (a *courierAvailabilitySnapshot) nextAvailableAt(from time.Time) *time.Time {
+	if !a.duty.OnDuty {
+		return nil
+	}
+
+	t := from
+	horizon := from.Add(availabilityHorizon)
+	for t.Before(horizon) {
+		if off := a.timeOffAt(t); off != nil {
+			t = off.EndAt
+			continue
+		}
+		if a.inSchedule(t) {
+			return &t
+		}
+		next := a.nextWindowStart(t)
+		if next == nil {
+			return nil
+		}
+		t = *next
+	}
+	return nil
+}
+
+// checkCourierCapacity 检查信使是否还能接新任务
+func checkCourierCapacity(db *gorm.DB, userID string) error {
+	duty, err := loadDutyStatus(db, userID)
+	if err != nil {
+		return err
+	}
+	if countActiveTasks(db, userID) >= duty.MaxActiveTasks {
+		return ErrCourierAtCapacity
+	}
+	return nil
+}
+
+// countActiveTasks 信使进行中的任务数
+func countActiveTasks(db *gorm.DB, userID string) int {
+	var count int64
+	db.Model(&models.Task{}).Where("courier_id = ? AND status IN ?", userID,
+		[]string{models.TaskStatusAccepted, models.TaskStatusCollected, models.TaskStatusInTransit}).Count(&count)
+	return int(count)
+}
+
+// parseClockMinute 将 HH:MM 解析为当天分钟数，允许 24:00 表示当天结束
+func parseClockMinute(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidAvailabilityWindow, value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
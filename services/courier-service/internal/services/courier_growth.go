@@ -462,23 +462,39 @@ func (s *CourierGrowthService) checkBadgeConditions(courierID string, badge mode
 }
 
 // ClaimIncentive 领取激励奖励
+// 发放奖励与写入领取记录在同一事务内完成，领取记录计入结算对账单
 func (s *CourierGrowthService) ClaimIncentive(courierID string, incentiveType models.IncentiveType, reference string, amount *int) (map[string]interface{}, error) {
-	switch incentiveType {
-	case models.IncentiveTypeSubsidy:
-		return s.claimSubsidy(courierID, reference)
+	var result map[string]interface{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txService := &CourierGrowthService{db: tx, redis: s.redis, wsManager: s.wsManager}
 
-	case models.IncentiveTypePoints:
-		return s.claimPoints(courierID, reference, amount)
+		var err error
+		switch incentiveType {
+		case models.IncentiveTypeSubsidy:
+			result, err = txService.claimSubsidy(courierID, reference)
 
-	case models.IncentiveTypeCommission:
-		return s.claimCommission(courierID, reference)
+		case models.IncentiveTypePoints:
+			result, err = txService.claimPoints(courierID, reference, amount)
 
-	case models.IncentiveTypeBadge:
-		return s.claimBadge(courierID, reference)
+		case models.IncentiveTypeCommission:
+			result, err = txService.claimCommission(courierID, reference)
 
-	default:
-		return nil, errors.New("不支持的激励类型")
+		case models.IncentiveTypeBadge:
+			result, err = txService.claimBadge(courierID, reference)
+
+		default:
+			return errors.New("不支持的激励类型")
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordIncentiveClaim(tx, courierID, incentiveType, reference, result)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
 }
 
 // claimSubsidy 领取投递补贴
@@ -835,6 +851,11 @@ func (s *CourierGrowthService) GetPerformanceStatistics(courierID, timeRange, st
 		avgDeliveryTime = float64(totalDeliveryTime) / float64(totalCompleted)
 	}
 
+	slaCompliance, err := calculateSLACompliance(s.db, courierID, since, until)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"time_range":          timeRange,
 		"start_date":          since.Format("2006-01-02"),
@@ -848,6 +869,7 @@ func (s *CourierGrowthService) GetPerformanceStatistics(courierID, timeRange, st
 		"total_distance":      totalDistance,
 		"total_earnings":      totalEarnings,
 		"total_points":        totalPoints,
+		"sla_compliance":      slaCompliance,
 		"daily_stats":         stats,
 	}, nil
 }
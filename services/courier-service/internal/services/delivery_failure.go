@@ -0,0 +1,449 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFailureTaskNotFound   = errors.New("任务不存在")
+	ErrFailureNotTaskCourier = errors.New("任务未分配给该信使")
+	ErrFailureInvalidState   = errors.New("任务当前状态不能上报投递失败")
+	ErrRetryPolicyNotFound   = errors.New("重投策略不存在")
+)
+
+// deliveryRetrySweepInterval 扫描到期重投任务的间隔
+const deliveryRetrySweepInterval = time.Minute
+
+// DeliveryFailureService 投递失败处理服务
+// 失败按原因对应的策略延后重投，重投次数用尽后生成退回寄件人的任务；
+// 退件任务重投用尽时不再生成新的退件，信件滞留站点由管理员处理
+type DeliveryFailureService struct {
+	db           *gorm.DB
+	queueService *QueueService
+	wsManager    *utils.WebSocketManager
+}
+
+// NewDeliveryFailureService 创建投递失败处理服务
+func NewDeliveryFailureService(db *gorm.DB, queueService *QueueService, wsManager *utils.WebSocketManager) *DeliveryFailureService {
+	return &DeliveryFailureService{
+		db:           db,
+		queueService: queueService,
+		wsManager:    wsManager,
+	}
+}
+
+// EnsureDefaultPolicies 写入缺失的默认重投策略，已有配置不覆盖
+func (s *DeliveryFailureService) EnsureDefaultPolicies() error {
+	for _, policy := range models.DefaultDeliveryRetryPolicies {
+		policy.UpdatedBy = "system"
+		if err := s.db.Where("reason = ?", policy.Reason).FirstOrCreate(&policy).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordFailure 信使上报投递失败，按策略安排重投或退回寄件人
+func (s *DeliveryFailureService) RecordFailure(courierID, letterCode string, req *models.DeliveryFailureRequest) (*models.DeliveryFailureResult, error) {
+	var result *models.DeliveryFailureResult
+	var task models.Task
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("letter_id = ? AND status <> ?", letterCode, models.TaskStatusReturned).
+			Order("created_at DESC").First(&task).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFailureTaskNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if task.IsRelay {
+			return ErrRelayScanNotAllowed
+		}
+		if task.CourierID == nil || *task.CourierID != courierID {
+			return ErrFailureNotTaskCourier
+		}
+		if !task.CanTransitionTo(models.TaskStatusFailed) {
+			return ErrFailureInvalidState
+		}
+
+		if err := tx.Create(&models.ScanRecord{
+			ID:             uuid.New().String(),
+			TaskID:         task.TaskID,
+			CourierID:      courierID,
+			LetterID:       task.LetterID,
+			Action:         models.ScanActionFailed,
+			Location:       req.Location,
+			Latitude:       req.Latitude,
+			Longitude:      req.Longitude,
+			Note:           req.Note,
+			PhotoURL:       req.PhotoURL,
+			Timestamp:      time.Now(),
+			OperatorOPCode: req.OPCode,
+		}).Error; err != nil {
+			return err
+		}
+
+		result, err = s.applyFailure(tx, &task, courierID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.afterFailure(&task, result)
+	return result, nil
+}
+
+// GetAttempts 获取任务的投递失败记录
+func (s *DeliveryFailureService) GetAttempts(taskID string) ([]models.DeliveryAttempt, error) {
+	var attempts []models.DeliveryAttempt
+	if err := s.db.Where("task_id = ?", taskID).Order("attempt_no ASC").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetPolicies 获取全部重投策略
+func (s *DeliveryFailureService) GetPolicies() ([]models.DeliveryRetryPolicy, error) {
+	var policies []models.DeliveryRetryPolicy
+	if err := s.db.Order("reason ASC").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// UpdatePolicy 更新指定失败原因的重投策略
+func (s *DeliveryFailureService) UpdatePolicy(reason, operatorID string, req *models.DeliveryRetryPolicyRequest) (*models.DeliveryRetryPolicy, error) {
+	var policy models.DeliveryRetryPolicy
+	if err := s.db.Where("reason = ?", reason).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRetryPolicyNotFound
+		}
+		return nil, err
+	}
+
+	policy.MaxAttempts = req.MaxAttempts
+	policy.RetryDelayMinutes = req.RetryDelayMinutes
+	policy.Description = req.Description
+	policy.UpdatedBy = operatorID
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// StartRetryScheduler 定时处理到期的重投任务和未登记原因的失败任务
+func (s *DeliveryFailureService) StartRetryScheduler() {
+	ticker := time.NewTicker(deliveryRetrySweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.ProcessDueRetries(); err != nil {
+			log.Printf("Failed to process delivery retries: %v", err)
+		}
+	}
+}
+
+// ProcessDueRetries 将到期的失败任务重新放回队列
+// 未经失败登记直接置为失败的任务按"其他原因"补登记
+func (s *DeliveryFailureService) ProcessDueRetries() error {
+	var unrecorded []models.Task
+	if err := s.db.Where("status = ? AND next_retry_at IS NULL AND is_relay = ?", models.TaskStatusFailed, false).
+		Find(&unrecorded).Error; err != nil {
+		return err
+	}
+	for _, task := range unrecorded {
+		if err := s.recordUnreportedFailure(task.TaskID); err != nil {
+			log.Printf("Failed to record failure for task %s: %v", task.TaskID, err)
+		}
+	}
+
+	var due []models.Task
+	if err := s.db.Where("status = ? AND next_retry_at <= ?", models.TaskStatusFailed, time.Now()).
+		Find(&due).Error; err != nil {
+		return err
+	}
+	for _, task := range due {
+		if err := s.requeue(task.TaskID); err != nil {
+			log.Printf("Failed to requeue task %s: %v", task.TaskID, err)
+		}
+	}
+
+	return nil
+}
+
+// 私有方法
+
+// applyFailure 登记失败并按策略更新任务，需在事务内调用
+func (s *DeliveryFailureService) applyFailure(tx *gorm.DB, task *models.Task, courierID string, req *models.DeliveryFailureRequest) (*models.DeliveryFailureResult, error) {
+	policy, err := s.getPolicy(tx, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	attempt := &models.DeliveryAttempt{
+		ID:        uuid.New().String(),
+		TaskID:    task.TaskID,
+		LetterID:  task.LetterID,
+		CourierID: courierID,
+		AttemptNo: task.AttemptCount + 1,
+		Reason:    req.Reason,
+		Note:      req.Note,
+		PhotoURL:  req.PhotoURL,
+		OPCode:    req.OPCode,
+		Location:  req.Location,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		FailedAt:  now,
+	}
+
+	updates := map[string]interface{}{
+		"attempt_count":  attempt.AttemptNo,
+		"failure_reason": req.Reason,
+	}
+	if req.OPCode != "" {
+		updates["current_op_code"] = req.OPCode
+	}
+
+	result := &models.DeliveryFailureResult{Attempt: attempt}
+	if attempt.AttemptNo < policy.MaxAttempts {
+		nextRetryAt := now.Add(policy.RetryDelay(attempt.AttemptNo))
+		attempt.NextAction = models.FailureActionRetry
+		attempt.NextRetryAt = &nextRetryAt
+		updates["status"] = models.TaskStatusFailed
+		updates["next_retry_at"] = &nextRetryAt
+	} else if task.ReturnForTaskID != nil {
+		// 退件本身也送不到，不能再退，流程到此结束
+		attempt.NextAction = models.FailureActionHoldAtStation
+		updates["status"] = models.TaskStatusHeld
+		updates["next_retry_at"] = nil
+	} else {
+		returnTask := s.buildReturnTask(task, courierID, req.OPCode)
+		if err := tx.Create(returnTask).Error; err != nil {
+			return nil, err
+		}
+		attempt.NextAction = models.FailureActionReturnToSender
+		attempt.ReturnTaskID = &returnTask.TaskID
+		updates["status"] = models.TaskStatusReturned
+		updates["next_retry_at"] = nil
+		result.ReturnTask = returnTask
+	}
+	result.NextAction = attempt.NextAction
+
+	if err := tx.Create(attempt).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.Task{}).Where("task_id = ?", task.TaskID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := recordTaskSyncEvent(tx, task.TaskID, req.Reason); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// buildReturnTask 生成退回寄件人的任务
+// 信件仍在失败信使手中，退件任务直接交给该信使，送往寄件人OP Code
+func (s *DeliveryFailureService) buildReturnTask(task *models.Task, courierID, opCode string) *models.Task {
+	pickupOPCode := opCode
+	if pickupOPCode == "" {
+		pickupOPCode = task.DeliveryOPCode
+	}
+	senderOPCode := task.SenderOPCode
+	if senderOPCode == "" {
+		senderOPCode = task.PickupOPCode
+	}
+
+	now := time.Now()
+	deadline := now.Add(24 * time.Hour)
+	return &models.Task{
+		ID:               uuid.New().String(),
+		TaskID:           utils.GenerateTaskID(),
+		LetterID:         task.LetterID,
+		CourierID:        &courierID,
+		PickupLocation:   task.DeliveryLocation,
+		DeliveryLocation: task.PickupLocation,
+		PickupLat:        task.DeliveryLat,
+		PickupLng:        task.DeliveryLng,
+		DeliveryLat:      task.PickupLat,
+		DeliveryLng:      task.PickupLng,
+		Status:           models.TaskStatusAccepted,
+		Priority:         models.TaskPriorityUrgent,
+		Reward:           task.Reward,
+		SpecialNote:      "退回寄件人",
+		AcceptedAt:       &now,
+		Deadline:         &deadline,
+		PickupOPCode:     pickupOPCode,
+		DeliveryOPCode:   senderOPCode,
+		CurrentOPCode:    pickupOPCode,
+		SenderID:         task.SenderID,
+		SenderOPCode:     task.SenderOPCode,
+		ReturnForTaskID:  &task.TaskID,
+	}
+}
+
+// recordUnreportedFailure 为没有失败记录的失败任务补登记
+func (s *DeliveryFailureService) recordUnreportedFailure(taskID string) error {
+	var task models.Task
+	var result *models.DeliveryFailureResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("task_id = ? AND status = ? AND next_retry_at IS NULL", taskID, models.TaskStatusFailed).
+			First(&task).Error
+		if err != nil {
+			return err
+		}
+
+		courierID := ""
+		if task.CourierID != nil {
+			courierID = *task.CourierID
+		}
+		result, err = s.applyFailure(tx, &task, courierID, &models.DeliveryFailureRequest{
+			Reason: models.FailureReasonOther,
+			OPCode: task.CurrentOPCode,
+		})
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.afterFailure(&task, result)
+	return nil
+}
+
+// requeue 到期重投：释放信使并重新推入任务队列
+func (s *DeliveryFailureService) requeue(taskID string) error {
+	var task models.Task
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("task_id = ? AND status = ? AND next_retry_at <= ?", taskID, models.TaskStatusFailed, time.Now()).
+			First(&task).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", taskID).Updates(map[string]interface{}{
+			"status":        models.TaskStatusAvailable,
+			"courier_id":    nil,
+			"accepted_at":   nil,
+			"deadline":      nil,
+			"next_retry_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, taskID, "到期重投")
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	task.Status = models.TaskStatusAvailable
+	if err := s.queueService.PushTaskToQueue(&task); err != nil {
+		return err
+	}
+
+	s.notifySender(&task, "DELIVERY_RETRY_DISPATCHED", map[string]interface{}{
+		"attempt_no": task.AttemptCount + 1,
+	})
+	return nil
+}
+
+// afterFailure 失败登记提交后的通知
+func (s *DeliveryFailureService) afterFailure(task *models.Task, result *models.DeliveryFailureResult) {
+	if result.Attempt.CourierID != "" {
+		s.wsManager.SendTaskUpdate(task.TaskID, models.TaskStatusFailed, result.Attempt.CourierID)
+	}
+
+	data := map[string]interface{}{
+		"attempt_no":  result.Attempt.AttemptNo,
+		"reason":      result.Attempt.Reason,
+		"next_action": result.NextAction,
+	}
+	if result.NextAction == models.FailureActionRetry {
+		data["next_retry_at"] = result.Attempt.NextRetryAt
+		s.notifySender(task, "DELIVERY_RETRY_SCHEDULED", data)
+		return
+	}
+	if result.NextAction == models.FailureActionHoldAtStation {
+		s.notifySender(task, "DELIVERY_RETURN_FAILED", data)
+		s.alertHeld(task, result.Attempt)
+		return
+	}
+
+	data["return_task_id"] = result.ReturnTask.TaskID
+	s.notifySender(task, "DELIVERY_RETURNING_TO_SENDER", data)
+	s.wsManager.SendTaskAssignment(result.ReturnTask.TaskID, *result.ReturnTask.CourierID, result.ReturnTask)
+}
+
+// alertHeld 退件无法送达，通知管理员人工处理滞留信件
+func (s *DeliveryFailureService) alertHeld(task *models.Task, attempt *models.DeliveryAttempt) {
+	log.Printf("Return task %s for letter %s failed after %d attempts, letter held at station", task.TaskID, task.LetterID, attempt.AttemptNo)
+	s.wsManager.BroadcastToAdmins(utils.WebSocketEvent{
+		Type: "RETURN_DELIVERY_HELD",
+		Data: map[string]interface{}{
+			"task_id":            task.TaskID,
+			"letter_id":          task.LetterID,
+			"return_for_task_id": *task.ReturnForTaskID,
+			"attempt_no":         attempt.AttemptNo,
+			"reason":             attempt.Reason,
+			"op_code":            attempt.OPCode,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// notifySender 通过通知队列告知寄件人投递进展
+func (s *DeliveryFailureService) notifySender(task *models.Task, eventType string, data map[string]interface{}) {
+	if task.SenderID == nil || *task.SenderID == "" {
+		return
+	}
+
+	data["sender_id"] = *task.SenderID
+	data["task_id"] = task.TaskID
+	data["letter_id"] = task.LetterID
+	if err := s.queueService.PushNotification(utils.WebSocketEvent{
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}); err != nil {
+		log.Printf("Failed to queue sender notification for task %s: %v", task.TaskID, err)
+	}
+}
+
+// getPolicy 获取失败原因对应的重投策略，数据库未配置时使用默认策略
+func (s *DeliveryFailureService) getPolicy(tx *gorm.DB, reason string) (*models.DeliveryRetryPolicy, error) {
+	var policy models.DeliveryRetryPolicy
+	err := tx.Where("reason = ?", reason).First(&policy).Error
+	if err == nil {
+		return &policy, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	for _, defaultPolicy := range models.DefaultDeliveryRetryPolicies {
+		if defaultPolicy.Reason == reason {
+			return &defaultPolicy, nil
+		}
+	}
+	return nil, ErrRetryPolicyNotFound
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrDeliveryReceiptRequired = errors.New("送达须由收件人当面签收，未找到该信使有效的签收凭证")
+
+// DeliveryReceiptVerifier 签收凭证校验，签名格式与主服务签收凭证一致：
+// 由 JWT 密钥派生的 HMAC-SHA256
+type DeliveryReceiptVerifier struct {
+	key [32]byte
+}
+
+// NewDeliveryReceiptVerifier 创建签收凭证校验器，jwtSecret 与主服务相同
+func NewDeliveryReceiptVerifier(jwtSecret string) *DeliveryReceiptVerifier {
+	return &DeliveryReceiptVerifier{
+		key: sha256.Sum256([]byte("openpenpal-delivery-receipt:" + jwtSecret)),
+	}
+}
+
+// Require 返回该信使为信件取得的有效签收凭证，没有或签名不符时返回 ErrDeliveryReceiptRequired
+func (v *DeliveryReceiptVerifier) Require(db *gorm.DB, letterCode, courierID string) (*models.DeliveryReceipt, error) {
+	if v == nil {
+		return nil, ErrDeliveryReceiptRequired
+	}
+
+	var receipt models.DeliveryReceipt
+	err := db.Where("letter_code = ? AND courier_id = ?", letterCode, courierID).First(&receipt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeliveryReceiptRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !v.Verify(&receipt) {
+		return nil, ErrDeliveryReceiptRequired
+	}
+	return &receipt, nil
+}
+
+// Verified 同步事件引用的签收凭证是否属于该信件且签名有效
+func (v *DeliveryReceiptVerifier) Verified(db *gorm.DB, receiptID, letterCode string) (bool, error) {
+	if v == nil || receiptID == "" {
+		return false, nil
+	}
+
+	var receipt models.DeliveryReceipt
+	err := db.First(&receipt, "id = ?", receiptID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return receipt.LetterCode == letterCode && v.Verify(&receipt), nil
+}
+
+// Verify 校验签收凭证签名，凭证内容被篡改时返回false
+func (v *DeliveryReceiptVerifier) Verify(receipt *models.DeliveryReceipt) bool {
+	photoID := ""
+	if receipt.PhotoFileID != nil {
+		photoID = *receipt.PhotoFileID
+	}
+	payload := strings.Join([]string{
+		receipt.ID,
+		receipt.LetterID,
+		receipt.LetterCode,
+		receipt.CourierID,
+		receipt.RecipientID,
+		receipt.ConfirmationID,
+		receipt.ConfirmMethod,
+		photoID,
+		receipt.PhotoHash,
+		receipt.TargetOPCode,
+		formatReceiptCoordinate(receipt.Latitude),
+		formatReceiptCoordinate(receipt.Longitude),
+		receipt.GeofenceResult,
+		receipt.DeliveredAt.UTC().Format(time.RFC3339),
+	}, "|")
+
+	mac := hmac.New(sha256.New, v.key[:])
+	mac.Write([]byte(payload))
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(receipt.Signature))
+}
+
+func formatReceiptCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", *value)
+}
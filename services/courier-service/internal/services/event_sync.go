@@ -0,0 +1,730 @@
+package services
+
+import (
+	"context"
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSyncEvent      = errors.New("同步事件缺少必要字段")
+	ErrUnsupportedSyncSchema = errors.New("不支持的同步事件版本")
+)
+
+const (
+	syncDispatchInterval  = 2 * time.Second  // 出站事件投递间隔
+	syncDispatchBatchSize = 100              // 每次投递的最大事件数
+	syncConsumeTimeout    = 5 * time.Second  // 等待 backend 事件的超时
+	syncReconcileInterval = 10 * time.Minute // 对账间隔
+	syncReconcileWindow   = 24 * time.Hour   // 对账覆盖最近更新的任务
+	syncMaxAttempts       = 8                // 事件应用失败的最大次数，超过后转入死信队列
+	syncRetryBaseDelay    = 2 * time.Second  // 首次重试间隔，之后每次翻倍
+	syncRetryMaxDelay     = 5 * time.Minute  // 最长重试间隔
+)
+
+// EventSyncService 与 backend 的信件状态同步服务
+// 任务状态变化与出站事件在同一事务写入，再由投递协程推送到 Redis；
+// backend 的事件按 event_id 去重、按发生时间取最新后应用到任务；
+// 定时对账发布快照，两边据此发现并修复状态差异
+type EventSyncService struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	wsManager *utils.WebSocketManager
+	locations *LocationService
+	receipts  *DeliveryReceiptVerifier
+
+	mu               sync.RWMutex
+	lastReconciledAt *time.Time
+}
+
+// NewEventSyncService 创建状态同步服务
+func NewEventSyncService(db *gorm.DB, redis *redis.Client, wsManager *utils.WebSocketManager) *EventSyncService {
+	return &EventSyncService{
+		db:        db,
+		redis:     redis,
+		wsManager: wsManager,
+	}
+}
+
+// SetLocationService 设置地理位置服务，同步创建或改投的任务按校园地图补全坐标
+func (s *EventSyncService) SetLocationService(locations *LocationService) {
+	s.locations = locations
+}
+
+// SetDeliveryReceiptVerifier 设置签收凭证校验器，backend 的送达事件须引用有效签收凭证才应用
+func (s *EventSyncService) SetDeliveryReceiptVerifier(receipts *DeliveryReceiptVerifier) {
+	s.receipts = receipts
+}
+
+// StartDispatcher 定时投递出站事件
+func (s *EventSyncService) StartDispatcher() {
+	ticker := time.NewTicker(syncDispatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.PublishPending(); err != nil {
+			log.Printf("Sync outbox dispatch failed: %v", err)
+		}
+	}
+}
+
+// PublishPending 按写入顺序投递待发送事件，遇到错误即停止，下次从失败处继续
+func (s *EventSyncService) PublishPending() (int, error) {
+	var events []models.TaskOutboxEvent
+	if err := s.db.Where("status = ?", models.OutboxStatusPending).
+		Order("created_at ASC").Limit(syncDispatchBatchSize).Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	published := 0
+	for i := range events {
+		if err := s.redis.LPush(ctx, models.SyncQueueToBackend, events[i].Payload).Err(); err != nil {
+			s.db.Model(&events[i]).Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": err.Error(),
+			})
+			return published, err
+		}
+
+		// 推送成功但标记失败时会重复投递，由对方按 event_id 去重
+		now := time.Now()
+		if err := s.db.Model(&events[i]).Updates(map[string]interface{}{
+			"status":       models.OutboxStatusPublished,
+			"published_at": &now,
+		}).Error; err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// StartConsumer 消费 backend 发来的事件
+func (s *EventSyncService) StartConsumer() {
+	ctx := context.Background()
+
+	for {
+		if err := s.requeueDueRetries(ctx); err != nil {
+			log.Printf("Sync retry requeue failed: %v", err)
+		}
+
+		result, err := s.redis.BRPop(ctx, syncConsumeTimeout, models.SyncQueueToCourierService).Result()
+		if err != nil {
+			if err != redis.Nil {
+				log.Printf("Sync consumer BRPop error: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		if len(result) < 2 {
+			continue
+		}
+
+		s.consume(ctx, result[1])
+	}
+}
+
+// consume 应用一条事件，失败时按次数退避重试，无法处理或超过重试上限的进入死信队列
+func (s *EventSyncService) consume(ctx context.Context, payload string) {
+	var event models.SyncEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Failed to unmarshal sync event: %v", err)
+		s.deadLetter(ctx, payload, "")
+		return
+	}
+
+	if _, err := s.ApplyEvent(&event); err != nil {
+		log.Printf("Failed to apply sync event %s: %v", event.EventID, err)
+		if errors.Is(err, ErrInvalidSyncEvent) || errors.Is(err, ErrUnsupportedSyncSchema) {
+			s.deadLetter(ctx, payload, event.EventID)
+			return
+		}
+		// 事务已回滚，稍后重试
+		s.scheduleRetry(ctx, payload, event.EventID)
+		return
+	}
+
+	s.redis.HDel(ctx, models.SyncAttemptsToCourierService, event.EventID)
+}
+
+// scheduleRetry 记录失败次数并按指数退避放入重试集合
+func (s *EventSyncService) scheduleRetry(ctx context.Context, payload, eventID string) {
+	attempts, err := s.redis.HIncrBy(ctx, models.SyncAttemptsToCourierService, eventID, 1).Result()
+	if err != nil {
+		log.Printf("Failed to count sync event attempts %s: %v", eventID, err)
+		attempts = 1
+	}
+	if attempts >= syncMaxAttempts {
+		log.Printf("Sync event %s failed %d times, moved to dead letter queue", eventID, attempts)
+		s.deadLetter(ctx, payload, eventID)
+		return
+	}
+
+	retryAt := time.Now().Add(syncRetryDelay(int(attempts)))
+	if err := s.redis.ZAdd(ctx, models.SyncRetryToCourierService, redis.Z{
+		Score:  float64(retryAt.Unix()),
+		Member: payload,
+	}).Err(); err != nil {
+		log.Printf("Failed to schedule sync event retry %s: %v", eventID, err)
+	}
+}
+
+// deadLetter 放入死信队列，不再自动重试
+func (s *EventSyncService) deadLetter(ctx context.Context, payload, eventID string) {
+	if err := s.redis.LPush(ctx, models.SyncDeadLetterToCourierService, payload).Err(); err != nil {
+		log.Printf("Failed to dead-letter sync event %s: %v", eventID, err)
+	}
+	if eventID != "" {
+		s.redis.HDel(ctx, models.SyncAttemptsToCourierService, eventID)
+	}
+}
+
+// requeueDueRetries 把到期的重试事件放回消费队列，多个消费者时只有移出成功的一方放回
+func (s *EventSyncService) requeueDueRetries(ctx context.Context) error {
+	due, err := s.redis.ZRangeByScore(ctx, models.SyncRetryToCourierService, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range due {
+		removed, err := s.redis.ZRem(ctx, models.SyncRetryToCourierService, payload).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		if err := s.redis.LPush(ctx, models.SyncQueueToCourierService, payload).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRetryDelay 第 attempts 次失败后的重试间隔
+func syncRetryDelay(attempts int) time.Duration {
+	delay := syncRetryBaseDelay
+	for i := 1; i < attempts && delay < syncRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > syncRetryMaxDelay {
+		delay = syncRetryMaxDelay
+	}
+	return delay
+}
+
+// ApplyEvent 应用一条 backend 事件，返回处理结果
+// 应用过程不写出站事件，避免两边来回回显
+func (s *EventSyncService) ApplyEvent(event *models.SyncEvent) (string, error) {
+	if event.EventID == "" || event.LetterCode == "" || event.Status == "" || event.OccurredAt.IsZero() {
+		return "", ErrInvalidSyncEvent
+	}
+	if event.SchemaVersion > models.SyncSchemaVersion {
+		return "", ErrUnsupportedSyncSchema
+	}
+
+	var result string
+	var updated *models.Task
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		inbox := &models.TaskSyncInbox{
+			EventID:     event.EventID,
+			EventType:   event.EventType,
+			Source:      event.Source,
+			LetterCode:  event.LetterCode,
+			Result:      models.SyncResultApplied,
+			ProcessedAt: time.Now(),
+		}
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(inbox)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			result = models.SyncResultDuplicate
+			return nil
+		}
+
+		var err error
+		result, updated, err = s.applyEvent(tx, event)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.TaskSyncInbox{}).Where("event_id = ?", event.EventID).
+			Update("result", result).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if updated != nil {
+		courierID := ""
+		if updated.CourierID != nil {
+			courierID = *updated.CourierID
+		}
+		s.wsManager.SendTaskUpdate(updated.TaskID, updated.Status, courierID)
+	}
+
+	return result, nil
+}
+
+// Reconcile 为最近更新的任务发布对账快照，每封信取最新的任务
+func (s *EventSyncService) Reconcile() (int, error) {
+	var tasks []models.Task
+	if err := s.db.Where("updated_at >= ? AND return_for_task_id IS NULL", time.Now().Add(-syncReconcileWindow)).
+		Order("created_at DESC").Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	published := 0
+	for i := range tasks {
+		task := &tasks[i]
+		if seen[task.LetterID] {
+			continue
+		}
+		seen[task.LetterID] = true
+
+		status := models.TaskLifecycleStatus(task.Status)
+		if status == "" {
+			continue
+		}
+
+		_, occurredAt, err := localSyncStatus(s.db, task)
+		if err != nil {
+			return published, err
+		}
+		event, err := newTaskSyncEvent(s.db, task, models.SyncEventTaskSnapshot, occurredAt)
+		if err != nil {
+			return published, err
+		}
+		if err := enqueueTaskSyncEvent(s.db, event); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.lastReconciledAt = &now
+	s.mu.Unlock()
+
+	return published, nil
+}
+
+// StartReconciler 定时对账
+func (s *EventSyncService) StartReconciler() {
+	ticker := time.NewTicker(syncReconcileInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.Reconcile(); err != nil {
+			log.Printf("Sync reconcile failed: %v", err)
+		}
+	}
+}
+
+// GetDivergences 查询对账差异
+func (s *EventSyncService) GetDivergences(query *models.SyncDivergenceQuery) ([]models.TaskSyncDivergence, int64, error) {
+	var divergences []models.TaskSyncDivergence
+	var total int64
+
+	db := s.db.Model(&models.TaskSyncDivergence{})
+	if query.Resolution != "" {
+		db = db.Where("resolution = ?", query.Resolution)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Order("detected_at DESC").Limit(query.Limit).Offset(query.Offset).Find(&divergences).Error
+	return divergences, total, err
+}
+
+// GetStatus 获取同步概况
+func (s *EventSyncService) GetStatus() (*models.SyncStatusResponse, error) {
+	status := &models.SyncStatusResponse{}
+
+	if err := s.db.Model(&models.TaskOutboxEvent{}).
+		Where("status = ?", models.OutboxStatusPending).Count(&status.PendingOutbox).Error; err != nil {
+		return nil, err
+	}
+	if status.PendingOutbox > 0 {
+		var oldest models.TaskOutboxEvent
+		if err := s.db.Where("status = ?", models.OutboxStatusPending).
+			Order("created_at ASC").First(&oldest).Error; err == nil {
+			status.OldestPendingAt = &oldest.CreatedAt
+		}
+	}
+	if err := s.db.Model(&models.TaskSyncInbox{}).Count(&status.ProcessedInbox).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.TaskSyncDivergence{}).
+		Where("resolution IN ?", []string{models.DivergenceConflict, models.DivergenceRejected}).
+		Count(&status.OpenConflicts).Error; err != nil {
+		return nil, err
+	}
+	deadLetters, err := s.redis.LLen(context.Background(), models.SyncDeadLetterToCourierService).Result()
+	if err != nil {
+		return nil, err
+	}
+	status.DeadLetters = deadLetters
+
+	s.mu.RLock()
+	status.LastReconciledAt = s.lastReconciledAt
+	s.mu.RUnlock()
+
+	return status, nil
+}
+
+// 私有方法
+
+// applyEvent 在事务内比较本地状态并应用事件
+func (s *EventSyncService) applyEvent(tx *gorm.DB, event *models.SyncEvent) (string, *models.Task, error) {
+	task, err := findSyncTask(tx, event.LetterCode)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if task == nil {
+		task, err = createSyncTask(tx, event, s.locations)
+		if err != nil || task == nil {
+			return models.SyncResultIgnored, nil, err
+		}
+		return models.SyncResultApplied, task, saveTaskSyncState(tx, event.LetterCode, event.Status, event.OccurredAt, event.EventID, event.Source)
+	}
+
+	// 跨校接力任务的状态只由交接扫码推进
+	if task.IsRelay {
+		return models.SyncResultIgnored, nil, nil
+	}
+
+	// 转寄只改投递目标，不改变任务进度
+	if event.EventType == models.SyncEventLetterRerouted {
+		if event.DeliveryOPCode == "" || task.DeliveryOPCode == event.DeliveryOPCode {
+			return models.SyncResultInSync, nil, nil
+		}
+		task.DeliveryOPCode = event.DeliveryOPCode
+		task.DeliveryLocation = event.DeliveryOPCode
+		task.DeliveryLat, task.DeliveryLng = 0, 0
+		s.locations.FillTaskCoordinates(task)
+		if err := tx.Model(task).Updates(map[string]interface{}{
+			"delivery_op_code":  task.DeliveryOPCode,
+			"delivery_location": task.DeliveryLocation,
+			"delivery_lat":      task.DeliveryLat,
+			"delivery_lng":      task.DeliveryLng,
+		}).Error; err != nil {
+			return "", nil, err
+		}
+		return models.SyncResultApplied, task, nil
+	}
+
+	localStatus, localAt, err := localSyncStatus(tx, task)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if event.IsSnapshot() {
+		return s.reconcileSnapshot(tx, task, localStatus, localAt, event)
+	}
+
+	if !event.OccurredAt.After(localAt) {
+		return models.SyncResultStale, nil, nil
+	}
+
+	if localStatus == event.Status {
+		return models.SyncResultInSync, nil, saveTaskSyncState(tx, event.LetterCode, event.Status, event.OccurredAt, event.EventID, event.Source)
+	}
+
+	verified, err := s.verifyDeliveryReceipt(tx, event)
+	if err != nil {
+		return "", nil, err
+	}
+	if !verified {
+		return models.SyncResultRejected, nil, nil
+	}
+
+	if err := applyTaskLifecycle(tx, task, event); err != nil {
+		return "", nil, err
+	}
+	return models.SyncResultApplied, task, nil
+}
+
+// reconcileSnapshot 对比对方快照，较新的一方为准
+func (s *EventSyncService) reconcileSnapshot(tx *gorm.DB, task *models.Task, localStatus string, localAt time.Time, event *models.SyncEvent) (string, *models.Task, error) {
+	if localStatus == event.Status {
+		return models.SyncResultInSync, nil, nil
+	}
+
+	divergence := &models.TaskSyncDivergence{
+		ID:            uuid.New().String(),
+		LetterCode:    event.LetterCode,
+		LocalStatus:   localStatus,
+		RemoteStatus:  event.Status,
+		RemoteSource:  event.Source,
+		LocalEventAt:  localAt,
+		RemoteEventAt: event.OccurredAt,
+		DetectedAt:    time.Now(),
+	}
+
+	var updated *models.Task
+	switch {
+	case event.OccurredAt.After(localAt):
+		verified, err := s.verifyDeliveryReceipt(tx, event)
+		if err != nil {
+			return "", nil, err
+		}
+		if !verified {
+			divergence.Resolution = models.DivergenceRejected
+			break
+		}
+		if err := applyTaskLifecycle(tx, task, event); err != nil {
+			return "", nil, err
+		}
+		divergence.Resolution = models.DivergenceRepairedLocal
+		updated = task
+	case localAt.After(event.OccurredAt):
+		// 本地更新，重新发布本地快照让对方修复
+		snapshot, err := newTaskSyncEvent(tx, task, models.SyncEventTaskSnapshot, localAt)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := enqueueTaskSyncEvent(tx, snapshot); err != nil {
+			return "", nil, err
+		}
+		divergence.Resolution = models.DivergenceRepublished
+	default:
+		// 发生时间相同无法判断先后，只登记不处理，避免两边互相覆盖
+		divergence.Resolution = models.DivergenceConflict
+	}
+
+	if err := tx.Create(divergence).Error; err != nil {
+		return "", nil, err
+	}
+
+	return models.SyncResultApplied, updated, nil
+}
+
+// verifyDeliveryReceipt 送达事件须引用本信件签名有效的签收凭证，其他状态的事件直接通过
+func (s *EventSyncService) verifyDeliveryReceipt(tx *gorm.DB, event *models.SyncEvent) (bool, error) {
+	if event.Status != models.LifecycleDelivered {
+		return true, nil
+	}
+	return s.receipts.Verified(tx, event.ReceiptID, event.LetterCode)
+}
+
+// recordTaskSyncEvent 在任务状态变化的事务内写入出站事件，需在更新任务后调用
+func recordTaskSyncEvent(tx *gorm.DB, taskID, note string) error {
+	var task models.Task
+	if err := tx.Where("task_id = ?", taskID).First(&task).Error; err != nil {
+		return err
+	}
+
+	// 退件任务不代表信件本身的投递进度，原任务已发布 returned
+	if task.ReturnForTaskID != nil {
+		return nil
+	}
+
+	status := models.TaskLifecycleStatus(task.Status)
+	if status == "" {
+		return nil
+	}
+
+	event, err := newTaskSyncEvent(tx, &task, models.SyncEventTaskStatusChanged, time.Now())
+	if err != nil {
+		return err
+	}
+	event.Note = note
+	if err := enqueueTaskSyncEvent(tx, event); err != nil {
+		return err
+	}
+
+	return saveTaskSyncState(tx, task.LetterID, status, event.OccurredAt, event.EventID, event.Source)
+}
+
+// newTaskSyncEvent 由任务生成同步事件，已送达的任务带上签收凭证供对方校验
+func newTaskSyncEvent(db *gorm.DB, task *models.Task, eventType string, occurredAt time.Time) (*models.SyncEvent, error) {
+	event := &models.SyncEvent{
+		EventID:        uuid.New().String(),
+		EventType:      eventType,
+		Source:         models.SyncSourceCourierService,
+		SchemaVersion:  models.SyncSchemaVersion,
+		LetterCode:     task.LetterID,
+		Status:         models.TaskLifecycleStatus(task.Status),
+		PickupOPCode:   task.PickupOPCode,
+		DeliveryOPCode: task.DeliveryOPCode,
+		CurrentOPCode:  task.CurrentOPCode,
+		OccurredAt:     occurredAt,
+	}
+	if task.CourierID != nil {
+		event.CourierID = *task.CourierID
+	}
+	if task.Status == models.TaskStatusDelivered {
+		var receiptIDs []string
+		if err := db.Model(&models.DeliveryReceipt{}).Where("letter_code = ?", task.LetterID).
+			Pluck("id", &receiptIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(receiptIDs) > 0 {
+			event.ReceiptID = receiptIDs[0]
+		}
+	}
+	return event, nil
+}
+
+// enqueueTaskSyncEvent 写入出站事件
+func enqueueTaskSyncEvent(tx *gorm.DB, event *models.SyncEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.TaskOutboxEvent{
+		ID:         event.EventID,
+		EventType:  event.EventType,
+		LetterCode: event.LetterCode,
+		Payload:    string(payload),
+		Status:     models.OutboxStatusPending,
+	}).Error
+}
+
+// saveTaskSyncState 记录信件最近一次已知状态
+func saveTaskSyncState(tx *gorm.DB, letterCode, status string, occurredAt time.Time, eventID, source string) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.TaskSyncState{
+		LetterCode:  letterCode,
+		Status:      status,
+		LastEventAt: occurredAt,
+		LastEventID: eventID,
+		LastSource:  source,
+	}).Error
+}
+
+// localSyncStatus 任务当前的统一状态及其发生时间
+// 同步状态与任务一致时取记录的时间，否则任务被绕过同步直接修改过，以任务更新时间为准
+func localSyncStatus(db *gorm.DB, task *models.Task) (string, time.Time, error) {
+	status := models.TaskLifecycleStatus(task.Status)
+
+	var state models.TaskSyncState
+	err := db.Where("letter_code = ?", task.LetterID).First(&state).Error
+	if err == nil && state.Status == status {
+		return status, state.LastEventAt, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", time.Time{}, err
+	}
+
+	return status, task.UpdatedAt, nil
+}
+
+// findSyncTask 查找信件对应的投递任务，不含退件任务
+func findSyncTask(tx *gorm.DB, letterCode string) (*models.Task, error) {
+	var task models.Task
+	err := tx.Where("letter_id = ? AND return_for_task_id IS NULL", letterCode).
+		Order("created_at DESC").First(&task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// createSyncTask backend 新分配的信件在本地还没有任务时补建；公共信箱收取的信件到达时已是已取件状态
+func createSyncTask(tx *gorm.DB, event *models.SyncEvent, locations *LocationService) (*models.Task, error) {
+	collected := event.Status == models.LifecycleCollected && event.CourierID != ""
+	if event.Status != models.LifecycleCreated && event.Status != models.LifecycleAssigned && !collected {
+		return nil, nil
+	}
+	if event.PickupOPCode == "" || event.DeliveryOPCode == "" {
+		return nil, nil
+	}
+
+	task := &models.Task{
+		ID:               uuid.New().String(),
+		TaskID:           utils.GenerateTaskID(),
+		LetterID:         event.LetterCode,
+		PickupLocation:   event.PickupOPCode,
+		DeliveryLocation: event.DeliveryOPCode,
+		Status:           models.TaskStatusFromLifecycle(event.Status),
+		Priority:         models.TaskPriorityNormal,
+		Reward:           5.0,
+		PickupOPCode:     event.PickupOPCode,
+		DeliveryOPCode:   event.DeliveryOPCode,
+		CurrentOPCode:    event.PickupOPCode,
+	}
+	if event.Location != "" {
+		task.PickupLocation = event.Location
+	}
+	if (event.Status == models.LifecycleAssigned || collected) && event.CourierID != "" {
+		courierID := event.CourierID
+		task.CourierID = &courierID
+		task.AcceptedAt = &event.OccurredAt
+	}
+	if collected {
+		task.CollectedAt = &event.OccurredAt
+	}
+	locations.FillTaskCoordinates(task)
+
+	if err := tx.Create(task).Error; err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// applyTaskLifecycle 按事件状态更新任务并记录同步状态
+func applyTaskLifecycle(tx *gorm.DB, task *models.Task, event *models.SyncEvent) error {
+	target := models.TaskStatusFromLifecycle(event.Status)
+	if target == "" {
+		return nil
+	}
+
+	at := event.OccurredAt
+	updates := map[string]interface{}{
+		"status": target,
+	}
+	switch event.Status {
+	case models.LifecycleCreated:
+		updates["courier_id"] = nil
+		updates["accepted_at"] = nil
+		updates["collected_at"] = nil
+	case models.LifecycleAssigned:
+		if event.CourierID != "" {
+			updates["courier_id"] = event.CourierID
+		}
+		updates["accepted_at"] = &at
+	case models.LifecycleCollected:
+		updates["collected_at"] = &at
+	case models.LifecycleDelivered:
+		updates["completed_at"] = &at
+	}
+	if event.CurrentOPCode != "" {
+		updates["current_op_code"] = event.CurrentOPCode
+	}
+
+	if err := tx.Model(&models.Task{}).Where("task_id = ?", task.TaskID).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("task_id = ?", task.TaskID).First(task).Error; err != nil {
+		return err
+	}
+
+	return saveTaskSyncState(tx, event.LetterCode, event.Status, event.OccurredAt, event.EventID, event.Source)
+}
@@ -118,6 +118,9 @@ func (s *HierarchicalAssignmentService) cascadeAssignment(manager *models.Courie
 
 	// 选择最优信使（基于评分）
 	bestCourier := s.selectBestCourierForTask(validCouriers, task)
+	if bestCourier == nil {
+		return nil, ErrNoAvailableCourier
+	}
 
 	// 执行分配
 	err = s.assignTaskToCourier(task, bestCourier, manager.ID)
@@ -220,7 +223,7 @@ func (s *HierarchicalAssignmentService) GetAssignmentHistory(managerID string, l
 func (s *HierarchicalAssignmentService) ReassignTask(managerID string, req *models.TaskReassignmentRequest) (*models.Task, error) {
 	// 获取管理者信息
 	var manager models.Courier
-	if err := s.db.First(&manager, managerID).Error; err != nil {
+	if err := s.db.First(&manager, "id = ?", managerID).Error; err != nil {
 		return nil, fmt.Errorf("管理者不存在: %w", err)
 	}
 
@@ -230,6 +233,11 @@ func (s *HierarchicalAssignmentService) ReassignTask(managerID string, req *mode
 		return nil, fmt.Errorf("任务不存在: %w", err)
 	}
 
+	// 接力任务的信使随交接变化，需调整对应接力段
+	if task.IsRelay {
+		return nil, errors.New("跨校接力任务请调整对应接力段的信使")
+	}
+
 	// 获取原信使信息
 	var oldCourier models.Courier
 	if task.CourierID != nil {
@@ -243,7 +251,7 @@ func (s *HierarchicalAssignmentService) ReassignTask(managerID string, req *mode
 
 	// 获取新的目标信使
 	var newCourier models.Courier
-	if err := s.db.First(&newCourier, req.NewCourierID).Error; err != nil {
+	if err := s.db.First(&newCourier, "id = ?", req.NewCourierID).Error; err != nil {
 		return nil, fmt.Errorf("新的目标信使不存在: %w", err)
 	}
 
@@ -288,6 +296,8 @@ func (s *HierarchicalAssignmentService) canHandleTaskInZone(courier *models.Cour
 
 // selectBestCourierForTask 为任务选择最佳信使
 func (s *HierarchicalAssignmentService) selectBestCourierForTask(couriers []models.Courier, task *models.Task) *models.Courier {
+	// 只在当前在岗、在排班时段内且有余量的信使中选择
+	couriers = s.assignmentService.availabilityService.FilterAvailable(couriers, time.Now())
 	if len(couriers) == 0 {
 		return nil
 	}
@@ -325,7 +335,11 @@ func (s *HierarchicalAssignmentService) findBestCourierInHierarchy(manager *mode
 		return nil, errors.New("没有找到合适的信使")
 	}
 
-	return s.selectBestCourierForTask(validCouriers, task), nil
+	bestCourier := s.selectBestCourierForTask(validCouriers, task)
+	if bestCourier == nil {
+		return nil, ErrNoAvailableCourier
+	}
+	return bestCourier, nil
 }
 
 // isInManagementScope 检查是否在管理范围内
@@ -393,6 +407,11 @@ func (s *HierarchicalAssignmentService) assignTaskToCourier(task *models.Task, c
 		return err
 	}
 
+	if err := recordTaskSyncEvent(tx, task.TaskID, ""); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	tx.Commit()
 
 	// 发送通知
@@ -416,9 +435,10 @@ func (s *HierarchicalAssignmentService) reassignTaskToCourier(task *models.Task,
 
 	// 更新任务信息
 	err := tx.Model(task).Updates(map[string]interface{}{
-		"courier_id":  &newCourier.UserID,
-		"status":      models.TaskStatusAccepted,
-		"accepted_at": &now,
+		"courier_id":   &newCourier.UserID,
+		"status":       models.TaskStatusAccepted,
+		"accepted_at":  &now,
+		"collected_at": nil, // 改派后由新信使重新收取
 	}).Error
 
 	if err != nil {
@@ -442,6 +462,11 @@ func (s *HierarchicalAssignmentService) reassignTaskToCourier(task *models.Task,
 		return err
 	}
 
+	if err := recordTaskSyncEvent(tx, task.TaskID, reason); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	tx.Commit()
 
 	// 发送通知
@@ -296,7 +296,7 @@ func (s *LeaderboardService) createInitialRanking(courierID string) (*models.Cou
 // updateRanking 更新单个信使排名
 func (s *LeaderboardService) updateRanking(courierID string) {
 	var courier models.Courier
-	if err := s.db.First(&courier, courierID).Error; err != nil {
+	if err := s.db.First(&courier, "id = ?", courierID).Error; err != nil {
 		return
 	}
 
@@ -312,13 +312,20 @@ func (s *LeaderboardService) updateRanking(courierID string) {
 		successRate = float64(completedTasks) / float64(totalTasks) * 100
 	}
 
+	// SLA达成率按近30天统计，任务上记录的是信使用户ID
+	slaComplianceRate := 100.0
+	if compliance, err := calculateSLACompliance(s.db, courier.UserID, time.Now().AddDate(0, 0, -30), time.Now()); err == nil {
+		slaComplianceRate = compliance.ComplianceRate
+	}
+
 	// 更新或创建排名记录
 	ranking := &models.CourierRanking{
-		CourierID:   courierID,
-		Points:      courier.Points,
-		TotalTasks:  int(totalTasks),
-		SuccessRate: successRate,
-		UpdatedAt:   time.Now(),
+		CourierID:         courierID,
+		Points:            courier.Points,
+		TotalTasks:        int(totalTasks),
+		SuccessRate:       successRate,
+		SLAComplianceRate: slaComplianceRate,
+		UpdatedAt:         time.Now(),
 	}
 
 	s.db.Where("courier_id = ?", courierID).
@@ -337,7 +344,7 @@ func (s *LeaderboardService) updateSchoolRankings() error {
 				cr.id,
 				ROW_NUMBER() OVER (
 					PARTITION BY SUBSTRING(c.zone_code, 1, 6) 
-					ORDER BY cr.points DESC, cr.success_rate DESC
+					ORDER BY cr.points DESC, cr.sla_compliance_rate DESC, cr.success_rate DESC
 				) as rank
 			FROM courier_rankings cr
 			JOIN couriers c ON cr.courier_id = c.id
@@ -358,7 +365,7 @@ func (s *LeaderboardService) updateZoneRankings() error {
 				cr.id,
 				ROW_NUMBER() OVER (
 					PARTITION BY c.zone_code 
-					ORDER BY cr.points DESC, cr.success_rate DESC
+					ORDER BY cr.points DESC, cr.sla_compliance_rate DESC, cr.success_rate DESC
 				) as rank
 			FROM courier_rankings cr
 			JOIN couriers c ON cr.courier_id = c.id
@@ -378,7 +385,7 @@ func (s *LeaderboardService) updateNationalRankings() error {
 			SELECT 
 				cr.id,
 				ROW_NUMBER() OVER (
-					ORDER BY cr.points DESC, cr.success_rate DESC
+					ORDER BY cr.points DESC, cr.sla_compliance_rate DESC, cr.success_rate DESC
 				) as rank
 			FROM courier_rankings cr
 			JOIN couriers c ON cr.courier_id = c.id
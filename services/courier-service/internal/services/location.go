@@ -2,18 +2,50 @@ package services
 
 import (
 	"courier-service/internal/models"
+	"courier-service/internal/utils"
 	"fmt"
 	"math"
 )
 
 // LocationService 地理位置服务
-type LocationService struct{}
+type LocationService struct {
+	geodata *utils.CampusGeodata
+}
 
 // NewLocationService 创建地理位置服务实例
 func NewLocationService() *LocationService {
 	return &LocationService{}
 }
 
+// SetGeodata 设置校园地图，设置后按片区边界和投递点坐标定位
+func (s *LocationService) SetGeodata(geodata *utils.CampusGeodata) {
+	s.geodata = geodata
+}
+
+// FillTaskCoordinates 按取件/送达OP Code（没有时按地点字段）补全任务坐标，已有坐标不覆盖
+func (s *LocationService) FillTaskCoordinates(task *models.Task) {
+	if s == nil || s.geodata == nil {
+		return
+	}
+	if task.PickupLat == 0 && task.PickupLng == 0 {
+		if lat, lng, ok := s.locateCode(task.PickupOPCode, task.PickupLocation); ok {
+			task.PickupLat, task.PickupLng = lat, lng
+		}
+	}
+	if task.DeliveryLat == 0 && task.DeliveryLng == 0 {
+		if lat, lng, ok := s.locateCode(task.DeliveryOPCode, task.DeliveryLocation); ok {
+			task.DeliveryLat, task.DeliveryLng = lat, lng
+		}
+	}
+}
+
+func (s *LocationService) locateCode(opCode, location string) (float64, float64, bool) {
+	if opCode != "" {
+		return s.geodata.Locate(opCode)
+	}
+	return s.geodata.Locate(location)
+}
+
 // CalculateDistance 使用Haversine公式计算两点之间的距离（单位：公里）
 func (s *LocationService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371 // 地球半径 (km)
@@ -113,6 +145,13 @@ func (s *LocationService) FormatDistance(distanceKm float64) string {
 
 // ParseLocation 解析位置字符串（这里可以集成地理编码服务）
 func (s *LocationService) ParseLocation(location string) (float64, float64, error) {
+	// 校园地图中的OP Code或片区前缀
+	if s.geodata != nil {
+		if lat, lng, ok := s.geodata.Locate(location); ok {
+			return lat, lng, nil
+		}
+	}
+
 	// 这里可以集成百度地图、高德地图等地理编码API
 	// 暂时返回默认值
 
@@ -131,9 +170,13 @@ func (s *LocationService) ParseLocation(location string) (float64, float64, erro
 }
 
 // GetZoneFromCoordinate 根据坐标获取所属区域
+// 有校园地图时返回所在片区的4位OP Code前缀，否则按学校大致范围返回学校名称
 func (s *LocationService) GetZoneFromCoordinate(lat, lng float64) string {
-	// 这里可以实现更复杂的区域判断逻辑
-	// 暂时简单处理
+	if s.geodata != nil {
+		if prefix, _, ok := s.geodata.AreaAt(lat, lng); ok {
+			return prefix
+		}
+	}
 
 	// 北京大学范围
 	if lat >= 39.985 && lat <= 39.997 && lng >= 116.300 && lng <= 116.315 {
@@ -275,6 +275,13 @@ func (s *QueueService) processNotification(notification *utils.WebSocketEvent) {
 		}
 	case "TASK_AUTO_ASSIGNED", "NEW_COURIER_APPLICATION":
 		s.wsManager.BroadcastToAdmins(*notification)
+	case "DELIVERY_RETRY_SCHEDULED", "DELIVERY_RETRY_DISPATCHED", "DELIVERY_RETURNING_TO_SENDER":
+		// 投递失败进展只发给寄件人
+		if data, ok := notification.Data.(map[string]interface{}); ok {
+			if senderID, ok := data["sender_id"].(string); ok {
+				s.wsManager.BroadcastToUser(senderID, *notification)
+			}
+		}
 	default:
 		s.wsManager.BroadcastToAll(*notification)
 	}
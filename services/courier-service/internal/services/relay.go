@@ -0,0 +1,828 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRelayTaskNotFound        = errors.New("任务不存在")
+	ErrRelayNotRelayTask        = errors.New("任务不是跨校接力任务")
+	ErrRelayAlreadyPlanned      = errors.New("任务已拆分为接力段")
+	ErrRelayTaskNotPlannable    = errors.New("任务已被接取，无法拆分接力")
+	ErrRelayOPCodeMissing       = errors.New("任务缺少取件或送达OP Code")
+	ErrRelayNotCrossSchool      = errors.New("取件与送达地址属于同一学校，无需接力")
+	ErrRelayPermissionDenied    = errors.New("无权管理该接力任务")
+	ErrRelayLegNotFound         = errors.New("接力段不存在")
+	ErrRelayLegStarted          = errors.New("接力段已开始，不能更换信使")
+	ErrRelayCourierNotEligible  = errors.New("信使等级或管辖范围不符合该接力段")
+	ErrRelayNotLegCourier       = errors.New("当前接力段不属于该信使")
+	ErrRelayFromCourierMismatch = errors.New("交出信使不是当前保管人")
+	ErrRelayInvalidState        = errors.New("当前接力进度不允许该操作")
+	ErrRelayScanNotAllowed      = errors.New("跨校接力任务请使用接力扫码")
+	ErrRelayHandoffCodeInvalid  = errors.New("交接码无效或已过期，请交出信使重新获取")
+)
+
+const (
+	relayHandoffCodeTTL      = 10 * time.Minute
+	relayHandoffCodeAttempts = 5
+)
+
+// RelayService 跨校接力服务
+// 跨校信件按 楼栋收件 → 校级集中 → 城市转运 → 目的学校投递 拆成接力段，
+// 每次交接由交出信使申请交接码、接手信使扫码并输入交接码，信件同一时刻只由一段保管
+type RelayService struct {
+	db         *gorm.DB
+	wsManager  *utils.WebSocketManager
+	receipts   *DeliveryReceiptVerifier
+	qrVerifier *utils.QRTokenVerifier
+}
+
+// NewRelayService 创建跨校接力服务
+func NewRelayService(db *gorm.DB, wsManager *utils.WebSocketManager) *RelayService {
+	return &RelayService{
+		db:        db,
+		wsManager: wsManager,
+	}
+}
+
+// SetQRTokenVerifier 设置二维码签名验证器，设置后收件、交接和投递扫码前校验标签签名
+func (s *RelayService) SetQRTokenVerifier(verifier *utils.QRTokenVerifier) {
+	s.qrVerifier = verifier
+}
+
+// SetDeliveryReceiptVerifier 设置签收凭证校验器，最后一段投递须有收件人签收凭证
+func (s *RelayService) SetDeliveryReceiptVerifier(receipts *DeliveryReceiptVerifier) {
+	s.receipts = receipts
+}
+
+// PlanRelay 将跨校任务拆分为接力段并按层级匹配各段信使
+// 仅三级及以上信使可操作，三级信使须管辖寄出或目的学校
+func (s *RelayService) PlanRelay(plannerID, taskID string) (*models.RelayChain, error) {
+	planner, err := s.getCourierByUserID(plannerID)
+	if err != nil {
+		return nil, ErrRelayPermissionDenied
+	}
+	if !planner.IsActive() || planner.Level < models.CourierLevelThree {
+		return nil, ErrRelayPermissionDenied
+	}
+
+	var legs []models.RelayLeg
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		task, err := s.lockTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if task.IsRelay {
+			return ErrRelayAlreadyPlanned
+		}
+		if task.Status != models.TaskStatusAvailable {
+			return ErrRelayTaskNotPlannable
+		}
+		if len(task.PickupOPCode) < 2 || len(task.DeliveryOPCode) < 2 {
+			return ErrRelayOPCodeMissing
+		}
+		if task.PickupOPCode[:2] == task.DeliveryOPCode[:2] {
+			return ErrRelayNotCrossSchool
+		}
+		if !courierCoversOPCode(planner, task.PickupOPCode) && !courierCoversOPCode(planner, task.DeliveryOPCode) {
+			return ErrRelayPermissionDenied
+		}
+
+		legs = buildRelayLegs(task)
+		for i := range legs {
+			courier, err := s.findRelayCourier(tx, &legs[i])
+			if err != nil {
+				return err
+			}
+			if courier != nil {
+				legs[i].CourierID = &courier.UserID
+			}
+		}
+		if err := tx.Create(&legs).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", task.TaskID).Updates(map[string]interface{}{
+			"is_relay":    true,
+			"status":      models.TaskStatusAccepted,
+			"courier_id":  legs[0].CourierID,
+			"accepted_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, task.TaskID, "跨校接力")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range legs {
+		if legs[i].CourierID != nil {
+			s.notifyRelay(*legs[i].CourierID, "RELAY_LEG_ASSIGNED", &legs[i], nil)
+		}
+	}
+
+	return s.GetRelayChain(taskID)
+}
+
+// AssignLeg 为尚未开始的接力段指定信使，用于补齐自动匹配不到的段或调整人选
+func (s *RelayService) AssignLeg(managerID, legID string, req *models.RelayAssignLegRequest) (*models.RelayLeg, error) {
+	manager, err := s.getCourierByUserID(managerID)
+	if err != nil || !manager.IsActive() || manager.Level < models.CourierLevelThree {
+		return nil, ErrRelayPermissionDenied
+	}
+
+	courier, err := s.getCourierByUserID(req.CourierID)
+	if err != nil {
+		return nil, ErrRelayCourierNotEligible
+	}
+	if courier.Level > manager.Level {
+		return nil, ErrRelayPermissionDenied
+	}
+
+	var leg models.RelayLeg
+	var previousCourierID *string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", legID).First(&leg).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRelayLegNotFound
+			}
+			return err
+		}
+		if _, err := s.lockTask(tx, leg.TaskID); err != nil {
+			return err
+		}
+		// 加锁后重新读取，避免与交接扫码并发
+		if err := tx.Where("id = ?", legID).First(&leg).Error; err != nil {
+			return err
+		}
+		if leg.Status != models.RelayLegStatusPending {
+			return ErrRelayLegStarted
+		}
+		if !isRelayLegEligible(courier, &leg) {
+			return ErrRelayCourierNotEligible
+		}
+		if !courierCoversOPCode(manager, leg.FromOPCode) && !courierCoversOPCode(manager, leg.ToOPCode) {
+			return ErrRelayPermissionDenied
+		}
+
+		previousCourierID = leg.CourierID
+		leg.CourierID = &courier.UserID
+		if err := tx.Model(&leg).Update("courier_id", courier.UserID).Error; err != nil {
+			return err
+		}
+		if leg.Sequence == 1 {
+			return tx.Model(&models.Task{}).Where("task_id = ?", leg.TaskID).Update("courier_id", courier.UserID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyRelay(courier.UserID, "RELAY_LEG_ASSIGNED", &leg, nil)
+	if previousCourierID != nil && *previousCourierID != courier.UserID {
+		s.notifyRelay(*previousCourierID, "RELAY_LEG_UNASSIGNED", &leg, nil)
+	}
+
+	return &leg, nil
+}
+
+// CollectLetter 第一段信使收件扫码，信件进入接力保管
+func (s *RelayService) CollectLetter(courierID, taskID string, req *models.RelayScanRequest) (*models.RelayChain, error) {
+	var first models.RelayLeg
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		task, err := s.lockTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.IsRelay {
+			return ErrRelayNotRelayTask
+		}
+		// 拒绝伪造或复印的标签
+		if err := s.verifyScanToken(task.LetterID, req.QRToken); err != nil {
+			return err
+		}
+
+		legs, err := s.loadLegs(tx, taskID)
+		if err != nil {
+			return err
+		}
+		first = legs[0]
+		if first.Status != models.RelayLegStatusPending || !task.CanTransitionTo(models.TaskStatusCollected) {
+			return ErrRelayInvalidState
+		}
+		if err := s.claimLeg(tx, &first, courierID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&first).Updates(map[string]interface{}{
+			"courier_id":       courierID,
+			"status":           models.RelayLegStatusInCustody,
+			"custody_start_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		opCode := req.OPCode
+		if opCode == "" {
+			opCode = task.PickupOPCode
+		}
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", taskID).Updates(map[string]interface{}{
+			"status":          models.TaskStatusCollected,
+			"collected_at":    &now,
+			"courier_id":      courierID,
+			"current_op_code": opCode,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordTaskSyncEvent(tx, taskID, req.Note); err != nil {
+			return err
+		}
+
+		return s.createRelayScanRecord(tx, task, courierID, models.TaskStatusCollected, opCode, req, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.wsManager.SendTaskUpdate(taskID, models.TaskStatusCollected, courierID)
+	return s.GetRelayChain(taskID)
+}
+
+// IssueHandoffCode 当前保管信使申请交接码，交接时当面告知接手信使；重新申请后旧码失效
+func (s *RelayService) IssueHandoffCode(courierID, taskID string) (*models.RelayHandoffCode, error) {
+	code, err := generateHandoffCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var issued *models.RelayHandoffCode
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		task, err := s.lockTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.IsRelay {
+			return ErrRelayNotRelayTask
+		}
+		legs, err := s.loadLegs(tx, taskID)
+		if err != nil {
+			return err
+		}
+		current := currentRelayLeg(legs)
+		if current == nil || current.Sequence >= len(legs) {
+			return ErrRelayInvalidState
+		}
+		if current.CourierID == nil || *current.CourierID != courierID {
+			return ErrRelayNotLegCourier
+		}
+
+		expiresAt := time.Now().Add(relayHandoffCodeTTL)
+		if err := tx.Model(current).Updates(map[string]interface{}{
+			"handoff_code_hash":       hashHandoffCode(current.ID, code),
+			"handoff_code_expires_at": &expiresAt,
+			"handoff_code_attempts":   0,
+		}).Error; err != nil {
+			return err
+		}
+		issued = &models.RelayHandoffCode{
+			TaskID:    taskID,
+			LegID:     current.ID,
+			Code:      code,
+			ExpiresAt: expiresAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// HandOff 下一段信使扫码并输入交出信使出示的交接码接手，结束当前段保管并记录交接
+func (s *RelayService) HandOff(receiverID, taskID string, req *models.RelayScanRequest) (*models.RelayChain, error) {
+	if strings.TrimSpace(req.HandoffCode) == "" {
+		return nil, ErrRelayHandoffCodeInvalid
+	}
+	// 先原子地占用一次尝试机会再核对，交接码无法被穷举
+	result := s.db.Model(&models.RelayLeg{}).
+		Where("task_id = ? AND status = ? AND handoff_code_hash <> '' AND handoff_code_attempts < ?",
+			taskID, models.RelayLegStatusInCustody, relayHandoffCodeAttempts).
+		UpdateColumn("handoff_code_attempts", gorm.Expr("handoff_code_attempts + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRelayHandoffCodeInvalid
+	}
+
+	var handoff *models.RelayHandoff
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		task, err := s.lockTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.IsRelay {
+			return ErrRelayNotRelayTask
+		}
+		// 拒绝伪造或复印的标签
+		if err := s.verifyScanToken(task.LetterID, req.QRToken); err != nil {
+			return err
+		}
+
+		legs, err := s.loadLegs(tx, taskID)
+		if err != nil {
+			return err
+		}
+		current := currentRelayLeg(legs)
+		if current == nil || current.Sequence >= len(legs) {
+			return ErrRelayInvalidState
+		}
+		next := &legs[current.Sequence]
+		// 交出信使以当前段的保管人为准，交接码证明其本人同意交出
+		if current.CourierID == nil || (req.FromCourierID != "" && *current.CourierID != req.FromCourierID) {
+			return ErrRelayFromCourierMismatch
+		}
+		fromCourierID := *current.CourierID
+		if fromCourierID == receiverID {
+			return ErrRelayInvalidState
+		}
+		if !handoffCodeMatches(current, strings.TrimSpace(req.HandoffCode), time.Now()) {
+			return ErrRelayHandoffCodeInvalid
+		}
+		if err := s.claimLeg(tx, next, receiverID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(current).Updates(map[string]interface{}{
+			"status":                  models.RelayLegStatusHandedOff,
+			"custody_end_at":          &now,
+			"handoff_code_hash":       "",
+			"handoff_code_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(next).Updates(map[string]interface{}{
+			"courier_id":       receiverID,
+			"status":           models.RelayLegStatusInCustody,
+			"custody_start_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		opCode := req.OPCode
+		if opCode == "" {
+			opCode = task.CurrentOPCode
+		}
+		handoff = &models.RelayHandoff{
+			ID:            uuid.New().String(),
+			TaskID:        taskID,
+			LetterID:      task.LetterID,
+			FromLegID:     current.ID,
+			ToLegID:       next.ID,
+			FromCourierID: fromCourierID,
+			ToCourierID:   receiverID,
+			OPCode:        opCode,
+			Location:      req.Location,
+			Latitude:      req.Latitude,
+			Longitude:     req.Longitude,
+			Note:          req.Note,
+			HandedOffAt:   now,
+		}
+		if err := tx.Create(handoff).Error; err != nil {
+			return err
+		}
+
+		// 父任务的信使始终指向当前保管人，便于扫码历史和路线规划沿用
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", taskID).Updates(map[string]interface{}{
+			"status":          models.TaskStatusInTransit,
+			"courier_id":      receiverID,
+			"current_op_code": opCode,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordTaskSyncEvent(tx, taskID, req.Note); err != nil {
+			return err
+		}
+
+		return s.createRelayScanRecord(tx, task, receiverID, models.TaskStatusInTransit, opCode, req, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.wsManager.SendTaskUpdate(taskID, models.TaskStatusInTransit, receiverID)
+	s.notifyRelay(handoff.FromCourierID, "RELAY_HANDED_OFF", nil, handoff)
+	s.notifyRelay(handoff.ToCourierID, "RELAY_HANDED_OFF", nil, handoff)
+
+	return s.GetRelayChain(taskID)
+}
+
+// DeliverLetter 最后一段信使投递扫码，完成整条接力链
+func (s *RelayService) DeliverLetter(courierID, taskID string, req *models.RelayScanRequest) (*models.RelayChain, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		task, err := s.lockTask(tx, taskID)
+		if err != nil {
+			return err
+		}
+		if !task.IsRelay {
+			return ErrRelayNotRelayTask
+		}
+		// 拒绝伪造或复印的标签
+		if err := s.verifyScanToken(task.LetterID, req.QRToken); err != nil {
+			return err
+		}
+
+		legs, err := s.loadLegs(tx, taskID)
+		if err != nil {
+			return err
+		}
+		current := currentRelayLeg(legs)
+		if current == nil || current.Sequence != len(legs) || !task.CanTransitionTo(models.TaskStatusDelivered) {
+			return ErrRelayInvalidState
+		}
+		if current.CourierID == nil || *current.CourierID != courierID {
+			return ErrRelayNotLegCourier
+		}
+		if _, err := s.receipts.Require(tx, task.LetterID, courierID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(current).Updates(map[string]interface{}{
+			"status":         models.RelayLegStatusDelivered,
+			"custody_end_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		opCode := req.OPCode
+		if opCode == "" {
+			opCode = task.DeliveryOPCode
+		}
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", taskID).Updates(map[string]interface{}{
+			"status":          models.TaskStatusDelivered,
+			"completed_at":    &now,
+			"current_op_code": opCode,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordTaskSyncEvent(tx, taskID, req.Note); err != nil {
+			return err
+		}
+
+		return s.createRelayScanRecord(tx, task, courierID, models.TaskStatusDelivered, opCode, req, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.wsManager.SendTaskUpdate(taskID, models.TaskStatusDelivered, courierID)
+	return s.GetRelayChain(taskID)
+}
+
+// GetRelayChain 获取接力链详情及当前保管人
+func (s *RelayService) GetRelayChain(taskID string) (*models.RelayChain, error) {
+	var task models.Task
+	if err := s.db.Where("task_id = ?", taskID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRelayTaskNotFound
+		}
+		return nil, err
+	}
+	if !task.IsRelay {
+		return nil, ErrRelayNotRelayTask
+	}
+
+	legs, err := s.loadLegs(s.db, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var handoffs []models.RelayHandoff
+	if err := s.db.Where("task_id = ?", taskID).Order("handed_off_at ASC").Find(&handoffs).Error; err != nil {
+		return nil, err
+	}
+
+	chain := &models.RelayChain{
+		Task:     &task,
+		Legs:     legs,
+		Handoffs: handoffs,
+	}
+	if current := currentRelayLeg(legs); current != nil {
+		chain.CurrentLeg = current
+		chain.CustodyCourierID = current.CourierID
+	}
+
+	return chain, nil
+}
+
+// GetCourierLegs 获取信使承接的接力段及已获得的接力奖励
+func (s *RelayService) GetCourierLegs(courierID, status string, limit, offset int) (*models.RelayLegListResponse, error) {
+	query := s.db.Model(&models.RelayLeg{}).Where("courier_id = ?", courierID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var legs []models.RelayLeg
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&legs).Error; err != nil {
+		return nil, err
+	}
+
+	var earned float64
+	if err := s.db.Model(&models.RelayLeg{}).
+		Where("courier_id = ? AND status IN ?", courierID, []string{models.RelayLegStatusHandedOff, models.RelayLegStatusDelivered}).
+		Select("COALESCE(SUM(reward), 0)").Scan(&earned).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.RelayLegListResponse{
+		Legs:         legs,
+		Total:        total,
+		EarnedReward: earned,
+	}, nil
+}
+
+// 私有方法
+
+// buildRelayLegs 按层级生成接力段，并按比例拆分任务奖励
+func buildRelayLegs(task *models.Task) []models.RelayLeg {
+	originSchool := task.PickupOPCode[:2]
+	destSchool := task.DeliveryOPCode[:2]
+
+	plan := []struct {
+		legType  string
+		from, to string
+	}{
+		{models.RelayLegCollection, task.PickupOPCode, originSchool},
+		{models.RelayLegConsolidation, originSchool, originSchool},
+		{models.RelayLegTransfer, originSchool, destSchool},
+		{models.RelayLegDelivery, destSchool, task.DeliveryOPCode},
+	}
+
+	legs := make([]models.RelayLeg, len(plan))
+	allocated := 0.0
+	for i, p := range plan {
+		reward := math.Round(task.Reward*models.RelayLegRewardShare[p.legType]*100) / 100
+		if i == len(plan)-1 {
+			// 最后一段补齐舍入误差，保证各段之和等于任务奖励
+			reward = math.Round((task.Reward-allocated)*100) / 100
+		}
+		allocated += reward
+
+		legs[i] = models.RelayLeg{
+			ID:         uuid.New().String(),
+			TaskID:     task.TaskID,
+			LetterID:   task.LetterID,
+			Sequence:   i + 1,
+			LegType:    p.legType,
+			FromOPCode: p.from,
+			ToOPCode:   p.to,
+			Status:     models.RelayLegStatusPending,
+			Reward:     reward,
+		}
+	}
+
+	return legs
+}
+
+// verifyScanToken 校验扫码携带的签名令牌
+func (s *RelayService) verifyScanToken(letterCode, token string) error {
+	if s.qrVerifier == nil {
+		return nil
+	}
+	_, err := s.qrVerifier.Verify(token, letterCode, time.Now())
+	return err
+}
+
+// findRelayCourier 按等级优先顺序查找管辖该段的信使
+// 同等级内优先OP Code前缀更精确、未完成接力段更少、评分更高的信使
+func (s *RelayService) findRelayCourier(tx *gorm.DB, leg *models.RelayLeg) (*models.Courier, error) {
+	scope := relayLegScope(leg)
+
+	for _, level := range models.RelayLegCandidateLevels[leg.LegType] {
+		var couriers []models.Courier
+		if err := tx.Where("level = ? AND status = ?", level, models.CourierStatusApproved).Find(&couriers).Error; err != nil {
+			return nil, err
+		}
+
+		candidates := make([]models.Courier, 0, len(couriers))
+		for _, courier := range couriers {
+			if courierCoversOPCode(&courier, scope) {
+				candidates = append(candidates, courier)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		openLegs := make(map[string]int64, len(candidates))
+		for _, courier := range candidates {
+			var count int64
+			if err := tx.Model(&models.RelayLeg{}).
+				Where("courier_id = ? AND status IN ?", courier.UserID, []string{models.RelayLegStatusPending, models.RelayLegStatusInCustody}).
+				Count(&count).Error; err != nil {
+				return nil, err
+			}
+			openLegs[courier.UserID] = count
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if len(a.ManagedOPCodePrefix) != len(b.ManagedOPCodePrefix) {
+				return len(a.ManagedOPCodePrefix) > len(b.ManagedOPCodePrefix)
+			}
+			if openLegs[a.UserID] != openLegs[b.UserID] {
+				return openLegs[a.UserID] < openLegs[b.UserID]
+			}
+			return a.Rating > b.Rating
+		})
+
+		return &candidates[0], nil
+	}
+
+	return nil, nil
+}
+
+// claimLeg 校验扫码信使是否为该段信使，未分配的段由符合条件的信使直接认领
+func (s *RelayService) claimLeg(tx *gorm.DB, leg *models.RelayLeg, courierID string) error {
+	if leg.CourierID != nil {
+		if *leg.CourierID != courierID {
+			return ErrRelayNotLegCourier
+		}
+		return nil
+	}
+
+	var courier models.Courier
+	if err := tx.Where("user_id = ?", courierID).First(&courier).Error; err != nil {
+		return ErrRelayCourierNotEligible
+	}
+	if !isRelayLegEligible(&courier, leg) {
+		return ErrRelayCourierNotEligible
+	}
+	return nil
+}
+
+// generateHandoffCode 生成6位数字交接码
+func generateHandoffCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashHandoffCode 交接码只保存与接力段绑定的摘要
+func hashHandoffCode(legID, code string) string {
+	sum := sha256.Sum256([]byte(legID + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// handoffCodeMatches 核对交接码及有效期
+func handoffCodeMatches(leg *models.RelayLeg, code string, now time.Time) bool {
+	if leg.HandoffCodeHash == "" || leg.HandoffCodeExpiresAt == nil || now.After(*leg.HandoffCodeExpiresAt) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashHandoffCode(leg.ID, code)), []byte(leg.HandoffCodeHash)) == 1
+}
+
+// lockTask 锁定父任务，同一任务的接力操作串行执行
+func (s *RelayService) lockTask(tx *gorm.DB, taskID string) (*models.Task, error) {
+	var task models.Task
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("task_id = ?", taskID).First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRelayTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// loadLegs 按顺序加载任务的接力段
+func (s *RelayService) loadLegs(tx *gorm.DB, taskID string) ([]models.RelayLeg, error) {
+	var legs []models.RelayLeg
+	if err := tx.Where("task_id = ?", taskID).Order("sequence ASC").Find(&legs).Error; err != nil {
+		return nil, err
+	}
+	if len(legs) == 0 {
+		return nil, ErrRelayNotRelayTask
+	}
+	return legs, nil
+}
+
+// createRelayScanRecord 为接力扫码写入扫码记录，保持扫码历史完整
+func (s *RelayService) createRelayScanRecord(tx *gorm.DB, task *models.Task, courierID, action, opCode string, req *models.RelayScanRequest, now time.Time) error {
+	record := &models.ScanRecord{
+		ID:             uuid.New().String(),
+		TaskID:         task.TaskID,
+		CourierID:      courierID,
+		LetterID:       task.LetterID,
+		Action:         action,
+		Location:       req.Location,
+		Latitude:       req.Latitude,
+		Longitude:      req.Longitude,
+		Note:           req.Note,
+		Timestamp:      now,
+		OperatorOPCode: opCode,
+	}
+	return tx.Create(record).Error
+}
+
+// notifyRelay 通知信使接力段变化
+func (s *RelayService) notifyRelay(userID, eventType string, leg *models.RelayLeg, handoff *models.RelayHandoff) {
+	data := map[string]interface{}{}
+	if leg != nil {
+		data["task_id"] = leg.TaskID
+		data["leg_id"] = leg.ID
+		data["sequence"] = leg.Sequence
+		data["leg_type"] = leg.LegType
+		data["reward"] = leg.Reward
+	}
+	if handoff != nil {
+		data["task_id"] = handoff.TaskID
+		data["handoff_id"] = handoff.ID
+		data["from_courier_id"] = handoff.FromCourierID
+		data["to_courier_id"] = handoff.ToCourierID
+		data["op_code"] = handoff.OPCode
+	}
+
+	s.wsManager.BroadcastToUser(userID, utils.WebSocketEvent{
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// getCourierByUserID 根据用户ID获取信使
+func (s *RelayService) getCourierByUserID(userID string) (*models.Courier, error) {
+	var courier models.Courier
+	if err := s.db.Where("user_id = ?", userID).First(&courier).Error; err != nil {
+		return nil, err
+	}
+	return &courier, nil
+}
+
+// currentRelayLeg 返回当前保管信件的接力段
+func currentRelayLeg(legs []models.RelayLeg) *models.RelayLeg {
+	for i := range legs {
+		if legs[i].Status == models.RelayLegStatusInCustody {
+			return &legs[i]
+		}
+	}
+	return nil
+}
+
+// relayLegScope 匹配信使时使用的OP Code，投递段按送达地址，其余按起点
+func relayLegScope(leg *models.RelayLeg) string {
+	if leg.LegType == models.RelayLegDelivery {
+		return leg.ToOPCode
+	}
+	return leg.FromOPCode
+}
+
+// isRelayLegEligible 检查信使等级和管辖范围是否可承接该段
+func isRelayLegEligible(courier *models.Courier, leg *models.RelayLeg) bool {
+	if !courier.IsActive() {
+		return false
+	}
+	for _, level := range models.RelayLegCandidateLevels[leg.LegType] {
+		if courier.Level == level {
+			return courierCoversOPCode(courier, relayLegScope(leg))
+		}
+	}
+	return false
+}
+
+// courierCoversOPCode 检查OP Code是否在信使管辖前缀内，未设置前缀的只有城市级信使视为全城
+func courierCoversOPCode(courier *models.Courier, opCode string) bool {
+	if courier.ManagedOPCodePrefix == "" {
+		return courier.Level == models.CourierLevelFour
+	}
+	return strings.HasPrefix(opCode, courier.ManagedOPCodePrefix)
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRouteSpeedKmh       = 15.0 // 与EstimateDeliveryTime一致：校园步行+等待
+	defaultRouteServiceMinutes = 2    // 每站取件/投递停留时间
+	routeLatePenalty           = 10.0 // 每迟到1分钟折算的路程分钟数
+	routeMaxImprovePasses      = 50
+	routeOrOptMaxSegment       = 3
+)
+
+// routeNode 路线规划中的站点
+type routeNode struct {
+	task     *models.Task
+	stopType string
+	lat      float64
+	lng      float64
+	pickup   int // 送达站点对应的取件站点下标，无需取件时为-1
+}
+
+// routeProblem 一次路线规划的输入与预计算距离
+type routeProblem struct {
+	nodes       []routeNode
+	originDist  []float64   // 起点到各站点距离(km)
+	dist        [][]float64 // 站点间距离(km)
+	speedKmh    float64
+	serviceMins float64
+	start       time.Time
+}
+
+// RoutePlannerService 信使多站点路线规划服务
+type RoutePlannerService struct {
+	db              *gorm.DB
+	locationService *LocationService
+}
+
+// NewRoutePlannerService 创建路线规划服务实例
+func NewRoutePlannerService(db *gorm.DB, locationService *LocationService) *RoutePlannerService {
+	return &RoutePlannerService{
+		db:              db,
+		locationService: locationService,
+	}
+}
+
+// PlanRoute 为信使已接取的任务规划取件/送达顺序
+// 先用最近邻构造满足"先取后送"的初始路线，再用2-opt与Or-opt改进，目标为总耗时+迟到惩罚
+func (s *RoutePlannerService) PlanRoute(courierID string, query *models.RoutePlanQuery) (*models.RoutePlan, error) {
+	var tasks []models.Task
+	err := s.db.Where("courier_id = ? AND status IN ?", courierID, []string{
+		models.TaskStatusAccepted,
+		models.TaskStatusCollected,
+		models.TaskStatusInTransit,
+	}).Order("accepted_at ASC").Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	speed := query.SpeedKmh
+	if speed <= 0 {
+		speed = defaultRouteSpeedKmh
+	}
+	serviceMins := query.ServiceMinutes
+	if serviceMins <= 0 {
+		serviceMins = defaultRouteServiceMinutes
+	}
+
+	problem := &routeProblem{
+		speedKmh:    speed,
+		serviceMins: float64(serviceMins),
+		start:       time.Now(),
+	}
+	plan := &models.RoutePlan{
+		CourierID:   courierID,
+		OriginLat:   query.Lat,
+		OriginLng:   query.Lng,
+		Stops:       []models.RouteStop{},
+		GeneratedAt: problem.start,
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		needsPickup := task.Status == models.TaskStatusAccepted
+		if !hasCoordinate(task.DeliveryLat, task.DeliveryLng) ||
+			(needsPickup && !hasCoordinate(task.PickupLat, task.PickupLng)) {
+			plan.UnplannedTaskIDs = append(plan.UnplannedTaskIDs, task.TaskID)
+			continue
+		}
+
+		pickup := -1
+		if needsPickup {
+			pickup = len(problem.nodes)
+			problem.nodes = append(problem.nodes, routeNode{
+				task: task, stopType: models.RouteStopPickup,
+				lat: task.PickupLat, lng: task.PickupLng, pickup: -1,
+			})
+		}
+		problem.nodes = append(problem.nodes, routeNode{
+			task: task, stopType: models.RouteStopDelivery,
+			lat: task.DeliveryLat, lng: task.DeliveryLng, pickup: pickup,
+		})
+	}
+
+	if len(problem.nodes) == 0 {
+		plan.EstimatedFinishAt = problem.start
+		return plan, nil
+	}
+
+	s.buildDistances(problem, query.Lat, query.Lng)
+
+	// 基线：按接单顺序逐单取件、送达
+	baseline := make([]int, len(problem.nodes))
+	for i := range baseline {
+		baseline[i] = i
+	}
+	plan.BaselineDistanceKm = roundKm(problem.distance(baseline))
+
+	route := problem.nearestNeighbour()
+	route = problem.improve(route)
+	if problem.cost(baseline) < problem.cost(route) {
+		route = baseline
+	}
+
+	s.fillPlan(plan, problem, route)
+	return plan, nil
+}
+
+// buildDistances 预计算起点与站点间的距离
+func (s *RoutePlannerService) buildDistances(p *routeProblem, lat, lng float64) {
+	n := len(p.nodes)
+	p.originDist = make([]float64, n)
+	p.dist = make([][]float64, n)
+	for i := range p.nodes {
+		p.originDist[i] = s.locationService.CalculateDistance(lat, lng, p.nodes[i].lat, p.nodes[i].lng)
+		p.dist[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := s.locationService.CalculateDistance(p.nodes[i].lat, p.nodes[i].lng, p.nodes[j].lat, p.nodes[j].lng)
+			p.dist[i][j] = d
+			p.dist[j][i] = d
+		}
+	}
+}
+
+// fillPlan 根据站点顺序计算每站距离与ETA
+func (s *RoutePlannerService) fillPlan(plan *models.RoutePlan, p *routeProblem, route []int) {
+	elapsed := 0.0
+	total := 0.0
+	for i, idx := range route {
+		leg := p.originDist[idx]
+		if i > 0 {
+			leg = p.dist[route[i-1]][idx]
+		}
+		total += leg
+		elapsed += p.travelMinutes(leg)
+
+		node := p.nodes[idx]
+		stop := models.RouteStop{
+			Sequence:             i + 1,
+			TaskID:               node.task.TaskID,
+			LetterID:             node.task.LetterID,
+			StopType:             node.stopType,
+			Lat:                  node.lat,
+			Lng:                  node.lng,
+			LegDistanceKm:        roundKm(leg),
+			LegDistance:          s.locationService.FormatDistance(leg),
+			CumulativeDistanceKm: roundKm(total),
+			ETA:                  p.start.Add(time.Duration(elapsed * float64(time.Minute))),
+		}
+		if node.stopType == models.RouteStopPickup {
+			stop.Location = node.task.PickupLocation
+			stop.OPCode = node.task.PickupOPCode
+		} else {
+			stop.Location = node.task.DeliveryLocation
+			stop.OPCode = node.task.DeliveryOPCode
+			stop.Deadline = node.task.Deadline
+			if stop.Deadline != nil && stop.ETA.After(*stop.Deadline) {
+				stop.Late = true
+				plan.LateStops++
+			}
+		}
+		plan.Stops = append(plan.Stops, stop)
+		elapsed += p.serviceMins
+	}
+
+	plan.TotalDistanceKm = roundKm(total)
+	plan.TotalDistance = s.locationService.FormatDistance(total)
+	plan.TotalMinutes = int(math.Ceil(elapsed))
+	plan.EstimatedFinishAt = p.start.Add(time.Duration(elapsed * float64(time.Minute)))
+}
+
+// travelMinutes 行走距离所需分钟数
+func (p *routeProblem) travelMinutes(km float64) float64 {
+	return km / p.speedKmh * 60
+}
+
+// legDistance 路线中第i站与上一站（或起点）的距离
+func (p *routeProblem) legDistance(route []int, i int) float64 {
+	if i == 0 {
+		return p.originDist[route[0]]
+	}
+	return p.dist[route[i-1]][route[i]]
+}
+
+// distance 路线总距离
+func (p *routeProblem) distance(route []int) float64 {
+	total := 0.0
+	for i := range route {
+		total += p.legDistance(route, i)
+	}
+	return total
+}
+
+// cost 路线代价：总耗时（分钟）+ 送达迟到分钟数×惩罚系数
+func (p *routeProblem) cost(route []int) float64 {
+	elapsed := 0.0
+	late := 0.0
+	for i, idx := range route {
+		elapsed += p.travelMinutes(p.legDistance(route, i))
+		node := p.nodes[idx]
+		if node.stopType == models.RouteStopDelivery && node.task.Deadline != nil {
+			slack := node.task.Deadline.Sub(p.start).Minutes()
+			if elapsed > slack {
+				late += elapsed - slack
+			}
+		}
+		elapsed += p.serviceMins
+	}
+	return elapsed + late*routeLatePenalty
+}
+
+// feasible 检查每个任务都先取件后送达
+func (p *routeProblem) feasible(route []int) bool {
+	seen := make([]bool, len(p.nodes))
+	for _, idx := range route {
+		if pickup := p.nodes[idx].pickup; pickup >= 0 && !seen[pickup] {
+			return false
+		}
+		seen[idx] = true
+	}
+	return true
+}
+
+// nearestNeighbour 最近邻构造初始路线，送达站点在取件后才可选
+func (p *routeProblem) nearestNeighbour() []int {
+	n := len(p.nodes)
+	visited := make([]bool, n)
+	route := make([]int, 0, n)
+	for len(route) < n {
+		best, bestDist := -1, math.MaxFloat64
+		for i := range p.nodes {
+			if visited[i] {
+				continue
+			}
+			if pickup := p.nodes[i].pickup; pickup >= 0 && !visited[pickup] {
+				continue
+			}
+			d := p.originDist[i]
+			if len(route) > 0 {
+				d = p.dist[route[len(route)-1]][i]
+			}
+			if d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		visited[best] = true
+		route = append(route, best)
+	}
+	return route
+}
+
+// improve 交替使用2-opt与Or-opt局部搜索直到无法改进
+func (p *routeProblem) improve(route []int) []int {
+	best := append([]int(nil), route...)
+	bestCost := p.cost(best)
+	candidate := make([]int, len(best))
+
+	for pass := 0; pass < routeMaxImprovePasses; pass++ {
+		improved := false
+
+		// 2-opt：反转一段路线
+		for i := 0; i < len(best)-1; i++ {
+			for j := i + 1; j < len(best); j++ {
+				copy(candidate, best)
+				for l, r := i, j; l < r; l, r = l+1, r-1 {
+					candidate[l], candidate[r] = candidate[r], candidate[l]
+				}
+				if c := p.cost(candidate); c < bestCost-1e-9 && p.feasible(candidate) {
+					copy(best, candidate)
+					bestCost = c
+					improved = true
+				}
+			}
+		}
+
+		// Or-opt：把连续1~3个站点移动到其他位置
+		for seg := 1; seg <= routeOrOptMaxSegment && seg < len(best); seg++ {
+			for i := 0; i+seg <= len(best); i++ {
+				rest := make([]int, 0, len(best)-seg)
+				rest = append(rest, best[:i]...)
+				rest = append(rest, best[i+seg:]...)
+				segment := append([]int(nil), best[i:i+seg]...)
+
+				for k := 0; k <= len(rest); k++ {
+					if k == i {
+						continue
+					}
+					candidate = candidate[:0]
+					candidate = append(candidate, rest[:k]...)
+					candidate = append(candidate, segment...)
+					candidate = append(candidate, rest[k:]...)
+					if c := p.cost(candidate); c < bestCost-1e-9 && p.feasible(candidate) {
+						copy(best, candidate)
+						bestCost = c
+						improved = true
+						break
+					}
+				}
+			}
+		}
+
+		if !improved {
+			break
+		}
+	}
+	return best
+}
+
+// hasCoordinate 检查坐标是否已设置
+func hasCoordinate(lat, lng float64) bool {
+	return lat != 0 || lng != 0
+}
+
+// roundKm 距离保留3位小数
+func roundKm(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
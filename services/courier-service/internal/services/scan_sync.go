@@ -0,0 +1,286 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	scanSyncMaxClockSkew  = 5 * time.Minute    // 允许设备时间略快于服务器
+	scanSyncMaxOfflineAge = 7 * 24 * time.Hour // 超过该时长的离线扫码不再接受
+)
+
+// scanStatusRank 任务在取送流程中的先后顺序，用于判断离线扫码是否已被后续状态覆盖
+var scanStatusRank = map[string]int{
+	models.TaskStatusAccepted:  1,
+	models.TaskStatusCollected: 2,
+	models.TaskStatusInTransit: 3,
+	models.TaskStatusDelivered: 4,
+	models.TaskStatusFailed:    4,
+}
+
+// SyncOfflineScans 批量同步离线扫码
+// 事件按提交顺序逐条在独立事务中处理，同一 client_event_id 重复提交时返回首次结果
+func (s *TaskService) SyncOfflineScans(courierID string, request *models.ScanSyncRequest) (*models.ScanSyncResponse, error) {
+	now := time.Now()
+	response := &models.ScanSyncResponse{
+		Results:    make([]models.ScanSyncResult, 0, len(request.Events)),
+		ServerTime: now,
+	}
+
+	for i := range request.Events {
+		result, err := s.syncOfflineScan(courierID, request.DeviceID, &request.Events[i], now)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.Result {
+		case models.ScanSyncApplied:
+			response.Applied++
+			if result.failure != nil {
+				s.failures.afterFailure(result.task, result.failure)
+			} else {
+				s.wsManager.SendTaskUpdate(result.taskID, result.NewStatus, courierID)
+			}
+		case models.ScanSyncDuplicate:
+			response.Duplicates++
+		case models.ScanSyncSkipped:
+			response.Skipped++
+		case models.ScanSyncConflict:
+			response.Conflicts++
+		case models.ScanSyncRejected:
+			response.Rejected++
+		}
+		response.Results = append(response.Results, result.ScanSyncResult)
+	}
+
+	return response, nil
+}
+
+// scanSyncOutcome 单条事件处理结果及通知所需的任务信息
+type scanSyncOutcome struct {
+	models.ScanSyncResult
+	taskID  string
+	task    *models.Task
+	failure *models.DeliveryFailureResult // 失败扫码的登记结果
+}
+
+// syncOfflineScan 处理单条离线扫码事件
+func (s *TaskService) syncOfflineScan(courierID, deviceID string, event *models.ScanSyncEventInput, now time.Time) (*scanSyncOutcome, error) {
+	outcome := &scanSyncOutcome{ScanSyncResult: models.ScanSyncResult{
+		ClientEventID: event.ClientEventID,
+		LetterCode:    event.LetterCode,
+		DeviceTime:    event.DeviceTime,
+	}}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.ScanSyncEvent
+		err := tx.Where("courier_id = ? AND client_event_id = ?", courierID, event.ClientEventID).First(&existing).Error
+		if err == nil {
+			outcome.Result = models.ScanSyncDuplicate
+			outcome.FirstResult = existing.Result
+			outcome.Reason = existing.Reason
+			outcome.OldStatus = existing.OldStatus
+			outcome.NewStatus = existing.NewStatus
+			outcome.ScanRecordID = existing.ScanRecordID
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		record := &models.ScanSyncEvent{
+			ID:            uuid.New().String(),
+			CourierID:     courierID,
+			ClientEventID: event.ClientEventID,
+			DeviceID:      deviceID,
+			LetterID:      event.LetterCode,
+			Action:        event.Action,
+			DeviceTime:    event.DeviceTime,
+		}
+
+		failure, err := s.applyOfflineScan(tx, courierID, deviceID, event, record, now)
+		if err != nil {
+			return err
+		}
+		if failure != nil {
+			outcome.failure = failure
+			outcome.task = &models.Task{}
+			if err := tx.Where("task_id = ?", record.TaskID).First(outcome.task).Error; err != nil {
+				return err
+			}
+		}
+
+		outcome.Result = record.Result
+		outcome.Reason = record.Reason
+		outcome.OldStatus = record.OldStatus
+		outcome.NewStatus = record.NewStatus
+		outcome.ScanRecordID = record.ScanRecordID
+		outcome.taskID = record.TaskID
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return outcome, nil
+}
+
+// applyOfflineScan 按任务状态流转表校验并应用离线扫码，结果写入 record
+func (s *TaskService) applyOfflineScan(tx *gorm.DB, courierID, deviceID string, event *models.ScanSyncEventInput, record *models.ScanSyncEvent, now time.Time) (*models.DeliveryFailureResult, error) {
+	if event.DeviceTime.After(now.Add(scanSyncMaxClockSkew)) {
+		record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonFutureDeviceTime
+		return nil, nil
+	}
+	if event.DeviceTime.Before(now.Add(-scanSyncMaxOfflineAge)) {
+		record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonStaleDeviceTime
+		return nil, nil
+	}
+	// 令牌有效期按设备上的扫码时间判断
+	if err := s.VerifyScanToken(event.LetterCode, event.QRToken, event.DeviceTime); err != nil {
+		record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonInvalidQRToken
+		return nil, nil
+	}
+
+	var task models.Task
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("letter_id = ? AND status <> ?", event.LetterCode, models.TaskStatusReturned).
+		Order("created_at DESC").First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonTaskNotFound
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.TaskID = task.TaskID
+	record.OldStatus = task.Status
+
+	// 离线期间任务被取消或改派给其他信使
+	if !task.IsAssigned() {
+		record.Result, record.Reason = models.ScanSyncConflict, models.ScanSyncReasonNotAssigned
+		return nil, nil
+	}
+	if *task.CourierID != courierID {
+		record.Result, record.Reason = models.ScanSyncConflict, models.ScanSyncReasonReassigned
+		return nil, nil
+	}
+	if task.IsRelay {
+		record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonRelayTask
+		return nil, nil
+	}
+	if task.AcceptedAt != nil && event.DeviceTime.Before(*task.AcceptedAt) {
+		record.Result, record.Reason = models.ScanSyncConflict, models.ScanSyncReasonBeforeAssignment
+		return nil, nil
+	}
+
+	targetStatus := models.ActionToStatus[event.Action]
+	if task.Status == targetStatus {
+		record.Result, record.Reason = models.ScanSyncSkipped, models.ScanSyncReasonAlreadyInStatus
+		return nil, nil
+	}
+	if !task.CanTransitionTo(targetStatus) {
+		if scanStatusRank[targetStatus] <= scanStatusRank[task.Status] {
+			record.Result, record.Reason = models.ScanSyncSkipped, models.ScanSyncReasonSuperseded
+		} else {
+			record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonInvalidTransition
+		}
+		return nil, nil
+	}
+
+	if targetStatus == models.TaskStatusDelivered {
+		_, err := s.receipts.Require(tx, event.LetterCode, courierID)
+		if errors.Is(err, ErrDeliveryReceiptRequired) {
+			record.Result, record.Reason = models.ScanSyncRejected, models.ScanSyncReasonReceiptRequired
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var failure *models.DeliveryFailureResult
+	if targetStatus == models.TaskStatusFailed && s.failures != nil {
+		// 失败扫码与在线上报一样按原因登记，由重投策略决定重投或退回
+		reason := event.FailureReason
+		if reason == "" {
+			reason = models.FailureReasonOther
+		}
+		failure, err = s.failures.applyFailure(tx, &task, courierID, &models.DeliveryFailureRequest{
+			Reason:    reason,
+			Note:      event.Note,
+			PhotoURL:  event.PhotoURL,
+			OPCode:    event.OperatorOPCode,
+			Location:  event.Location,
+			Latitude:  event.Latitude,
+			Longitude: event.Longitude,
+		})
+		if err != nil {
+			return nil, err
+		}
+		switch failure.NextAction {
+		case models.FailureActionReturnToSender:
+			targetStatus = models.TaskStatusReturned
+		case models.FailureActionHoldAtStation:
+			targetStatus = models.TaskStatusHeld
+		}
+	} else {
+		updates := map[string]interface{}{
+			"status": targetStatus,
+		}
+		scannedAt := event.DeviceTime
+		switch targetStatus {
+		case models.TaskStatusCollected:
+			updates["collected_at"] = &scannedAt
+		case models.TaskStatusDelivered:
+			updates["completed_at"] = &scannedAt
+		}
+		if event.OperatorOPCode != "" {
+			updates["current_op_code"] = event.OperatorOPCode
+		}
+		if err := tx.Model(&models.Task{}).Where("task_id = ?", task.TaskID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		if err := recordTaskSyncEvent(tx, task.TaskID, "离线扫码补传"); err != nil {
+			return nil, err
+		}
+	}
+
+	deviceInfo, _ := json.Marshal(map[string]interface{}{
+		"device_id": deviceID,
+		"source":    "offline_sync",
+	})
+	syncedAt := now
+	scanRecord := &models.ScanRecord{
+		ID:             uuid.New().String(),
+		TaskID:         task.TaskID,
+		CourierID:      courierID,
+		LetterID:       event.LetterCode,
+		Action:         event.Action,
+		Location:       event.Location,
+		Latitude:       event.Latitude,
+		Longitude:      event.Longitude,
+		Note:           event.Note,
+		PhotoURL:       event.PhotoURL,
+		Timestamp:      event.DeviceTime,
+		OperatorOPCode: event.OperatorOPCode,
+		DeviceInfo:     string(deviceInfo),
+		ClientEventID:  event.ClientEventID,
+		SyncedAt:       &syncedAt,
+	}
+	if err := tx.Create(scanRecord).Error; err != nil {
+		return nil, err
+	}
+
+	record.Result = models.ScanSyncApplied
+	record.NewStatus = targetStatus
+	record.ScanRecordID = scanRecord.ID
+	return failure, nil
+}
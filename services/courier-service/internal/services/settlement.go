@@ -0,0 +1,684 @@
+package services
+
+import (
+	"bytes"
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSettlementPeriodNotFound    = errors.New("结算周期不存在")
+	ErrSettlementPeriodInvalid     = errors.New("结算周期的结束时间必须晚于开始时间")
+	ErrSettlementPeriodOverlap     = errors.New("与已有的同类型结算周期时间重叠")
+	ErrSettlementPeriodClosed      = errors.New("结算周期已关账")
+	ErrSettlementPeriodNotEnded    = errors.New("结算周期尚未结束")
+	ErrSettlementDisputesPending   = errors.New("存在未处理的对账单申诉")
+	ErrSettlementStatementNotFound = errors.New("对账单不存在")
+	ErrSettlementLineNotFound      = errors.New("对账单明细不存在")
+	ErrSettlementDisputeNotFound   = errors.New("申诉不存在")
+	ErrSettlementDisputeResolved   = errors.New("申诉已处理")
+	ErrSettlementPermissionDenied  = errors.New("无权操作该对账单")
+)
+
+// SettlementService 信使收益结算服务
+// 进行中的周期按任务、接力段、超时记录和激励领取记录实时生成对账单；关账后对账单冻结，不再重算
+type SettlementService struct {
+	db        *gorm.DB
+	wsManager *utils.WebSocketManager
+}
+
+// NewSettlementService 创建结算服务
+func NewSettlementService(db *gorm.DB, wsManager *utils.WebSocketManager) *SettlementService {
+	return &SettlementService{
+		db:        db,
+		wsManager: wsManager,
+	}
+}
+
+// CreatePeriod 创建结算周期，同类型周期之间不能重叠
+func (s *SettlementService) CreatePeriod(operatorID string, req *models.SettlementPeriodRequest) (*models.SettlementPeriod, error) {
+	if !req.EndAt.After(req.StartAt) {
+		return nil, ErrSettlementPeriodInvalid
+	}
+
+	var overlapping int64
+	if err := s.db.Model(&models.SettlementPeriod{}).
+		Where("type = ? AND start_at < ? AND end_at > ?", req.Type, req.EndAt, req.StartAt).
+		Count(&overlapping).Error; err != nil {
+		return nil, err
+	}
+	if overlapping > 0 {
+		return nil, ErrSettlementPeriodOverlap
+	}
+
+	period := &models.SettlementPeriod{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Type:      req.Type,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		Status:    models.SettlementPeriodOpen,
+		CreatedBy: operatorID,
+	}
+	if err := s.db.Create(period).Error; err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// ListPeriods 获取结算周期列表，按开始时间倒序
+func (s *SettlementService) ListPeriods(periodType string) ([]models.SettlementPeriod, error) {
+	db := s.db.Model(&models.SettlementPeriod{})
+	if periodType != "" {
+		db = db.Where("type = ?", periodType)
+	}
+
+	var periods []models.SettlementPeriod
+	if err := db.Order("start_at DESC").Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+// GetStatement 获取信使在指定周期的对账单，进行中的周期先重新生成
+func (s *SettlementService) GetStatement(periodID, courierID string) (*models.CourierStatement, error) {
+	period, err := s.getPeriod(periodID)
+	if err != nil {
+		return nil, err
+	}
+
+	if period.Status == models.SettlementPeriodOpen {
+		var statement *models.CourierStatement
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			statement, err = generateStatement(tx, period, courierID)
+			return err
+		})
+		return statement, err
+	}
+
+	var statement models.CourierStatement
+	if err := s.db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at ASC")
+	}).Where("period_id = ? AND courier_id = ?", periodID, courierID).First(&statement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementStatementNotFound
+		}
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// GetStatementByID 按ID获取对账单（含明细），进行中的周期先重新生成
+func (s *SettlementService) GetStatementByID(statementID string) (*models.CourierStatement, error) {
+	var statement models.CourierStatement
+	if err := s.db.Where("id = ?", statementID).First(&statement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementStatementNotFound
+		}
+		return nil, err
+	}
+	return s.GetStatement(statement.PeriodID, statement.CourierID)
+}
+
+// ListPeriodStatements 获取周期内所有信使的对账单（不含明细），供社团发放津贴
+func (s *SettlementService) ListPeriodStatements(periodID string) ([]models.CourierStatement, error) {
+	period, err := s.getPeriod(periodID)
+	if err != nil {
+		return nil, err
+	}
+
+	if period.Status == models.SettlementPeriodOpen {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			return generatePeriodStatements(tx, period)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var statements []models.CourierStatement
+	if err := s.db.Where("period_id = ?", periodID).Order("total_amount DESC").Find(&statements).Error; err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// ClosePeriod 关账：生成所有信使的最终对账单并冻结，之后不可申诉或修改
+func (s *SettlementService) ClosePeriod(periodID, operatorID string) (*models.SettlementPeriod, error) {
+	period, err := s.getPeriod(periodID)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status == models.SettlementPeriodClosed {
+		return nil, ErrSettlementPeriodClosed
+	}
+	now := time.Now()
+	if period.EndAt.After(now) {
+		return nil, ErrSettlementPeriodNotEnded
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockOpenPeriod(tx, periodID); err != nil {
+			return err
+		}
+
+		var pending int64
+		if err := tx.Model(&models.StatementDispute{}).
+			Where("period_id = ? AND status = ?", periodID, models.StatementDisputePending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrSettlementDisputesPending
+		}
+
+		if err := generatePeriodStatements(tx, period); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CourierStatement{}).Where("period_id = ?", periodID).
+			Update("status", models.SettlementPeriodClosed).Error; err != nil {
+			return err
+		}
+
+		// 条件更新防止并发关账
+		result := tx.Model(&models.SettlementPeriod{}).
+			Where("id = ? AND status = ?", periodID, models.SettlementPeriodOpen).
+			Updates(map[string]interface{}{
+				"status":    models.SettlementPeriodClosed,
+				"closed_at": now,
+				"closed_by": operatorID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSettlementPeriodClosed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	period.Status = models.SettlementPeriodClosed
+	period.ClosedAt = &now
+	period.ClosedBy = operatorID
+	return period, nil
+}
+
+// CreateDispute 信使对自己的对账单发起申诉，由其上级信使审批
+func (s *SettlementService) CreateDispute(userID, statementID string, req *models.StatementDisputeRequest) (*models.StatementDispute, error) {
+	var statement models.CourierStatement
+	if err := s.db.Where("id = ?", statementID).First(&statement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementStatementNotFound
+		}
+		return nil, err
+	}
+	if statement.CourierID != userID {
+		return nil, ErrSettlementPermissionDenied
+	}
+
+	dispute := &models.StatementDispute{
+		ID:              uuid.New().String(),
+		StatementID:     statement.ID,
+		PeriodID:        statement.PeriodID,
+		CourierID:       userID,
+		Reason:          req.Reason,
+		RequestedAmount: math.Round(req.RequestedAmount*100) / 100,
+		Status:          models.StatementDisputePending,
+	}
+	if req.LineID != "" {
+		var count int64
+		if err := s.db.Model(&models.StatementLine{}).
+			Where("id = ? AND statement_id = ?", req.LineID, statement.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrSettlementLineNotFound
+		}
+		dispute.LineID = &req.LineID
+	}
+
+	parent, err := findParentCourier(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		dispute.ApproverID = &parent.UserID
+	}
+
+	// 锁住周期行再写入，关账检查待处理申诉时不会漏掉并发提交的申诉
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockOpenPeriod(tx, statement.PeriodID); err != nil {
+			return err
+		}
+		return tx.Create(dispute).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	event := s.disputeEvent("STATEMENT_DISPUTE_CREATED", dispute)
+	if dispute.ApproverID != nil {
+		s.wsManager.BroadcastToUser(*dispute.ApproverID, event)
+	} else {
+		s.wsManager.BroadcastToAdmins(event)
+	}
+	return dispute, nil
+}
+
+// GetPendingDisputes 获取待我审批的申诉，管理员可查看全部
+func (s *SettlementService) GetPendingDisputes(userID string, isAdmin bool) ([]models.StatementDispute, error) {
+	db := s.db.Where("status = ?", models.StatementDisputePending)
+	if !isAdmin {
+		db = db.Where("approver_id = ?", userID)
+	}
+
+	var disputes []models.StatementDispute
+	if err := db.Order("created_at ASC").Find(&disputes).Error; err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// ResolveDispute 上级信使或管理员审批申诉，通过后以调整项计入对账单
+func (s *SettlementService) ResolveDispute(userID string, isAdmin bool, disputeID string, req *models.ResolveDisputeRequest) (*models.StatementDispute, error) {
+	var dispute models.StatementDispute
+	if err := s.db.Where("id = ?", disputeID).First(&dispute).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementDisputeNotFound
+		}
+		return nil, err
+	}
+	if dispute.Status != models.StatementDisputePending {
+		return nil, ErrSettlementDisputeResolved
+	}
+	if !isAdmin && (dispute.ApproverID == nil || *dispute.ApproverID != userID) {
+		return nil, ErrSettlementPermissionDenied
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":          models.StatementDisputeRejected,
+		"resolved_by":     userID,
+		"resolution_note": req.Note,
+		"resolved_at":     now,
+	}
+	if *req.Approve {
+		amount := dispute.RequestedAmount
+		if req.ApprovedAmount != nil {
+			amount = math.Round(*req.ApprovedAmount*100) / 100
+		}
+		updates["status"] = models.StatementDisputeApproved
+		updates["approved_amount"] = amount
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 周期在审批期间可能已关账，关账后的对账单不再变动
+		period, err := lockOpenPeriod(tx, dispute.PeriodID)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.StatementDispute{}).
+			Where("id = ? AND status = ?", dispute.ID, models.StatementDisputePending).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSettlementDisputeResolved
+		}
+		_, err = generateStatement(tx, period, dispute.CourierID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("id = ?", dispute.ID).First(&dispute).Error; err != nil {
+		return nil, err
+	}
+	s.wsManager.BroadcastToUser(dispute.CourierID, s.disputeEvent("STATEMENT_DISPUTE_RESOLVED", &dispute))
+	return &dispute, nil
+}
+
+// ExportCSV 导出对账单为 CSV
+func (s *SettlementService) ExportCSV(statement *models.CourierStatement) ([]byte, error) {
+	period, err := s.getPeriod(statement.PeriodID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	records := [][]string{
+		{"结算周期", period.Name},
+		{"信使", statement.CourierID},
+		{"状态", statement.Status},
+		{},
+		{"时间", "类型", "关联ID", "说明", "金额", "积分"},
+	}
+	for _, line := range statement.Lines {
+		records = append(records, []string{
+			line.OccurredAt.Format(time.RFC3339),
+			line.Type,
+			line.ReferenceID,
+			line.Description,
+			formatAmount(line.Amount),
+			strconv.Itoa(line.Points),
+		})
+	}
+	records = append(records,
+		[]string{},
+		[]string{"任务奖励", formatAmount(statement.TaskEarnings)},
+		[]string{"时效奖励", formatAmount(statement.SLABonus)},
+		[]string{"超时扣款", formatAmount(statement.SLAPenalty)},
+		[]string{"激励", formatAmount(statement.IncentiveTotal)},
+		[]string{"申诉调整", formatAmount(statement.AdjustmentTotal)},
+		[]string{"合计", formatAmount(statement.TotalAmount)},
+	)
+
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportPDF 导出对账单为 PDF
+func (s *SettlementService) ExportPDF(statement *models.CourierStatement) ([]byte, error) {
+	period, err := s.getPeriod(statement.PeriodID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := utils.NewPDFDocument()
+	doc.WriteLine(16, "信使收益对账单")
+	doc.WriteLine(10, fmt.Sprintf("结算周期：%s（%s 至 %s）", period.Name,
+		period.StartAt.Format("2006-01-02"), period.EndAt.Format("2006-01-02")))
+	doc.WriteLine(10, fmt.Sprintf("信使：%s    状态：%s    生成时间：%s", statement.CourierID, statement.Status,
+		statement.GeneratedAt.Format("2006-01-02 15:04")))
+	doc.WriteLine(10, fmt.Sprintf("完成任务 %d 个，时效达成率 %.1f%%", statement.TaskCount, statement.SLAComplianceRate))
+	doc.WriteLine(10, "")
+	for _, line := range statement.Lines {
+		text := fmt.Sprintf("%s  %-11s  %10s  %s", line.OccurredAt.Format("01-02 15:04"), line.Type,
+			formatAmount(line.Amount), line.Description)
+		if line.Points != 0 {
+			text += fmt.Sprintf("（%d积分）", line.Points)
+		}
+		doc.WriteLine(9, text)
+	}
+	doc.WriteLine(10, "")
+	doc.WriteLine(10, fmt.Sprintf("任务奖励 %s    时效奖励 %s    超时扣款 %s",
+		formatAmount(statement.TaskEarnings), formatAmount(statement.SLABonus), formatAmount(statement.SLAPenalty)))
+	doc.WriteLine(10, fmt.Sprintf("激励 %s    申诉调整 %s", formatAmount(statement.IncentiveTotal), formatAmount(statement.AdjustmentTotal)))
+	doc.WriteLine(12, fmt.Sprintf("合计：%s 元", formatAmount(statement.TotalAmount)))
+
+	return doc.Bytes(), nil
+}
+
+// lockOpenPeriod 锁定结算周期并确认尚未关账，申诉的提交、审批与关账在周期行上串行
+func lockOpenPeriod(tx *gorm.DB, periodID string) (*models.SettlementPeriod, error) {
+	var period models.SettlementPeriod
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", periodID).First(&period).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSettlementPeriodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if period.Status == models.SettlementPeriodClosed {
+		return nil, ErrSettlementPeriodClosed
+	}
+	return &period, nil
+}
+
+func (s *SettlementService) getPeriod(periodID string) (*models.SettlementPeriod, error) {
+	var period models.SettlementPeriod
+	if err := s.db.Where("id = ?", periodID).First(&period).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementPeriodNotFound
+		}
+		return nil, err
+	}
+	return &period, nil
+}
+
+func (s *SettlementService) disputeEvent(eventType string, dispute *models.StatementDispute) utils.WebSocketEvent {
+	return utils.WebSocketEvent{
+		Type: eventType,
+		Data: map[string]interface{}{
+			"dispute_id":       dispute.ID,
+			"statement_id":     dispute.StatementID,
+			"courier_id":       dispute.CourierID,
+			"status":           dispute.Status,
+			"requested_amount": dispute.RequestedAmount,
+			"approved_amount":  dispute.ApprovedAmount,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// generatePeriodStatements 为周期内所有已审核信使生成对账单
+func generatePeriodStatements(tx *gorm.DB, period *models.SettlementPeriod) error {
+	var courierIDs []string
+	if err := tx.Model(&models.Courier{}).Where("status = ?", models.CourierStatusApproved).
+		Pluck("user_id", &courierIDs).Error; err != nil {
+		return err
+	}
+	for _, courierID := range courierIDs {
+		if _, err := generateStatement(tx, period, courierID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateStatement 重新计算并保存信使在周期内的对账单
+// 明细按 (类型, 关联ID) 沿用已有行的ID，申诉引用的明细在重算后仍然有效
+func generateStatement(tx *gorm.DB, period *models.SettlementPeriod, courierID string) (*models.CourierStatement, error) {
+	var statement models.CourierStatement
+	err := tx.Where("period_id = ? AND courier_id = ?", period.ID, courierID).First(&statement).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		statement = models.CourierStatement{
+			ID:        uuid.New().String(),
+			PeriodID:  period.ID,
+			CourierID: courierID,
+		}
+	}
+
+	lines, err := collectStatementLines(tx, period, &statement)
+	if err != nil {
+		return nil, err
+	}
+
+	statement.TaskEarnings, statement.SLABonus, statement.SLAPenalty = 0, 0, 0
+	statement.IncentiveTotal, statement.AdjustmentTotal, statement.TaskCount = 0, 0, 0
+	for i := range lines {
+		line := &lines[i]
+		switch line.Type {
+		case models.StatementLineTask, models.StatementLineRelayLeg:
+			statement.TaskEarnings += line.Amount
+			statement.TaskCount++
+		case models.StatementLineSLABonus:
+			statement.SLABonus += line.Amount
+		case models.StatementLineSLAPenalty:
+			statement.SLAPenalty += line.Amount
+		case models.StatementLineIncentive:
+			statement.IncentiveTotal += line.Amount
+		case models.StatementLineAdjustment:
+			statement.AdjustmentTotal += line.Amount
+		}
+	}
+	statement.TotalAmount = math.Round((statement.TaskEarnings+statement.SLABonus+statement.SLAPenalty+
+		statement.IncentiveTotal+statement.AdjustmentTotal)*100) / 100
+	statement.Status = period.Status
+	statement.GeneratedAt = time.Now()
+	statement.Lines = nil
+
+	if err := tx.Save(&statement).Error; err != nil {
+		return nil, err
+	}
+
+	var existing []models.StatementLine
+	if err := tx.Where("statement_id = ?", statement.ID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	existingIDs := make(map[string]string, len(existing))
+	for _, line := range existing {
+		existingIDs[line.Type+"/"+line.ReferenceID] = line.ID
+	}
+	keep := make([]string, 0, len(lines))
+	for i := range lines {
+		if id, ok := existingIDs[lines[i].Type+"/"+lines[i].ReferenceID]; ok {
+			lines[i].ID = id
+		}
+		keep = append(keep, lines[i].ID)
+	}
+
+	// 不再属于本周期的明细删除，其余按ID覆盖写入
+	stale := tx.Where("statement_id = ?", statement.ID)
+	if len(keep) > 0 {
+		stale = stale.Where("id NOT IN ?", keep)
+	}
+	if err := stale.Delete(&models.StatementLine{}).Error; err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		if err := tx.Save(&lines).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	statement.Lines = lines
+	return &statement, nil
+}
+
+// collectStatementLines 汇总周期内的任务、接力段、超时、激励和申诉调整明细
+// 任务按送达时间、接力段按交出时间、超时按发现时间、激励按领取时间归入周期
+func collectStatementLines(tx *gorm.DB, period *models.SettlementPeriod, statement *models.CourierStatement) ([]models.StatementLine, error) {
+	courierID := statement.CourierID
+	var lines []models.StatementLine
+	add := func(lineType, referenceID, description string, amount float64, points int, occurredAt time.Time) {
+		lines = append(lines, models.StatementLine{
+			ID:          uuid.New().String(),
+			StatementID: statement.ID,
+			Type:        lineType,
+			ReferenceID: referenceID,
+			Description: description,
+			Amount:      math.Round(amount*100) / 100,
+			Points:      points,
+			OccurredAt:  occurredAt,
+		})
+	}
+
+	// 接力任务的奖励按段发放，不重复计入
+	var tasks []models.Task
+	if err := tx.Where("courier_id = ? AND status = ? AND is_relay = ? AND completed_at >= ? AND completed_at < ?",
+		courierID, models.TaskStatusDelivered, false, period.StartAt, period.EndAt).
+		Order("completed_at ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	earnings := 0.0
+	for _, task := range tasks {
+		add(models.StatementLineTask, task.TaskID, fmt.Sprintf("投递任务 %s", task.TaskID), task.Reward, 0, *task.CompletedAt)
+		earnings += task.Reward
+	}
+
+	var legs []models.RelayLeg
+	if err := tx.Where("courier_id = ? AND status IN ? AND custody_end_at >= ? AND custody_end_at < ?",
+		courierID, []string{models.RelayLegStatusHandedOff, models.RelayLegStatusDelivered}, period.StartAt, period.EndAt).
+		Order("custody_end_at ASC").Find(&legs).Error; err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		add(models.StatementLineRelayLeg, leg.ID, fmt.Sprintf("接力任务 %s 第%d段", leg.TaskID, leg.Sequence), leg.Reward, 0, *leg.CustodyEndAt)
+		earnings += leg.Reward
+	}
+
+	compliance, err := calculateSLACompliance(tx, courierID, period.StartAt, period.EndAt)
+	if err != nil {
+		return nil, err
+	}
+	statement.SLAComplianceRate = compliance.ComplianceRate
+	if len(tasks)+len(legs) >= models.SettlementSLABonusMinTasks && compliance.ComplianceRate >= models.SettlementSLABonusMinRate {
+		add(models.StatementLineSLABonus, "", fmt.Sprintf("时效达成率 %.1f%%", compliance.ComplianceRate),
+			earnings*models.SettlementSLABonusRate, 0, period.EndAt)
+	}
+
+	// 任务因取消等原因关闭的超时不扣款
+	var breaches []models.SLABreach
+	if err := tx.Where("courier_id = ? AND detected_at >= ? AND detected_at < ? AND (resolution IS NULL OR resolution <> ?)",
+		courierID, period.StartAt, period.EndAt, models.SLAResolutionClosed).
+		Order("detected_at ASC").Find(&breaches).Error; err != nil {
+		return nil, err
+	}
+	for _, breach := range breaches {
+		add(models.StatementLineSLAPenalty, breach.ID, fmt.Sprintf("任务 %s %s阶段超时", breach.TaskID, breach.Stage),
+			-models.SettlementSLAPenaltyPerBreach, 0, breach.DetectedAt)
+	}
+
+	var claims []models.CourierIncentiveClaim
+	if err := tx.Where("courier_id = ? AND claimed_at >= ? AND claimed_at < ?", courierID, period.StartAt, period.EndAt).
+		Order("claimed_at ASC").Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	for _, claim := range claims {
+		add(models.StatementLineIncentive, claim.ID, fmt.Sprintf("领取激励 %s %s", claim.Type, claim.Reference),
+			claim.Amount, claim.Points, claim.ClaimedAt)
+	}
+
+	var disputes []models.StatementDispute
+	if err := tx.Where("statement_id = ? AND status = ?", statement.ID, models.StatementDisputeApproved).
+		Order("resolved_at ASC").Find(&disputes).Error; err != nil {
+		return nil, err
+	}
+	for _, dispute := range disputes {
+		if dispute.ApprovedAmount == nil || dispute.ResolvedAt == nil {
+			continue
+		}
+		add(models.StatementLineAdjustment, dispute.ID, fmt.Sprintf("申诉调整：%s", dispute.Reason),
+			*dispute.ApprovedAmount, 0, *dispute.ResolvedAt)
+	}
+
+	return lines, nil
+}
+
+// recordIncentiveClaim 记录激励领取，供结算对账单汇总
+func recordIncentiveClaim(db *gorm.DB, courierID string, incentiveType models.IncentiveType, reference string, result map[string]interface{}) error {
+	claim := &models.CourierIncentiveClaim{
+		ID:        uuid.New().String(),
+		CourierID: courierID,
+		Type:      string(incentiveType),
+		Reference: reference,
+		ClaimedAt: time.Now(),
+	}
+	// 积分奖励的 amount 为积分数，其余类型的 amount 为金额
+	switch value := result["amount"].(type) {
+	case float64:
+		claim.Amount = value
+	case int:
+		claim.Points = value
+	}
+	if points, ok := result["points"].(int); ok {
+		claim.Points = points
+	}
+	return db.Create(claim).Error
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,608 @@
+package services
+
+import (
+	"courier-service/internal/models"
+	"courier-service/internal/utils"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSLABreachNotFound   = errors.New("超时记录不存在")
+	ErrSLABreachResolved   = errors.New("超时记录已处理")
+	ErrSLAPolicyNotFound   = errors.New("SLA时限配置不存在")
+	ErrSLAPermissionDenied = errors.New("无权处理该超时任务")
+)
+
+// slaCheckInterval SLA巡检间隔
+const slaCheckInterval = 5 * time.Minute
+
+// slaStageStatuses 各SLA阶段对应的任务状态
+var slaStageStatuses = map[string][]string{
+	models.SLAStageAccept:  {models.TaskStatusAvailable},
+	models.SLAStageCollect: {models.TaskStatusAccepted},
+	models.SLAStageDeliver: {models.TaskStatusCollected, models.TaskStatusInTransit},
+}
+
+// SLAService 任务时效监控服务
+// 定时巡检各阶段超时任务，超时后上报给负责信使的上级，仍未处理则继续逐级上报
+type SLAService struct {
+	db                            *gorm.DB
+	hierarchicalAssignmentService *HierarchicalAssignmentService
+	wsManager                     *utils.WebSocketManager
+}
+
+// NewSLAService 创建任务时效监控服务
+func NewSLAService(db *gorm.DB, hierarchicalAssignmentService *HierarchicalAssignmentService, wsManager *utils.WebSocketManager) *SLAService {
+	return &SLAService{
+		db:                            db,
+		hierarchicalAssignmentService: hierarchicalAssignmentService,
+		wsManager:                     wsManager,
+	}
+}
+
+// EnsureDefaultPolicies 写入缺失的默认SLA时限，已有配置不覆盖
+func (s *SLAService) EnsureDefaultPolicies() error {
+	for _, policy := range models.DefaultTaskSLAPolicies {
+		policy.UpdatedBy = "system"
+		if err := s.db.Where("priority = ?", policy.Priority).FirstOrCreate(&policy).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartMonitor 定时巡检SLA
+func (s *SLAService) StartMonitor() {
+	ticker := time.NewTicker(slaCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.CheckBreaches(); err != nil {
+			log.Printf("SLA check failed: %v", err)
+		}
+	}
+}
+
+// CheckBreaches 关闭已推进的超时记录，登记新超时，并继续上报长时间未处理的记录
+func (s *SLAService) CheckBreaches() error {
+	now := time.Now()
+	policies, err := loadSLAPolicies(s.db)
+	if err != nil {
+		return err
+	}
+
+	if err := s.resolveAdvancedBreaches(now); err != nil {
+		return err
+	}
+
+	for _, stage := range []string{models.SLAStageAccept, models.SLAStageCollect, models.SLAStageDeliver} {
+		var tasks []models.Task
+		if err := s.db.Where("status IN ? AND is_relay = ?", slaStageStatuses[stage], false).Find(&tasks).Error; err != nil {
+			return err
+		}
+
+		for i := range tasks {
+			task := &tasks[i]
+			policy := policies[task.Priority]
+			if policy == nil {
+				policy = policies[models.TaskPriorityNormal]
+			}
+			dueAt, ok := slaStageDueAt(task, stage, policy)
+			if !ok || now.Before(dueAt) {
+				continue
+			}
+			if err := s.openBreach(task, stage, dueAt, policy, now); err != nil {
+				log.Printf("Failed to open SLA breach for task %s: %v", task.TaskID, err)
+			}
+		}
+	}
+
+	var overdue []models.SLABreach
+	if err := s.db.Where("status = ? AND next_escalation_at <= ?", models.SLABreachOpen, now).Find(&overdue).Error; err != nil {
+		return err
+	}
+	for i := range overdue {
+		policy := policies[overdue[i].Priority]
+		if policy == nil {
+			policy = policies[models.TaskPriorityNormal]
+		}
+		if err := s.escalate(&overdue[i], policy, now); err != nil {
+			log.Printf("Failed to escalate SLA breach %s: %v", overdue[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetEscalatedBreaches 获取上报给当前信使的超时任务，按超时时长倒序
+func (s *SLAService) GetEscalatedBreaches(userID string, query *models.SLABreachQuery) ([]models.SLABreachView, int64, error) {
+	db := s.db.Model(&models.SLABreach{}).Where("escalated_to = ?", userID)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Stage != "" {
+		db = db.Where("stage = ?", query.Stage)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var breaches []models.SLABreach
+	if err := db.Order("due_at ASC").Limit(limit).Offset(query.Offset).Find(&breaches).Error; err != nil {
+		return nil, 0, err
+	}
+
+	taskIDs := make([]string, 0, len(breaches))
+	for _, breach := range breaches {
+		taskIDs = append(taskIDs, breach.TaskID)
+	}
+	var tasks []models.Task
+	if len(taskIDs) > 0 {
+		if err := s.db.Where("task_id IN ?", taskIDs).Find(&tasks).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	taskMap := make(map[string]*models.Task, len(tasks))
+	for i := range tasks {
+		taskMap[tasks[i].TaskID] = &tasks[i]
+	}
+
+	now := time.Now()
+	views := make([]models.SLABreachView, 0, len(breaches))
+	for _, breach := range breaches {
+		end := now
+		if breach.ResolvedAt != nil {
+			end = *breach.ResolvedAt
+		}
+		views = append(views, models.SLABreachView{
+			SLABreach:      breach,
+			Task:           taskMap[breach.TaskID],
+			OverdueMinutes: int(end.Sub(breach.DueAt).Minutes()),
+		})
+	}
+
+	return views, total, nil
+}
+
+// ReassignBreachedTask 上级信使改派超时任务，改派复用层级分配的权限校验
+func (s *SLAService) ReassignBreachedTask(userID, breachID string, req *models.SLAReassignRequest) (*models.Task, error) {
+	var breach models.SLABreach
+	if err := s.db.Where("id = ?", breachID).First(&breach).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSLABreachNotFound
+		}
+		return nil, err
+	}
+	if breach.Status != models.SLABreachOpen {
+		return nil, ErrSLABreachResolved
+	}
+
+	var manager models.Courier
+	if err := s.db.Where("user_id = ?", userID).First(&manager).Error; err != nil {
+		return nil, ErrSLAPermissionDenied
+	}
+	if breach.EscalatedTo == nil || *breach.EscalatedTo != userID {
+		// 未直接收到上报的更高层级信使也可处理
+		if !s.isAboveInHierarchy(&manager, breach.EscalatedTo) {
+			return nil, ErrSLAPermissionDenied
+		}
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "SLA超时改派"
+	}
+	task, err := s.hierarchicalAssignmentService.ReassignTask(manager.ID, &models.TaskReassignmentRequest{
+		TaskID:       breach.TaskID,
+		NewCourierID: req.NewCourierID,
+		Reason:       reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&breach).Updates(map[string]interface{}{
+		"status":             models.SLABreachResolved,
+		"resolution":         models.SLAResolutionReassigned,
+		"resolved_by":        userID,
+		"resolved_at":        &now,
+		"next_escalation_at": nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Where("task_id = ?", breach.TaskID).First(task)
+	return task, nil
+}
+
+// GetCourierCompliance 获取信使指定时间段内的SLA达成情况
+func (s *SLAService) GetCourierCompliance(userID string, since, until time.Time) (*models.SLACompliance, error) {
+	return calculateSLACompliance(s.db, userID, since, until)
+}
+
+// GetPolicies 获取SLA时限配置
+func (s *SLAService) GetPolicies() ([]models.TaskSLAPolicy, error) {
+	var policies []models.TaskSLAPolicy
+	if err := s.db.Order("accept_minutes ASC").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// UpdatePolicy 更新指定优先级的SLA时限
+func (s *SLAService) UpdatePolicy(priority, operatorID string, req *models.TaskSLAPolicyRequest) (*models.TaskSLAPolicy, error) {
+	var policy models.TaskSLAPolicy
+	if err := s.db.Where("priority = ?", priority).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSLAPolicyNotFound
+		}
+		return nil, err
+	}
+
+	policy.AcceptMinutes = req.AcceptMinutes
+	policy.CollectMinutes = req.CollectMinutes
+	policy.DeliverMinutes = req.DeliverMinutes
+	policy.EscalateAfterMinutes = req.EscalateAfterMinutes
+	policy.UpdatedBy = operatorID
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// 私有方法
+
+// resolveAdvancedBreaches 任务已离开超时阶段时关闭记录
+func (s *SLAService) resolveAdvancedBreaches(now time.Time) error {
+	var open []models.SLABreach
+	if err := s.db.Where("status = ?", models.SLABreachOpen).Find(&open).Error; err != nil {
+		return err
+	}
+
+	for _, breach := range open {
+		var task models.Task
+		err := s.db.Where("task_id = ?", breach.TaskID).First(&task).Error
+		resolution := ""
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			resolution = models.SLAResolutionClosed
+		case err != nil:
+			return err
+		case task.IsRelay || !task.IsActive():
+			if task.Status == models.TaskStatusDelivered {
+				resolution = models.SLAResolutionCompletedLate
+			} else {
+				resolution = models.SLAResolutionClosed
+			}
+		case !containsString(slaStageStatuses[breach.Stage], task.Status):
+			resolution = models.SLAResolutionCompletedLate
+		}
+		if resolution == "" {
+			continue
+		}
+
+		if err := s.db.Model(&models.SLABreach{}).Where("id = ?", breach.ID).Updates(map[string]interface{}{
+			"status":             models.SLABreachResolved,
+			"resolution":         resolution,
+			"resolved_by":        "system",
+			"resolved_at":        &now,
+			"next_escalation_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openBreach 登记超时并上报给第一级处理人
+func (s *SLAService) openBreach(task *models.Task, stage string, dueAt time.Time, policy *models.TaskSLAPolicy, now time.Time) error {
+	var count int64
+	if err := s.db.Model(&models.SLABreach{}).
+		Where("task_id = ? AND stage = ? AND due_at = ?", task.TaskID, stage, dueAt).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	breach := &models.SLABreach{
+		ID:         uuid.New().String(),
+		TaskID:     task.TaskID,
+		Stage:      stage,
+		DueAt:      dueAt,
+		Priority:   task.Priority,
+		CourierID:  task.CourierID,
+		DetectedAt: now,
+		Status:     models.SLABreachOpen,
+	}
+	if err := s.db.Create(breach).Error; err != nil {
+		return err
+	}
+
+	if task.CourierID != nil {
+		s.wsManager.BroadcastToUser(*task.CourierID, utils.WebSocketEvent{
+			Type: "SLA_BREACH_WARNING",
+			Data: map[string]interface{}{
+				"task_id": task.TaskID,
+				"stage":   stage,
+				"due_at":  dueAt,
+			},
+			Timestamp: now,
+		})
+	}
+
+	return s.escalate(breach, policy, now)
+}
+
+// escalate 将超时记录上报给更高一级的信使，没有更高层级时通知管理员
+func (s *SLAService) escalate(breach *models.SLABreach, policy *models.TaskSLAPolicy, now time.Time) error {
+	var target *models.Courier
+	var err error
+	if breach.EscalatedTo == nil {
+		target, err = s.findFirstEscalationTarget(breach)
+	} else {
+		target, err = s.findParentCourier(*breach.EscalatedTo)
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if target == nil {
+		// 已到顶层，不再定时上报
+		updates["next_escalation_at"] = nil
+		s.wsManager.BroadcastToAdmins(s.escalationEvent(breach, "", now))
+	} else {
+		next := now.Add(time.Duration(policy.EscalateAfterMinutes) * time.Minute)
+		breach.EscalatedTo = &target.UserID
+		breach.EscalationLevel++
+		updates["escalated_to"] = target.UserID
+		updates["escalation_level"] = breach.EscalationLevel
+		updates["escalated_at"] = &now
+		updates["next_escalation_at"] = &next
+		s.wsManager.BroadcastToUser(target.UserID, s.escalationEvent(breach, target.UserID, now))
+	}
+
+	return s.db.Model(&models.SLABreach{}).Where("id = ?", breach.ID).Updates(updates).Error
+}
+
+// findFirstEscalationTarget 首次上报：负责信使的上级；尚无人接取时找管辖取件地址的最低层级管理者
+func (s *SLAService) findFirstEscalationTarget(breach *models.SLABreach) (*models.Courier, error) {
+	if breach.CourierID != nil {
+		return s.findParentCourier(*breach.CourierID)
+	}
+
+	var task models.Task
+	if err := s.db.Where("task_id = ?", breach.TaskID).First(&task).Error; err != nil {
+		return nil, err
+	}
+	opCode := task.PickupOPCode
+	if opCode == "" {
+		opCode = task.DeliveryOPCode
+	}
+	if opCode == "" {
+		return nil, nil
+	}
+
+	var managers []models.Courier
+	if err := s.db.Where("level >= ? AND status = ?", models.CourierLevelTwo, models.CourierStatusApproved).
+		Find(&managers).Error; err != nil {
+		return nil, err
+	}
+	candidates := make([]models.Courier, 0, len(managers))
+	for _, manager := range managers {
+		if courierCoversOPCode(&manager, opCode) {
+			candidates = append(candidates, manager)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Level < candidates[j].Level
+	})
+	return &candidates[0], nil
+}
+
+// findParentCourier 根据信使用户ID查找其上级信使
+func (s *SLAService) findParentCourier(userID string) (*models.Courier, error) {
+	return findParentCourier(s.db, userID)
+}
+
+// isAboveInHierarchy 检查 manager 是否为当前处理人的上级（任意层）
+func (s *SLAService) isAboveInHierarchy(manager *models.Courier, escalatedTo *string) bool {
+	if escalatedTo == nil {
+		return manager.Level >= models.CourierLevelThree
+	}
+
+	current := *escalatedTo
+	for depth := 0; depth < models.CourierLevelFour; depth++ {
+		parent, err := s.findParentCourier(current)
+		if err != nil || parent == nil {
+			return false
+		}
+		if parent.ID == manager.ID {
+			return true
+		}
+		current = parent.UserID
+	}
+	return false
+}
+
+// escalationEvent 构造上报通知
+func (s *SLAService) escalationEvent(breach *models.SLABreach, escalatedTo string, now time.Time) utils.WebSocketEvent {
+	return utils.WebSocketEvent{
+		Type: "SLA_BREACH_ESCALATED",
+		Data: map[string]interface{}{
+			"breach_id":        breach.ID,
+			"task_id":          breach.TaskID,
+			"stage":            breach.Stage,
+			"due_at":           breach.DueAt,
+			"courier_id":       breach.CourierID,
+			"escalated_to":     escalatedTo,
+			"escalation_level": breach.EscalationLevel,
+		},
+		Timestamp: now,
+	}
+}
+
+// loadSLAPolicies 按优先级加载SLA时限，未配置的优先级使用默认值
+func loadSLAPolicies(db *gorm.DB) (map[string]*models.TaskSLAPolicy, error) {
+	policies := make(map[string]*models.TaskSLAPolicy, len(models.DefaultTaskSLAPolicies))
+	for i := range models.DefaultTaskSLAPolicies {
+		policy := models.DefaultTaskSLAPolicies[i]
+		policies[policy.Priority] = &policy
+	}
+
+	var configured []models.TaskSLAPolicy
+	if err := db.Find(&configured).Error; err != nil {
+		return nil, err
+	}
+	for i := range configured {
+		policies[configured[i].Priority] = &configured[i]
+	}
+	return policies, nil
+}
+
+// slaStageDueAt 计算任务在指定阶段的到期时间，送达阶段不晚于任务截止时间
+func slaStageDueAt(task *models.Task, stage string, policy *models.TaskSLAPolicy) (time.Time, bool) {
+	var start *time.Time
+	switch stage {
+	case models.SLAStageAccept:
+		start = &task.CreatedAt
+	case models.SLAStageCollect:
+		start = task.AcceptedAt
+	case models.SLAStageDeliver:
+		start = task.CollectedAt
+		if start == nil {
+			start = task.AcceptedAt
+		}
+	}
+	if start == nil || start.IsZero() {
+		return time.Time{}, false
+	}
+
+	dueAt := start.Add(policy.StageLimit(stage))
+	if stage == models.SLAStageDeliver && task.Deadline != nil && task.Deadline.Before(dueAt) {
+		dueAt = *task.Deadline
+	}
+	return dueAt, true
+}
+
+// calculateSLACompliance 统计信使在时间段内接取的任务中收取、送达两个阶段的按时率
+// 被上级因超时改派走的任务计为未达成
+func calculateSLACompliance(db *gorm.DB, userID string, since, until time.Time) (*models.SLACompliance, error) {
+	policies, err := loadSLAPolicies(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	if err := db.Where("courier_id = ? AND is_relay = ? AND accepted_at >= ? AND accepted_at <= ?", userID, false, since, until).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &models.SLACompliance{}
+	record := func(onTime bool) {
+		result.TotalStages++
+		if onTime {
+			result.OnTimeStages++
+		} else {
+			result.BreachedStages++
+		}
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+		policy := policies[task.Priority]
+		if policy == nil {
+			policy = policies[models.TaskPriorityNormal]
+		}
+
+		if collectDue, ok := slaStageDueAt(task, models.SLAStageCollect, policy); ok {
+			if task.CollectedAt != nil {
+				record(!task.CollectedAt.After(collectDue))
+			} else if task.Status == models.TaskStatusAccepted && now.After(collectDue) {
+				record(false)
+			}
+		}
+
+		if task.CollectedAt == nil {
+			continue
+		}
+		if deliverDue, ok := slaStageDueAt(task, models.SLAStageDeliver, policy); ok {
+			if task.Status == models.TaskStatusDelivered && task.CompletedAt != nil {
+				record(!task.CompletedAt.After(deliverDue))
+			} else if containsString(slaStageStatuses[models.SLAStageDeliver], task.Status) && now.After(deliverDue) {
+				record(false)
+			}
+		}
+	}
+
+	var reassignedAway int64
+	if err := db.Model(&models.SLABreach{}).
+		Where("courier_id = ? AND resolution = ? AND detected_at >= ? AND detected_at <= ?", userID, models.SLAResolutionReassigned, since, until).
+		Count(&reassignedAway).Error; err != nil {
+		return nil, err
+	}
+	for i := int64(0); i < reassignedAway; i++ {
+		record(false)
+	}
+
+	result.ComplianceRate = 100
+	if result.TotalStages > 0 {
+		result.ComplianceRate = float64(result.OnTimeStages) / float64(result.TotalStages) * 100
+	}
+	return result, nil
+}
+
+// containsString 检查切片是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// findParentCourier 根据信使用户ID查找其上级信使，没有上级时返回 nil
+func findParentCourier(db *gorm.DB, userID string) (*models.Courier, error) {
+	var courier models.Courier
+	if err := db.Where("user_id = ?", userID).First(&courier).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if courier.ParentID == nil {
+		return nil, nil
+	}
+
+	var parent models.Courier
+	if err := db.Where("id = ?", *courier.ParentID).First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &parent, nil
+}
@@ -15,9 +15,13 @@ import (
 
 // TaskService 任务服务
 type TaskService struct {
-	db        *gorm.DB
-	redis     *redis.Client
-	wsManager *utils.WebSocketManager
+	db         *gorm.DB
+	redis      *redis.Client
+	wsManager  *utils.WebSocketManager
+	qrVerifier *utils.QRTokenVerifier
+	locations  *LocationService
+	failures   *DeliveryFailureService
+	receipts   *DeliveryReceiptVerifier
 }
 
 // NewTaskService 创建任务服务实例
@@ -29,8 +33,37 @@ func NewTaskService(db *gorm.DB, redis *redis.Client, wsManager *utils.WebSocket
 	}
 }
 
+// SetQRTokenVerifier 设置二维码签名验证器，设置后扫码改状态前校验标签签名
+func (s *TaskService) SetQRTokenVerifier(verifier *utils.QRTokenVerifier) {
+	s.qrVerifier = verifier
+}
+
+// SetLocationService 设置地理位置服务，新建任务时按校园地图补全坐标
+func (s *TaskService) SetLocationService(locations *LocationService) {
+	s.locations = locations
+}
+
+// SetDeliveryFailureService 设置投递失败处理服务，离线补传的失败扫码按原因登记并安排重投
+func (s *TaskService) SetDeliveryFailureService(failures *DeliveryFailureService) {
+	s.failures = failures
+}
+
+// SetDeliveryReceiptVerifier 设置签收凭证校验器，送达扫码须有收件人签收凭证
+func (s *TaskService) SetDeliveryReceiptVerifier(receipts *DeliveryReceiptVerifier) {
+	s.receipts = receipts
+}
+
+// VerifyScanToken 校验扫码携带的签名令牌，at 为实际扫码时间
+func (s *TaskService) VerifyScanToken(letterCode, token string, at time.Time) error {
+	if s.qrVerifier == nil {
+		return nil
+	}
+	_, err := s.qrVerifier.Verify(token, letterCode, at)
+	return err
+}
+
 // CreateTask 创建任务
-func (s *TaskService) CreateTask(letterID, pickupLocation, deliveryLocation string, queueService *QueueService) (*models.Task, error) {
+func (s *TaskService) CreateTask(letterID, pickupLocation, deliveryLocation, senderID, senderOPCode string, queueService *QueueService) (*models.Task, error) {
 	task := &models.Task{
 		TaskID:           utils.GenerateTaskID(),
 		LetterID:         letterID,
@@ -39,9 +72,20 @@ func (s *TaskService) CreateTask(letterID, pickupLocation, deliveryLocation stri
 		Status:           models.TaskStatusAvailable,
 		Priority:         models.TaskPriorityNormal,
 		Reward:           5.0, // 默认奖励
+		SenderOPCode:     senderOPCode,
 	}
+	if senderID != "" {
+		task.SenderID = &senderID
+	}
+	s.locations.FillTaskCoordinates(task)
 
-	if err := s.db.Create(task).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, task.TaskID, "")
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -115,6 +159,11 @@ func (s *TaskService) AcceptTask(taskID, courierID string, request *models.TaskA
 		return nil, gorm.ErrInvalidValue
 	}
 
+	// 检查接单容量
+	if err := checkCourierCapacity(s.db, courierID); err != nil {
+		return nil, err
+	}
+
 	// 更新任务状态
 	now := time.Now()
 	deadline := now.Add(4 * time.Hour) // 默认4小时截止
@@ -127,7 +176,13 @@ func (s *TaskService) AcceptTask(taskID, courierID string, request *models.TaskA
 		"deadline":       &deadline,
 	}
 
-	if err := s.db.Model(&task).Where("task_id = ?", taskID).Updates(updates).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Where("task_id = ?", taskID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, taskID, "")
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -143,9 +198,16 @@ func (s *TaskService) AcceptTask(taskID, courierID string, request *models.TaskA
 
 // UpdateTaskStatus 更新任务状态（通过扫码）
 func (s *TaskService) UpdateTaskStatus(letterCode, courierID string, scanRequest *models.ScanRequest) (*models.ScanResponse, error) {
+	// 拒绝伪造或复印的标签
+	if err := s.VerifyScanToken(letterCode, scanRequest.QRToken, time.Now()); err != nil {
+		return nil, err
+	}
+
 	// 根据信件编号查找任务
+	// 退回寄件人后原任务保留为 returned，扫码作用于退件任务
 	var task models.Task
-	if err := s.db.Where("letter_id = ?", letterCode).First(&task).Error; err != nil {
+	if err := s.db.Where("letter_id = ? AND status <> ?", letterCode, models.TaskStatusReturned).
+		Order("created_at DESC").First(&task).Error; err != nil {
 		return nil, err
 	}
 
@@ -154,6 +216,11 @@ func (s *TaskService) UpdateTaskStatus(letterCode, courierID string, scanRequest
 		return nil, gorm.ErrInvalidValue
 	}
 
+	// 跨校接力任务由交接扫码推进
+	if task.IsRelay {
+		return nil, ErrRelayScanNotAllowed
+	}
+
 	// 获取目标状态
 	targetStatus, exists := models.ActionToStatus[scanRequest.Action]
 	if !exists {
@@ -165,6 +232,13 @@ func (s *TaskService) UpdateTaskStatus(letterCode, courierID string, scanRequest
 		return nil, gorm.ErrInvalidTransaction
 	}
 
+	// 送达须有收件人当面签收的凭证
+	if targetStatus == models.TaskStatusDelivered {
+		if _, err := s.receipts.Require(s.db, letterCode, courierID); err != nil {
+			return nil, err
+		}
+	}
+
 	oldStatus := task.Status
 
 	// 更新任务状态
@@ -172,12 +246,21 @@ func (s *TaskService) UpdateTaskStatus(letterCode, courierID string, scanRequest
 		"status": targetStatus,
 	}
 
-	if targetStatus == models.TaskStatusDelivered {
-		now := time.Now()
+	now := time.Now()
+	switch targetStatus {
+	case models.TaskStatusCollected:
+		updates["collected_at"] = &now
+	case models.TaskStatusDelivered:
 		updates["completed_at"] = &now
 	}
 
-	if err := s.db.Model(&task).Where("letter_id = ?", letterCode).Updates(updates).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Where("task_id = ?", task.TaskID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordTaskSyncEvent(tx, task.TaskID, scanRequest.Note)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -192,7 +275,7 @@ func (s *TaskService) UpdateTaskStatus(letterCode, courierID string, scanRequest
 		Longitude: scanRequest.Longitude,
 		Note:      scanRequest.Note,
 		PhotoURL:  scanRequest.PhotoURL,
-		Timestamp: time.Now(),
+		Timestamp: now,
 	}
 
 	if err := s.db.Create(scanRecord).Error; err != nil {
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 校园地图，格式与主服务 /api/v1/opcode/geo 导出的 GeoJSON 一致：
+// 片区为 Polygon/MultiPolygon，properties.area 为4位前缀；投递点为 Point，properties.op_code 为6位编码。
+// 位置数组为 [经度, 纬度]。
+
+var ErrInvalidGeodata = errors.New("校园地图不是有效的 GeoJSON FeatureCollection")
+
+type geoPoint struct {
+	lat, lng float64
+}
+
+// geoArea 片区边界，第一个环为外边界，其余为空洞
+type geoArea struct {
+	prefix   string
+	name     string
+	polygons [][][]geoPoint
+	minLat   float64
+	minLng   float64
+	maxLat   float64
+	maxLng   float64
+}
+
+func (a *geoArea) contains(pt geoPoint) bool {
+	if pt.lat < a.minLat || pt.lat > a.maxLat || pt.lng < a.minLng || pt.lng > a.maxLng {
+		return false
+	}
+	for _, polygon := range a.polygons {
+		if len(polygon) == 0 || !ringContains(polygon[0], pt) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, pt) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *geoArea) span() float64 {
+	return (a.maxLat - a.minLat) * (a.maxLng - a.minLng)
+}
+
+func (a *geoArea) center() geoPoint {
+	return geoPoint{lat: (a.minLat + a.maxLat) / 2, lng: (a.minLng + a.maxLng) / 2}
+}
+
+// ringContains 射线法判断点是否在环内
+func ringContains(ring []geoPoint, pt geoPoint) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.lat > pt.lat) != (b.lat > pt.lat) &&
+			pt.lng < (b.lng-a.lng)*(pt.lat-a.lat)/(b.lat-a.lat)+a.lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// CampusGeodata 校园地图缓存：坐标到片区、编码到坐标的查询，可定期从主服务刷新
+type CampusGeodata struct {
+	mu     sync.RWMutex
+	areas  []*geoArea
+	points map[string]geoPoint
+	client *http.Client
+}
+
+// NewCampusGeodata 创建空的校园地图
+func NewCampusGeodata() *CampusGeodata {
+	return &CampusGeodata{
+		points: make(map[string]geoPoint),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Load 解析 GeoJSON 并整体替换当前地图，返回片区数和投递点数
+func (g *CampusGeodata) Load(data []byte) (int, int, error) {
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil || collection.Type != "FeatureCollection" {
+		return 0, 0, ErrInvalidGeodata
+	}
+
+	var areas []*geoArea
+	points := make(map[string]geoPoint)
+	for _, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		switch feature.Geometry.Type {
+		case "Point":
+			code, _ := feature.Properties["op_code"].(string)
+			var position []float64
+			if len(code) != 6 || json.Unmarshal(feature.Geometry.Coordinates, &position) != nil || len(position) < 2 {
+				continue
+			}
+			points[strings.ToUpper(code)] = geoPoint{lat: position[1], lng: position[0]}
+		case "Polygon", "MultiPolygon":
+			prefix, _ := feature.Properties["area"].(string)
+			if len(prefix) != 4 {
+				continue
+			}
+			area, err := parseGeoArea(feature.Geometry.Type, feature.Geometry.Coordinates)
+			if err != nil {
+				return 0, 0, fmt.Errorf("片区 %s: %w", prefix, err)
+			}
+			area.prefix = strings.ToUpper(prefix)
+			area.name, _ = feature.Properties["name"].(string)
+			areas = append(areas, area)
+		}
+	}
+	// 重叠时范围较小的片区优先
+	sort.SliceStable(areas, func(i, j int) bool { return areas[i].span() < areas[j].span() })
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.areas = areas
+	g.points = points
+	return len(areas), len(points), nil
+}
+
+func parseGeoArea(geometryType string, coordinates json.RawMessage) (*geoArea, error) {
+	var raw [][][][]float64
+	if geometryType == "Polygon" {
+		var polygon [][][]float64
+		if err := json.Unmarshal(coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		raw = [][][][]float64{polygon}
+	} else if err := json.Unmarshal(coordinates, &raw); err != nil {
+		return nil, err
+	}
+
+	area := &geoArea{minLat: math.Inf(1), minLng: math.Inf(1), maxLat: math.Inf(-1), maxLng: math.Inf(-1)}
+	for _, rawPolygon := range raw {
+		var polygon [][]geoPoint
+		for r, rawRing := range rawPolygon {
+			if len(rawRing) < 4 {
+				return nil, ErrInvalidGeodata
+			}
+			ring := make([]geoPoint, 0, len(rawRing))
+			for _, position := range rawRing {
+				if len(position) < 2 {
+					return nil, ErrInvalidGeodata
+				}
+				pt := geoPoint{lat: position[1], lng: position[0]}
+				ring = append(ring, pt)
+				if r == 0 {
+					area.minLat, area.maxLat = math.Min(area.minLat, pt.lat), math.Max(area.maxLat, pt.lat)
+					area.minLng, area.maxLng = math.Min(area.minLng, pt.lng), math.Max(area.maxLng, pt.lng)
+				}
+			}
+			polygon = append(polygon, ring)
+		}
+		area.polygons = append(area.polygons, polygon)
+	}
+	if len(area.polygons) == 0 {
+		return nil, ErrInvalidGeodata
+	}
+	return area, nil
+}
+
+// LoadFile 从本地文件加载，用于新校区接入前的离线地图
+func (g *CampusGeodata) LoadFile(path string) (int, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return g.Load(data)
+}
+
+// Refresh 从主服务拉取地图；拉取或解析失败时保留当前地图
+func (g *CampusGeodata) Refresh(ctx context.Context, geodataURL string) (int, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, geodataURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("geodata endpoint returned %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, 0, err
+	}
+	return g.Load(data)
+}
+
+// StartAutoRefresh 定期从主服务刷新地图，失败时回调 onError 并沿用已有地图
+func (g *CampusGeodata) StartAutoRefresh(geodataURL string, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if _, _, err := g.Refresh(ctx, geodataURL); err != nil && onError != nil {
+			onError(err)
+		}
+		cancel()
+		<-ticker.C
+	}
+}
+
+// AreaAt 坐标所在片区的4位前缀和名称
+func (g *CampusGeodata) AreaAt(lat, lng float64) (string, string, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pt := geoPoint{lat: lat, lng: lng}
+	for _, area := range g.areas {
+		if area.contains(pt) {
+			return area.prefix, area.name, true
+		}
+	}
+	return "", "", false
+}
+
+// Locate 编码的坐标：6位投递点取点位坐标，没有时退到片区中心；4位片区前缀取片区中心
+func (g *CampusGeodata) Locate(code string) (float64, float64, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 4 && len(code) != 6 {
+		return 0, 0, false
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if pt, ok := g.points[code]; ok {
+		return pt.lat, pt.lng, true
+	}
+	for _, area := range g.areas {
+		if area.prefix == code[:4] {
+			center := area.center()
+			return center.lat, center.lng, true
+		}
+	}
+	return 0, 0, false
+}
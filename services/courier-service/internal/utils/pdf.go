@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 页面尺寸（pt）
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// pdfMargin 流式排版的页边距
+const pdfMargin = 50.0
+
+// PDFDocument 简易文本 PDF
+// 使用阅读器内置的 STSong-Light 字体显示中文，不嵌入字体文件，适合对账单、标签等纯文本文档
+type PDFDocument struct {
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+	cursorY float64
+}
+
+// NewPDFDocument 创建空白 PDF 文档
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{}
+}
+
+// AddPage 新增一页
+func (d *PDFDocument) AddPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+	d.cursorY = PDFPageHeight - pdfMargin
+}
+
+// Text 在指定位置输出一行文字，坐标原点为页面左下角
+func (d *PDFDocument) Text(x, y, size float64, text string) {
+	if d.current == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.current, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, pdfHexText(text))
+}
+
+// Rect 绘制矩形边框
+func (d *PDFDocument) Rect(x, y, width, height float64) {
+	if d.current == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.current, "0.5 w %.2f %.2f %.2f %.2f re S\n", x, y, width, height)
+}
+
+// Line 绘制直线
+func (d *PDFDocument) Line(x1, y1, x2, y2 float64) {
+	if d.current == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.current, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// WriteLine 从上到下流式输出一行文字，写满一页自动换页
+func (d *PDFDocument) WriteLine(size float64, text string) {
+	lineHeight := size * 1.5
+	if d.current == nil || d.cursorY-lineHeight < pdfMargin {
+		d.AddPage()
+	}
+	d.cursorY -= lineHeight
+	d.Text(pdfMargin, d.cursorY, size, text)
+}
+
+// Bytes 生成 PDF 文件内容
+func (d *PDFDocument) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// 1 目录 2 页面树 3-5 字体，之后每页依次为页面对象和内容流
+	const firstPageObject = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+i*2)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	object("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	object("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", PDFPageWidth, PDFPageHeight, firstPageObject+i*2+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// PDFTextWidth 估算文字宽度：ASCII 半角，其余全角
+func PDFTextWidth(size float64, text string) float64 {
+	width := 0.0
+	for _, r := range text {
+		if r < 0x80 {
+			width += size * 0.5
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// pdfHexText 将文字编码为 UCS-2 十六进制串，超出基本平面的字符以问号代替
+func pdfHexText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
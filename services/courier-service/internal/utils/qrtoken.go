@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 信件二维码签名令牌，格式与主服务 pkg/qrtoken 一致：
+// OPQ1.<kid>.<base64url claims>.<base64url Ed25519 签名>，本服务只持有公钥，仅做验证
+const qrTokenPrefix = "OPQ1"
+
+var (
+	ErrQRTokenMissing  = errors.New("扫码未携带二维码签名令牌")
+	ErrQRTokenInvalid  = errors.New("二维码签名无效，标签可能被伪造")
+	ErrQRTokenExpired  = errors.New("二维码已过期")
+	ErrQRTokenMismatch = errors.New("二维码与信件编号不符")
+)
+
+var qrTokenEncoding = base64.RawURLEncoding
+
+// QRTokenClaims 令牌声明
+type QRTokenClaims struct {
+	Code            string `json:"c"`
+	RecipientOPCode string `json:"r,omitempty"`
+	Nonce           string `json:"n"`
+	IssuedAt        int64  `json:"iat"`
+	ExpiresAt       int64  `json:"exp"`
+}
+
+// QRPublicKey 主服务 /api/v1/qr/keys 发布的公钥
+type QRPublicKey struct {
+	KeyID string `json:"kid"`
+	X     string `json:"x"`
+}
+
+// QRTokenVerifier 二维码签名验证器，公钥按 kid 索引，可定期从主服务刷新
+type QRTokenVerifier struct {
+	mu       sync.RWMutex
+	keys     map[string]ed25519.PublicKey
+	required bool
+	client   *http.Client
+}
+
+// NewQRTokenVerifier 创建验证器，required 为 true 时拒绝未携带令牌的扫码
+func NewQRTokenVerifier(required bool) *QRTokenVerifier {
+	return &QRTokenVerifier{
+		keys:     make(map[string]ed25519.PublicKey),
+		required: required,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AddKey 添加公钥，x 为 base64url 编码
+func (v *QRTokenVerifier) AddKey(kid, x string) error {
+	key, err := qrTokenEncoding.DecodeString(strings.TrimRight(x, "="))
+	if err != nil || len(key) != ed25519.PublicKeySize || kid == "" {
+		return fmt.Errorf("invalid qr public key %q", kid)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[kid] = ed25519.PublicKey(key)
+	return nil
+}
+
+// LoadKeys 加载 kid:x 逗号分隔的公钥列表
+func (v *QRTokenVerifier) LoadKeys(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, x, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("invalid qr public key entry %q, expected kid:key", entry)
+		}
+		if err := v.AddKey(kid, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh 从主服务拉取公钥，已有公钥保留以兼容轮换前的标签
+func (v *QRTokenVerifier) Refresh(ctx context.Context, keysURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keysURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("qr keys endpoint returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Keys []QRPublicKey `json:"keys"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+	for _, key := range body.Data.Keys {
+		if err := v.AddKey(key.KeyID, key.X); err != nil {
+			return 0, err
+		}
+	}
+	return len(body.Data.Keys), nil
+}
+
+// StartAutoRefresh 定期从主服务刷新公钥，失败时回调 onError 并沿用已有公钥
+func (v *QRTokenVerifier) StartAutoRefresh(keysURL string, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if _, err := v.Refresh(ctx, keysURL); err != nil && onError != nil {
+			onError(err)
+		}
+		cancel()
+		<-ticker.C
+	}
+}
+
+// KeyCount 已加载的公钥数量
+func (v *QRTokenVerifier) KeyCount() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.keys)
+}
+
+// Verify 校验令牌签名、信件编号和在 at 时刻的有效期
+// 未携带令牌时仅在强制模式下拒绝，返回的声明为 nil
+func (v *QRTokenVerifier) Verify(content, letterCode string, at time.Time) (*QRTokenClaims, error) {
+	token := ExtractQRToken(content)
+	if token == "" {
+		if v.required {
+			return nil, ErrQRTokenMissing
+		}
+		return nil, nil
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != qrTokenPrefix {
+		return nil, ErrQRTokenInvalid
+	}
+
+	v.mu.RLock()
+	key, ok := v.keys[parts[1]]
+	v.mu.RUnlock()
+	if !ok {
+		return nil, ErrQRTokenInvalid
+	}
+
+	signature, err := qrTokenEncoding.DecodeString(parts[3])
+	if err != nil || !ed25519.Verify(key, []byte(strings.Join(parts[:3], ".")), signature) {
+		return nil, ErrQRTokenInvalid
+	}
+	payload, err := qrTokenEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrQRTokenInvalid
+	}
+	var claims QRTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrQRTokenInvalid
+	}
+
+	if claims.Code != letterCode {
+		return nil, ErrQRTokenMismatch
+	}
+	if claims.ExpiresAt > 0 && at.Unix() >= claims.ExpiresAt {
+		return nil, ErrQRTokenExpired
+	}
+	return &claims, nil
+}
+
+// ExtractQRToken 从扫码内容中提取令牌，支持令牌原文和带 t 参数的阅读地址
+func ExtractQRToken(content string) string {
+	content = strings.TrimSpace(content)
+	if content == "" || strings.HasPrefix(content, qrTokenPrefix+".") {
+		return content
+	}
+	if parsed, err := url.Parse(content); err == nil {
+		if token := parsed.Query().Get("t"); token != "" {
+			return token
+		}
+	}
+	return content
+}
@@ -11,7 +11,7 @@ from app.utils.response import (
     business_error_response,
     permission_error_response
 )
-from app.utils.auth import jwt_required, get_current_user
+from app.utils.auth import jwt_required, service_key_or_jwt_required, get_current_user
 from app.services.ocr_engine import MultiEngineOCR
 from app.services.cache_service import get_cache_service
 
@@ -64,12 +64,12 @@ def save_uploaded_file(file):
 
 
 @ocr_bp.route('/recognize', methods=['POST'])
-@jwt_required
+@service_key_or_jwt_required
 def recognize_image():
     """图片OCR识别接口"""
     try:
-        # 获取当前用户
-        user_info = get_current_user()
+        # 获取当前用户（服务调用时为服务身份）
+        user_info = request.current_user
         user_id = user_info.get('user_id')
         
         # 检查文件
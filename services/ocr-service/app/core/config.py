@@ -15,6 +15,9 @@ class Config:
     JWT_SECRET = os.getenv('JWT_SECRET', 'shared-jwt-secret')
     JWT_ALGORITHM = 'HS256'
     JWT_EXPIRATION_HOURS = 24
+
+    # 后端服务调用识别接口的共享密钥，为空时只接受JWT
+    OCR_SERVICE_API_KEY = os.getenv('OCR_SERVICE_API_KEY', '')
     
     # Redis配置
     REDIS_HOST = os.getenv('REDIS_HOST', 'localhost')
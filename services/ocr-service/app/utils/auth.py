@@ -1,3 +1,4 @@
+import hmac
 import jwt
 from datetime import datetime
 from functools import wraps
@@ -66,6 +67,29 @@ def jwt_required(f):
     return decorated_function
 
 
+def service_key_or_jwt_required(f):
+    """识别接口认证：后端服务携带共享服务密钥调用，其他调用方仍需JWT"""
+    @wraps(f)
+    def decorated_function(*args, **kwargs):
+        service_key = request.headers.get('X-Service-Key')
+        if service_key is not None:
+            expected = current_app.config.get('OCR_SERVICE_API_KEY', '')
+            if not expected or not hmac.compare_digest(service_key.encode(), expected.encode()):
+                return permission_error_response("无效的服务密钥"), 403
+
+            # 服务调用不代表任何用户，仅记录发起识别的用户便于追溯
+            request.current_user = {
+                'user_id': 'service:backend',
+                'role': 'service',
+                'requested_by': request.headers.get('X-Requested-By', ''),
+            }
+            return f(*args, **kwargs)
+
+        return jwt_required(f)(*args, **kwargs)
+
+    return decorated_function
+
+
 def admin_required(f):
     """管理员权限装饰器"""
     @wraps(f)
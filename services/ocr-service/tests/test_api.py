@@ -79,6 +79,24 @@ class TestOCRAPI:
         data = json.loads(response.data)
         assert data['code'] == 2  # 权限错误
     
+    def test_recognize_service_key(self, app, client):
+        """测试后端服务使用服务密钥调用识别接口"""
+        app.config['OCR_SERVICE_API_KEY'] = 'service-key'
+
+        response = client.post('/api/ocr/recognize', headers={'X-Service-Key': 'wrong-key'})
+        assert response.status_code == 403
+
+        response = client.post('/api/ocr/recognize', headers={'X-Service-Key': 'service-key'})
+        assert response.status_code == 400
+        assert '缺少图片文件' in json.loads(response.data)['msg']
+
+    def test_recognize_service_key_not_configured(self, app, client):
+        """测试未配置服务密钥时拒绝服务调用"""
+        app.config['OCR_SERVICE_API_KEY'] = ''
+
+        response = client.post('/api/ocr/recognize', headers={'X-Service-Key': ''})
+        assert response.status_code == 403
+
     @patch('app.utils.auth.decode_jwt_token')
     def test_recognize_no_file(self, mock_decode, client):
         """测试没有上传文件的OCR识别"""